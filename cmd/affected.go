@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/helmfile/helmfile/pkg/app"
+	"github.com/helmfile/helmfile/pkg/config"
+)
+
+// NewAffectedCmd returns affected subcmd
+func NewAffectedCmd(globalCfg *config.GlobalImpl) *cobra.Command {
+	affectedOptions := config.NewAffectedOptions()
+
+	cmd := &cobra.Command{
+		Use:   "affected",
+		Short: "List the releases that transitively depend on the release via needs, or whose inputs changed since the git ref",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			affectedImpl := config.NewAffectedImpl(globalCfg, affectedOptions)
+			err := config.NewCLIConfigImpl(affectedImpl.GlobalImpl)
+			if err != nil {
+				return err
+			}
+
+			if err := affectedImpl.ValidateConfig(); err != nil {
+				return err
+			}
+
+			a := app.New(affectedImpl)
+			return toCLIError(affectedImpl.GlobalImpl, a.Affected(affectedImpl))
+		},
+	}
+
+	f := cmd.Flags()
+	f.StringVar(&affectedOptions.Release, "release", "", "the release to list the dependents of, in the form of name, namespace/name, or kubecontext/namespace/name like in needs")
+	f.StringVar(&affectedOptions.Since, "since", "", "the git ref to list the releases whose state files, values files or local charts changed since")
+	f.StringVar(&affectedOptions.Output, "output", "", `output the releases as a json string with "json", or as the selectors matching them, one per line, with "selectors"`)
+	cmd.MarkFlagsMutuallyExclusive("release", "since")
+
+	return cmd
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/helmfile/helmfile/pkg/app"
 	"github.com/helmfile/helmfile/pkg/config"
 	"github.com/helmfile/helmfile/pkg/runtime"
+	"github.com/helmfile/helmfile/pkg/state"
 )
 
 // NewApplyCmd returns apply subcmd
@@ -17,6 +18,7 @@ func NewApplyCmd(globalCfg *config.GlobalImpl) *cobra.Command {
 		Short: "Apply all resources from state file only when there are changes",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			applyImpl := config.NewApplyImpl(globalCfg, applyOptions)
+			applyImpl.Cmd = cmd
 
 			err := config.NewCLIConfigImpl(applyImpl.GlobalImpl)
 			if err != nil {
@@ -36,14 +38,18 @@ func NewApplyCmd(globalCfg *config.GlobalImpl) *cobra.Command {
 	f.StringArrayVar(&applyOptions.Set, "set", nil, "additional values to be merged into the command")
 	f.StringArrayVar(&applyOptions.Values, "values", nil, "additional value files to be merged into the command")
 	f.IntVar(&applyOptions.Concurrency, "concurrency", 0, "maximum number of concurrent helm processes to run, 0 is unlimited")
+	f.IntVar(&applyOptions.StateConcurrency, "state-concurrency", 1, `maximum number of helmfiles whose releases are processed concurrently once all the helmfiles are loaded. The helmfiles are processed after their sub-helmfiles and the helmfiles having the releases they need, and one by one when 1`)
 	f.BoolVar(&applyOptions.Validate, "validate", false, "validate your manifests against the Kubernetes cluster you are currently pointing at. Note that this requires access to a Kubernetes cluster to obtain information necessary for validating, like the list of available API versions")
 	f.IntVar(&applyOptions.Context, "context", 0, "output NUM lines of context around changes")
 	f.StringVar(&applyOptions.Output, "output", "", "output format for diff plugin")
 	f.BoolVar(&applyOptions.DetailedExitcode, "detailed-exitcode", false, "return a non-zero exit code 2 instead of 0 when there were changes detected AND the changes are synced successfully")
+	f.StringVar(&applyOptions.ChangedReleasesFile, "changed-releases-file", "", "write the releases with changes to the file as JSON")
+	f.StringVar(&applyOptions.DiffOutputDir, "diff-output-dir", "", "write the diff of each release to a file in the directory before upgrading")
+	f.StringVar(&applyOptions.DiffOutputFileTemplate, "diff-output-file-template", state.DefaultDiffOutputFileTemplate, "go text template for the path of each release's diff file relative to --diff-output-dir")
 	f.StringVar(&globalCfg.GlobalOptions.Args, "args", "", "pass args to helm exec")
 	if !runtime.V1Mode {
 		// TODO: Remove this function once Helmfile v0.x
-		f.BoolVar(&applyOptions.RetainValuesFiles, "retain-values-files", false, "DEPRECATED: Use skip-cleanup instead")
+		f.BoolVar(&applyOptions.RetainValuesFiles, "retain-values-files", false, "DEPRECATED: Use --keep-tempfiles instead")
 		_ = f.MarkDeprecated("retain-values-files", "Use skip-cleanup instead")
 	}
 
@@ -62,9 +68,16 @@ func NewApplyCmd(globalCfg *config.GlobalImpl) *cobra.Command {
 	f.BoolVar(&applyOptions.SkipDeps, "skip-deps", false, `skip running "helm repo update" and "helm dependency build"`)
 	f.BoolVar(&applyOptions.Wait, "wait", false, `Override helmDefaults.wait setting "helm upgrade --install --wait"`)
 	f.BoolVar(&applyOptions.WaitForJobs, "wait-for-jobs", false, `Override helmDefaults.waitForJobs setting "helm upgrade --install --wait-for-jobs"`)
+	f.BoolVar(&applyOptions.Atomic, "atomic", false, `Override the atomic setting of the releases and helmDefaults "helm upgrade --install --atomic"`)
+	f.BoolVar(&applyOptions.CleanupOnFail, "cleanup-on-fail", false, `Override the cleanupOnFail setting of the releases and helmDefaults "helm upgrade --install --cleanup-on-fail"`)
+	f.IntVar(&applyOptions.ReleaseTimeout, "release-timeout", 0, `Override the timeout setting of the releases and helmDefaults in seconds "helm upgrade --install --timeout". Unset by default: the timeout of the releases and helmDefaults applies`)
 	f.BoolVar(&applyOptions.ReuseValues, "reuse-values", false, `Override helmDefaults.reuseValues "helm upgrade --install --reuse-values"`)
 	f.BoolVar(&applyOptions.ResetValues, "reset-values", false, `Override helmDefaults.reuseValues "helm upgrade --install --reset-values"`)
 	f.StringVar(&applyOptions.PostRenderer, "post-renderer", "", `pass --post-renderer to "helm template" or "helm upgrade --install"`)
+	f.StringVar(&applyOptions.KubeVersionCheck, "kube-version-check", state.KubeVersionCheckOff, `what to do when the kubeVersion constraints of the charts aren't satisfied by the target Kubernetes versions. One of "off", "warn" and "fail"`)
+	f.StringVar(&applyOptions.DiffRenderer, "diff-renderer", state.DiffRendererDefault, `how to render the diff. "rich" highlights the changed words and collapses the unchanged lines, and "json" prints the result of each release as a JSON object per line. One of "default", "rich" and "json"`)
+	f.BoolVar(&applyOptions.DryRun, "dry-run", false, "print the diffs and the commands of the hooks that would run, rendered but not run, without changing any release")
+	f.BoolVar(&applyOptions.InteractivePlan, "interactive-plan", false, "print the plan of the releases to install, upgrade and delete with the numbers of the changed resources and lines, and ask for its approval once before applying it. With --interactive, each release is approved one by one instead")
 
 	return cmd
 }
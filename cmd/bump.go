@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/helmfile/helmfile/pkg/app"
+	"github.com/helmfile/helmfile/pkg/config"
+)
+
+// NewBumpCmd returns bump subcmd
+func NewBumpCmd(globalCfg *config.GlobalImpl) *cobra.Command {
+	bumpOptions := config.NewBumpOptions()
+
+	cmd := &cobra.Command{
+		Use:   "bump",
+		Short: "Update the chart versions of releases in state file to the latest ones allowed by their bumpPolicy",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			bumpImpl := config.NewBumpImpl(globalCfg, bumpOptions)
+			err := config.NewCLIConfigImpl(bumpImpl.GlobalImpl)
+			if err != nil {
+				return err
+			}
+
+			if err := bumpImpl.ValidateConfig(); err != nil {
+				return err
+			}
+
+			a := app.New(bumpImpl)
+			return toCLIError(bumpImpl.GlobalImpl, a.Bump(bumpImpl))
+		},
+	}
+
+	f := cmd.Flags()
+	f.BoolVar(&bumpOptions.DryRun, "dry-run", false, "print the newer versions without updating the state files and the lock files")
+	f.BoolVar(&bumpOptions.SkipRepos, "skip-repos", false, `skip running "helm repo add" before looking up the versions`)
+	f.StringVar(&bumpOptions.Output, "output", "", "output the version updates as a json string")
+
+	return cmd
+}
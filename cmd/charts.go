@@ -1,4 +1,3 @@
-// TODO: Remove this function once Helmfile v0.x
 package cmd
 
 import (
@@ -6,16 +5,93 @@ import (
 
 	"github.com/helmfile/helmfile/pkg/app"
 	"github.com/helmfile/helmfile/pkg/config"
+	"github.com/helmfile/helmfile/pkg/runtime"
 )
 
+// NewChartsPackageSubcommand returns charts package subcmd
+func NewChartsPackageSubcommand(globalCfg *config.GlobalImpl) *cobra.Command {
+	packageOptions := config.NewChartsPackageOptions()
+
+	cmd := &cobra.Command{
+		Use:   "package",
+		Short: "Package the local charts of releases with the versions injected by chartVersion and chartAppVersion",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			packageImpl := config.NewChartsPackageImpl(globalCfg, packageOptions)
+			err := config.NewCLIConfigImpl(packageImpl.GlobalImpl)
+			if err != nil {
+				return err
+			}
+
+			if err := packageImpl.ValidateConfig(); err != nil {
+				return err
+			}
+
+			a := app.New(packageImpl)
+			return toCLIError(packageImpl.GlobalImpl, a.PackageCharts(packageImpl))
+		},
+	}
+
+	f := cmd.Flags()
+	f.StringVar(&packageOptions.OutputDir, "output-dir", ".", "the directory to write the chart archives to")
+	f.StringVar(&packageOptions.Version, "version", "", "the version to package the charts with. defaults to the chartVersion of the release, the version of the release if it's a semantic version, or else the version in Chart.yaml")
+	f.BoolVar(&packageOptions.GitSuffix, "git-suffix", false, "append the short commit hash of the chart directory to the versions, like 1.2.0-g1a2b3c4")
+	f.StringVar(&packageOptions.Output, "output", "", "output the packaged charts as a json string")
+
+	return cmd
+}
+
+// NewChartsPushSubcommand returns charts push subcmd
+func NewChartsPushSubcommand(globalCfg *config.GlobalImpl) *cobra.Command {
+	pushOptions := config.NewChartsPushOptions()
+
+	cmd := &cobra.Command{
+		Use:   "push",
+		Short: "Package the local charts of releases, and push them to an OCI registry",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pushImpl := config.NewChartsPushImpl(globalCfg, pushOptions)
+			err := config.NewCLIConfigImpl(pushImpl.GlobalImpl)
+			if err != nil {
+				return err
+			}
+
+			if err := pushImpl.ValidateConfig(); err != nil {
+				return err
+			}
+
+			a := app.New(pushImpl)
+			return toCLIError(pushImpl.GlobalImpl, a.PushCharts(pushImpl))
+		},
+	}
+
+	f := cmd.Flags()
+	f.StringVar(&pushOptions.Registry, "registry", "", "the OCI registry to push the charts to, like oci://registry.example.com/charts")
+	f.StringVar(&pushOptions.Version, "version", "", "the version to push the charts with. defaults to the chartVersion of the release, the version of the release if it's a semantic version, or else the version in Chart.yaml")
+	f.BoolVar(&pushOptions.GitSuffix, "git-suffix", false, "append the short commit hash of the chart directory to the versions, like 1.2.0-g1a2b3c4")
+	f.BoolVar(&pushOptions.Rewrite, "rewrite", false, "rewrite the charts and the versions of the releases in the state files to the pushed ones")
+	f.BoolVar(&pushOptions.DryRun, "dry-run", false, "print the charts to be pushed without pushing them nor rewriting the state files")
+	f.StringVar(&pushOptions.Output, "output", "", "output the pushed charts as a json string")
+
+	return cmd
+}
+
 // NewChartsCmd returns charts subcmd
 func NewChartsCmd(globalCfg *config.GlobalImpl) *cobra.Command {
-	chartsOptions := config.NewChartsOptions()
-
 	cmd := &cobra.Command{
 		Use:   "charts",
-		Short: "DEPRECATED: sync releases from state file (helm upgrade --install)",
-		RunE: func(cmd *cobra.Command, args []string) error {
+		Short: "Manage the local charts of releases",
+	}
+
+	cmd.AddCommand(
+		NewChartsPackageSubcommand(globalCfg),
+		NewChartsPushSubcommand(globalCfg),
+	)
+
+	// TODO: Remove this once Helmfile v0.x
+	if !runtime.V1Mode {
+		chartsOptions := config.NewChartsOptions()
+
+		cmd.Short = "DEPRECATED: sync releases from state file (helm upgrade --install)"
+		cmd.RunE = func(cmd *cobra.Command, args []string) error {
 			chartsImpl := config.NewChartsImpl(globalCfg, chartsOptions)
 			err := config.NewCLIConfigImpl(chartsImpl.GlobalImpl)
 			if err != nil {
@@ -28,14 +104,14 @@ func NewChartsCmd(globalCfg *config.GlobalImpl) *cobra.Command {
 
 			a := app.New(chartsImpl)
 			return toCLIError(chartsImpl.GlobalImpl, a.DeprecatedSyncCharts(chartsImpl))
-		},
-	}
+		}
 
-	f := cmd.Flags()
-	f.StringVar(&globalCfg.GlobalOptions.Args, "args", "", "pass args to helm exec")
-	f.StringArrayVar(&chartsOptions.Set, "set", nil, "additional values to be merged into the command")
-	f.StringArrayVar(&chartsOptions.Values, "values", nil, "additional value files to be merged into the command")
-	f.IntVar(&chartsOptions.Concurrency, "concurrency", 0, "maximum number of concurrent helm processes to run, 0 is unlimited")
+		f := cmd.Flags()
+		f.StringVar(&globalCfg.GlobalOptions.Args, "args", "", "pass args to helm exec")
+		f.StringArrayVar(&chartsOptions.Set, "set", nil, "additional values to be merged into the command")
+		f.StringArrayVar(&chartsOptions.Values, "values", nil, "additional value files to be merged into the command")
+		f.IntVar(&chartsOptions.Concurrency, "concurrency", 0, "maximum number of concurrent helm processes to run, 0 is unlimited")
+	}
 
 	return cmd
 }
@@ -37,6 +37,9 @@ func NewDeleteCmd(globalCfg *config.GlobalImpl) *cobra.Command {
 	f.BoolVar(&deleteOptions.Purge, "purge", false, "purge releases i.e. free release names and histories")
 	f.BoolVar(&deleteOptions.SkipDeps, "skip-deps", false, `skip running "helm repo update" and "helm dependency build"`)
 	f.BoolVar(&deleteOptions.SkipCharts, "skip-charts", false, "don't prepare charts when deleting releases")
+	f.BoolVar(&deleteOptions.ReportOrphans, "report-orphans", false, "report the PVCs, Secrets and namespaces left behind by the deleted releases")
+	f.StringSliceVar(&deleteOptions.DeleteOrphans, "delete-orphans", nil, "delete the resources of the types left behind by the deleted releases, out of: pvc, secret, namespace")
+	f.StringSliceVar(&deleteOptions.KeepNamespaces, "keep-namespaces", nil, "glob patterns of the namespaces never deleted by --delete-orphans namespace, like team-*")
 
 	return cmd
 }
@@ -34,6 +34,7 @@ func NewDepsCmd(globalCfg *config.GlobalImpl) *cobra.Command {
 	f.StringVar(&globalCfg.GlobalOptions.Args, "args", "", "pass args to helm exec")
 	f.BoolVar(&depsOptions.SkipRepos, "skip-repos", false, `skip running "helm repo update" and "helm dependency build"`)
 	f.IntVar(&depsOptions.Concurrency, "concurrency", 0, "maximum number of concurrent helm processes to run, 0 is unlimited")
+	f.BoolVar(&depsOptions.UpdateLocks, "update-locks", false, "fetch the remote helmfiles and values files again and resolve the OCI charts to their digests again, to update their checksums and digests in the lock files")
 
 	return cmd
 }
@@ -35,6 +35,13 @@ func NewDestroyCmd(globalCfg *config.GlobalImpl) *cobra.Command {
 	f.IntVar(&destroyOptions.Concurrency, "concurrency", 0, "maximum number of concurrent helm processes to run, 0 is unlimited")
 	f.BoolVar(&destroyOptions.SkipDeps, "skip-deps", false, `skip running "helm repo update" and "helm dependency build"`)
 	f.BoolVar(&destroyOptions.SkipCharts, "skip-charts", false, "don't prepare charts when destroying releases")
+	f.BoolVar(&destroyOptions.ReportOrphans, "report-orphans", false, "report the PVCs, Secrets and namespaces left behind by the deleted releases")
+	f.StringSliceVar(&destroyOptions.DeleteOrphans, "delete-orphans", nil, "delete the resources of the types left behind by the deleted releases, out of: pvc, secret, namespace")
+	f.StringSliceVar(&destroyOptions.KeepNamespaces, "keep-namespaces", nil, "glob patterns of the namespaces never deleted by --delete-orphans namespace, like team-*")
+	f.BoolVar(&destroyOptions.Cascade, "cascade", false, "delete the releases tier by tier in the reverse order of their needs, waiting for each tier to be gone before the next one")
+	f.BoolVar(&destroyOptions.IncludeDependents, "include-dependents", false, "delete the releases needing the selected ones, directly or transitively, too. The included releases are listed before deleting them")
+	f.IntVar(&destroyOptions.CascadeTimeout, "cascade-timeout", 300, "seconds to wait for the resources of each release to be deleted with --cascade")
+	f.BoolVar(&destroyOptions.ContinueOnError, "continue-on-error", false, "keep deleting the remaining tiers after a tier fails to be deleted with --cascade, instead of stopping at the first failure")
 
 	return cmd
 }
@@ -5,6 +5,7 @@ import (
 
 	"github.com/helmfile/helmfile/pkg/app"
 	"github.com/helmfile/helmfile/pkg/config"
+	"github.com/helmfile/helmfile/pkg/state"
 )
 
 // NewDiffCmd returns diff subcmd
@@ -45,6 +46,7 @@ func NewDiffCmd(globalCfg *config.GlobalImpl) *cobra.Command {
 	f.BoolVar(&diffOptions.ShowSecrets, "show-secrets", false, "do not redact secret values in the output. should be used for debug purpose only")
 	f.BoolVar(&diffOptions.NoHooks, "no-hooks", false, "do not diff changes made by hooks.")
 	f.BoolVar(&diffOptions.DetailedExitcode, "detailed-exitcode", false, "return a detailed exit code")
+	f.StringVar(&diffOptions.ChangedReleasesFile, "changed-releases-file", "", "write the releases with changes to the file as JSON")
 	f.IntVar(&diffOptions.Context, "context", 0, "output NUM lines of context around changes")
 	f.StringVar(&diffOptions.Output, "output", "", "output format for diff plugin")
 	f.BoolVar(&diffOptions.SuppressSecrets, "suppress-secrets", false, "suppress secrets in the output. highly recommended to specify on CI/CD use-cases")
@@ -52,6 +54,12 @@ func NewDiffCmd(globalCfg *config.GlobalImpl) *cobra.Command {
 	f.BoolVar(&diffOptions.ReuseValues, "reuse-values", false, `Override helmDefaults.reuseValues "helm diff upgrade --install --reuse-values"`)
 	f.BoolVar(&diffOptions.ResetValues, "reset-values", false, `Override helmDefaults.reuseValues "helm diff upgrade --install --reset-values"`)
 	f.StringVar(&diffOptions.PostRenderer, "post-renderer", "", `pass --post-renderer to "helm template" or "helm upgrade --install"`)
+	f.StringVar(&diffOptions.KubeVersionCheck, "kube-version-check", state.KubeVersionCheckOff, `what to do when the kubeVersion constraints of the charts aren't satisfied by the target Kubernetes versions. One of "off", "warn" and "fail"`)
+	f.StringVar(&diffOptions.DiffRenderer, "diff-renderer", state.DiffRendererDefault, `how to render the diff. "rich" highlights the changed words and collapses the unchanged lines, and "json" prints the result of each release as a JSON object per line. One of "default", "rich" and "json"`)
+	f.BoolVar(&diffOptions.PlanHooks, "plan-hooks", false, "print the commands of the hooks that apply would run for the releases, rendered but not run. No hook is run during the diff")
+	f.BoolVar(&diffOptions.RenderCache, "render-cache", false, "skip diffing the releases found with no changes by the previous runs with --render-cache while their inputs and the deployed releases stay the same. The changes made outside of Helm aren't detected for the skipped releases")
+	f.BoolVar(&diffOptions.NoCache, "no-cache", false, "with --render-cache, diff all the releases instead of skipping the ones found with no changes by the previous runs, and record the releases found with no changes again")
+	f.StringVar(&diffOptions.CacheDir, "cache-dir", "", `the directory the releases found with no changes are recorded in under "diffs" with --render-cache. Defaults to the cache directory shown by "helmfile cache info"`)
 
 	return cmd
 }
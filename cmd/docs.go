@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/helmfile/helmfile/pkg/app"
+	"github.com/helmfile/helmfile/pkg/config"
+)
+
+// NewDocsCmd returns docs subcmd
+func NewDocsCmd(globalCfg *config.GlobalImpl) *cobra.Command {
+	docsOptions := config.NewDocsOptions()
+
+	cmd := &cobra.Command{
+		Use:   "docs",
+		Short: "Generate the Markdown documentation of the environments, the sub-helmfiles, the releases and their needs",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			docsImpl := config.NewDocsImpl(globalCfg, docsOptions)
+			err := config.NewCLIConfigImpl(docsImpl.GlobalImpl)
+			if err != nil {
+				return err
+			}
+
+			if err := docsImpl.ValidateConfig(); err != nil {
+				return err
+			}
+
+			a := app.New(docsImpl)
+			return toCLIError(docsImpl.GlobalImpl, a.Docs(docsImpl))
+		},
+	}
+
+	f := cmd.Flags()
+	f.StringVar(&docsOptions.OutputFile, "output-file", "", "write the documentation to the file instead of the standard output")
+	f.BoolVar(&docsOptions.Check, "check", false, "fail when the output file is out of date instead of writing it")
+
+	return cmd
+}
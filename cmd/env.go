@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/helmfile/helmfile/pkg/config"
+	"github.com/helmfile/helmfile/pkg/dotenv"
+	"github.com/helmfile/helmfile/pkg/envvar"
+)
+
+// flagEnvVarPrefix is the prefix of the environment variables bound to flags.
+const flagEnvVarPrefix = "HELMFILE_"
+
+// flagsWithoutEnvVar is the set of flags that have no environment variable binding.
+var flagsWithoutEnvVar = map[string]bool{
+	"help":    true,
+	"version": true,
+}
+
+// flagEnvVarAliases is the set of flags bound to the environment variables helmfile read before the flags were bound,
+// instead of the ones derived from their names, so that each flag has one environment variable.
+var flagEnvVarAliases = map[string]string{
+	"temp-dir": envvar.TempDir,
+}
+
+// flagEnvVar returns the name of the environment variable bound to the flag, e.g. HELMFILE_HELM_BINARY for --helm-binary.
+func flagEnvVar(name string) string {
+	if v, ok := flagEnvVarAliases[name]; ok {
+		return v
+	}
+	return flagEnvVarPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// loadFlagDefaults sets the flags not given on the command line to, in the order of precedence,
+// their HELMFILE_<FLAG> environment variables, the ones in the .env files, and the values in the configuration files.
+// The .env files are located by dotEnvFiles with the flags given on the command line or via the environment variables.
+func loadFlagDefaults(fs *pflag.FlagSet, dotEnvFiles func() []string) error {
+	if err := bindFlagsToEnvVars(fs); err != nil {
+		return err
+	}
+
+	if err := dotenv.Load(dotEnvFiles()...); err != nil {
+		return err
+	}
+
+	// The .env files don't override the environment variables, which are bound already
+	if err := bindFlagsToEnvVars(fs); err != nil {
+		return err
+	}
+
+	fileDefaults, err := config.LoadFileDefaults()
+	if err != nil {
+		return err
+	}
+
+	return fileDefaults.Apply(fs)
+}
+
+// bindFlagsToEnvVars sets every flag not specified on the command line to the value of its HELMFILE_<FLAG> environment variable, if any.
+func bindFlagsToEnvVars(fs *pflag.FlagSet) error {
+	var err error
+
+	fs.VisitAll(func(f *pflag.Flag) {
+		if err != nil || f.Changed || flagsWithoutEnvVar[f.Name] {
+			return
+		}
+
+		v, ok := os.LookupEnv(flagEnvVar(f.Name))
+		if !ok {
+			return
+		}
+
+		if setErr := fs.Set(f.Name, v); setErr != nil {
+			err = fmt.Errorf("setting --%s from %s: %v", f.Name, flagEnvVar(f.Name), setErr)
+		}
+	})
+
+	return err
+}
+
+// documentFlagEnvVars appends the name of the bound environment variable to the usage of every flag of the command and its sub-commands.
+func documentFlagEnvVars(c *cobra.Command) {
+	document := func(f *pflag.Flag) {
+		if flagsWithoutEnvVar[f.Name] {
+			return
+		}
+
+		note := fmt.Sprintf("[$%s]", flagEnvVar(f.Name))
+		if !strings.HasSuffix(f.Usage, note) {
+			f.Usage = fmt.Sprintf("%s %s", f.Usage, note)
+		}
+	}
+
+	c.PersistentFlags().VisitAll(document)
+	c.LocalNonPersistentFlags().VisitAll(document)
+
+	for _, sub := range c.Commands() {
+		documentFlagEnvVars(sub)
+	}
+}
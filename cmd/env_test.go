@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/config"
+	"github.com/helmfile/helmfile/pkg/envvar"
+)
+
+// unsetenv unsets the environment variable during the test, restoring it afterwards
+func unsetenv(t *testing.T, name string) {
+	t.Helper()
+
+	t.Setenv(name, "")
+	require.NoError(t, os.Unsetenv(name))
+}
+
+func newEnvTestFlags(t *testing.T, args ...string) *pflag.FlagSet {
+	t.Helper()
+
+	fs := pflag.NewFlagSet("helmfile", pflag.ContinueOnError)
+	fs.String("helm-binary", "helm", "")
+	fs.String("log-level", "info", "")
+	fs.Int("concurrency", 0, "")
+	fs.Bool("skip-deps", false, "")
+	fs.String("temp-dir", "", "")
+	fs.Bool("help", false, "")
+
+	require.NoError(t, fs.Parse(args))
+
+	return fs
+}
+
+func TestFlagEnvVar(t *testing.T) {
+	require.Equal(t, "HELMFILE_HELM_BINARY", flagEnvVar("helm-binary"))
+	require.Equal(t, "HELMFILE_SKIP_DEPS", flagEnvVar("skip-deps"))
+	require.Equal(t, envvar.TempDir, flagEnvVar("temp-dir"))
+}
+
+func TestBindFlagsToEnvVars(t *testing.T) {
+	t.Setenv("HELMFILE_HELM_BINARY", "helm-from-env")
+	t.Setenv("HELMFILE_SKIP_DEPS", "true")
+	t.Setenv("HELMFILE_CONCURRENCY", "4")
+	t.Setenv(envvar.TempDir, "/tmp/helmfile")
+	t.Setenv("HELMFILE_HELP", "true")
+	unsetenv(t, "HELMFILE_LOG_LEVEL")
+
+	fs := newEnvTestFlags(t, "--concurrency", "2")
+
+	require.NoError(t, bindFlagsToEnvVars(fs))
+
+	helmBinary, err := fs.GetString("helm-binary")
+	require.NoError(t, err)
+	require.Equal(t, "helm-from-env", helmBinary)
+
+	skipDeps, err := fs.GetBool("skip-deps")
+	require.NoError(t, err)
+	require.True(t, skipDeps)
+
+	concurrency, err := fs.GetInt("concurrency")
+	require.NoError(t, err)
+	require.Equal(t, 2, concurrency, "the flag given on the command line takes precedence")
+
+	tempDir, err := fs.GetString("temp-dir")
+	require.NoError(t, err)
+	require.Equal(t, "/tmp/helmfile", tempDir)
+
+	require.False(t, fs.Changed("help"))
+	require.False(t, fs.Changed("log-level"))
+}
+
+func TestBindFlagsToEnvVars_InvalidValue(t *testing.T) {
+	t.Setenv("HELMFILE_SKIP_DEPS", "maybe")
+
+	err := bindFlagsToEnvVars(newEnvTestFlags(t))
+	require.ErrorContains(t, err, "setting --skip-deps from HELMFILE_SKIP_DEPS")
+}
+
+func TestLoadFlagDefaults(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HELMFILE_CONCURRENCY", "3")
+	unsetenv(t, "HELMFILE_HELM_BINARY")
+	unsetenv(t, "HELMFILE_LOG_LEVEL")
+	unsetenv(t, "HELMFILE_SKIP_DEPS")
+	unsetenv(t, envvar.TempDir)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".env"), []byte("HELMFILE_HELM_BINARY=helm-from-dotenv\nHELMFILE_CONCURRENCY=2\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, config.ProjectConfigFile), []byte("helm-binary: helm-from-file\nlog-level: debug\nskip-deps: true\n"), 0644))
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	t.Cleanup(func() {
+		require.NoError(t, os.Chdir(wd))
+	})
+
+	fs := newEnvTestFlags(t, "--skip-deps=false")
+
+	require.NoError(t, loadFlagDefaults(fs, func() []string {
+		return []string{filepath.Join(dir, ".env")}
+	}))
+
+	helmBinary, err := fs.GetString("helm-binary")
+	require.NoError(t, err)
+	require.Equal(t, "helm-from-dotenv", helmBinary, "the .env files take precedence over the configuration files")
+
+	concurrency, err := fs.GetInt("concurrency")
+	require.NoError(t, err)
+	require.Equal(t, 3, concurrency, "the environment variables take precedence over the .env files")
+
+	logLevel, err := fs.GetString("log-level")
+	require.NoError(t, err)
+	require.Equal(t, "debug", logLevel)
+	require.False(t, fs.Changed("log-level"))
+
+	skipDeps, err := fs.GetBool("skip-deps")
+	require.NoError(t, err)
+	require.False(t, skipDeps, "the flag given on the command line takes precedence")
+}
+
+func TestDocumentFlagEnvVars(t *testing.T) {
+	root := &cobra.Command{Use: "helmfile"}
+	root.PersistentFlags().String("temp-dir", "", "The directory")
+
+	sync := &cobra.Command{Use: "sync"}
+	sync.Flags().Bool("skip-deps", false, "Skip the deps")
+	root.AddCommand(sync)
+
+	documentFlagEnvVars(root)
+	documentFlagEnvVars(root)
+
+	require.Equal(t, "The directory [$HELMFILE_TEMPDIR]", root.PersistentFlags().Lookup("temp-dir").Usage)
+	require.Equal(t, "Skip the deps [$HELMFILE_SKIP_DEPS]", sync.Flags().Lookup("skip-deps").Usage)
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/helmfile/helmfile/pkg/app"
+	"github.com/helmfile/helmfile/pkg/config"
+)
+
+// NewExecCmd returns exec subcmd
+func NewExecCmd(globalCfg *config.GlobalImpl) *cobra.Command {
+	execOptions := config.NewExecOptions()
+
+	cmd := &cobra.Command{
+		Use:   "exec -- HELM_COMMAND [ARGS...]",
+		Short: "Run a helm command for each release in state file, with the namespace and kube context of the release filled in",
+		Example: `  # Print the manifests of the releases in the cluster
+  helmfile exec -- get manifest
+
+  # Refer to the release with a go template, in which case the release name isn't appended to the command
+  helmfile exec -- rollback {{ .Release.Name }} 0 --wait`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			execOptions.Command = args
+
+			execImpl := config.NewExecImpl(globalCfg, execOptions)
+			err := config.NewCLIConfigImpl(execImpl.GlobalImpl)
+			if err != nil {
+				return err
+			}
+
+			if err := execImpl.ValidateConfig(); err != nil {
+				return err
+			}
+
+			a := app.New(execImpl)
+			return toCLIError(execImpl.GlobalImpl, a.Exec(execImpl))
+		},
+	}
+
+	f := cmd.Flags()
+	f.IntVar(&execOptions.Concurrency, "concurrency", 0, "maximum number of concurrent helm processes to run, 0 is unlimited")
+
+	return cmd
+}
@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"fmt"
+
 	"github.com/spf13/cobra"
 
 	"github.com/helmfile/helmfile/pkg/app"
@@ -36,6 +38,7 @@ func NewFetchCmd(globalCfg *config.GlobalImpl) *cobra.Command {
 	f.BoolVar(&fetchOptions.SkipDeps, "skip-deps", false, `skip running "helm repo update" and "helm dependency build"`)
 	f.StringVar(&fetchOptions.OutputDir, "output-dir", "", "directory to store charts (default: temporary directory which is deleted when the command terminates)")
 	f.StringVar(&fetchOptions.OutputDirTemplate, "output-dir-template", state.DefaultFetchOutputDirTemplate, "go text template for generating the output directory")
+	f.BoolVar(&fetchOptions.Vendor, "vendor", false, fmt.Sprintf("copy the charts into %s next to each helmfile along with %s, to be used instead of downloading them", state.DefaultVendorDir, state.VendorManifestFile))
 
 	return cmd
 }
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/helmfile/helmfile/pkg/app"
+	"github.com/helmfile/helmfile/pkg/config"
+)
+
+// NewGraphCmd returns graph subcmd
+func NewGraphCmd(globalCfg *config.GlobalImpl) *cobra.Command {
+	graphOptions := config.NewGraphOptions()
+
+	cmd := &cobra.Command{
+		Use:   "graph",
+		Short: "Print the graph of the needs of the releases across all the helmfiles, with the groups they are installed in and the cycles",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			graphImpl := config.NewGraphImpl(globalCfg, graphOptions)
+			err := config.NewCLIConfigImpl(graphImpl.GlobalImpl)
+			if err != nil {
+				return err
+			}
+
+			if err := graphImpl.ValidateConfig(); err != nil {
+				return err
+			}
+
+			a := app.New(graphImpl)
+			return toCLIError(graphImpl.GlobalImpl, a.Graph(graphImpl))
+		},
+	}
+
+	f := cmd.Flags()
+	f.StringVar(&graphOptions.Output, "output", "dot", `output format of the graph, one of "dot", "mermaid" or "json"`)
+
+	return cmd
+}
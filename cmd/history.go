@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/helmfile/helmfile/pkg/app"
+	"github.com/helmfile/helmfile/pkg/config"
+)
+
+// NewHistoryCmd returns history subcmd
+func NewHistoryCmd(globalCfg *config.GlobalImpl) *cobra.Command {
+	historyOptions := config.NewHistoryOptions()
+
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "Show the revision history of releases in state file",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			historyImpl := config.NewHistoryImpl(globalCfg, historyOptions)
+			err := config.NewCLIConfigImpl(historyImpl.GlobalImpl)
+			if err != nil {
+				return err
+			}
+
+			if err := historyImpl.ValidateConfig(); err != nil {
+				return err
+			}
+
+			a := app.New(historyImpl)
+			return toCLIError(historyImpl.GlobalImpl, a.History(historyImpl))
+		},
+	}
+
+	f := cmd.Flags()
+	f.IntVar(&historyOptions.Concurrency, "concurrency", 0, "maximum number of concurrent helm processes to run, 0 is unlimited")
+	f.IntVar(&historyOptions.Max, "max", 0, "maximum number of revisions to show per release, 0 is unlimited")
+	f.StringVar(&historyOptions.Output, "output", "", "output the history as a json string")
+
+	return cmd
+}
@@ -33,7 +33,7 @@ func NewListCmd(globalCfg *config.GlobalImpl) *cobra.Command {
 	f := cmd.Flags()
 	f.BoolVar(&listOptions.KeepTempDir, "keep-temp-dir", false, "Keep temporary directory")
 	f.BoolVar(&listOptions.SkipCharts, "skip-charts", false, "don't prepare charts when listing releases")
-	f.StringVar(&listOptions.Output, "output", "", "output releases list as a json string")
+	f.StringVar(&listOptions.Output, "output", "table", `output format of the releases, one of "table", "json" or "yaml"`)
 
 	return cmd
 }
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/helmfile/helmfile/pkg/app"
+	"github.com/helmfile/helmfile/pkg/config"
+	"github.com/helmfile/helmfile/pkg/state"
+)
+
+// NewPreflightCmd returns preflight subcmd
+func NewPreflightCmd(globalCfg *config.GlobalImpl) *cobra.Command {
+	preflightOptions := config.NewPreflightOptions()
+
+	cmd := &cobra.Command{
+		Use:   "preflight",
+		Short: "Render the releases, and check that their clusters are reachable and the current identity is allowed the operation on their resources, without changing anything",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			preflightImpl := config.NewPreflightImpl(globalCfg, preflightOptions)
+			err := config.NewCLIConfigImpl(preflightImpl.GlobalImpl)
+			if err != nil {
+				return err
+			}
+
+			if err := preflightImpl.ValidateConfig(); err != nil {
+				return err
+			}
+
+			a := app.New(preflightImpl)
+			return toCLIError(preflightImpl.GlobalImpl, a.Preflight(preflightImpl))
+		},
+	}
+
+	f := cmd.Flags()
+	f.StringVar(&preflightOptions.Operation, "operation", state.PreflightOperationSync, "the operation to check the permissions for. One of diff, sync (also for apply), or destroy")
+	f.StringVar(&preflightOptions.Output, "output", "", `output the results of the checks as a json string with "json"`)
+	f.IntVar(&preflightOptions.Concurrency, "concurrency", 0, "maximum number of concurrent helm processes to run, 0 is unlimited")
+	f.BoolVar(&preflightOptions.SkipDeps, "skip-deps", false, `skip running "helm repo update" and "helm dependency build"`)
+	f.StringArrayVar(&preflightOptions.Set, "set", nil, "additional values to be merged into the helm command --set flag")
+	f.StringArrayVar(&preflightOptions.Values, "values", nil, "additional value files to be merged into the helm command --values flag")
+
+	return cmd
+}
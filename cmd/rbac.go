@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/helmfile/helmfile/pkg/app"
+	"github.com/helmfile/helmfile/pkg/config"
+)
+
+// NewRBACCmd returns rbac subcmd
+func NewRBACCmd(globalCfg *config.GlobalImpl) *cobra.Command {
+	rbacOptions := config.NewRBACOptions()
+
+	cmd := &cobra.Command{
+		Use:   "rbac",
+		Short: "Print the Roles and the ClusterRoles allowing the minimal permissions to apply the rendered manifests of the releases",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rbacImpl := config.NewRBACImpl(globalCfg, rbacOptions)
+			err := config.NewCLIConfigImpl(rbacImpl.GlobalImpl)
+			if err != nil {
+				return err
+			}
+
+			if err := rbacImpl.ValidateConfig(); err != nil {
+				return err
+			}
+
+			a := app.New(rbacImpl)
+			return toCLIError(rbacImpl.GlobalImpl, a.RBAC(rbacImpl))
+		},
+	}
+
+	f := cmd.Flags()
+	f.IntVar(&rbacOptions.Concurrency, "concurrency", 0, "maximum number of concurrent helm processes to run, 0 is unlimited")
+	f.BoolVar(&rbacOptions.SkipDeps, "skip-deps", false, `skip running "helm repo update" and "helm dependency build"`)
+	f.StringArrayVar(&rbacOptions.Set, "set", nil, "additional values to be merged into the helm command --set flag")
+	f.StringArrayVar(&rbacOptions.Values, "values", nil, "additional value files to be merged into the helm command --values flag")
+	f.StringVar(&rbacOptions.Name, "name", "helmfile-deployer", "the name of the Roles and the ClusterRoles")
+
+	return cmd
+}
@@ -29,11 +29,11 @@ func toCLIError(g *config.GlobalImpl, err error) error {
 			if g.AllowNoMatchingRelease {
 				noMatchingExitCode = 0
 			}
-			return errors.NewExitError(e.Error(), noMatchingExitCode)
+			return errors.NewExitError(e, noMatchingExitCode)
 		case *app.MultiError:
-			return errors.NewExitError(e.Error(), 1)
+			return errors.NewExitError(e, 1)
 		case *app.Error:
-			return errors.NewExitError(e.Error(), e.Code())
+			return errors.NewExitError(e, e.Code())
 		default:
 			panic(fmt.Errorf("BUG: please file an github issue for this unhandled error: %T: %v", e, e))
 		}
@@ -43,6 +43,8 @@ func toCLIError(g *config.GlobalImpl, err error) error {
 
 // NewRootCmd creates the root command for the CLI.
 func NewRootCmd(globalConfig *config.GlobalOptions) (*cobra.Command, error) {
+	globalImpl := config.NewGlobalImpl(globalConfig)
+
 	cmd := &cobra.Command{
 		Use:           "helmfile",
 		Short:         globalUsage,
@@ -52,6 +54,19 @@ func NewRootCmd(globalConfig *config.GlobalOptions) (*cobra.Command, error) {
 		SilenceUsage:  true,
 		SilenceErrors: true,
 		PersistentPreRunE: func(c *cobra.Command, args []string) error {
+			// Flags not set on the command line default to their HELMFILE_<FLAG> environment variables, including the ones in the .env files,
+			// and then to the values in the user-level and project-local configuration files
+			if err := loadFlagDefaults(c.Flags(), globalImpl.DotEnvFiles); err != nil {
+				return err
+			}
+
+			if err := globalImpl.LoadSelectorFile(); err != nil {
+				return err
+			}
+
+			// The run stops on SIGINT and SIGTERM, which cancel the context given by main.go, and on --run-timeout
+			globalImpl.SetRunContext(c.Context())
+
 			// Valid levels:
 			// https://github.com/uber-go/zap/blob/7e7e266a8dbce911a49554b945538c5b950196b8/zapcore/level.go#L126
 			logLevel := globalConfig.LogLevel
@@ -65,7 +80,12 @@ func NewRootCmd(globalConfig *config.GlobalOptions) (*cobra.Command, error) {
 			globalConfig.SetLogger(logger)
 			return nil
 		},
+		PersistentPostRun: func(*cobra.Command, []string) {
+			// The failed run exits the process, which releases the timer of --run-timeout along with it
+			globalImpl.StopRun()
+		},
 	}
+
 	flags := cmd.PersistentFlags()
 
 	// Set the global options for the root command.
@@ -73,8 +93,6 @@ func NewRootCmd(globalConfig *config.GlobalOptions) (*cobra.Command, error) {
 
 	flags.ParseErrorsWhitelist.UnknownFlags = true
 
-	globalImpl := config.NewGlobalImpl(globalConfig)
-
 	// when set environment HELMFILE_UPGRADE_NOTICE_DISABLED any value, skip upgrade notice.
 	var versionOpts []extension.CobraOption
 	if os.Getenv(envvar.UpgradeNoticeDisabled) == "" {
@@ -98,6 +116,18 @@ func NewRootCmd(globalConfig *config.GlobalOptions) (*cobra.Command, error) {
 		NewSyncCmd(globalImpl),
 		NewDiffCmd(globalImpl),
 		NewStatusCmd(globalImpl),
+		NewExecCmd(globalImpl),
+		NewHistoryCmd(globalImpl),
+		NewBumpCmd(globalImpl),
+		NewWatchCmd(globalImpl),
+		NewAffectedCmd(globalImpl),
+		NewGraphCmd(globalImpl),
+		NewPreflightCmd(globalImpl),
+		NewRBACCmd(globalImpl),
+		NewValidateCmd(globalImpl),
+		NewDocsCmd(globalImpl),
+		NewChartsCmd(globalImpl),
+		NewSnapshotCmd(globalImpl),
 		extension.NewVersionCobraCmd(
 			versionOpts...,
 		),
@@ -106,11 +136,12 @@ func NewRootCmd(globalConfig *config.GlobalOptions) (*cobra.Command, error) {
 	// TODO: Remove this function once Helmfile v0.x
 	if !runtime.V1Mode {
 		cmd.AddCommand(
-			NewChartsCmd(globalImpl),
 			NewDeleteCmd(globalImpl),
 		)
 	}
 
+	documentFlagEnvVars(cmd)
+
 	return cmd, nil
 }
 
@@ -119,6 +150,8 @@ func setGlobalOptionsForRootCmd(fs *pflag.FlagSet, globalOptions *config.GlobalO
 	fs.StringVarP(&globalOptions.File, "file", "f", "", "load config from file or directory. defaults to `helmfile.yaml` or `helmfile.d`(means `helmfile.d/*.yaml`) in this preference. Specify - to load the config from the standard input.")
 	fs.StringVarP(&globalOptions.Environment, "environment", "e", "", `specify the environment name. defaults to "default"`)
 	fs.StringArrayVar(&globalOptions.StateValuesSet, "state-values-set", nil, "set state values on the command line (can specify multiple or separate values with commas: key1=val1,key2=val2)")
+	fs.StringArrayVar(&globalOptions.StateValuesSetString, "state-values-set-string", nil, "set state values on the command line as strings (can specify multiple or separate values with commas: key1=val1,key2=val2)")
+	fs.StringArrayVar(&globalOptions.StateValuesSetFile, "state-values-set-file", nil, "set state values from the contents of files on the command line (can specify multiple or separate values with commas: key1=path1,key2=path2)")
 	fs.StringArrayVar(&globalOptions.StateValuesFile, "state-values-file", nil, "specify state values in a YAML file")
 	fs.BoolVarP(&globalOptions.Quiet, "quiet", "q", false, "Silence output. Equivalent to log-level warn")
 	fs.StringVar(&globalOptions.KubeContext, "kube-context", "", "Set kubectl context. Uses current context by default")
@@ -126,16 +159,35 @@ func setGlobalOptionsForRootCmd(fs *pflag.FlagSet, globalOptions *config.GlobalO
 	fs.BoolVar(&globalOptions.Color, "color", false, "Output with color")
 	fs.BoolVar(&globalOptions.NoColor, "no-color", false, "Output without color")
 	fs.StringVar(&globalOptions.LogLevel, "log-level", "info", "Set log level, default info")
+	fs.StringVar(&globalOptions.ErrorOutput, "error-output", "text", `How to print the error on exit. "text" prints the error message, and "json" prints it along with the stable codes and the state files, the releases and the phases of the causes in JSON`)
+	fs.StringVar(&globalOptions.LogOutput, "log-output", "interleaved", `How to print the logs of the releases installed and upgraded concurrently by sync and apply. "interleaved" prints them as they come, and "grouped" prints the ones of each release as a block on its completion`)
 	fs.StringVarP(&globalOptions.Namespace, "namespace", "n", "", "Set namespace. Uses the namespace set in the context by default, and is available in templates as {{ .Namespace }}")
 	fs.StringVarP(&globalOptions.Chart, "chart", "c", "", "Set chart. Uses the chart set in release by default, and is available in template as {{ .Chart }}")
 	fs.StringArrayVarP(&globalOptions.Selector, "selector", "l", nil, `Only run using the releases that match labels. Labels can take the form of foo=bar or foo!=bar. 
 A release must match all labels in a group in order to be used. Multiple groups can be specified at once.
 "--selector tier=frontend,tier!=proxy --selector tier=backend" will match all frontend, non-proxy releases AND all backend releases.
 The name of a release can be used as a label: "--selector name=myrelease"`)
+	fs.StringArrayVar(&globalOptions.HelmfileSelector, "helmfile-selector", nil, `Only load the sub-helmfiles whose labels match, along with their nested sub-helmfiles. Labels take the same form as --selector, and the name of a sub-helmfile can be used as a label.
+"--helmfile-selector team=platform" will load only the sub-helmfiles labeled team=platform, skipping the others without rendering them`)
+	fs.StringArrayVar(&globalOptions.RepositoryMirror, "repository-mirror", nil, `Use the mirror for the repositories and the OCI charts whose URLs match, in the form of FROM=TO. Can be specified multiple times.
+"--repository-mirror 'https://charts.bitnami.com/*=https://nexus.internal/bitnami'" adds the bitnami repositories from the mirror. Takes precedence over repositoryMirrors in the state files`)
+	fs.StringArrayVar(&globalOptions.CommandTimeout, "command-timeout", nil, `Kill the helm commands running longer than the timeout along with their child processes, like 10m for all the commands or upgrade=30m for the command type, which is the helm subcommand like upgrade, diff, pull and repo. Can be specified multiple times.
+The commandTimeout of helmDefaults and the releases takes precedence for the helm commands of the releases`)
+	fs.DurationVar(&globalOptions.RunTimeout, "run-timeout", 0, `Stop the whole run after the duration like 30m, stopping the helm commands running and skipping the remaining releases, and report which releases completed.
+SIGINT and SIGTERM stop the run in the same way. No timeout by default`)
+	fs.StringArrayVar(&globalOptions.CommandLiveOutput, "command-live-output", nil, `Whether to show live output of the helm commands of the type, like upgrade=true or diff=false, taking precedence over --enable-live-output. Can be specified multiple times`)
+	fs.StringVar(&globalOptions.TempDir, "temp-dir", "", `The directory to create the working directory of each run in, which contains the temporary files like the generated values files and is removed at the end of the run. Defaults to HELMFILE_TEMPDIR, or the system's temporary directory`)
+	fs.BoolVar(&globalOptions.KeepTempFiles, "keep-tempfiles", false, `Keep the working directory of the run containing the temporary files, and print its path, for debugging`)
+	fs.BoolVar(&globalOptions.Offline, "offline", false, `Run without network access to the chart repositories, the OCI registries, and the remote helmfiles and values files. The repositories aren't updated, and only the charts vendored by "helmfile fetch --vendor", the local charts and the ones in the cache are used.
+Fails before installing anything, listing the charts missing from them`)
+	fs.BoolVar(&globalOptions.AllowClusterLookups, "allow-cluster-lookups", false, `Allow the templates to query the cluster of --kube-context, or of the current context, with the kubectlGet template function, for the states that adapt to the live cluster data`)
+	fs.StringVar(&globalOptions.SelectorFile, "selector-file", "", `Load additional selectors from the file, either as a YAML list or one selector per line. Lines starting with "#" are comments`)
 	fs.BoolVar(&globalOptions.AllowNoMatchingRelease, "allow-no-matching-release", false, `Do not exit with an error code if the provided selector has no matching releases.`)
 	fs.BoolVar(&globalOptions.EnableLiveOutput, "enable-live-output", globalOptions.EnableLiveOutput, `Show live output from the Helm binary Stdout/Stderr into Helmfile own Stdout/Stderr.
 It only applies for the Helm CLI commands, Stdout/Stderr for Hooks are still displayed only when it's execution finishes.`)
 	fs.BoolVarP(&globalOptions.Interactive, "interactive", "i", false, "Request confirmation before attempting to modify clusters")
+	fs.BoolVar(&globalOptions.DotEnv, "dotenv", false, `Load environment variables from .env, .helmfile.env, .env.<environment>, and .helmfile.<environment>.env found in the directory of the state file, in this order of increasing precedence.
+Variables already set in the environment are never overridden.`)
 	// avoid 'pflag: help requested' error (#251)
 	fs.BoolP("help", "h", false, "help for helmfile")
 }
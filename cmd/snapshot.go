@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/helmfile/helmfile/pkg/app"
+	"github.com/helmfile/helmfile/pkg/config"
+)
+
+// newSnapshotSubcommand returns the snapshot subcmd running f
+func newSnapshotSubcommand(globalCfg *config.GlobalImpl, use, short string, f func(*app.App, *config.SnapshotImpl) error) *cobra.Command {
+	snapshotOptions := config.NewSnapshotOptions()
+
+	cmd := &cobra.Command{
+		Use:   use,
+		Short: short,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			snapshotImpl := config.NewSnapshotImpl(globalCfg, snapshotOptions)
+			err := config.NewCLIConfigImpl(snapshotImpl.GlobalImpl)
+			if err != nil {
+				return err
+			}
+
+			if err := snapshotImpl.ValidateConfig(); err != nil {
+				return err
+			}
+
+			a := app.New(snapshotImpl)
+			return toCLIError(snapshotImpl.GlobalImpl, f(a, snapshotImpl))
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&globalCfg.GlobalOptions.Args, "args", "", "pass args to helm template")
+	flags.StringVar(&snapshotOptions.Dir, "snapshot-dir", "snapshots", "the directory the snapshots are stored in")
+	flags.StringArrayVar(&snapshotOptions.Set, "set", nil, "additional values to be merged into the command")
+	flags.StringArrayVar(&snapshotOptions.Values, "values", nil, "additional value files to be merged into the command")
+	flags.IntVar(&snapshotOptions.Concurrency, "concurrency", 0, "maximum number of concurrent helm processes to run, 0 is unlimited")
+	flags.BoolVar(&snapshotOptions.IncludeCRDs, "include-crds", false, "include CRDs in the snapshots")
+	flags.BoolVar(&snapshotOptions.SkipTests, "skip-tests", false, "skip tests from the snapshots")
+	flags.BoolVar(&snapshotOptions.SkipDeps, "skip-deps", false, `skip running "helm repo update" and "helm dependency build"`)
+
+	return cmd
+}
+
+// NewSnapshotCmd returns snapshot subcmd
+func NewSnapshotCmd(globalCfg *config.GlobalImpl) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "snapshot",
+		Short: "Store the rendered manifests of releases as snapshots, and verify the releases still render the same",
+	}
+
+	cmd.AddCommand(
+		newSnapshotSubcommand(globalCfg, "write", "Render the releases, and write their manifests to the snapshot directory", func(a *app.App, c *config.SnapshotImpl) error {
+			return a.WriteSnapshots(c)
+		}),
+		newSnapshotSubcommand(globalCfg, "verify", "Render the releases, and fail with the diffs when their manifests differ from the snapshots", func(a *app.App, c *config.SnapshotImpl) error {
+			return a.VerifySnapshots(c)
+		}),
+	)
+
+	return cmd
+}
@@ -33,6 +33,7 @@ func NewStatusCmd(globalCfg *config.GlobalImpl) *cobra.Command {
 	f := cmd.Flags()
 	f.StringVar(&globalCfg.GlobalOptions.Args, "args", "", "pass args to helm exec")
 	f.IntVar(&statusOptions.Concurrency, "concurrency", 0, "maximum number of concurrent helm processes to run, 0 is unlimited")
+	f.StringVar(&statusOptions.Output, "output", "", `output the statuses of all the releases in one table with "table", or as a json string with "json", instead of the outputs of helm status`)
 
 	return cmd
 }
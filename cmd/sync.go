@@ -5,6 +5,7 @@ import (
 
 	"github.com/helmfile/helmfile/pkg/app"
 	"github.com/helmfile/helmfile/pkg/config"
+	"github.com/helmfile/helmfile/pkg/state"
 )
 
 // NewSyncCmd returns sync subcmd
@@ -16,6 +17,7 @@ func NewSyncCmd(globalCfg *config.GlobalImpl) *cobra.Command {
 		Short: "Sync releases defined in state file",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			syncImpl := config.NewSyncImpl(globalCfg, syncOptions)
+			syncImpl.Cmd = cmd
 			err := config.NewCLIConfigImpl(syncImpl.GlobalImpl)
 			if err != nil {
 				return err
@@ -35,6 +37,7 @@ func NewSyncCmd(globalCfg *config.GlobalImpl) *cobra.Command {
 	f.StringArrayVar(&syncOptions.Set, "set", nil, "additional values to be merged into the command")
 	f.StringArrayVar(&syncOptions.Values, "values", nil, "additional value files to be merged into the command")
 	f.IntVar(&syncOptions.Concurrency, "concurrency", 0, "maximum number of concurrent helm processes to run, 0 is unlimited")
+	f.IntVar(&syncOptions.StateConcurrency, "state-concurrency", 1, `maximum number of helmfiles whose releases are processed concurrently once all the helmfiles are loaded. The helmfiles are processed after their sub-helmfiles and the helmfiles having the releases they need, and one by one when 1`)
 	f.BoolVar(&syncOptions.Validate, "validate", false, "validate your manifests against the Kubernetes cluster you are currently pointing at. Note that this requires access to a Kubernetes cluster to obtain information necessary for validating, like the sync of available API versions")
 	f.BoolVar(&syncOptions.SkipNeeds, "skip-needs", true, `do not automatically include releases from the target release's "needs" when --selector/-l flag is provided. Does nothing when --selector/-l flag is not provided. Defaults to true when --include-needs or --include-transitive-needs is not provided`)
 	f.BoolVar(&syncOptions.SkipCRDs, "skip-crds", false, "if set, no CRDs will be installed on sync. By default, CRDs are installed if not already present")
@@ -43,9 +46,13 @@ func NewSyncCmd(globalCfg *config.GlobalImpl) *cobra.Command {
 	f.BoolVar(&syncOptions.SkipDeps, "skip-deps", false, `skip running "helm repo update" and "helm dependency build"`)
 	f.BoolVar(&syncOptions.Wait, "wait", false, `Override helmDefaults.wait setting "helm upgrade --install --wait"`)
 	f.BoolVar(&syncOptions.WaitForJobs, "wait-for-jobs", false, `Override helmDefaults.waitForJobs setting "helm upgrade --install --wait-for-jobs"`)
+	f.BoolVar(&syncOptions.Atomic, "atomic", false, `Override the atomic setting of the releases and helmDefaults "helm upgrade --install --atomic"`)
+	f.BoolVar(&syncOptions.CleanupOnFail, "cleanup-on-fail", false, `Override the cleanupOnFail setting of the releases and helmDefaults "helm upgrade --install --cleanup-on-fail"`)
+	f.IntVar(&syncOptions.ReleaseTimeout, "release-timeout", 0, `Override the timeout setting of the releases and helmDefaults in seconds "helm upgrade --install --timeout". Unset by default: the timeout of the releases and helmDefaults applies`)
 	f.BoolVar(&syncOptions.ReuseValues, "reuse-values", false, `Override helmDefaults.reuseValues "helm upgrade --install --reuse-values"`)
 	f.BoolVar(&syncOptions.ResetValues, "reset-values", false, `Override helmDefaults.reuseValues "helm upgrade --install --reset-values"`)
 	f.StringVar(&syncOptions.PostRenderer, "post-renderer", "", `pass --post-renderer to "helm template" or "helm upgrade --install"`)
+	f.StringVar(&syncOptions.KubeVersionCheck, "kube-version-check", state.KubeVersionCheckOff, `what to do when the kubeVersion constraints of the charts aren't satisfied by the target Kubernetes versions. One of "off", "warn" and "fail"`)
 
 	return cmd
 }
@@ -40,6 +40,11 @@ func NewTemplateCmd(globalCfg *config.GlobalImpl) *cobra.Command {
 	f.BoolVar(&templateOptions.Validate, "validate", false, "validate your manifests against the Kubernetes cluster you are currently pointing at. Note that this requires access to a Kubernetes cluster to obtain information necessary for validating, like the template of available API versions")
 	f.BoolVar(&templateOptions.IncludeCRDs, "include-crds", false, "include CRDs in the templated output")
 	f.BoolVar(&templateOptions.SkipTests, "skip-tests", false, "skip tests from templated output")
+	f.StringArrayVar(&templateOptions.ShowOnly, "show-only", nil, `only show the templates rendered from the given paths, like "templates/deployment.yaml" for all the releases or "myapp:templates/deployment.yaml" for the release named myapp. Releases with no template to show are skipped`)
+	f.BoolVar(&templateOptions.AnnotateSource, "annotate-source", false, "prefix each rendered manifest with comments identifying the state file, release and chart it came from")
+	f.BoolVar(&templateOptions.RenderCache, "render-cache", false, "cache the rendered manifests of each release in the cache directory, and reuse them while its chart, values and flags stay the same")
+	f.BoolVar(&templateOptions.NoCache, "no-cache", false, "with --render-cache, render the manifests of all the releases instead of reusing the cached ones, and cache them again")
+	f.StringVar(&templateOptions.CacheDir, "cache-dir", "", `the directory the rendered manifests are cached in under "templates" with --render-cache. Defaults to the cache directory shown by "helmfile cache info"`)
 	f.BoolVar(&templateOptions.SkipNeeds, "skip-needs", true, `do not automatically include releases from the target release's "needs" when --selector/-l flag is provided. Does nothing when --selector/-l flag is not provided. Defaults to true when --include-needs or --include-transitive-needs is not provided`)
 	f.BoolVar(&templateOptions.IncludeNeeds, "include-needs", false, `automatically include releases from the target release's "needs" when --selector/-l flag is provided. Does nothing when --selector/-l flag is not provided`)
 	f.BoolVar(&templateOptions.IncludeTransitiveNeeds, "include-transitive-needs", false, `like --include-needs, but also includes transitive needs (needs of needs). Does nothing when --selector/-l flag is not provided. Overrides exclusions of other selectors and conditions.`)
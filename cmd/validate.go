@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/helmfile/helmfile/pkg/app"
+	"github.com/helmfile/helmfile/pkg/config"
+)
+
+// NewValidateCmd returns validate subcmd
+func NewValidateCmd(globalCfg *config.GlobalImpl) *cobra.Command {
+	validateOptions := config.NewValidateOptions()
+
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Validate the rendered manifests of the releases against the schemas of the resources and the rego policies",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			validateImpl := config.NewValidateImpl(globalCfg, validateOptions)
+			err := config.NewCLIConfigImpl(validateImpl.GlobalImpl)
+			if err != nil {
+				return err
+			}
+
+			if err := validateImpl.ValidateConfig(); err != nil {
+				return err
+			}
+
+			a := app.New(validateImpl)
+			return toCLIError(validateImpl.GlobalImpl, a.Validate(validateImpl))
+		},
+	}
+
+	f := cmd.Flags()
+	f.IntVar(&validateOptions.Concurrency, "concurrency", 0, "maximum number of concurrent helm processes to run, 0 is unlimited")
+	f.BoolVar(&validateOptions.SkipDeps, "skip-deps", false, `skip running "helm repo update" and "helm dependency build"`)
+	f.StringArrayVar(&validateOptions.Set, "set", nil, "additional values to be merged into the helm command --set flag")
+	f.StringArrayVar(&validateOptions.Values, "values", nil, "additional value files to be merged into the helm command --values flag")
+	f.StringArrayVar(&validateOptions.SchemaLocations, "schema-location", nil, "the location of the JSON schemas passed to kubeconform. Can be specified multiple times")
+	f.BoolVar(&validateOptions.Strict, "strict", false, "disallow the fields not in the schemas")
+	f.BoolVar(&validateOptions.IgnoreMissingSchemas, "ignore-missing-schemas", false, "skip the resources without the schemas, like the custom resources, instead of failing")
+	f.StringArrayVar(&validateOptions.Policies, "policy", nil, "the file or the directory of the rego policies checked with conftest. Can be specified multiple times")
+	f.StringVar(&validateOptions.Output, "output", "", `output the violations as a json string with "json"`)
+
+	return cmd
+}
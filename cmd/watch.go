@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/helmfile/helmfile/pkg/app"
+	"github.com/helmfile/helmfile/pkg/config"
+)
+
+// NewWatchCmd returns watch subcmd
+func NewWatchCmd(globalCfg *config.GlobalImpl) *cobra.Command {
+	watchOptions := config.NewWatchOptions()
+
+	cmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Watch state files, values files and local charts, and re-run diff or template on the releases affected by changes",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			watchImpl := config.NewWatchImpl(globalCfg, watchOptions)
+			err := config.NewCLIConfigImpl(watchImpl.GlobalImpl)
+			if err != nil {
+				return err
+			}
+
+			if err := watchImpl.ValidateConfig(); err != nil {
+				return err
+			}
+
+			a := app.New(watchImpl)
+
+			var run func() error
+			switch watchImpl.Command() {
+			case "diff":
+				diffImpl := config.NewDiffImpl(globalCfg, watchImpl.DiffOptions())
+				run = func() error { return a.Diff(diffImpl) }
+			case "template":
+				templateImpl := config.NewTemplateImpl(globalCfg, watchImpl.TemplateOptions())
+				run = func() error { return a.Template(templateImpl) }
+			default:
+				return fmt.Errorf("unsupported command %q: must be either diff or template", watchImpl.Command())
+			}
+
+			return toCLIError(watchImpl.GlobalImpl, a.Watch(watchImpl, run))
+		},
+	}
+
+	f := cmd.Flags()
+	f.StringVar(&watchOptions.Command, "command", "diff", "the command to re-run on changes, either diff or template")
+	f.DurationVar(&watchOptions.Interval, "interval", time.Second, "the interval to check the files for changes")
+	f.StringArrayVar(&watchOptions.Set, "set", nil, "additional values to be merged into the command")
+	f.StringArrayVar(&watchOptions.Values, "values", nil, "additional value files to be merged into the command")
+	f.BoolVar(&watchOptions.SkipDeps, "skip-deps", false, `skip running "helm repo update" and "helm dependency build"`)
+	f.IntVar(&watchOptions.Concurrency, "concurrency", 0, "maximum number of concurrent helm processes to run, 0 is unlimited")
+	f.DurationVar(&watchOptions.DriftInterval, "drift-interval", 0, "the interval to re-run diff on all the selected releases to detect the drifts of the releases, like 10m. Disabled when 0")
+	f.StringVar(&watchOptions.DriftWebhook, "drift-webhook", "", "the URL to post the drifts detected to as JSON")
+	f.BoolVar(&watchOptions.ExitOnDrift, "exit-on-drift", false, "stop watching with the exit code 2 once a drift is detected")
+
+	return cmd
+}
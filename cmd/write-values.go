@@ -36,6 +36,7 @@ func NewWriteValuesCmd(globalCfg *config.GlobalImpl) *cobra.Command {
 	f.StringArrayVar(&writeValuesOptions.Set, "set", nil, "additional values to be merged into the command")
 	f.StringArrayVar(&writeValuesOptions.Values, "values", nil, "additional value files to be merged into the command")
 	f.StringVar(&writeValuesOptions.OutputFileTemplate, "output-file-template", "", "go text template for generating the output file. Default: {{ .State.BaseName }}-{{ .State.AbsPathSHA1 }}/{{ .Release.Name}}.yaml")
+	f.BoolVar(&writeValuesOptions.IncludeChartValues, "include-chart-values", false, "merge the values into the default values of the charts, writing all the values the releases are rendered with")
 
 	return cmd
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/signal"
@@ -17,15 +18,20 @@ func main() {
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
 
+	// The signals cancel the context of the run, which kills the helm commands running and skips the remaining releases
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
 	go func() {
 		sig = <-sigs
+		cancel()
 	}()
 
 	globalConfig := new(config.GlobalOptions)
 	rootCmd, err := cmd.NewRootCmd(globalConfig)
 	errors.HandleExitCoder(err)
 
-	if err := rootCmd.Execute(); err != nil {
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
 		if sig != nil {
 			fmt.Fprintln(os.Stderr, err)
 			app.CleanWaitGroup.Wait()
@@ -38,6 +44,9 @@ func main() {
 				os.Exit(143)
 			}
 		}
+		if globalConfig.ErrorOutput == errors.OutputJSON {
+			err = errors.ToJSON(err)
+		}
 		errors.HandleExitCoder(err)
 	}
 }
@@ -0,0 +1,258 @@
+package app
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/helmfile/helmfile/pkg/helmexec"
+	"github.com/helmfile/helmfile/pkg/state"
+)
+
+// AffectedRelease is a release that transitively depends on the queried release via needs
+type AffectedRelease struct {
+	ID string `json:"id"`
+	// Selector is the selector matching only the release, like `name=web,namespace=default`
+	Selector    string `json:"selector"`
+	Name        string `json:"name"`
+	Namespace   string `json:"namespace"`
+	KubeContext string `json:"kubeContext"`
+	// Depth is the number of needs between the release and the queried release, which is 1 when it needs the queried release directly
+	Depth int `json:"depth"`
+	// Via is the ID of the release it needs on the way to the queried release
+	Via string `json:"via"`
+	// Helmfile is the state file the release is defined in
+	Helmfile string `json:"helmfile"`
+}
+
+// affectedNode is a release along with the state file it is defined in
+type affectedNode struct {
+	release  state.ReleaseSpec
+	helmfile string
+}
+
+// matchesReleaseRef returns true if the release is the one referenced like in needs,
+// that is either `name`, `namespace/name`, or `kubecontext/namespace/name`
+func matchesReleaseRef(r *state.ReleaseSpec, ref string) bool {
+	if state.ReleaseToID(r) == ref {
+		return true
+	}
+
+	components := strings.Split(ref, "/")
+	if components[len(components)-1] != r.Name {
+		return false
+	}
+	if len(components) > 1 && components[len(components)-2] != r.Namespace {
+		return false
+	}
+	if len(components) > 2 && strings.Join(components[:len(components)-2], "/") != r.KubeContext {
+		return false
+	}
+
+	return true
+}
+
+// affectedReleases returns the releases transitively depending on the referenced release via needs, sorted by depth and ID
+func affectedReleases(nodes []affectedNode, ref string) ([]AffectedRelease, error) {
+	dependents := map[string][]affectedNode{}
+	for _, n := range nodes {
+		for _, need := range n.release.Needs {
+			dependents[need] = append(dependents[need], n)
+		}
+	}
+
+	var queue []string
+	visited := map[string]bool{}
+	for i := range nodes {
+		if matchesReleaseRef(&nodes[i].release, ref) {
+			id := state.ReleaseToID(&nodes[i].release)
+			if !visited[id] {
+				visited[id] = true
+				queue = append(queue, id)
+			}
+		}
+	}
+	if len(queue) == 0 {
+		return nil, fmt.Errorf("no release found for %q", ref)
+	}
+
+	affected := []AffectedRelease{}
+	for depth := 1; len(queue) > 0; depth++ {
+		var next []string
+		for _, via := range queue {
+			for _, n := range dependents[via] {
+				id := state.ReleaseToID(&n.release)
+				if visited[id] {
+					continue
+				}
+				visited[id] = true
+				next = append(next, id)
+
+				affected = append(affected, AffectedRelease{
+					ID:          id,
+					Selector:    releaseSelector(&n.release),
+					Name:        n.release.Name,
+					Namespace:   n.release.Namespace,
+					KubeContext: n.release.KubeContext,
+					Depth:       depth,
+					Via:         via,
+					Helmfile:    n.helmfile,
+				})
+			}
+		}
+		queue = next
+	}
+
+	sort.SliceStable(affected, func(i, j int) bool {
+		if affected[i].Depth != affected[j].Depth {
+			return affected[i].Depth < affected[j].Depth
+		}
+		return affected[i].ID < affected[j].ID
+	})
+
+	return affected, nil
+}
+
+// InputChangedRelease is a release whose inputs changed since a git ref
+type InputChangedRelease struct {
+	ID string `json:"id"`
+	// Selector is the selector matching only the release, like `name=web,namespace=default`
+	Selector string `json:"selector"`
+	// Changed are the changed files feeding the release, relative to the root of the git repository
+	Changed []string `json:"changed"`
+	// Helmfile is the state file the release is defined in, relative to the root of the git repository
+	Helmfile string `json:"helmfile"`
+}
+
+// inputChangedReleases returns the releases fed by the changed files, that are their state files, values files, or files in their local charts.
+// The paths are made relative to the root.
+func (in watchedInputs) inputChangedReleases(changed []string, root string) []InputChangedRelease {
+	rel := func(p string) string {
+		if r, err := filepath.Rel(root, p); err == nil {
+			return r
+		}
+		return p
+	}
+
+	releases := []InputChangedRelease{}
+	for _, r := range in.releases {
+		var files []string
+		for _, p := range changed {
+			if p == r.stateFile || r.feeds(p) {
+				files = append(files, rel(p))
+			}
+		}
+		if len(files) == 0 {
+			continue
+		}
+
+		releases = append(releases, InputChangedRelease{
+			ID:       r.id,
+			Selector: r.selector,
+			Changed:  files,
+			Helmfile: rel(r.stateFile),
+		})
+	}
+
+	return releases
+}
+
+// gitChangedFiles returns the absolute paths to the files changed since the git ref, including the uncommitted and untracked ones,
+// along with the root of the git repository
+func gitChangedFiles(runner helmexec.Runner, ref string) ([]string, string, error) {
+	out, err := runner.Execute("git", []string{"rev-parse", "--show-toplevel"}, nil, false)
+	if err != nil {
+		return nil, "", fmt.Errorf("finding the root of the git repository: %w", err)
+	}
+	root := strings.TrimSpace(string(out))
+
+	diff, err := runner.Execute("git", []string{"diff", "--name-only", ref, "--"}, nil, false)
+	if err != nil {
+		return nil, "", fmt.Errorf("finding the files changed since %q: %w", ref, err)
+	}
+
+	untracked, err := runner.Execute("git", []string{"ls-files", "--others", "--exclude-standard", "--full-name"}, nil, false)
+	if err != nil {
+		return nil, "", fmt.Errorf("finding the untracked files: %w", err)
+	}
+
+	var changed []string
+	for _, l := range strings.Split(string(diff)+"\n"+string(untracked), "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			changed = append(changed, filepath.Join(root, l))
+		}
+	}
+
+	return changed, root, nil
+}
+
+// Affected prints the releases that transitively depend on the release via needs,
+// across all the helmfiles regardless of the selectors, along with the helmfiles they are defined in.
+// With the git ref to compare with, it prints the selected releases whose inputs changed since the ref instead.
+func (a *App) Affected(c AffectedConfigProvider) error {
+	if c.Release() == "" && c.Since() == "" {
+		return fmt.Errorf("either the release or the git ref to list the affected releases since is required")
+	}
+
+	if c.Since() != "" {
+		return a.affectedSince(c)
+	}
+
+	var nodes []affectedNode
+
+	err := a.ForEachState(func(run *Run) (bool, []error) {
+		for _, r := range run.state.Releases {
+			nodes = append(nodes, affectedNode{release: r, helmfile: run.state.FilePath})
+		}
+		return true, nil
+	}, false)
+	if err != nil {
+		return err
+	}
+
+	affected, err := affectedReleases(nodes, c.Release())
+	if err != nil {
+		return err
+	}
+
+	switch c.Output() {
+	case "json":
+		return FormatAsJson(a.Stdout(), affected)
+	case "selectors":
+		var selectors []string
+		for _, r := range affected {
+			selectors = append(selectors, r.Selector)
+		}
+		return FormatSelectors(a.Stdout(), selectors)
+	}
+
+	return FormatAffectedAsTable(a.Stdout(), affected)
+}
+
+func (a *App) affectedSince(c AffectedConfigProvider) error {
+	changed, root, err := gitChangedFiles(helmexec.ShellRunner{Logger: a.Logger}, c.Since())
+	if err != nil {
+		return err
+	}
+
+	inputs, err := a.collectWatchedInputs()
+	if err != nil {
+		return err
+	}
+
+	releases := inputs.inputChangedReleases(changed, root)
+
+	switch c.Output() {
+	case "json":
+		return FormatAsJson(a.Stdout(), releases)
+	case "selectors":
+		var selectors []string
+		for _, r := range releases {
+			selectors = append(selectors, r.Selector)
+		}
+		return FormatSelectors(a.Stdout(), selectors)
+	}
+
+	return FormatInputChangedAsTable(a.Stdout(), releases)
+}
@@ -0,0 +1,85 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/exectest"
+	"github.com/helmfile/helmfile/pkg/state"
+)
+
+func TestAffectedReleases(t *testing.T) {
+	nodes := []affectedNode{
+		{release: state.ReleaseSpec{Name: "cert-manager", Namespace: "infra"}, helmfile: "helmfile.yaml"},
+		{release: state.ReleaseSpec{Name: "ingress", Namespace: "infra", Needs: []string{"infra/cert-manager"}}, helmfile: "helmfile.yaml"},
+		{release: state.ReleaseSpec{Name: "web", Namespace: "apps", Needs: []string{"infra/ingress"}}, helmfile: "apps/helmfile.yaml"},
+		{release: state.ReleaseSpec{Name: "api", Namespace: "apps", Needs: []string{"infra/ingress", "infra/cert-manager"}}, helmfile: "apps/helmfile.yaml"},
+		{release: state.ReleaseSpec{Name: "db", Namespace: "apps"}, helmfile: "apps/helmfile.yaml"},
+	}
+
+	affected, err := affectedReleases(nodes, "cert-manager")
+	require.NoError(t, err)
+	require.Equal(t, []AffectedRelease{
+		{ID: "apps/api", Selector: "name=api,namespace=apps", Name: "api", Namespace: "apps", Depth: 1, Via: "infra/cert-manager", Helmfile: "apps/helmfile.yaml"},
+		{ID: "infra/ingress", Selector: "name=ingress,namespace=infra", Name: "ingress", Namespace: "infra", Depth: 1, Via: "infra/cert-manager", Helmfile: "helmfile.yaml"},
+		{ID: "apps/web", Selector: "name=web,namespace=apps", Name: "web", Namespace: "apps", Depth: 2, Via: "infra/ingress", Helmfile: "apps/helmfile.yaml"},
+	}, affected)
+
+	affected, err = affectedReleases(nodes, "apps/db")
+	require.NoError(t, err)
+	require.Empty(t, affected)
+
+	_, err = affectedReleases(nodes, "apps/ingress")
+	require.EqualError(t, err, `no release found for "apps/ingress"`)
+}
+
+type affectedConfig struct {
+	release, since, output string
+}
+
+func (c affectedConfig) Release() string { return c.release }
+func (c affectedConfig) Since() string   { return c.since }
+func (c affectedConfig) Output() string  { return c.output }
+
+func TestAffected_NeitherReleaseNorSince(t *testing.T) {
+	err := (&App{}).Affected(affectedConfig{output: "json"})
+	require.EqualError(t, err, "either the release or the git ref to list the affected releases since is required")
+}
+
+func TestGitChangedFiles(t *testing.T) {
+	runner := &exectest.Runner{
+		Outputs: map[string]string{
+			"git rev-parse --show-toplevel":                        "/work\n",
+			"git diff --name-only origin/main --":                  "helmfile.yaml\nvalues/web.yaml\n",
+			"git ls-files --others --exclude-standard --full-name": "charts/web/templates/new.yaml\n",
+		},
+	}
+
+	changed, root, err := gitChangedFiles(runner, "origin/main")
+	require.NoError(t, err)
+	require.Equal(t, "/work", root)
+	require.Equal(t, []string{"/work/helmfile.yaml", "/work/values/web.yaml", "/work/charts/web/templates/new.yaml"}, changed)
+}
+
+func TestWatchedInputs_inputChangedReleases(t *testing.T) {
+	inputs := watchedInputs{
+		stateFiles: []string{"/work/helmfile.yaml", "/work/apps/helmfile.yaml"},
+		releases: []watchedRelease{
+			{id: "default/web", selector: "name=web,namespace=default", stateFile: "/work/apps/helmfile.yaml", paths: []string{"/work/values/web.yaml", "/work/charts/web"}},
+			{id: "db", selector: "name=db", stateFile: "/work/helmfile.yaml", paths: []string{"/work/values/db.yaml"}},
+			{id: "cache", selector: "name=cache", stateFile: "/work/helmfile.yaml"},
+		},
+	}
+
+	require.Equal(t, []InputChangedRelease{
+		{ID: "default/web", Selector: "name=web,namespace=default", Changed: []string{"charts/web/templates/deployment.yaml"}, Helmfile: "apps/helmfile.yaml"},
+	}, inputs.inputChangedReleases([]string{"/work/charts/web/templates/deployment.yaml", "/work/charts/webhook/Chart.yaml"}, "/work"))
+
+	require.Equal(t, []InputChangedRelease{
+		{ID: "db", Selector: "name=db", Changed: []string{"helmfile.yaml", "values/db.yaml"}, Helmfile: "helmfile.yaml"},
+		{ID: "cache", Selector: "name=cache", Changed: []string{"helmfile.yaml"}, Helmfile: "helmfile.yaml"},
+	}, inputs.inputChangedReleases([]string{"/work/helmfile.yaml", "/work/values/db.yaml"}, "/work"))
+
+	require.Empty(t, inputs.inputChangedReleases([]string{"/work/README.md"}, "/work"))
+}
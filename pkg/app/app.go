@@ -2,29 +2,37 @@ package app
 
 import (
 	"bytes"
+	gocontext "context"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
 	"text/tabwriter"
+	"time"
 
 	"github.com/helmfile/vals"
 	"go.uber.org/zap"
 
 	"github.com/helmfile/helmfile/pkg/argparser"
+	"github.com/helmfile/helmfile/pkg/errors"
 	"github.com/helmfile/helmfile/pkg/filesystem"
 	"github.com/helmfile/helmfile/pkg/helmexec"
 	"github.com/helmfile/helmfile/pkg/plugins"
 	"github.com/helmfile/helmfile/pkg/remote"
 	"github.com/helmfile/helmfile/pkg/runtime"
 	"github.com/helmfile/helmfile/pkg/state"
+	"github.com/helmfile/helmfile/pkg/tmpl"
 )
 
 var CleanWaitGroup sync.WaitGroup
 
 // App is the main application object.
+//
+// Construct an App with New or NewWithOptions rather than populating its fields directly,
+// so that the defaults are set and the vals runtime is initialized.
 type App struct {
 	OverrideKubeContext string
 	OverrideHelmBinary  string
@@ -41,6 +49,17 @@ type App struct {
 
 	FileOrDir string
 
+	// opts is the Options the App is created with, which holds the settings not exposed as the fields above
+	opts Options
+
+	stdout io.Writer
+
+	callbacks Callbacks
+
+	// ctx is the context of the operations not taking contexts, which is done on SIGINT, SIGTERM, or --run-timeout for the CLI.
+	// The operations never stop when it is nil.
+	ctx gocontext.Context
+
 	fs *filesystem.FileSystem
 
 	remote *remote.Remote
@@ -49,34 +68,27 @@ type App struct {
 
 	helms      map[helmKey]helmexec.Interface
 	helmsMutex sync.Mutex
+
+	// onDiffed is called with the releases with changes after each diff, which `helmfile watch` sets to detect the drifts
+	onDiffed func([]ChangedRelease)
+
+	// stdin is the values read from stdin for `--values -` and `--state-values-file -`
+	stdin *stdinValues
 }
 
 type HelmRelease struct {
-	Name      string `json:"name"`
-	Namespace string `json:"namespace"`
-	Enabled   bool   `json:"enabled"`
-	Installed bool   `json:"installed"`
-	Labels    string `json:"labels"`
-	Chart     string `json:"chart"`
-	Version   string `json:"version"`
+	Name      string `json:"name" yaml:"name"`
+	Namespace string `json:"namespace" yaml:"namespace"`
+	Enabled   bool   `json:"enabled" yaml:"enabled"`
+	Installed bool   `json:"installed" yaml:"installed"`
+	Labels    string `json:"labels" yaml:"labels"`
+	Chart     string `json:"chart" yaml:"chart"`
+	Version   string `json:"version" yaml:"version"`
 }
 
+// New creates a new App from the given ConfigProvider.
 func New(conf ConfigProvider) *App {
-	return Init(&App{
-		OverrideKubeContext: conf.KubeContext(),
-		OverrideHelmBinary:  conf.HelmBinary(),
-		EnableLiveOutput:    conf.EnableLiveOutput(),
-		Logger:              conf.Logger(),
-		Env:                 conf.Env(),
-		Namespace:           conf.Namespace(),
-		Chart:               conf.Chart(),
-		Selectors:           conf.Selectors(),
-		Args:                conf.Args(),
-		FileOrDir:           conf.FileOrDir(),
-		ValuesFiles:         conf.StateValuesFiles(),
-		Set:                 conf.StateValuesSet(),
-		fs:                  filesystem.DefaultFileSystem(),
-	})
+	return NewWithOptions(OptionsFromConfig(conf))
 }
 
 func Init(app *App) *App {
@@ -98,10 +110,15 @@ func (a *App) Init(c InitConfigProvider) error {
 		Logger: a.Logger,
 	}
 	helmfileInit := NewHelmfileInit(a.OverrideHelmBinary, c, a.Logger, runner)
+	helmfileInit.stdout = a.Stdout()
 	return helmfileInit.Initialize()
 }
 
 func (a *App) Deps(c DepsConfigProvider) error {
+	if a.opts.Offline {
+		return errors.WithCode(errors.CodeRepoFetch, errors.PhaseRepos, fmt.Errorf("offline: helmfile deps can't run with --offline, as it downloads the dependencies of the charts"))
+	}
+
 	return a.ForEachState(func(run *Run) (_ bool, errs []error) {
 		prepErr := run.withPreparedCharts("deps", state.ChartPrepareOptions{
 			SkipRepos:   c.SkipRepos(),
@@ -121,6 +138,10 @@ func (a *App) Deps(c DepsConfigProvider) error {
 }
 
 func (a *App) Repos(c ReposConfigProvider) error {
+	if a.opts.Offline {
+		return errors.WithCode(errors.CodeRepoFetch, errors.PhaseRepos, fmt.Errorf("offline: helmfile repos can't run with --offline, as it updates the repositories"))
+	}
+
 	return a.ForEachState(func(run *Run) (_ bool, errs []error) {
 		reposErr := run.Repos(c)
 
@@ -152,11 +173,22 @@ func (a *App) DeprecatedSyncCharts(c DeprecatedChartsConfigProvider) error {
 }
 
 func (a *App) Diff(c DiffConfigProvider) error {
+	return a.diffContext(a.runContext(), c)
+}
+
+func (a *App) diffContext(ctx gocontext.Context, c DiffConfigProvider) error {
+	op := &operation{ctx: ctx}
+	if c.PlanHooks() {
+		op.hookPlan = &state.HookPlan{}
+	}
+
 	var allDiffDetectedErrs []error
 
 	var affectedAny bool
 
-	err := a.ForEachState(func(run *Run) (bool, []error) {
+	changed := &changedReleases{}
+
+	err := a.forEachState(op, func(run *Run) (bool, []error) {
 		var criticalErrs []error
 
 		var msg *string
@@ -175,7 +207,7 @@ func (a *App) Diff(c DiffConfigProvider) error {
 			Concurrency:            c.Concurrency(),
 			IncludeTransitiveNeeds: c.IncludeNeeds(),
 		}, func() {
-			msg, matched, affected, errs = a.diff(run, c)
+			msg, matched, affected, errs = a.diff(run, c, changed)
 		})
 
 		if msg != nil {
@@ -204,12 +236,32 @@ func (a *App) Diff(c DiffConfigProvider) error {
 		}
 
 		return matched, criticalErrs
-	}, c.IncludeTransitiveNeeds())
+	}, c.IncludeTransitiveNeeds(), SetCommand("diff"))
 
 	if err != nil {
 		return err
 	}
 
+	if f := c.ChangedReleasesFile(); f != "" {
+		if err := changed.writeFile(f); err != nil {
+			return appError("", err)
+		}
+	}
+
+	if op.hookPlan != nil {
+		if err := op.hookPlan.Write(a.Stdout()); err != nil {
+			return appError("", err)
+		}
+	}
+
+	if c.DetailedExitcode() {
+		changed.display(a.Logger)
+	}
+
+	if a.onDiffed != nil {
+		a.onDiffed(changed.sorted())
+	}
+
 	if c.DetailedExitcode() && (len(allDiffDetectedErrs) > 0 || affectedAny) {
 		// We take the first release error w/ exit status 2 (although all the defered errs should have exit status 2)
 		// to just let helmfile itself to exit with 2
@@ -226,7 +278,11 @@ func (a *App) Diff(c DiffConfigProvider) error {
 }
 
 func (a *App) Template(c TemplateConfigProvider) error {
-	return a.ForEachState(func(run *Run) (ok bool, errs []error) {
+	return a.templateContext(a.runContext(), c)
+}
+
+func (a *App) templateContext(ctx gocontext.Context, c TemplateConfigProvider) error {
+	return a.forEachState(&operation{ctx: ctx}, func(run *Run) (ok bool, errs []error) {
 		includeCRDs := c.IncludeCRDs()
 
 		// Live output should never be enabled for the "template" subcommand to avoid breaking `helmfile template | kubectl apply -f -`
@@ -275,6 +331,10 @@ type MultiError struct {
 	Errors []error
 }
 
+func (e *MultiError) Unwrap() []error {
+	return e.Errors
+}
+
 func (e *MultiError) Error() string {
 	indent := func(text string, indent string) string {
 		lines := strings.Split(text, "\n")
@@ -346,7 +406,13 @@ func (a *App) Fetch(c FetchConfigProvider) error {
 			OutputDir:         c.OutputDir(),
 			OutputDirTemplate: c.OutputDirTemplate(),
 			Concurrency:       c.Concurrency(),
+			SkipVendor:        c.Vendor(),
 		}, func() {
+			if c.Vendor() {
+				if err := run.state.VendorCharts(run.ReleaseToChart); err != nil {
+					errs = append(errs, err)
+				}
+			}
 		})
 
 		if prepErr != nil {
@@ -358,14 +424,16 @@ func (a *App) Fetch(c FetchConfigProvider) error {
 }
 
 func (a *App) Sync(c SyncConfigProvider) error {
-	return a.ForEachState(func(run *Run) (ok bool, errs []error) {
+	return a.syncContext(a.runContext(), c)
+}
+
+func (a *App) syncContext(ctx gocontext.Context, c SyncConfigProvider) error {
+	return a.forEachState(&operation{ctx: ctx}, func(run *Run) (ok bool, errs []error) {
 		includeCRDs := !c.SkipCRDs()
 
 		prepErr := run.withPreparedCharts("sync", state.ChartPrepareOptions{
 			SkipRepos:              c.SkipDeps(),
 			SkipDeps:               c.SkipDeps(),
-			Wait:                   c.Wait(),
-			WaitForJobs:            c.WaitForJobs(),
 			IncludeCRDs:            &includeCRDs,
 			IncludeTransitiveNeeds: c.IncludeNeeds(),
 			Validate:               c.Validate(),
@@ -379,33 +447,58 @@ func (a *App) Sync(c SyncConfigProvider) error {
 		}
 
 		return
-	}, c.IncludeTransitiveNeeds())
+	}, c.IncludeTransitiveNeeds(), SetCommand("sync"), SetStateConcurrency(stateConcurrency(c)))
+}
+
+// stateConcurrency returns the state concurrency of the command, which is 1 on asking for the confirmations so that the prompts of the states don't interleave
+func stateConcurrency(c interface {
+	stateConcurrencyConfig
+	interactive
+}) int {
+	if c.Interactive() {
+		return 1
+	}
+	return c.StateConcurrency()
 }
 
 func (a *App) Apply(c ApplyConfigProvider) error {
+	return a.applyContext(a.runContext(), c)
+}
+
+func (a *App) applyContext(ctx gocontext.Context, c ApplyConfigProvider) error {
+	op := &operation{ctx: ctx}
+	if c.DryRun() {
+		op.hookPlan = &state.HookPlan{}
+	}
+
 	var any bool
 
 	mut := &sync.Mutex{}
 
+	changed := &changedReleases{}
+
 	var opts []LoadOption
 
-	opts = append(opts, SetRetainValuesFiles(c.RetainValuesFiles() || c.SkipCleanup()))
+	concurrency := stateConcurrency(c)
+	if c.InteractivePlan() {
+		concurrency = 1
+	}
 
-	err := a.ForEachState(func(run *Run) (ok bool, errs []error) {
+	opts = append(opts, SetRetainValuesFiles(c.RetainValuesFiles() || c.SkipCleanup()), SetCommand("apply"), SetStateConcurrency(concurrency))
+
+	err := a.forEachState(op, func(run *Run) (ok bool, errs []error) {
 		includeCRDs := !c.SkipCRDs()
 
 		prepErr := run.withPreparedCharts("apply", state.ChartPrepareOptions{
 			SkipRepos:              c.SkipDeps(),
 			SkipDeps:               c.SkipDeps(),
-			Wait:                   c.Wait(),
-			WaitForJobs:            c.WaitForJobs(),
 			IncludeCRDs:            &includeCRDs,
 			SkipCleanup:            c.RetainValuesFiles() || c.SkipCleanup(),
 			Validate:               c.Validate(),
 			Concurrency:            c.Concurrency(),
 			IncludeTransitiveNeeds: c.IncludeNeeds(),
 		}, func() {
-			matched, updated, es := a.apply(run, c)
+			matched, updated, es := a.apply(run, c, changed)
 
 			mut.Lock()
 			any = any || updated
@@ -425,6 +518,18 @@ func (a *App) Apply(c ApplyConfigProvider) error {
 		return err
 	}
 
+	if f := c.ChangedReleasesFile(); f != "" {
+		if err := changed.writeFile(f); err != nil {
+			return appError("", err)
+		}
+	}
+
+	if op.hookPlan != nil {
+		if err := op.hookPlan.Write(a.Stdout()); err != nil {
+			return appError("", err)
+		}
+	}
+
 	if c.DetailedExitcode() && any {
 		code := 2
 
@@ -435,13 +540,15 @@ func (a *App) Apply(c ApplyConfigProvider) error {
 }
 
 func (a *App) Status(c StatusesConfigProvider) error {
-	return a.ForEachState(func(run *Run) (ok bool, errs []error) {
+	var summaries []state.ReleaseStatusSummary
+
+	err := a.ForEachState(func(run *Run) (ok bool, errs []error) {
 		err := run.withPreparedCharts("status", state.ChartPrepareOptions{
 			SkipRepos:   true,
 			SkipDeps:    true,
 			Concurrency: c.Concurrency(),
 		}, func() {
-			ok, errs = a.status(run, c)
+			ok, errs = a.status(run, c, &summaries)
 		})
 
 		if err != nil {
@@ -450,6 +557,29 @@ func (a *App) Status(c StatusesConfigProvider) error {
 
 		return
 	}, false, SetFilter(true))
+
+	if err != nil {
+		return err
+	}
+
+	sort.SliceStable(summaries, func(i, j int) bool {
+		return summaries[i].ID < summaries[j].ID
+	})
+
+	switch c.Output() {
+	case "json":
+		return FormatAsJson(a.Stdout(), summaries)
+	case "table":
+		return FormatStatusesAsTable(a.Stdout(), summaries, time.Now())
+	}
+
+	return nil
+}
+
+func (a *App) Exec(c ExecConfigProvider) error {
+	return a.ForEachState(func(run *Run) (ok bool, errs []error) {
+		return a.exec(run, c)
+	}, false, SetFilter(true))
 }
 
 // TODO: Remove this function once Helmfile v0.x
@@ -471,7 +601,7 @@ func (a *App) Delete(c DeleteConfigProvider) error {
 			ok, errs = a.delete(run, c.Purge(), c)
 		}
 		return
-	}, false, SetReverse(true))
+	}, false, SetReverse(true), SetCommand("delete"))
 }
 
 func (a *App) Destroy(c DestroyConfigProvider) error {
@@ -491,11 +621,13 @@ func (a *App) Destroy(c DestroyConfigProvider) error {
 			ok, errs = a.delete(run, true, c)
 		}
 		return
-	}, false, SetReverse(true))
+	}, false, SetReverse(true), SetCommand("destroy"))
 }
 
 func (a *App) Test(c TestConfigProvider) error {
-	return a.ForEachState(func(run *Run) (_ bool, errs []error) {
+	results := &state.TestResults{}
+
+	err := a.ForEachState(func(run *Run) (_ bool, errs []error) {
 		if c.Cleanup() {
 			a.Logger.Warnf("warn: requested cleanup will not be applied. " +
 				"To clean up test resources with Helm 3, you have to remove them manually " +
@@ -507,7 +639,7 @@ func (a *App) Test(c TestConfigProvider) error {
 			SkipDeps:    c.SkipDeps(),
 			Concurrency: c.Concurrency(),
 		}, func() {
-			errs = a.test(run, c)
+			errs = a.test(run, c, results)
 		})
 
 		if err != nil {
@@ -516,6 +648,42 @@ func (a *App) Test(c TestConfigProvider) error {
 
 		return
 	}, false, SetFilter(true))
+
+	if c.Output() == "json" {
+		if jsonErr := FormatTestResultsAsJson(a.Stdout(), results.Results()); jsonErr != nil && err == nil {
+			err = jsonErr
+		}
+	} else {
+		displayTestResults(results, a.Logger)
+	}
+
+	return err
+}
+
+// displayTestResults prints the aggregate report of the release tests
+func displayTestResults(results *state.TestResults, logger *zap.SugaredLogger) {
+	rs := results.Results()
+	if len(rs) == 0 {
+		return
+	}
+
+	buf := &bytes.Buffer{}
+
+	w := new(tabwriter.Writer)
+
+	w.Init(buf, 0, 1, 3, ' ', 0)
+
+	fmt.Fprintln(w, "RELEASE\tRESULT\tDURATION\tLOG")
+
+	for _, r := range rs {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.ID, r.Result, formatTestDuration(r), r.LogFile)
+	}
+
+	_ = w.Flush()
+
+	logger.Info("\nTEST RESULTS:")
+	logger.Info(buf.String())
+	logger.Infof("%d of %d release test(s) failed", results.Failed(), len(rs))
 }
 
 func (a *App) PrintState(c StateConfigProvider) error {
@@ -558,7 +726,7 @@ func (a *App) PrintState(c StateConfigProvider) error {
 				errs = []error{err}
 				return
 			}
-			fmt.Printf("---\n#  Source: %s\n\n%+v", sourceFile, stateYaml)
+			fmt.Fprintf(a.Stdout(), "---\n#  Source: %s\n\n%+v", sourceFile, stateYaml)
 
 			errs = []error{}
 		})
@@ -607,15 +775,119 @@ func (a *App) ListReleases(c ListConfigProvider) error {
 		return err
 	}
 
-	if c.Output() == "json" {
-		err = FormatAsJson(releases)
-	} else {
-		err = FormatAsTable(releases)
+	switch c.Output() {
+	case "json":
+		err = FormatAsJson(a.Stdout(), releases)
+	case "yaml":
+		err = FormatAsYaml(a.Stdout(), releases)
+	default:
+		err = FormatAsTable(a.Stdout(), releases)
 	}
 
 	return err
 }
 
+func (a *App) History(c HistoryConfigProvider) error {
+	var revisions []state.ReleaseRevision
+
+	err := a.ForEachState(func(run *Run) (ok bool, errs []error) {
+		var stateRevisions []state.ReleaseRevision
+
+		ok, errs = a.history(run, c, &stateRevisions)
+
+		revisions = append(revisions, stateRevisions...)
+
+		return
+	}, false, SetFilter(true))
+
+	if err != nil {
+		return err
+	}
+
+	if c.Output() == "json" {
+		return FormatAsJson(a.Stdout(), revisions)
+	}
+
+	return FormatHistoryAsTable(a.Stdout(), revisions, time.Now())
+}
+
+func (a *App) history(r *Run, c HistoryConfigProvider, revisions *[]state.ReleaseRevision) (bool, []error) {
+	st := r.state
+	helm := r.helm
+
+	selectedReleases, _, err := a.getSelectedReleases(r, false)
+	if err != nil {
+		return false, []error{err}
+	}
+	if len(selectedReleases) == 0 {
+		return false, nil
+	}
+
+	st.Releases = selectedReleases
+
+	rs, errs := st.ReleaseHistories(helm, c.Max(), c.Concurrency())
+
+	*revisions = rs
+
+	return true, errs
+}
+
+func (a *App) Bump(c BumpConfigProvider) error {
+	var bumps []state.VersionBump
+
+	err := a.ForEachState(func(run *Run) (ok bool, errs []error) {
+		var stateBumps []state.VersionBump
+
+		ok, errs = a.bump(run, c, &stateBumps)
+
+		bumps = append(bumps, stateBumps...)
+
+		return
+	}, false, SetFilter(true))
+
+	if err != nil {
+		return err
+	}
+
+	if c.Output() == "json" {
+		return FormatAsJson(a.Stdout(), bumps)
+	}
+
+	if len(bumps) == 0 {
+		c.Logger().Info("All the charts are up to date")
+		return nil
+	}
+
+	return FormatBumpsAsTable(a.Stdout(), bumps)
+}
+
+func (a *App) bump(r *Run, c BumpConfigProvider, bumps *[]state.VersionBump) (bool, []error) {
+	st := r.state
+	helm := r.helm
+
+	selectedReleases, _, err := a.getSelectedReleases(r, false)
+	if err != nil {
+		return false, []error{err}
+	}
+	if len(selectedReleases) == 0 {
+		return false, nil
+	}
+
+	st.Releases = selectedReleases
+
+	if !c.SkipRepos() {
+		if err := r.ctx.SyncReposOnce(st, helm); err != nil {
+			return false, []error{err}
+		}
+	}
+
+	bs, errs := st.BumpVersions(helm, c.DryRun())
+
+	*bumps = bs
+
+	return true, errs
+}
+
 func (a *App) list(run *Run) ([]*HelmRelease, error) {
 	var releases []*HelmRelease
 
@@ -640,7 +912,7 @@ func (a *App) list(run *Run) ([]*HelmRelease, error) {
 		}
 		labels = strings.Trim(labels, ",")
 
-		enabled, err := state.ConditionEnabled(r, run.state.Values())
+		enabled, err := state.ReleaseEnabled(r, run.state.Env.Name, run.state.Values())
 		if err != nil {
 			return nil, err
 		}
@@ -746,6 +1018,12 @@ func (a *App) loadDesiredStateFromYaml(file string, opts ...LoadOpts) (*state.He
 		overrideKubeContext: a.OverrideKubeContext,
 		overrideHelmBinary:  a.OverrideHelmBinary,
 		enableLiveOutput:    a.EnableLiveOutput,
+		repositoryMirrors:   a.opts.RepositoryMirrors,
+		hookPlan:            op.getOperation().hookPlan,
+		workDir:             op.getOperation().workDir,
+		ctx:                 op.getOperation().context(),
+		offline:             a.opts.Offline,
+		clusterLookups:      tmpl.ClusterLookups{Allowed: a.opts.AllowClusterLookups, KubeContext: a.OverrideKubeContext},
 		getHelm:             a.getHelm,
 		valsRuntime:         a.valsRuntime,
 	}
@@ -778,23 +1056,43 @@ func (a *App) getHelm(st *state.HelmState) helmexec.Interface {
 		a.helms = map[helmKey]helmexec.Interface{}
 	}
 
-	bin := st.DefaultHelmBinary
-	kubectx := st.HelmDefaults.KubeContext
-
-	key := createHelmKey(bin, kubectx)
+	key := createHelmKey(st.DefaultHelmBinary, st.HelmDefaults.KubeContext)
 
 	if _, ok := a.helms[key]; !ok {
-		a.helms[key] = helmexec.New(bin, a.EnableLiveOutput, a.Logger, kubectx, &helmexec.ShellRunner{
-			Logger: a.Logger,
-		})
+		a.helms[key] = a.newHelm(st)
 	}
 
 	return a.helms[key]
 }
 
+// newHelm returns a new helm exec instance for the specified state, which isn't shared with the other states
+func (a *App) newHelm(st *state.HelmState) helmexec.Interface {
+	bin := st.DefaultHelmBinary
+	kubectx := st.HelmDefaults.KubeContext
+
+	helm := helmexec.New(bin, a.EnableLiveOutput, a.Logger, kubectx, &helmexec.ShellRunner{
+		Logger: a.Logger,
+		Stdout: a.stdout,
+	})
+	if timeouts, err := helmexec.ParseCommandTimeouts(a.opts.CommandTimeouts); err == nil {
+		helm.SetCommandTimeouts(timeouts)
+	} else {
+		a.Logger.Warnf("ignoring the command timeouts: %v", err)
+	}
+	if liveOutputs, err := helmexec.ParseCommandLiveOutputs(a.opts.CommandLiveOutputs); err == nil {
+		helm.SetCommandLiveOutputs(liveOutputs)
+	} else {
+		a.Logger.Warnf("ignoring the command live outputs: %v", err)
+	}
+
+	return helm
+}
+
 func (a *App) visitStates(fileOrDir string, defOpts LoadOpts, converge func(*state.HelmState) (bool, []error)) error {
 	noMatchInHelmfiles := true
 
+	op := defOpts.getOperation()
+
 	err := a.visitStateFiles(fileOrDir, defOpts, func(f, d string) (retErr error) {
 		opts := defOpts.DeepCopy()
 
@@ -814,23 +1112,57 @@ func (a *App) visitStates(fileOrDir string, defOpts LoadOpts, converge func(*sta
 				case *state.UndefinedEnvError:
 					return nil
 				default:
-					return ctx.wrapErrs(err)
+					return ctx.wrapErrs(&errors.CatalogError{Code: errors.CodeStateLoad, Phase: errors.PhaseLoad, StateFile: f, Err: err})
 				}
 			default:
-				return ctx.wrapErrs(err)
+				return ctx.wrapErrs(&errors.CatalogError{Code: errors.CodeStateLoad, Phase: errors.PhaseLoad, StateFile: f, Err: err})
 			}
 		}
 		st.Selectors = opts.Selectors
 
+		op.externalNeeds.start(filepath.Join(d, f), opts.HelmfileName)
+
+		// Only the root helmfiles, which are loaded without callers, have the run-level hooks
+		if op.hooks != nil && defOpts.CalleePath == "" {
+			if err := op.hooks.prerun(st); err != nil {
+				return appError(fmt.Sprintf("failed running prerun hooks in \"%s\"", f), errors.WithCode(errors.CodeHook, errors.PhasePrerun, err))
+			}
+		}
+
 		visitSubHelmfiles := func() error {
 			if len(st.Helmfiles) > 0 {
 				noMatchInSubHelmfiles := true
+
+				// The sub-helmfiles are scheduled before being visited,
+				// so that the ones having the releases needed by the releases of the others are visited earlier
+				type visit struct {
+					ref string
+					do  func() error
+				}
+				var visits []visit
+
 				for i, m := range st.Helmfiles {
+					selected := opts.HelmfileSelected
+					if !selected {
+						matched, err := a.matchHelmfileSelectors(m)
+						if err != nil {
+							return err
+						}
+						if !matched {
+							a.Logger.Debugf("skipping %s %q not matching the helmfile selectors", m.Ref(i), m.Path)
+							continue
+						}
+						selected = len(a.opts.HelmfileSelectors) > 0
+					}
+
 					optsForNestedState := LoadOpts{
 						CalleePath:        filepath.Join(d, f),
 						Environment:       m.Environment,
 						Reverse:           defOpts.Reverse,
 						RetainValuesFiles: defOpts.RetainValuesFiles,
+						HelmfileSelected:  selected,
+						HelmfileName:      m.Name,
+						op:                defOpts.op,
 					}
 					// assign parent selector to sub helm selector in legacy mode or do not inherit in experimental mode
 					if (m.Selectors == nil && !isExplicitSelectorInheritanceEnabled()) || m.SelectorsInherited {
@@ -839,12 +1171,19 @@ func (a *App) visitStates(fileOrDir string, defOpts LoadOpts, converge func(*sta
 						optsForNestedState.Selectors = m.Selectors
 					}
 
-					if err := a.visitStates(m.Path, optsForNestedState, converge); err != nil {
+					m := m
+					visits = append(visits, visit{ref: m.Ref(i), do: a.scheduleSubHelmfile(op, d, m, func() error {
+						return a.visitStates(m.Path, optsForNestedState, converge)
+					})})
+				}
+
+				for _, v := range visits {
+					if err := v.do(); err != nil {
 						switch err.(type) {
 						case *NoMatchingHelmfileError:
 
 						default:
-							return appError(fmt.Sprintf("in .helmfiles[%d]", i), err)
+							return appError(fmt.Sprintf("in %s", v.ref), err)
 						}
 					} else {
 						noMatchInSubHelmfiles = false
@@ -855,6 +1194,9 @@ func (a *App) visitStates(fileOrDir string, defOpts LoadOpts, converge func(*sta
 			return nil
 		}
 
+		// The tasks of the sub-helmfiles are added while visiting them
+		subTasks := op.stateTasks.len()
+
 		if !opts.Reverse {
 			err = visitSubHelmfiles()
 			if err != nil {
@@ -864,7 +1206,30 @@ func (a *App) visitStates(fileOrDir string, defOpts LoadOpts, converge func(*sta
 
 		templated, tmplErr := st.ExecuteTemplates()
 		if tmplErr != nil {
-			return appError(fmt.Sprintf("failed executing release templates in \"%s\"", f), tmplErr)
+			return appError(fmt.Sprintf("failed executing release templates in \"%s\"", f), errors.WithCode(errors.CodeTemplate, errors.PhaseLoad, tmplErr))
+		}
+
+		var needed []string
+		if !opts.Reverse {
+			needed, err = op.externalNeeds.prepare(a.Logger, d, f, templated)
+			if err != nil {
+				return appError(fmt.Sprintf("failed processing the needs on the other helmfiles in \"%s\"", f), err)
+			}
+		}
+
+		if op.stateTasks != nil {
+			// The releases are processed once all the states are loaded, after the ones of the sub-helmfiles and the needed helmfiles,
+			// in the working directory of the run rather than the directory of the state
+			templated.Rebase(d)
+			op.stateTasks.add(filepath.Join(d, f), subTasks, needed, func() (bool, error) {
+				return a.convergeState(templated, defOpts.RetainValuesFiles, converge)
+			})
+
+			op.externalNeeds.finish(filepath.Join(d, f), templated)
+
+			noMatchInHelmfiles = false
+
+			return nil
 		}
 
 		var (
@@ -891,8 +1256,12 @@ func (a *App) visitStates(fileOrDir string, defOpts LoadOpts, converge func(*sta
 			}
 		}()
 
+		a.getCallbacks().OnStateLoaded(templated)
+
 		processed, errs = converge(templated)
 
+		op.externalNeeds.finish(filepath.Join(d, f), templated)
+
 		noMatchInHelmfiles = noMatchInHelmfiles && !processed
 
 		if opts.Reverse {
@@ -916,6 +1285,52 @@ func (a *App) visitStates(fileOrDir string, defOpts LoadOpts, converge func(*sta
 	return nil
 }
 
+// convergeState runs the converge function for the state processed concurrently with the others,
+// and removes the temporary files generated while running it, like visitStates does for the states processed one by one
+func (a *App) convergeState(templated *state.HelmState, retainValues bool, converge func(*state.HelmState) (bool, []error)) (processed bool, retErr error) {
+	var errs []error
+
+	CleanWaitGroup.Add(1)
+	defer func() {
+		defer CleanWaitGroup.Done()
+		retErr = context{app: a, st: templated, retainValues: retainValues}.clean(errs)
+	}()
+
+	a.getCallbacks().OnStateLoaded(templated)
+
+	processed, errs = converge(templated)
+
+	return processed, nil
+}
+
+// matchHelmfileSelectors returns true if the labels of a sub-helmfile, along with its name as the `name` label,
+// match any of the helmfile selectors, or there are no helmfile selectors
+func (a *App) matchHelmfileSelectors(hf state.SubHelmfileSpec) (bool, error) {
+	if len(a.opts.HelmfileSelectors) == 0 {
+		return true, nil
+	}
+
+	labels := map[string]string{}
+	if hf.Name != "" {
+		labels["name"] = hf.Name
+	}
+	for k, v := range hf.Labels {
+		labels[k] = v
+	}
+
+	for _, s := range a.opts.HelmfileSelectors {
+		filter, err := state.ParseLabels(s)
+		if err != nil {
+			return false, fmt.Errorf("invalid helmfile selector: %w", err)
+		}
+		if filter.MatchLabels(labels) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
 type LoadOption func(o *LoadOpts)
 
 var (
@@ -936,19 +1351,109 @@ var (
 			o.Filter = f
 		}
 	}
-)
 
-func (a *App) ForEachState(do func(*Run) (bool, []error), includeTransitiveNeeds bool, o ...LoadOption) error {
+	SetCommand = func(cmd string) func(o *LoadOpts) {
+		return func(o *LoadOpts) {
+			o.Command = cmd
+		}
+	}
+
+	SetStateConcurrency = func(n int) func(o *LoadOpts) {
+		return func(o *LoadOpts) {
+			o.StateConcurrency = n
+		}
+	}
+
+	SetSkipRunHooks = func(s bool) func(o *LoadOpts) {
+		return func(o *LoadOpts) {
+			o.SkipRunHooks = s
+		}
+	}
+)
+
+func (a *App) ForEachState(do func(*Run) (bool, []error), includeTransitiveNeeds bool, o ...LoadOption) error {
+	return a.forEachState(&operation{ctx: a.runContext()}, do, includeTransitiveNeeds, o...)
+}
+
+// forEachState runs do for each of the states loaded as a part of the operation
+func (a *App) forEachState(op *operation, do func(*Run) (bool, []error), includeTransitiveNeeds bool, o ...LoadOption) error {
+	var opts LoadOpts
+	for _, f := range o {
+		f(&opts)
+	}
+
+	hooks := newRunHooks(opts.Command)
+	// The postrun hooks are triggered only for the states whose prerun hooks were triggered
+	if !opts.SkipRunHooks {
+		op.hooks = hooks
+	}
+	op.progress = newRunProgress()
+	op.externalNeeds = newExternalNeeds(opts.Command)
+	op.stateTasks = newStateTasks(opts.Command, opts.StateConcurrency)
+	op.kubeVersionChecker = state.NewKubeVersionChecker()
+
+	workDir, err := a.createWorkDir()
+	if err != nil {
+		return err
+	}
+	op.workDir = workDir
+	// The working directory is removed on all the exit paths, including SIGINT and SIGTERM,
+	// which main.go catches so that the run returns once the helm commands are killed
+	defer a.removeWorkDir(workDir)
+
 	ctx := NewContext()
-	err := a.visitStatesWithSelectorsAndRemoteSupport(a.FileOrDir, func(st *state.HelmState) (bool, []error) {
+	ctx.stdinValues = a.getStdinValues()
+	defer ctx.stdinValues.cleanup()
+
+	err = a.visitStatesWithSelectorsAndRemoteSupport(a.FileOrDir, func(st *state.HelmState) (bool, []error) {
+		// Stop before processing the next state once the context is done
+		if err := op.context().Err(); err != nil {
+			return false, []error{err}
+		}
+
 		helm := a.getHelm(st)
+		if op.stateTasks != nil {
+			// The states processed concurrently have their own helm, as the extra args of helm are set for each state
+			helm = a.newHelm(st)
+		}
 
 		run, err := NewRun(st, helm, ctx)
 		if err != nil {
 			return false, []error{err}
 		}
-		return do(run)
-	}, includeTransitiveNeeds, o...)
+		run.stdout = a.Stdout()
+		run.op = op
+
+		processed, errs := do(run)
+		if processed {
+			hooks.processed(st, includeTransitiveNeeds)
+		}
+
+		return processed, errs
+	}, includeTransitiveNeeds, append(o, withOperation(op))...)
+
+	if err == nil && op.stateTasks != nil {
+		var processed bool
+		processed, err = op.stateTasks.run(a.Logger)
+		if err == nil && !processed {
+			err = &NoMatchingHelmfileError{selectors: a.Selectors, env: a.Env}
+		}
+	}
+
+	if postrunErr := hooks.postrun(err); postrunErr != nil {
+		if err == nil {
+			err = appError("failed running postrun hooks", errors.WithCode(errors.CodeHook, errors.PhasePostrun, postrunErr))
+		} else {
+			a.Logger.Warnf("warn: failed running postrun hooks: %v", postrunErr)
+		}
+	}
+
+	if err != nil {
+		if ctxErr := op.context().Err(); ctxErr != nil {
+			op.progress.report(a.Logger, ctxErr)
+		}
+		a.getCallbacks().OnError(err)
+	}
 
 	return err
 }
@@ -985,6 +1490,31 @@ func withDAG(templated *state.HelmState, helm helmexec.Interface, logger *zap.Su
 	return withBatches(opts.Purpose, templated, batches, helm, logger, converge)
 }
 
+// withSyncDAG is withDAG for installing the releases, which gives all the releases to converge at once when the state schedules the releases by needs,
+// so that SyncReleases starts each release as soon as all the releases it needs are done, instead of waiting for the whole group before it
+func withSyncDAG(templated *state.HelmState, helm helmexec.Interface, logger *zap.SugaredLogger, opts state.PlanOptions, converge func(*state.HelmState, helmexec.Interface) (bool, []error)) (bool, []error) {
+	if !templated.SchedulesReleasesByNeeds() {
+		return withDAG(templated, helm, logger, opts, converge)
+	}
+
+	// The plan validates the needs, and orders the releases so that each release comes after the ones it needs
+	batches, err := templated.PlanReleases(opts)
+	if err != nil {
+		return false, []error{err}
+	}
+
+	var releases []state.Release
+	for _, batch := range batches {
+		releases = append(releases, batch...)
+	}
+
+	if len(releases) == 0 {
+		return false, nil
+	}
+
+	return withBatches(opts.Purpose, templated, [][]state.Release{releases}, helm, logger, converge)
+}
+
 func withBatches(purpose string, templated *state.HelmState, batches [][]state.Release, helm helmexec.Interface, logger *zap.SugaredLogger, converge func(*state.HelmState, helmexec.Interface) (bool, []error)) (bool, []error) {
 	numBatches := len(batches)
 
@@ -1026,6 +1556,50 @@ func withBatches(purpose string, templated *state.HelmState, batches [][]state.R
 	return any, nil
 }
 
+// withCascadingDAG is withDAG for deleting the releases with `destroy --cascade`, which converges the groups of the releases one by one
+// in the reverse order of their needs. It stops at the first group failing to be deleted, leaving the releases the group needs installed,
+// unless continueOnError is set, in which case the remaining groups are deleted anyway and all the errors are returned.
+func withCascadingDAG(templated *state.HelmState, helm helmexec.Interface, logger *zap.SugaredLogger, opts state.PlanOptions, continueOnError bool, converge func(*state.HelmState, helmexec.Interface) (bool, []error)) []error {
+	batches, err := templated.PlanReleases(opts)
+	if err != nil {
+		return []error{err}
+	}
+
+	numBatches := len(batches)
+
+	logger.Debugf("deleting %d groups of releases in this order:\n%s", numBatches, printBatches(batches))
+
+	var errs []error
+
+	for i, batch := range batches {
+		var releaseIds []string
+
+		batchSt := *templated
+		batchSt.Releases = nil
+
+		for _, marked := range batch {
+			release := marked.ReleaseSpec
+			batchSt.Releases = append(batchSt.Releases, release)
+			releaseIds = append(releaseIds, state.ReleaseToID(&release))
+		}
+
+		logger.Infof("deleting releases in group %d/%d: %s", i+1, numBatches, strings.Join(releaseIds, ", "))
+
+		if _, batchErrs := converge(&batchSt, helm); len(batchErrs) > 0 {
+			errs = append(errs, batchErrs...)
+
+			if !continueOnError {
+				if i+1 < numBatches {
+					logger.Warnf("stopped deleting the remaining %d groups of releases, as group %d/%d failed to be deleted", numBatches-i-1, i+1, numBatches)
+				}
+				return errs
+			}
+		}
+	}
+
+	return errs
+}
+
 type Opts struct {
 	DAGEnabled bool
 }
@@ -1042,6 +1616,14 @@ func (a *App) visitStatesWithSelectorsAndRemoteSupport(fileOrDir string, converg
 	envvals := []interface{}{}
 
 	for _, v := range a.ValuesFiles {
+		if v == stdinValuesPath {
+			vals, err := a.getStdinValues().values()
+			if err != nil {
+				return err
+			}
+			envvals = append(envvals, vals)
+			continue
+		}
 		envvals = append(envvals, v)
 	}
 
@@ -1054,6 +1636,7 @@ func (a *App) visitStatesWithSelectorsAndRemoteSupport(fileOrDir string, converg
 	}
 
 	a.remote = remote.NewRemote(a.Logger, "", a.fs)
+	a.remote.Offline = a.opts.Offline
 
 	f := converge
 	if opts.Filter {
@@ -1133,6 +1716,13 @@ func (a *App) Wrap(converge func(*state.HelmState, helmexec.Interface) []error)
 
 func (a *App) WrapWithoutSelector(converge func(*state.HelmState, helmexec.Interface) []error) func(st *state.HelmState, helm helmexec.Interface) (bool, []error) {
 	return func(st *state.HelmState, helm helmexec.Interface) (bool, []error) {
+		// The remaining batches of the releases are skipped once the context of the state is done
+		if st.Context != nil {
+			if err := st.Context.Err(); err != nil {
+				return false, []error{err}
+			}
+		}
+
 		errs := converge(st, helm)
 		processed := len(st.Releases) != 0 && len(errs) == 0
 		return processed, errs
@@ -1299,7 +1889,12 @@ func (a *App) getSelectedReleases(r *Run, includeTransitiveNeeds bool) ([]state.
 	return selected, deduplicated, nil
 }
 
-func (a *App) apply(r *Run, c ApplyConfigProvider) (bool, bool, []error) {
+func (a *App) apply(r *Run, c ApplyConfigProvider, changed *changedReleases) (bool, bool, []error) {
+	valuesFiles, err := r.ctx.ValuesFiles(c.Values())
+	if err != nil {
+		return false, false, []error{err}
+	}
+
 	st := r.state
 	helm := r.helm
 
@@ -1334,19 +1929,34 @@ func (a *App) apply(r *Run, c ApplyConfigProvider) (bool, bool, []error) {
 	// on running various helm commands on unnecessary releases
 	st.Releases = toApplyWithNeeds
 
+	if err := a.checkKubeVersions(r, st, helm, toApplyWithNeeds, c.KubeVersionCheck()); err != nil {
+		return true, false, []error{err}
+	}
+
 	// helm must be 2.11+ and helm-diff should be provided `--detailed-exitcode` in order for `helmfile apply` to work properly
 	detailedExitCode := true
 
 	diffOpts := &state.DiffOpts{
-		Color:             c.Color(),
-		NoColor:           c.NoColor(),
-		Context:           c.Context(),
-		Output:            c.DiffOutput(),
-		Set:               c.Set(),
-		SkipCleanup:       c.RetainValuesFiles() || c.SkipCleanup(),
-		SkipDiffOnInstall: c.SkipDiffOnInstall(),
-		ReuseValues:       c.ReuseValues(),
-		ResetValues:       c.ResetValues(),
+		Color:              c.Color(),
+		NoColor:            c.NoColor(),
+		Context:            c.Context(),
+		Output:             c.DiffOutput(),
+		Set:                c.Set(),
+		SkipCleanup:        c.RetainValuesFiles() || c.SkipCleanup(),
+		SkipDiffOnInstall:  c.SkipDiffOnInstall(),
+		ReuseValues:        c.ReuseValues(),
+		ResetValues:        c.ResetValues(),
+		Stdout:             a.Stdout(),
+		OutputDir:          c.DiffOutputDir(),
+		OutputFileTemplate: c.DiffOutputFileTemplate(),
+		Renderer:           c.DiffRenderer(),
+		HelmfileCommand:    "apply",
+	}
+
+	// The results are used by the interactive plan, and exposed to the preapply hooks as `.Diff`
+	diffResults := map[string]state.DiffResult{}
+	diffOpts.OnDiffResult = func(_ *state.ReleaseSpec, result state.DiffResult) {
+		diffResults[result.Release] = result
 	}
 
 	infoMsg, releasesToBeUpdated, releasesToBeDeleted, errs := r.diff(false, detailedExitCode, c, diffOpts)
@@ -1354,6 +1964,9 @@ func (a *App) apply(r *Run, c ApplyConfigProvider) (bool, bool, []error) {
 		return false, false, errs
 	}
 
+	changed.add(releaseChangeUpdated, releasesToBeUpdated)
+	changed.add(releaseChangeDeleted, releasesToBeDeleted)
+
 	var toDelete []state.ReleaseSpec
 	for _, r := range releasesToBeDeleted {
 		toDelete = append(toDelete, r)
@@ -1398,11 +2011,32 @@ Do you really want to apply?
 	// Traverse DAG of all the releases so that we don't suffer from false-positive missing dependencies
 	st.Releases = selectedAndNeededReleases
 
-	if !interactive || interactive && r.askForConfirmation(confMsg) {
+	if c.DryRun() {
+		st.Releases = toApplyWithNeeds
+		if err := a.planApplyHooks(st, releasesToBeUpdated, releasesToBeDeleted); err != nil {
+			return true, false, []error{err}
+		}
+		st.Releases = selectedAndNeededReleases
+	} else if approved, err := a.approveApply(r, c, confMsg, releasesToBeUpdated, releasesToBeDeleted, diffResults); err != nil {
+		return true, false, []error{err}
+	} else if approved {
+		if err := a.planReleases(r, releasesToBeDeleted, ReleaseActionDelete); err != nil {
+			return true, false, []error{err}
+		}
+		if err := a.planReleases(r, releasesToBeUpdated, ReleaseActionUpgrade); err != nil {
+			return true, false, []error{err}
+		}
+
 		if _, preapplyErrors := withDAG(st, helm, a.Logger, state.PlanOptions{Purpose: "invoking preapply hooks for", Reverse: true, SelectedReleases: toApplyWithNeeds, SkipNeeds: true}, a.WrapWithoutSelector(func(subst *state.HelmState, helm helmexec.Interface) []error {
 			for _, r := range subst.Releases {
 				release := r
-				if _, err := st.TriggerPreapplyEvent(&release, "apply"); err != nil {
+
+				var diff *state.DiffResult
+				if d, ok := diffResults[state.ReleaseToID(&release)]; ok {
+					diff = &d
+				}
+
+				if _, err := st.TriggerPreapplyEvent(&release, diff, "apply"); err != nil {
 					return []error{err}
 				}
 			}
@@ -1429,7 +2063,9 @@ Do you really want to apply?
 
 				subst.Releases = rs
 
-				return subst.DeleteReleasesForSync(&affectedReleases, helm, c.Concurrency())
+				errs := subst.DeleteReleasesForSync(&affectedReleases, helm, c.Concurrency())
+				a.notifyReleasesApplied(r, rs, ReleaseActionDelete, errs)
+				return errs
 			}))
 
 			if len(deletionErrs) > 0 {
@@ -1439,7 +2075,7 @@ Do you really want to apply?
 
 		// We upgrade releases by traversing the DAG
 		if len(releasesToBeUpdated) > 0 {
-			_, updateErrs := withDAG(st, helm, a.Logger, state.PlanOptions{SelectedReleases: toUpdate, Reverse: false, SkipNeeds: true, IncludeTransitiveNeeds: c.IncludeTransitiveNeeds()}, a.WrapWithoutSelector(func(subst *state.HelmState, helm helmexec.Interface) []error {
+			_, updateErrs := withSyncDAG(st, helm, a.Logger, state.PlanOptions{SelectedReleases: toUpdate, Reverse: false, SkipNeeds: true, IncludeTransitiveNeeds: c.IncludeTransitiveNeeds()}, a.WrapWithoutSelector(func(subst *state.HelmState, helm helmexec.Interface) []error {
 				var rs []state.ReleaseSpec
 
 				for _, r := range subst.Releases {
@@ -1455,12 +2091,14 @@ Do you really want to apply?
 					Set:         c.Set(),
 					SkipCleanup: c.RetainValuesFiles() || c.SkipCleanup(),
 					SkipCRDs:    c.SkipCRDs(),
-					Wait:        c.Wait(),
-					WaitForJobs: c.WaitForJobs(),
 					ReuseValues: c.ReuseValues(),
 					ResetValues: c.ResetValues(),
+					LogOutput:   c.LogOutput(),
 				}
-				return subst.SyncReleases(&affectedReleases, helm, c.Values(), c.Concurrency(), syncOpts)
+				overrideReleases(syncOpts, c)
+				errs := subst.SyncReleases(&affectedReleases, helm, valuesFiles, c.Concurrency(), syncOpts)
+				a.notifyReleasesApplied(r, rs, ReleaseActionUpgrade, errs)
+				return errs
 			}))
 
 			if len(updateErrs) > 0 {
@@ -1484,12 +2122,25 @@ Do you really want to apply?
 	return true, true, applyErrs
 }
 
+// approveApply asks for the approval of the changes when interactive, or shows the plan of the changes and asks for its approval with --interactive-plan
+func (a *App) approveApply(r *Run, c ApplyConfigProvider, confMsg string, releasesToBeUpdated, releasesToBeDeleted map[string]state.ReleaseSpec, diffResults map[string]state.DiffResult) (bool, error) {
+	if c.InteractivePlan() {
+		return a.approveApplyPlan(r, c.Interactive(), releasesToBeUpdated, releasesToBeDeleted, diffResults)
+	}
+
+	return !c.Interactive() || r.askForConfirmation(confMsg), nil
+}
+
 func (a *App) delete(r *Run, purge bool, c DestroyConfigProvider) (bool, []error) {
 	st := r.state
 	helm := r.helm
 
 	affectedReleases := state.AffectedReleases{}
 
+	if err := state.ValidateOrphanTypes(c.DeleteOrphans()); err != nil {
+		return false, []error{err}
+	}
+
 	toSync, _, err := a.getSelectedReleases(r, false)
 	if err != nil {
 		return false, []error{err}
@@ -1498,6 +2149,18 @@ func (a *App) delete(r *Run, purge bool, c DestroyConfigProvider) (bool, []error
 		return false, nil
 	}
 
+	if c.IncludeDependents() {
+		dependents := st.ReleasesNeeding(toSync)
+		if len(dependents) > 0 {
+			names := make([]string, len(dependents))
+			for i, r := range dependents {
+				names[i] = r.Name
+			}
+			c.Logger().Infof("Including the releases needing the selected ones: %s", strings.Join(names, ", "))
+		}
+		toSync = append(toSync, dependents...)
+	}
+
 	toDelete, err := st.DetectReleasesToBeDeleted(helm, toSync)
 	if err != nil {
 		return false, []error{err}
@@ -1545,10 +2208,26 @@ Do you really want to delete?
 	if !interactive || interactive && r.askForConfirmation(msg) {
 		r.helm.SetExtraArgs(argparser.GetArgs(c.Args(), r.state)...)
 
+		if err := a.planReleases(r, releasesToDelete, ReleaseActionDelete); err != nil {
+			return true, []error{err}
+		}
+
 		if len(releasesToDelete) > 0 {
-			_, deletionErrs := withDAG(st, helm, a.Logger, state.PlanOptions{SelectedReleases: toDelete, Reverse: true, SkipNeeds: true}, a.WrapWithoutSelector(func(subst *state.HelmState, helm helmexec.Interface) []error {
-				return subst.DeleteReleases(&affectedReleases, helm, c.Concurrency(), purge)
-			}))
+			planOpts := state.PlanOptions{SelectedReleases: toDelete, Reverse: true, SkipNeeds: true}
+			deleteOpts := &state.DeleteOpts{Wait: c.Cascade(), Timeout: c.CascadeTimeout()}
+
+			converge := a.WrapWithoutSelector(func(subst *state.HelmState, helm helmexec.Interface) []error {
+				errs := subst.DeleteReleases(&affectedReleases, helm, c.Concurrency(), purge, deleteOpts)
+				a.notifyReleasesApplied(r, subst.Releases, ReleaseActionDelete, errs)
+				return errs
+			})
+
+			var deletionErrs []error
+			if c.Cascade() {
+				deletionErrs = withCascadingDAG(st, helm, a.Logger, planOpts, c.ContinueOnError(), converge)
+			} else {
+				_, deletionErrs = withDAG(st, helm, a.Logger, planOpts, converge)
+			}
 
 			if len(deletionErrs) > 0 {
 				errs = append(errs, deletionErrs...)
@@ -1556,10 +2235,85 @@ Do you really want to delete?
 		}
 	}
 	affectedReleases.DisplayAffectedReleases(c.Logger())
+
+	deleteTypes := c.DeleteOrphans()
+
+	if (c.ReportOrphans() || len(deleteTypes) > 0) && len(affectedReleases.Deleted) > 0 {
+		errs = append(errs, a.reportOrphanedResources(st, helm, affectedReleases.Deleted, deleteTypes, c.KeepNamespaces(), c.Logger())...)
+	}
+
 	return true, errs
 }
 
-func (a *App) diff(r *Run, c DiffConfigProvider) (*string, bool, bool, []error) {
+// reportOrphanedResources prints the resources left behind by the deleted releases,
+// deleting the ones of the types in deleteTypes.
+// The namespaces matching keepNamespaces and the ones not empty are never deleted.
+// Failing to look them up is only warned, as the releases are already deleted.
+func (a *App) reportOrphanedResources(st *state.HelmState, helm helmexec.Interface, deleted []*state.ReleaseSpec, deleteTypes []string, keepNamespaces []string, logger *zap.SugaredLogger) []error {
+	orphans, err := st.FindOrphanedResources(helm, deleted)
+	if err != nil {
+		logger.Warnf("warn: unable to look up orphaned resources: %v", err)
+		return nil
+	}
+
+	if len(orphans) == 0 {
+		return nil
+	}
+
+	toDelete := map[string]bool{}
+	for _, t := range deleteTypes {
+		toDelete[t] = true
+	}
+
+	var errs []error
+
+	buf := &bytes.Buffer{}
+
+	w := new(tabwriter.Writer)
+
+	w.Init(buf, 0, 1, 3, ' ', 0)
+
+	fmt.Fprintln(w, "RELEASE\tTYPE\tNAMESPACE\tNAME\tACTION")
+
+	for _, o := range orphans {
+		action := "kept"
+		if toDelete[o.Type] && o.Type == state.OrphanTypeNamespace && matchesAny(keepNamespaces, o.Name) {
+			action = "kept: excluded"
+		} else if toDelete[o.Type] {
+			err := st.DeleteOrphanedResource(o)
+			if notEmpty, ok := err.(*state.NamespaceNotEmptyError); ok {
+				logger.Debugf("%v", err)
+				action = fmt.Sprintf("kept: %d resources left", len(notEmpty.Resources))
+			} else if err != nil {
+				errs = append(errs, err)
+				action = "delete failed"
+			} else {
+				action = "deleted"
+			}
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", o.Release, o.Type, o.Namespace, o.Name, action)
+	}
+
+	_ = w.Flush()
+
+	logger.Info("\nORPHANED RESOURCES:")
+	logger.Info(buf.String())
+
+	return errs
+}
+
+// matchesAny returns true if the name matches any of the glob patterns
+func matchesAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := filepath.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *App) diff(r *Run, c DiffConfigProvider, changed *changedReleases) (*string, bool, bool, []error) {
 	var (
 		infoMsg          *string
 		updated, deleted map[string]state.ReleaseSpec
@@ -1571,6 +2325,10 @@ func (a *App) diff(r *Run, c DiffConfigProvider) (*string, bool, bool, []error)
 		helm.SetExtraArgs(argparser.GetArgs(c.Args(), r.state)...)
 		helm.SetPostRenderer(c.PostRenderer())
 
+		if err := a.checkKubeVersions(r, st, helm, st.Releases, c.KubeVersionCheck()); err != nil {
+			return []error{err}
+		}
+
 		var errs []error
 
 		opts := &state.DiffOpts{
@@ -1582,23 +2340,104 @@ func (a *App) diff(r *Run, c DiffConfigProvider) (*string, bool, bool, []error)
 			SkipDiffOnInstall: c.SkipDiffOnInstall(),
 			ReuseValues:       c.ReuseValues(),
 			ResetValues:       c.ResetValues(),
+			Stdout:            a.Stdout(),
+			Renderer:          c.DiffRenderer(),
+		}
+		// The extra args and the post-renderer given on the command line aren't a part of the flags the results are cached with
+		if c.Args() == "" && c.PostRenderer() == "" {
+			opts.CacheDir = cacheDir(c, "diffs")
+			opts.RefreshCache = c.NoCache()
 		}
 
 		filtered := &Run{
-			state: st,
-			helm:  helm,
-			ctx:   r.ctx,
-			Ask:   r.Ask,
+			state:  st,
+			helm:   helm,
+			ctx:    r.ctx,
+			op:     r.op,
+			Ask:    r.Ask,
+			stdout: r.stdout,
 		}
 		infoMsg, updated, deleted, errs = filtered.diff(true, c.DetailedExitcode(), c, opts)
 
+		changed.add(releaseChangeUpdated, updated)
+		changed.add(releaseChangeDeleted, deleted)
+
+		if len(errs) == 0 && r.getOperation().hookPlan != nil {
+			if err := a.planApplyHooks(st, updated, deleted); err != nil {
+				return []error{err}
+			}
+		}
+
 		return errs
 	})
 
 	return infoMsg, ok, len(deleted) > 0 || len(updated) > 0, errs
 }
 
+// planApplyHooks records the hooks apply runs on the releases of the state into the hook plan, without running them
+func (a *App) planApplyHooks(st *state.HelmState, updated, deleted map[string]state.ReleaseSpec) error {
+	var toUpdate, toDelete []state.ReleaseSpec
+
+	for _, r := range st.Releases {
+		release := r
+		if _, err := st.TriggerPreapplyEvent(&release, nil, "apply"); err != nil {
+			return err
+		}
+
+		id := state.ReleaseToID(&release)
+		if u, ok := updated[id]; ok {
+			toUpdate = append(toUpdate, u)
+		}
+		if d, ok := deleted[id]; ok {
+			toDelete = append(toDelete, d)
+		}
+	}
+
+	return st.PlanSyncHooks(toDelete, toUpdate)
+}
+
+// checkKubeVersions warns on, or fails with, the releases whose charts don't support the Kubernetes versions they are deployed to,
+// skipping the releases already checked in the run
+func (a *App) checkKubeVersions(r *Run, st *state.HelmState, helm helmexec.Interface, releases []state.ReleaseSpec, mode string) error {
+	if mode == "" || mode == state.KubeVersionCheckOff {
+		return nil
+	}
+
+	if err := state.ValidateKubeVersionCheck(mode); err != nil {
+		return err
+	}
+
+	checker := r.getOperation().kubeVersionChecker
+	if checker == nil {
+		checker = state.NewKubeVersionChecker()
+	}
+
+	incompatibilities := st.CheckKubeVersions(helm, releases, checker)
+	if len(incompatibilities) == 0 {
+		return nil
+	}
+
+	if mode == state.KubeVersionCheckWarn {
+		for _, i := range incompatibilities {
+			a.Logger.Warnf("%s", i)
+		}
+		return nil
+	}
+
+	var msgs []string
+	for _, i := range incompatibilities {
+		msgs = append(msgs, i.String())
+	}
+
+	return fmt.Errorf("%d release(s) incompatible with the target Kubernetes versions:\n%s", len(incompatibilities), strings.Join(msgs, "\n"))
+}
+
 func (a *App) lint(r *Run, c LintConfigProvider) (bool, []error, []error) {
+	valuesFiles, err := r.ctx.ValuesFiles(c.Values())
+	if err != nil {
+		return false, []error{err}, nil
+	}
+
 	var deferredLintErrs []error
 
 	ok, errs := a.withNeeds(r, c, false, func(st *state.HelmState) []error {
@@ -1617,7 +2456,7 @@ func (a *App) lint(r *Run, c LintConfigProvider) (bool, []error, []error) {
 			Set:         c.Set(),
 			SkipCleanup: c.SkipCleanup(),
 		}
-		lintErrs := st.LintReleases(helm, c.Values(), args, c.Concurrency(), opts)
+		lintErrs := st.LintReleases(helm, valuesFiles, args, c.Concurrency(), opts)
 		if len(lintErrs) == 1 {
 			if err, ok := lintErrs[0].(helmexec.ExitError); ok {
 				if err.Code > 0 {
@@ -1634,7 +2473,8 @@ func (a *App) lint(r *Run, c LintConfigProvider) (bool, []error, []error) {
 	return ok, deferredLintErrs, errs
 }
 
-func (a *App) status(r *Run, c StatusesConfigProvider) (bool, []error) {
+// status prints the statuses of the selected releases with helm, or collects their summaries to print them at once with --output
+func (a *App) status(r *Run, c StatusesConfigProvider, summaries *[]state.ReleaseStatusSummary) (bool, []error) {
 	st := r.state
 	helm := r.helm
 
@@ -1676,7 +2516,13 @@ func (a *App) status(r *Run, c StatusesConfigProvider) (bool, []error) {
 
 	if len(toStatus) > 0 {
 		_, templateErrs := withDAG(st, helm, a.Logger, state.PlanOptions{SelectedReleases: toStatus, Reverse: false, SkipNeeds: true}, a.WrapWithoutSelector(func(subst *state.HelmState, helm helmexec.Interface) []error {
-			return subst.ReleaseStatuses(helm, c.Concurrency())
+			if c.Output() == "" {
+				return subst.ReleaseStatuses(helm, c.Concurrency())
+			}
+
+			rs, errs := subst.ReleaseStatusSummaries(helm, c.Concurrency())
+			*summaries = append(*summaries, rs...)
+			return errs
 		}))
 
 		if len(templateErrs) > 0 {
@@ -1686,7 +2532,60 @@ func (a *App) status(r *Run, c StatusesConfigProvider) (bool, []error) {
 	return true, errs
 }
 
+func (a *App) exec(r *Run, c ExecConfigProvider) (bool, []error) {
+	st := r.state
+	helm := r.helm
+
+	allReleases := st.Releases
+
+	selectedReleases, _, err := a.getSelectedReleases(r, false)
+	if err != nil {
+		return false, []error{err}
+	}
+	if len(selectedReleases) == 0 {
+		return false, nil
+	}
+
+	var toExec []state.ReleaseSpec
+	for _, r := range selectedReleases {
+		if r.Installed != nil && !*r.Installed {
+			continue
+		}
+		toExec = append(toExec, r)
+	}
+
+	if len(toExec) == 0 {
+		return true, nil
+	}
+
+	// Traverse DAG of all the releases so that we don't suffer from false-positive missing dependencies
+	st.Releases = allReleases
+
+	_, errs := withDAG(st, helm, a.Logger, state.PlanOptions{SelectedReleases: toExec, Reverse: false, SkipNeeds: true}, a.WrapWithoutSelector(func(subst *state.HelmState, helm helmexec.Interface) []error {
+		return subst.ExecReleases(helm, c.Command(), c.Concurrency(), a.Stdout())
+	}))
+
+	return true, errs
+}
+
+// overrideReleases sets the settings of the releases overridden by the flags of the command to the sync options,
+// which take precedence over the ones of the releases and helmDefaults
+func overrideReleases(opts *state.SyncOpts, c releaseOverrides) {
+	opts.Atomic = c.Atomic()
+	opts.CleanupOnFail = c.CleanupOnFail()
+	opts.Wait = c.Wait()
+	opts.WaitForJobs = c.WaitForJobs()
+	if timeout := c.ReleaseTimeout(); timeout != state.EmptyTimeout {
+		opts.Timeout = &timeout
+	}
+}
+
 func (a *App) sync(r *Run, c SyncConfigProvider) (bool, []error) {
+	valuesFiles, err := r.ctx.ValuesFiles(c.Values())
+	if err != nil {
+		return false, []error{err}
+	}
+
 	st := r.state
 	helm := r.helm
 
@@ -1721,6 +2620,10 @@ func (a *App) sync(r *Run, c SyncConfigProvider) (bool, []error) {
 	// on running various helm commands on unnecessary releases
 	st.Releases = toSyncWithNeeds
 
+	if err := a.checkKubeVersions(r, st, helm, toSyncWithNeeds, c.KubeVersionCheck()); err != nil {
+		return true, []error{err}
+	}
+
 	toDelete, err := st.DetectReleasesToBeDeletedForSync(helm, toSyncWithNeeds)
 	if err != nil {
 		return false, []error{err}
@@ -1807,6 +2710,13 @@ Do you really want to sync?
 	affectedReleases := state.AffectedReleases{}
 
 	if !interactive || interactive && r.askForConfirmation(confMsg) {
+		if err := a.planReleases(r, releasesToDelete, ReleaseActionDelete); err != nil {
+			return true, []error{err}
+		}
+		if err := a.planReleases(r, releasesToUpdate, ReleaseActionUpgrade); err != nil {
+			return true, []error{err}
+		}
+
 		if len(releasesToDelete) > 0 {
 			_, deletionErrs := withDAG(st, helm, a.Logger, state.PlanOptions{Reverse: true, SelectedReleases: toDelete, SkipNeeds: true}, a.WrapWithoutSelector(func(subst *state.HelmState, helm helmexec.Interface) []error {
 				var rs []state.ReleaseSpec
@@ -1820,7 +2730,9 @@ Do you really want to sync?
 
 				subst.Releases = rs
 
-				return subst.DeleteReleasesForSync(&affectedReleases, helm, c.Concurrency())
+				errs := subst.DeleteReleasesForSync(&affectedReleases, helm, c.Concurrency())
+				a.notifyReleasesApplied(r, rs, ReleaseActionDelete, errs)
+				return errs
 			}))
 
 			if len(deletionErrs) > 0 {
@@ -1829,7 +2741,7 @@ Do you really want to sync?
 		}
 
 		if len(releasesToUpdate) > 0 {
-			_, syncErrs := withDAG(st, helm, a.Logger, state.PlanOptions{SelectedReleases: toUpdate, SkipNeeds: true, IncludeTransitiveNeeds: c.IncludeTransitiveNeeds()}, a.WrapWithoutSelector(func(subst *state.HelmState, helm helmexec.Interface) []error {
+			_, syncErrs := withSyncDAG(st, helm, a.Logger, state.PlanOptions{SelectedReleases: toUpdate, SkipNeeds: true, IncludeTransitiveNeeds: c.IncludeTransitiveNeeds()}, a.WrapWithoutSelector(func(subst *state.HelmState, helm helmexec.Interface) []error {
 				var rs []state.ReleaseSpec
 
 				for _, r := range subst.Releases {
@@ -1844,12 +2756,14 @@ Do you really want to sync?
 				opts := &state.SyncOpts{
 					Set:         c.Set(),
 					SkipCRDs:    c.SkipCRDs(),
-					Wait:        c.Wait(),
-					WaitForJobs: c.WaitForJobs(),
 					ReuseValues: c.ReuseValues(),
 					ResetValues: c.ResetValues(),
+					LogOutput:   c.LogOutput(),
 				}
-				return subst.SyncReleases(&affectedReleases, helm, c.Values(), c.Concurrency(), opts)
+				overrideReleases(opts, c)
+				errs := subst.SyncReleases(&affectedReleases, helm, valuesFiles, c.Concurrency(), opts)
+				a.notifyReleasesApplied(r, rs, ReleaseActionUpgrade, errs)
+				return errs
 			}))
 
 			if len(syncErrs) > 0 {
@@ -1862,6 +2776,11 @@ Do you really want to sync?
 }
 
 func (a *App) template(r *Run, c TemplateConfigProvider) (bool, []error) {
+	valuesFiles, err := r.ctx.ValuesFiles(c.Values())
+	if err != nil {
+		return false, []error{err}
+	}
+
 	return a.withNeeds(r, c, false, func(st *state.HelmState) []error {
 		helm := r.helm
 
@@ -1882,8 +2801,16 @@ func (a *App) template(r *Run, c TemplateConfigProvider) (bool, []error) {
 			OutputDirTemplate: c.OutputDirTemplate(),
 			SkipCleanup:       c.SkipCleanup(),
 			SkipTests:         c.SkipTests(),
+			ShowOnly:          c.ShowOnly(),
+			AnnotateSource:    c.AnnotateSource(),
+			Stdout:            a.Stdout(),
 		}
-		return st.TemplateReleases(helm, c.OutputDir(), c.Values(), args, c.Concurrency(), c.Validate(), opts)
+		// The manifests post-rendered by the post-renderer given on the command line may change while the inputs stay the same
+		if c.PostRenderer() == "" {
+			opts.RenderCacheDir = cacheDir(c, "templates")
+			opts.RefreshRenderCache = c.NoCache()
+		}
+		return st.TemplateReleases(helm, c.OutputDir(), valuesFiles, args, c.Concurrency(), c.Validate(), opts)
 	})
 }
 
@@ -1968,7 +2895,7 @@ func (a *App) withNeeds(r *Run, c DAGConfig, includeDisabled bool, f func(*state
 	return true, errs
 }
 
-func (a *App) test(r *Run, c TestConfigProvider) []error {
+func (a *App) test(r *Run, c TestConfigProvider, results *state.TestResults) []error {
 	cleanup := c.Cleanup()
 	timeout := c.Timeout()
 	concurrency := c.Concurrency()
@@ -1990,10 +2917,33 @@ func (a *App) test(r *Run, c TestConfigProvider) []error {
 
 	r.helm.SetExtraArgs(argparser.GetArgs(c.Args(), r.state)...)
 
-	return st.TestReleases(r.helm, cleanup, timeout, concurrency, state.Logs(c.Logs()))
+	// Releases are tested in the order of the DAG, concurrently within each group.
+	// A failed group doesn't stop the later ones, so that the report covers all the releases.
+	var errs []error
+
+	opts := []state.TestOption{state.Logs(c.Logs()), state.LogsDir(c.LogsDir()), state.CollectTestResults(results)}
+
+	// The outputs of the release tests are printed to stderr so as not to break the JSON report on stdout
+	if c.Output() == "json" {
+		opts = append(opts, state.TestOutput(a.Stderr()))
+	} else {
+		opts = append(opts, state.TestOutput(a.Stdout()))
+	}
+
+	_, dagErrs := withDAG(st, r.helm, a.Logger, state.PlanOptions{Purpose: "testing", SelectedReleases: toTest, SkipNeeds: true}, a.WrapWithoutSelector(func(subst *state.HelmState, helm helmexec.Interface) []error {
+		errs = append(errs, subst.TestReleases(helm, cleanup, timeout, concurrency, opts...)...)
+		return nil
+	}))
+
+	return append(dagErrs, errs...)
 }
 
 func (a *App) writeValues(r *Run, c WriteValuesConfigProvider) (bool, []error) {
+	valuesFiles, err := r.ctx.ValuesFiles(c.Values())
+	if err != nil {
+		return false, []error{err}
+	}
+
 	st := r.state
 	helm := r.helm
 
@@ -2037,8 +2987,9 @@ func (a *App) writeValues(r *Run, c WriteValuesConfigProvider) (bool, []error) {
 			Set:                c.Set(),
 			OutputFileTemplate: c.OutputFileTemplate(),
 			SkipCleanup:        c.SkipCleanup(),
+			IncludeChartValues: c.IncludeChartValues(),
 		}
-		errs = st.WriteReleasesValues(helm, c.Values(), opts)
+		errs = st.WriteReleasesValues(helm, valuesFiles, opts)
 	}
 
 	return true, errs
@@ -2051,6 +3002,9 @@ type Error struct {
 	Errors []error
 
 	code *int
+
+	// stateFile is the state file the errors occurred in, if any
+	stateFile string
 }
 
 func (e *Error) Error() string {
@@ -2082,6 +3036,14 @@ func (e *Error) Error() string {
 	return msg
 }
 
+func (e *Error) Unwrap() []error {
+	return e.Errors
+}
+
+func (e *Error) Describe() errors.Detail {
+	return errors.Detail{StateFile: e.stateFile}
+}
+
 func (e *Error) Code() int {
 	if e.code != nil {
 		return *e.code
@@ -2152,13 +3114,32 @@ func (c context) wrapErrs(errs ...error) error {
 				c.app.Logger.Debugf("err: %v", e)
 			}
 		}
-		return &Error{Errors: errs}
+		var stateFile string
+		if c.st != nil {
+			stateFile = c.st.FilePath
+		}
+		return &Error{Errors: errs, stateFile: stateFile}
 	}
 	return nil
 }
 
+// cacheDir returns the directory the results of the releases whose inputs haven't changed are cached in, under the name,
+// or an empty one when they aren't cached
+func cacheDir(c cacheConfig, name string) string {
+	if !c.RenderCache() {
+		return ""
+	}
+
+	dir := c.CacheDir()
+	if dir == "" {
+		dir = remote.CacheDir()
+	}
+
+	return filepath.Join(dir, name)
+}
+
 func (a *App) ShowCacheDir(c CacheConfigProvider) error {
-	fmt.Printf("Cache directory: %s\n", remote.CacheDir())
+	fmt.Fprintf(a.Stdout(), "Cache directory: %s\n", remote.CacheDir())
 
 	if !a.fs.DirectoryExistsAt(remote.CacheDir()) {
 		return nil
@@ -2168,7 +3149,7 @@ func (a *App) ShowCacheDir(c CacheConfigProvider) error {
 		return err
 	}
 	for _, e := range dirs {
-		fmt.Printf("- %s\n", e.Name())
+		fmt.Fprintf(a.Stdout(), "- %s\n", e.Name())
 	}
 
 	return nil
@@ -2178,13 +3159,13 @@ func (a *App) CleanCacheDir(c CacheConfigProvider) error {
 	if !a.fs.DirectoryExistsAt(remote.CacheDir()) {
 		return nil
 	}
-	fmt.Printf("Cleaning up cache directory: %s\n", remote.CacheDir())
+	fmt.Fprintf(a.Stdout(), "Cleaning up cache directory: %s\n", remote.CacheDir())
 	dirs, err := os.ReadDir(remote.CacheDir())
 	if err != nil {
 		return err
 	}
 	for _, e := range dirs {
-		fmt.Printf("- %s\n", e.Name())
+		fmt.Fprintf(a.Stdout(), "- %s\n", e.Name())
 		err := os.RemoveAll(filepath.Join(remote.CacheDir(), e.Name()))
 		if err != nil {
 			return err
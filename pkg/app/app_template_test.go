@@ -1,6 +1,10 @@
 package app
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -495,3 +499,68 @@ releases:
 		})
 	})
 }
+
+// manifestHelm writes a manifest of the release on `helm template`
+type manifestHelm struct {
+	exectest.Helm
+}
+
+func (helm *manifestHelm) TemplateRelease(context helmexec.HelmContext, name, chart string, flags ...string) error {
+	if err := helm.Helm.TemplateRelease(context, name, chart, flags...); err != nil {
+		return err
+	}
+	if context.Writer == nil {
+		return errors.New("the manifests must be written to the writer of the app")
+	}
+	_, err := fmt.Fprintf(context.Writer, "kind: ConfigMap\nmetadata:\n  name: %s\n", name)
+	return err
+}
+
+func TestTemplate_Stdout(t *testing.T) {
+	for _, annotateSource := range []bool{false, true} {
+		t.Run(fmt.Sprintf("annotate_source_%t", annotateSource), func(t *testing.T) {
+			helm := &manifestHelm{
+				Helm: exectest.Helm{
+					DiffMutex:     &sync.Mutex{},
+					ChartsMutex:   &sync.Mutex{},
+					ReleasesMutex: &sync.Mutex{},
+					Helm3:         true,
+				},
+			}
+
+			valsRuntime, err := vals.New(vals.Options{CacheSize: 32})
+			require.NoError(t, err)
+
+			files := map[string]string{
+				"/path/to/helmfile.yaml": `
+releases:
+- name: web
+  chart: incubator/raw
+  namespace: default
+`,
+			}
+
+			var stdout bytes.Buffer
+
+			app := appWithFs(&App{
+				OverrideHelmBinary:  DefaultHelmBinary,
+				fs:                  &ffs.FileSystem{Glob: filepath.Glob},
+				OverrideKubeContext: "default",
+				Env:                 "default",
+				Logger:              helmexec.NewLogger(io.Discard, "debug"),
+				helms: map[helmKey]helmexec.Interface{
+					createHelmKey("helm", "default"): helm,
+				},
+				valsRuntime: valsRuntime,
+				stdout:      &stdout,
+			}, files)
+
+			require.NoError(t, app.Template(applyConfig{concurrency: 1, annotateSource: annotateSource}))
+
+			require.Contains(t, stdout.String(), "kind: ConfigMap\nmetadata:\n  name: web\n")
+			if annotateSource {
+				require.Contains(t, stdout.String(), "# Helmfile-Release: default/web")
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package app
 
 import (
 	"bytes"
+	gocontext "context"
 	"errors"
 	"fmt"
 	"io"
@@ -796,6 +797,154 @@ func runFilterSubHelmFilesTests(testcases []struct {
 	}
 }
 
+func TestVisitDesiredStatesWithHelmfileSelectors(t *testing.T) {
+	files := map[string]string{
+		"/path/to/helmfile.yaml": `
+helmfiles:
+- name: platform
+  path: helmfile.d/platform.yaml
+  labels:
+    team: platform
+- path: helmfile.d/apps.yaml
+  labels:
+    team: apps
+- helmfile.d/shared.yaml
+releases:
+- name: root
+  chart: stable/root
+`,
+		"/path/to/helmfile.d/platform.yaml": `
+helmfiles:
+- path: platform/*.yaml
+  labels:
+    tier: backend
+releases:
+- name: ingress
+  chart: stable/ingress
+`,
+		"/path/to/helmfile.d/platform/monitoring.yaml": `
+releases:
+- name: prometheus
+  chart: stable/prometheus
+`,
+		"/path/to/helmfile.d/apps.yaml": `
+releases:
+- name: web
+  chart: stable/web
+`,
+		"/path/to/helmfile.d/shared.yaml": `
+releases:
+- name: redis
+  chart: stable/redis
+`,
+	}
+
+	testcases := []struct {
+		selectors        []string
+		expectedReleases []string
+	}{
+		{selectors: nil, expectedReleases: []string{"prometheus", "ingress", "web", "redis", "root"}},
+		{selectors: []string{"team=platform"}, expectedReleases: []string{"prometheus", "ingress", "root"}},
+		{selectors: []string{"team=platform,tier=backend"}, expectedReleases: []string{"root"}},
+		{selectors: []string{"tier=backend"}, expectedReleases: []string{"root"}},
+		{selectors: []string{"team!=platform"}, expectedReleases: []string{"web", "redis", "root"}},
+		{selectors: []string{"team=apps", "tier=backend"}, expectedReleases: []string{"web", "root"}},
+		{selectors: []string{"name=platform"}, expectedReleases: []string{"prometheus", "ingress", "root"}},
+	}
+
+	for _, tc := range testcases {
+		actual := []string{}
+
+		app := appWithFs(&App{
+			OverrideHelmBinary:  DefaultHelmBinary,
+			OverrideKubeContext: "default",
+			Logger:              newAppTestLogger(),
+			opts:                Options{HelmfileSelectors: tc.selectors},
+			Env:                 "default",
+			FileOrDir:           "helmfile.yaml",
+		}, files)
+
+		expectNoCallsToHelm(app)
+
+		err := app.ForEachState(func(run *Run) (bool, []error) {
+			for _, r := range run.state.Releases {
+				actual = append(actual, r.Name)
+			}
+			return true, nil
+		}, false, SetFilter(true))
+
+		if err != nil {
+			t.Errorf("unexpected error for helmfile selectors %v: %v", tc.selectors, err)
+		}
+		if !reflect.DeepEqual(actual, tc.expectedReleases) {
+			t.Errorf("unexpected releases for helmfile selectors %v: expected=%v, actual=%v", tc.selectors, tc.expectedReleases, actual)
+		}
+	}
+}
+
+func TestVisitDesiredStates_NamedSubHelmfiles(t *testing.T) {
+	testcases := []struct {
+		helmfile string
+		errMsg   string
+	}{
+		{
+			helmfile: `
+helmfiles:
+- name: apps
+  path: helmfile.d/a1.yaml
+`,
+			errMsg: `in ./helmfile.yaml: in helmfile "apps": in /path/to/helmfile.d/a1.yaml: malformed label: name=. Expected label in form k=v or k!=v`,
+		},
+		{
+			helmfile: `
+helmfiles:
+- name: apps
+  path: helmfile.d/a1.yaml
+- name: apps
+  path: helmfile.d/a2.yaml
+`,
+			errMsg: `duplicate name "apps" found in .helmfiles[0] and .helmfiles[1]`,
+		},
+	}
+
+	for _, tc := range testcases {
+		files := map[string]string{
+			"/path/to/helmfile.yaml": tc.helmfile,
+			"/path/to/helmfile.d/a1.yaml": `
+releases:
+- name: zipkin
+  chart: stable/zipkin
+`,
+			"/path/to/helmfile.d/a2.yaml": `
+releases:
+- name: prometheus
+  chart: stable/prometheus
+`,
+		}
+
+		app := appWithFs(&App{
+			OverrideHelmBinary:  DefaultHelmBinary,
+			OverrideKubeContext: "default",
+			Logger:              newAppTestLogger(),
+			Selectors:           []string{"name="},
+			Env:                 "default",
+			FileOrDir:           "helmfile.yaml",
+		}, files)
+
+		expectNoCallsToHelm(app)
+
+		err := app.ForEachState(func(run *Run) (bool, []error) {
+			return true, nil
+		}, false, SetFilter(true))
+
+		if err == nil {
+			t.Errorf("error expected but not happened: expected=%q", tc.errMsg)
+		} else if !strings.Contains(err.Error(), tc.errMsg) {
+			t.Errorf("unexpected error message: expected=%q, actual=%q", tc.errMsg, err.Error())
+		}
+	}
+}
+
 func TestVisitDesiredStatesWithReleasesFiltered_EmbeddedNestedStateAdditionalEnvValues(t *testing.T) {
 	files := map[string]string{
 		"/path/to/helmfile.yaml": `
@@ -2092,6 +2241,7 @@ type configImpl struct {
 	skipCRDs    bool
 	skipDeps    bool
 	skipTests   bool
+	showOnly    []string
 
 	skipNeeds              bool
 	includeNeeds           bool
@@ -2159,6 +2309,27 @@ func (c configImpl) IncludeCRDs() bool {
 	return c.includeCRDs
 }
 
+func (c configImpl) ShowOnly() []string {
+	return c.showOnly
+}
+
+func (c configImpl) AnnotateSource() bool {
+	return false
+}
+
+// RenderCache is false not to reuse the results of the previous test runs
+func (c configImpl) RenderCache() bool {
+	return false
+}
+
+func (c configImpl) NoCache() bool {
+	return false
+}
+
+func (c configImpl) CacheDir() string {
+	return ""
+}
+
 func (c configImpl) Concurrency() int {
 	return 1
 }
@@ -2205,32 +2376,63 @@ type applyConfig struct {
 	context                int
 	diffOutput             string
 	concurrency            int
+	stateConcurrency       int
 	detailedExitcode       bool
+	changedReleasesFile    string
+	diffOutputDir          string
+	diffOutputFileTemplate string
 	interactive            bool
 	skipDiffOnInstall      bool
 	logger                 *zap.SugaredLogger
-	wait                   bool
-	waitForJobs            bool
+	wait                   *bool
+	waitForJobs            *bool
+	atomic                 *bool
+	cleanupOnFail          *bool
+	releaseTimeout         int
 	reuseValues            bool
 	postRenderer           string
+	kubeVersionCheck       string
+	dryRun                 bool
+	interactivePlan        bool
 
 	// template-only options
 	includeCRDs, skipTests       bool
 	outputDir, outputDirTemplate string
+	showOnly                     []string
+	annotateSource               bool
 }
 
 func (a applyConfig) Args() string {
 	return a.args
 }
 
-func (a applyConfig) Wait() bool {
+func (a applyConfig) Wait() *bool {
 	return a.wait
 }
 
-func (a applyConfig) WaitForJobs() bool {
+func (a applyConfig) LogOutput() string {
+	return state.LogOutputInterleaved
+}
+
+func (a applyConfig) WaitForJobs() *bool {
 	return a.waitForJobs
 }
 
+func (a applyConfig) Atomic() *bool {
+	return a.atomic
+}
+
+func (a applyConfig) CleanupOnFail() *bool {
+	return a.cleanupOnFail
+}
+
+func (a applyConfig) ReleaseTimeout() int {
+	if a.releaseTimeout == 0 {
+		return state.EmptyTimeout
+	}
+	return a.releaseTimeout
+}
+
 func (a applyConfig) Values() []string {
 	return a.values
 }
@@ -2311,10 +2513,30 @@ func (a applyConfig) Concurrency() int {
 	return a.concurrency
 }
 
+func (a applyConfig) StateConcurrency() int {
+	return a.stateConcurrency
+}
+
 func (a applyConfig) DetailedExitcode() bool {
 	return a.detailedExitcode
 }
 
+func (a applyConfig) ChangedReleasesFile() string {
+	return a.changedReleasesFile
+}
+
+func (a applyConfig) DiffOutputDir() string {
+	return a.diffOutputDir
+}
+
+func (a applyConfig) DiffOutputFileTemplate() string {
+	return a.diffOutputFileTemplate
+}
+
+func (a applyConfig) InteractivePlan() bool {
+	return a.interactivePlan
+}
+
 func (a applyConfig) Interactive() bool {
 	return a.interactive
 }
@@ -2342,6 +2564,27 @@ func (a applyConfig) SkipTests() bool {
 	return a.skipTests
 }
 
+func (a applyConfig) ShowOnly() []string {
+	return a.showOnly
+}
+
+func (a applyConfig) AnnotateSource() bool {
+	return a.annotateSource
+}
+
+// RenderCache is false not to reuse the results of the previous test runs
+func (a applyConfig) RenderCache() bool {
+	return false
+}
+
+func (a applyConfig) NoCache() bool {
+	return false
+}
+
+func (a applyConfig) CacheDir() string {
+	return ""
+}
+
 func (a applyConfig) OutputDir() string {
 	return a.outputDir
 }
@@ -2361,9 +2604,30 @@ func (a applyConfig) PostRenderer() string {
 	return a.postRenderer
 }
 
+func (a applyConfig) KubeVersionCheck() string {
+	return a.kubeVersionCheck
+}
+
+func (a applyConfig) DiffRenderer() string {
+	return ""
+}
+
+func (a applyConfig) DryRun() bool {
+	return a.dryRun
+}
+
+func (a applyConfig) PlanHooks() bool {
+	return a.dryRun
+}
+
 type depsConfig struct {
 	skipRepos              bool
 	includeTransitiveNeeds bool
+	updateLocks            bool
+}
+
+func (d depsConfig) UpdateLocks() bool {
+	return d.updateLocks
 }
 
 func (d depsConfig) SkipRepos() bool {
@@ -2416,24 +2680,28 @@ type mockRepo struct {
 	Name string
 }
 
-func (helm *mockHelmExec) TemplateRelease(name, chart string, flags ...string) error {
+func (helm *mockHelmExec) TemplateRelease(context helmexec.HelmContext, name, chart string, flags ...string) error {
 	helm.templated = append(helm.templated, mockTemplates{name: name, chart: chart, flags: flags})
 	return nil
 }
 
-func (helm *mockHelmExec) ChartPull(chart string, path string, flags ...string) error {
+func (helm *mockHelmExec) ChartPull(ctx gocontext.Context, chart string, path string, flags ...string) error {
 	return nil
 }
 
-func (helm *mockHelmExec) ChartExport(chart string, path string, flags ...string) error {
+func (helm *mockHelmExec) ChartExport(ctx gocontext.Context, chart string, path string, flags ...string) error {
 	return nil
 }
 
-func (helm *mockHelmExec) UpdateDeps(chart string) error {
+func (helm *mockHelmExec) ChartDigest(ctx gocontext.Context, chart string, flags ...string) (string, error) {
+	return "", nil
+}
+
+func (helm *mockHelmExec) UpdateDeps(ctx gocontext.Context, chart string) error {
 	return nil
 }
 
-func (helm *mockHelmExec) BuildDeps(name, chart string, flags ...string) error {
+func (helm *mockHelmExec) BuildDeps(ctx gocontext.Context, name, chart string, flags ...string) error {
 	return nil
 }
 
@@ -2448,14 +2716,14 @@ func (helm *mockHelmExec) SetPostRenderer(postRenderer string) {
 func (helm *mockHelmExec) GetPostRenderer() string {
 	return ""
 }
-func (helm *mockHelmExec) AddRepo(name, repository, cafile, certfile, keyfile, username, password string, managed string, passCredentials string, skipTLSVerify string) error {
+func (helm *mockHelmExec) AddRepo(ctx gocontext.Context, name, repository, cafile, certfile, keyfile, username, password string, managed string, passCredentials string, skipTLSVerify string) error {
 	helm.repos = append(helm.repos, mockRepo{Name: name})
 	return nil
 }
-func (helm *mockHelmExec) UpdateRepo() error {
+func (helm *mockHelmExec) UpdateRepo(ctx gocontext.Context) error {
 	return nil
 }
-func (helm *mockHelmExec) RegistryLogin(name string, username string, password string) error {
+func (helm *mockHelmExec) RegistryLogin(ctx gocontext.Context, name string, username string, password string, flags ...string) error {
 	return nil
 }
 func (helm *mockHelmExec) SyncRelease(context helmexec.HelmContext, name, chart string, flags ...string) error {
@@ -2467,6 +2735,9 @@ func (helm *mockHelmExec) DiffRelease(context helmexec.HelmContext, name, chart
 func (helm *mockHelmExec) ReleaseStatus(context helmexec.HelmContext, release string, flags ...string) error {
 	return nil
 }
+func (helm *mockHelmExec) Exec(context helmexec.HelmContext, args ...string) error {
+	return nil
+}
 func (helm *mockHelmExec) DeleteRelease(context helmexec.HelmContext, name string, flags ...string) error {
 	return nil
 }
@@ -2481,10 +2752,10 @@ func (helm *mockHelmExec) DecryptSecret(context helmexec.HelmContext, name strin
 func (helm *mockHelmExec) TestRelease(context helmexec.HelmContext, name string, flags ...string) error {
 	return nil
 }
-func (helm *mockHelmExec) Fetch(chart string, flags ...string) error {
+func (helm *mockHelmExec) Fetch(ctx gocontext.Context, chart string, flags ...string) error {
 	return nil
 }
-func (helm *mockHelmExec) Lint(name, chart string, flags ...string) error {
+func (helm *mockHelmExec) Lint(ctx gocontext.Context, name, chart string, flags ...string) error {
 	return nil
 }
 func (helm *mockHelmExec) IsHelm3() bool {
@@ -2499,10 +2770,14 @@ func (helm *mockHelmExec) IsVersionAtLeast(versionStr string) bool {
 	return false
 }
 
-func (helm *mockHelmExec) ShowChart(chartPath string) (chart.Metadata, error) {
+func (helm *mockHelmExec) ShowChart(ctx gocontext.Context, chartPath string, flags ...string) (chart.Metadata, error) {
 	return chart.Metadata{}, errors.New("tests logs rely on this error")
 }
 
+func (helm *mockHelmExec) ShowValues(ctx gocontext.Context, chartPath string, flags ...string) (string, error) {
+	return "", nil
+}
+
 func TestTemplate_SingleStateFile(t *testing.T) {
 	files := map[string]string{
 		"/path/to/helmfile.yaml": `
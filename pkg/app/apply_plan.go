@@ -0,0 +1,153 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/helmfile/helmfile/pkg/state"
+)
+
+// The actions of the releases in the plan of `apply --interactive-plan`
+const (
+	applyPlanActionInstall = "install"
+	applyPlanActionUpgrade = "upgrade"
+	applyPlanActionDelete  = "delete"
+)
+
+// applyPlanEntry is a release to be changed by `apply`, shown in the plan of `apply --interactive-plan`
+type applyPlanEntry struct {
+	ID      string
+	Release state.ReleaseSpec
+	Action  string
+	// Diff is the result of the diff of the release, which is empty for the releases to be deleted
+	Diff state.DiffResult
+}
+
+// newApplyPlan returns the plan of the releases to be updated or deleted, in the order of their IDs.
+// The releases to be updated are installed when they aren't installed yet, or upgraded otherwise.
+func newApplyPlan(r *Run, releasesToBeUpdated, releasesToBeDeleted map[string]state.ReleaseSpec, diffResults map[string]state.DiffResult) ([]applyPlanEntry, error) {
+	var toUpdate []state.ReleaseSpec
+	for _, id := range sortedReleaseIDs(releasesToBeUpdated) {
+		toUpdate = append(toUpdate, releasesToBeUpdated[id])
+	}
+
+	installed, err := r.state.DetectInstalledReleases(r.helm, toUpdate)
+	if err != nil {
+		return nil, err
+	}
+
+	upgraded := map[string]bool{}
+	for _, i := range installed {
+		release := i
+		upgraded[state.ReleaseToID(&release)] = true
+	}
+
+	var plan []applyPlanEntry
+
+	for id, release := range releasesToBeUpdated {
+		action := applyPlanActionInstall
+		if upgraded[id] {
+			action = applyPlanActionUpgrade
+		}
+		plan = append(plan, applyPlanEntry{ID: id, Release: release, Action: action, Diff: diffResults[id]})
+	}
+
+	for id, release := range releasesToBeDeleted {
+		plan = append(plan, applyPlanEntry{ID: id, Release: release, Action: applyPlanActionDelete})
+	}
+
+	sort.Slice(plan, func(i, j int) bool {
+		return plan[i].ID < plan[j].ID
+	})
+
+	return plan, nil
+}
+
+// formatApplyPlan summarizes the changes to the releases with the numbers of the changed resources and lines
+func formatApplyPlan(plan []applyPlanEntry) string {
+	counts := map[string]int{}
+	for _, e := range plan {
+		counts[e.Action]++
+	}
+
+	buf := &bytes.Buffer{}
+
+	fmt.Fprintf(buf, "Plan: %d to install, %d to upgrade, %d to delete\n\n", counts[applyPlanActionInstall], counts[applyPlanActionUpgrade], counts[applyPlanActionDelete])
+
+	w := new(tabwriter.Writer)
+
+	w.Init(buf, 0, 1, 3, ' ', 0)
+
+	fmt.Fprintln(w, "RELEASE\tCHART\tACTION\tRESOURCES\tCHANGES")
+
+	for _, e := range plan {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", e.ID, e.Release.Chart, e.Action, formatApplyPlanResources(e), formatApplyPlanChanges(e))
+	}
+
+	_ = w.Flush()
+
+	return buf.String()
+}
+
+func formatApplyPlanResources(e applyPlanEntry) string {
+	if e.Action == applyPlanActionDelete {
+		return "-"
+	}
+	return fmt.Sprintf("%d", len(e.Diff.Resources))
+}
+
+func formatApplyPlanChanges(e applyPlanEntry) string {
+	if e.Action == applyPlanActionDelete {
+		return "-"
+	}
+	return fmt.Sprintf("+%d/-%d", e.Diff.Added, e.Diff.Removed)
+}
+
+// approveApplyPlan shows the plan of the changes to the releases and asks for the approval of the whole plan at once,
+// or of each release when interactive. The releases denied are removed from releasesToBeUpdated and releasesToBeDeleted.
+// It returns false when nothing is approved.
+func (a *App) approveApplyPlan(r *Run, interactive bool, releasesToBeUpdated, releasesToBeDeleted map[string]state.ReleaseSpec, diffResults map[string]state.DiffResult) (bool, error) {
+	if len(releasesToBeUpdated) == 0 && len(releasesToBeDeleted) == 0 {
+		return true, nil
+	}
+
+	plan, err := newApplyPlan(r, releasesToBeUpdated, releasesToBeDeleted, diffResults)
+	if err != nil {
+		return false, err
+	}
+
+	summary := formatApplyPlan(plan)
+
+	if !interactive {
+		return r.askForConfirmation(fmt.Sprintf(`%s
+Do you really want to apply this plan?
+  Helmfile will apply all the changes in the plan, as shown above.
+
+`, summary)), nil
+	}
+
+	fmt.Fprintln(a.Stdout(), summary)
+
+	var approved int
+
+	for _, e := range plan {
+		msg := fmt.Sprintf("Do you want to %s release %s (%s)?", e.Action, e.ID, e.Release.Chart)
+		if e.Action != applyPlanActionDelete {
+			msg = fmt.Sprintf("Do you want to %s release %s (%s), changing %d resources by %s lines?", e.Action, e.ID, e.Release.Chart, len(e.Diff.Resources), formatApplyPlanChanges(e))
+		}
+
+		if r.askForConfirmation(msg) {
+			approved++
+			continue
+		}
+
+		a.Logger.Infof("Skipping release %s, which is denied", e.ID)
+
+		delete(releasesToBeUpdated, e.ID)
+		delete(releasesToBeDeleted, e.ID)
+	}
+
+	return approved > 0, nil
+}
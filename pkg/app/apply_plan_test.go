@@ -0,0 +1,98 @@
+package app
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/exectest"
+	"github.com/helmfile/helmfile/pkg/helmexec"
+	"github.com/helmfile/helmfile/pkg/state"
+)
+
+func TestApproveApplyPlan(t *testing.T) {
+	newReleases := func() (map[string]state.ReleaseSpec, map[string]state.ReleaseSpec) {
+		return map[string]state.ReleaseSpec{
+			"web": {Name: "web", Chart: "charts/web"},
+			"db":  {Name: "db", Chart: "charts/mysql"},
+		}, map[string]state.ReleaseSpec{
+			"legacy": {Name: "legacy", Chart: "charts/legacy"},
+		}
+	}
+
+	diffResults := map[string]state.DiffResult{
+		"web": {Release: "web", Changed: true, Added: 3, Removed: 1, Resources: []state.DiffResourceResult{{Kind: "Deployment", Name: "web"}}},
+		"db":  {Release: "db", Changed: true, Added: 40, Resources: []state.DiffResourceResult{{Kind: "StatefulSet", Name: "db"}, {Kind: "Service", Name: "db"}}},
+	}
+
+	newRun := func(answers map[string]bool, asked *[]string) *Run {
+		helm := &exectest.Helm{
+			Helm3: true,
+			Lists: map[exectest.ListKey]string{
+				{Filter: "^web$", Flags: helmV3ListFlagsWithoutKubeContext}: "web",
+			},
+		}
+
+		return &Run{
+			state: &state.HelmState{},
+			helm:  helm,
+			Ask: func(msg string) bool {
+				*asked = append(*asked, msg)
+				for m, a := range answers {
+					if strings.Contains(msg, m) {
+						return a
+					}
+				}
+				return false
+			},
+		}
+	}
+
+	wantPlan := `Plan: 1 to install, 1 to upgrade, 1 to delete
+
+RELEASE   CHART           ACTION    RESOURCES   CHANGES
+db        charts/mysql    install   2           +40/-0
+legacy    charts/legacy   delete    -           -
+web       charts/web      upgrade   1           +3/-1
+`
+
+	t.Run("approves the whole plan at once", func(t *testing.T) {
+		var asked []string
+
+		app := &App{Logger: helmexec.NewLogger(io.Discard, "debug")}
+
+		toUpdate, toDelete := newReleases()
+
+		approved, err := app.approveApplyPlan(newRun(map[string]bool{"this plan": true}, &asked), false, toUpdate, toDelete, diffResults)
+		require.NoError(t, err)
+		require.True(t, approved)
+		require.Len(t, asked, 1)
+		require.Contains(t, asked[0], wantPlan)
+		require.Len(t, toUpdate, 2)
+		require.Len(t, toDelete, 1)
+	})
+
+	t.Run("approves each release", func(t *testing.T) {
+		var asked []string
+
+		stdout := &bytes.Buffer{}
+		app := &App{Logger: helmexec.NewLogger(io.Discard, "debug"), stdout: stdout}
+
+		toUpdate, toDelete := newReleases()
+
+		approved, err := app.approveApplyPlan(newRun(map[string]bool{"release web": true, "release db": false, "release legacy": true}, &asked), true, toUpdate, toDelete, diffResults)
+		require.NoError(t, err)
+		require.True(t, approved)
+		require.Equal(t, wantPlan+"\n", stdout.String())
+		require.Equal(t, []string{
+			"Do you want to install release db (charts/mysql), changing 2 resources by +40/-0 lines?",
+			"Do you want to delete release legacy (charts/legacy)?",
+			"Do you want to upgrade release web (charts/web), changing 1 resources by +3/-1 lines?",
+		}, asked)
+		require.Equal(t, map[string]state.ReleaseSpec{"web": {Name: "web", Chart: "charts/web"}}, toUpdate)
+		require.Len(t, toDelete, 1)
+	})
+}
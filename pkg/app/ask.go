@@ -3,19 +3,28 @@ package app
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"strings"
 )
 
+// AskForConfirmation writes the prompt to os.Stdout, and reads the answers from os.Stdin until it's either yes or no.
+// The App writes its prompts to Options.Stdout instead.
+//
 // Copyright (c) 2017 Roland Singer [roland.singer@desertbit.com]
 //
 // Shamelessly borrowed from @r0l1's awesome work that is available at https://gist.github.com/r0l1/3dcbb0c8f6cfe9c66ab8008f55f8f28b
 func AskForConfirmation(s string) bool {
-	reader := bufio.NewReader(os.Stdin)
+	return askForConfirmation(os.Stdout, os.Stdin, s)
+}
+
+// askForConfirmation writes the prompt to w, and reads the answers from r until it's either yes or no
+func askForConfirmation(w io.Writer, r io.Reader, s string) bool {
+	reader := bufio.NewReader(r)
 
 	for {
-		fmt.Printf("%s [y/n]: ", s)
+		fmt.Fprintf(w, "%s [y/n]: ", s)
 
 		response, err := reader.ReadString('\n')
 		if err != nil {
@@ -0,0 +1,21 @@
+package app
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAskForConfirmation(t *testing.T) {
+	var out bytes.Buffer
+
+	require.True(t, askForConfirmation(&out, strings.NewReader("maybe\nYes\n"), "Do you really want to apply?"))
+	require.Equal(t, "Do you really want to apply? [y/n]: Do you really want to apply? [y/n]: ", out.String())
+
+	out.Reset()
+
+	require.False(t, askForConfirmation(&out, strings.NewReader("n\n"), "Do you really want to delete?"))
+	require.Equal(t, "Do you really want to delete? [y/n]: ", out.String())
+}
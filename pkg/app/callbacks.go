@@ -0,0 +1,100 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/helmfile/helmfile/pkg/state"
+)
+
+// ReleaseAction is the kind of change helmfile is going to make, or has made, to a release.
+type ReleaseAction string
+
+const (
+	// ReleaseActionUpgrade means the release is installed or upgraded via `helm upgrade --install`.
+	ReleaseActionUpgrade ReleaseAction = "upgrade"
+	// ReleaseActionDelete means the release is uninstalled via `helm delete`.
+	ReleaseActionDelete ReleaseAction = "delete"
+)
+
+// Callbacks is the set of functions called by App at specific points of its lifecycle.
+// It allows programs embedding helmfile to observe and gate its operations without parsing logs.
+//
+// Embed NopCallbacks in your implementation so that you need to implement only the callbacks you are interested in.
+type Callbacks interface {
+	// OnStateLoaded is called once per state file after it is loaded and its release templates are rendered.
+	OnStateLoaded(st *state.HelmState)
+	// OnReleasePlanned is called for every release that `apply`, `sync`, or `destroy` is going to change,
+	// before any change is made to the cluster.
+	// Returning an error aborts the whole operation on the state before touching any release.
+	OnReleasePlanned(release *state.ReleaseSpec, action ReleaseAction) error
+	// OnReleaseApplied is called for every planned release after the change is made. err is nil when it succeeded.
+	OnReleaseApplied(release *state.ReleaseSpec, action ReleaseAction, err error)
+	// OnError is called with the error returned by a command, if any.
+	OnError(err error)
+}
+
+// NopCallbacks is a Callbacks implementation that does nothing.
+type NopCallbacks struct{}
+
+func (NopCallbacks) OnStateLoaded(*state.HelmState) {}
+
+func (NopCallbacks) OnReleasePlanned(*state.ReleaseSpec, ReleaseAction) error { return nil }
+
+func (NopCallbacks) OnReleaseApplied(*state.ReleaseSpec, ReleaseAction, error) {}
+
+func (NopCallbacks) OnError(error) {}
+
+func (a *App) getCallbacks() Callbacks {
+	if a.callbacks == nil {
+		return NopCallbacks{}
+	}
+	return a.callbacks
+}
+
+// planReleases calls OnReleasePlanned for each of the releases, stopping at the first error.
+func (a *App) planReleases(r *Run, releases map[string]state.ReleaseSpec, action ReleaseAction) error {
+	cb := a.getCallbacks()
+
+	r.getOperation().progress.plan(releases, action)
+
+	for _, id := range sortedReleaseIDs(releases) {
+		r := releases[id]
+		if err := cb.OnReleasePlanned(&r, action); err != nil {
+			return appError(fmt.Sprintf("release %q is rejected", id), err)
+		}
+	}
+
+	return nil
+}
+
+// notifyReleasesApplied calls OnReleaseApplied for each of the releases,
+// along with the release error found in errs, if any.
+func (a *App) notifyReleasesApplied(r *Run, releases []state.ReleaseSpec, action ReleaseAction, errs []error) {
+	cb := a.getCallbacks()
+
+	releaseErrs := map[string]error{}
+	for _, err := range errs {
+		if e, ok := err.(*state.ReleaseError); ok && e.ReleaseSpec != nil {
+			releaseErrs[state.ReleaseToID(e.ReleaseSpec)] = e
+		}
+	}
+
+	progress := r.getOperation().progress
+
+	for i := range releases {
+		r := releases[i]
+		err := releaseErrs[state.ReleaseToID(&r)]
+		progress.applied(&r, action, err)
+		cb.OnReleaseApplied(&r, action, err)
+	}
+}
+
+func sortedReleaseIDs(releases map[string]state.ReleaseSpec) []string {
+	ids := make([]string, 0, len(releases))
+	for id := range releases {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,128 @@
+package app
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/helmfile/vals"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/exectest"
+	ffs "github.com/helmfile/helmfile/pkg/filesystem"
+	"github.com/helmfile/helmfile/pkg/helmexec"
+	"github.com/helmfile/helmfile/pkg/state"
+)
+
+type recordingCallbacks struct {
+	NopCallbacks
+
+	mu       sync.Mutex
+	loaded   []string
+	planned  []string
+	applied  []string
+	errs     []error
+	rejectOn string
+}
+
+func (c *recordingCallbacks) OnStateLoaded(st *state.HelmState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.loaded = append(c.loaded, st.FilePath)
+}
+
+func (c *recordingCallbacks) OnReleasePlanned(r *state.ReleaseSpec, action ReleaseAction) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.planned = append(c.planned, string(action)+":"+r.Name)
+	if r.Name == c.rejectOn {
+		return errors.New("rejected by test")
+	}
+	return nil
+}
+
+func (c *recordingCallbacks) OnReleaseApplied(r *state.ReleaseSpec, action ReleaseAction, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.applied = append(c.applied, string(action)+":"+r.Name)
+}
+
+func (c *recordingCallbacks) OnError(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.errs = append(c.errs, err)
+}
+
+func TestCallbacks_Sync(t *testing.T) {
+	files := map[string]string{
+		"/path/to/helmfile.yaml": `
+releases:
+- name: foo
+  chart: incubator/raw
+  namespace: default
+- name: bar
+  chart: incubator/raw
+  namespace: default
+  needs:
+  - default/foo
+`,
+	}
+
+	run := func(t *testing.T, cb *recordingCallbacks) (*exectest.Helm, error) {
+		t.Helper()
+
+		helm := &exectest.Helm{
+			Lists:         map[exectest.ListKey]string{},
+			DiffMutex:     &sync.Mutex{},
+			ChartsMutex:   &sync.Mutex{},
+			ReleasesMutex: &sync.Mutex{},
+			Helm3:         true,
+		}
+
+		valsRuntime, err := vals.New(vals.Options{CacheSize: 32})
+		require.NoError(t, err)
+
+		logger := newAppTestLogger()
+
+		app := appWithFs(&App{
+			OverrideHelmBinary:  DefaultHelmBinary,
+			fs:                  ffs.DefaultFileSystem(),
+			OverrideKubeContext: "default",
+			Env:                 "default",
+			Logger:              logger,
+			helms: map[helmKey]helmexec.Interface{
+				createHelmKey("helm", "default"): helm,
+			},
+			valsRuntime: valsRuntime,
+			callbacks:   cb,
+		}, files)
+
+		return helm, app.Sync(applyConfig{concurrency: 1, logger: logger})
+	}
+
+	t.Run("notifies every lifecycle event", func(t *testing.T) {
+		cb := &recordingCallbacks{}
+
+		helm, err := run(t, cb)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"helmfile.yaml"}, cb.loaded)
+		assert.Equal(t, []string{"upgrade:bar", "upgrade:foo"}, cb.planned)
+		assert.Equal(t, []string{"upgrade:foo", "upgrade:bar"}, cb.applied)
+		assert.Empty(t, cb.errs)
+		assert.Len(t, helm.Releases, 2)
+	})
+
+	t.Run("planned callback can abort the sync", func(t *testing.T) {
+		cb := &recordingCallbacks{rejectOn: "foo"}
+
+		helm, err := run(t, cb)
+		require.Error(t, err)
+
+		assert.Contains(t, err.Error(), "rejected by test")
+		assert.Empty(t, cb.applied)
+		assert.Len(t, cb.errs, 1)
+		assert.Empty(t, helm.Releases)
+	})
+}
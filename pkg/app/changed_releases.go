@@ -0,0 +1,112 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"text/tabwriter"
+
+	"go.uber.org/zap"
+
+	"github.com/helmfile/helmfile/pkg/state"
+)
+
+const (
+	releaseChangeUpdated = "updated"
+	releaseChangeDeleted = "deleted"
+)
+
+// ChangedRelease is a release that diff detected changes to
+type ChangedRelease struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Namespace   string `json:"namespace,omitempty"`
+	KubeContext string `json:"kubeContext,omitempty"`
+	Chart       string `json:"chart"`
+	// Change is either `updated` or `deleted`
+	Change string `json:"change"`
+}
+
+// changedReleases collects the releases with changes across all the states
+type changedReleases struct {
+	mu       sync.Mutex
+	releases map[string]ChangedRelease
+}
+
+func (c *changedReleases) add(change string, releases map[string]state.ReleaseSpec) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.releases == nil {
+		c.releases = map[string]ChangedRelease{}
+	}
+
+	for id, r := range releases {
+		c.releases[id] = ChangedRelease{
+			ID:          id,
+			Name:        r.Name,
+			Namespace:   r.Namespace,
+			KubeContext: r.KubeContext,
+			Chart:       r.Chart,
+			Change:      change,
+		}
+	}
+}
+
+// sorted returns the releases with changes sorted by ID
+func (c *changedReleases) sorted() []ChangedRelease {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	rs := make([]ChangedRelease, 0, len(c.releases))
+	for _, r := range c.releases {
+		rs = append(rs, r)
+	}
+
+	sort.Slice(rs, func(i, j int) bool {
+		return rs[i].ID < rs[j].ID
+	})
+
+	return rs
+}
+
+func (c *changedReleases) display(logger *zap.SugaredLogger) {
+	rs := c.sorted()
+	if len(rs) == 0 {
+		return
+	}
+
+	buf := &bytes.Buffer{}
+
+	w := new(tabwriter.Writer)
+
+	w.Init(buf, 0, 1, 3, ' ', 0)
+
+	fmt.Fprintln(w, "RELEASE\tCHART\tCHANGE")
+
+	for _, r := range rs {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", r.ID, r.Chart, r.Change)
+	}
+
+	_ = w.Flush()
+
+	logger.Info("\nRELEASES WITH CHANGES:")
+	logger.Info(buf.String())
+}
+
+// writeFile writes the releases with changes to the file as a JSON array, which is empty when there are no changes
+func (c *changedReleases) writeFile(path string) error {
+	bs, err := json.MarshalIndent(c.sorted(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("error generating json: %v", err)
+	}
+
+	if err := os.WriteFile(path, append(bs, '\n'), 0644); err != nil {
+		return fmt.Errorf("writing changed releases to %s: %v", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/state"
+)
+
+func TestChangedReleases_writeFile(t *testing.T) {
+	changed := &changedReleases{}
+
+	changed.add(releaseChangeUpdated, map[string]state.ReleaseSpec{
+		"prod/web/frontend": {Name: "frontend", Namespace: "web", KubeContext: "prod", Chart: "charts/frontend"},
+		"prod/db/mysql":     {Name: "mysql", Namespace: "db", KubeContext: "prod", Chart: "bitnami/mysql"},
+	})
+	changed.add(releaseChangeDeleted, map[string]state.ReleaseSpec{
+		"prod/web/legacy": {Name: "legacy", Namespace: "web", KubeContext: "prod", Chart: "charts/legacy"},
+	})
+
+	path := filepath.Join(t.TempDir(), "changed.json")
+	require.NoError(t, changed.writeFile(path))
+
+	bs, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, `[
+  {
+    "id": "prod/db/mysql",
+    "name": "mysql",
+    "namespace": "db",
+    "kubeContext": "prod",
+    "chart": "bitnami/mysql",
+    "change": "updated"
+  },
+  {
+    "id": "prod/web/frontend",
+    "name": "frontend",
+    "namespace": "web",
+    "kubeContext": "prod",
+    "chart": "charts/frontend",
+    "change": "updated"
+  },
+  {
+    "id": "prod/web/legacy",
+    "name": "legacy",
+    "namespace": "web",
+    "kubeContext": "prod",
+    "chart": "charts/legacy",
+    "change": "deleted"
+  }
+]
+`, string(bs))
+
+	empty := filepath.Join(t.TempDir(), "empty.json")
+	require.NoError(t, (&changedReleases{}).writeFile(empty))
+
+	bs, err = os.ReadFile(empty)
+	require.NoError(t, err)
+	require.Equal(t, "[]\n", string(bs))
+}
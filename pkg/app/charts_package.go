@@ -0,0 +1,65 @@
+package app
+
+import (
+	"github.com/helmfile/helmfile/pkg/state"
+)
+
+// PackageCharts packages the local charts of the selected releases into the output directory
+func (a *App) PackageCharts(c ChartsPackageConfigProvider) error {
+	var packages []state.ChartPackage
+
+	err := a.ForEachState(func(run *Run) (ok bool, errs []error) {
+		var statePackages []state.ChartPackage
+
+		ok, errs = a.packageCharts(run, c, &statePackages)
+
+		packages = append(packages, statePackages...)
+
+		return
+	}, false, SetFilter(true))
+
+	if err != nil {
+		return err
+	}
+
+	if c.Output() == "json" {
+		return FormatAsJson(a.Stdout(), packages)
+	}
+
+	if len(packages) == 0 {
+		c.Logger().Info("No local charts to package")
+		return nil
+	}
+
+	return FormatChartPackagesAsTable(a.Stdout(), packages)
+}
+
+func (a *App) packageCharts(r *Run, c ChartsPackageConfigProvider, packages *[]state.ChartPackage) (bool, []error) {
+	st := r.state
+	helm := r.helm
+
+	selectedReleases, _, err := a.getSelectedReleases(r, false)
+	if err != nil {
+		return false, []error{err}
+	}
+	if len(selectedReleases) == 0 {
+		return false, nil
+	}
+
+	st.Releases = selectedReleases
+
+	// Add the repositories the dependencies of the charts are fetched from
+	if err := r.ctx.SyncReposOnce(st, helm); err != nil {
+		return false, []error{err}
+	}
+
+	ps, errs := st.PackageCharts(helm, state.PackageChartsOpts{
+		OutputDir: c.OutputDir(),
+		Version:   c.ChartVersion(),
+		GitSuffix: c.GitSuffix(),
+	})
+
+	*packages = ps
+
+	return true, errs
+}
@@ -0,0 +1,69 @@
+package app
+
+import (
+	"github.com/helmfile/helmfile/pkg/state"
+)
+
+// PushCharts packages the local charts of the selected releases, and pushes them to the OCI registry
+func (a *App) PushCharts(c ChartsPushConfigProvider) error {
+	var pushes []state.ChartPush
+
+	err := a.ForEachState(func(run *Run) (ok bool, errs []error) {
+		var statePushes []state.ChartPush
+
+		ok, errs = a.pushCharts(run, c, &statePushes)
+
+		pushes = append(pushes, statePushes...)
+
+		return
+	}, false, SetFilter(true))
+
+	if err != nil {
+		return err
+	}
+
+	if c.Output() == "json" {
+		return FormatAsJson(a.Stdout(), pushes)
+	}
+
+	if len(pushes) == 0 {
+		c.Logger().Info("No local charts to push")
+		return nil
+	}
+
+	return FormatChartPushesAsTable(a.Stdout(), pushes)
+}
+
+func (a *App) pushCharts(r *Run, c ChartsPushConfigProvider, pushes *[]state.ChartPush) (bool, []error) {
+	st := r.state
+	helm := r.helm
+
+	selectedReleases, _, err := a.getSelectedReleases(r, false)
+	if err != nil {
+		return false, []error{err}
+	}
+	if len(selectedReleases) == 0 {
+		return false, nil
+	}
+
+	st.Releases = selectedReleases
+
+	if !c.DryRun() {
+		// Log in to the registries, and add the repositories the dependencies of the charts are fetched from
+		if err := r.ctx.SyncReposOnce(st, helm); err != nil {
+			return false, []error{err}
+		}
+	}
+
+	ps, errs := st.PushCharts(helm, state.PushChartsOpts{
+		Registry:  c.Registry(),
+		Version:   c.ChartVersion(),
+		GitSuffix: c.GitSuffix(),
+		Rewrite:   c.Rewrite(),
+		DryRun:    c.DryRun(),
+	})
+
+	*pushes = ps
+
+	return true, errs
+}
@@ -1,6 +1,10 @@
 package app
 
-import "go.uber.org/zap"
+import (
+	"time"
+
+	"go.uber.org/zap"
+)
 
 type ConfigProvider interface {
 	Args() string
@@ -12,6 +16,14 @@ type ConfigProvider interface {
 	Namespace() string
 	Chart() string
 	Selectors() []string
+	HelmfileSelectors() []string
+	RepositoryMirrors() []string
+	CommandTimeouts() []string
+	CommandLiveOutputs() []string
+	TempDir() string
+	KeepTempFiles() bool
+	Offline() bool
+	AllowClusterLookups() bool
 	StateValuesSet() map[string]interface{}
 	StateValuesFiles() []string
 	Env() string
@@ -32,6 +44,7 @@ type DepsConfigProvider interface {
 	Args() string
 	SkipRepos() bool
 	IncludeTransitiveNeeds() bool
+	UpdateLocks() bool
 
 	concurrencyConfig
 }
@@ -49,8 +62,8 @@ type ApplyConfigProvider interface {
 	Set() []string
 	SkipCRDs() bool
 	SkipDeps() bool
-	Wait() bool
-	WaitForJobs() bool
+	LogOutput() string
+	releaseOverrides
 
 	IncludeTests() bool
 
@@ -61,6 +74,9 @@ type ApplyConfigProvider interface {
 	SuppressDiff() bool
 
 	DetailedExitcode() bool
+	ChangedReleasesFile() string
+	DiffOutputDir() string
+	DiffOutputFileTemplate() string
 
 	Color() bool
 	NoColor() bool
@@ -73,13 +89,21 @@ type ApplyConfigProvider interface {
 	Validate() bool
 	SkipCleanup() bool
 	SkipDiffOnInstall() bool
+	KubeVersionCheck() string
+	DiffRenderer() string
+	DryRun() bool
+	PlanHooks() bool
+	InteractivePlan() bool
 
 	DAGConfig
 
 	concurrencyConfig
+	stateConcurrencyConfig
 	interactive
 	loggingConfig
 	valuesControlMode
+	// cacheConfig is needed to diff the releases like the diff command, which doesn't cache the results on apply
+	cacheConfig
 }
 
 type SyncConfigProvider interface {
@@ -90,10 +114,11 @@ type SyncConfigProvider interface {
 	Set() []string
 	SkipCRDs() bool
 	SkipDeps() bool
-	Wait() bool
-	WaitForJobs() bool
+	LogOutput() string
+	releaseOverrides
 
 	Validate() bool
+	KubeVersionCheck() string
 
 	SkipNeeds() bool
 	IncludeNeeds() bool
@@ -101,6 +126,7 @@ type SyncConfigProvider interface {
 	DAGConfig
 
 	concurrencyConfig
+	stateConcurrencyConfig
 	interactive
 	loggingConfig
 	valuesControlMode
@@ -113,6 +139,9 @@ type DiffConfigProvider interface {
 	Values() []string
 	Set() []string
 	Validate() bool
+	KubeVersionCheck() string
+	DiffRenderer() string
+	PlanHooks() bool
 	SkipCRDs() bool
 	SkipDeps() bool
 
@@ -128,6 +157,7 @@ type DiffConfigProvider interface {
 	DAGConfig
 
 	DetailedExitcode() bool
+	ChangedReleasesFile() string
 	Color() bool
 	NoColor() bool
 	Context() int
@@ -135,6 +165,7 @@ type DiffConfigProvider interface {
 
 	concurrencyConfig
 	valuesControlMode
+	cacheConfig
 }
 
 // TODO: Remove this function once Helmfile v0.x
@@ -144,10 +175,14 @@ type DeleteConfigProvider interface {
 	Purge() bool
 	SkipDeps() bool
 	SkipCharts() bool
+	ReportOrphans() bool
+	DeleteOrphans() []string
+	KeepNamespaces() []string
 
 	interactive
 	loggingConfig
 	concurrencyConfig
+	cascadeConfig
 }
 
 type DestroyConfigProvider interface {
@@ -155,10 +190,14 @@ type DestroyConfigProvider interface {
 
 	SkipDeps() bool
 	SkipCharts() bool
+	ReportOrphans() bool
+	DeleteOrphans() []string
+	KeepNamespaces() []string
 
 	interactive
 	loggingConfig
 	concurrencyConfig
+	cascadeConfig
 }
 
 type TestConfigProvider interface {
@@ -168,6 +207,8 @@ type TestConfigProvider interface {
 	Timeout() int
 	Cleanup() bool
 	Logs() bool
+	LogsDir() string
+	Output() string
 
 	concurrencyConfig
 }
@@ -189,6 +230,7 @@ type FetchConfigProvider interface {
 	SkipDeps() bool
 	OutputDir() string
 	OutputDirTemplate() string
+	Vendor() bool
 
 	concurrencyConfig
 }
@@ -206,10 +248,13 @@ type TemplateConfigProvider interface {
 	SkipTests() bool
 	OutputDir() string
 	IncludeCRDs() bool
+	ShowOnly() []string
+	AnnotateSource() bool
 
 	DAGConfig
 
 	concurrencyConfig
+	cacheConfig
 }
 
 type DAGConfig interface {
@@ -222,6 +267,7 @@ type WriteValuesConfigProvider interface {
 	Values() []string
 	Set() []string
 	OutputFileTemplate() string
+	IncludeChartValues() bool
 	SkipDeps() bool
 	SkipCleanup() bool
 	IncludeTransitiveNeeds() bool
@@ -231,10 +277,125 @@ type WriteValuesConfigProvider interface {
 
 type StatusesConfigProvider interface {
 	Args() string
+	Output() string
+
+	concurrencyConfig
+}
+
+type ExecConfigProvider interface {
+	Command() []string
+
+	concurrencyConfig
+}
+
+type HistoryConfigProvider interface {
+	Max() int
+	Output() string
 
 	concurrencyConfig
 }
 
+type BumpConfigProvider interface {
+	DryRun() bool
+	SkipRepos() bool
+	Output() string
+
+	loggingConfig
+}
+
+type ChartsPackageConfigProvider interface {
+	OutputDir() string
+	ChartVersion() string
+	GitSuffix() bool
+	Output() string
+
+	loggingConfig
+}
+
+type ChartsPushConfigProvider interface {
+	Registry() string
+	ChartVersion() string
+	GitSuffix() bool
+	Rewrite() bool
+	DryRun() bool
+	Output() string
+
+	loggingConfig
+}
+
+type AffectedConfigProvider interface {
+	Release() string
+	Since() string
+	Output() string
+}
+
+type GraphConfigProvider interface {
+	Output() string
+}
+
+type PreflightConfigProvider interface {
+	Operation() string
+	Output() string
+
+	rbacRenderConfig
+}
+
+type RBACConfigProvider interface {
+	Name() string
+
+	rbacRenderConfig
+}
+
+// rbacRenderConfig is the config to render the manifests of the releases to tell the permissions needed to apply them
+type rbacRenderConfig interface {
+	Args() string
+
+	Values() []string
+	Set() []string
+	SkipDeps() bool
+
+	DAGConfig
+	concurrencyConfig
+}
+
+type ValidateConfigProvider interface {
+	Args() string
+
+	Values() []string
+	Set() []string
+	SkipDeps() bool
+	SchemaLocations() []string
+	Strict() bool
+	IgnoreMissingSchemas() bool
+	Policies() []string
+	Output() string
+
+	DAGConfig
+	concurrencyConfig
+}
+
+type SnapshotConfigProvider interface {
+	Args() string
+
+	Values() []string
+	Set() []string
+	SkipDeps() bool
+	IncludeCRDs() bool
+	SkipTests() bool
+	SnapshotDir() string
+
+	DAGConfig
+	concurrencyConfig
+}
+
+type WatchConfigProvider interface {
+	Command() string
+	Interval() time.Duration
+	DriftInterval() time.Duration
+	DriftWebhook() string
+	ExitOnDrift() bool
+}
+
 type StateConfigProvider interface {
 	EmbedValues() bool
 }
@@ -243,6 +404,43 @@ type concurrencyConfig interface {
 	Concurrency() int
 }
 
+type stateConcurrencyConfig interface {
+	// StateConcurrency is the maximum number of helmfiles whose releases are processed concurrently, which are processed one by one when it's 1 or less
+	StateConcurrency() int
+}
+
+type cacheConfig interface {
+	// RenderCache enables the cache of the results of the releases whose inputs haven't changed
+	RenderCache() bool
+	// NoCache processes all the releases instead of reusing the cached results, while still caching the new ones with RenderCache
+	NoCache() bool
+	// CacheDir is the directory the results are cached in, which defaults to the cache directory of helmfile
+	CacheDir() string
+}
+
+type releaseOverrides interface {
+	// Atomic and CleanupOnFail override the ones of the releases and helmDefaults when non-nil
+	Atomic() *bool
+	CleanupOnFail() *bool
+	// Wait and WaitForJobs override the ones of the releases and helmDefaults when non-nil
+	Wait() *bool
+	WaitForJobs() *bool
+	// ReleaseTimeout overrides the timeout of the releases and helmDefaults unless it's state.EmptyTimeout
+	ReleaseTimeout() int
+}
+
+type cascadeConfig interface {
+	// Cascade deletes the releases tier by tier in the reverse order of their needs,
+	// waiting for the resources of each tier to be deleted before the next one
+	Cascade() bool
+	// IncludeDependents deletes the releases needing the selected ones, directly or transitively, too
+	IncludeDependents() bool
+	// CascadeTimeout is the seconds to wait for the resources of each release to be deleted
+	CascadeTimeout() int
+	// ContinueOnError keeps deleting the remaining tiers after a tier fails to be deleted
+	ContinueOnError() bool
+}
+
 type loggingConfig interface {
 	Logger() *zap.SugaredLogger
 }
@@ -256,6 +454,11 @@ type ListConfigProvider interface {
 	SkipCharts() bool
 }
 
+type DocsConfigProvider interface {
+	OutputFile() string
+	Check() bool
+}
+
 type CacheConfigProvider interface{}
 
 type InitConfigProvider interface {
@@ -1,25 +1,44 @@
 package app
 
 import (
+	"sync"
+
+	"github.com/helmfile/helmfile/pkg/errors"
 	"github.com/helmfile/helmfile/pkg/state"
 )
 
 type Context struct {
+	// reposMutex serializes the updates of the repositories of the states processed concurrently
+	reposMutex   *sync.Mutex
 	updatedRepos map[string]bool
+	stdinValues  *stdinValues
 }
 
 func NewContext() Context {
 	return Context{
+		reposMutex:   &sync.Mutex{},
 		updatedRepos: map[string]bool{},
 	}
 }
 
 func (ctx Context) SyncReposOnce(st *state.HelmState, helm state.RepoUpdater) error {
+	ctx.reposMutex.Lock()
+	defer ctx.reposMutex.Unlock()
+
 	updated, err := st.SyncRepos(helm, ctx.updatedRepos)
 
 	for _, r := range updated {
 		ctx.updatedRepos[r] = true
 	}
 
-	return err
+	return errors.WithCode(errors.CodeRepoFetch, errors.PhaseRepos, err)
+}
+
+// ValuesFiles returns the values files with `-` replaced by the file containing the values read from stdin
+func (ctx Context) ValuesFiles(files []string) ([]string, error) {
+	if ctx.stdinValues == nil {
+		return files, nil
+	}
+
+	return ctx.stdinValues.valuesFiles(files)
 }
@@ -2,9 +2,11 @@ package app
 
 import (
 	"bytes"
+	gocontext "context"
 	"errors"
 	"fmt"
 	"path/filepath"
+	"strings"
 
 	"github.com/helmfile/vals"
 	"github.com/imdario/mergo"
@@ -16,6 +18,7 @@ import (
 	"github.com/helmfile/helmfile/pkg/remote"
 	"github.com/helmfile/helmfile/pkg/runtime"
 	"github.com/helmfile/helmfile/pkg/state"
+	"github.com/helmfile/helmfile/pkg/tmpl"
 )
 
 const (
@@ -26,6 +29,14 @@ type desiredStateLoader struct {
 	overrideKubeContext string
 	overrideHelmBinary  string
 	enableLiveOutput    bool
+	repositoryMirrors   []string
+	hookPlan            *state.HookPlan
+	workDir             string
+	// ctx is the context of the run the states are loaded for, which kills their helm commands once it is done
+	ctx     gocontext.Context
+	offline bool
+	// clusterLookups is whether the templates of the states are allowed to query the cluster with kubectlGet
+	clusterLookups tmpl.ClusterLookups
 
 	env       string
 	namespace string
@@ -52,6 +63,10 @@ func (ld *desiredStateLoader) Load(f string, opts LoadOpts) (*state.HelmState, e
 		}
 		storage := state.NewStorage(opts.CalleePath, ld.logger, ld.fs)
 		envld := state.NewEnvironmentValuesLoader(storage, ld.fs, ld.logger, ld.remote)
+		envld.Namespace = ld.namespace
+		envld.KubeContext = ld.overrideKubeContext
+		envld.HelmBinary = ld.overrideHelmBinary
+		envld.ClusterLookups = ld.clusterLookups
 		handler := state.MissingFileHandlerError
 		vals, err := envld.LoadEnvironmentValues(&handler, args, environment.New(ld.env), ld.env)
 		if err != nil {
@@ -83,6 +98,17 @@ func (ld *desiredStateLoader) Load(f string, opts LoadOpts) (*state.HelmState, e
 		st.HelmDefaults.KubeContext = ld.overrideKubeContext
 	}
 
+	for _, m := range ld.repositoryMirrors {
+		from, to, err := state.ParseRepositoryMirror(m)
+		if err != nil {
+			return nil, err
+		}
+		if st.RepositoryMirrors == nil {
+			st.RepositoryMirrors = map[string]string{}
+		}
+		st.RepositoryMirrors[from] = to
+	}
+
 	if ld.namespace != "" {
 		if st.OverrideNamespace != "" {
 			return nil, errors.New("err: Cannot use option --namespace and set attribute namespace.")
@@ -97,6 +123,11 @@ func (ld *desiredStateLoader) Load(f string, opts LoadOpts) (*state.HelmState, e
 		st.OverrideChart = ld.chart
 	}
 
+	st.HookPlan = ld.hookPlan
+	st.WorkDir = ld.workDir
+	st.Context = ld.ctx
+	st.Offline = ld.offline
+
 	return st, nil
 }
 
@@ -140,10 +171,10 @@ func (ld *desiredStateLoader) loadFileWithOverrides(inheritedEnv, overrodeEnv *e
 
 	for i, h := range self.Helmfiles {
 		if h.Path == f {
-			return nil, fmt.Errorf("%s contains a recursion into the same sub-helmfile at helmfiles[%d]", f, i)
+			return nil, fmt.Errorf("%s contains a recursion into the same sub-helmfile at %s", f, h.Ref(i))
 		}
 		if h.Path == "." {
-			return nil, fmt.Errorf("%s contains a recursion into the the directory containing this helmfile at helmfiles[%d]", f, i)
+			return nil, fmt.Errorf("%s contains a recursion into the the directory containing this helmfile at %s", f, h.Ref(i))
 		}
 	}
 
@@ -153,6 +184,9 @@ func (ld *desiredStateLoader) loadFileWithOverrides(inheritedEnv, overrodeEnv *e
 func (a *desiredStateLoader) underlying() *state.StateCreator {
 	c := state.NewCreator(a.logger, a.fs, a.valsRuntime, a.getHelm, a.overrideHelmBinary, a.remote, a.enableLiveOutput, a.lockFilePath)
 	c.LoadFile = a.loadFile
+	c.Namespace = a.namespace
+	c.KubeContext = a.overrideKubeContext
+	c.ClusterLookups = a.clusterLookups
 	return c
 }
 
@@ -181,10 +215,19 @@ func (ld *desiredStateLoader) load(env, overrodeEnv *environment.Environment, ba
 	normalizedContent := bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
 	parts := bytes.Split(normalizedContent, []byte("\n---\n"))
 
-	var finalState *state.HelmState
+	var (
+		finalState *state.HelmState
+		// templateLibraries is the directories of the template libraries declared in the previous parts
+		templateLibraries []string
+	)
+
+	// line is the line of the file the current part starts at
+	line := 1
 
 	for i, part := range parts {
 		id := fmt.Sprintf("%s.part.%d", filename, i)
+		partLine := line
+		line += bytes.Count(part, []byte("\n")) + 2
 
 		var rawContent []byte
 
@@ -195,12 +238,12 @@ func (ld *desiredStateLoader) load(env, overrodeEnv *environment.Environment, ba
 			if env == nil && overrodeEnv == nil {
 				yamlBuf, err = ld.renderTemplatesToYaml(baseDir, id, part)
 				if err != nil {
-					return nil, fmt.Errorf("error during %s parsing: %v", id, err)
+					return nil, newStateRenderError(filename, i, partLine, err)
 				}
 			} else {
-				yamlBuf, err = ld.renderTemplatesToYamlWithEnv(baseDir, id, part, env, overrodeEnv)
+				yamlBuf, err = ld.renderTemplatesToYamlWithEnv(baseDir, id, part, env, overrodeEnv, templateLibraries)
 				if err != nil {
-					return nil, fmt.Errorf("error during %s parsing: %v", id, err)
+					return nil, newStateRenderError(filename, i, partLine, err)
 				}
 			}
 			rawContent = yamlBuf.Bytes()
@@ -233,7 +276,90 @@ func (ld *desiredStateLoader) load(env, overrodeEnv *environment.Environment, ba
 		env = &finalState.Env
 
 		ld.logger.Debugf("merged environment: %v", env)
+
+		templateLibraries, err = ld.locateTemplateLibraries(baseDir, finalState.TemplateLibraries)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	return finalState, nil
 }
+
+// StateRenderError is the error of rendering a document of a state file, located at the line of the file where rendering failed
+type StateRenderError struct {
+	// Path is the path of the state file, or of the template library file when the error occurred in it
+	Path string
+	// Document is the 1-based index of the document in the state file, or 0 for a template library file
+	Document int
+	// Line is the line of the file, or 0 when it's unknown
+	Line int
+
+	Err error
+}
+
+// newStateRenderError locates the error of rendering the document at the index of the state file, which starts at the line of the file
+func newStateRenderError(filename string, index, partLine int, err error) *StateRenderError {
+	e := &StateRenderError{Path: filename, Document: index + 1, Err: err}
+
+	name, line := tmpl.ErrorLocation(err)
+	switch {
+	case name == tmpl.TemplateName:
+		e.Line = partLine + line - 1
+	case name != "":
+		e.Path = name
+		e.Document = 0
+		e.Line = line
+	}
+
+	return e
+}
+
+func (e *StateRenderError) Error() string {
+	location := []string{e.Path}
+	if e.Document > 0 {
+		location = append(location, fmt.Sprintf("document %d", e.Document))
+	}
+	if e.Line > 0 {
+		location = append(location, fmt.Sprintf("line %d", e.Line))
+	}
+
+	// The messages of required and fail are written for the users of the state file, so the template internals are left out
+	var failErr *tmpl.FailError
+	if errors.As(e.Err, &failErr) {
+		return fmt.Sprintf("%s: %s", strings.Join(location, ", "), failErr.Message)
+	}
+
+	return fmt.Sprintf("%s: %v", strings.Join(location, ", "), e.Err)
+}
+
+func (e *StateRenderError) Unwrap() error {
+	return e.Err
+}
+
+// locateTemplateLibraries returns the local directories of the template libraries, fetching the remote ones.
+// The relative paths are relative to the directory of the state file.
+func (ld *desiredStateLoader) locateTemplateLibraries(baseDir string, libraries []string) ([]string, error) {
+	var dirs []string
+
+	for _, lib := range libraries {
+		dir := lib
+		if remote.IsRemote(lib) {
+			fetched, err := ld.remote.Locate(lib)
+			if err != nil {
+				return nil, fmt.Errorf("locate template library %s: %v", lib, err)
+			}
+			dir = fetched
+		} else if !filepath.IsAbs(lib) {
+			dir = filepath.Join(baseDir, lib)
+		}
+
+		if !ld.fs.DirectoryExistsAt(dir) {
+			return nil, fmt.Errorf("template library %s: %s is not a directory", lib, dir)
+		}
+
+		dirs = append(dirs, dir)
+	}
+
+	return dirs, nil
+}
@@ -1,11 +1,13 @@
 package app
 
 import (
+	"io"
 	"strings"
 	"sync"
 	"testing"
 
 	"github.com/helmfile/vals"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 
 	"github.com/helmfile/helmfile/pkg/exectest"
@@ -40,6 +42,13 @@ type destroyConfig struct {
 	logger                 *zap.SugaredLogger
 	includeTransitiveNeeds bool
 	skipCharts             bool
+	reportOrphans          bool
+	deleteOrphans          []string
+	keepNamespaces         []string
+	cascade                bool
+	includeDependents      bool
+	cascadeTimeout         int
+	continueOnError        bool
 }
 
 func (d destroyConfig) Args() string {
@@ -50,6 +59,34 @@ func (d destroyConfig) SkipCharts() bool {
 	return d.skipCharts
 }
 
+func (d destroyConfig) ReportOrphans() bool {
+	return d.reportOrphans
+}
+
+func (d destroyConfig) DeleteOrphans() []string {
+	return d.deleteOrphans
+}
+
+func (d destroyConfig) KeepNamespaces() []string {
+	return d.keepNamespaces
+}
+
+func (d destroyConfig) Cascade() bool {
+	return d.cascade
+}
+
+func (d destroyConfig) IncludeDependents() bool {
+	return d.includeDependents
+}
+
+func (d destroyConfig) CascadeTimeout() int {
+	return d.cascadeTimeout
+}
+
+func (d destroyConfig) ContinueOnError() bool {
+	return d.continueOnError
+}
+
 func (d destroyConfig) Interactive() bool {
 	return d.interactive
 }
@@ -799,3 +836,114 @@ changing working directory back to "/path/to"
 		})
 	})
 }
+
+func TestDestroy_Cascade(t *testing.T) {
+	files := map[string]string{
+		"/path/to/helmfile.yaml": `
+releases:
+- name: database
+  chart: charts/mysql
+- name: backend
+  chart: charts/backend
+  needs:
+  - database
+- name: frontend
+  chart: charts/frontend
+  needs:
+  - backend
+- name: logging
+  chart: charts/fluent-bit
+`,
+	}
+
+	testcases := []struct {
+		name              string
+		frontend          string
+		includeDependents bool
+		continueOnError   bool
+		deleted           []string
+		error             string
+	}{
+		{
+			name:     "deletes only the selected one",
+			frontend: "frontend",
+			deleted:  []string{"database"},
+		},
+		{
+			name:              "deletes the releases needing the selected one first",
+			frontend:          "frontend",
+			includeDependents: true,
+			deleted:           []string{"frontend", "backend", "database"},
+		},
+		{
+			name:              "stops at the first failing tier",
+			frontend:          "frontend-error",
+			includeDependents: true,
+			deleted:           nil,
+			error:             `release "frontend-error" failed: error`,
+		},
+		{
+			name:              "continues on error",
+			frontend:          "frontend-error",
+			includeDependents: true,
+			continueOnError:   true,
+			deleted:           []string{"backend", "database"},
+			error:             `release "frontend-error" failed: error`,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			helm := &exectest.Helm{
+				Helm3:         true,
+				DiffMutex:     &sync.Mutex{},
+				ChartsMutex:   &sync.Mutex{},
+				ReleasesMutex: &sync.Mutex{},
+			}
+
+			valsRuntime, err := vals.New(vals.Options{CacheSize: 32})
+			if err != nil {
+				t.Fatalf("unexpected error creating vals runtime: %v", err)
+			}
+
+			logger := helmexec.NewLogger(io.Discard, "debug")
+
+			app := appWithFs(&App{
+				OverrideHelmBinary:  DefaultHelmBinary,
+				fs:                  ffs.DefaultFileSystem(),
+				OverrideKubeContext: "default",
+				Env:                 "default",
+				Logger:              logger,
+				Selectors:           []string{"name=database"},
+				helms: map[helmKey]helmexec.Interface{
+					createHelmKey("helm", "default"): helm,
+				},
+				valsRuntime: valsRuntime,
+			}, map[string]string{
+				"/path/to/helmfile.yaml": strings.Replace(files["/path/to/helmfile.yaml"], "name: frontend\n", "name: "+tc.frontend+"\n", 1),
+			})
+
+			destroyErr := app.Destroy(destroyConfig{
+				concurrency:       1,
+				logger:            logger,
+				cascade:           true,
+				includeDependents: tc.includeDependents,
+				cascadeTimeout:    60,
+				continueOnError:   tc.continueOnError,
+			})
+
+			if tc.error == "" {
+				require.NoError(t, destroyErr)
+			} else {
+				require.ErrorContains(t, destroyErr, tc.error)
+			}
+
+			var deleted []string
+			for _, r := range helm.Deleted {
+				deleted = append(deleted, r.Name)
+				require.Equal(t, []string{"--wait", "--timeout", "60s"}, r.Flags[len(r.Flags)-3:])
+			}
+			require.Equal(t, tc.deleted, deleted)
+		})
+	}
+}
@@ -36,9 +36,11 @@ type diffConfig struct {
 	diffOutput             string
 	concurrency            int
 	detailedExitcode       bool
+	changedReleasesFile    string
 	interactive            bool
 	skipDiffOnInstall      bool
 	reuseValues            bool
+	planHooks              bool
 	logger                 *zap.SugaredLogger
 }
 
@@ -118,6 +120,19 @@ func (a diffConfig) DiffOutput() string {
 	return a.diffOutput
 }
 
+// RenderCache is false not to reuse the results of the previous test runs
+func (a diffConfig) RenderCache() bool {
+	return false
+}
+
+func (a diffConfig) NoCache() bool {
+	return false
+}
+
+func (a diffConfig) CacheDir() string {
+	return ""
+}
+
 func (a diffConfig) Concurrency() int {
 	return a.concurrency
 }
@@ -126,6 +141,10 @@ func (a diffConfig) DetailedExitcode() bool {
 	return a.detailedExitcode
 }
 
+func (a diffConfig) ChangedReleasesFile() string {
+	return a.changedReleasesFile
+}
+
 func (a diffConfig) Interactive() bool {
 	return a.interactive
 }
@@ -154,6 +173,18 @@ func (a diffConfig) PostRenderer() string {
 	return ""
 }
 
+func (a diffConfig) KubeVersionCheck() string {
+	return ""
+}
+
+func (a diffConfig) DiffRenderer() string {
+	return ""
+}
+
+func (a diffConfig) PlanHooks() bool {
+	return a.planHooks
+}
+
 func TestDiff(t *testing.T) {
 	type flags struct {
 		skipNeeds    bool
@@ -0,0 +1,28 @@
+// Package app implements the helmfile commands on top of the state and helmexec packages.
+//
+// Besides backing the helmfile CLI, the package is usable as a library by other Go programs
+// that want to embed the helmfile engine. Create an App with NewWithOptions and call one of the
+// per-command methods with the corresponding config, e.g. one created by the config package:
+//
+//	a := app.NewWithOptions(app.Options{
+//		FileOrDir:   "helmfile.yaml",
+//		Environment: "production",
+//		Stdout:      &buf,
+//	})
+//	err := a.ListReleases(config.NewListImpl(global, config.NewListOptions()))
+//
+// The command results, like the `list` tables, the diffs and the rendered manifests, the outputs of the release tests,
+// the confirmation prompts of the interactive commands, and the live outputs of the helm commands are written to Options.Stdout,
+// which defaults to os.Stdout. The logs are written by Options.Logger.
+//
+// The App is configured only by Options. The exported fields of App, like Namespace and Selectors, are kept for compatibility,
+// and the settings added since then, like Offline and TempDir, are available only as Options.
+//
+// Diff, Sync, Apply and Template have the variants taking contexts, like ApplyContext, which stop once the contexts are done,
+// killing the helm commands running. LoadStates returns the states with the selected releases without changing anything,
+// for the programs reading the desired states, like controllers reconciling them:
+//
+//	states, err := a.LoadStates(ctx)
+//	...
+//	err = a.ApplyContext(ctx, config.NewApplyImpl(global, config.NewApplyOptions()))
+package app
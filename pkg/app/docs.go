@@ -0,0 +1,225 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/helmfile/helmfile/pkg/state"
+)
+
+// stateDoc is the part of a state file documented by `helmfile docs`
+type stateDoc struct {
+	// file is the path to the state file relative to the working directory
+	file         string
+	environments []string
+	subHelmfiles []string
+	releases     []state.ReleaseSpec
+}
+
+// Docs generates the Markdown documentation of the desired state of the selected releases,
+// and writes it to the output file or the standard output.
+// With check, it fails instead when the output file isn't up to date.
+func (a *App) Docs(c DocsConfigProvider) error {
+	wd, err := a.fs.Getwd()
+	if err != nil {
+		return err
+	}
+
+	var docs []stateDoc
+
+	err = a.ForEachState(func(run *Run) (bool, []error) {
+		st := run.state
+
+		selected, _, err := a.getSelectedReleases(run, false)
+		if err != nil {
+			return false, []error{err}
+		}
+
+		file := st.FilePath
+		if full, err := st.FullFilePath(); err == nil {
+			if rel, err := filepath.Rel(wd, full); err == nil {
+				file = rel
+			}
+		}
+
+		doc := stateDoc{
+			file:     file,
+			releases: selected,
+		}
+
+		for name := range st.Environments {
+			doc.environments = append(doc.environments, name)
+		}
+		sort.Strings(doc.environments)
+
+		for _, h := range st.Helmfiles {
+			doc.subHelmfiles = append(doc.subHelmfiles, h.Path)
+		}
+
+		docs = append(docs, doc)
+
+		return len(selected) > 0, nil
+	}, false, SetFilter(true))
+	if err != nil {
+		return err
+	}
+
+	// The sub-helmfiles are visited before their parents, so the state files are sorted to have the parents first
+	sort.SliceStable(docs, func(i, j int) bool {
+		return strings.Count(docs[i].file, string(filepath.Separator)) < strings.Count(docs[j].file, string(filepath.Separator))
+	})
+
+	var buf bytes.Buffer
+	if err := writeDocs(&buf, a.Env, docs); err != nil {
+		return err
+	}
+
+	outputFile := c.OutputFile()
+	if outputFile == "" {
+		_, err := a.Stdout().Write(buf.Bytes())
+		return err
+	}
+
+	if c.Check() {
+		current, err := os.ReadFile(outputFile)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if !bytes.Equal(current, buf.Bytes()) {
+			return fmt.Errorf("%s is out of date: run `helmfile docs --output-file %s` to update it", outputFile, outputFile)
+		}
+		return nil
+	}
+
+	return os.WriteFile(outputFile, buf.Bytes(), 0644)
+}
+
+// writeDocs writes the Markdown documentation of the state files
+func writeDocs(w io.Writer, environment string, docs []stateDoc) error {
+	if environment == "" {
+		environment = state.DefaultEnv
+	}
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# Desired state\n\nEnvironment: `%s`\n", environment)
+
+	for _, d := range docs {
+		fmt.Fprintf(&b, "\n## %s\n", d.file)
+
+		if len(d.environments) > 0 {
+			fmt.Fprintf(&b, "\nEnvironments: %s\n", codeList(d.environments))
+		}
+
+		if len(d.subHelmfiles) > 0 {
+			b.WriteString("\nSub-helmfiles:\n\n")
+			for _, h := range d.subHelmfiles {
+				fmt.Fprintf(&b, "- `%s`\n", h)
+			}
+		}
+
+		if len(d.releases) == 0 {
+			continue
+		}
+
+		b.WriteString("\n### Releases\n\n")
+		b.WriteString("| Release | Namespace | Kube context | Chart | Version | Installed | Labels | Needs |\n")
+		b.WriteString("|---|---|---|---|---|---|---|---|\n")
+
+		for _, r := range d.releases {
+			var labels []string
+			for k, v := range r.Labels {
+				labels = append(labels, k+"="+v)
+			}
+			sort.Strings(labels)
+
+			installed := "yes"
+			if !r.Desired() {
+				installed = "no"
+			}
+
+			fmt.Fprintf(&b, "| %s | %s | %s | %s | %s | %s | %s | %s |\n",
+				markdownCell(r.Name),
+				markdownCell(r.Namespace),
+				markdownCell(r.KubeContext),
+				markdownCell(r.Chart),
+				markdownCell(r.Version),
+				installed,
+				markdownCell(strings.Join(labels, ", ")),
+				markdownCell(strings.Join(r.Needs, ", ")),
+			)
+		}
+
+		writeNeedsGraph(&b, d.releases)
+	}
+
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
+
+// writeNeedsGraph writes the Mermaid flowchart of the needs of the releases, from the needed releases to the ones needing them,
+// or nothing when no release has needs
+func writeNeedsGraph(b *strings.Builder, releases []state.ReleaseSpec) {
+	var edges [][2]string
+
+	for _, r := range releases {
+		for _, need := range r.Needs {
+			from := need
+			for i := range releases {
+				if needMatches(&releases[i], need) {
+					from = state.ReleaseToID(&releases[i])
+					break
+				}
+			}
+			edges = append(edges, [2]string{from, state.ReleaseToID(&r)})
+		}
+	}
+
+	if len(edges) == 0 {
+		return
+	}
+
+	nodes := map[string]string{}
+	node := func(id string) string {
+		n, ok := nodes[id]
+		if !ok {
+			n = fmt.Sprintf("r%d", len(nodes))
+			nodes[id] = n
+			fmt.Fprintf(b, "  %s[\"%s\"]\n", n, strings.ReplaceAll(id, `"`, "#quot;"))
+		}
+		return n
+	}
+
+	b.WriteString("\n### Needs\n\n```mermaid\ngraph LR\n")
+
+	for _, e := range edges {
+		from, to := node(e[0]), node(e[1])
+		fmt.Fprintf(b, "  %s --> %s\n", from, to)
+	}
+
+	b.WriteString("```\n")
+}
+
+// needMatches returns true if the need refers to the release, by `name`, `namespace/name` or `kubeContext/namespace/name`
+func needMatches(r *state.ReleaseSpec, need string) bool {
+	return need == state.ReleaseToID(r) || need == r.Namespace+"/"+r.Name || (r.Namespace == "" && need == r.Name)
+}
+
+func codeList(items []string) string {
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		quoted[i] = "`" + item + "`"
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// markdownCell escapes the pipes in the text to be put in a Markdown table cell
+func markdownCell(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
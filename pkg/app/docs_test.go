@@ -0,0 +1,61 @@
+package app
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/state"
+)
+
+func TestWriteDocs(t *testing.T) {
+	docs := []stateDoc{
+		{
+			file:         "helmfile.yaml",
+			environments: []string{"default", "prod"},
+			subHelmfiles: []string{"apps/helmfile.yaml"},
+		},
+		{
+			file: "apps/helmfile.yaml",
+			releases: []state.ReleaseSpec{
+				{Name: "db", Namespace: "data", Chart: "bitnami/postgresql", Version: "12.1.0", Labels: map[string]string{"tier": "data", "app": "db"}},
+				{Name: "web", Namespace: "web", Chart: "./charts/web", Needs: []string{"data/db", "cache"}, Labels: map[string]string{"tier": "a|b"}},
+			},
+		},
+	}
+
+	var b strings.Builder
+	require.NoError(t, writeDocs(&b, "prod", docs))
+
+	require.Equal(t, "# Desired state\n"+`
+Environment: `+"`prod`"+`
+
+## helmfile.yaml
+
+Environments: `+"`default`, `prod`"+`
+
+Sub-helmfiles:
+
+- `+"`apps/helmfile.yaml`"+`
+
+## apps/helmfile.yaml
+
+### Releases
+
+| Release | Namespace | Kube context | Chart | Version | Installed | Labels | Needs |
+|---|---|---|---|---|---|---|---|
+| db | data |  | bitnami/postgresql | 12.1.0 | yes | app=db, tier=data |  |
+| web | web |  | ./charts/web |  | yes | tier=a\|b | data/db, cache |
+
+### Needs
+
+`+"```mermaid"+`
+graph LR
+  r0["data/db"]
+  r1["web/web"]
+  r0 --> r1
+  r2["cache"]
+  r2 --> r1
+`+"```\n", b.String())
+}
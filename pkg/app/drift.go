@@ -0,0 +1,116 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// driftWebhookTimeout is the timeout of posting a drift report to the webhook
+const driftWebhookTimeout = 10 * time.Second
+
+// DriftReport is the drifts of the releases `helmfile watch` posts to the webhook as JSON
+type DriftReport struct {
+	// Releases are the releases whose live states differ from the desired ones, which are empty when the drifts are resolved
+	Releases []ChangedRelease `json:"releases"`
+	// DetectedAt is the time the drifts are detected
+	DetectedAt time.Time `json:"detectedAt"`
+}
+
+// driftDetector reports the drifts of the releases detected by the diffs `helmfile watch` runs on all the selected releases.
+// The drifts are reported only when they differ from the ones reported last, so that the same drifts aren't reported over and over.
+type driftDetector struct {
+	// webhook is the URL the drift reports are posted to, if any
+	webhook string
+	// exitOnDrift stops watching with the exit code 2 once a drift is detected
+	exitOnDrift bool
+
+	client *http.Client
+	now    func() time.Time
+
+	// reported is the key of the drifts reported last
+	reported string
+}
+
+func newDriftDetector(c WatchConfigProvider) *driftDetector {
+	if c.DriftInterval() <= 0 {
+		return nil
+	}
+
+	return &driftDetector{
+		webhook:     c.DriftWebhook(),
+		exitOnDrift: c.ExitOnDrift(),
+		client:      &http.Client{Timeout: driftWebhookTimeout},
+		now:         time.Now,
+	}
+}
+
+// detect reports the drifts of the releases with changes, and returns the error with the exit code 2 to stop watching
+// when a drift is detected with exitOnDrift
+func (d *driftDetector) detect(logger *zap.SugaredLogger, changed []ChangedRelease) error {
+	key := driftKey(changed)
+
+	if key != d.reported {
+		if len(changed) > 0 {
+			ids := make([]string, 0, len(changed))
+			for _, r := range changed {
+				ids = append(ids, fmt.Sprintf("%s (%s)", r.ID, r.Change))
+			}
+			logger.Warnf("Drift detected in %d releases: %s", len(changed), strings.Join(ids, ", "))
+		} else {
+			logger.Infof("The drifts are resolved")
+		}
+
+		if d.webhook != "" {
+			if err := d.post(DriftReport{Releases: changed, DetectedAt: d.now()}); err != nil {
+				logger.Errorf("Failed to post the drift report to the webhook: %v", err)
+			}
+		}
+
+		d.reported = key
+	}
+
+	if d.exitOnDrift && len(changed) > 0 {
+		code := 2
+		return &Error{msg: "Identified drifts", code: &code}
+	}
+
+	return nil
+}
+
+func (d *driftDetector) post(report DriftReport) error {
+	if report.Releases == nil {
+		report.Releases = []ChangedRelease{}
+	}
+
+	bs, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	resp, err := d.client.Post(d.webhook, "application/json", bytes.NewReader(bs))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return nil
+}
+
+// driftKey returns the key identifying the drifts, which is empty when there are no drifts
+func driftKey(changed []ChangedRelease) string {
+	keys := make([]string, 0, len(changed))
+	for _, r := range changed {
+		keys = append(keys, r.ID+"="+r.Change)
+	}
+	return strings.Join(keys, ",")
+}
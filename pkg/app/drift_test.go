@@ -0,0 +1,84 @@
+package app
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/helmexec"
+)
+
+func TestDriftDetector_detect(t *testing.T) {
+	var reports []DriftReport
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		var report DriftReport
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&report))
+		reports = append(reports, report)
+	}))
+	defer server.Close()
+
+	detectedAt := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	d := &driftDetector{
+		webhook: server.URL,
+		client:  server.Client(),
+		now:     func() time.Time { return detectedAt },
+	}
+
+	logger := helmexec.NewLogger(io.Discard, "debug")
+
+	web := ChangedRelease{ID: "default/web", Name: "web", Namespace: "default", Chart: "charts/web", Change: releaseChangeUpdated}
+	db := ChangedRelease{ID: "default/db", Name: "db", Namespace: "default", Chart: "charts/db", Change: releaseChangeUpdated}
+
+	require.NoError(t, d.detect(logger, nil))
+	require.Empty(t, reports, "no drifts should be reported before any drift")
+
+	require.NoError(t, d.detect(logger, []ChangedRelease{web}))
+	require.NoError(t, d.detect(logger, []ChangedRelease{web}))
+	require.NoError(t, d.detect(logger, []ChangedRelease{db, web}))
+	require.NoError(t, d.detect(logger, nil))
+
+	require.Equal(t, []DriftReport{
+		{Releases: []ChangedRelease{web}, DetectedAt: detectedAt},
+		{Releases: []ChangedRelease{db, web}, DetectedAt: detectedAt},
+		{Releases: []ChangedRelease{}, DetectedAt: detectedAt},
+	}, reports)
+
+	d.exitOnDrift = true
+
+	err := d.detect(logger, []ChangedRelease{db})
+	require.EqualError(t, err, "Identified drifts")
+	require.Equal(t, 2, err.(*Error).Code())
+}
+
+func TestApp_runWatched_drift(t *testing.T) {
+	code := 2
+
+	a := &App{Logger: helmexec.NewLogger(io.Discard, "debug"), Selectors: []string{"tier=backend"}}
+
+	web := ChangedRelease{ID: "default/web", Name: "web", Change: releaseChangeUpdated}
+
+	var selectors [][]string
+
+	run := func() error {
+		selectors = append(selectors, a.Selectors)
+		a.onDiffed([]ChangedRelease{web})
+		return &Error{msg: "Identified at least one change", code: &code}
+	}
+
+	d := &driftDetector{exitOnDrift: true, now: time.Now}
+
+	require.NoError(t, a.runWatched(run, []string{"name=web"}, d), "the diffs of the releases affected by the changed files shouldn't be reported as drifts")
+	require.EqualError(t, a.runWatched(run, nil, d), "Identified drifts")
+	require.NoError(t, a.runWatched(run, nil, nil))
+	require.Equal(t, [][]string{{"name=web"}, {"tier=backend"}, {"tier=backend"}}, selectors)
+	require.Nil(t, a.onDiffed)
+}
@@ -0,0 +1,81 @@
+package app
+
+import (
+	gocontext "context"
+
+	"github.com/helmfile/helmfile/pkg/state"
+)
+
+// The operations taking contexts are for the programs embedding helmfile, like controllers and operators.
+// Each of them stops once the context is done, killing the helm commands running along with their child processes,
+// and returns the error of the context. The operations running at the same time on an App have their own contexts.
+
+// DiffContext is Diff stopping once ctx is done
+func (a *App) DiffContext(ctx gocontext.Context, c DiffConfigProvider) error {
+	return withContext(ctx, func() error {
+		return a.diffContext(ctx, c)
+	})
+}
+
+// SyncContext is Sync stopping once ctx is done
+func (a *App) SyncContext(ctx gocontext.Context, c SyncConfigProvider) error {
+	return withContext(ctx, func() error {
+		return a.syncContext(ctx, c)
+	})
+}
+
+// ApplyContext is Apply stopping once ctx is done
+func (a *App) ApplyContext(ctx gocontext.Context, c ApplyConfigProvider) error {
+	return withContext(ctx, func() error {
+		return a.applyContext(ctx, c)
+	})
+}
+
+// TemplateContext is Template stopping once ctx is done
+func (a *App) TemplateContext(ctx gocontext.Context, c TemplateConfigProvider) error {
+	return withContext(ctx, func() error {
+		return a.templateContext(ctx, c)
+	})
+}
+
+// LoadStates loads the state files with the releases selected by the selectors, and returns the states with their templates rendered,
+// without running any helm command other than detecting the version of helm.
+// The releases of each state are the selected ones. It stops once ctx is done.
+func (a *App) LoadStates(ctx gocontext.Context) ([]*state.HelmState, error) {
+	var states []*state.HelmState
+
+	err := withContext(ctx, func() error {
+		return a.forEachState(&operation{ctx: ctx}, func(run *Run) (bool, []error) {
+			states = append(states, run.state)
+			return true, nil
+		}, false, SetFilter(true))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return states, nil
+}
+
+// withContext runs the operation stopping once ctx is done,
+// returning the error of the context instead of the errors of the commands killed
+func withContext(ctx gocontext.Context, f func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	err := f()
+	if ctxErr := ctx.Err(); err != nil && ctxErr != nil {
+		return ctxErr
+	}
+
+	return err
+}
+
+// runContext returns the context of the operations not taking contexts, which is never done unless Options.Context sets it
+func (a *App) runContext() gocontext.Context {
+	if a.ctx == nil {
+		return gocontext.Background()
+	}
+	return a.ctx
+}
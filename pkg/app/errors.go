@@ -3,6 +3,8 @@ package app
 import (
 	"fmt"
 	"strings"
+
+	"github.com/helmfile/helmfile/pkg/errors"
 )
 
 type NoMatchingHelmfileError struct {
@@ -17,3 +19,7 @@ func (e *NoMatchingHelmfileError) Error() string {
 		e.env,
 	)
 }
+
+func (e *NoMatchingHelmfileError) Describe() errors.Detail {
+	return errors.Detail{Code: errors.CodeNoMatchingRelease}
+}
@@ -0,0 +1,294 @@
+package app
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/helmfile/helmfile/pkg/remote"
+	"github.com/helmfile/helmfile/pkg/state"
+)
+
+// externalNeeds orders the helmfiles of a run by the needs of their releases on the releases of the other helmfiles,
+// so that the helmfile having the needed releases is processed before the one needing them, and fails when it can't be.
+type externalNeeds struct {
+	mu sync.Mutex
+
+	// processed is the selected releases of the states processed, by the absolute paths to the state files
+	processed map[string][]state.ReleaseSpec
+	// defined is all the releases of the states processed, by the absolute paths to the state files
+	defined map[string][]state.ReleaseSpec
+	// inProgress is the absolute paths to the state files being processed, whose releases aren't processed yet
+	inProgress map[string]bool
+	// names is the absolute paths to the state files by the names given in the `helmfiles` of their parents
+	names map[string]string
+	// pending is the sub-helmfiles to be processed later in the run
+	pending []*pendingHelmfile
+}
+
+// pendingHelmfile is a sub-helmfile to be processed later in the run, which is processed earlier when a release needs its releases
+type pendingHelmfile struct {
+	// path is the absolute path, or the glob pattern, of the sub-helmfile
+	path string
+	name string
+
+	visit func() error
+	err   error
+	done  bool
+}
+
+// newExternalNeeds returns the external needs of the command, which is nil for the commands not installing the releases
+func newExternalNeeds(command string) *externalNeeds {
+	switch command {
+	case "sync", "apply":
+	default:
+		return nil
+	}
+
+	return &externalNeeds{
+		processed:  map[string][]state.ReleaseSpec{},
+		defined:    map[string][]state.ReleaseSpec{},
+		inProgress: map[string]bool{},
+		names:      map[string]string{},
+	}
+}
+
+// schedule registers the sub-helmfile to be processed later with the visit function,
+// and returns the function processing it, which returns the result of the earlier one when it's processed for the needs of another helmfile
+func (n *externalNeeds) schedule(path, name string, visit func() error) func() error {
+	if n == nil {
+		return visit
+	}
+
+	p := &pendingHelmfile{path: path, name: name, visit: visit}
+
+	n.mu.Lock()
+	n.pending = append(n.pending, p)
+	n.mu.Unlock()
+
+	return func() error {
+		if !n.claim(p) {
+			return p.err
+		}
+		p.err = p.visit()
+		return p.err
+	}
+}
+
+func (n *externalNeeds) claim(p *pendingHelmfile) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if p.done {
+		return false
+	}
+	p.done = true
+
+	return true
+}
+
+// start marks the state file being processed, naming it after the entry of `helmfiles` including it, if any
+func (n *externalNeeds) start(path, name string) {
+	if n == nil {
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.inProgress[path] = true
+	if name != "" {
+		n.names[name] = path
+	}
+}
+
+// finish records the releases of the state file processed
+func (n *externalNeeds) finish(path string, st *state.HelmState) {
+	if n == nil {
+		return
+	}
+
+	selected, err := st.GetSelectedReleases(false)
+	if err != nil {
+		selected = st.Releases
+	}
+
+	var desired []state.ReleaseSpec
+	for _, r := range selected {
+		if r.Desired() {
+			desired = append(desired, r)
+		}
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	delete(n.inProgress, path)
+	n.processed[path] = desired
+	n.defined[path] = st.Releases
+}
+
+// resolve returns the absolute path to the helmfile of the need of the release of the state file in the directory
+func (n *externalNeeds) resolve(dir string, need state.ExternalNeed) string {
+	if !need.IsName() {
+		return filepath.Clean(filepath.Join(dir, need.Helmfile))
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if path, ok := n.names[need.Helmfile]; ok {
+		return path
+	}
+
+	for _, p := range n.pending {
+		if p.name == need.Helmfile {
+			return p.path
+		}
+	}
+
+	return need.Helmfile
+}
+
+// takePending returns the pending sub-helmfile matching the path to the state file, marking it processed, or nil if there's none
+func (n *externalNeeds) takePending(path, name string) *pendingHelmfile {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for _, p := range n.pending {
+		if p.done {
+			continue
+		}
+
+		matched := p.path == path || filepath.Dir(path) == p.path || (name != "" && p.name == name)
+		if !matched {
+			matched, _ = filepath.Match(p.path, path)
+		}
+
+		if matched {
+			p.done = true
+			return p
+		}
+	}
+
+	return nil
+}
+
+// prepare processes the pending helmfiles having the releases the selected releases of the state file in the directory need,
+// and fails when any of the needed releases can't be processed before them.
+// It returns the absolute paths to the needed helmfiles, which may be the directories of the state files.
+func (n *externalNeeds) prepare(logger *zap.SugaredLogger, dir, file string, st *state.HelmState) ([]string, error) {
+	if n == nil {
+		return nil, nil
+	}
+
+	releases, err := st.GetSelectedReleases(false)
+	if err != nil {
+		return nil, err
+	}
+
+	var needed []string
+
+	for _, r := range releases {
+		if !r.Desired() {
+			continue
+		}
+
+		for _, need := range r.ExternalNeeds() {
+			path := n.resolve(dir, need)
+
+			if p := n.takePending(path, nameOf(need)); p != nil {
+				logger.Debugf("processing %s before %s, as release %q needs %q of it", p.path, file, r.Name, need.Release)
+				p.err = p.visit()
+				if _, ok := p.err.(*NoMatchingHelmfileError); p.err != nil && !ok {
+					return nil, p.err
+				}
+				// The name of the helmfile is resolved once it's processed
+				path = n.resolve(dir, need)
+			}
+
+			if err := n.check(logger, path, file, r, need); err != nil {
+				return nil, err
+			}
+
+			needed = append(needed, path)
+		}
+	}
+
+	return needed, nil
+}
+
+// scheduleSubHelmfile returns the function visiting the sub-helmfile of the state file in the directory,
+// which is visited earlier when the releases of the other helmfiles need its releases
+func (a *App) scheduleSubHelmfile(op *operation, dir string, m state.SubHelmfileSpec, visit func() error) func() error {
+	if op.externalNeeds == nil || remote.IsRemote(m.Path) {
+		return visit
+	}
+
+	path := m.Path
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+
+	return op.externalNeeds.schedule(filepath.Clean(path), m.Name, func() error {
+		return a.within(dir, visit)
+	})
+}
+
+func nameOf(need state.ExternalNeed) string {
+	if need.IsName() {
+		return need.Helmfile
+	}
+	return ""
+}
+
+// check fails when the needed release isn't processed before the release needing it.
+// The path may be the directory of the state files, like `helmfile.d`, in which case the release can be in any of them.
+func (n *externalNeeds) check(logger *zap.SugaredLogger, path, file string, r state.ReleaseSpec, need state.ExternalNeed) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for p := range n.inProgress {
+		if p == path || filepath.Dir(p) == path {
+			return fmt.Errorf("release %q of %s needs %q of %s, which is processed after it. "+
+				"The releases of a helmfile are processed after the ones of its sub-helmfiles", r.Name, file, need.Release, need.Helmfile)
+		}
+	}
+
+	var (
+		found   bool
+		defined bool
+	)
+
+	for p, releases := range n.defined {
+		if p != path && filepath.Dir(p) != path {
+			continue
+		}
+		found = true
+
+		for i := range n.processed[p] {
+			if need.Matches(&n.processed[p][i]) {
+				return nil
+			}
+		}
+
+		for i := range releases {
+			if need.Matches(&releases[i]) {
+				defined = true
+			}
+		}
+	}
+
+	switch {
+	case !found:
+		logger.Warnf("release %q of %s needs %q of %s, which isn't processed in this run. Assuming it's already installed", r.Name, file, need.Release, need.Helmfile)
+	case defined:
+		logger.Warnf("release %q of %s needs %q of %s, which isn't selected in this run. Assuming it's already installed", r.Name, file, need.Release, need.Helmfile)
+	default:
+		return fmt.Errorf("release %q of %s needs an undefined release %q of %s. Perhaps you made a typo in \"needs\"?", r.Name, file, need.Release, need.Helmfile)
+	}
+
+	return nil
+}
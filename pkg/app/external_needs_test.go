@@ -0,0 +1,86 @@
+package app
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/helmexec"
+	"github.com/helmfile/helmfile/pkg/state"
+)
+
+func newExternalNeedsTestState(releases ...state.ReleaseSpec) *state.HelmState {
+	st := &state.HelmState{}
+	st.RenderedValues = map[string]interface{}{}
+	st.Releases = releases
+	return st
+}
+
+func TestExternalNeeds(t *testing.T) {
+	require.Nil(t, newExternalNeeds("diff"))
+
+	logger := helmexec.NewLogger(io.Discard, "debug")
+
+	n := newExternalNeeds("sync")
+
+	platform := newExternalNeedsTestState(state.ReleaseSpec{Name: "ingress-nginx", Namespace: "kube-system"})
+
+	var visited []string
+
+	visitPlatform := n.schedule("/infra/platform/helmfile.yaml", "platform", func() error {
+		visited = append(visited, "platform")
+		n.start("/infra/platform/helmfile.yaml", "platform")
+		n.finish("/infra/platform/helmfile.yaml", platform)
+		return nil
+	})
+
+	root := "/infra/helmfile.yaml"
+	n.start(root, "")
+
+	apps := newExternalNeedsTestState(state.ReleaseSpec{
+		Name:      "app",
+		Namespace: "default",
+		Needs:     state.Needs{"default/db", "../platform/helmfile.yaml//kube-system/ingress-nginx"},
+	})
+
+	n.start("/infra/apps/helmfile.yaml", "apps")
+	needed, err := n.prepare(logger, "/infra/apps", "helmfile.yaml", apps)
+	require.NoError(t, err)
+	require.Equal(t, []string{"/infra/platform/helmfile.yaml"}, needed)
+	n.finish("/infra/apps/helmfile.yaml", apps)
+
+	require.Equal(t, []string{"platform"}, visited)
+
+	// The sub-helmfile processed for the needs isn't processed again
+	require.NoError(t, visitPlatform())
+	require.Equal(t, []string{"platform"}, visited)
+
+	tests := []struct {
+		need string
+		err  string
+	}{
+		{need: "helmfile:platform//ingress-nginx"},
+		{need: "helmfile:apps//default/app"},
+		{need: "../other/helmfile.yaml//db"},
+		{
+			need: "helmfile:platform//default/ingress-nginx",
+			err:  `release "web" of helmfile.yaml needs an undefined release "default/ingress-nginx" of platform. Perhaps you made a typo in "needs"?`,
+		},
+		{
+			need: "../helmfile.yaml//web",
+			err:  `release "web" of helmfile.yaml needs "web" of ../helmfile.yaml, which is processed after it. The releases of a helmfile are processed after the ones of its sub-helmfiles`,
+		},
+	}
+
+	for _, tt := range tests {
+		st := newExternalNeedsTestState(state.ReleaseSpec{Name: "web", Needs: state.Needs{tt.need}})
+
+		_, err := n.prepare(logger, "/infra/web", "helmfile.yaml", st)
+		if tt.err == "" {
+			require.NoError(t, err, tt.need)
+		} else {
+			require.EqualError(t, err, tt.err, tt.need)
+		}
+	}
+}
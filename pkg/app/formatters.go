@@ -3,31 +3,206 @@ package app
 import (
 	"encoding/json"
 	"fmt"
+	"io"
+	"strings"
+	"time"
 
 	"github.com/gosuri/uitable"
+	"k8s.io/apimachinery/pkg/util/duration"
+
+	"github.com/helmfile/helmfile/pkg/state"
+	"github.com/helmfile/helmfile/pkg/yaml"
 )
 
-func FormatAsTable(releases []*HelmRelease) error {
+func FormatAsTable(w io.Writer, releases []*HelmRelease) error {
+	return formatTable(w, []interface{}{"NAME", "NAMESPACE", "ENABLED", "INSTALLED", "LABELS", "CHART", "VERSION"}, releases, func(r *HelmRelease) []interface{} {
+		return []interface{}{r.Name, r.Namespace, fmt.Sprintf("%t", r.Enabled), fmt.Sprintf("%t", r.Installed), r.Labels, r.Chart, r.Version}
+	})
+}
+
+// FormatAsJson writes the items, like the releases or the revisions of the history, as a JSON array,
+// which is empty rather than null when there are no items
+func FormatAsJson[T any](w io.Writer, items []T) error {
+	if items == nil {
+		items = []T{}
+	}
+
+	return formatJson(w, items)
+}
+
+func FormatAsYaml(w io.Writer, releases []*HelmRelease) error {
+	if releases == nil {
+		releases = []*HelmRelease{}
+	}
+
+	output, err := yaml.Marshal(releases)
+
+	if err != nil {
+		return fmt.Errorf("error generating yaml: %v", err)
+	}
+
+	_, err = fmt.Fprint(w, string(output))
+
+	return err
+}
+
+// formatTable writes the header and the row returned by the function for each of the items as a table
+func formatTable[T any](w io.Writer, header []interface{}, items []T, row func(T) []interface{}) error {
 	table := uitable.New()
-	table.AddRow("NAME", "NAMESPACE", "ENABLED", "INSTALLED", "LABELS", "CHART", "VERSION")
+	table.AddRow(header...)
 
-	for _, r := range releases {
-		table.AddRow(r.Name, r.Namespace, fmt.Sprintf("%t", r.Enabled), fmt.Sprintf("%t", r.Installed), r.Labels, r.Chart, r.Version)
+	for _, item := range items {
+		table.AddRow(row(item)...)
 	}
 
-	fmt.Println(table.String())
+	_, err := fmt.Fprintln(w, table.String())
 
-	return nil
+	return err
 }
 
-func FormatAsJson(releases []*HelmRelease) error {
-	output, err := json.Marshal(releases)
+func formatJson(w io.Writer, v interface{}) error {
+	output, err := json.Marshal(v)
 
 	if err != nil {
 		return fmt.Errorf("error generating json: %v", err)
 	}
 
-	fmt.Println(string(output))
+	_, err = fmt.Fprintln(w, string(output))
+
+	return err
+}
+
+func FormatHistoryAsTable(w io.Writer, revisions []state.ReleaseRevision, now time.Time) error {
+	return formatTable(w, []interface{}{"RELEASE", "REVISION", "AGE", "STATUS", "CHART", "APP VERSION", "DESCRIPTION"}, revisions, func(r state.ReleaseRevision) []interface{} {
+		return []interface{}{r.ID, r.Revision, duration.HumanDuration(now.Sub(r.Updated)), r.Status, r.Chart, r.AppVersion, r.Description}
+	})
+}
+
+func FormatStatusesAsTable(w io.Writer, summaries []state.ReleaseStatusSummary, now time.Time) error {
+	return formatTable(w, []interface{}{"RELEASE", "REVISION", "STATUS", "HEALTH", "CHART", "APP VERSION", "LAST DEPLOYED"}, summaries, func(s state.ReleaseStatusSummary) []interface{} {
+		revision, lastDeployed := "-", "-"
+		if s.Revision > 0 {
+			revision = fmt.Sprintf("%d", s.Revision)
+		}
+		if s.LastDeployed != nil {
+			lastDeployed = duration.HumanDuration(now.Sub(*s.LastDeployed)) + " ago"
+		}
+		return []interface{}{s.ID, revision, s.Status, s.Health, s.Chart, s.AppVersion, lastDeployed}
+	})
+}
+
+func FormatBumpsAsTable(w io.Writer, bumps []state.VersionBump) error {
+	return formatTable(w, []interface{}{"RELEASE", "CHART", "VERSION", "LATEST", "FILE"}, bumps, func(b state.VersionBump) []interface{} {
+		file := b.File
+		if !b.Updated {
+			file = "(update manually)"
+		}
+		return []interface{}{b.ID, b.Chart, b.Current, b.Latest, file}
+	})
+}
+
+func FormatChartPackagesAsTable(w io.Writer, packages []state.ChartPackage) error {
+	return formatTable(w, []interface{}{"RELEASE", "CHART", "VERSION", "PATH"}, packages, func(p state.ChartPackage) []interface{} {
+		return []interface{}{p.ID, p.Chart, p.Version, p.Path}
+	})
+}
+
+func FormatChartPushesAsTable(w io.Writer, pushes []state.ChartPush) error {
+	return formatTable(w, []interface{}{"RELEASE", "CHART", "PUSHED", "VERSION", "REWRITTEN"}, pushes, func(p state.ChartPush) []interface{} {
+		return []interface{}{p.ID, p.Chart, p.Ref, p.Version, p.Rewritten}
+	})
+}
+
+// TestReport is the report of `helmfile test --output json`
+type TestReport struct {
+	Results []TestReportResult `json:"results"`
+	Passed  int                `json:"passed"`
+	Failed  int                `json:"failed"`
+	Skipped int                `json:"skipped"`
+}
+
+// TestReportResult is the result of the test of a release in the report
+type TestReportResult struct {
+	state.TestResult
+	// Duration is the time `helm test` took, like `12.3s`, which is empty for the skipped releases
+	Duration string `json:"duration,omitempty"`
+}
+
+func FormatTestResultsAsJson(w io.Writer, results []state.TestResult) error {
+	report := TestReport{Results: []TestReportResult{}}
+
+	for _, r := range results {
+		switch r.Result {
+		case state.TestResultPassed:
+			report.Passed++
+		case state.TestResultFailed:
+			report.Failed++
+		case state.TestResultSkipped:
+			report.Skipped++
+		}
+		report.Results = append(report.Results, TestReportResult{TestResult: r, Duration: formatTestDuration(r)})
+	}
+
+	return formatJson(w, report)
+}
+
+// formatTestDuration returns the duration of the release test rounded to 0.1s, or an empty string for the skipped releases
+func formatTestDuration(r state.TestResult) string {
+	if r.Result == state.TestResultSkipped {
+		return ""
+	}
+	return r.Duration.Round(100 * time.Millisecond).String()
+}
+
+func FormatAffectedAsTable(w io.Writer, affected []AffectedRelease) error {
+	return formatTable(w, []interface{}{"RELEASE", "DEPTH", "VIA", "HELMFILE"}, affected, func(r AffectedRelease) []interface{} {
+		return []interface{}{r.ID, r.Depth, r.Via, r.Helmfile}
+	})
+}
+
+func FormatPreflightAsTable(w io.Writer, checks []state.PreflightCheck) error {
+	return formatTable(w, []interface{}{"KUBECONTEXT", "NAMESPACE", "CHECK", "RESULT", "MESSAGE"}, checks, func(c state.PreflightCheck) []interface{} {
+		result := "ok"
+		if !c.OK {
+			result = "failed"
+		}
+		return []interface{}{c.KubeContext, c.Namespace, c.Check, result, c.Message}
+	})
+}
+
+func FormatViolationsAsTable(w io.Writer, violations []state.Violation) error {
+	return formatTable(w, []interface{}{"RELEASE", "NAMESPACE", "KUBECONTEXT", "CHECK", "RESOURCE", "SEVERITY", "MESSAGE"}, violations, func(v state.Violation) []interface{} {
+		var resource string
+		if v.Kind != "" {
+			resource = v.Kind + "/" + v.Name
+		}
+		severity := "error"
+		if v.Warning {
+			severity = "warning"
+		}
+		return []interface{}{v.Release, v.Namespace, v.KubeContext, v.Check, resource, severity, v.Message}
+	})
+}
+
+func FormatInputChangedAsTable(w io.Writer, releases []InputChangedRelease) error {
+	return formatTable(w, []interface{}{"RELEASE", "CHANGED", "HELMFILE"}, releases, func(r InputChangedRelease) []interface{} {
+		return []interface{}{r.ID, strings.Join(r.Changed, ","), r.Helmfile}
+	})
+}
+
+// FormatSelectors writes the selectors one per line, omitting the duplicates, to be read with --selector-file
+func FormatSelectors(w io.Writer, selectors []string) error {
+	seen := map[string]bool{}
+	for _, s := range selectors {
+		if seen[s] {
+			continue
+		}
+		seen[s] = true
+
+		if _, err := fmt.Fprintln(w, s); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
@@ -1,9 +1,12 @@
 package app
 
 import (
+	"bytes"
 	"os"
 	"testing"
+	"time"
 
+	"github.com/helmfile/helmfile/pkg/state"
 	"github.com/helmfile/helmfile/pkg/testutil"
 )
 
@@ -37,7 +40,7 @@ func TestFormatAsTable(t *testing.T) {
 	}
 
 	result := testutil.CaptureStdout(func() {
-		FormatAsTable(h)
+		FormatAsTable(os.Stdout, h)
 	})
 	if result != string(expectd) {
 		t.Errorf("FormatAsTable() = %v, want %v", result, string(expectd))
@@ -71,10 +74,98 @@ func TestFormatAsJson(t *testing.T) {
 		t.Errorf("error reading %s: %v", output, err)
 	}
 	result := testutil.CaptureStdout(func() {
-		FormatAsJson(h)
+		FormatAsJson(os.Stdout, h)
 	})
 
 	if result != string(expectd) {
 		t.Errorf("FormatAsJson() = %v, want %v", result, string(expectd))
 	}
 }
+
+func TestFormatHistoryAsTable(t *testing.T) {
+	now := time.Date(2023, 1, 5, 0, 0, 0, 0, time.UTC)
+
+	revisions := []state.ReleaseRevision{
+		{ID: "data/db", Revision: 3, Updated: now.Add(-48 * time.Hour), Status: "failed", Chart: "db-2.0.0", AppVersion: "2.0.0", Description: "Upgrade failed"},
+		{ID: "default/web", Revision: 1, Updated: now.Add(-96 * time.Hour), Status: "superseded", Chart: "web-1.0.0", AppVersion: "1.0.0", Description: "Install complete"},
+	}
+
+	tableoutput := "testdata/formatters/historytableoutput"
+	expected, err := os.ReadFile(tableoutput)
+	if err != nil {
+		t.Errorf("error reading %s: %v", tableoutput, err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := FormatHistoryAsTable(buf, revisions, now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if buf.String() != string(expected) {
+		t.Errorf("FormatHistoryAsTable() = %q, want %q", buf.String(), string(expected))
+	}
+}
+
+func TestFormatAsYaml(t *testing.T) {
+	h := []*HelmRelease{
+		{Name: "test", Namespace: "test", Enabled: true, Installed: true, Labels: "test", Chart: "test", Version: "test"},
+		{Name: "test1", Namespace: "test2", Enabled: false, Installed: false, Labels: "test1", Chart: "test1", Version: "test1"},
+	}
+
+	output := "testdata/formatters/yamloutput"
+	expected, err := os.ReadFile(output)
+	if err != nil {
+		t.Errorf("error reading %s: %v", output, err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := FormatAsYaml(buf, h); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if buf.String() != string(expected) {
+		t.Errorf("FormatAsYaml() = %q, want %q", buf.String(), string(expected))
+	}
+}
+
+func TestFormatStatusesAsTable(t *testing.T) {
+	now := time.Date(2023, 1, 5, 0, 0, 0, 0, time.UTC)
+	lastDeployed := now.Add(-48 * time.Hour)
+
+	summaries := []state.ReleaseStatusSummary{
+		{ID: "data/db", Revision: 3, Status: "failed", Health: state.ReleaseHealthUnhealthy, Chart: "db-2.0.0", AppVersion: "2.0.0", LastDeployed: &lastDeployed},
+		{ID: "default/new", Status: "not-installed", Health: state.ReleaseHealthMissing},
+	}
+
+	buf := &bytes.Buffer{}
+	if err := FormatStatusesAsTable(buf, summaries, now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := "RELEASE    \tREVISION\tSTATUS       \tHEALTH   \tCHART   \tAPP VERSION\tLAST DEPLOYED\n" +
+		"data/db    \t3       \tfailed       \tunhealthy\tdb-2.0.0\t2.0.0      \t2d ago       \n" +
+		"default/new\t-       \tnot-installed\tmissing  \t        \t           \t-            \n"
+	if buf.String() != expected {
+		t.Errorf("FormatStatusesAsTable() = %q, want %q", buf.String(), expected)
+	}
+}
+
+func TestFormatTestResultsAsJson(t *testing.T) {
+	results := []state.TestResult{
+		{ID: "app/api", Release: "api", Result: state.TestResultPassed, Duration: 12340 * time.Millisecond},
+		{ID: "app/error-prone", Release: "error-prone", Result: state.TestResultFailed, LogFile: "logs/app_error-prone.log", Duration: 3 * time.Second},
+		{ID: "app/legacy", Release: "legacy", Result: state.TestResultSkipped},
+	}
+
+	buf := &bytes.Buffer{}
+	if err := FormatTestResultsAsJson(buf, results); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := `{"results":[` +
+		`{"id":"app/api","release":"api","result":"passed","duration":"12.3s"},` +
+		`{"id":"app/error-prone","release":"error-prone","result":"failed","logFile":"logs/app_error-prone.log","duration":"3s"},` +
+		`{"id":"app/legacy","release":"legacy","result":"skipped"}` +
+		`],"passed":1,"failed":1,"skipped":1}` + "\n"
+	if buf.String() != expected {
+		t.Errorf("FormatTestResultsAsJson() = %q, want %q", buf.String(), expected)
+	}
+}
@@ -0,0 +1,340 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/helmfile/helmfile/pkg/state"
+)
+
+// GraphRelease is a node of the graph of the needs
+type GraphRelease struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Namespace   string `json:"namespace"`
+	KubeContext string `json:"kubeContext"`
+	// Helmfile is the state file the release is defined in
+	Helmfile string `json:"helmfile"`
+	// Group is the position of the group of the releases the release is installed in, from 1,
+	// or 0 when it can't be installed because of a cycle or an undefined release in its needs
+	Group int `json:"group"`
+	// Defined is false for the releases that are needed but defined in no helmfile
+	Defined bool `json:"defined"`
+}
+
+// GraphEdge is an edge of the graph of the needs, from the needed release to the release needing it
+type GraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Cycle is true for the edges within the cycles of the needs
+	Cycle bool `json:"cycle"`
+}
+
+// ReleaseGraph is the graph of the needs of the releases across all the helmfiles
+type ReleaseGraph struct {
+	Releases []GraphRelease `json:"releases"`
+	Edges    []GraphEdge    `json:"edges"`
+	// Cycles are the IDs of the releases in each cycle of the needs
+	Cycles [][]string `json:"cycles"`
+}
+
+// releaseGraph returns the graph of the needs of the releases, whose needs must be the IDs of the releases.
+// The releases are sorted by ID, and the edges by the IDs of the needed releases and then the releases needing them.
+func releaseGraph(nodes []affectedNode) ReleaseGraph {
+	graph := ReleaseGraph{
+		Releases: []GraphRelease{},
+		Edges:    []GraphEdge{},
+		Cycles:   [][]string{},
+	}
+
+	releases := map[string]*GraphRelease{}
+	needs := map[string][]string{}
+	seenEdges := map[[2]string]bool{}
+
+	for _, n := range nodes {
+		id := state.ReleaseToID(&n.release)
+		if r, ok := releases[id]; ok && r.Defined {
+			continue
+		}
+
+		releases[id] = &GraphRelease{
+			ID:          id,
+			Name:        n.release.Name,
+			Namespace:   n.release.Namespace,
+			KubeContext: n.release.KubeContext,
+			Helmfile:    n.helmfile,
+			Defined:     true,
+		}
+
+		for _, need := range n.release.Needs {
+			if seenEdges[[2]string{need, id}] {
+				continue
+			}
+			seenEdges[[2]string{need, id}] = true
+			needs[id] = append(needs[id], need)
+		}
+	}
+
+	for _, ns := range needs {
+		for _, need := range ns {
+			if _, ok := releases[need]; !ok {
+				components := strings.Split(need, "/")
+				releases[need] = &GraphRelease{ID: need, Name: components[len(components)-1]}
+			}
+		}
+	}
+
+	ids := make([]string, 0, len(releases))
+	for id := range releases {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, ns := range needs {
+		sort.Strings(ns)
+	}
+
+	// cycles are the strongly connected components of more than one release, or of a release needing itself
+	inCycle := map[string]int{}
+	for _, c := range stronglyConnectedComponents(ids, needs) {
+		if len(c) == 1 && !seenEdges[[2]string{c[0], c[0]}] {
+			continue
+		}
+		sort.Strings(c)
+		for _, id := range c {
+			inCycle[id] = len(graph.Cycles) + 1
+		}
+		graph.Cycles = append(graph.Cycles, c)
+	}
+
+	sort.Slice(graph.Cycles, func(i, j int) bool {
+		return graph.Cycles[i][0] < graph.Cycles[j][0]
+	})
+
+	// groups are computed from the needed releases, -1 meaning that the release is being visited
+	groups := map[string]int{}
+	var group func(id string) int
+	group = func(id string) int {
+		if g, ok := groups[id]; ok {
+			if g < 0 {
+				return 0
+			}
+			return g
+		}
+
+		if !releases[id].Defined || inCycle[id] > 0 {
+			groups[id] = 0
+			return 0
+		}
+
+		groups[id] = -1
+
+		g := 1
+		for _, need := range needs[id] {
+			ng := group(need)
+			if ng == 0 {
+				g = 0
+				break
+			}
+			if ng+1 > g {
+				g = ng + 1
+			}
+		}
+
+		groups[id] = g
+		return g
+	}
+
+	for _, id := range ids {
+		r := releases[id]
+		r.Group = group(id)
+		graph.Releases = append(graph.Releases, *r)
+	}
+
+	for _, id := range ids {
+		for _, need := range needs[id] {
+			graph.Edges = append(graph.Edges, GraphEdge{
+				From:  need,
+				To:    id,
+				Cycle: inCycle[need] > 0 && inCycle[need] == inCycle[id],
+			})
+		}
+	}
+
+	sort.SliceStable(graph.Edges, func(i, j int) bool {
+		if graph.Edges[i].From != graph.Edges[j].From {
+			return graph.Edges[i].From < graph.Edges[j].From
+		}
+		return graph.Edges[i].To < graph.Edges[j].To
+	})
+
+	return graph
+}
+
+// stronglyConnectedComponents returns the strongly connected components of the graph of the ids and their needs,
+// found with Tarjan's algorithm
+func stronglyConnectedComponents(ids []string, needs map[string][]string) [][]string {
+	var (
+		index      int
+		stack      []string
+		onStack    = map[string]bool{}
+		indices    = map[string]int{}
+		lowLinks   = map[string]int{}
+		components [][]string
+	)
+
+	var connect func(id string)
+	connect = func(id string) {
+		indices[id] = index
+		lowLinks[id] = index
+		index++
+		stack = append(stack, id)
+		onStack[id] = true
+
+		for _, need := range needs[id] {
+			if _, ok := indices[need]; !ok {
+				connect(need)
+				if lowLinks[need] < lowLinks[id] {
+					lowLinks[id] = lowLinks[need]
+				}
+			} else if onStack[need] && indices[need] < lowLinks[id] {
+				lowLinks[id] = indices[need]
+			}
+		}
+
+		if lowLinks[id] != indices[id] {
+			return
+		}
+
+		var c []string
+		for {
+			n := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[n] = false
+			c = append(c, n)
+			if n == id {
+				break
+			}
+		}
+		components = append(components, c)
+	}
+
+	for _, id := range ids {
+		if _, ok := indices[id]; !ok {
+			connect(id)
+		}
+	}
+
+	return components
+}
+
+// graphLabel returns the label of the release, with the group it's installed in
+func graphLabel(r GraphRelease, newline string) string {
+	switch {
+	case !r.Defined:
+		return r.ID + newline + "undefined"
+	case r.Group == 0:
+		return r.ID + newline + "unordered"
+	}
+	return fmt.Sprintf("%s%sgroup %d", r.ID, newline, r.Group)
+}
+
+// dotQuote quotes the string as a DOT ID
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+// FormatGraphAsDot writes the graph in the DOT language of Graphviz, with the edges in the cycles colored in red
+func FormatGraphAsDot(w io.Writer, graph ReleaseGraph) error {
+	var b strings.Builder
+
+	b.WriteString("digraph helmfile {\n  rankdir=LR;\n")
+
+	for _, r := range graph.Releases {
+		attrs := "label=" + dotQuote(graphLabel(r, "\n"))
+		if !r.Defined {
+			attrs += ", style=dashed"
+		}
+		fmt.Fprintf(&b, "  %s [%s];\n", dotQuote(r.ID), attrs)
+	}
+
+	for _, e := range graph.Edges {
+		if e.Cycle {
+			fmt.Fprintf(&b, "  %s -> %s [color=red];\n", dotQuote(e.From), dotQuote(e.To))
+		} else {
+			fmt.Fprintf(&b, "  %s -> %s;\n", dotQuote(e.From), dotQuote(e.To))
+		}
+	}
+
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
+
+// FormatGraphAsMermaid writes the graph as a Mermaid flowchart, with the edges in the cycles colored in red
+func FormatGraphAsMermaid(w io.Writer, graph ReleaseGraph) error {
+	var b strings.Builder
+
+	b.WriteString("graph LR\n")
+
+	nodes := map[string]string{}
+	for i, r := range graph.Releases {
+		nodes[r.ID] = fmt.Sprintf("r%d", i)
+		fmt.Fprintf(&b, "  %s[\"%s\"]\n", nodes[r.ID], strings.ReplaceAll(graphLabel(r, "<br/>"), `"`, "#quot;"))
+	}
+
+	var cycleEdges []string
+	for i, e := range graph.Edges {
+		fmt.Fprintf(&b, "  %s --> %s\n", nodes[e.From], nodes[e.To])
+		if e.Cycle {
+			cycleEdges = append(cycleEdges, fmt.Sprintf("%d", i))
+		}
+	}
+
+	if len(cycleEdges) > 0 {
+		fmt.Fprintf(&b, "  linkStyle %s stroke:red\n", strings.Join(cycleEdges, ","))
+	}
+
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
+
+func FormatGraphAsJson(w io.Writer, graph ReleaseGraph) error {
+	return formatJson(w, graph)
+}
+
+// Graph prints the graph of the needs of the releases across all the helmfiles regardless of the selectors,
+// along with the groups they are installed in, and the cycles of the needs, which are warned about too.
+func (a *App) Graph(c GraphConfigProvider) error {
+	var nodes []affectedNode
+
+	err := a.ForEachState(func(run *Run) (bool, []error) {
+		for _, r := range run.state.Releases {
+			nodes = append(nodes, affectedNode{release: r, helmfile: run.state.FilePath})
+		}
+		return true, nil
+	}, false)
+	if err != nil {
+		return err
+	}
+
+	graph := releaseGraph(nodes)
+
+	for _, c := range graph.Cycles {
+		a.Logger.Warnf("found a cycle in the needs of the releases: %s", strings.Join(c, ", "))
+	}
+
+	switch c.Output() {
+	case "json":
+		return FormatGraphAsJson(a.Stdout(), graph)
+	case "mermaid":
+		return FormatGraphAsMermaid(a.Stdout(), graph)
+	}
+
+	return FormatGraphAsDot(a.Stdout(), graph)
+}
@@ -0,0 +1,82 @@
+package app
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/state"
+)
+
+func TestReleaseGraph(t *testing.T) {
+	nodes := []affectedNode{
+		{release: state.ReleaseSpec{Name: "cert-manager", Namespace: "infra"}, helmfile: "helmfile.yaml"},
+		{release: state.ReleaseSpec{Name: "ingress", Namespace: "infra", Needs: []string{"infra/cert-manager"}}, helmfile: "helmfile.yaml"},
+		{release: state.ReleaseSpec{Name: "web", Namespace: "apps", Needs: []string{"infra/ingress", "infra/cert-manager"}}, helmfile: "apps/helmfile.yaml"},
+		{release: state.ReleaseSpec{Name: "a", Namespace: "loop", Needs: []string{"loop/b"}}, helmfile: "loop.yaml"},
+		{release: state.ReleaseSpec{Name: "b", Namespace: "loop", Needs: []string{"loop/a"}}, helmfile: "loop.yaml"},
+		{release: state.ReleaseSpec{Name: "c", Namespace: "loop", Needs: []string{"loop/a"}}, helmfile: "loop.yaml"},
+		{release: state.ReleaseSpec{Name: "api", Namespace: "apps", Needs: []string{"apps/db"}}, helmfile: "apps/helmfile.yaml"},
+	}
+
+	graph := releaseGraph(nodes)
+
+	require.Equal(t, []GraphRelease{
+		{ID: "apps/api", Name: "api", Namespace: "apps", Helmfile: "apps/helmfile.yaml", Group: 0, Defined: true},
+		{ID: "apps/db", Name: "db", Group: 0},
+		{ID: "apps/web", Name: "web", Namespace: "apps", Helmfile: "apps/helmfile.yaml", Group: 3, Defined: true},
+		{ID: "infra/cert-manager", Name: "cert-manager", Namespace: "infra", Helmfile: "helmfile.yaml", Group: 1, Defined: true},
+		{ID: "infra/ingress", Name: "ingress", Namespace: "infra", Helmfile: "helmfile.yaml", Group: 2, Defined: true},
+		{ID: "loop/a", Name: "a", Namespace: "loop", Helmfile: "loop.yaml", Group: 0, Defined: true},
+		{ID: "loop/b", Name: "b", Namespace: "loop", Helmfile: "loop.yaml", Group: 0, Defined: true},
+		{ID: "loop/c", Name: "c", Namespace: "loop", Helmfile: "loop.yaml", Group: 0, Defined: true},
+	}, graph.Releases)
+
+	require.Equal(t, []GraphEdge{
+		{From: "apps/db", To: "apps/api"},
+		{From: "infra/cert-manager", To: "apps/web"},
+		{From: "infra/cert-manager", To: "infra/ingress"},
+		{From: "infra/ingress", To: "apps/web"},
+		{From: "loop/a", To: "loop/b", Cycle: true},
+		{From: "loop/a", To: "loop/c"},
+		{From: "loop/b", To: "loop/a", Cycle: true},
+	}, graph.Edges)
+
+	require.Equal(t, [][]string{{"loop/a", "loop/b"}}, graph.Cycles)
+}
+
+func TestFormatGraph(t *testing.T) {
+	graph := releaseGraph([]affectedNode{
+		{release: state.ReleaseSpec{Name: "cert-manager", Namespace: "infra"}, helmfile: "helmfile.yaml"},
+		{release: state.ReleaseSpec{Name: "ingress", Namespace: "infra", Needs: []string{"infra/cert-manager", "infra/ingress"}}, helmfile: "helmfile.yaml"},
+		{release: state.ReleaseSpec{Name: "web", Namespace: "apps", Needs: []string{"apps/db"}}, helmfile: "helmfile.yaml"},
+	})
+
+	var dot bytes.Buffer
+	require.NoError(t, FormatGraphAsDot(&dot, graph))
+	require.Equal(t, `digraph helmfile {
+  rankdir=LR;
+  "apps/db" [label="apps/db\nundefined", style=dashed];
+  "apps/web" [label="apps/web\nunordered"];
+  "infra/cert-manager" [label="infra/cert-manager\ngroup 1"];
+  "infra/ingress" [label="infra/ingress\nunordered"];
+  "apps/db" -> "apps/web";
+  "infra/cert-manager" -> "infra/ingress";
+  "infra/ingress" -> "infra/ingress" [color=red];
+}
+`, dot.String())
+
+	var mermaid bytes.Buffer
+	require.NoError(t, FormatGraphAsMermaid(&mermaid, graph))
+	require.Equal(t, `graph LR
+  r0["apps/db<br/>undefined"]
+  r1["apps/web<br/>unordered"]
+  r2["infra/cert-manager<br/>group 1"]
+  r3["infra/ingress<br/>unordered"]
+  r0 --> r1
+  r2 --> r3
+  r3 --> r3
+  linkStyle 2 stroke:red
+`, mermaid.String())
+}
@@ -67,6 +67,8 @@ type HelmfileInit struct {
 	configProvider InitConfigProvider
 	logger         *zap.SugaredLogger
 	runner         helmexec.Runner
+	// stdout is where the prompts are written to, which defaults to os.Stdout
+	stdout io.Writer
 }
 
 func downloadfile(filepath string, url string) error {
@@ -154,13 +156,20 @@ func (h *HelmfileInit) WhetherContinue(ask string) error {
 	if h.configProvider.Force() {
 		return nil
 	}
-	askYes := AskForConfirmation(ask)
+	askYes := askForConfirmation(h.out(), os.Stdin, ask)
 	if !askYes {
 		return &Error{msg: "cancel automatic installation, please install manually", code: &manuallyInstallCode}
 	}
 	return nil
 }
 
+func (h *HelmfileInit) out() io.Writer {
+	if h.stdout != nil {
+		return h.stdout
+	}
+	return os.Stdout
+}
+
 func (h *HelmfileInit) CheckHelmPlugins() error {
 	settings := cli.New()
 	helm := helmexec.New(h.helmBinary, false, h.logger, "", h.runner)
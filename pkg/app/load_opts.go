@@ -9,14 +9,35 @@ type LoadOpts struct {
 	Selectors   []string
 	Environment state.SubhelmfileEnvironmentSpec
 
+	// HelmfileSelected is true when the sub-helmfile being loaded, or any of the ones including it, matched the helmfile selectors,
+	// so that its own sub-helmfiles are loaded as a whole
+	HelmfileSelected bool
+
 	RetainValuesFiles bool
 
 	// CalleePath is the absolute path to the file being loaded
 	CalleePath string
 
+	// HelmfileName is the name of the sub-helmfile being loaded given in the `helmfiles` of its parent,
+	// which the `needs` of the releases of the other helmfiles refer to
+	HelmfileName string
+
 	Reverse bool
 
 	Filter bool
+
+	// Command is the helmfile command run for the states, which triggers the `pre<command>` and `post<command>` hooks of the root helmfiles
+	Command string
+
+	// StateConcurrency is the maximum number of states whose releases are processed concurrently once all the states are loaded
+	StateConcurrency int
+
+	// SkipRunHooks is true when the states are loaded only to be inspected, like the ones scanned by watch,
+	// which don't trigger the `prerun` and `postrun` hooks
+	SkipRunHooks bool
+
+	// op is the operation the states are loaded for
+	op *operation
 }
 
 func (o LoadOpts) DeepCopy() LoadOpts {
@@ -30,5 +51,7 @@ func (o LoadOpts) DeepCopy() LoadOpts {
 		panic(err)
 	}
 
+	new.op = o.op
+
 	return new
 }
@@ -1,6 +1,7 @@
 package app
 
 import (
+	gocontext "context"
 	"helm.sh/helm/v3/pkg/chart"
 
 	"github.com/helmfile/helmfile/pkg/helmexec"
@@ -22,24 +23,28 @@ func (helm *noCallHelmExec) doPanic() {
 	panic("unexpected call to helm")
 }
 
-func (helm *noCallHelmExec) TemplateRelease(name, chart string, flags ...string) error {
+func (helm *noCallHelmExec) TemplateRelease(context helmexec.HelmContext, name, chart string, flags ...string) error {
 	helm.doPanic()
 	return nil
 }
-func (helm *noCallHelmExec) ChartPull(chart string, path string, flags ...string) error {
+func (helm *noCallHelmExec) ChartPull(ctx gocontext.Context, chart string, path string, flags ...string) error {
 	helm.doPanic()
 	return nil
 }
-func (helm *noCallHelmExec) ChartExport(chart string, path string, flags ...string) error {
+func (helm *noCallHelmExec) ChartExport(ctx gocontext.Context, chart string, path string, flags ...string) error {
 	helm.doPanic()
 	return nil
 }
-func (helm *noCallHelmExec) UpdateDeps(chart string) error {
+func (helm *noCallHelmExec) ChartDigest(ctx gocontext.Context, chart string, flags ...string) (string, error) {
+	helm.doPanic()
+	return "", nil
+}
+func (helm *noCallHelmExec) UpdateDeps(ctx gocontext.Context, chart string) error {
 	helm.doPanic()
 	return nil
 }
 
-func (helm *noCallHelmExec) BuildDeps(name, chart string, flags ...string) error {
+func (helm *noCallHelmExec) BuildDeps(ctx gocontext.Context, name, chart string, flags ...string) error {
 	helm.doPanic()
 	return nil
 }
@@ -58,16 +63,15 @@ func (helm *noCallHelmExec) SetPostRenderer(postRenderer string) {
 func (helm *noCallHelmExec) GetPostRenderer() string {
 	return ""
 }
-
-func (helm *noCallHelmExec) AddRepo(name, repository, cafile, certfile, keyfile, username, password string, managed string, passCredentials string, skipTLSVerify string) error {
+func (helm *noCallHelmExec) AddRepo(ctx gocontext.Context, name, repository, cafile, certfile, keyfile, username, password string, managed string, passCredentials string, skipTLSVerify string) error {
 	helm.doPanic()
 	return nil
 }
-func (helm *noCallHelmExec) UpdateRepo() error {
+func (helm *noCallHelmExec) UpdateRepo(ctx gocontext.Context) error {
 	helm.doPanic()
 	return nil
 }
-func (helm *noCallHelmExec) RegistryLogin(name string, username string, password string) error {
+func (helm *noCallHelmExec) RegistryLogin(ctx gocontext.Context, name string, username string, password string, flags ...string) error {
 	helm.doPanic()
 	return nil
 }
@@ -83,6 +87,10 @@ func (helm *noCallHelmExec) ReleaseStatus(context helmexec.HelmContext, release
 	helm.doPanic()
 	return nil
 }
+func (helm *noCallHelmExec) Exec(context helmexec.HelmContext, args ...string) error {
+	helm.doPanic()
+	return nil
+}
 func (helm *noCallHelmExec) DeleteRelease(context helmexec.HelmContext, name string, flags ...string) error {
 	helm.doPanic()
 	return nil
@@ -101,11 +109,11 @@ func (helm *noCallHelmExec) TestRelease(context helmexec.HelmContext, name strin
 	helm.doPanic()
 	return nil
 }
-func (helm *noCallHelmExec) Fetch(chart string, flags ...string) error {
+func (helm *noCallHelmExec) Fetch(ctx gocontext.Context, chart string, flags ...string) error {
 	helm.doPanic()
 	return nil
 }
-func (helm *noCallHelmExec) Lint(name, chart string, flags ...string) error {
+func (helm *noCallHelmExec) Lint(ctx gocontext.Context, name, chart string, flags ...string) error {
 	helm.doPanic()
 	return nil
 }
@@ -124,7 +132,12 @@ func (helm *noCallHelmExec) IsVersionAtLeast(versionStr string) bool {
 	return false
 }
 
-func (helm *noCallHelmExec) ShowChart(chartPath string) (chart.Metadata, error) {
+func (helm *noCallHelmExec) ShowChart(ctx gocontext.Context, chartPath string, flags ...string) (chart.Metadata, error) {
 	helm.doPanic()
 	return chart.Metadata{}, nil
 }
+
+func (helm *noCallHelmExec) ShowValues(ctx gocontext.Context, chartPath string, flags ...string) (string, error) {
+	helm.doPanic()
+	return "", nil
+}
@@ -0,0 +1,61 @@
+package app
+
+import (
+	gocontext "context"
+
+	"github.com/helmfile/helmfile/pkg/state"
+)
+
+// operation is a run of an operation of the App, like a sync, holding what the states processed in it share.
+// The operations running at the same time on an App have their own ones, so that they don't affect each other.
+type operation struct {
+	// ctx is done on SIGINT, SIGTERM, or --run-timeout for the CLI, or by the program embedding helmfile.
+	// The operation never stops when it is nil.
+	ctx gocontext.Context
+
+	hooks    *runHooks
+	progress *runProgress
+	// externalNeeds is non-nil while running sync or apply
+	externalNeeds *externalNeeds
+	// stateTasks is non-nil while running sync or apply with the state concurrency more than 1
+	stateTasks *stateTasks
+	// kubeVersionChecker checks the kubeVersion constraints of each release once in the operation
+	kubeVersionChecker *state.KubeVersionChecker
+
+	// workDir is the working directory of the operation, where the temporary files are created
+	workDir string
+
+	// hookPlan is non-nil while the hooks are recorded instead of being run, like on `apply --dry-run`
+	hookPlan *state.HookPlan
+}
+
+// context returns the context of the operation
+func (op *operation) context() gocontext.Context {
+	if op.ctx == nil {
+		return gocontext.Background()
+	}
+	return op.ctx
+}
+
+// withOperation makes the states loaded a part of the operation
+func withOperation(op *operation) LoadOption {
+	return func(o *LoadOpts) {
+		o.op = op
+	}
+}
+
+// getOperation returns the operation the states are loaded for, or an empty one when they are loaded outside of any operation
+func (o LoadOpts) getOperation() *operation {
+	if o.op == nil {
+		return &operation{}
+	}
+	return o.op
+}
+
+// getOperation returns the operation the run is a part of, or an empty one when it is run outside of any operation
+func (r *Run) getOperation() *operation {
+	if r.op == nil {
+		return &operation{}
+	}
+	return r.op
+}
@@ -0,0 +1,196 @@
+package app
+
+import (
+	gocontext "context"
+	"io"
+	"os"
+
+	"go.uber.org/zap"
+
+	"github.com/helmfile/helmfile/pkg/envvar"
+	"github.com/helmfile/helmfile/pkg/filesystem"
+	"github.com/helmfile/helmfile/pkg/helmexec"
+	"github.com/helmfile/helmfile/pkg/state"
+)
+
+// Options is the set of settings used to construct an App.
+// It is the preferred way to configure an App when embedding helmfile as a library,
+// as it doesn't require implementing the ConfigProvider interface.
+type Options struct {
+	// HelmBinary is the path to the helm binary. Defaults to DefaultHelmBinary.
+	HelmBinary string
+	// KubeContext overrides the kube context declared in the state files.
+	KubeContext string
+	// EnableLiveOutput streams the stdout/stderr of helm commands as they run.
+	EnableLiveOutput bool
+
+	// Environment is the name of the environment to load. Defaults to "default".
+	Environment string
+	// Namespace overrides the namespace of every release.
+	Namespace string
+	// Chart overrides the chart of every release.
+	Chart string
+	// Selectors is the list of release selectors, each in the form of `key=value[,key2=value2]`.
+	Selectors []string
+	// HelmfileSelectors is the list of sub-helmfile selectors, each in the form of `key=value[,key2=value2]`,
+	// matched against the labels of the `helmfiles:` entries.
+	HelmfileSelectors []string
+	// RepositoryMirrors is the list of repository mirrors, each in the form of `FROM=TO`,
+	// taking precedence over the `repositoryMirrors` of the state files.
+	RepositoryMirrors []string
+	// CommandTimeouts is the list of timeouts of the helm commands, each like `10m` for all the commands or `upgrade=30m` for the command type.
+	CommandTimeouts []string
+	// CommandLiveOutputs is the list of whether to stream the outputs of the helm commands, each like `upgrade=true` for the command type,
+	// taking precedence over EnableLiveOutput.
+	CommandLiveOutputs []string
+	// TempDir is the directory the working directories of the runs are created in.
+	// Defaults to HELMFILE_TEMPDIR, or the system's temporary directory.
+	TempDir string
+	// KeepTempFiles keeps the working directories of the runs containing the temporary files, instead of removing them at the end of the runs.
+	KeepTempFiles bool
+	// Offline skips the repository updates and the downloads of the charts, the remote helmfiles and the remote values files,
+	// using only the vendored charts and the ones in the cache, and fails listing the ones missing from them.
+	Offline bool
+	// AllowClusterLookups allows the templates to query the cluster of KubeContext, or of the current context, with kubectlGet.
+	AllowClusterLookups bool
+	// Args is the extra args passed to every helm command.
+	Args string
+
+	// FileOrDir is the path to the state file or the directory containing state files.
+	FileOrDir string
+	// StateValuesFiles is the list of state values files overriding the environment values.
+	StateValuesFiles []string
+	// StateValuesSet is the state values overriding the environment values.
+	StateValuesSet map[string]interface{}
+
+	// Logger is the logger used by the App. Defaults to an info-level logger writing to Stderr.
+	Logger *zap.SugaredLogger
+	// Stdout is where the App writes command results like `list` tables and `diff` outputs, and the live outputs of the helm commands.
+	// Defaults to os.Stdout.
+	Stdout io.Writer
+	// Stderr is where the default logger writes to when Logger is nil, and where the outputs kept apart from the command results,
+	// like the outputs of the release tests with --output json, are written to. Defaults to os.Stderr.
+	Stderr io.Writer
+	// FileSystem is the filesystem used for reading state files. Defaults to filesystem.DefaultFileSystem().
+	FileSystem *filesystem.FileSystem
+	// Callbacks is notified of the lifecycle events of the App. Defaults to NopCallbacks.
+	Callbacks Callbacks
+	// Context stops the operations of the App once it is done, killing the helm commands running and skipping the remaining releases.
+	// The operations taking contexts, like ApplyContext, use theirs instead. Never done by default.
+	Context gocontext.Context
+}
+
+// OptionsFromConfig returns the Options that corresponds to the given ConfigProvider.
+func OptionsFromConfig(conf ConfigProvider) Options {
+	return Options{
+		HelmBinary:          conf.HelmBinary(),
+		KubeContext:         conf.KubeContext(),
+		EnableLiveOutput:    conf.EnableLiveOutput(),
+		Environment:         conf.Env(),
+		Namespace:           conf.Namespace(),
+		Chart:               conf.Chart(),
+		Selectors:           conf.Selectors(),
+		HelmfileSelectors:   conf.HelmfileSelectors(),
+		RepositoryMirrors:   conf.RepositoryMirrors(),
+		CommandTimeouts:     conf.CommandTimeouts(),
+		CommandLiveOutputs:  conf.CommandLiveOutputs(),
+		TempDir:             conf.TempDir(),
+		KeepTempFiles:       conf.KeepTempFiles() || skipsCleanup(conf),
+		Offline:             conf.Offline(),
+		AllowClusterLookups: conf.AllowClusterLookups(),
+		Args:                conf.Args(),
+		FileOrDir:           conf.FileOrDir(),
+		StateValuesFiles:    conf.StateValuesFiles(),
+		StateValuesSet:      conf.StateValuesSet(),
+		Logger:              conf.Logger(),
+		Context:             runContextOf(conf),
+	}
+}
+
+// runContextOf returns the context of the command of the config, which is done on SIGINT, SIGTERM, or --run-timeout for the CLI
+func runContextOf(conf ConfigProvider) gocontext.Context {
+	if c, ok := conf.(interface{ RunContext() gocontext.Context }); ok {
+		return c.RunContext()
+	}
+	return nil
+}
+
+// skipsCleanup returns whether the command of the config leaves the temporary files for debugging,
+// which requires the working directory containing them to be kept too
+func skipsCleanup(conf ConfigProvider) bool {
+	if c, ok := conf.(interface{ SkipCleanup() bool }); ok && c.SkipCleanup() {
+		return true
+	}
+	if c, ok := conf.(interface{ RetainValuesFiles() bool }); ok && c.RetainValuesFiles() {
+		return true
+	}
+	return false
+}
+
+// NewWithOptions creates a new App from the given Options.
+func NewWithOptions(opts Options) *App {
+	helmBinary := opts.HelmBinary
+	if helmBinary == "" {
+		helmBinary = DefaultHelmBinary
+	}
+
+	logger := opts.Logger
+	if logger == nil {
+		stderr := opts.Stderr
+		if stderr == nil {
+			stderr = os.Stderr
+		}
+		logger = helmexec.NewLogger(stderr, "info")
+	}
+
+	env := opts.Environment
+	if env == "" {
+		env = state.DefaultEnv
+	}
+
+	if opts.TempDir == "" {
+		opts.TempDir = os.Getenv(envvar.TempDir)
+	}
+
+	fs := opts.FileSystem
+	if fs == nil {
+		fs = filesystem.DefaultFileSystem()
+	}
+
+	return Init(&App{
+		OverrideKubeContext: opts.KubeContext,
+		OverrideHelmBinary:  helmBinary,
+		EnableLiveOutput:    opts.EnableLiveOutput,
+		Logger:              logger,
+		Env:                 env,
+		Namespace:           opts.Namespace,
+		Chart:               opts.Chart,
+		Selectors:           opts.Selectors,
+		Args:                opts.Args,
+		FileOrDir:           opts.FileOrDir,
+		ValuesFiles:         opts.StateValuesFiles,
+		Set:                 opts.StateValuesSet,
+		opts:                opts,
+		stdout:              opts.Stdout,
+		callbacks:           opts.Callbacks,
+		ctx:                 opts.Context,
+		fs:                  fs,
+	})
+}
+
+// Stdout returns the writer the App writes command results to.
+func (a *App) Stdout() io.Writer {
+	if a.stdout != nil {
+		return a.stdout
+	}
+	// Resolve os.Stdout lazily so that redirecting os.Stdout after the App is created takes effect.
+	return os.Stdout
+}
+
+// Stderr returns the writer the App writes the outputs kept apart from the command results to.
+func (a *App) Stderr() io.Writer {
+	if a.opts.Stderr != nil {
+		return a.opts.Stderr
+	}
+	return os.Stderr
+}
@@ -0,0 +1,114 @@
+package app
+
+import (
+	"bytes"
+	gocontext "context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/state"
+	"github.com/helmfile/helmfile/pkg/testhelper"
+)
+
+func TestNewWithOptions_Defaults(t *testing.T) {
+	a := NewWithOptions(Options{})
+
+	assert.Equal(t, DefaultHelmBinary, a.OverrideHelmBinary)
+	assert.Equal(t, state.DefaultEnv, a.Env)
+	assert.NotNil(t, a.Logger)
+	assert.NotNil(t, a.fs)
+	assert.NotNil(t, a.Stdout())
+}
+
+func TestNewWithOptions_Stdout(t *testing.T) {
+	files := map[string]string{
+		"/path/to/helmfile.yaml": `
+releases:
+- name: myrelease1
+  chart: mychart1
+`,
+	}
+
+	var out bytes.Buffer
+
+	a := NewWithOptions(Options{
+		FileOrDir:   "/path/to/helmfile.yaml",
+		KubeContext: "default",
+		Logger:      newAppTestLogger(),
+		Stdout:      &out,
+		FileSystem:  testhelper.NewTestFs(files).ToFileSystem(),
+	})
+
+	expectNoCallsToHelm(a)
+
+	err := a.ListReleases(configImpl{skipCharts: true, output: "json"})
+	require.NoError(t, err)
+
+	expected := `[{"name":"myrelease1","namespace":"","enabled":true,"installed":true,"labels":"","chart":"mychart1","version":""}]
+`
+	assert.Equal(t, expected, out.String())
+}
+
+func TestLoadStates(t *testing.T) {
+	files := map[string]string{
+		"/path/to/helmfile.yaml": `
+releases:
+- name: myrelease1
+  chart: mychart1
+  labels:
+    tier: backend
+- name: myrelease2
+  chart: mychart2
+`,
+	}
+
+	a := NewWithOptions(Options{
+		FileOrDir:   "/path/to/helmfile.yaml",
+		KubeContext: "default",
+		Selectors:   []string{"tier=backend"},
+		Logger:      newAppTestLogger(),
+		FileSystem:  testhelper.NewTestFs(files).ToFileSystem(),
+	})
+
+	expectNoCallsToHelm(a)
+
+	ctx, cancel := gocontext.WithCancel(gocontext.Background())
+	defer cancel()
+
+	states, err := a.LoadStates(ctx)
+	require.NoError(t, err)
+	require.Len(t, states, 1)
+	require.Len(t, states[0].Releases, 1)
+	assert.Equal(t, "myrelease1", states[0].Releases[0].Name)
+	// The helm commands of the states run in the context of the operation, leaving the App as is
+	assert.Equal(t, ctx, states[0].Context)
+	assert.Nil(t, a.ctx)
+}
+
+func TestApplyContext_Canceled(t *testing.T) {
+	files := map[string]string{
+		"/path/to/helmfile.yaml": `
+releases:
+- name: myrelease1
+  chart: mychart1
+`,
+	}
+
+	a := NewWithOptions(Options{
+		FileOrDir:   "/path/to/helmfile.yaml",
+		KubeContext: "default",
+		Logger:      newAppTestLogger(),
+		FileSystem:  testhelper.NewTestFs(files).ToFileSystem(),
+	})
+
+	expectNoCallsToHelm(a)
+
+	ctx, cancel := gocontext.WithCancel(gocontext.Background())
+	cancel()
+
+	err := a.ApplyContext(ctx, applyConfig{logger: newAppTestLogger()})
+	require.ErrorIs(t, err, gocontext.Canceled)
+	assert.Nil(t, a.ctx)
+}
@@ -0,0 +1,95 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/helmfile/helmfile/pkg/state"
+)
+
+// preflightVerbs are the verbs needed for each operation on the resources in the manifests of the releases,
+// the Secrets in the release namespaces, where Helm stores the release records, and the namespaces created by `--create-namespace`
+var preflightVerbs = map[string]struct {
+	resources  []string
+	storage    []string
+	namespaces []string
+	// applies is whether the operation applies the manifests, which needs the verbs depending on the releases and the resources
+	applies bool
+}{
+	// helm-diff reads the live resources to compare them with the rendered ones
+	state.PreflightOperationDiff: {
+		resources: []string{"get"},
+		storage:   []string{"get", "list"},
+	},
+	state.PreflightOperationSync: {
+		resources:  rbacVerbs,
+		storage:    rbacStorageVerbs,
+		namespaces: rbacNamespaceVerbs,
+		applies:    true,
+	},
+	state.PreflightOperationDestroy: {
+		resources: []string{"delete", "get"},
+		storage:   []string{"delete", "get", "list"},
+	},
+}
+
+// Preflight renders the manifests of the selected releases, and checks that the clusters of them are reachable
+// and that the current identity is allowed the verbs needed for the operation on each resource in them, before anything is changed
+func (a *App) Preflight(c PreflightConfigProvider) error {
+	report := state.NewPreflightReport()
+	verbs := preflightVerbs[c.Operation()]
+	restMapper := a.restMappers()
+
+	err := a.ForEachState(func(run *Run) (ok bool, errs []error) {
+		permissions := newRBACReport()
+		permissions.resourceVerbs = verbs.resources
+		permissions.storageVerbs = verbs.storage
+		permissions.namespaceVerbs = verbs.namespaces
+		permissions.applies = verbs.applies
+		permissions.restMapper = restMapper
+
+		selected, _, err := a.getSelectedReleases(run, false)
+		if err != nil {
+			return false, []error{err}
+		}
+
+		// The release records of the releases with `installed: false` are checked as well, as sync uninstalls them,
+		// while only the installed releases are rendered
+		for i := range selected {
+			r := &selected[i]
+
+			kubeContext := r.KubeContext
+			if kubeContext == "" {
+				kubeContext = run.state.HelmDefaults.KubeContext
+			}
+
+			permissions.addStorage(kubeContext, r.Namespace, run.state.CreatesNamespace(r))
+		}
+
+		ok, errs = a.renderRBAC(run, c, permissions)
+		if len(errs) > 0 {
+			return
+		}
+
+		run.state.Preflight(permissions.permissions(), report)
+
+		return
+	}, false)
+	if err != nil {
+		return err
+	}
+
+	if c.Output() == "json" {
+		err = FormatAsJson(a.Stdout(), report.Checks)
+	} else {
+		err = FormatPreflightAsTable(a.Stdout(), report.Checks)
+	}
+	if err != nil {
+		return err
+	}
+
+	if failed := report.Failed(); len(failed) > 0 {
+		return fmt.Errorf("%d of %d preflight checks failed", len(failed), len(report.Checks))
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"text/tabwriter"
+
+	"go.uber.org/zap"
+
+	"github.com/helmfile/helmfile/pkg/state"
+)
+
+// The statuses of the releases in the report of the run interrupted
+const (
+	progressStatusCompleted    = "completed"
+	progressStatusFailed       = "failed"
+	progressStatusNotCompleted = "not completed"
+)
+
+// runProgress records the changes planned and made to the releases in a run,
+// to report which releases completed when the run is interrupted by SIGINT, SIGTERM, or --run-timeout
+type runProgress struct {
+	mu sync.Mutex
+	// planned is the releases planned to be changed, in the order they are planned
+	planned []progressEntry
+	// done is the errors of the releases changed by their keys, which are nil for the releases completed
+	done map[string]error
+}
+
+type progressEntry struct {
+	ID     string
+	Action ReleaseAction
+}
+
+func (e progressEntry) key() string {
+	return string(e.Action) + "/" + e.ID
+}
+
+func newRunProgress() *runProgress {
+	return &runProgress{done: map[string]error{}}
+}
+
+// plan records the releases to be changed by the action
+func (p *runProgress) plan(releases map[string]state.ReleaseSpec, action ReleaseAction) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, id := range sortedReleaseIDs(releases) {
+		p.planned = append(p.planned, progressEntry{ID: id, Action: action})
+	}
+}
+
+// applied records the release changed by the action, along with its error if any
+func (p *runProgress) applied(release *state.ReleaseSpec, action ReleaseAction, err error) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.done[progressEntry{ID: state.ReleaseToID(release), Action: action}.key()] = err
+}
+
+// format returns the statuses of the releases planned, along with the numbers of the releases completed and planned
+func (p *runProgress) format() (string, int, int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	buf := &bytes.Buffer{}
+
+	w := new(tabwriter.Writer)
+
+	w.Init(buf, 0, 1, 3, ' ', 0)
+
+	fmt.Fprintln(w, "RELEASE\tACTION\tSTATUS")
+
+	var completed int
+
+	for _, e := range p.planned {
+		status := progressStatusNotCompleted
+		if err, ok := p.done[e.key()]; ok {
+			status = progressStatusFailed
+			if err == nil {
+				status = progressStatusCompleted
+				completed++
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", e.ID, e.Action, status)
+	}
+
+	_ = w.Flush()
+
+	return buf.String(), completed, len(p.planned)
+}
+
+// report logs which of the releases planned completed before the run is interrupted by cause
+func (p *runProgress) report(logger *zap.SugaredLogger, cause error) {
+	if p == nil {
+		return
+	}
+
+	// The releases may still be being planned and changed by the other goroutines, so they are read under the lock
+	statuses, completed, planned := p.format()
+
+	if planned == 0 {
+		logger.Warnf("The run is interrupted (%v) before changing any release", cause)
+		return
+	}
+
+	logger.Warnf("The run is interrupted (%v) after completing %d of %d releases:\n\n%s", cause, completed, planned, statuses)
+}
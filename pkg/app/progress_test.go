@@ -0,0 +1,69 @@
+package app
+
+import (
+	gocontext "context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/exectest"
+	"github.com/helmfile/helmfile/pkg/helmexec"
+	"github.com/helmfile/helmfile/pkg/state"
+)
+
+func TestRunProgress(t *testing.T) {
+	p := newRunProgress()
+
+	p.plan(map[string]state.ReleaseSpec{
+		"legacy": {Name: "legacy"},
+	}, ReleaseActionDelete)
+	p.plan(map[string]state.ReleaseSpec{
+		"web":      {Name: "web"},
+		"db":       {Name: "db"},
+		"frontend": {Name: "frontend"},
+	}, ReleaseActionUpgrade)
+
+	p.applied(&state.ReleaseSpec{Name: "legacy"}, ReleaseActionDelete, nil)
+	p.applied(&state.ReleaseSpec{Name: "db"}, ReleaseActionUpgrade, nil)
+	p.applied(&state.ReleaseSpec{Name: "frontend"}, ReleaseActionUpgrade, errors.New("killed"))
+
+	statuses, completed, planned := p.format()
+
+	require.Equal(t, 2, completed)
+	require.Equal(t, 4, planned)
+	require.Equal(t, `RELEASE    ACTION    STATUS
+legacy     delete    completed
+db         upgrade   completed
+frontend   upgrade   failed
+web        upgrade   not completed
+`, statuses)
+}
+
+func TestWrapWithoutSelector_ContextDone(t *testing.T) {
+	ctx, cancel := gocontext.WithCancel(gocontext.Background())
+
+	app := &App{Logger: helmexec.NewLogger(io.Discard, "debug"), ctx: ctx}
+
+	var converged int
+
+	converge := app.WrapWithoutSelector(func(st *state.HelmState, helm helmexec.Interface) []error {
+		converged++
+		return nil
+	})
+
+	st := &state.HelmState{}
+	st.Releases = []state.ReleaseSpec{{Name: "web"}}
+
+	processed, errs := converge(st, &exectest.Helm{})
+	require.True(t, processed)
+	require.Empty(t, errs)
+
+	cancel()
+
+	processed, errs = converge(st, &exectest.Helm{})
+	require.False(t, processed)
+	require.Equal(t, []error{gocontext.Canceled}, errs)
+	require.Equal(t, 1, converged)
+}
@@ -0,0 +1,601 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/helmfile/helmfile/pkg/argparser"
+	"github.com/helmfile/helmfile/pkg/state"
+	"github.com/helmfile/helmfile/pkg/yaml"
+)
+
+// rbacVerbs are the verbs Helm needs on the resources of a release to install, upgrade and uninstall it.
+// The verbs needed only by some releases and resources, like `update` to replace the resources with `--force`, are added by rbacReport.
+var rbacVerbs = []string{"create", "delete", "get", "patch"}
+
+// rbacStorageVerbs are the verbs Helm needs on the Secrets in the release namespace, where it stores the release records
+var rbacStorageVerbs = []string{"create", "delete", "get", "list", "update"}
+
+// rbacNamespaceVerbs are the verbs Helm needs on the release namespace to create it with `--create-namespace`
+var rbacNamespaceVerbs = []string{"create", "get"}
+
+// builtinResource is the resource of a built-in kind, and whether it's namespaced or not
+type builtinResource struct {
+	resource   string
+	namespaced bool
+}
+
+// builtinResources are the resources of the built-in kinds by `group/kind`,
+// used when the resources of the cluster can't be discovered, like with `--offline`
+var builtinResources = map[string]builtinResource{
+	"/ConfigMap":             {"configmaps", true},
+	"/Endpoints":             {"endpoints", true},
+	"/Event":                 {"events", true},
+	"/LimitRange":            {"limitranges", true},
+	"/Namespace":             {"namespaces", false},
+	"/Node":                  {"nodes", false},
+	"/PersistentVolume":      {"persistentvolumes", false},
+	"/PersistentVolumeClaim": {"persistentvolumeclaims", true},
+	"/Pod":                   {"pods", true},
+	"/PodTemplate":           {"podtemplates", true},
+	"/ReplicationController": {"replicationcontrollers", true},
+	"/ResourceQuota":         {"resourcequotas", true},
+	"/Secret":                {"secrets", true},
+	"/Service":               {"services", true},
+	"/ServiceAccount":        {"serviceaccounts", true},
+
+	"admissionregistration.k8s.io/MutatingWebhookConfiguration":   {"mutatingwebhookconfigurations", false},
+	"admissionregistration.k8s.io/ValidatingWebhookConfiguration": {"validatingwebhookconfigurations", false},
+	"apiextensions.k8s.io/CustomResourceDefinition":               {"customresourcedefinitions", false},
+	"apiregistration.k8s.io/APIService":                           {"apiservices", false},
+	"apps/ControllerRevision":                                     {"controllerrevisions", true},
+	"apps/DaemonSet":                                              {"daemonsets", true},
+	"apps/Deployment":                                             {"deployments", true},
+	"apps/ReplicaSet":                                             {"replicasets", true},
+	"apps/StatefulSet":                                            {"statefulsets", true},
+	"autoscaling/HorizontalPodAutoscaler":                         {"horizontalpodautoscalers", true},
+	"batch/CronJob":                                               {"cronjobs", true},
+	"batch/Job":                                                   {"jobs", true},
+	"certificates.k8s.io/CertificateSigningRequest":               {"certificatesigningrequests", false},
+	"coordination.k8s.io/Lease":                                   {"leases", true},
+	"discovery.k8s.io/EndpointSlice":                              {"endpointslices", true},
+	"events.k8s.io/Event":                                         {"events", true},
+	"flowcontrol.apiserver.k8s.io/FlowSchema":                     {"flowschemas", false},
+	"flowcontrol.apiserver.k8s.io/PriorityLevelConfiguration":     {"prioritylevelconfigurations", false},
+	"networking.k8s.io/Ingress":                                   {"ingresses", true},
+	"networking.k8s.io/IngressClass":                              {"ingressclasses", false},
+	"networking.k8s.io/NetworkPolicy":                             {"networkpolicies", true},
+	"node.k8s.io/RuntimeClass":                                    {"runtimeclasses", false},
+	"policy/PodDisruptionBudget":                                  {"poddisruptionbudgets", true},
+	"policy/PodSecurityPolicy":                                    {"podsecuritypolicies", false},
+	"rbac.authorization.k8s.io/ClusterRole":                       {"clusterroles", false},
+	"rbac.authorization.k8s.io/ClusterRoleBinding":                {"clusterrolebindings", false},
+	"rbac.authorization.k8s.io/Role":                              {"roles", true},
+	"rbac.authorization.k8s.io/RoleBinding":                       {"rolebindings", true},
+	"scheduling.k8s.io/PriorityClass":                             {"priorityclasses", false},
+	"storage.k8s.io/CSIDriver":                                    {"csidrivers", false},
+	"storage.k8s.io/CSINode":                                      {"csinodes", false},
+	"storage.k8s.io/CSIStorageCapacity":                           {"csistoragecapacities", true},
+	"storage.k8s.io/StorageClass":                                 {"storageclasses", false},
+	"storage.k8s.io/VolumeAttachment":                             {"volumeattachments", false},
+}
+
+// rbacManifest is the part of a rendered manifest needed to tell the permissions to apply it
+type rbacManifest struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	Metadata   struct {
+		Namespace   string            `yaml:"namespace"`
+		Annotations map[string]string `yaml:"annotations"`
+	} `yaml:"metadata"`
+	// Spec is read only for CustomResourceDefinitions
+	Spec struct {
+		Group string `yaml:"group"`
+		Scope string `yaml:"scope"`
+		Names struct {
+			Kind   string `yaml:"kind"`
+			Plural string `yaml:"plural"`
+		} `yaml:"names"`
+	} `yaml:"spec"`
+	// RoleRef is read only for RoleBindings and ClusterRoleBindings
+	RoleRef struct {
+		Kind string `yaml:"kind"`
+	} `yaml:"roleRef"`
+}
+
+// rbacRelease is the release whose manifests are added to rbacReport
+type rbacRelease struct {
+	kubeContext string
+	namespace   string
+	// createNamespace is whether the release namespace is created by `--create-namespace`
+	createNamespace bool
+	// wait is whether Helm waits for the resources with `--wait`, which lists the ReplicaSets of the Deployments
+	wait bool
+	// force is whether Helm replaces the resources with `--force`, which updates them
+	force bool
+}
+
+// rbacScope is the kube context and the namespace a Role is created in, or the kube context of a ClusterRole when the namespace is empty
+type rbacScope struct {
+	kubeContext string
+	namespace   string
+}
+
+// customResource is the resource and the scope of a kind defined by a CustomResourceDefinition
+type customResource struct {
+	resource   string
+	namespaced bool
+}
+
+// rbacReport collects the resources, and the verbs on them, needed to apply the manifests of the releases
+type rbacReport struct {
+	// verbs is the verbs on `group/resource` in each scope
+	verbs map[rbacScope]map[string]map[string]bool
+
+	// resourceVerbs, storageVerbs and namespaceVerbs are the verbs added for the resources in the manifests,
+	// the release records and the namespaces created by `--create-namespace`, which default to the ones to apply the manifests
+	resourceVerbs  []string
+	storageVerbs   []string
+	namespaceVerbs []string
+	// applies is whether the manifests are applied, which needs the verbs depending on the releases and the resources,
+	// like `escalate` on the Roles and `bind` on the roles of the RoleBindings, on top of resourceVerbs
+	applies bool
+
+	// restMapper returns the RESTMapper discovering the resources of the cluster of the kube context,
+	// or nil when the cluster isn't available, in which case the resources are looked up in builtinResources
+	restMapper func(kubeContext string) meta.RESTMapper
+
+	// pending is the resources whose kinds may be defined by the CustomResourceDefinitions rendered later
+	pending []pendingResource
+	// crds is the custom resources by `group/kind`
+	crds map[string]customResource
+}
+
+type pendingResource struct {
+	kubeContext string
+	namespace   string
+	gvk         schema.GroupVersionKind
+	verbs       []string
+}
+
+func newRBACReport() *rbacReport {
+	return &rbacReport{
+		verbs:          map[rbacScope]map[string]map[string]bool{},
+		resourceVerbs:  rbacVerbs,
+		storageVerbs:   rbacStorageVerbs,
+		namespaceVerbs: rbacNamespaceVerbs,
+		applies:        true,
+		crds:           map[string]customResource{},
+	}
+}
+
+func (r *rbacReport) add(scope rbacScope, group, resource string, verbs []string) {
+	if r.verbs[scope] == nil {
+		r.verbs[scope] = map[string]map[string]bool{}
+	}
+
+	key := group + "/" + resource
+	if r.verbs[scope][key] == nil {
+		r.verbs[scope][key] = map[string]bool{}
+	}
+
+	for _, v := range verbs {
+		r.verbs[scope][key][v] = true
+	}
+}
+
+// addStorage adds the release records Helm stores in the release namespace,
+// and the release namespace itself when it's created by `--create-namespace`
+func (r *rbacReport) addStorage(kubeContext, releaseNamespace string, createNamespace bool) {
+	if createNamespace && releaseNamespace != "" && len(r.namespaceVerbs) > 0 {
+		r.add(rbacScope{kubeContext: kubeContext}, "", "namespaces", r.namespaceVerbs)
+	}
+
+	if releaseNamespace == "" {
+		releaseNamespace = "default"
+	}
+
+	r.add(rbacScope{kubeContext: kubeContext, namespace: releaseNamespace}, "", "secrets", r.storageVerbs)
+}
+
+// addRelease adds the resources in the manifests of the release, along with the ones added by addStorage
+func (r *rbacReport) addRelease(release rbacRelease, manifests []byte) error {
+	r.addStorage(release.kubeContext, release.namespace, release.createNamespace)
+
+	releaseNamespace := release.namespace
+	if releaseNamespace == "" {
+		releaseNamespace = "default"
+	}
+
+	decode := yaml.NewDecoder(manifests, false)
+
+	for {
+		var m rbacManifest
+
+		err := decode(&m)
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return fmt.Errorf("decoding manifest: %v", err)
+		}
+
+		if m.Kind == "" {
+			continue
+		}
+
+		gv, err := schema.ParseGroupVersion(m.APIVersion)
+		if err != nil {
+			return fmt.Errorf("manifest of kind %s: %v", m.Kind, err)
+		}
+
+		if m.Kind == "CustomResourceDefinition" && m.Spec.Names.Kind != "" {
+			r.crds[m.Spec.Group+"/"+m.Spec.Names.Kind] = customResource{
+				resource:   m.Spec.Names.Plural,
+				namespaced: m.Spec.Scope != "Cluster",
+			}
+		}
+
+		namespace := m.Metadata.Namespace
+		if namespace == "" {
+			namespace = releaseNamespace
+		}
+
+		gvk := gv.WithKind(m.Kind)
+
+		verbs := r.resourceVerbs
+		if r.applies {
+			verbs = append(append([]string{}, verbs...), r.applyVerbs(release, &m, gvk)...)
+			r.addApplyDependencies(release, &m, gvk, namespace)
+		}
+
+		r.pending = append(r.pending, pendingResource{
+			kubeContext: release.kubeContext,
+			namespace:   namespace,
+			gvk:         gvk,
+			verbs:       verbs,
+		})
+	}
+
+	return nil
+}
+
+// applyVerbs returns the verbs needed to apply the resource on top of resourceVerbs
+func (r *rbacReport) applyVerbs(release rbacRelease, m *rbacManifest, gvk schema.GroupVersionKind) []string {
+	var verbs []string
+
+	if release.force {
+		verbs = append(verbs, "update")
+	}
+
+	switch gvk.GroupKind().String() {
+	case "Role.rbac.authorization.k8s.io", "ClusterRole.rbac.authorization.k8s.io":
+		// Creating the roles granting the permissions the deployer doesn't have is allowed only with `escalate`
+		verbs = append(verbs, "escalate")
+	case "Job.batch", "Pod":
+		// Helm watches the hooks until they complete
+		if m.Metadata.Annotations["helm.sh/hook"] != "" {
+			verbs = append(verbs, "list", "watch")
+		}
+	}
+
+	return verbs
+}
+
+// addApplyDependencies adds the other resources needed to apply the resource, like the roles bound by the RoleBindings
+func (r *rbacReport) addApplyDependencies(release rbacRelease, m *rbacManifest, gvk schema.GroupVersionKind, namespace string) {
+	switch gvk.GroupKind().String() {
+	case "RoleBinding.rbac.authorization.k8s.io", "ClusterRoleBinding.rbac.authorization.k8s.io":
+		// Binding the roles granting the permissions the deployer doesn't have is allowed only with `bind` on the roles
+		if m.RoleRef.Kind != "" {
+			r.pending = append(r.pending, pendingResource{
+				kubeContext: release.kubeContext,
+				namespace:   namespace,
+				gvk:         schema.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: m.RoleRef.Kind},
+				verbs:       []string{"bind"},
+			})
+		}
+	case "Deployment.apps":
+		// Helm finds the ReplicaSets of the Deployments to wait for
+		if release.wait {
+			r.pending = append(r.pending, pendingResource{
+				kubeContext: release.kubeContext,
+				namespace:   namespace,
+				gvk:         schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "ReplicaSet"},
+				verbs:       []string{"list"},
+			})
+		}
+	}
+}
+
+// resolve adds the resources collected so far, now that all the CustomResourceDefinitions are known
+func (r *rbacReport) resolve() {
+	for _, p := range r.pending {
+		resource, namespaced := r.resourceFor(p.kubeContext, p.gvk)
+
+		scope := rbacScope{kubeContext: p.kubeContext}
+		if namespaced {
+			scope.namespace = p.namespace
+		}
+
+		r.add(scope, p.gvk.Group, resource, p.verbs)
+	}
+
+	r.pending = nil
+}
+
+// resourceFor returns the resource of the kind, and whether it's namespaced or not.
+// The kind is looked up in the CustomResourceDefinitions rendered, the cluster of the kube context, and builtinResources in this order,
+// and the unknown kinds are considered namespaced, with their resources guessed from them.
+func (r *rbacReport) resourceFor(kubeContext string, gvk schema.GroupVersionKind) (string, bool) {
+	if crd, ok := r.crds[gvk.Group+"/"+gvk.Kind]; ok && crd.resource != "" {
+		return crd.resource, crd.namespaced
+	}
+
+	if r.restMapper != nil {
+		if mapper := r.restMapper(kubeContext); mapper != nil {
+			if mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version); err == nil {
+				return mapping.Resource.Resource, mapping.Scope.Name() == meta.RESTScopeNameNamespace
+			}
+		}
+	}
+
+	if b, ok := builtinResources[gvk.Group+"/"+gvk.Kind]; ok {
+		return b.resource, b.namespaced
+	}
+
+	plural, _ := meta.UnsafeGuessKindToResource(gvk)
+
+	return plural.Resource, true
+}
+
+// scopes returns the scopes in a stable order
+func (r *rbacReport) scopes() []rbacScope {
+	var scopes []rbacScope
+	for s := range r.verbs {
+		scopes = append(scopes, s)
+	}
+	sort.Slice(scopes, func(i, j int) bool {
+		if scopes[i].kubeContext != scopes[j].kubeContext {
+			return scopes[i].kubeContext < scopes[j].kubeContext
+		}
+		return scopes[i].namespace < scopes[j].namespace
+	})
+	return scopes
+}
+
+// permissions returns the verbs on the resources collected so far to be checked with `kubectl auth can-i`, in a stable order
+func (r *rbacReport) permissions() []state.PreflightPermission {
+	r.resolve()
+
+	var permissions []state.PreflightPermission
+
+	for _, s := range r.scopes() {
+		var keys []string
+		for key := range r.verbs[s] {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			group, resource, _ := strings.Cut(key, "/")
+			if group != "" {
+				resource += "." + group
+			}
+
+			var verbs []string
+			for v := range r.verbs[s][key] {
+				verbs = append(verbs, v)
+			}
+			sort.Strings(verbs)
+
+			for _, v := range verbs {
+				permissions = append(permissions, state.PreflightPermission{
+					KubeContext: s.kubeContext,
+					Namespace:   s.namespace,
+					Verb:        v,
+					Resource:    resource,
+				})
+			}
+		}
+	}
+
+	return permissions
+}
+
+// rbacRule is a rule of a Role or a ClusterRole
+type rbacRule struct {
+	APIGroups []string `yaml:"apiGroups"`
+	Resources []string `yaml:"resources"`
+	Verbs     []string `yaml:"verbs"`
+}
+
+// rules returns the rules of the scope, one per API group and the set of verbs, in a stable order
+func (r *rbacReport) rules(scope rbacScope) []rbacRule {
+	type ruleKey struct {
+		group string
+		verbs string
+	}
+
+	resources := map[ruleKey][]string{}
+
+	for key, verbSet := range r.verbs[scope] {
+		group, resource, _ := strings.Cut(key, "/")
+
+		var verbs []string
+		for v := range verbSet {
+			verbs = append(verbs, v)
+		}
+		sort.Strings(verbs)
+
+		k := ruleKey{group: group, verbs: strings.Join(verbs, ",")}
+		resources[k] = append(resources[k], resource)
+	}
+
+	var keys []ruleKey
+	for k := range resources {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].group != keys[j].group {
+			return keys[i].group < keys[j].group
+		}
+		return keys[i].verbs < keys[j].verbs
+	})
+
+	var rules []rbacRule
+	for _, k := range keys {
+		rs := resources[k]
+		sort.Strings(rs)
+		rules = append(rules, rbacRule{
+			APIGroups: []string{k.group},
+			Resources: rs,
+			Verbs:     strings.Split(k.verbs, ","),
+		})
+	}
+
+	return rules
+}
+
+// rbacRole is a Role or a ClusterRole manifest
+type rbacRole struct {
+	APIVersion string       `yaml:"apiVersion"`
+	Kind       string       `yaml:"kind"`
+	Metadata   rbacRoleMeta `yaml:"metadata"`
+	Rules      []rbacRule   `yaml:"rules"`
+}
+
+type rbacRoleMeta struct {
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace,omitempty"`
+}
+
+// write writes the ClusterRole and the Roles named after the name, each preceded by a comment of the kube context it is for
+func (r *rbacReport) write(w io.Writer, name string) error {
+	r.resolve()
+
+	var buf bytes.Buffer
+
+	for _, s := range r.scopes() {
+		role := rbacRole{
+			APIVersion: "rbac.authorization.k8s.io/v1",
+			Kind:       "ClusterRole",
+			Metadata:   rbacRoleMeta{Name: name},
+			Rules:      r.rules(s),
+		}
+		if s.namespace != "" {
+			role.Kind = "Role"
+			role.Metadata.Namespace = s.namespace
+		}
+
+		bs, err := yaml.Marshal(role)
+		if err != nil {
+			return err
+		}
+
+		buf.WriteString("---\n")
+		if s.kubeContext != "" {
+			fmt.Fprintf(&buf, "# kubeContext: %s\n", s.kubeContext)
+		}
+		buf.Write(bs)
+	}
+
+	_, err := w.Write(buf.Bytes())
+
+	return err
+}
+
+// RBAC renders the manifests of the selected releases, and prints the Roles and the ClusterRoles
+// allowing the minimal permissions to apply them
+func (a *App) RBAC(c RBACConfigProvider) error {
+	report := newRBACReport()
+	report.restMapper = a.restMappers()
+
+	err := a.ForEachState(func(run *Run) (bool, []error) {
+		return a.renderRBAC(run, c, report)
+	}, false)
+	if err != nil {
+		return err
+	}
+
+	return report.write(a.Stdout(), c.Name())
+}
+
+// renderRBAC prepares the charts of the run and renders the manifests of the selected releases, adding the resources in them to the report
+func (a *App) renderRBAC(run *Run, c rbacRenderConfig, report *rbacReport) (ok bool, errs []error) {
+	// The CustomResourceDefinitions are needed to tell the resources and the scopes of the custom resources
+	includeCRDs := true
+
+	run.helm.SetEnableLiveOutput(false)
+
+	prepErr := run.withPreparedCharts("template", state.ChartPrepareOptions{
+		SkipRepos:   c.SkipDeps(),
+		SkipDeps:    c.SkipDeps(),
+		IncludeCRDs: &includeCRDs,
+		Concurrency: c.Concurrency(),
+	}, func() {
+		ok, errs = a.rbac(run, c, report)
+	})
+
+	if prepErr != nil {
+		errs = append(errs, prepErr)
+	}
+
+	return
+}
+
+// rbac renders the manifests of the selected releases of the run, adding the resources in them to the report
+func (a *App) rbac(r *Run, c rbacRenderConfig, report *rbacReport) (bool, []error) {
+	valuesFiles, err := r.ctx.ValuesFiles(c.Values())
+	if err != nil {
+		return false, []error{err}
+	}
+
+	return a.withNeeds(r, c, false, func(st *state.HelmState) []error {
+		helm := r.helm
+
+		args := argparser.GetArgs(c.Args(), st)
+
+		// Reset the extra args if already set, not to break `helm fetch` by adding the args intended for `lint`
+		helm.SetExtraArgs()
+
+		if len(args) > 0 {
+			helm.SetExtraArgs(args...)
+		}
+
+		var errs []error
+
+		opts := &state.TemplateOpts{
+			Set:         c.Set(),
+			IncludeCRDs: true,
+			OnManifests: func(release *state.ReleaseSpec, manifests []byte) {
+				kubeContext := release.KubeContext
+				if kubeContext == "" {
+					kubeContext = st.HelmDefaults.KubeContext
+				}
+
+				rel := rbacRelease{
+					kubeContext:     kubeContext,
+					namespace:       release.Namespace,
+					createNamespace: st.CreatesNamespace(release),
+					wait:            st.Waits(release),
+					force:           st.Forces(release),
+				}
+
+				if err := report.addRelease(rel, manifests); err != nil {
+					errs = append(errs, fmt.Errorf("release %q: %w", release.Name, err))
+				}
+			},
+		}
+
+		templateErrs := st.TemplateReleases(helm, "", valuesFiles, args, c.Concurrency(), false, opts)
+
+		return append(templateErrs, errs...)
+	})
+}
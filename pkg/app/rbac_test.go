@@ -0,0 +1,315 @@
+package app
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/helmfile/helmfile/pkg/state"
+)
+
+func TestRBACReport(t *testing.T) {
+	report := newRBACReport()
+
+	require.NoError(t, report.addRelease(rbacRelease{kubeContext: "prod", namespace: "web", createNamespace: true, wait: true}, []byte(`---
+# Source: web/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+  namespace: edge
+---
+apiVersion: example.com/v1
+kind: Backup
+metadata:
+  name: web
+---
+apiVersion: example.com/v1
+kind: BackupPolicy
+metadata:
+  name: default
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: web
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+  name: web
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: web
+---
+apiVersion: v1
+kind: Endpoints
+metadata:
+  name: external
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+  annotations:
+    helm.sh/hook: pre-upgrade
+`)))
+
+	require.NoError(t, report.addRelease(rbacRelease{kubeContext: "prod", force: true}, []byte(`apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: backuppolicies.example.com
+spec:
+  group: example.com
+  scope: Cluster
+  names:
+    kind: BackupPolicy
+    plural: backuppolicies
+`)))
+
+	var buf bytes.Buffer
+	require.NoError(t, report.write(&buf, "deployer"))
+
+	require.Equal(t, `---
+# kubeContext: prod
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: deployer
+rules:
+- apiGroups:
+  - ""
+  resources:
+  - namespaces
+  verbs:
+  - create
+  - get
+- apiGroups:
+  - apiextensions.k8s.io
+  resources:
+  - customresourcedefinitions
+  verbs:
+  - create
+  - delete
+  - get
+  - patch
+  - update
+- apiGroups:
+  - example.com
+  resources:
+  - backuppolicies
+  verbs:
+  - create
+  - delete
+  - get
+  - patch
+- apiGroups:
+  - rbac.authorization.k8s.io
+  resources:
+  - clusterroles
+  verbs:
+  - bind
+  - create
+  - delete
+  - escalate
+  - get
+  - patch
+---
+# kubeContext: prod
+apiVersion: rbac.authorization.k8s.io/v1
+kind: Role
+metadata:
+  name: deployer
+  namespace: default
+rules:
+- apiGroups:
+  - ""
+  resources:
+  - secrets
+  verbs:
+  - create
+  - delete
+  - get
+  - list
+  - update
+---
+# kubeContext: prod
+apiVersion: rbac.authorization.k8s.io/v1
+kind: Role
+metadata:
+  name: deployer
+  namespace: edge
+rules:
+- apiGroups:
+  - networking.k8s.io
+  resources:
+  - ingresses
+  verbs:
+  - create
+  - delete
+  - get
+  - patch
+---
+# kubeContext: prod
+apiVersion: rbac.authorization.k8s.io/v1
+kind: Role
+metadata:
+  name: deployer
+  namespace: web
+rules:
+- apiGroups:
+  - ""
+  resources:
+  - secrets
+  verbs:
+  - create
+  - delete
+  - get
+  - list
+  - update
+- apiGroups:
+  - ""
+  resources:
+  - endpoints
+  - services
+  verbs:
+  - create
+  - delete
+  - get
+  - patch
+- apiGroups:
+  - apps
+  resources:
+  - deployments
+  verbs:
+  - create
+  - delete
+  - get
+  - patch
+- apiGroups:
+  - apps
+  resources:
+  - replicasets
+  verbs:
+  - list
+- apiGroups:
+  - batch
+  resources:
+  - jobs
+  verbs:
+  - create
+  - delete
+  - get
+  - list
+  - patch
+  - watch
+- apiGroups:
+  - example.com
+  resources:
+  - backups
+  verbs:
+  - create
+  - delete
+  - get
+  - patch
+- apiGroups:
+  - rbac.authorization.k8s.io
+  resources:
+  - rolebindings
+  verbs:
+  - create
+  - delete
+  - get
+  - patch
+`, buf.String())
+}
+
+func TestRBACReport_Permissions(t *testing.T) {
+	verbs := preflightVerbs[state.PreflightOperationDestroy]
+
+	report := newRBACReport()
+	report.resourceVerbs = verbs.resources
+	report.storageVerbs = verbs.storage
+	report.namespaceVerbs = verbs.namespaces
+	report.applies = verbs.applies
+
+	report.addStorage("prod", "db", true)
+
+	require.NoError(t, report.addRelease(rbacRelease{kubeContext: "prod", namespace: "web", createNamespace: true, wait: true}, []byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: web
+`)))
+
+	require.Equal(t, []state.PreflightPermission{
+		{KubeContext: "prod", Verb: "delete", Resource: "namespaces"},
+		{KubeContext: "prod", Verb: "get", Resource: "namespaces"},
+		{KubeContext: "prod", Namespace: "db", Verb: "delete", Resource: "secrets"},
+		{KubeContext: "prod", Namespace: "db", Verb: "get", Resource: "secrets"},
+		{KubeContext: "prod", Namespace: "db", Verb: "list", Resource: "secrets"},
+		{KubeContext: "prod", Namespace: "web", Verb: "delete", Resource: "secrets"},
+		{KubeContext: "prod", Namespace: "web", Verb: "get", Resource: "secrets"},
+		{KubeContext: "prod", Namespace: "web", Verb: "list", Resource: "secrets"},
+		{KubeContext: "prod", Namespace: "web", Verb: "delete", Resource: "deployments.apps"},
+		{KubeContext: "prod", Namespace: "web", Verb: "get", Resource: "deployments.apps"},
+	}, report.permissions())
+}
+
+func TestRBACReport_RESTMapper(t *testing.T) {
+	gv := schema.GroupVersion{Group: "example.com", Version: "v1"}
+
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{gv})
+	mapper.AddSpecific(gv.WithKind("Cactus"), gv.WithResource("cacti"), gv.WithResource("cactus"), meta.RESTScopeRoot)
+
+	report := newRBACReport()
+	report.restMapper = func(kubeContext string) meta.RESTMapper {
+		require.Equal(t, "prod", kubeContext)
+		return mapper
+	}
+
+	require.NoError(t, report.addRelease(rbacRelease{kubeContext: "prod", namespace: "web"}, []byte(`apiVersion: example.com/v1
+kind: Cactus
+metadata:
+  name: web
+---
+apiVersion: v1
+kind: Endpoints
+metadata:
+  name: web
+`)))
+
+	require.Equal(t, []state.PreflightPermission{
+		{KubeContext: "prod", Verb: "create", Resource: "cacti.example.com"},
+		{KubeContext: "prod", Verb: "delete", Resource: "cacti.example.com"},
+		{KubeContext: "prod", Verb: "get", Resource: "cacti.example.com"},
+		{KubeContext: "prod", Verb: "patch", Resource: "cacti.example.com"},
+		{KubeContext: "prod", Namespace: "web", Verb: "create", Resource: "endpoints"},
+		{KubeContext: "prod", Namespace: "web", Verb: "delete", Resource: "endpoints"},
+		{KubeContext: "prod", Namespace: "web", Verb: "get", Resource: "endpoints"},
+		{KubeContext: "prod", Namespace: "web", Verb: "patch", Resource: "endpoints"},
+		{KubeContext: "prod", Namespace: "web", Verb: "create", Resource: "secrets"},
+		{KubeContext: "prod", Namespace: "web", Verb: "delete", Resource: "secrets"},
+		{KubeContext: "prod", Namespace: "web", Verb: "get", Resource: "secrets"},
+		{KubeContext: "prod", Namespace: "web", Verb: "list", Resource: "secrets"},
+		{KubeContext: "prod", Namespace: "web", Verb: "update", Resource: "secrets"},
+	}, report.permissions())
+}
@@ -0,0 +1,48 @@
+package app
+
+import (
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+// restMapperTimeout is how long discovering the resources of a cluster waits for the API server
+const restMapperTimeout = "10s"
+
+// restMappers returns the function returning the RESTMapper discovering the resources of the cluster of the kube context,
+// or nil when the cluster isn't reachable, caching it for each kube context.
+// The clusters aren't discovered with --offline, in which case it returns nil.
+func (a *App) restMappers() func(kubeContext string) meta.RESTMapper {
+	if a.opts.Offline {
+		return nil
+	}
+
+	mappers := map[string]meta.RESTMapper{}
+
+	return func(kubeContext string) meta.RESTMapper {
+		if m, ok := mappers[kubeContext]; ok {
+			return m
+		}
+
+		flags := genericclioptions.NewConfigFlags(true)
+		if kubeContext != "" {
+			flags.Context = &kubeContext
+		}
+		timeout := restMapperTimeout
+		flags.Timeout = &timeout
+
+		m, err := flags.ToRESTMapper()
+		if err == nil {
+			// The discovery is deferred until the first lookup, which fails when the cluster isn't reachable
+			_, err = m.KindFor(schema.GroupVersionResource{Version: "v1", Resource: "namespaces"})
+		}
+		if err != nil {
+			a.Logger.Debugf("looking up the resources of the built-in kinds, as discovering the resources of the cluster of kube context %q failed: %v", kubeContext, err)
+			m = nil
+		}
+
+		mappers[kubeContext] = m
+
+		return m
+	}
+}
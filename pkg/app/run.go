@@ -2,12 +2,16 @@ package app
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 
 	"github.com/helmfile/helmfile/pkg/argparser"
+	"github.com/helmfile/helmfile/pkg/errors"
 	"github.com/helmfile/helmfile/pkg/helmexec"
+	"github.com/helmfile/helmfile/pkg/remote"
 	"github.com/helmfile/helmfile/pkg/state"
 )
 
@@ -15,10 +19,14 @@ type Run struct {
 	state *state.HelmState
 	helm  helmexec.Interface
 	ctx   Context
+	// op is the operation the run is a part of
+	op *operation
 
 	ReleaseToChart map[state.PrepareChartKey]string
 
 	Ask func(string) bool
+
+	stdout io.Writer
 }
 
 func NewRun(st *state.HelmState, helm helmexec.Interface, ctx Context) (*Run, error) {
@@ -37,7 +45,14 @@ func (r *Run) askForConfirmation(msg string) bool {
 	if r.Ask != nil {
 		return r.Ask(msg)
 	}
-	return AskForConfirmation(msg)
+	return askForConfirmation(r.out(), os.Stdin, msg)
+}
+
+func (r *Run) out() io.Writer {
+	if r.stdout != nil {
+		return r.stdout
+	}
+	return os.Stdout
 }
 
 func (r *Run) withPreparedCharts(helmfileCommand string, opts state.ChartPrepareOptions, f func()) error {
@@ -45,6 +60,11 @@ func (r *Run) withPreparedCharts(helmfileCommand string, opts state.ChartPrepare
 		panic("Run.PrepareCharts can be called only once")
 	}
 
+	// The repositories aren't needed when all the charts are vendored, so that the vendored charts work without network access
+	if !opts.SkipRepos && !opts.SkipVendor && r.state.ChartsVendored() {
+		opts.SkipRepos = true
+	}
+
 	if !opts.SkipRepos {
 		ctx := r.ctx
 		if err := ctx.SyncReposOnce(r.state, r.helm); err != nil {
@@ -55,7 +75,7 @@ func (r *Run) withPreparedCharts(helmfileCommand string, opts state.ChartPrepare
 	// Create tmp directory and bail immediately if it fails
 	var dir string
 	if len(opts.OutputDir) == 0 {
-		tempDir, err := os.MkdirTemp("", "helmfile*")
+		tempDir, err := r.state.MkdirTemp("helmfile*")
 		if err != nil {
 			return err
 		}
@@ -63,9 +83,14 @@ func (r *Run) withPreparedCharts(helmfileCommand string, opts state.ChartPrepare
 			_ = os.RemoveAll(tempDir)
 		}()
 		dir = tempDir
+
+		// The OCI charts pinned by digest are reused across the runs, unless they are downloaded into the output directory
+		if opts.OCICacheDir == "" {
+			opts.OCICacheDir = filepath.Join(remote.CacheDir(), "oci")
+		}
 	} else {
 		dir = opts.OutputDir
-		fmt.Printf("Charts will be downloaded to: %s\n", dir)
+		fmt.Fprintf(r.out(), "Charts will be downloaded to: %s\n", dir)
 	}
 
 	if _, err := r.state.TriggerGlobalPrepareEvent(helmfileCommand); err != nil {
@@ -77,7 +102,7 @@ func (r *Run) withPreparedCharts(helmfileCommand string, opts state.ChartPrepare
 	releaseToChart, errs := r.state.PrepareCharts(r.helm, dir, concurrency, helmfileCommand, opts)
 
 	if len(errs) > 0 {
-		return fmt.Errorf("%v", errs)
+		return errors.WithCode(errors.CodeChartPrepare, errors.PhasePrepare, fmt.Errorf("%v", errs))
 	}
 
 	for i := range r.state.Releases {
@@ -107,7 +132,7 @@ func (r *Run) withPreparedCharts(helmfileCommand string, opts state.ChartPrepare
 func (r *Run) Deps(c DepsConfigProvider) []error {
 	r.helm.SetExtraArgs(argparser.GetArgs(c.Args(), r.state)...)
 
-	return r.state.UpdateDeps(r.helm, c.IncludeTransitiveNeeds())
+	return r.state.UpdateDeps(r.helm, c.IncludeTransitiveNeeds(), &state.DepsOpts{UpdateLocks: c.UpdateLocks()})
 }
 
 func (r *Run) Repos(c ReposConfigProvider) error {
@@ -121,8 +146,13 @@ func (r *Run) DeprecatedSyncCharts(c DeprecatedChartsConfigProvider) []error {
 	st := r.state
 	helm := r.helm
 
+	valuesFiles, err := r.ctx.ValuesFiles(c.Values())
+	if err != nil {
+		return []error{err}
+	}
+
 	affectedReleases := state.AffectedReleases{}
-	errs := st.SyncReleases(&affectedReleases, helm, c.Values(), c.Concurrency())
+	errs := st.SyncReleases(&affectedReleases, helm, valuesFiles, c.Concurrency())
 	affectedReleases.DisplayAffectedReleases(c.Logger())
 	return errs
 }
@@ -135,9 +165,14 @@ func (r *Run) diff(triggerCleanupEvent bool, detailedExitCode bool, c DiffConfig
 	var deletingReleases []state.ReleaseSpec
 	var planningErrs []error
 
+	valuesFiles, err := r.ctx.ValuesFiles(c.Values())
+	if err != nil {
+		return nil, nil, nil, []error{err}
+	}
+
 	// TODO Better way to detect diff on only filtered releases
 	{
-		changedReleases, planningErrs = st.DiffReleases(helm, c.Values(), c.Concurrency(), detailedExitCode, c.IncludeTests(), c.Suppress(), c.SuppressSecrets(), c.ShowSecrets(), c.NoHooks(), c.SuppressDiff(), triggerCleanupEvent, diffOpts)
+		changedReleases, planningErrs = st.DiffReleases(helm, valuesFiles, c.Concurrency(), detailedExitCode, c.IncludeTests(), c.Suppress(), c.SuppressSecrets(), c.ShowSecrets(), c.NoHooks(), c.SuppressDiff(), triggerCleanupEvent, diffOpts)
 
 		var err error
 		deletingReleases, err = st.DetectReleasesToBeDeletedForSync(helm, st.Releases)
@@ -0,0 +1,83 @@
+package app
+
+import (
+	"sync"
+	"time"
+
+	"github.com/helmfile/helmfile/pkg/state"
+)
+
+// RunSummary is the summary of a helmfile invocation, available to `postrun` hooks as `.Run`
+type RunSummary struct {
+	// States is the paths to the state files processed, in order
+	States []string
+	// Releases is the IDs of the releases selected in the processed states
+	Releases []string
+	// Duration is the time elapsed since the `prerun` hooks were triggered
+	Duration time.Duration
+	// Succeeded is false when the invocation failed, in which case `.Event.Error` is the error
+	Succeeded bool
+}
+
+// runHooks triggers the `prerun` and `postrun` hooks of the root helmfiles exactly once per invocation,
+// rather than per state or release like the other hooks, along with the `pre<command>` and `post<command>` hooks of the command.
+type runHooks struct {
+	mu sync.Mutex
+
+	// command is the helmfile command run, which is empty for the commands without the run-level hooks of their own
+	command string
+
+	started time.Time
+	// roots are the root states whose `prerun` hooks were triggered
+	roots []*state.HelmState
+
+	summary RunSummary
+}
+
+func newRunHooks(command string) *runHooks {
+	return &runHooks{command: command, started: time.Now()}
+}
+
+// prerun triggers the `prerun` and `pre<command>` hooks of the root state
+func (h *runHooks) prerun(st *state.HelmState) error {
+	h.mu.Lock()
+	h.roots = append(h.roots, st)
+	h.mu.Unlock()
+
+	_, err := st.TriggerPrerunEvent(h.command)
+
+	return err
+}
+
+// processed records the state and its selected releases in the summary
+func (h *runHooks) processed(st *state.HelmState, includeTransitiveNeeds bool) {
+	releases, err := st.GetSelectedReleases(includeTransitiveNeeds)
+	if err != nil {
+		releases = st.Releases
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.summary.States = append(h.summary.States, st.FilePath)
+	for i := range releases {
+		h.summary.Releases = append(h.summary.Releases, state.ReleaseToID(&releases[i]))
+	}
+}
+
+// postrun triggers the `post<command>` and `postrun` hooks of the root states with the summary of the run, returning the first error
+func (h *runHooks) postrun(runErr error) error {
+	summary := h.summary
+	summary.Duration = time.Since(h.started)
+	summary.Succeeded = runErr == nil
+
+	var firstErr error
+
+	for _, st := range h.roots {
+		if _, err := st.TriggerPostrunEvent(h.command, runErr, summary); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
@@ -0,0 +1,326 @@
+package app
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aryann/difflib"
+
+	"github.com/helmfile/helmfile/pkg/argparser"
+	"github.com/helmfile/helmfile/pkg/errors"
+	"github.com/helmfile/helmfile/pkg/state"
+)
+
+// snapshotDiffContext is the number of the unchanged lines shown around each change in the diffs of the snapshots
+const snapshotDiffContext = 3
+
+// snapshotPath returns the path to the snapshot of the release relative to the directory of the environment,
+// like `<kubeContext>/<namespace>/<name>.yaml`.
+// The empty namespace is `_`, not to conflate the releases with and without the kube context.
+func snapshotPath(release *state.ReleaseSpec) string {
+	ns := release.Namespace
+	if ns == "" {
+		ns = "_"
+	}
+
+	p := path.Join(ns, release.Name+".yaml")
+	if release.KubeContext != "" {
+		p = path.Join(release.KubeContext, p)
+	}
+
+	return filepath.FromSlash(p)
+}
+
+// renderSnapshots renders the selected releases, and returns their manifests by the paths to their snapshots relative to the snapshot directory,
+// along with the environments they are rendered in
+func (a *App) renderSnapshots(c SnapshotConfigProvider) (map[string][]byte, map[string]bool, error) {
+	snapshots := map[string][]byte{}
+	envs := map[string]bool{}
+
+	err := a.ForEachState(func(run *Run) (ok bool, errs []error) {
+		includeCRDs := c.IncludeCRDs()
+
+		run.helm.SetEnableLiveOutput(false)
+
+		prepErr := run.withPreparedCharts("template", state.ChartPrepareOptions{
+			SkipRepos:   c.SkipDeps(),
+			SkipDeps:    c.SkipDeps(),
+			IncludeCRDs: &includeCRDs,
+			Concurrency: c.Concurrency(),
+		}, func() {
+			ok, errs = a.snapshot(run, c, snapshots, envs)
+		})
+
+		if prepErr != nil {
+			errs = append(errs, prepErr)
+		}
+
+		return
+	}, false)
+
+	return snapshots, envs, err
+}
+
+func (a *App) snapshot(r *Run, c SnapshotConfigProvider, snapshots map[string][]byte, envs map[string]bool) (bool, []error) {
+	valuesFiles, err := r.ctx.ValuesFiles(c.Values())
+	if err != nil {
+		return false, []error{err}
+	}
+
+	return a.withNeeds(r, c, false, func(st *state.HelmState) []error {
+		helm := r.helm
+
+		args := argparser.GetArgs(c.Args(), st)
+
+		// Reset the extra args if already set, not to break `helm fetch` by adding the args intended for `lint`
+		helm.SetExtraArgs()
+
+		if len(args) > 0 {
+			helm.SetExtraArgs(args...)
+		}
+
+		var errs []error
+
+		opts := &state.TemplateOpts{
+			Set:         c.Set(),
+			IncludeCRDs: c.IncludeCRDs(),
+			SkipTests:   c.SkipTests(),
+			OnManifests: func(release *state.ReleaseSpec, manifests []byte) {
+				p := filepath.Join(st.Env.Name, snapshotPath(release))
+				if _, ok := snapshots[p]; ok {
+					errs = append(errs, fmt.Errorf("release %q of %s: another release has the same snapshot %s", release.Name, st.FilePath, p))
+					return
+				}
+
+				snapshots[p] = manifests
+				envs[st.Env.Name] = true
+			},
+		}
+
+		templateErrs := st.TemplateReleases(helm, "", valuesFiles, args, c.Concurrency(), false, opts)
+
+		return append(templateErrs, errs...)
+	})
+}
+
+// obsoleteSnapshots returns the paths to the snapshots in the directories of the environments, relative to the snapshot directory,
+// that no release is rendered into
+func obsoleteSnapshots(dir string, envs map[string]bool, snapshots map[string][]byte) ([]string, error) {
+	var obsolete []string
+
+	for env := range envs {
+		err := filepath.WalkDir(filepath.Join(dir, env), func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+
+			if d.IsDir() || filepath.Ext(p) != ".yaml" {
+				return nil
+			}
+
+			rel, err := filepath.Rel(dir, p)
+			if err != nil {
+				return err
+			}
+
+			if _, ok := snapshots[rel]; !ok {
+				obsolete = append(obsolete, rel)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Strings(obsolete)
+
+	return obsolete, nil
+}
+
+func sortedSnapshotPaths(snapshots map[string][]byte) []string {
+	paths := make([]string, 0, len(snapshots))
+	for p := range snapshots {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// WriteSnapshots renders the selected releases, and writes their manifests to the snapshot directory.
+// The snapshots no release is rendered into are removed when no release is selected by the selectors.
+func (a *App) WriteSnapshots(c SnapshotConfigProvider) error {
+	// The paths are resolved before visiting the state files, which changes the working directory to the ones of the sub-helmfiles
+	dir, err := filepath.Abs(c.SnapshotDir())
+	if err != nil {
+		return err
+	}
+
+	snapshots, envs, err := a.renderSnapshots(c)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range sortedSnapshotPaths(snapshots) {
+		file := filepath.Join(dir, p)
+
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			return err
+		}
+
+		a.Logger.Infof("Writing snapshot %s", file)
+
+		if err := os.WriteFile(file, snapshots[p], 0644); err != nil {
+			return err
+		}
+	}
+
+	if len(a.Selectors) > 0 || len(a.opts.HelmfileSelectors) > 0 {
+		return nil
+	}
+
+	obsolete, err := obsoleteSnapshots(dir, envs, snapshots)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range obsolete {
+		file := filepath.Join(dir, p)
+
+		a.Logger.Infof("Removing obsolete snapshot %s", file)
+
+		if err := os.Remove(file); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// VerifySnapshots renders the selected releases, and prints the diffs between their manifests and the snapshots.
+// It fails when any release is rendered differently from its snapshot or has no snapshot,
+// or when no release is rendered into a snapshot and no release is selected by the selectors.
+func (a *App) VerifySnapshots(c SnapshotConfigProvider) error {
+	dir, err := filepath.Abs(c.SnapshotDir())
+	if err != nil {
+		return err
+	}
+
+	snapshots, envs, err := a.renderSnapshots(c)
+	if err != nil {
+		return err
+	}
+
+	var mismatches []string
+
+	for _, p := range sortedSnapshotPaths(snapshots) {
+		file := filepath.Join(dir, p)
+
+		want, err := os.ReadFile(file)
+		if os.IsNotExist(err) {
+			mismatches = append(mismatches, p+": no snapshot")
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		if diff, changed := snapshotDiff(string(want), string(snapshots[p])); changed {
+			fmt.Fprintf(a.Stdout(), "--- %s (snapshot)\n+++ %s (rendered)\n%s", p, p, diff)
+			mismatches = append(mismatches, p+": rendered differently")
+		}
+	}
+
+	if len(a.Selectors) == 0 && len(a.opts.HelmfileSelectors) == 0 {
+		obsolete, err := obsoleteSnapshots(dir, envs, snapshots)
+		if err != nil {
+			return err
+		}
+
+		for _, p := range obsolete {
+			mismatches = append(mismatches, p+": no release is rendered into it")
+		}
+	}
+
+	if len(mismatches) > 0 {
+		return &errors.CatalogError{
+			Code: errors.CodeSnapshotMismatch,
+			Err: fmt.Errorf("%d snapshot(s) in %s don't match the rendered releases. Run `helmfile snapshot write` to update them if the changes are expected:\n%s",
+				len(mismatches), c.SnapshotDir(), strings.Join(mismatches, "\n")),
+		}
+	}
+
+	a.Logger.Infof("All the %d snapshot(s) in %s match the rendered releases", len(snapshots), c.SnapshotDir())
+
+	return nil
+}
+
+// snapshotDiff returns the lines of the diff between the snapshot and the rendered manifests with the unchanged lines around the changes,
+// and whether they differ or not
+func snapshotDiff(want, got string) (string, bool) {
+	records := difflib.Diff(strings.Split(want, "\n"), strings.Split(got, "\n"))
+
+	// distances is the distance of each line to the closest change
+	distances := make([]int, len(records))
+	last := -1
+	for i, r := range records {
+		if r.Delta != difflib.Common {
+			last = i
+		}
+		distances[i] = len(records)
+		if last >= 0 {
+			distances[i] = i - last
+		}
+	}
+	last = -1
+	for i := len(records) - 1; i >= 0; i-- {
+		if records[i].Delta != difflib.Common {
+			last = i
+		}
+		if last >= 0 && last-i < distances[i] {
+			distances[i] = last - i
+		}
+	}
+
+	var (
+		sb       strings.Builder
+		changed  bool
+		omitting bool
+	)
+
+	for i, r := range records {
+		if r.Delta != difflib.Common {
+			changed = true
+		}
+
+		if distances[i] > snapshotDiffContext {
+			if !omitting {
+				sb.WriteString("...\n")
+				omitting = true
+			}
+			continue
+		}
+		omitting = false
+
+		switch r.Delta {
+		case difflib.LeftOnly:
+			sb.WriteString("- ")
+		case difflib.RightOnly:
+			sb.WriteString("+ ")
+		default:
+			sb.WriteString("  ")
+		}
+		sb.WriteString(r.Payload)
+		sb.WriteString("\n")
+	}
+
+	return sb.String(), changed
+}
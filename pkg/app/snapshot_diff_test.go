@@ -0,0 +1,56 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/state"
+)
+
+func TestSnapshotPath(t *testing.T) {
+	require.Equal(t, filepath.FromSlash("web/frontend.yaml"), snapshotPath(&state.ReleaseSpec{Name: "frontend", Namespace: "web"}))
+	require.Equal(t, filepath.FromSlash("_/frontend.yaml"), snapshotPath(&state.ReleaseSpec{Name: "frontend"}))
+	require.Equal(t, filepath.FromSlash("prod/_/frontend.yaml"), snapshotPath(&state.ReleaseSpec{Name: "frontend", KubeContext: "prod"}))
+	require.Equal(t, filepath.FromSlash("prod/web/frontend.yaml"), snapshotPath(&state.ReleaseSpec{Name: "frontend", Namespace: "web", KubeContext: "prod"}))
+}
+
+func TestSnapshotDiff(t *testing.T) {
+	want := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+	got := "a\nb\nc\nd\ne\nF\ng\nh\ni\nj\n"
+
+	diff, changed := snapshotDiff(want, got)
+	require.True(t, changed)
+	require.Equal(t, `...
+  c
+  d
+  e
+- f
++ F
+  g
+  h
+  i
+...
+`, diff)
+
+	_, changed = snapshotDiff(want, want)
+	require.False(t, changed)
+}
+
+func TestObsoleteSnapshots(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, p := range []string{"default/web/frontend.yaml", "default/web/backend.yaml", "default/README.md", "prod/web/frontend.yaml"} {
+		file := filepath.Join(dir, filepath.FromSlash(p))
+		require.NoError(t, os.MkdirAll(filepath.Dir(file), 0755))
+		require.NoError(t, os.WriteFile(file, nil, 0644))
+	}
+
+	obsolete, err := obsoleteSnapshots(dir, map[string]bool{"default": true, "staging": true}, map[string][]byte{
+		filepath.FromSlash("default/web/frontend.yaml"): nil,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{filepath.FromSlash("default/web/backend.yaml")}, obsolete)
+}
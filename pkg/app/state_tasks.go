@@ -0,0 +1,163 @@
+package app
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// stateTasks is the releases of the states to be processed concurrently once all the states are loaded, like on `sync --state-concurrency 4`.
+//
+// The states are loaded one by one as usual, as each of them is rendered in its own directory,
+// and their releases are processed afterwards in the order of their tasks,
+// so that the states independent of each other are processed concurrently up to the concurrency.
+type stateTasks struct {
+	mu          sync.Mutex
+	concurrency int
+	tasks       []*stateTask
+}
+
+// stateTask processes the releases of a state after the ones of the states it depends on
+type stateTask struct {
+	// path is the absolute path to the state file
+	path string
+	// deps is the tasks of the sub-helmfiles of the state and of the helmfiles having the releases the state needs
+	deps []*stateTask
+	run  func() (bool, error)
+
+	done      chan struct{}
+	processed bool
+	err       error
+	// skipped is true when the task didn't run, as the run failed before it
+	skipped bool
+}
+
+// newStateTasks returns the state tasks of the command, which is nil unless the concurrency is more than 1 on the commands installing the releases
+func newStateTasks(command string, concurrency int) *stateTasks {
+	if concurrency <= 1 {
+		return nil
+	}
+
+	switch command {
+	case "sync", "apply":
+	default:
+		return nil
+	}
+
+	return &stateTasks{concurrency: concurrency}
+}
+
+// len returns the number of the tasks added so far, which marks the tasks added afterwards, like the ones of the sub-helmfiles of a state
+func (t *stateTasks) len() int {
+	if t == nil {
+		return 0
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return len(t.tasks)
+}
+
+// add adds the task of the state at the path, depending on the tasks since the index, which are the ones of its sub-helmfiles,
+// and the tasks of the helmfiles at the needed paths, which may be the directories of the state files
+func (t *stateTasks) add(path string, since int, needed []string, run func() (bool, error)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	task := &stateTask{path: path, run: run, done: make(chan struct{})}
+
+	deps := map[*stateTask]bool{}
+
+	for _, d := range t.tasks[since:] {
+		deps[d] = true
+	}
+
+	for _, p := range needed {
+		for _, d := range t.tasks {
+			if d.path == p || filepath.Dir(d.path) == p {
+				deps[d] = true
+			}
+		}
+	}
+
+	for _, d := range t.tasks {
+		if deps[d] {
+			task.deps = append(task.deps, d)
+		}
+	}
+
+	t.tasks = append(t.tasks, task)
+}
+
+// run runs the tasks up to the concurrency, each once all the tasks it depends on are done.
+// No task starts once any of them fails, and the error of the first task failed in the order of the tasks is returned.
+func (t *stateTasks) run(logger *zap.SugaredLogger) (bool, error) {
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed bool
+	)
+
+	slots := make(chan struct{}, t.concurrency)
+
+	for _, task := range t.tasks {
+		wg.Add(1)
+
+		go func(task *stateTask) {
+			defer wg.Done()
+			defer close(task.done)
+
+			for _, d := range task.deps {
+				<-d.done
+			}
+
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			mu.Lock()
+			task.skipped = failed
+			mu.Unlock()
+
+			if task.skipped {
+				logger.Debugf("skipping the releases of %s, as processing the releases of another helmfile failed", task.path)
+				return
+			}
+
+			logger.Debugf("processing the releases of %s", task.path)
+
+			task.processed, task.err = task.run()
+
+			if task.err != nil {
+				mu.Lock()
+				failed = true
+				mu.Unlock()
+			}
+		}(task)
+	}
+
+	wg.Wait()
+
+	var (
+		processed bool
+		err       error
+	)
+
+	for _, task := range t.tasks {
+		processed = processed || task.processed
+
+		if task.err == nil {
+			continue
+		}
+
+		if err == nil {
+			err = appError(fmt.Sprintf("in %s", task.path), task.err)
+		} else {
+			logger.Errorf("in %s: %v", task.path, task.err)
+		}
+	}
+
+	return processed, err
+}
@@ -0,0 +1,100 @@
+package app
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/helmexec"
+)
+
+func TestNewStateTasks(t *testing.T) {
+	require.Nil(t, newStateTasks("sync", 1))
+	require.Nil(t, newStateTasks("diff", 4))
+	require.NotNil(t, newStateTasks("apply", 4))
+}
+
+func TestStateTasks_Run(t *testing.T) {
+	logger := helmexec.NewLogger(io.Discard, "debug")
+
+	tasks := newStateTasks("sync", 2)
+
+	var (
+		mu    sync.Mutex
+		order []string
+	)
+
+	record := func(name string) {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, name)
+	}
+
+	platformStarted := make(chan struct{})
+
+	// The releases of platform and monitoring are processed concurrently, as they are independent of each other
+	tasks.add("/infra/platform/helmfile.yaml", tasks.len(), nil, func() (bool, error) {
+		close(platformStarted)
+		record("platform")
+		return true, nil
+	})
+	tasks.add("/infra/monitoring/helmfile.yaml", tasks.len(), nil, func() (bool, error) {
+		select {
+		case <-platformStarted:
+		case <-time.After(10 * time.Second):
+			return false, errors.New("platform wasn't processed concurrently")
+		}
+		record("monitoring")
+		return true, nil
+	})
+	tasks.add("/infra/apps/helmfile.yaml", tasks.len(), []string{"/infra/platform"}, func() (bool, error) {
+		record("apps")
+		return false, nil
+	})
+	tasks.add("/infra/helmfile.yaml", 0, nil, func() (bool, error) {
+		record("root")
+		return false, nil
+	})
+
+	processed, err := tasks.run(logger)
+	require.NoError(t, err)
+	require.True(t, processed)
+
+	require.Len(t, order, 4)
+	require.Equal(t, "root", order[3])
+	require.Less(t, indexOf(order, "platform"), indexOf(order, "apps"))
+}
+
+func TestStateTasks_RunFailed(t *testing.T) {
+	logger := helmexec.NewLogger(io.Discard, "debug")
+
+	tasks := newStateTasks("apply", 4)
+
+	var ran []string
+
+	tasks.add("/infra/platform/helmfile.yaml", tasks.len(), nil, func() (bool, error) {
+		ran = append(ran, "platform")
+		return true, errors.New("release \"ingress\" failed")
+	})
+	tasks.add("/infra/apps/helmfile.yaml", tasks.len(), []string{"/infra/platform/helmfile.yaml"}, func() (bool, error) {
+		ran = append(ran, "apps")
+		return true, nil
+	})
+
+	_, err := tasks.run(logger)
+	require.EqualError(t, err, `in /infra/platform/helmfile.yaml: release "ingress" failed`)
+	require.Equal(t, []string{"platform"}, ran)
+}
+
+func indexOf(items []string, item string) int {
+	for i, v := range items {
+		if v == item {
+			return i
+		}
+	}
+	return -1
+}
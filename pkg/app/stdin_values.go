@@ -0,0 +1,128 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/helmfile/helmfile/pkg/yaml"
+)
+
+// stdinValuesPath is the path to --values and --state-values-file to read the values from stdin
+const stdinValuesPath = "-"
+
+// stdinValues is the values read from stdin.
+// The stdin is read only once, as helmfile loads the states and runs helm many times in a run.
+type stdinValues struct {
+	reader io.Reader
+
+	once sync.Once
+	data []byte
+	err  error
+
+	mu   sync.Mutex
+	file string
+}
+
+func newStdinValues(r io.Reader) *stdinValues {
+	return &stdinValues{reader: r}
+}
+
+func (v *stdinValues) read() ([]byte, error) {
+	v.once.Do(func() {
+		v.data, v.err = io.ReadAll(v.reader)
+		if v.err != nil {
+			v.err = fmt.Errorf("reading values from stdin: %v", v.err)
+		}
+	})
+
+	return v.data, v.err
+}
+
+// values returns the values read from stdin
+func (v *stdinValues) values() (map[string]interface{}, error) {
+	bs, err := v.read()
+	if err != nil {
+		return nil, err
+	}
+
+	vals := map[string]interface{}{}
+	if err := yaml.Unmarshal(bs, &vals); err != nil {
+		return nil, fmt.Errorf("parsing values from stdin: %v", err)
+	}
+
+	return vals, nil
+}
+
+// valuesFiles returns the values files with `-` replaced by the file containing the values read from stdin.
+// The file is only readable by the current user and is removed by cleanup.
+func (v *stdinValues) valuesFiles(files []string) ([]string, error) {
+	var resolved []string
+
+	for _, f := range files {
+		if f != stdinValuesPath {
+			resolved = append(resolved, f)
+			continue
+		}
+
+		path, err := v.writeFile()
+		if err != nil {
+			return nil, err
+		}
+
+		resolved = append(resolved, path)
+	}
+
+	return resolved, nil
+}
+
+func (v *stdinValues) writeFile() (string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.file != "" {
+		return v.file, nil
+	}
+
+	bs, err := v.read()
+	if err != nil {
+		return "", err
+	}
+
+	// os.CreateTemp creates the file with the mode 0600
+	f, err := os.CreateTemp("", "helmfile-stdin-values-*.yaml")
+	if err != nil {
+		return "", fmt.Errorf("writing values from stdin: %v", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	if _, err := f.Write(bs); err != nil {
+		_ = os.Remove(f.Name())
+		return "", fmt.Errorf("writing values from stdin: %v", err)
+	}
+
+	v.file = f.Name()
+
+	return v.file, nil
+}
+
+func (v *stdinValues) cleanup() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.file != "" {
+		_ = os.Remove(v.file)
+		v.file = ""
+	}
+}
+
+func (a *App) getStdinValues() *stdinValues {
+	if a.stdin == nil {
+		a.stdin = newStdinValues(os.Stdin)
+	}
+
+	return a.stdin
+}
@@ -0,0 +1,39 @@
+package app
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStdinValues(t *testing.T) {
+	v := newStdinValues(strings.NewReader("tag: v1.2.3\n"))
+
+	vals, err := v.values()
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"tag": "v1.2.3"}, vals)
+
+	files, err := v.valuesFiles([]string{"values.yaml", "-"})
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	require.Equal(t, "values.yaml", files[0])
+
+	// The stdin is read only once, and the same file is reused
+	again, err := v.valuesFiles([]string{"-"})
+	require.NoError(t, err)
+	require.Equal(t, files[1], again[0])
+
+	bs, err := os.ReadFile(files[1])
+	require.NoError(t, err)
+	require.Equal(t, "tag: v1.2.3\n", string(bs))
+
+	info, err := os.Stat(files[1])
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	v.cleanup()
+	_, err = os.Stat(files[1])
+	require.True(t, os.IsNotExist(err))
+}
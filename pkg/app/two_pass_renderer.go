@@ -22,9 +22,14 @@ func prependLineNumbers(text string) string {
 	return buf.String()
 }
 
-func (r *desiredStateLoader) renderPrestate(firstPassEnv *environment.Environment, baseDir, filename string, content []byte) (*environment.Environment, *state.HelmState) {
+func (r *desiredStateLoader) renderPrestate(firstPassEnv *environment.Environment, baseDir, filename string, content []byte, templateLibraries []string) (*environment.Environment, *state.HelmState) {
 	tmplData := state.NewEnvironmentTemplateData(*firstPassEnv, r.namespace, map[string]interface{}{})
+	tmplData.KubeContext = r.overrideKubeContext
+	tmplData.Files = tmpl.NewFiles(r.fs, baseDir)
 	firstPassRenderer := tmpl.NewFirstPassRenderer(baseDir, tmplData)
+	firstPassRenderer.Context.SetTemplateLibraries(templateLibraries)
+	firstPassRenderer.Context.SetHelmBinary(r.overrideHelmBinary)
+	firstPassRenderer.Context.SetClusterLookups(r.clusterLookups)
 
 	// parse as much as we can, tolerate errors, this is a preparse
 	yamlBuf, err := firstPassRenderer.RenderTemplateContentToBuffer(content)
@@ -70,14 +75,14 @@ type RenderOpts struct {
 func (r *desiredStateLoader) renderTemplatesToYaml(baseDir, filename string, content []byte) (*bytes.Buffer, error) {
 	env := &environment.Environment{Name: r.env, Values: map[string]interface{}(nil)}
 
-	return r.renderTemplatesToYamlWithEnv(baseDir, filename, content, env, nil)
+	return r.renderTemplatesToYamlWithEnv(baseDir, filename, content, env, nil, nil)
 }
 
-func (r *desiredStateLoader) renderTemplatesToYamlWithEnv(baseDir, filename string, content []byte, inherited, overrode *environment.Environment) (*bytes.Buffer, error) {
-	return r.twoPassRenderTemplateToYaml(inherited, overrode, baseDir, filename, content)
+func (r *desiredStateLoader) renderTemplatesToYamlWithEnv(baseDir, filename string, content []byte, inherited, overrode *environment.Environment, templateLibraries []string) (*bytes.Buffer, error) {
+	return r.twoPassRenderTemplateToYaml(inherited, overrode, baseDir, filename, content, templateLibraries)
 }
 
-func (r *desiredStateLoader) twoPassRenderTemplateToYaml(inherited, overrode *environment.Environment, baseDir, filename string, content []byte) (*bytes.Buffer, error) {
+func (r *desiredStateLoader) twoPassRenderTemplateToYaml(inherited, overrode *environment.Environment, baseDir, filename string, content []byte, templateLibraries []string) (*bytes.Buffer, error) {
 	// try a first pass render. This will always succeed, but can produce a limited env
 	var phase string
 	if !runtime.V1Mode {
@@ -94,6 +99,8 @@ func (r *desiredStateLoader) twoPassRenderTemplateToYaml(inherited, overrode *en
 		renderingPhase string
 		finalEnv       *environment.Environment
 		vals           map[string]interface{}
+		// helmBinary is the one of the state deduced by the first pass, unless it's overridden by --helm-binary
+		helmBinary = r.overrideHelmBinary
 	)
 
 	if runtime.V1Mode {
@@ -108,7 +115,7 @@ func (r *desiredStateLoader) twoPassRenderTemplateToYaml(inherited, overrode *en
 	} else {
 		r.logger.Debugf("first-pass uses: %v", initEnv)
 
-		renderedEnv, prestate := r.renderPrestate(initEnv, baseDir, filename, content)
+		renderedEnv, prestate := r.renderPrestate(initEnv, baseDir, filename, content, templateLibraries)
 
 		r.logger.Debugf("first-pass produced: %v", renderedEnv)
 
@@ -135,12 +142,18 @@ func (r *desiredStateLoader) twoPassRenderTemplateToYaml(inherited, overrode *en
 
 		if prestate != nil {
 			prestate.Env = *mergedEnv
+			helmBinary = prestate.DefaultHelmBinary
 			r.logger.Debugf("vals:\n%v\ndefaultVals:%v", vals, prestate.DefaultValues)
 		}
 	}
 
 	tmplData := state.NewEnvironmentTemplateData(*finalEnv, r.namespace, vals)
+	tmplData.KubeContext = r.overrideKubeContext
+	tmplData.Files = tmpl.NewFiles(r.fs, baseDir)
 	renderer := tmpl.NewFileRenderer(r.fs, baseDir, tmplData)
+	renderer.Context.SetTemplateLibraries(templateLibraries)
+	renderer.Context.SetHelmBinary(helmBinary)
+	renderer.Context.SetClusterLookups(r.clusterLookups)
 	yamlBuf, err := renderer.RenderTemplateContentToBuffer(content)
 	if err != nil {
 		r.logger.Debugf("%srendering failed, input of \"%s\":\n%s", renderingPhase, filename, prependLineNumbers(string(content)))
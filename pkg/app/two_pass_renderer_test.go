@@ -2,6 +2,7 @@ package app
 
 import (
 	"bytes"
+	"errors"
 	"strings"
 	"testing"
 
@@ -301,3 +302,74 @@ releases:
 		t.Fatalf("wanted error, none returned")
 	}
 }
+
+func TestLoad_TemplateLibraries(t *testing.T) {
+	files := map[string]string{
+		"/path/to/helmfile.yaml": `templateLibraries:
+- lib
+---
+releases:
+- name: web
+  {{- include "release.defaults" (dict "chart" "./charts/web") | nindent 2 }}
+`,
+		"/path/to/lib/_release.tpl": `{{ define "release.defaults" }}namespace: web
+chart: {{ .chart }}{{ end }}`,
+	}
+
+	r, _, _ := makeLoader(files, "default")
+	st, err := r.Load("/path/to/helmfile.yaml", LoadOpts{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(st.Releases) != 1 || st.Releases[0].Namespace != "web" || st.Releases[0].Chart != "./charts/web" {
+		t.Errorf("unexpected releases: %+v", st.Releases)
+	}
+
+	files["/path/to/helmfile.yaml"] = "templateLibraries:\n- missing\n"
+
+	r, _, _ = makeLoader(files, "default")
+	_, err = r.Load("/path/to/helmfile.yaml", LoadOpts{})
+	if err == nil || err.Error() != "template library missing: /path/to/missing is not a directory" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestLoad_RequiredErrorLocation(t *testing.T) {
+	files := map[string]string{
+		"/path/to/helmfile.yaml": `environments:
+  default:
+    values:
+    - domain: ""
+---
+releases:
+- name: web
+  chart: ./charts/web
+  values:
+  - domain: {{ .Values.domain | required "the domain of the environment is required" }}
+`,
+	}
+
+	r, _, _ := makeLoader(files, "default")
+	_, err := r.Load("/path/to/helmfile.yaml", LoadOpts{})
+	if err == nil || err.Error() != "/path/to/helmfile.yaml, document 2, line 10: the domain of the environment is required" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var renderErr *StateRenderError
+	if !errors.As(err, &renderErr) || renderErr.Document != 2 || renderErr.Line != 10 {
+		t.Errorf("unexpected error location: %+v", renderErr)
+	}
+
+	files["/path/to/helmfile.yaml"] = `releases:
+- name: web
+  chart: ./charts/web
+  installed: {{ .Values.missing.installed }}
+`
+
+	r, _, _ = makeLoader(files, "default")
+	_, err = r.Load("/path/to/helmfile.yaml", LoadOpts{})
+	if err == nil || !strings.HasPrefix(err.Error(), "/path/to/helmfile.yaml, document 1, line 4: template: stringTemplate:4:") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
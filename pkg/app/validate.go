@@ -0,0 +1,116 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/helmfile/helmfile/pkg/argparser"
+	"github.com/helmfile/helmfile/pkg/state"
+)
+
+// Validate renders the manifests of the selected releases like `helmfile template`,
+// validates them against the schemas of the Kubernetes resources, and checks them against the rego policies if any,
+// failing when any violation other than the warnings of the policies is found.
+func (a *App) Validate(c ValidateConfigProvider) error {
+	var violations []state.Violation
+
+	err := a.ForEachState(func(run *Run) (ok bool, errs []error) {
+		// The CustomResourceDefinitions are validated as well
+		includeCRDs := true
+
+		run.helm.SetEnableLiveOutput(false)
+
+		prepErr := run.withPreparedCharts("template", state.ChartPrepareOptions{
+			SkipRepos:   c.SkipDeps(),
+			SkipDeps:    c.SkipDeps(),
+			IncludeCRDs: &includeCRDs,
+			Concurrency: c.Concurrency(),
+		}, func() {
+			var vs []state.Violation
+			vs, ok, errs = a.validate(run, c)
+			violations = append(violations, vs...)
+		})
+
+		if prepErr != nil {
+			errs = append(errs, prepErr)
+		}
+
+		return
+	}, false)
+	if err != nil {
+		return err
+	}
+
+	if c.Output() == "json" {
+		err = FormatAsJson(a.Stdout(), violations)
+	} else {
+		err = FormatViolationsAsTable(a.Stdout(), violations)
+	}
+	if err != nil {
+		return err
+	}
+
+	var failed int
+	releases := map[string]bool{}
+	for _, v := range violations {
+		if !v.Warning {
+			failed++
+			releases[v.KubeContext+"/"+v.Namespace+"/"+v.Release] = true
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("found %d violations in %d releases", failed, len(releases))
+	}
+
+	return nil
+}
+
+func (a *App) validate(r *Run, c ValidateConfigProvider) ([]state.Violation, bool, []error) {
+	valuesFiles, err := r.ctx.ValuesFiles(c.Values())
+	if err != nil {
+		return nil, false, []error{err}
+	}
+
+	var violations []state.Violation
+
+	opts := state.ValidateOpts{
+		SchemaLocations:      c.SchemaLocations(),
+		Strict:               c.Strict(),
+		IgnoreMissingSchemas: c.IgnoreMissingSchemas(),
+		Policies:             c.Policies(),
+	}
+
+	ok, errs := a.withNeeds(r, c, false, func(st *state.HelmState) []error {
+		helm := r.helm
+
+		args := argparser.GetArgs(c.Args(), st)
+
+		// Reset the extra args if already set, not to break `helm fetch` by adding the args intended for `lint`
+		helm.SetExtraArgs()
+
+		if len(args) > 0 {
+			helm.SetExtraArgs(args...)
+		}
+
+		var errs []error
+
+		templateOpts := &state.TemplateOpts{
+			Set:         c.Set(),
+			IncludeCRDs: true,
+			OnManifests: func(release *state.ReleaseSpec, manifests []byte) {
+				vs, err := st.ValidateManifests(release, manifests, opts)
+				if err != nil {
+					errs = append(errs, err)
+					return
+				}
+				violations = append(violations, vs...)
+			},
+		}
+
+		templateErrs := st.TemplateReleases(helm, "", valuesFiles, args, c.Concurrency(), false, templateOpts)
+
+		return append(templateErrs, errs...)
+	})
+
+	return violations, ok, errs
+}
@@ -0,0 +1,316 @@
+package app
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/helmfile/helmfile/pkg/state"
+)
+
+// watchedRelease is a release `helmfile watch` re-runs the command on when any of its files changes
+type watchedRelease struct {
+	id string
+	// selector is the selector matching only the release, like `name=web,namespace=default`
+	selector string
+	// stateFile is the absolute path to the state file the release is defined in
+	stateFile string
+	// paths are the absolute paths to the values files and the local chart directory of the release
+	paths []string
+}
+
+// watchedInputs are the files `helmfile watch` checks for changes
+type watchedInputs struct {
+	// stateFiles are the absolute paths to the state files, whose changes affect all the releases
+	stateFiles []string
+	releases   []watchedRelease
+}
+
+func (in watchedInputs) paths() []string {
+	paths := append([]string{}, in.stateFiles...)
+	for _, r := range in.releases {
+		paths = append(paths, r.paths...)
+	}
+	return paths
+}
+
+// affectedSelectors returns the selectors of the releases affected by the changed paths.
+// It returns nil when all the releases are affected, as a state file changed.
+func (in watchedInputs) affectedSelectors(changed []string) []string {
+	isChanged := map[string]bool{}
+	for _, p := range changed {
+		isChanged[p] = true
+	}
+
+	for _, p := range in.stateFiles {
+		if isChanged[p] {
+			return nil
+		}
+	}
+
+	selectors := []string{}
+	for _, r := range in.releases {
+		for _, p := range r.paths {
+			if isChanged[p] || isChangedWithin(p, changed) {
+				selectors = append(selectors, r.selector)
+				break
+			}
+		}
+	}
+
+	return selectors
+}
+
+// feeds returns true if the file is one of the values files of the release, or in its local chart
+func (r watchedRelease) feeds(file string) bool {
+	for _, p := range r.paths {
+		if file == p || isChangedWithin(p, []string{file}) {
+			return true
+		}
+	}
+	return false
+}
+
+// releaseSelector returns the selector matching only the release
+func releaseSelector(r *state.ReleaseSpec) string {
+	selector := "name=" + r.Name
+	if r.Namespace != "" {
+		selector += ",namespace=" + r.Namespace
+	}
+	return selector
+}
+
+// isChangedWithin returns true if any of the changed paths is in the directory
+func isChangedWithin(dir string, changed []string) bool {
+	for _, p := range changed {
+		if strings.HasPrefix(p, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// stampFiles returns the modification times and the sizes of the files, including the ones in the directories.
+// The files that don't exist are omitted, so that their creations are detected as changes too.
+func stampFiles(paths []string) map[string]fileStamp {
+	stamps := map[string]fileStamp{}
+
+	for _, p := range paths {
+		_ = filepath.WalkDir(p, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+
+			stamps[path] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+
+			return nil
+		})
+	}
+
+	return stamps
+}
+
+// changedFiles returns the files created, modified or removed between the two stamps, sorted by path
+func changedFiles(prev, cur map[string]fileStamp) []string {
+	var changed []string
+
+	for p, s := range cur {
+		if ps, ok := prev[p]; !ok || ps != s {
+			changed = append(changed, p)
+		}
+	}
+
+	for p := range prev {
+		if _, ok := cur[p]; !ok {
+			changed = append(changed, p)
+		}
+	}
+
+	sort.Strings(changed)
+
+	return changed
+}
+
+// collectWatchedInputs loads the states to find the files the selected releases depend on
+func (a *App) collectWatchedInputs() (watchedInputs, error) {
+	var inputs watchedInputs
+
+	err := a.ForEachState(func(run *Run) (bool, []error) {
+		st := run.state
+
+		stateFile, err := st.FullFilePath()
+		if err != nil {
+			return false, []error{err}
+		}
+		inputs.stateFiles = append(inputs.stateFiles, stateFile)
+
+		selected, _, err := a.getSelectedReleases(run, false)
+		if err != nil {
+			return false, []error{err}
+		}
+
+		for _, r := range selected {
+			release := r
+
+			var files []string
+			for _, v := range append(append([]interface{}{}, r.Values...), r.Secrets...) {
+				if f, ok := v.(string); ok && !strings.Contains(f, "://") && !strings.Contains(f, "{{") {
+					files = append(files, f)
+				}
+			}
+			if a.fs.DirectoryExistsAt(r.Chart) {
+				files = append(files, r.Chart)
+			}
+
+			var paths []string
+			for _, f := range files {
+				abs, err := a.fs.Abs(f)
+				if err != nil {
+					return false, []error{err}
+				}
+				paths = append(paths, abs)
+			}
+
+			inputs.releases = append(inputs.releases, watchedRelease{
+				id:        state.ReleaseToID(&release),
+				selector:  releaseSelector(&release),
+				stateFile: stateFile,
+				paths:     paths,
+			})
+		}
+
+		return true, nil
+	}, false, SetFilter(true), SetSkipRunHooks(true))
+
+	return inputs, err
+}
+
+// Watch runs the command, and then re-runs it on the releases affected by the changes of the state files,
+// the values files and the local charts, each time they are detected.
+// The files are checked for changes at the interval, until the process is terminated.
+// With the drift interval, the diff is re-run on all the selected releases at the interval too, to report the drifts of the releases.
+func (a *App) Watch(c WatchConfigProvider, run func() error) error {
+	ticker := time.NewTicker(c.Interval())
+	defer ticker.Stop()
+
+	var driftTicks <-chan time.Time
+	if c.DriftInterval() > 0 {
+		driftTicker := time.NewTicker(c.DriftInterval())
+		defer driftTicker.Stop()
+		driftTicks = driftTicker.C
+	}
+
+	return a.watch(run, ticker.C, driftTicks, newDriftDetector(c))
+}
+
+func (a *App) watch(run func() error, ticks, driftTicks <-chan time.Time, drift *driftDetector) error {
+	inputs, err := a.collectWatchedInputs()
+	if err != nil {
+		return err
+	}
+
+	if err := a.runWatched(run, nil, drift); err != nil {
+		return err
+	}
+
+	stamps := stampFiles(inputs.paths())
+
+	a.Logger.Infof("Watching %d files for changes", len(stamps))
+
+	for {
+		select {
+		case <-a.runContext().Done():
+			return nil
+		case <-driftTicks:
+			if err := a.runWatched(run, nil, drift); err != nil {
+				return err
+			}
+			continue
+		case _, ok := <-ticks:
+			if !ok {
+				return nil
+			}
+		}
+
+		cur := stampFiles(inputs.paths())
+
+		changed := changedFiles(stamps, cur)
+		if len(changed) == 0 {
+			continue
+		}
+
+		a.Logger.Infof("Detected changes in %s", strings.Join(changed, ", "))
+
+		selectors := inputs.affectedSelectors(changed)
+		if selectors == nil || len(selectors) > 0 {
+			if err := a.runWatched(run, selectors, drift); err != nil {
+				return err
+			}
+		}
+
+		// The releases and their files may have changed along with the state files
+		if updated, err := a.collectWatchedInputs(); err != nil {
+			a.Logger.Errorf("Failed to reload the state files: %v", err)
+		} else {
+			inputs = updated
+		}
+
+		stamps = stampFiles(inputs.paths())
+	}
+}
+
+// runWatched runs the command on the releases selected by the selectors, or on all the selected releases when they are nil.
+// The error of the command is logged rather than returned, so that it can be fixed while watching.
+// The releases with changes of the diffs of all the selected releases are reported as the drifts to the drift detector, if any,
+// whose error to stop watching is returned.
+func (a *App) runWatched(run func() error, selectors []string, drift *driftDetector) error {
+	if selectors != nil {
+		prev := a.Selectors
+		a.Selectors = selectors
+		defer func() {
+			a.Selectors = prev
+		}()
+	}
+
+	var (
+		diffed  bool
+		changed []ChangedRelease
+	)
+
+	a.onDiffed = func(rs []ChangedRelease) {
+		diffed = true
+		changed = rs
+	}
+	defer func() {
+		a.onDiffed = nil
+	}()
+
+	if err := run(); err != nil && !isChangesIdentified(err) {
+		a.Logger.Errorf("%v", err)
+		return nil
+	}
+
+	if drift == nil || selectors != nil || !diffed {
+		return nil
+	}
+
+	return drift.detect(a.Logger, changed)
+}
+
+// isChangesIdentified returns true if the error is the one diff returns with the exit code 2 on changes
+func isChangesIdentified(err error) bool {
+	e, ok := err.(*Error)
+	return ok && e.Errors == nil && e.Code() == 2
+}
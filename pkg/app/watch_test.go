@@ -0,0 +1,54 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchedInputs_affectedSelectors(t *testing.T) {
+	inputs := watchedInputs{
+		stateFiles: []string{"/work/helmfile.yaml"},
+		releases: []watchedRelease{
+			{selector: "name=web,namespace=default", paths: []string{"/work/values/web.yaml", "/work/charts/web"}},
+			{selector: "name=db", paths: []string{"/work/values/db.yaml"}},
+			{selector: "name=cache"},
+		},
+	}
+
+	require.Equal(t, []string{"name=db"}, inputs.affectedSelectors([]string{"/work/values/db.yaml"}))
+	require.Equal(t, []string{"name=web,namespace=default"}, inputs.affectedSelectors([]string{"/work/charts/web/templates/deployment.yaml"}))
+	require.Equal(t, []string{}, inputs.affectedSelectors([]string{"/work/charts/webhook/Chart.yaml"}))
+	require.Nil(t, inputs.affectedSelectors([]string{"/work/helmfile.yaml", "/work/values/db.yaml"}), "all the releases should be affected by the state file")
+}
+
+func TestChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	values := filepath.Join(dir, "values.yaml")
+	require.NoError(t, os.WriteFile(values, []byte("replicas: 1\n"), 0644))
+
+	chart := filepath.Join(dir, "chart")
+	require.NoError(t, os.MkdirAll(filepath.Join(chart, "templates"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(chart, "Chart.yaml"), []byte("name: chart\n"), 0644))
+
+	paths := []string{values, chart, filepath.Join(dir, "missing.yaml")}
+
+	prev := stampFiles(paths)
+	require.Len(t, prev, 2)
+	require.Empty(t, changedFiles(prev, stampFiles(paths)))
+
+	require.NoError(t, os.WriteFile(values, []byte("replicas: 2\n"), 0644))
+	require.NoError(t, os.Chtimes(values, time.Now(), time.Now().Add(time.Minute)))
+	require.NoError(t, os.WriteFile(filepath.Join(chart, "templates", "cm.yaml"), []byte("kind: ConfigMap\n"), 0644))
+	require.NoError(t, os.Remove(filepath.Join(chart, "Chart.yaml")))
+
+	require.Equal(t, []string{
+		filepath.Join(chart, "Chart.yaml"),
+		filepath.Join(chart, "templates", "cm.yaml"),
+		values,
+	}, changedFiles(prev, stampFiles(paths)))
+}
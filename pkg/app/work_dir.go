@@ -0,0 +1,38 @@
+package app
+
+import (
+	"fmt"
+	"os"
+)
+
+// workDirPattern is the pattern of the names of the working directories of the runs
+const workDirPattern = "helmfile-run-*"
+
+// createWorkDir creates the working directory of a run in TempDir,
+// so that the temporary files of the runs sharing TempDir, like the concurrent runs on a CI runner, never collide
+func (a *App) createWorkDir() (string, error) {
+	if a.opts.TempDir != "" {
+		if err := os.MkdirAll(a.opts.TempDir, 0700); err != nil {
+			return "", fmt.Errorf("creating the temporary directory %s: %w", a.opts.TempDir, err)
+		}
+	}
+
+	dir, err := os.MkdirTemp(a.opts.TempDir, workDirPattern)
+	if err != nil {
+		return "", fmt.Errorf("creating the working directory: %w", err)
+	}
+
+	return dir, nil
+}
+
+// removeWorkDir removes the working directory of a run along with the temporary files in it, unless KeepTempFiles is set
+func (a *App) removeWorkDir(dir string) {
+	if a.opts.KeepTempFiles {
+		a.Logger.Infof("Kept the temporary files in %s", dir)
+		return
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		a.Logger.Warnf("warn: removing the working directory %s: %v", dir, err)
+	}
+}
@@ -0,0 +1,53 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/testhelper"
+)
+
+func TestForEachState_WorkDir(t *testing.T) {
+	files := map[string]string{
+		"/path/to/helmfile.yaml": `
+releases:
+- name: myrelease1
+  chart: mychart1
+`,
+	}
+
+	for _, keep := range []bool{false, true} {
+		tempDir := filepath.Join(t.TempDir(), "tmp")
+
+		a := NewWithOptions(Options{
+			FileOrDir:     "/path/to/helmfile.yaml",
+			KubeContext:   "default",
+			Logger:        newAppTestLogger(),
+			FileSystem:    testhelper.NewTestFs(files).ToFileSystem(),
+			TempDir:       tempDir,
+			KeepTempFiles: keep,
+		})
+
+		expectNoCallsToHelm(a)
+
+		var workDir string
+
+		err := a.ForEachState(func(run *Run) (bool, []error) {
+			workDir = run.state.WorkDir
+			return true, nil
+		}, false)
+		require.NoError(t, err)
+
+		require.Equal(t, tempDir, filepath.Dir(workDir), "the working directory must be created in the temporary directory")
+
+		_, err = os.Stat(workDir)
+		if keep {
+			require.NoError(t, err, "the working directory must be kept with KeepTempFiles")
+		} else {
+			require.True(t, os.IsNotExist(err), "the working directory must be removed at the end of the run")
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package config
+
+import "errors"
+
+// AffectedOptions is the options for the affected command
+type AffectedOptions struct {
+	// Release is the release to find the dependents of, referenced like in needs
+	Release string
+	// Since is the git ref to find the releases whose inputs changed since
+	Since string
+	// Output is the output format
+	Output string
+}
+
+// NewAffectedOptions creates a new AffectedOptions
+func NewAffectedOptions() *AffectedOptions {
+	return &AffectedOptions{}
+}
+
+// AffectedImpl is impl for AffectedOptions
+type AffectedImpl struct {
+	*GlobalImpl
+	*AffectedOptions
+}
+
+// NewAffectedImpl creates a new AffectedImpl
+func NewAffectedImpl(g *GlobalImpl, b *AffectedOptions) *AffectedImpl {
+	return &AffectedImpl{
+		GlobalImpl:      g,
+		AffectedOptions: b,
+	}
+}
+
+// ValidateConfig validates the affected options
+func (a *AffectedImpl) ValidateConfig() error {
+	if (a.AffectedOptions.Release == "") == (a.AffectedOptions.Since == "") {
+		return errors.New("either --release or --since is required")
+	}
+	return a.GlobalImpl.ValidateConfig()
+}
+
+// Release returns the release to find the dependents of
+func (a *AffectedImpl) Release() string {
+	return a.AffectedOptions.Release
+}
+
+// Output returns the output format
+func (a *AffectedImpl) Output() string {
+	return a.AffectedOptions.Output
+}
+
+// Since returns the git ref to find the releases whose inputs changed since
+func (a *AffectedImpl) Since() string {
+	return a.AffectedOptions.Since
+}
@@ -1,5 +1,9 @@
 package config
 
+import (
+	"github.com/spf13/cobra"
+)
+
 // ApplyOptoons is the options for the apply command
 type ApplyOptions struct {
 	// Set is a list of key value pairs to be merged into the command
@@ -8,6 +12,8 @@ type ApplyOptions struct {
 	Values []string
 	// Concurrency is the maximum number of concurrent helm processes to run
 	Concurrency int
+	// StateConcurrency is the maximum number of helmfiles whose releases are processed concurrently
+	StateConcurrency int
 	// Validate is validate your manifests against the Kubernetes cluster you are currently pointing at. Note that this requires access to a Kubernetes cluster to obtain information necessary for validating, like the list of available API versions
 	Validate bool
 	// Context is the number of lines of context to show around changes
@@ -16,6 +22,12 @@ type ApplyOptions struct {
 	Output string
 	// DetailedExitcode is true if the exit code should be 2 instead of 0 if there were changes detected and the changes were synced successfully
 	DetailedExitcode bool
+	// ChangedReleasesFile is the file to write the releases with changes to
+	ChangedReleasesFile string
+	// DiffOutputDir is the directory to write the diff of each release to before upgrading
+	DiffOutputDir string
+	// DiffOutputFileTemplate is the go text template for the path of each release's diff file in DiffOutputDir
+	DiffOutputFileTemplate string
 
 	// TODO: Remove this function once Helmfile v0.x
 	// DEPRECATED: Use skip-cleanup instead
@@ -51,12 +63,26 @@ type ApplyOptions struct {
 	Wait bool
 	// WaitForJobs is true if the helm command should wait for the jobs to be completed
 	WaitForJobs bool
+	// Atomic is the atomic flag, which overrides the atomic of the releases
+	Atomic bool
+	// CleanupOnFail is the cleanup on fail flag, which overrides the cleanupOnFail of the releases
+	CleanupOnFail bool
+	// ReleaseTimeout is the timeout in seconds, which overrides the timeout of the releases
+	ReleaseTimeout int
 	// ReuseValues is true if the helm command should reuse the values
 	ReuseValues bool
 	// ResetValues is true if helm command should reset values to charts' default
 	ResetValues bool
 	// Propagate '--post-renderer' to helmv3 template and helm install
 	PostRenderer string
+	// KubeVersionCheck is the kube-version-check flag
+	KubeVersionCheck string
+	// DiffRenderer is the diff-renderer flag
+	DiffRenderer string
+	// DryRun prints the hooks that would run without running any hook nor changing the releases
+	DryRun bool
+	// InteractivePlan prints the plan of the changes to the releases, and asks for its approval at once before applying it
+	InteractivePlan bool
 }
 
 // NewApply creates a new Apply
@@ -68,6 +94,7 @@ func NewApplyOptions() *ApplyOptions {
 type ApplyImpl struct {
 	*GlobalImpl
 	*ApplyOptions
+	Cmd *cobra.Command
 }
 
 // NewApplyImpl creates a new ApplyImpl
@@ -78,6 +105,11 @@ func NewApplyImpl(g *GlobalImpl, a *ApplyOptions) *ApplyImpl {
 	}
 }
 
+// ValidateConfig validates the values files along with the global config
+func (a *ApplyImpl) ValidateConfig() error {
+	return a.GlobalImpl.validateValues(a.Values())
+}
+
 // Set returns the set.
 func (a *ApplyImpl) Set() []string {
 	return a.ApplyOptions.Set
@@ -88,6 +120,11 @@ func (a *ApplyImpl) Concurrency() int {
 	return a.ApplyOptions.Concurrency
 }
 
+// StateConcurrency returns the state concurrency.
+func (a *ApplyImpl) StateConcurrency() int {
+	return a.ApplyOptions.StateConcurrency
+}
+
 // Context returns the context.
 func (a *ApplyImpl) Context() int {
 	return a.ApplyOptions.Context
@@ -98,6 +135,21 @@ func (a *ApplyImpl) DetailedExitcode() bool {
 	return a.ApplyOptions.DetailedExitcode
 }
 
+// ChangedReleasesFile returns the file to write the releases with changes to.
+func (a *ApplyImpl) ChangedReleasesFile() string {
+	return a.ApplyOptions.ChangedReleasesFile
+}
+
+// DiffOutputDir returns the directory to write the diff of each release to.
+func (a *ApplyImpl) DiffOutputDir() string {
+	return a.ApplyOptions.DiffOutputDir
+}
+
+// DiffOutputFileTemplate returns the template for the path of each release's diff file.
+func (a *ApplyImpl) DiffOutputFileTemplate() string {
+	return a.ApplyOptions.DiffOutputFileTemplate
+}
+
 // DiffOutput returns the diff output.
 func (a *ApplyImpl) DiffOutput() string {
 	return a.ApplyOptions.Output
@@ -187,14 +239,29 @@ func (a *ApplyImpl) Values() []string {
 	return a.ApplyOptions.Values
 }
 
-// Wait returns the wait.
-func (a *ApplyImpl) Wait() bool {
-	return a.ApplyOptions.Wait
+// Wait returns the wait flag when it's given
+func (a *ApplyImpl) Wait() *bool {
+	return boolFlagOverride(a.Cmd, "wait", a.ApplyOptions.Wait)
+}
+
+// WaitForJobs returns the wait for jobs flag when it's given
+func (a *ApplyImpl) WaitForJobs() *bool {
+	return boolFlagOverride(a.Cmd, "wait-for-jobs", a.ApplyOptions.WaitForJobs)
+}
+
+// Atomic returns the atomic flag when it's given
+func (a *ApplyImpl) Atomic() *bool {
+	return boolFlagOverride(a.Cmd, "atomic", a.ApplyOptions.Atomic)
+}
+
+// CleanupOnFail returns the cleanup on fail flag when it's given
+func (a *ApplyImpl) CleanupOnFail() *bool {
+	return boolFlagOverride(a.Cmd, "cleanup-on-fail", a.ApplyOptions.CleanupOnFail)
 }
 
-// WaitForJobs returns the wait for jobs.
-func (a *ApplyImpl) WaitForJobs() bool {
-	return a.ApplyOptions.WaitForJobs
+// ReleaseTimeout returns the timeout of the releases in seconds, or state.EmptyTimeout when it isn't given
+func (a *ApplyImpl) ReleaseTimeout() int {
+	return timeoutFlag(a.Cmd, "release-timeout", a.ApplyOptions.ReleaseTimeout)
 }
 
 // ReuseValues returns the ReuseValues.
@@ -213,3 +280,43 @@ func (a *ApplyImpl) ResetValues() bool {
 func (a *ApplyImpl) PostRenderer() string {
 	return a.ApplyOptions.PostRenderer
 }
+
+// KubeVersionCheck returns the KubeVersionCheck.
+func (a *ApplyImpl) KubeVersionCheck() string {
+	return a.ApplyOptions.KubeVersionCheck
+}
+
+// DiffRenderer returns the DiffRenderer.
+func (a *ApplyImpl) DiffRenderer() string {
+	return a.ApplyOptions.DiffRenderer
+}
+
+// DryRun returns the dry-run flag.
+func (a *ApplyImpl) DryRun() bool {
+	return a.ApplyOptions.DryRun
+}
+
+// InteractivePlan returns the interactive-plan flag.
+func (a *ApplyImpl) InteractivePlan() bool {
+	return a.ApplyOptions.InteractivePlan
+}
+
+// PlanHooks returns true if the hooks are printed instead of being run, which is the case on the dry-run.
+func (a *ApplyImpl) PlanHooks() bool {
+	return a.ApplyOptions.DryRun
+}
+
+// RenderCache returns false, as apply always diffs the releases to find the changes made in the clusters too.
+func (a *ApplyImpl) RenderCache() bool {
+	return false
+}
+
+// NoCache returns true, as apply always diffs the releases.
+func (a *ApplyImpl) NoCache() bool {
+	return true
+}
+
+// CacheDir returns the cache dir, which is unused by apply.
+func (a *ApplyImpl) CacheDir() string {
+	return ""
+}
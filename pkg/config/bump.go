@@ -0,0 +1,45 @@
+package config
+
+// BumpOptions is the options for the bump command
+type BumpOptions struct {
+	// DryRun prints the newer versions without updating the files
+	DryRun bool
+	// SkipRepos is the skip repos flag
+	SkipRepos bool
+	// Output is the output format
+	Output string
+}
+
+// NewBumpOptions creates a new BumpOptions
+func NewBumpOptions() *BumpOptions {
+	return &BumpOptions{}
+}
+
+// BumpImpl is impl for BumpOptions
+type BumpImpl struct {
+	*GlobalImpl
+	*BumpOptions
+}
+
+// NewBumpImpl creates a new BumpImpl
+func NewBumpImpl(g *GlobalImpl, b *BumpOptions) *BumpImpl {
+	return &BumpImpl{
+		GlobalImpl:  g,
+		BumpOptions: b,
+	}
+}
+
+// DryRun returns the dry-run flag
+func (b *BumpImpl) DryRun() bool {
+	return b.BumpOptions.DryRun
+}
+
+// SkipRepos returns the skip repos flag
+func (b *BumpImpl) SkipRepos() bool {
+	return b.BumpOptions.SkipRepos
+}
+
+// Output returns the output format
+func (b *BumpImpl) Output() string {
+	return b.BumpOptions.Output
+}
@@ -0,0 +1,52 @@
+package config
+
+// ChartsPackageOptions is the options for the charts package command
+type ChartsPackageOptions struct {
+	// OutputDir is the directory to write the chart archives to
+	OutputDir string
+	// Version overrides the versions of the charts
+	Version string
+	// GitSuffix appends the short commit hash to the versions
+	GitSuffix bool
+	// Output is the output format
+	Output string
+}
+
+// NewChartsPackageOptions creates a new ChartsPackageOptions
+func NewChartsPackageOptions() *ChartsPackageOptions {
+	return &ChartsPackageOptions{}
+}
+
+// ChartsPackageImpl is impl for ChartsPackageOptions
+type ChartsPackageImpl struct {
+	*GlobalImpl
+	*ChartsPackageOptions
+}
+
+// NewChartsPackageImpl creates a new ChartsPackageImpl
+func NewChartsPackageImpl(g *GlobalImpl, c *ChartsPackageOptions) *ChartsPackageImpl {
+	return &ChartsPackageImpl{
+		GlobalImpl:           g,
+		ChartsPackageOptions: c,
+	}
+}
+
+// OutputDir returns the output directory
+func (c *ChartsPackageImpl) OutputDir() string {
+	return c.ChartsPackageOptions.OutputDir
+}
+
+// ChartVersion returns the version the charts are packaged with
+func (c *ChartsPackageImpl) ChartVersion() string {
+	return c.ChartsPackageOptions.Version
+}
+
+// GitSuffix returns the git suffix flag
+func (c *ChartsPackageImpl) GitSuffix() bool {
+	return c.ChartsPackageOptions.GitSuffix
+}
+
+// Output returns the output format
+func (c *ChartsPackageImpl) Output() string {
+	return c.ChartsPackageOptions.Output
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"errors"
+	"strings"
+)
+
+// ChartsPushOptions is the options for the charts push command
+type ChartsPushOptions struct {
+	// Registry is the OCI registry to push the charts to
+	Registry string
+	// Version overrides the versions of the charts
+	Version string
+	// GitSuffix appends the short commit hash to the versions
+	GitSuffix bool
+	// Rewrite rewrites the releases to the pushed charts
+	Rewrite bool
+	// DryRun prints the charts to be pushed without pushing them
+	DryRun bool
+	// Output is the output format
+	Output string
+}
+
+// NewChartsPushOptions creates a new ChartsPushOptions
+func NewChartsPushOptions() *ChartsPushOptions {
+	return &ChartsPushOptions{}
+}
+
+// ChartsPushImpl is impl for ChartsPushOptions
+type ChartsPushImpl struct {
+	*GlobalImpl
+	*ChartsPushOptions
+}
+
+// NewChartsPushImpl creates a new ChartsPushImpl
+func NewChartsPushImpl(g *GlobalImpl, c *ChartsPushOptions) *ChartsPushImpl {
+	return &ChartsPushImpl{
+		GlobalImpl:        g,
+		ChartsPushOptions: c,
+	}
+}
+
+// ValidateConfig validates the registry along with the global config
+func (c *ChartsPushImpl) ValidateConfig() error {
+	if !strings.HasPrefix(c.ChartsPushOptions.Registry, "oci://") {
+		return errors.New("--registry is required, like --registry oci://registry.example.com/charts")
+	}
+	return c.GlobalImpl.ValidateConfig()
+}
+
+// Registry returns the registry
+func (c *ChartsPushImpl) Registry() string {
+	return c.ChartsPushOptions.Registry
+}
+
+// ChartVersion returns the version the charts are pushed with
+func (c *ChartsPushImpl) ChartVersion() string {
+	return c.ChartsPushOptions.Version
+}
+
+// GitSuffix returns the git suffix flag
+func (c *ChartsPushImpl) GitSuffix() bool {
+	return c.ChartsPushOptions.GitSuffix
+}
+
+// Rewrite returns the rewrite flag
+func (c *ChartsPushImpl) Rewrite() bool {
+	return c.ChartsPushOptions.Rewrite
+}
+
+// DryRun returns the dry-run flag
+func (c *ChartsPushImpl) DryRun() bool {
+	return c.ChartsPushOptions.DryRun
+}
+
+// Output returns the output format
+func (c *ChartsPushImpl) Output() string {
+	return c.ChartsPushOptions.Output
+}
@@ -1,14 +1,21 @@
 package config
 
 import (
+	"fmt"
+	"os"
 	"strings"
 
+	"github.com/spf13/cobra"
+
 	"github.com/helmfile/helmfile/pkg/maputil"
+	"github.com/helmfile/helmfile/pkg/state"
 )
 
 func NewCLIConfigImpl(g *GlobalImpl) error {
 	optsSet := g.RawStateValuesSet()
-	if len(optsSet) > 0 {
+	optsSetString := g.RawStateValuesSetString()
+	optsSetFile := g.RawStateValuesSetFile()
+	if len(optsSet) > 0 || len(optsSetString) > 0 || len(optsSetFile) > 0 {
 		set := map[string]interface{}{}
 		for i := range optsSet {
 			ops := strings.Split(optsSet[i], ",")
@@ -20,8 +27,84 @@ func NewCLIConfigImpl(g *GlobalImpl) error {
 				maputil.Set(set, k, v)
 			}
 		}
+		if err := setStateValues(set, "--state-values-set-string", optsSetString, func(v string) (string, error) {
+			return v, nil
+		}); err != nil {
+			return err
+		}
+		if err := setStateValues(set, "--state-values-set-file", optsSetFile, func(path string) (string, error) {
+			bs, err := os.ReadFile(path)
+			if err != nil {
+				return "", err
+			}
+			return string(bs), nil
+		}); err != nil {
+			return err
+		}
 		g.SetSet(set)
 	}
 
 	return nil
 }
+
+// setStateValues sets the state values given as `key1=val1,key2=val2` to the flag, converting each value with the func.
+// A comma escaped with a backslash, like `key=a\,b`, is a part of the value.
+func setStateValues(set map[string]interface{}, flag string, opts []string, value func(string) (string, error)) error {
+	for _, o := range opts {
+		for _, kv := range splitStateValues(o) {
+			k, v, found := strings.Cut(kv, "=")
+			if !found || k == "" {
+				return fmt.Errorf("invalid %s %q: must be in the form of key=value", flag, kv)
+			}
+
+			val, err := value(v)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q: %v", flag, kv, err)
+			}
+
+			maputil.Set(set, maputil.ParseKey(k), val)
+		}
+	}
+
+	return nil
+}
+
+// splitStateValues splits the state values given as `key1=val1,key2=val2` at the commas not escaped with a backslash,
+// unescaping the escaped commas and backslashes like helm's --set-string does
+func splitStateValues(s string) []string {
+	var (
+		kvs []string
+		kv  strings.Builder
+	)
+
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && i+1 < len(s) && (s[i+1] == ',' || s[i+1] == '\\'):
+			kv.WriteByte(s[i+1])
+			i++
+		case c == ',':
+			kvs = append(kvs, kv.String())
+			kv.Reset()
+		default:
+			kv.WriteByte(c)
+		}
+	}
+
+	return append(kvs, kv.String())
+}
+
+// boolFlagOverride returns the value of the bool flag when it's given to the command, or nil to keep the settings of the state files
+func boolFlagOverride(cmd *cobra.Command, name string, v bool) *bool {
+	if cmd == nil || !cmd.Flags().Changed(name) {
+		return nil
+	}
+	return &v
+}
+
+// timeoutFlag returns the timeout in seconds given to the command by the flag, or state.EmptyTimeout when it isn't given
+func timeoutFlag(cmd *cobra.Command, name string, timeout int) int {
+	if cmd == nil || !cmd.Flags().Changed(name) {
+		return state.EmptyTimeout
+	}
+	return timeout
+}
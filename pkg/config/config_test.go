@@ -0,0 +1,95 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitStateValues(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{in: "a=1", want: []string{"a=1"}},
+		{in: "a=1,b=2", want: []string{"a=1", "b=2"}},
+		{in: `hosts=a.example.com\,b.example.com,c=3`, want: []string{"hosts=a.example.com,b.example.com", "c=3"}},
+		{in: `path=C:\\dir\,x`, want: []string{`path=C:\dir,x`}},
+		{in: `path=C:\dir`, want: []string{`path=C:\dir`}},
+		{in: `a=1\`, want: []string{`a=1\`}},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.want, splitStateValues(tt.in), tt.in)
+	}
+}
+
+func TestSetStateValues(t *testing.T) {
+	t.Run("set-string", func(t *testing.T) {
+		set := map[string]interface{}{}
+
+		err := setStateValues(set, "--state-values-set-string", []string{`image.tag=1.10,hosts=a.example.com\,b.example.com`, "replicas=3"}, func(v string) (string, error) {
+			return v, nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, map[string]interface{}{
+			"image":    map[string]interface{}{"tag": "1.10"},
+			"hosts":    "a.example.com,b.example.com",
+			"replicas": "3",
+		}, set)
+	})
+
+	t.Run("set-file", func(t *testing.T) {
+		crt := filepath.Join(t.TempDir(), "tls.crt")
+		require.NoError(t, os.WriteFile(crt, []byte("CERT"), 0644))
+
+		set := map[string]interface{}{}
+
+		err := setStateValues(set, "--state-values-set-file", []string{"tls.crt=" + crt}, func(path string) (string, error) {
+			bs, err := os.ReadFile(path)
+			return string(bs), err
+		})
+		require.NoError(t, err)
+		require.Equal(t, map[string]interface{}{"tls": map[string]interface{}{"crt": "CERT"}}, set)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, o := range []string{"image.tag", "=1.10", "a=1,"} {
+			err := setStateValues(map[string]interface{}{}, "--state-values-set-string", []string{o}, func(v string) (string, error) {
+				return v, nil
+			})
+			require.ErrorContains(t, err, "invalid --state-values-set-string", o)
+		}
+	})
+}
+
+func TestValidateConfig_InteractiveStdin(t *testing.T) {
+	tests := []struct {
+		global  GlobalOptions
+		values  []string
+		wantErr string
+	}{
+		{global: GlobalOptions{Interactive: true}, values: []string{"values.yaml"}},
+		{global: GlobalOptions{}, values: []string{"-"}},
+		{global: GlobalOptions{Interactive: true}, values: []string{"-"}, wantErr: "--values - cannot be specified with --interactive"},
+		{global: GlobalOptions{Interactive: true, File: "-"}, wantErr: "--file - cannot be specified with --interactive"},
+		{global: GlobalOptions{Interactive: true, StateValuesFile: []string{"-"}}, wantErr: "--state-values-file - cannot be specified with --interactive"},
+	}
+
+	for _, tt := range tests {
+		global := tt.global
+
+		apply := NewApplyImpl(NewGlobalImpl(&global), &ApplyOptions{Values: tt.values})
+		sync := NewSyncImpl(NewGlobalImpl(&global), &SyncOptions{Values: tt.values})
+
+		for _, err := range []error{apply.ValidateConfig(), sync.ValidateConfig()} {
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.ErrorContains(t, err, tt.wantErr)
+			}
+		}
+	}
+}
@@ -11,6 +11,12 @@ type DeleteOptions struct {
 	SkipDeps bool
 	// SkipCharts makes Delete skip `withPreparedCharts`
 	SkipCharts bool
+	// ReportOrphans reports the resources left behind by the deleted releases
+	ReportOrphans bool
+	// DeleteOrphans is the types of the resources left behind by the deleted releases to delete
+	DeleteOrphans []string
+	// KeepNamespaces is the glob patterns of the namespaces never deleted
+	KeepNamespaces []string
 }
 
 // NewDeleteOptions creates a new Apply
@@ -51,3 +57,38 @@ func (c *DeleteImpl) SkipDeps() bool {
 func (c *DeleteImpl) SkipCharts() bool {
 	return c.DeleteOptions.SkipCharts
 }
+
+// ReportOrphans returns the report orphans flag
+func (c *DeleteImpl) ReportOrphans() bool {
+	return c.DeleteOptions.ReportOrphans
+}
+
+// DeleteOrphans returns the types of orphaned resources to delete
+func (c *DeleteImpl) DeleteOrphans() []string {
+	return c.DeleteOptions.DeleteOrphans
+}
+
+// KeepNamespaces returns the patterns of the namespaces never deleted
+func (c *DeleteImpl) KeepNamespaces() []string {
+	return c.DeleteOptions.KeepNamespaces
+}
+
+// Cascade returns false, as the deprecated delete command doesn't support --cascade
+func (c *DeleteImpl) Cascade() bool {
+	return false
+}
+
+// IncludeDependents returns false, as the deprecated delete command doesn't support --include-dependents
+func (c *DeleteImpl) IncludeDependents() bool {
+	return false
+}
+
+// CascadeTimeout returns 0, as the deprecated delete command doesn't support --cascade
+func (c *DeleteImpl) CascadeTimeout() int {
+	return 0
+}
+
+// ContinueOnError returns false, as the deprecated delete command doesn't support --cascade
+func (c *DeleteImpl) ContinueOnError() bool {
+	return false
+}
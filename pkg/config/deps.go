@@ -6,6 +6,8 @@ type DepsOptions struct {
 	SkipRepos bool
 	// Concurrency is the maximum number of concurrent helm processes to run
 	Concurrency int
+	// UpdateLocks is the update locks flag
+	UpdateLocks bool
 }
 
 // NewDepsOptions creates a new Apply
@@ -41,3 +43,8 @@ func (d *DepsImpl) IncludeTransitiveNeeds() bool {
 func (c *DepsImpl) Concurrency() int {
 	return c.DepsOptions.Concurrency
 }
+
+// UpdateLocks returns the update locks flag
+func (d *DepsImpl) UpdateLocks() bool {
+	return d.DepsOptions.UpdateLocks
+}
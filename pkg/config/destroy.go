@@ -8,6 +8,20 @@ type DestroyOptions struct {
 	SkipDeps bool
 	// SkipCharts makes Destroy skip `withPreparedCharts`
 	SkipCharts bool
+	// ReportOrphans reports the resources left behind by the deleted releases
+	ReportOrphans bool
+	// DeleteOrphans is the types of the resources left behind by the deleted releases to delete
+	DeleteOrphans []string
+	// KeepNamespaces is the glob patterns of the namespaces never deleted
+	KeepNamespaces []string
+	// Cascade deletes the releases in the reverse order of their needs tier by tier
+	Cascade bool
+	// IncludeDependents deletes the releases needing the selected ones too
+	IncludeDependents bool
+	// CascadeTimeout is the seconds to wait for the resources of each release to be deleted with --cascade
+	CascadeTimeout int
+	// ContinueOnError keeps deleting the remaining tiers after a tier fails with --cascade
+	ContinueOnError bool
 }
 
 // NewDestroyOptions creates a new Apply
@@ -43,3 +57,38 @@ func (c *DestroyImpl) SkipDeps() bool {
 func (c *DestroyImpl) SkipCharts() bool {
 	return c.DestroyOptions.SkipCharts
 }
+
+// ReportOrphans returns the report orphans flag
+func (c *DestroyImpl) ReportOrphans() bool {
+	return c.DestroyOptions.ReportOrphans
+}
+
+// DeleteOrphans returns the types of orphaned resources to delete
+func (c *DestroyImpl) DeleteOrphans() []string {
+	return c.DestroyOptions.DeleteOrphans
+}
+
+// KeepNamespaces returns the patterns of the namespaces never deleted
+func (c *DestroyImpl) KeepNamespaces() []string {
+	return c.DestroyOptions.KeepNamespaces
+}
+
+// Cascade returns the cascade flag
+func (c *DestroyImpl) Cascade() bool {
+	return c.DestroyOptions.Cascade
+}
+
+// IncludeDependents returns the include dependents flag
+func (c *DestroyImpl) IncludeDependents() bool {
+	return c.DestroyOptions.IncludeDependents
+}
+
+// CascadeTimeout returns the seconds to wait for the resources of each release to be deleted
+func (c *DestroyImpl) CascadeTimeout() int {
+	return c.DestroyOptions.CascadeTimeout
+}
+
+// ContinueOnError returns the continue on error flag
+func (c *DestroyImpl) ContinueOnError() bool {
+	return c.DestroyOptions.ContinueOnError
+}
@@ -10,6 +10,8 @@ type DiffOptions struct {
 	SkipDeps bool
 	// DetailedExitcode is the detailed exit code
 	DetailedExitcode bool
+	// ChangedReleasesFile is the file to write the releases with changes to
+	ChangedReleasesFile string
 	// IncludeTests is the include tests flag
 	IncludeTests bool
 	// SkipNeeds is the include crds flag
@@ -42,6 +44,18 @@ type DiffOptions struct {
 	ResetValues bool
 	// Propagate '--post-renderer' to helmv3 template and helm install
 	PostRenderer string
+	// KubeVersionCheck is the kube-version-check flag
+	KubeVersionCheck string
+	// DiffRenderer is the diff-renderer flag
+	DiffRenderer string
+	// PlanHooks prints the hooks that apply would run without running any hook
+	PlanHooks bool
+	// RenderCache skips diffing the releases found with no changes by the previous runs
+	RenderCache bool
+	// NoCache diffs all the releases instead of skipping the ones found with no changes, while still recording them
+	NoCache bool
+	// CacheDir is the directory the releases found with no changes are recorded in
+	CacheDir string
 }
 
 // NewDiffOptions creates a new Apply
@@ -117,6 +131,11 @@ func (t *DiffImpl) DetailedExitcode() bool {
 	return t.DiffOptions.DetailedExitcode
 }
 
+// ChangedReleasesFile returns the file to write the releases with changes to
+func (t *DiffImpl) ChangedReleasesFile() string {
+	return t.DiffOptions.ChangedReleasesFile
+}
+
 // Output returns the output
 func (t *DiffImpl) DiffOutput() string {
 	return t.DiffOptions.Output
@@ -178,3 +197,33 @@ func (t *DiffImpl) ResetValues() bool {
 func (t *DiffImpl) PostRenderer() string {
 	return t.DiffOptions.PostRenderer
 }
+
+// KubeVersionCheck returns the KubeVersionCheck.
+func (t *DiffImpl) KubeVersionCheck() string {
+	return t.DiffOptions.KubeVersionCheck
+}
+
+// DiffRenderer returns the DiffRenderer.
+func (t *DiffImpl) DiffRenderer() string {
+	return t.DiffOptions.DiffRenderer
+}
+
+// PlanHooks returns the plan-hooks flag.
+func (t *DiffImpl) PlanHooks() bool {
+	return t.DiffOptions.PlanHooks
+}
+
+// RenderCache returns the render-cache flag.
+func (t *DiffImpl) RenderCache() bool {
+	return t.DiffOptions.RenderCache
+}
+
+// NoCache returns the no-cache flag.
+func (t *DiffImpl) NoCache() bool {
+	return t.DiffOptions.NoCache
+}
+
+// CacheDir returns the cache-dir flag.
+func (t *DiffImpl) CacheDir() string {
+	return t.DiffOptions.CacheDir
+}
@@ -0,0 +1,49 @@
+package config
+
+import "errors"
+
+// DocsOptions is the options for the docs command
+type DocsOptions struct {
+	// OutputFile is the file to write the documentation to, instead of the standard output
+	OutputFile string
+	// Check fails when the output file isn't up to date, instead of writing it
+	Check bool
+}
+
+// NewDocsOptions creates a new DocsOptions
+func NewDocsOptions() *DocsOptions {
+	return &DocsOptions{}
+}
+
+// DocsImpl is impl for DocsOptions
+type DocsImpl struct {
+	*GlobalImpl
+	*DocsOptions
+}
+
+// NewDocsImpl creates a new DocsImpl
+func NewDocsImpl(g *GlobalImpl, b *DocsOptions) *DocsImpl {
+	return &DocsImpl{
+		GlobalImpl:  g,
+		DocsOptions: b,
+	}
+}
+
+// OutputFile returns the output file
+func (d *DocsImpl) OutputFile() string {
+	return d.DocsOptions.OutputFile
+}
+
+// Check returns the check flag
+func (d *DocsImpl) Check() bool {
+	return d.DocsOptions.Check
+}
+
+// ValidateConfig validates the docs config
+func (d *DocsImpl) ValidateConfig() error {
+	if d.DocsOptions.Check && d.DocsOptions.OutputFile == "" {
+		return errors.New("--check requires --output-file")
+	}
+
+	return d.GlobalImpl.ValidateConfig()
+}
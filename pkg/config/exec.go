@@ -0,0 +1,43 @@
+package config
+
+// ExecOptions is the options for the exec command
+type ExecOptions struct {
+	// Concurrency is the concurrent flag
+	Concurrency int
+	// Command is the helm command to run for each release, like `get manifest`
+	Command []string
+}
+
+// NewExecOptions creates a new ExecOptions
+func NewExecOptions() *ExecOptions {
+	return &ExecOptions{}
+}
+
+// ExecImpl is impl for ExecOptions
+type ExecImpl struct {
+	*GlobalImpl
+	*ExecOptions
+}
+
+// NewExecImpl creates a new ExecImpl
+func NewExecImpl(g *GlobalImpl, b *ExecOptions) *ExecImpl {
+	return &ExecImpl{
+		GlobalImpl:  g,
+		ExecOptions: b,
+	}
+}
+
+// IncludeTransitiveNeeds returns the include transitive needs
+func (e *ExecImpl) IncludeTransitiveNeeds() bool {
+	return false
+}
+
+// Concurrency returns the concurrency
+func (e *ExecImpl) Concurrency() int {
+	return e.ExecOptions.Concurrency
+}
+
+// Command returns the helm command to run for each release
+func (e *ExecImpl) Command() []string {
+	return e.ExecOptions.Command
+}
@@ -10,6 +10,8 @@ type FetchOptions struct {
 	OutputDir string
 	// OutputDirTemplate is the go template to generate the path of output directory
 	OutputDirTemplate string
+	// Vendor copies the charts into the vendor directory of each helmfile, along with the manifest of them
+	Vendor bool
 }
 
 // NewFetchOptions creates a new Apply
@@ -50,3 +52,8 @@ func (c *FetchImpl) OutputDir() string {
 func (c *FetchImpl) OutputDirTemplate() string {
 	return c.FetchOptions.OutputDirTemplate
 }
+
+// Vendor returns the vendor flag
+func (c *FetchImpl) Vendor() bool {
+	return c.FetchOptions.Vendor
+}
@@ -0,0 +1,121 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/pflag"
+
+	"github.com/helmfile/helmfile/pkg/envvar"
+	"github.com/helmfile/helmfile/pkg/yaml"
+)
+
+const (
+	// ProjectConfigFile is the name of the project-local configuration file looked up in the current working directory.
+	ProjectConfigFile = ".helmfile.yaml"
+
+	// cacheHomeKey is the configuration file key for the cache directory, which has no corresponding flag.
+	cacheHomeKey = "cache-home"
+)
+
+// UserConfigFile returns the path to the user-level configuration file,
+// that is `$XDG_CONFIG_HOME/helmfile/config.yaml` or `~/.config/helmfile/config.yaml`.
+func UserConfigFile() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "helmfile", "config.yaml"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, ".config", "helmfile", "config.yaml"), nil
+}
+
+// FileDefaults is the set of flag defaults read from the configuration files.
+// Each key is the long name of a flag, like `helm-binary` or `concurrency`.
+type FileDefaults map[string]interface{}
+
+// LoadFileDefaults reads the user-level and the project-local configuration files, if any,
+// and returns the merged flag defaults. The project-local file takes precedence over the user-level one.
+func LoadFileDefaults() (FileDefaults, error) {
+	defaults := FileDefaults{}
+
+	userFile, err := UserConfigFile()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range []string{userFile, ProjectConfigFile} {
+		d, err := readFileDefaults(f)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range d {
+			defaults[k] = v
+		}
+	}
+
+	return defaults, nil
+}
+
+func readFileDefaults(path string) (FileDefaults, error) {
+	bytes, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading %s: %v", path, err)
+	}
+
+	var d FileDefaults
+	if err := yaml.Unmarshal(bytes, &d); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+
+	return d, nil
+}
+
+// Apply sets the defaults to the flags that are not explicitly set on the command line or via environment variables.
+// Keys that don't correspond to any flag in fs are ignored, so that a single configuration file can serve every subcommand.
+// The flags aren't marked as changed, so that the defaults don't override the settings of the helmfile like the flags given explicitly do.
+func (d FileDefaults) Apply(fs *pflag.FlagSet) error {
+	if v, ok := d[cacheHomeKey]; ok && os.Getenv(envvar.CacheHome) == "" {
+		if err := os.Setenv(envvar.CacheHome, fmt.Sprintf("%v", v)); err != nil {
+			return err
+		}
+	}
+
+	var errs []error
+
+	fs.VisitAll(func(f *pflag.Flag) {
+		if f.Changed {
+			return
+		}
+
+		v, ok := d[f.Name]
+		if !ok {
+			return
+		}
+
+		var values []interface{}
+		if vs, isList := v.([]interface{}); isList {
+			values = vs
+		} else {
+			values = []interface{}{v}
+		}
+
+		for _, v := range values {
+			if err := f.Value.Set(fmt.Sprintf("%v", v)); err != nil {
+				errs = append(errs, fmt.Errorf("setting %q from the configuration file: %v", f.Name, err))
+			}
+		}
+	})
+
+	if len(errs) > 0 {
+		return errs[0]
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/envvar"
+)
+
+func newFileDefaultsTestFlags(t *testing.T, args ...string) *pflag.FlagSet {
+	t.Helper()
+
+	fs := pflag.NewFlagSet("helmfile", pflag.ContinueOnError)
+	fs.String("helm-binary", "helm", "")
+	fs.Int("concurrency", 0, "")
+	fs.Bool("wait", false, "")
+	fs.StringArray("values", nil, "")
+
+	require.NoError(t, fs.Parse(args))
+
+	return fs
+}
+
+func TestFileDefaults_Apply(t *testing.T) {
+	fs := newFileDefaultsTestFlags(t, "--helm-binary", "helm3")
+
+	d := FileDefaults{
+		"helm-binary": "/usr/local/bin/helm",
+		"concurrency": 4,
+		"wait":        true,
+		"values":      []interface{}{"a.yaml", "b.yaml"},
+		"unknown":     "ignored",
+	}
+	require.NoError(t, d.Apply(fs))
+
+	helmBinary, err := fs.GetString("helm-binary")
+	require.NoError(t, err)
+	require.Equal(t, "helm3", helmBinary, "the flag given on the command line takes precedence")
+
+	concurrency, err := fs.GetInt("concurrency")
+	require.NoError(t, err)
+	require.Equal(t, 4, concurrency)
+
+	wait, err := fs.GetBool("wait")
+	require.NoError(t, err)
+	require.True(t, wait)
+
+	values, err := fs.GetStringArray("values")
+	require.NoError(t, err)
+	require.Equal(t, []string{"a.yaml", "b.yaml"}, values)
+
+	require.True(t, fs.Changed("helm-binary"))
+	for _, name := range []string{"concurrency", "wait", "values"} {
+		require.False(t, fs.Changed(name), "the default of %s is marked as changed", name)
+	}
+}
+
+func TestFileDefaults_Apply_InvalidValue(t *testing.T) {
+	fs := newFileDefaultsTestFlags(t)
+
+	err := FileDefaults{"concurrency": "many"}.Apply(fs)
+	require.ErrorContains(t, err, `setting "concurrency" from the configuration file`)
+}
+
+func TestFileDefaults_Apply_CacheHome(t *testing.T) {
+	t.Setenv(envvar.CacheHome, "")
+
+	require.NoError(t, FileDefaults{"cache-home": "/tmp/helmfile-cache"}.Apply(newFileDefaultsTestFlags(t)))
+	require.Equal(t, "/tmp/helmfile-cache", os.Getenv(envvar.CacheHome))
+
+	t.Setenv(envvar.CacheHome, "/var/cache/helmfile")
+
+	require.NoError(t, FileDefaults{"cache-home": "/tmp/helmfile-cache"}.Apply(newFileDefaultsTestFlags(t)))
+	require.Equal(t, "/var/cache/helmfile", os.Getenv(envvar.CacheHome), "the environment variable takes precedence")
+}
+
+func TestLoadFileDefaults(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(configHome, "helmfile"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(configHome, "helmfile", "config.yaml"), []byte("helm-binary: helm3\nconcurrency: 2\n"), 0644))
+
+	project := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(project, ProjectConfigFile), []byte("concurrency: 4\n"), 0644))
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(project))
+	t.Cleanup(func() {
+		require.NoError(t, os.Chdir(wd))
+	})
+
+	d, err := LoadFileDefaults()
+	require.NoError(t, err)
+	require.Equal(t, "helm3", d["helm-binary"])
+	require.EqualValues(t, 4, d["concurrency"], "the project-local file takes precedence")
+}
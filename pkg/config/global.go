@@ -1,13 +1,19 @@
 package config
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"time"
 
 	"go.uber.org/zap"
 	"golang.org/x/term"
 
+	"github.com/helmfile/helmfile/pkg/dotenv"
+	helmfileerrors "github.com/helmfile/helmfile/pkg/errors"
+	"github.com/helmfile/helmfile/pkg/helmexec"
 	"github.com/helmfile/helmfile/pkg/state"
 )
 
@@ -21,6 +27,10 @@ type GlobalOptions struct {
 	Environment string
 	// StateValuesSet is a list of state values to set on the command line.
 	StateValuesSet []string
+	// StateValuesSetString is a list of state values to set on the command line as strings.
+	StateValuesSetString []string
+	// StateValuesSetFile is a list of state values to set from the contents of files on the command line.
+	StateValuesSetFile []string
 	// StateValuesFiles is a list of state values files to use.
 	StateValuesFile []string
 	// Quiet is true if the output should be quiet.
@@ -35,12 +45,34 @@ type GlobalOptions struct {
 	NoColor bool
 	// LogLevel is the log level to use.
 	LogLevel string
+	// LogOutput is how the logs of the releases processed concurrently are printed, either interleaved or grouped.
+	LogOutput string
+	// ErrorOutput is how the error is printed on exit, either text or json.
+	ErrorOutput string
 	// Namespace is the namespace to use.
 	Namespace string
 	// Chart is the chart to use.
 	Chart string
 	// Selector is a list of selectors to use.
 	Selector []string
+	// HelmfileSelector is a list of selectors of the sub-helmfiles to load, matched against their labels.
+	HelmfileSelector []string
+	// RepositoryMirror is a list of repository mirrors, each in the form of FROM=TO.
+	RepositoryMirror []string
+	// CommandTimeout is a list of timeouts of the helm commands, each like 10m for all the commands or upgrade=30m for the command type.
+	CommandTimeout []string
+	// CommandLiveOutput is a list of whether to stream the outputs of the helm commands, each like upgrade=true for the command type.
+	CommandLiveOutput []string
+	// TempDir is the directory the working directories of the runs, containing the temporary files, are created in.
+	TempDir string
+	// KeepTempFiles is true if the working directories of the runs should be kept for debugging.
+	KeepTempFiles bool
+	// Offline is true if only the vendored and cached charts and remote files should be used, without updating the repositories.
+	Offline bool
+	// AllowClusterLookups is true if the templates are allowed to query the cluster with kubectlGet.
+	AllowClusterLookups bool
+	// SelectorFile is the path to the file containing the selectors to use in addition to Selector.
+	SelectorFile string
 	// AllowNoMatchingRelease is not exit with an error code if the provided selector has no matching releases.
 	AllowNoMatchingRelease bool
 	// logger is the logger to use.
@@ -51,6 +83,10 @@ type GlobalOptions struct {
 	Interactive bool
 	// Args is the list of arguments to pass to the Helm binary.
 	Args string
+	// DotEnv is true if the dotenv files should be loaded into the process environment.
+	DotEnv bool
+	// RunTimeout is the time the whole run is allowed to take, after which the helm commands running are stopped. Zero means no timeout.
+	RunTimeout time.Duration
 }
 
 // Logger returns the logger to use.
@@ -67,6 +103,9 @@ func (g *GlobalOptions) SetLogger(logger *zap.SugaredLogger) {
 type GlobalImpl struct {
 	*GlobalOptions
 	set map[string]interface{}
+	// ctx is the context of the run, which is done on SIGINT, SIGTERM, or RunTimeout
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 // NewGlobalImpl creates a new GlobalImpl.
@@ -112,6 +151,89 @@ func (g *GlobalImpl) Selectors() []string {
 	return g.GlobalOptions.Selector
 }
 
+// HelmfileSelectors returns the selectors of the sub-helmfiles to load.
+func (g *GlobalImpl) HelmfileSelectors() []string {
+	return g.GlobalOptions.HelmfileSelector
+}
+
+// RepositoryMirrors returns the repository mirrors, each in the form of FROM=TO.
+func (g *GlobalImpl) RepositoryMirrors() []string {
+	return g.GlobalOptions.RepositoryMirror
+}
+
+// CommandTimeouts returns the timeouts of the helm commands, each like 10m or upgrade=30m.
+func (g *GlobalImpl) CommandTimeouts() []string {
+	return g.GlobalOptions.CommandTimeout
+}
+
+// CommandLiveOutputs returns whether to stream the outputs of the helm commands, each like upgrade=true.
+func (g *GlobalImpl) CommandLiveOutputs() []string {
+	return g.GlobalOptions.CommandLiveOutput
+}
+
+// TempDir returns the directory the working directories of the runs are created in.
+func (g *GlobalImpl) TempDir() string {
+	return g.GlobalOptions.TempDir
+}
+
+// SetRunContext sets the context of the run to parent, which is canceled on SIGINT and SIGTERM, along with RunTimeout if any.
+func (g *GlobalImpl) SetRunContext(parent context.Context) {
+	if g.GlobalOptions.RunTimeout > 0 {
+		g.ctx, g.cancel = context.WithTimeout(parent, g.GlobalOptions.RunTimeout)
+		return
+	}
+	g.ctx = parent
+}
+
+// RunContext returns the context of the run, which stops the run once it is done.
+func (g *GlobalImpl) RunContext() context.Context {
+	return g.ctx
+}
+
+// StopRun releases the timer of RunTimeout, if any, at the end of the run.
+func (g *GlobalImpl) StopRun() {
+	if g.cancel != nil {
+		g.cancel()
+	}
+}
+
+// KeepTempFiles returns whether to keep the working directories of the runs.
+func (g *GlobalImpl) KeepTempFiles() bool {
+	return g.GlobalOptions.KeepTempFiles
+}
+
+// Offline returns whether to use only the vendored and cached charts and remote files.
+func (g *GlobalImpl) Offline() bool {
+	return g.GlobalOptions.Offline
+}
+
+// AllowClusterLookups returns whether the templates are allowed to query the cluster.
+func (g *GlobalImpl) AllowClusterLookups() bool {
+	return g.GlobalOptions.AllowClusterLookups
+}
+
+// LoadSelectorFile appends the selectors in the selector file, if any, to the selectors to use.
+func (g *GlobalImpl) LoadSelectorFile() error {
+	f := g.GlobalOptions.SelectorFile
+	if f == "" {
+		return nil
+	}
+
+	content, err := os.ReadFile(f)
+	if err != nil {
+		return fmt.Errorf("reading selector file: %v", err)
+	}
+
+	selectors, err := state.ParseSelectors(content)
+	if err != nil {
+		return fmt.Errorf("parsing selector file %s: %v", f, err)
+	}
+
+	g.GlobalOptions.Selector = append(g.GlobalOptions.Selector, selectors...)
+
+	return nil
+}
+
 // StateValuesSet returns the set
 func (g *GlobalImpl) StateValuesSet() map[string]interface{} {
 	return g.set
@@ -122,6 +244,16 @@ func (g *GlobalImpl) RawStateValuesSet() []string {
 	return g.GlobalOptions.StateValuesSet
 }
 
+// RawStateValuesSetString returns the state values to set as strings
+func (g *GlobalImpl) RawStateValuesSetString() []string {
+	return g.GlobalOptions.StateValuesSetString
+}
+
+// RawStateValuesSetFile returns the state values to set from the contents of files
+func (g *GlobalImpl) RawStateValuesSetFile() []string {
+	return g.GlobalOptions.StateValuesSetFile
+}
+
 // StateValuesFiles returns the state values files
 func (g *GlobalImpl) StateValuesFiles() []string {
 	return g.GlobalOptions.StateValuesFile
@@ -182,9 +314,80 @@ func (g *GlobalImpl) ValidateConfig() error {
 	if g.NoColor() && g.Color() {
 		return errors.New("--color and --no-color cannot be specified at the same time")
 	}
+	if o := g.GlobalOptions.LogOutput; o != "" && o != state.LogOutputInterleaved && o != state.LogOutputGrouped {
+		return fmt.Errorf("--log-output must be either %s or %s, but got %q", state.LogOutputInterleaved, state.LogOutputGrouped, o)
+	}
+	if o := g.GlobalOptions.ErrorOutput; o != "" && o != helmfileerrors.OutputText && o != helmfileerrors.OutputJSON {
+		return fmt.Errorf("--error-output must be either %s or %s, but got %q", helmfileerrors.OutputText, helmfileerrors.OutputJSON, o)
+	}
+	for _, s := range g.GlobalOptions.HelmfileSelector {
+		if _, err := state.ParseLabels(s); err != nil {
+			return fmt.Errorf("invalid --helmfile-selector: %w", err)
+		}
+	}
+	for _, m := range g.GlobalOptions.RepositoryMirror {
+		if _, _, err := state.ParseRepositoryMirror(m); err != nil {
+			return fmt.Errorf("invalid --repository-mirror: %w", err)
+		}
+	}
+	if _, err := helmexec.ParseCommandTimeouts(g.GlobalOptions.CommandTimeout); err != nil {
+		return fmt.Errorf("invalid --command-timeout: %w", err)
+	}
+	if _, err := helmexec.ParseCommandLiveOutputs(g.GlobalOptions.CommandLiveOutput); err != nil {
+		return fmt.Errorf("invalid --command-live-output: %w", err)
+	}
+	if g.GlobalOptions.RunTimeout < 0 {
+		return fmt.Errorf("invalid --run-timeout: %v must not be negative", g.GlobalOptions.RunTimeout)
+	}
+	if g.GlobalOptions.File == "-" {
+		for _, f := range g.GlobalOptions.StateValuesFile {
+			if f == "-" {
+				return errors.New("--file - and --state-values-file - cannot be specified at the same time, as both read from stdin")
+			}
+		}
+		if err := g.validateNonInteractiveStdin("--file"); err != nil {
+			return err
+		}
+	}
+	for _, f := range g.GlobalOptions.StateValuesFile {
+		if f == "-" {
+			if err := g.validateNonInteractiveStdin("--state-values-file"); err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
 
+// validateNonInteractiveStdin rejects reading the flag from stdin in the interactive mode,
+// as stdin is where the answers to the confirmation prompts are read from
+func (g *GlobalImpl) validateNonInteractiveStdin(flag string) error {
+	if g.Interactive() {
+		return fmt.Errorf("%s - cannot be specified with --interactive, as the answers to the prompts are read from stdin", flag)
+	}
+	return nil
+}
+
+// validateValues validates the --values of the commands, which read the values from stdin when it's `-`
+func (g *GlobalImpl) validateValues(values []string) error {
+	for _, v := range values {
+		if v == "-" {
+			if err := g.validateNonInteractiveStdin("--values"); err != nil {
+				return err
+			}
+		}
+	}
+	return g.ValidateConfig()
+}
+
+// LogOutput returns how the logs of the releases processed concurrently are printed
+func (g *GlobalImpl) LogOutput() string {
+	if g.GlobalOptions.LogOutput == "" {
+		return state.LogOutputInterleaved
+	}
+	return g.GlobalOptions.LogOutput
+}
+
 // Interactive returns the Interactive
 func (g *GlobalImpl) Interactive() bool {
 	return g.GlobalOptions.Interactive
@@ -201,3 +404,25 @@ func (g *GlobalImpl) Args() string {
 
 	return args
 }
+
+// DotEnvFiles returns the dotenv files to be loaded, or nil when loading dotenv files is disabled.
+// The files are looked up in the directory of the state file, or the current working directory when
+// the state file is not a local file.
+func (g *GlobalImpl) DotEnvFiles() []string {
+	if !g.GlobalOptions.DotEnv {
+		return nil
+	}
+
+	dir := "."
+	if f := g.GlobalOptions.File; f != "" && f != "-" {
+		if info, err := os.Stat(f); err == nil {
+			if info.IsDir() {
+				dir = f
+			} else {
+				dir = filepath.Dir(f)
+			}
+		}
+	}
+
+	return dotenv.Files(dir, g.Env())
+}
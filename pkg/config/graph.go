@@ -0,0 +1,43 @@
+package config
+
+import "fmt"
+
+// GraphOptions is the options for the graph command
+type GraphOptions struct {
+	// Output is the output format
+	Output string
+}
+
+// NewGraphOptions creates a new GraphOptions
+func NewGraphOptions() *GraphOptions {
+	return &GraphOptions{}
+}
+
+// GraphImpl is impl for GraphOptions
+type GraphImpl struct {
+	*GlobalImpl
+	*GraphOptions
+}
+
+// NewGraphImpl creates a new GraphImpl
+func NewGraphImpl(g *GlobalImpl, b *GraphOptions) *GraphImpl {
+	return &GraphImpl{
+		GlobalImpl:   g,
+		GraphOptions: b,
+	}
+}
+
+// ValidateConfig validates the output format along with the global config
+func (g *GraphImpl) ValidateConfig() error {
+	switch g.GraphOptions.Output {
+	case "dot", "mermaid", "json":
+	default:
+		return fmt.Errorf("invalid output format %q: it must be one of dot, mermaid or json", g.GraphOptions.Output)
+	}
+	return g.GlobalImpl.ValidateConfig()
+}
+
+// Output returns the output format
+func (g *GraphImpl) Output() string {
+	return g.GraphOptions.Output
+}
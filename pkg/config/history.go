@@ -0,0 +1,50 @@
+package config
+
+// HistoryOptions is the options for the history command
+type HistoryOptions struct {
+	// Concurrency is the concurrent flag
+	Concurrency int
+	// Max is the maximum number of revisions to show per release
+	Max int
+	// Output is the output format
+	Output string
+}
+
+// NewHistoryOptions creates a new HistoryOptions
+func NewHistoryOptions() *HistoryOptions {
+	return &HistoryOptions{}
+}
+
+// HistoryImpl is impl for HistoryOptions
+type HistoryImpl struct {
+	*GlobalImpl
+	*HistoryOptions
+}
+
+// NewHistoryImpl creates a new HistoryImpl
+func NewHistoryImpl(g *GlobalImpl, b *HistoryOptions) *HistoryImpl {
+	return &HistoryImpl{
+		GlobalImpl:     g,
+		HistoryOptions: b,
+	}
+}
+
+// IncludeTransitiveNeeds returns the include transitive needs
+func (h *HistoryImpl) IncludeTransitiveNeeds() bool {
+	return false
+}
+
+// Concurrency returns the concurrency
+func (h *HistoryImpl) Concurrency() int {
+	return h.HistoryOptions.Concurrency
+}
+
+// Max returns the maximum number of revisions to show per release
+func (h *HistoryImpl) Max() int {
+	return h.HistoryOptions.Max
+}
+
+// Output returns the output format
+func (h *HistoryImpl) Output() string {
+	return h.HistoryOptions.Output
+}
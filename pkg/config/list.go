@@ -1,5 +1,7 @@
 package config
 
+import "fmt"
+
 // ListOptions is the options for the build command
 type ListOptions struct {
 	// Output is the output format
@@ -29,6 +31,16 @@ func NewListImpl(g *GlobalImpl, b *ListOptions) *ListImpl {
 	}
 }
 
+// ValidateConfig validates the output format along with the global config
+func (c *ListImpl) ValidateConfig() error {
+	switch c.ListOptions.Output {
+	case "", "table", "json", "yaml":
+	default:
+		return fmt.Errorf("invalid output format %q: it must be one of table, json or yaml", c.ListOptions.Output)
+	}
+	return c.GlobalImpl.ValidateConfig()
+}
+
 // Output returns the output
 func (c *ListImpl) Output() string {
 	return c.ListOptions.Output
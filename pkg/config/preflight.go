@@ -0,0 +1,91 @@
+package config
+
+import "github.com/helmfile/helmfile/pkg/state"
+
+// PreflightOptions is the options for the preflight command
+type PreflightOptions struct {
+	// Operation is the operation to check the permissions for
+	Operation string
+	// Output is the output format
+	Output string
+	// Concurrency is the maximum number of concurrent helm processes to run, 0 is unlimited
+	Concurrency int
+	// SkipDeps is the skip deps flag
+	SkipDeps bool
+	// Set is the set flags to pass to helm template
+	Set []string
+	// Values is the values flags to pass to helm template
+	Values []string
+}
+
+// NewPreflightOptions creates a new PreflightOptions
+func NewPreflightOptions() *PreflightOptions {
+	return &PreflightOptions{}
+}
+
+// PreflightImpl is impl for PreflightOptions
+type PreflightImpl struct {
+	*GlobalImpl
+	*PreflightOptions
+}
+
+// NewPreflightImpl creates a new PreflightImpl
+func NewPreflightImpl(g *GlobalImpl, b *PreflightOptions) *PreflightImpl {
+	return &PreflightImpl{
+		GlobalImpl:       g,
+		PreflightOptions: b,
+	}
+}
+
+// ValidateConfig validates the preflight options
+func (p *PreflightImpl) ValidateConfig() error {
+	if err := state.ValidatePreflightOperation(p.PreflightOptions.Operation); err != nil {
+		return err
+	}
+	return p.GlobalImpl.ValidateConfig()
+}
+
+// Operation returns the operation to check the permissions for
+func (p *PreflightImpl) Operation() string {
+	return p.PreflightOptions.Operation
+}
+
+// Output returns the output format
+func (p *PreflightImpl) Output() string {
+	return p.PreflightOptions.Output
+}
+
+// Concurrency returns the concurrency
+func (p *PreflightImpl) Concurrency() int {
+	return p.PreflightOptions.Concurrency
+}
+
+// SkipDeps returns the skip deps
+func (p *PreflightImpl) SkipDeps() bool {
+	return p.PreflightOptions.SkipDeps
+}
+
+// Set returns the Set
+func (p *PreflightImpl) Set() []string {
+	return p.PreflightOptions.Set
+}
+
+// Values returns the Values
+func (p *PreflightImpl) Values() []string {
+	return p.PreflightOptions.Values
+}
+
+// SkipNeeds returns the skip needs
+func (p *PreflightImpl) SkipNeeds() bool {
+	return true
+}
+
+// IncludeNeeds returns the include needs
+func (p *PreflightImpl) IncludeNeeds() bool {
+	return false
+}
+
+// IncludeTransitiveNeeds returns the include transitive needs
+func (p *PreflightImpl) IncludeTransitiveNeeds() bool {
+	return false
+}
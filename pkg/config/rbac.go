@@ -0,0 +1,74 @@
+package config
+
+// RBACOptions is the options for the rbac command
+type RBACOptions struct {
+	// Concurrency is the maximum number of concurrent helm processes to run, 0 is unlimited
+	Concurrency int
+	// SkipDeps is the skip deps flag
+	SkipDeps bool
+	// Set is the set flags to pass to helm template
+	Set []string
+	// Values is the values flags to pass to helm template
+	Values []string
+	// Name is the name of the Roles and the ClusterRoles
+	Name string
+}
+
+// NewRBACOptions creates a new RBACOptions
+func NewRBACOptions() *RBACOptions {
+	return &RBACOptions{}
+}
+
+// RBACImpl is impl for RBACOptions
+type RBACImpl struct {
+	*GlobalImpl
+	*RBACOptions
+}
+
+// NewRBACImpl creates a new RBACImpl
+func NewRBACImpl(g *GlobalImpl, b *RBACOptions) *RBACImpl {
+	return &RBACImpl{
+		GlobalImpl:  g,
+		RBACOptions: b,
+	}
+}
+
+// Concurrency returns the concurrency
+func (r *RBACImpl) Concurrency() int {
+	return r.RBACOptions.Concurrency
+}
+
+// SkipDeps returns the skip deps
+func (r *RBACImpl) SkipDeps() bool {
+	return r.RBACOptions.SkipDeps
+}
+
+// Set returns the Set
+func (r *RBACImpl) Set() []string {
+	return r.RBACOptions.Set
+}
+
+// Values returns the Values
+func (r *RBACImpl) Values() []string {
+	return r.RBACOptions.Values
+}
+
+// Name returns the name of the Roles and the ClusterRoles
+func (r *RBACImpl) Name() string {
+	return r.RBACOptions.Name
+}
+
+// SkipNeeds returns the skip needs
+func (r *RBACImpl) SkipNeeds() bool {
+	return true
+}
+
+// IncludeNeeds returns the include needs
+func (r *RBACImpl) IncludeNeeds() bool {
+	return false
+}
+
+// IncludeTransitiveNeeds returns the include transitive needs
+func (r *RBACImpl) IncludeTransitiveNeeds() bool {
+	return false
+}
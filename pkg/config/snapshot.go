@@ -0,0 +1,98 @@
+package config
+
+import "errors"
+
+// SnapshotOptions is the options for the snapshot write and verify commands
+type SnapshotOptions struct {
+	// Dir is the directory the snapshots are stored in
+	Dir string
+	// Concurrency is the maximum number of concurrent helm processes to run, 0 is unlimited
+	Concurrency int
+	// SkipDeps is the skip deps flag
+	SkipDeps bool
+	// Set is the set flags to pass to helm template
+	Set []string
+	// Values is the values flags to pass to helm template
+	Values []string
+	// IncludeCRDs is the include crds flag
+	IncludeCRDs bool
+	// SkipTests is the skip tests flag
+	SkipTests bool
+}
+
+// NewSnapshotOptions creates a new SnapshotOptions
+func NewSnapshotOptions() *SnapshotOptions {
+	return &SnapshotOptions{}
+}
+
+// SnapshotImpl is impl for SnapshotOptions
+type SnapshotImpl struct {
+	*GlobalImpl
+	*SnapshotOptions
+}
+
+// NewSnapshotImpl creates a new SnapshotImpl
+func NewSnapshotImpl(g *GlobalImpl, s *SnapshotOptions) *SnapshotImpl {
+	return &SnapshotImpl{
+		GlobalImpl:      g,
+		SnapshotOptions: s,
+	}
+}
+
+// ValidateConfig validates the snapshot directory along with the global config
+func (s *SnapshotImpl) ValidateConfig() error {
+	if s.SnapshotOptions.Dir == "" {
+		return errors.New("--snapshot-dir must not be empty")
+	}
+	return s.GlobalImpl.ValidateConfig()
+}
+
+// SnapshotDir returns the directory the snapshots are stored in
+func (s *SnapshotImpl) SnapshotDir() string {
+	return s.SnapshotOptions.Dir
+}
+
+// Concurrency returns the concurrency
+func (s *SnapshotImpl) Concurrency() int {
+	return s.SnapshotOptions.Concurrency
+}
+
+// SkipDeps returns the skip deps
+func (s *SnapshotImpl) SkipDeps() bool {
+	return s.SnapshotOptions.SkipDeps
+}
+
+// Set returns the Set
+func (s *SnapshotImpl) Set() []string {
+	return s.SnapshotOptions.Set
+}
+
+// Values returns the Values
+func (s *SnapshotImpl) Values() []string {
+	return s.SnapshotOptions.Values
+}
+
+// IncludeCRDs returns the include crds
+func (s *SnapshotImpl) IncludeCRDs() bool {
+	return s.SnapshotOptions.IncludeCRDs
+}
+
+// SkipTests returns the skip tests
+func (s *SnapshotImpl) SkipTests() bool {
+	return s.SnapshotOptions.SkipTests
+}
+
+// SkipNeeds returns the skip needs
+func (s *SnapshotImpl) SkipNeeds() bool {
+	return true
+}
+
+// IncludeNeeds returns the include needs
+func (s *SnapshotImpl) IncludeNeeds() bool {
+	return false
+}
+
+// IncludeTransitiveNeeds returns the include transitive needs
+func (s *SnapshotImpl) IncludeTransitiveNeeds() bool {
+	return false
+}
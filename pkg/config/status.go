@@ -1,9 +1,13 @@
 package config
 
+import "fmt"
+
 // StatusOptions is the options for the build command
 type StatusOptions struct {
 	// Concurrency is the concurrent flag
 	Concurrency int
+	// Output is the output format
+	Output string
 }
 
 // NewStatusOptions creates a new Apply
@@ -25,6 +29,16 @@ func NewStatusImpl(g *GlobalImpl, b *StatusOptions) *StatusImpl {
 	}
 }
 
+// ValidateConfig validates the output format along with the global config
+func (s *StatusImpl) ValidateConfig() error {
+	switch s.StatusOptions.Output {
+	case "", "table", "json":
+	default:
+		return fmt.Errorf("invalid output format %q: it must be table or json", s.StatusOptions.Output)
+	}
+	return s.GlobalImpl.ValidateConfig()
+}
+
 // IncludeTransitiveNeeds returns the include transitive needs
 func (s *StatusImpl) IncludeTransitiveNeeds() bool {
 	return false
@@ -34,3 +48,8 @@ func (s *StatusImpl) IncludeTransitiveNeeds() bool {
 func (s *StatusImpl) Concurrency() int {
 	return s.StatusOptions.Concurrency
 }
+
+// Output returns the output format
+func (s *StatusImpl) Output() string {
+	return s.StatusOptions.Output
+}
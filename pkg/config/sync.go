@@ -1,5 +1,9 @@
 package config
 
+import (
+	"github.com/spf13/cobra"
+)
+
 // SyncOptions is the options for the build command
 type SyncOptions struct {
 	// Set is the set flag
@@ -8,6 +12,8 @@ type SyncOptions struct {
 	Values []string
 	// Concurrency is the concurrency flag
 	Concurrency int
+	// StateConcurrency is the state concurrency flag
+	StateConcurrency int
 	// Validate is the validate flag
 	Validate bool
 	// IncludeCRDs is the include crds flag
@@ -24,12 +30,20 @@ type SyncOptions struct {
 	Wait bool
 	// WaitForJobs is the wait for jobs flag
 	WaitForJobs bool
+	// Atomic is the atomic flag, which overrides the atomic of the releases
+	Atomic bool
+	// CleanupOnFail is the cleanup on fail flag, which overrides the cleanupOnFail of the releases
+	CleanupOnFail bool
+	// ReleaseTimeout is the timeout in seconds, which overrides the timeout of the releases
+	ReleaseTimeout int
 	// ReuseValues is true if the helm command should reuse the values
 	ReuseValues bool
 	// ResetValues is true if helm command should reset values to charts' default
 	ResetValues bool
 	// Propagate '--post-renderer' to helmv3 template and helm install
 	PostRenderer string
+	// KubeVersionCheck is the kube-version-check flag
+	KubeVersionCheck string
 }
 
 // NewSyncOptions creates a new Apply
@@ -41,6 +55,7 @@ func NewSyncOptions() *SyncOptions {
 type SyncImpl struct {
 	*GlobalImpl
 	*SyncOptions
+	Cmd *cobra.Command
 }
 
 // NewSyncImpl creates a new SyncImpl
@@ -51,11 +66,21 @@ func NewSyncImpl(g *GlobalImpl, t *SyncOptions) *SyncImpl {
 	}
 }
 
+// ValidateConfig validates the values files along with the global config
+func (t *SyncImpl) ValidateConfig() error {
+	return t.GlobalImpl.validateValues(t.Values())
+}
+
 // Concurrency returns the concurrency
 func (t *SyncImpl) Concurrency() int {
 	return t.SyncOptions.Concurrency
 }
 
+// StateConcurrency returns the state concurrency
+func (t *SyncImpl) StateConcurrency() int {
+	return t.SyncOptions.StateConcurrency
+}
+
 // IncludeNeeds returns the include needs
 func (t *SyncImpl) IncludeNeeds() bool {
 	return t.SyncOptions.IncludeNeeds || t.IncludeTransitiveNeeds()
@@ -100,14 +125,29 @@ func (t *SyncImpl) SkipCRDs() bool {
 	return t.SyncOptions.SkipCRDs
 }
 
-// Wait returns the wait
-func (t *SyncImpl) Wait() bool {
-	return t.SyncOptions.Wait
+// Wait returns the wait flag when it's given
+func (t *SyncImpl) Wait() *bool {
+	return boolFlagOverride(t.Cmd, "wait", t.SyncOptions.Wait)
+}
+
+// WaitForJobs returns the wait for jobs flag when it's given
+func (t *SyncImpl) WaitForJobs() *bool {
+	return boolFlagOverride(t.Cmd, "wait-for-jobs", t.SyncOptions.WaitForJobs)
 }
 
-// WaitForJobs returns the wait for jobs
-func (t *SyncImpl) WaitForJobs() bool {
-	return t.SyncOptions.WaitForJobs
+// Atomic returns the atomic flag when it's given
+func (t *SyncImpl) Atomic() *bool {
+	return boolFlagOverride(t.Cmd, "atomic", t.SyncOptions.Atomic)
+}
+
+// CleanupOnFail returns the cleanup on fail flag when it's given
+func (t *SyncImpl) CleanupOnFail() *bool {
+	return boolFlagOverride(t.Cmd, "cleanup-on-fail", t.SyncOptions.CleanupOnFail)
+}
+
+// ReleaseTimeout returns the timeout of the releases in seconds, or state.EmptyTimeout when it isn't given
+func (t *SyncImpl) ReleaseTimeout() int {
+	return timeoutFlag(t.Cmd, "release-timeout", t.SyncOptions.ReleaseTimeout)
 }
 
 // ReuseValues returns the ReuseValues.
@@ -125,3 +165,8 @@ func (t *SyncImpl) ResetValues() bool {
 func (t *SyncImpl) PostRenderer() string {
 	return t.SyncOptions.PostRenderer
 }
+
+// KubeVersionCheck returns the KubeVersionCheck.
+func (t *SyncImpl) KubeVersionCheck() string {
+	return t.SyncOptions.KubeVersionCheck
+}
@@ -24,6 +24,16 @@ type TemplateOptions struct {
 	IncludeCRDs bool
 	// SkipTests is the skip tests flag
 	SkipTests bool
+	// ShowOnly is the show only flag
+	ShowOnly []string
+	// AnnotateSource is the annotate source flag
+	AnnotateSource bool
+	// RenderCache is the render cache flag
+	RenderCache bool
+	// NoCache renders all the releases instead of reusing the cached manifests, while still caching them
+	NoCache bool
+	// CacheDir is the directory the rendered manifests are cached in
+	CacheDir string
 	// SkipNeeds is the skip needs flag
 	SkipNeeds bool
 	// IncludeNeeds is the include needs flag
@@ -116,6 +126,31 @@ func (t *TemplateImpl) SkipTests() bool {
 	return t.TemplateOptions.SkipTests
 }
 
+// ShowOnly returns the show only
+func (t *TemplateImpl) ShowOnly() []string {
+	return t.TemplateOptions.ShowOnly
+}
+
+// AnnotateSource returns the annotate source
+func (t *TemplateImpl) AnnotateSource() bool {
+	return t.TemplateOptions.AnnotateSource
+}
+
+// RenderCache returns the render cache
+func (t *TemplateImpl) RenderCache() bool {
+	return t.TemplateOptions.RenderCache
+}
+
+// NoCache returns the no cache
+func (t *TemplateImpl) NoCache() bool {
+	return t.TemplateOptions.NoCache
+}
+
+// CacheDir returns the cache dir
+func (t *TemplateImpl) CacheDir() string {
+	return t.TemplateOptions.CacheDir
+}
+
 // Validate returns the validate
 func (t *TemplateImpl) Validate() bool {
 	return t.TemplateOptions.Validate
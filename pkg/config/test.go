@@ -1,6 +1,8 @@
 package config
 
 import (
+	"fmt"
+
 	"github.com/spf13/cobra"
 
 	"github.com/helmfile/helmfile/pkg/state"
@@ -16,6 +18,10 @@ type TestOptions struct {
 	Cleanup bool
 	// Logs is the logs flagj
 	Logs bool
+	// LogsDir is the directory to write the output of the failed tests to
+	LogsDir string
+	// Output is the format of the report of the results
+	Output string
 	// Timeout is the timeout flag
 	Timeout int
 }
@@ -40,6 +46,16 @@ func NewTestImpl(g *GlobalImpl, t *TestOptions) *TestImpl {
 	}
 }
 
+// ValidateConfig validates the output format along with the global config
+func (t *TestImpl) ValidateConfig() error {
+	switch t.TestOptions.Output {
+	case "", "table", "json":
+	default:
+		return fmt.Errorf("invalid output format %q: it must be table or json", t.TestOptions.Output)
+	}
+	return t.GlobalImpl.ValidateConfig()
+}
+
 // Concurrency returns the concurrency
 func (t *TestImpl) Concurrency() int {
 	return t.TestOptions.Concurrency
@@ -60,6 +76,16 @@ func (t *TestImpl) Logs() bool {
 	return t.TestOptions.Logs
 }
 
+// LogsDir returns the directory to write the output of the failed tests to
+func (t *TestImpl) LogsDir() string {
+	return t.TestOptions.LogsDir
+}
+
+// Output returns the format of the report of the results
+func (t *TestImpl) Output() string {
+	return t.TestOptions.Output
+}
+
 // Timeout returns the timeout
 func (t *TestImpl) Timeout() int {
 	if !t.Cmd.Flags().Changed("timeout") {
@@ -0,0 +1,102 @@
+package config
+
+// ValidateOptions is the options for the validate command
+type ValidateOptions struct {
+	// Concurrency is the maximum number of concurrent helm processes to run, 0 is unlimited
+	Concurrency int
+	// SkipDeps is the skip deps flag
+	SkipDeps bool
+	// Set is the set flags to pass to helm template
+	Set []string
+	// Values is the values flags to pass to helm template
+	Values []string
+	// SchemaLocations are the locations of the JSON schemas of the resources
+	SchemaLocations []string
+	// Strict disallows the fields not in the schemas
+	Strict bool
+	// IgnoreMissingSchemas skips the resources without the schemas
+	IgnoreMissingSchemas bool
+	// Policies are the files or the directories of the rego policies
+	Policies []string
+	// Output is the output format
+	Output string
+}
+
+// NewValidateOptions creates a new ValidateOptions
+func NewValidateOptions() *ValidateOptions {
+	return &ValidateOptions{}
+}
+
+// ValidateImpl is impl for ValidateOptions
+type ValidateImpl struct {
+	*GlobalImpl
+	*ValidateOptions
+}
+
+// NewValidateImpl creates a new ValidateImpl
+func NewValidateImpl(g *GlobalImpl, b *ValidateOptions) *ValidateImpl {
+	return &ValidateImpl{
+		GlobalImpl:      g,
+		ValidateOptions: b,
+	}
+}
+
+// Concurrency returns the concurrency
+func (v *ValidateImpl) Concurrency() int {
+	return v.ValidateOptions.Concurrency
+}
+
+// SkipDeps returns the skip deps
+func (v *ValidateImpl) SkipDeps() bool {
+	return v.ValidateOptions.SkipDeps
+}
+
+// Set returns the Set
+func (v *ValidateImpl) Set() []string {
+	return v.ValidateOptions.Set
+}
+
+// Values returns the Values
+func (v *ValidateImpl) Values() []string {
+	return v.ValidateOptions.Values
+}
+
+// SchemaLocations returns the locations of the JSON schemas
+func (v *ValidateImpl) SchemaLocations() []string {
+	return v.ValidateOptions.SchemaLocations
+}
+
+// Strict returns the strict flag
+func (v *ValidateImpl) Strict() bool {
+	return v.ValidateOptions.Strict
+}
+
+// IgnoreMissingSchemas returns the ignore missing schemas flag
+func (v *ValidateImpl) IgnoreMissingSchemas() bool {
+	return v.ValidateOptions.IgnoreMissingSchemas
+}
+
+// Policies returns the rego policies
+func (v *ValidateImpl) Policies() []string {
+	return v.ValidateOptions.Policies
+}
+
+// Output returns the output format
+func (v *ValidateImpl) Output() string {
+	return v.ValidateOptions.Output
+}
+
+// SkipNeeds returns the skip needs
+func (v *ValidateImpl) SkipNeeds() bool {
+	return true
+}
+
+// IncludeNeeds returns the include needs
+func (v *ValidateImpl) IncludeNeeds() bool {
+	return false
+}
+
+// IncludeTransitiveNeeds returns the include transitive needs
+func (v *ValidateImpl) IncludeTransitiveNeeds() bool {
+	return false
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"errors"
+	"time"
+)
+
+// WatchOptions is the options for the watch command
+type WatchOptions struct {
+	// Command is the command to re-run on changes, which is either `diff` or `template`
+	Command string
+	// Interval is the interval to check the files for changes
+	Interval time.Duration
+	// Set is the additional values to be merged into the command
+	Set []string
+	// Values is the additional value files to be merged into the command
+	Values []string
+	// SkipDeps is the skip deps flag
+	SkipDeps bool
+	// Concurrency is the maximum number of concurrent helm processes to run
+	Concurrency int
+	// DriftInterval is the interval to re-run diff on all the selected releases to detect the drifts, which is disabled when 0
+	DriftInterval time.Duration
+	// DriftWebhook is the URL to post the drifts detected to
+	DriftWebhook string
+	// ExitOnDrift stops watching with the exit code 2 once a drift is detected
+	ExitOnDrift bool
+}
+
+// NewWatchOptions creates a new WatchOptions
+func NewWatchOptions() *WatchOptions {
+	return &WatchOptions{}
+}
+
+// WatchImpl is impl for WatchOptions
+type WatchImpl struct {
+	*GlobalImpl
+	*WatchOptions
+}
+
+// NewWatchImpl creates a new WatchImpl
+func NewWatchImpl(g *GlobalImpl, w *WatchOptions) *WatchImpl {
+	return &WatchImpl{
+		GlobalImpl:   g,
+		WatchOptions: w,
+	}
+}
+
+// ValidateConfig validates the intervals and the drift detection flags
+func (w *WatchImpl) ValidateConfig() error {
+	if w.WatchOptions.Interval <= 0 {
+		return errors.New("--interval must be positive")
+	}
+	if w.WatchOptions.DriftInterval < 0 {
+		return errors.New("--drift-interval must not be negative")
+	}
+	if w.WatchOptions.DriftInterval > 0 && w.WatchOptions.Command != "diff" {
+		return errors.New("--drift-interval can be used only with --command diff")
+	}
+	if w.WatchOptions.DriftInterval == 0 && (w.WatchOptions.DriftWebhook != "" || w.WatchOptions.ExitOnDrift) {
+		return errors.New("--drift-webhook and --exit-on-drift require --drift-interval")
+	}
+	return w.GlobalImpl.ValidateConfig()
+}
+
+// Command returns the command to re-run on changes
+func (w *WatchImpl) Command() string {
+	return w.WatchOptions.Command
+}
+
+// Interval returns the interval to check the files for changes
+func (w *WatchImpl) Interval() time.Duration {
+	return w.WatchOptions.Interval
+}
+
+// DriftInterval returns the interval to re-run diff to detect the drifts
+func (w *WatchImpl) DriftInterval() time.Duration {
+	return w.WatchOptions.DriftInterval
+}
+
+// DriftWebhook returns the URL to post the drifts detected to
+func (w *WatchImpl) DriftWebhook() string {
+	return w.WatchOptions.DriftWebhook
+}
+
+// ExitOnDrift returns the exit on drift flag
+func (w *WatchImpl) ExitOnDrift() bool {
+	return w.WatchOptions.ExitOnDrift
+}
+
+// DiffOptions returns the options of the diff command run on changes.
+// The diff identifies the releases with changes to detect the drifts, with the drift interval.
+func (w *WatchImpl) DiffOptions() *DiffOptions {
+	return &DiffOptions{
+		Set:         w.WatchOptions.Set,
+		Values:      w.WatchOptions.Values,
+		SkipDeps:    w.WatchOptions.SkipDeps,
+		Concurrency: w.WatchOptions.Concurrency,
+		SkipNeeds:   true,
+
+		DetailedExitcode: w.WatchOptions.DriftInterval > 0,
+	}
+}
+
+// TemplateOptions returns the options of the template command run on changes
+func (w *WatchImpl) TemplateOptions() *TemplateOptions {
+	return &TemplateOptions{
+		Set:         w.WatchOptions.Set,
+		Values:      w.WatchOptions.Values,
+		SkipDeps:    w.WatchOptions.SkipDeps,
+		Concurrency: w.WatchOptions.Concurrency,
+		SkipNeeds:   true,
+	}
+}
@@ -12,6 +12,8 @@ type WriteValuesOptions struct {
 	Values []string
 	// OutputFileTemplate is the output file template
 	OutputFileTemplate string
+	// IncludeChartValues is true if the default values of the charts are written along with the values of the releases
+	IncludeChartValues bool
 }
 
 // NewWriteValuesOptions creates a new Apply
@@ -67,3 +69,8 @@ func (c *WriteValuesImpl) IncludeTransitiveNeeds() bool {
 func (c *WriteValuesImpl) OutputFileTemplate() string {
 	return c.WriteValuesOptions.OutputFileTemplate
 }
+
+// IncludeChartValues returns the include chart values flag
+func (c *WriteValuesImpl) IncludeChartValues() bool {
+	return c.WriteValuesOptions.IncludeChartValues
+}
@@ -0,0 +1,149 @@
+// Package dotenv loads environment variables from `.env` files.
+package dotenv
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Files returns the paths to the dotenv files for the environment in dir, in the order of increasing precedence:
+//
+//	.env, .helmfile.env, .env.<env>, .helmfile.<env>.env
+func Files(dir, env string) []string {
+	names := []string{".env", ".helmfile.env"}
+	if env != "" {
+		names = append(names, ".env."+env, ".helmfile."+env+".env")
+	}
+
+	var files []string
+	for _, n := range names {
+		files = append(files, filepath.Join(dir, n))
+	}
+	return files
+}
+
+// Load reads the files in order and sets the variables to the process environment.
+// Files that don't exist are skipped.
+// Variables defined in a later file override those in an earlier file,
+// but variables already set in the process environment before calling Load are never overridden.
+func Load(files ...string) error {
+	vars := map[string]string{}
+
+	for _, f := range files {
+		vs, err := readFile(f)
+		if err != nil {
+			return err
+		}
+		for k, v := range vs {
+			vars[k] = v
+		}
+	}
+
+	for k, v := range vars {
+		if _, ok := os.LookupEnv(k); ok {
+			continue
+		}
+		if err := os.Setenv(k, v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func readFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	vars, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return vars, nil
+}
+
+// Parse parses the dotenv content.
+//
+// Each line is in the form of `KEY=VALUE`, optionally prefixed with `export `.
+// Empty lines and lines starting with `#` are ignored.
+// A value can be enclosed in single or double quotes. Escape sequences like `\n` are expanded only within double quotes.
+func Parse(r io.Reader) (map[string]string, error) {
+	vars := map[string]string{}
+
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = strings.TrimPrefix(line, "export ")
+
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE, but got %q", lineNum, line)
+		}
+
+		key := strings.TrimSpace(kv[0])
+		if key == "" {
+			return nil, fmt.Errorf("line %d: missing key", lineNum)
+		}
+
+		value, err := parseValue(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNum, err)
+		}
+
+		vars[key] = value
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return vars, nil
+}
+
+func parseValue(v string) (string, error) {
+	if v == "" {
+		return "", nil
+	}
+
+	switch q := v[0]; q {
+	case '"', '\'':
+		end := strings.LastIndexByte(v, q)
+		if end == 0 {
+			return "", fmt.Errorf("unterminated quoted value %s", v)
+		}
+		if rest := strings.TrimSpace(v[end+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+			return "", fmt.Errorf("unexpected characters after the quoted value: %s", rest)
+		}
+		unquoted := v[1:end]
+		if q == '"' {
+			unquoted = strings.NewReplacer(`\n`, "\n", `\t`, "\t", `\"`, `"`, `\\`, `\`).Replace(unquoted)
+		}
+		return unquoted, nil
+	}
+
+	// Strip the inline comment from the unquoted value
+	if i := strings.Index(v, " #"); i >= 0 {
+		v = strings.TrimSpace(v[:i])
+	}
+
+	return v, nil
+}
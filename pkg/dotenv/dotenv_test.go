@@ -0,0 +1,75 @@
+package dotenv
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	content := `
+# comment
+FOO=foo
+export BAR = bar
+EMPTY=
+SINGLE='a \n b'
+DOUBLE="a\nb" # comment
+INLINE=value # comment
+WITH_EQ=a=b
+`
+	vars, err := Parse(strings.NewReader(content))
+	require.NoError(t, err)
+
+	require.Equal(t, map[string]string{
+		"FOO":     "foo",
+		"BAR":     "bar",
+		"EMPTY":   "",
+		"SINGLE":  `a \n b`,
+		"DOUBLE":  "a\nb",
+		"INLINE":  "value",
+		"WITH_EQ": "a=b",
+	}, vars)
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{content: "FOO", want: `line 1: expected KEY=VALUE, but got "FOO"`},
+		{content: "\n=foo", want: "line 2: missing key"},
+		{content: `FOO="foo`, want: `line 1: unterminated quoted value "foo`},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(strings.NewReader(tt.content))
+		require.EqualError(t, err, tt.want)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	write := func(name, content string) {
+		t.Helper()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	write(".env", "DOTENV_TEST_A=env\nDOTENV_TEST_B=env\nDOTENV_TEST_C=env\n")
+	write(".helmfile.prod.env", "DOTENV_TEST_B=prod\n")
+
+	t.Setenv("DOTENV_TEST_C", "process")
+	for _, k := range []string{"DOTENV_TEST_A", "DOTENV_TEST_B"} {
+		k := k
+		t.Cleanup(func() { _ = os.Unsetenv(k) })
+	}
+
+	require.NoError(t, Load(Files(dir, "prod")...))
+
+	require.Equal(t, "env", os.Getenv("DOTENV_TEST_A"))
+	require.Equal(t, "prod", os.Getenv("DOTENV_TEST_B"))
+	require.Equal(t, "process", os.Getenv("DOTENV_TEST_C"))
+}
@@ -12,4 +12,9 @@ const (
 	V1Mode                        = "HELMFILE_V1MODE"
 	GoccyGoYaml                   = "HELMFILE_GOCCY_GOYAML"
 	CacheHome                     = "HELMFILE_CACHE_HOME"
+	CosignBinary                  = "HELMFILE_COSIGN_BINARY"
+	KubectlBinary                 = "HELMFILE_KUBECTL_BINARY"
+	KubeconformBinary             = "HELMFILE_KUBECONFORM_BINARY"
+	ConftestBinary                = "HELMFILE_CONFTEST_BINARY"
+	KustomizeBinary               = "HELMFILE_KUSTOMIZE_BINARY"
 )
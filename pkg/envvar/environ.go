@@ -0,0 +1,53 @@
+package envvar
+
+import (
+	"os"
+	"sync"
+)
+
+// mu guards the environment variables set temporarily by With against the commands started with Environ
+var mu sync.RWMutex
+
+// With sets the environment variables only while f runs, restoring the previous values afterwards.
+// It's for the libraries configured only by the environment variables, like the secrets backends of vals,
+// so that the variables of one helmfile don't leak to the others, or to the commands run meanwhile.
+func With(vars map[string]string, f func() error) error {
+	if len(vars) == 0 {
+		return f()
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	prev := make(map[string]*string, len(vars))
+	defer func() {
+		for name, v := range prev {
+			if v == nil {
+				os.Unsetenv(name)
+			} else {
+				os.Setenv(name, *v)
+			}
+		}
+	}()
+
+	for name, v := range vars {
+		if cur, ok := os.LookupEnv(name); ok {
+			prev[name] = &cur
+		} else {
+			prev[name] = nil
+		}
+		if err := os.Setenv(name, v); err != nil {
+			return err
+		}
+	}
+
+	return f()
+}
+
+// Environ returns the environment of the process like os.Environ, without the variables set temporarily by With
+func Environ() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return os.Environ()
+}
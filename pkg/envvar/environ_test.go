@@ -0,0 +1,31 @@
+package envvar
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWith(t *testing.T) {
+	t.Setenv("HELMFILE_TEST_WITH_SET", "ambient")
+	t.Setenv("HELMFILE_TEST_WITH_UNSET", "")
+	os.Unsetenv("HELMFILE_TEST_WITH_UNSET")
+
+	vars := map[string]string{
+		"HELMFILE_TEST_WITH_SET":   "overridden",
+		"HELMFILE_TEST_WITH_UNSET": "set",
+	}
+
+	err := With(vars, func() error {
+		for name, want := range vars {
+			require.Equal(t, want, os.Getenv(name))
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, "ambient", os.Getenv("HELMFILE_TEST_WITH_SET"))
+	_, ok := os.LookupEnv("HELMFILE_TEST_WITH_UNSET")
+	require.False(t, ok)
+}
@@ -0,0 +1,191 @@
+package errors
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// Code is the stable identifier of a class of user-facing errors,
+// so that wrappers can react to errors programmatically instead of matching error messages.
+// Codes are never renamed nor reused once released.
+type Code string
+
+const (
+	// CodeUnknown is the code of the errors not in the catalog
+	CodeUnknown Code = "unknown"
+	// CodeNoMatchingRelease is the code of the error that no release matches the selectors in any helmfile
+	CodeNoMatchingRelease Code = "no_matching_release"
+	// CodeStateLoad is the code of the errors on reading, rendering or parsing a state file
+	CodeStateLoad Code = "state_load"
+	// CodeTemplate is the code of the errors on executing the release templates of a state file
+	CodeTemplate Code = "template"
+	// CodeHook is the code of the errors on running the prerun and postrun hooks
+	CodeHook Code = "hook"
+	// CodeRepoFetch is the code of the errors on adding the chart repositories and logging in to the registries
+	CodeRepoFetch Code = "repo_fetch"
+	// CodeChartPrepare is the code of the errors on fetching, building the dependencies of, and chartifying the charts
+	CodeChartPrepare Code = "chart_prepare"
+	// CodeReleaseFailed is the code of the errors on processing a release, like helm failing to upgrade it
+	CodeReleaseFailed Code = "release_failed"
+	// CodeDiffDetected is the code of the errors on `diff --detailed-exitcode` finding a release to change
+	CodeDiffDetected Code = "diff_detected"
+	// CodeTimeout is the code of the errors on killing a helm command running longer than its timeout
+	CodeTimeout Code = "timeout"
+	// CodeSnapshotMismatch is the code of the errors on `snapshot verify` finding a release rendered differently from its snapshot
+	CodeSnapshotMismatch Code = "snapshot_mismatch"
+)
+
+// The formats of the errors printed on exit
+const (
+	// OutputText prints the error messages as they are
+	OutputText = "text"
+	// OutputJSON prints the error messages along with the codes and the structured metadata in JSON
+	OutputJSON = "json"
+)
+
+// Phases of a helmfile run, where an error occurred
+const (
+	PhaseLoad    = "load"
+	PhasePrerun  = "prerun"
+	PhaseRepos   = "repos"
+	PhasePrepare = "prepare"
+	PhasePostrun = "postrun"
+)
+
+// Detail is the structured metadata of an error, printed in the JSON error output
+type Detail struct {
+	Code      Code   `json:"code"`
+	Message   string `json:"message"`
+	StateFile string `json:"stateFile,omitempty"`
+	Release   string `json:"release,omitempty"`
+	Phase     string `json:"phase,omitempty"`
+}
+
+// Describer is implemented by the errors carrying the structured metadata.
+// The non-empty fields of the detail override the ones of the errors wrapping them.
+type Describer interface {
+	Describe() Detail
+}
+
+// CatalogError adds the stable code and the structured metadata to an error, without changing its message
+type CatalogError struct {
+	Code      Code
+	StateFile string
+	Release   string
+	Phase     string
+
+	Err error
+}
+
+func (e *CatalogError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *CatalogError) Unwrap() error {
+	return e.Err
+}
+
+func (e *CatalogError) Describe() Detail {
+	return Detail{
+		Code:      e.Code,
+		StateFile: e.StateFile,
+		Release:   e.Release,
+		Phase:     e.Phase,
+	}
+}
+
+// WithCode returns the error with the code and the phase, or nil if the error is nil
+func WithCode(code Code, phase string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &CatalogError{Code: code, Phase: phase, Err: err}
+}
+
+// Details returns the structured metadata of the root causes of the error.
+// Each root cause inherits the metadata of the errors wrapping it, unless it has its own.
+func Details(err error) []Detail {
+	if err == nil {
+		return nil
+	}
+	return details(err, Detail{})
+}
+
+func details(err error, parent Detail) []Detail {
+	d := parent
+	if describer, ok := err.(Describer); ok {
+		d = merge(d, describer.Describe())
+	}
+
+	switch e := err.(type) {
+	case interface{ Unwrap() []error }:
+		var ds []Detail
+		for _, cause := range e.Unwrap() {
+			if cause != nil {
+				ds = append(ds, details(cause, d)...)
+			}
+		}
+		if len(ds) > 0 {
+			return ds
+		}
+	case interface{ Unwrap() error }:
+		if cause := e.Unwrap(); cause != nil {
+			return details(cause, d)
+		}
+	}
+
+	if d.Code == "" {
+		d.Code = CodeUnknown
+	}
+	d.Message = err.Error()
+
+	return []Detail{d}
+}
+
+func merge(d, o Detail) Detail {
+	if o.Code != "" {
+		d.Code = o.Code
+	}
+	if o.StateFile != "" {
+		d.StateFile = o.StateFile
+	}
+	if o.Release != "" {
+		d.Release = o.Release
+	}
+	if o.Phase != "" {
+		d.Phase = o.Phase
+	}
+	return d
+}
+
+// jsonError is the JSON error output
+type jsonError struct {
+	Message  string   `json:"message"`
+	ExitCode int      `json:"exitCode"`
+	Errors   []Detail `json:"errors"`
+}
+
+// ToJSON returns the error whose message is the JSON of the message, the exit code, and the structured metadata of the error,
+// keeping the exit code
+func ToJSON(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	exitCode := 3
+	var exitCoder ExitCoder
+	if errors.As(err, &exitCoder) {
+		exitCode = exitCoder.ExitCode()
+	}
+
+	bs, jsonErr := json.Marshal(jsonError{
+		Message:  err.Error(),
+		ExitCode: exitCode,
+		Errors:   Details(err),
+	})
+	if jsonErr != nil {
+		return err
+	}
+
+	return NewExitError(string(bs), exitCode)
+}
@@ -0,0 +1,77 @@
+package errors
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// multiError is an error of multiple causes with the state file, like the ones of helmfile runs
+type multiError struct {
+	stateFile string
+	errs      []error
+}
+
+func (e *multiError) Error() string {
+	return fmt.Sprintf("%d errors", len(e.errs))
+}
+
+func (e *multiError) Unwrap() []error {
+	return e.errs
+}
+
+func (e *multiError) Describe() Detail {
+	return Detail{StateFile: e.stateFile}
+}
+
+func TestDetails(t *testing.T) {
+	require.Nil(t, Details(nil))
+
+	require.Equal(t, []Detail{{Code: CodeUnknown, Message: "unexpected"}}, Details(fmt.Errorf("unexpected")))
+
+	err := NewExitError(&multiError{
+		stateFile: "helmfile.yaml",
+		errs: []error{
+			WithCode(CodeRepoFetch, PhaseRepos, fmt.Errorf("wrapped: %w", fmt.Errorf("looks like \"https://charts.example.com\" is not a valid chart repository"))),
+			&CatalogError{Code: CodeReleaseFailed, Release: "default/web", Err: fmt.Errorf("failed processing release web")},
+			nil,
+		},
+	}, 1)
+
+	require.Equal(t, []Detail{
+		{
+			Code:      CodeRepoFetch,
+			Message:   "looks like \"https://charts.example.com\" is not a valid chart repository",
+			StateFile: "helmfile.yaml",
+			Phase:     PhaseRepos,
+		},
+		{
+			Code:      CodeReleaseFailed,
+			Message:   "failed processing release web",
+			StateFile: "helmfile.yaml",
+			Release:   "default/web",
+		},
+	}, Details(err))
+}
+
+func TestToJSON(t *testing.T) {
+	require.NoError(t, ToJSON(nil))
+
+	err := ToJSON(NewExitError(WithCode(CodeNoMatchingRelease, "", fmt.Errorf("no releases found")), 3))
+
+	require.Equal(t, 3, err.(ExitCoder).ExitCode())
+
+	var out map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(err.Error()), &out))
+	require.Equal(t, map[string]interface{}{
+		"message":  "no releases found",
+		"exitCode": float64(3),
+		"errors": []interface{}{
+			map[string]interface{}{"code": "no_matching_release", "message": "no releases found"},
+		},
+	}, out)
+
+	require.Equal(t, 3, ToJSON(fmt.Errorf("unknown flag")).(ExitCoder).ExitCode())
+}
@@ -74,6 +74,14 @@ func (ee *ExitError) ExitCode() int {
 	return ee.exitCode
 }
 
+// Unwrap returns the message if it is an error
+func (ee *ExitError) Unwrap() error {
+	if err, ok := ee.message.(error); ok {
+		return err
+	}
+	return nil
+}
+
 // HandleExitCoder checks if the error fulfills the ExitCoder interface, and if
 // so prints the error to stderr (if it is non-empty) and calls OsExiter with the
 // given exit code.  If the given error is a MultiError, then this func is
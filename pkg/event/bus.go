@@ -2,6 +2,7 @@ package event
 
 import (
 	"fmt"
+	"path/filepath"
 	"strings"
 
 	"go.uber.org/zap"
@@ -19,6 +20,33 @@ type Hook struct {
 	Kubectl  map[string]string `yaml:"kubectlApply,omitempty"`
 	Args     []string          `yaml:"args"`
 	ShowLogs bool              `yaml:"showlogs"`
+	// WriteFile writes the file rendered from the template in-process, instead of running the command
+	WriteFile *WriteFileHook `yaml:"writeFile,omitempty"`
+}
+
+// WriteFileHook renders the Go template with the same data as the args of the hooks, and writes it to the file
+type WriteFileHook struct {
+	// Path is the file to write, relative to the directory of the helmfile, which is rendered as a template too
+	Path string `yaml:"path"`
+	// Content is the template of the content of the file
+	Content string `yaml:"content,omitempty"`
+	// Template is the file containing the template of the content, relative to the directory of the helmfile, which is exclusive with Content
+	Template string `yaml:"template,omitempty"`
+}
+
+// PlannedHook is the command of a hook evaluated without being executed, on the dry-runs
+type PlannedHook struct {
+	// Event is the event triggering the hook
+	Event string `json:"event"`
+	// Name is the name of the hook, which defaults to the command
+	Name string `json:"name"`
+	// Command and Args are the rendered command and arguments of the hook
+	Command string   `json:"command"`
+	Args    []string `json:"args"`
+	// Dir is the working directory the command would run in
+	Dir string `json:"dir"`
+	// Environment is the name of the helmfile environment the hook is rendered with
+	Environment string `json:"environment"`
 }
 
 type event struct {
@@ -37,8 +65,15 @@ type Bus struct {
 
 	Env environment.Environment
 	Fs  *filesystem.FileSystem
+	// HelmBinary is the helm binary run by `renderChart` and `chartValues` in the templates of the hooks
+	HelmBinary string
+	// ClusterLookups is whether the templates of the hooks are allowed to query the cluster with `kubectlGet`
+	ClusterLookups tmpl.ClusterLookups
 
 	Logger *zap.SugaredLogger
+
+	// Planned, if set, receives the hooks evaluated without being executed, instead of executing them
+	Planned func(PlannedHook)
 }
 
 func (bus *Bus) Trigger(evt string, evtErr error, context map[string]interface{}) (bool, error) {
@@ -64,13 +99,25 @@ func (bus *Bus) Trigger(evt string, evtErr error, context map[string]interface{}
 
 		name := hook.Name
 		if name == "" {
-			if hook.Kubectl != nil {
+			switch {
+			case hook.Kubectl != nil:
 				name = "kubectlApply"
-			} else {
+			case hook.WriteFile != nil:
+				name = "writeFile"
+			default:
 				name = hook.Command
 			}
 		}
 
+		if hook.WriteFile != nil {
+			if hook.Kubectl != nil {
+				return false, fmt.Errorf("hook[%s]: kubectlApply & writeFile cannot be used together", name)
+			}
+			if hook.Command != "" {
+				bus.Logger.Warnf("warn: ignoring command '%s' given within a writeFile hook", hook.Command)
+			}
+		}
+
 		if hook.Kubectl != nil {
 			if hook.Command != "" {
 				bus.Logger.Warnf("warn: ignoring command '%s' given within a kubectlApply hook", hook.Command)
@@ -102,9 +149,44 @@ func (bus *Bus) Trigger(evt string, evtErr error, context map[string]interface{}
 			data[k] = v
 		}
 		render := tmpl.NewTextRenderer(bus.Fs, bus.BasePath, data)
+		render.Context.SetHelmBinary(bus.HelmBinary)
+		render.Context.SetClusterLookups(bus.ClusterLookups)
 
 		bus.Logger.Debugf("hook[%s]: triggered by event \"%s\"\n", name, evt)
 
+		if hook.WriteFile != nil {
+			path, content, err := bus.renderWriteFile(hook.WriteFile, render)
+			if err != nil {
+				return false, fmt.Errorf("hook[%s]: %v", name, err)
+			}
+
+			if bus.Planned != nil {
+				bus.Logger.Debugf("hook[%s]: not executed on the dry-run\n", name)
+				bus.Planned(PlannedHook{
+					Event:       evt,
+					Name:        name,
+					Command:     "writeFile",
+					Args:        []string{path},
+					Dir:         bus.BasePath,
+					Environment: bus.Env.Name,
+				})
+				continue
+			}
+
+			if err := bus.Fs.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return false, fmt.Errorf("hook[%s]: %v", name, err)
+			}
+			if err := bus.Fs.WriteFile(path, []byte(content), 0644); err != nil {
+				return false, fmt.Errorf("hook[%s]: %v", name, err)
+			}
+
+			bus.Logger.Debugf("hook[%s]: wrote %s\n", name, path)
+
+			executed = true
+
+			continue
+		}
+
 		command, err := render.RenderTemplateText(hook.Command)
 		if err != nil {
 			return false, fmt.Errorf("hook[%s]: %v", name, err)
@@ -118,6 +200,19 @@ func (bus *Bus) Trigger(evt string, evtErr error, context map[string]interface{}
 			}
 		}
 
+		if bus.Planned != nil {
+			bus.Logger.Debugf("hook[%s]: not executed on the dry-run\n", name)
+			bus.Planned(PlannedHook{
+				Event:       evt,
+				Name:        name,
+				Command:     command,
+				Args:        args,
+				Dir:         bus.BasePath,
+				Environment: bus.Env.Name,
+			})
+			continue
+		}
+
 		bytes, err := bus.Runner.Execute(command, args, map[string]string{}, false)
 		bus.Logger.Debugf("hook[%s]: %s\n", name, string(bytes))
 		if hook.ShowLogs {
@@ -134,3 +229,43 @@ func (bus *Bus) Trigger(evt string, evtErr error, context map[string]interface{}
 
 	return executed, nil
 }
+
+// renderWriteFile renders the path and the content of the file written by the hook
+func (bus *Bus) renderWriteFile(hook *WriteFileHook, render tmpl.TextRenderer) (string, string, error) {
+	if hook.Path == "" {
+		return "", "", fmt.Errorf("writeFile: path is required")
+	}
+	if hook.Content != "" && hook.Template != "" {
+		return "", "", fmt.Errorf("writeFile: content & template cannot be used together")
+	}
+
+	path, err := render.RenderTemplateText(hook.Path)
+	if err != nil {
+		return "", "", err
+	}
+	path = bus.joinBasePath(path)
+
+	tpl := hook.Content
+	if hook.Template != "" {
+		bs, err := bus.Fs.ReadFile(bus.joinBasePath(hook.Template))
+		if err != nil {
+			return "", "", fmt.Errorf("writeFile: reading template %s: %v", hook.Template, err)
+		}
+		tpl = string(bs)
+	}
+
+	content, err := render.RenderTemplateText(tpl)
+	if err != nil {
+		return "", "", err
+	}
+
+	return path, content, nil
+}
+
+// joinBasePath returns the path relative to the directory of the helmfile, unless it's absolute
+func (bus *Bus) joinBasePath(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(bus.BasePath, path)
+}
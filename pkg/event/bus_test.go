@@ -3,6 +3,9 @@ package event
 import (
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
 
 	"go.uber.org/zap"
@@ -41,21 +44,21 @@ func TestTrigger(t *testing.T) {
 	}{
 		{
 			"okhook1",
-			&Hook{"okhook1", []string{"foo"}, "ok", nil, []string{}, true},
+			&Hook{"okhook1", []string{"foo"}, "ok", nil, []string{}, true, nil},
 			"foo",
 			true,
 			"",
 		},
 		{
 			"okhooké",
-			&Hook{"okhook2", []string{"foo"}, "ok", nil, []string{}, false},
+			&Hook{"okhook2", []string{"foo"}, "ok", nil, []string{}, false, nil},
 			"foo",
 			true,
 			"",
 		},
 		{
 			"missinghook1",
-			&Hook{"okhook1", []string{"foo"}, "ok", nil, []string{}, false},
+			&Hook{"okhook1", []string{"foo"}, "ok", nil, []string{}, false, nil},
 			"bar",
 			false,
 			"",
@@ -69,70 +72,70 @@ func TestTrigger(t *testing.T) {
 		},
 		{
 			"nghook1",
-			&Hook{"nghook1", []string{"foo"}, "ng", nil, []string{}, false},
+			&Hook{"nghook1", []string{"foo"}, "ng", nil, []string{}, false, nil},
 			"foo",
 			false,
 			"hook[nghook1]: command `ng` failed: cmd failed due to invalid cmd: ng",
 		},
 		{
 			"nghook2",
-			&Hook{"nghook2", []string{"foo"}, "ok", nil, []string{"ng"}, false},
+			&Hook{"nghook2", []string{"foo"}, "ok", nil, []string{"ng"}, false, nil},
 			"foo",
 			false,
 			"hook[nghook2]: command `ok` failed: cmd failed due to invalid arg: ng",
 		},
 		{
 			"okkubeapply1",
-			&Hook{"okkubeapply1", []string{"foo"}, "", map[string]string{"kustomize": "kustodir"}, []string{}, false},
+			&Hook{"okkubeapply1", []string{"foo"}, "", map[string]string{"kustomize": "kustodir"}, []string{}, false, nil},
 			"foo",
 			true,
 			"",
 		},
 		{
 			"okkubeapply2",
-			&Hook{"okkubeapply2", []string{"foo"}, "", map[string]string{"filename": "resource.yaml"}, []string{}, false},
+			&Hook{"okkubeapply2", []string{"foo"}, "", map[string]string{"filename": "resource.yaml"}, []string{}, false, nil},
 			"foo",
 			true,
 			"",
 		},
 		{
 			"kokubeapply",
-			&Hook{"kokubeapply", []string{"foo"}, "", map[string]string{"kustomize": "kustodir", "filename": "resource.yaml"}, []string{}, true},
+			&Hook{"kokubeapply", []string{"foo"}, "", map[string]string{"kustomize": "kustodir", "filename": "resource.yaml"}, []string{}, true, nil},
 			"foo",
 			false,
 			"hook[kokubeapply]: kustomize & filename cannot be used together",
 		},
 		{
 			"kokubeapply2",
-			&Hook{"kokubeapply2", []string{"foo"}, "", map[string]string{}, []string{}, true},
+			&Hook{"kokubeapply2", []string{"foo"}, "", map[string]string{}, []string{}, true, nil},
 			"foo",
 			false,
 			"hook[kokubeapply2]: either kustomize or filename must be given",
 		},
 		{
 			"kokubeapply3",
-			&Hook{"", []string{"foo"}, "", map[string]string{}, []string{}, true},
+			&Hook{"", []string{"foo"}, "", map[string]string{}, []string{}, true, nil},
 			"foo",
 			false,
 			"hook[kubectlApply]: either kustomize or filename must be given",
 		},
 		{
 			"warnkubeapply1",
-			&Hook{"warnkubeapply1", []string{"foo"}, "ok", map[string]string{"filename": "resource.yaml"}, []string{}, true},
+			&Hook{"warnkubeapply1", []string{"foo"}, "ok", map[string]string{"filename": "resource.yaml"}, []string{}, true, nil},
 			"foo",
 			true,
 			"",
 		},
 		{
 			"warnkubeapply2",
-			&Hook{"warnkubeapply2", []string{"foo"}, "", map[string]string{"filename": "resource.yaml"}, []string{"ng"}, true},
+			&Hook{"warnkubeapply2", []string{"foo"}, "", map[string]string{"filename": "resource.yaml"}, []string{"ng"}, true, nil},
 			"foo",
 			true,
 			"",
 		},
 		{
 			"warnkubeapply3",
-			&Hook{"warnkubeapply3", []string{"foo"}, "ok", map[string]string{"filename": "resource.yaml"}, []string{"ng"}, true},
+			&Hook{"warnkubeapply3", []string{"foo"}, "ok", map[string]string{"filename": "resource.yaml"}, []string{"ng"}, true, nil},
 			"foo",
 			true,
 			"",
@@ -185,3 +188,116 @@ func TestTrigger(t *testing.T) {
 		}
 	}
 }
+
+func TestTrigger_Planned(t *testing.T) {
+	readFile := func(filename string) ([]byte, error) {
+		return nil, fmt.Errorf("unexpected call to readFile: %s", filename)
+	}
+
+	var planned []PlannedHook
+
+	bus := &Bus{
+		Hooks: []Hook{
+			{Name: "migrate", Events: []string{"presync"}, Command: "./migrate.sh", Args: []string{"--env", "{{ .Environment.Name }}", "--release", "{{ .Release }}"}},
+			{Events: []string{"presync"}, Kubectl: map[string]string{"filename": "crds.yaml"}},
+			{Name: "notify", Events: []string{"postsync"}, Command: "ng"},
+		},
+		StateFilePath: "path/to/helmfile.yaml",
+		BasePath:      "path/to",
+		Namespace:     "myns",
+		Env:           environment.Environment{Name: "prod"},
+		Logger:        zap.NewNop().Sugar(),
+		Fs:            &ffs.FileSystem{ReadFile: readFile},
+		Runner:        &runner{},
+		Planned: func(h PlannedHook) {
+			planned = append(planned, h)
+		},
+	}
+
+	ok, err := bus.Trigger("presync", nil, map[string]interface{}{"Release": "myrel"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ok {
+		t.Errorf("the hooks must not be executed on the dry-run")
+	}
+
+	want := []PlannedHook{
+		{Event: "presync", Name: "migrate", Command: "./migrate.sh", Args: []string{"--env", "prod", "--release", "myrel"}, Dir: "path/to", Environment: "prod"},
+		{Event: "presync", Name: "kubectlApply", Command: "kubectl", Args: []string{"apply", "-f", "crds.yaml"}, Dir: "path/to", Environment: "prod"},
+	}
+	if !reflect.DeepEqual(planned, want) {
+		t.Errorf("unexpected planned hooks: expected=%v, actual=%v", want, planned)
+	}
+}
+
+func TestTrigger_WriteFile(t *testing.T) {
+	dir := t.TempDir()
+
+	readFile := func(filename string) ([]byte, error) {
+		if filename == filepath.Join(dir, "notify.tpl") {
+			return []byte(`{{ .Release.Name }} failed: {{ .Event.Error }}`), nil
+		}
+		return nil, fmt.Errorf("unexpected call to readFile: %s", filename)
+	}
+
+	dirs := map[string]bool{}
+	mkdirAll := func(dir string, _ os.FileMode) error {
+		dirs[dir] = true
+		return nil
+	}
+
+	written := map[string]string{}
+	writeFile := func(filename string, data []byte, _ os.FileMode) error {
+		if !dirs[filepath.Dir(filename)] {
+			return fmt.Errorf("unexpected call to writeFile before creating its directory: %s", filename)
+		}
+		written[filename] = string(data)
+		return nil
+	}
+
+	bus := &Bus{
+		Hooks: []Hook{
+			{Events: []string{"postdiff"}, WriteFile: &WriteFileHook{Path: "out/{{ .Release.Name }}.txt", Content: `changed={{ .Diff.Changed }}`}},
+			{Events: []string{"onFailure"}, WriteFile: &WriteFileHook{Path: "out/failed.txt", Template: "notify.tpl"}},
+			{Events: []string{"postsync"}, WriteFile: &WriteFileHook{Content: "no path"}},
+		},
+		StateFilePath: filepath.Join(dir, "helmfile.yaml"),
+		BasePath:      dir,
+		Namespace:     "myns",
+		Env:           environment.Environment{Name: "prod"},
+		Logger:        zap.NewNop().Sugar(),
+		Fs:            &ffs.FileSystem{ReadFile: readFile, WriteFile: writeFile, MkdirAll: mkdirAll},
+		Runner:        &runner{},
+	}
+
+	release := map[string]interface{}{"Name": "myrel"}
+
+	ok, err := bus.Trigger("postdiff", nil, map[string]interface{}{"Release": release, "Diff": map[string]interface{}{"Changed": true}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !ok {
+		t.Errorf("the writeFile hook must be executed")
+	}
+
+	ok, err = bus.Trigger("onFailure", fmt.Errorf("timed out"), map[string]interface{}{"Release": release})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !ok {
+		t.Errorf("the writeFile hook must be executed")
+	}
+
+	want := map[string]string{
+		filepath.Join(dir, "out", "myrel.txt"):  "changed=true",
+		filepath.Join(dir, "out", "failed.txt"): "myrel failed: timed out",
+	}
+	if !reflect.DeepEqual(written, want) {
+		t.Errorf("unexpected written files: expected=%v, actual=%v", want, written)
+	}
+
+	if _, err := bus.Trigger("postsync", nil, map[string]interface{}{"Release": release}); err == nil || err.Error() != "hook[writeFile]: writeFile: path is required" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
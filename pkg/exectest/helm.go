@@ -1,6 +1,7 @@
 package exectest
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
@@ -30,6 +31,7 @@ type DiffKey struct {
 type Helm struct {
 	Charts               []string
 	Repo                 []string
+	Registry             []string
 	Releases             []Release
 	Deleted              []Release
 	Linted               []Release
@@ -37,11 +39,16 @@ type Helm struct {
 	Lists                map[ListKey]string
 	Diffs                map[DiffKey]error
 	Diffed               []Release
+	Execs                [][]string
 	FailOnUnexpectedDiff bool
 	FailOnUnexpectedList bool
 	Version              *semver.Version
 
 	UpdateDepsCallbacks map[string]func(string) error
+	// Digests are the digests of the OCI chart references like `registry/app:1.0.0`
+	Digests map[string]string
+	// ChartValues are the default values of the remote charts shown by `helm show values`
+	ChartValues map[string]string
 
 	DiffMutex     *sync.Mutex
 	ChartsMutex   *sync.Mutex
@@ -61,7 +68,7 @@ type Affected struct {
 	Failed   []*Release
 }
 
-func (helm *Helm) UpdateDeps(chart string) error {
+func (helm *Helm) UpdateDeps(ctx context.Context, chart string) error {
 	if strings.Contains(chart, "error") {
 		return fmt.Errorf("simulated UpdateDeps failure for chart: %s", chart)
 	}
@@ -78,7 +85,7 @@ func (helm *Helm) UpdateDeps(chart string) error {
 	return nil
 }
 
-func (helm *Helm) BuildDeps(name, chart string, flags ...string) error {
+func (helm *Helm) BuildDeps(ctx context.Context, name, chart string, flags ...string) error {
 	if strings.Contains(chart, "error") {
 		return errors.New("error")
 	}
@@ -97,14 +104,15 @@ func (helm *Helm) SetPostRenderer(postRenderer string) {
 func (helm *Helm) GetPostRenderer() string {
 	return ""
 }
-func (helm *Helm) AddRepo(name, repository, cafile, certfile, keyfile, username, password string, managed string, passCredentials string, skipTLSVerify string) error {
+func (helm *Helm) AddRepo(ctx context.Context, name, repository, cafile, certfile, keyfile, username, password string, managed string, passCredentials string, skipTLSVerify string) error {
 	helm.Repo = []string{name, repository, cafile, certfile, keyfile, username, password, managed, passCredentials, skipTLSVerify}
 	return nil
 }
-func (helm *Helm) UpdateRepo() error {
+func (helm *Helm) UpdateRepo(ctx context.Context) error {
 	return nil
 }
-func (helm *Helm) RegistryLogin(name string, username string, password string) error {
+func (helm *Helm) RegistryLogin(ctx context.Context, name string, username string, password string, flags ...string) error {
+	helm.Registry = append([]string{name, username, password}, flags...)
 	return nil
 }
 func (helm *Helm) SyncRelease(context helmexec.HelmContext, name, chart string, flags ...string) error {
@@ -181,29 +189,49 @@ func (helm *Helm) TestRelease(context helmexec.HelmContext, name string, flags .
 	helm.Releases = append(helm.Releases, Release{Name: name, Flags: flags})
 	return nil
 }
-func (helm *Helm) Fetch(chart string, flags ...string) error {
+func (helm *Helm) Exec(context helmexec.HelmContext, args ...string) error {
+	for _, a := range args {
+		if strings.Contains(a, "error") {
+			return errors.New("error")
+		}
+	}
+	helm.sync(helm.ReleasesMutex, func() {
+		helm.Execs = append(helm.Execs, args)
+	})
+	if context.Writer != nil {
+		fmt.Fprintln(context.Writer, strings.Join(args, " "))
+	}
+	return nil
+}
+func (helm *Helm) Fetch(ctx context.Context, chart string, flags ...string) error {
 	return nil
 }
-func (helm *Helm) Lint(name, chart string, flags ...string) error {
+func (helm *Helm) Lint(ctx context.Context, name, chart string, flags ...string) error {
 	if strings.Contains(name, "error") {
 		return errors.New("error")
 	}
 	helm.Linted = append(helm.Linted, Release{Name: name, Flags: flags})
 	return nil
 }
-func (helm *Helm) TemplateRelease(name, chart string, flags ...string) error {
+func (helm *Helm) TemplateRelease(context helmexec.HelmContext, name, chart string, flags ...string) error {
 	if strings.Contains(name, "error") {
 		return errors.New("error")
 	}
 	helm.Templated = append(helm.Templated, Release{Name: name, Flags: flags})
 	return nil
 }
-func (helm *Helm) ChartPull(chart string, path string, flags ...string) error {
+func (helm *Helm) ChartPull(ctx context.Context, chart string, path string, flags ...string) error {
 	return nil
 }
-func (helm *Helm) ChartExport(chart string, path string, flags ...string) error {
+func (helm *Helm) ChartExport(ctx context.Context, chart string, path string, flags ...string) error {
 	return nil
 }
+func (helm *Helm) ChartDigest(ctx context.Context, chart string, flags ...string) (string, error) {
+	if digest, ok := helm.Digests[chart]; ok {
+		return digest, nil
+	}
+	return "", fmt.Errorf("no digest found for %s", chart)
+}
 func (helm *Helm) IsHelm3() bool {
 	if helm.Version == nil {
 		return helm.Helm3
@@ -237,7 +265,7 @@ func (helm *Helm) sync(m *sync.Mutex, f func()) {
 	f()
 }
 
-func (helm *Helm) ShowChart(chartPath string) (chart.Metadata, error) {
+func (helm *Helm) ShowChart(ctx context.Context, chartPath string, flags ...string) (chart.Metadata, error) {
 	switch chartPath {
 	case "../../foo-bar":
 		return chart.Metadata{Version: "3.2.0"}, nil
@@ -245,3 +273,11 @@ func (helm *Helm) ShowChart(chartPath string) (chart.Metadata, error) {
 		return chart.Metadata{}, errors.New("fake test error")
 	}
 }
+
+func (helm *Helm) ShowValues(ctx context.Context, chartPath string, flags ...string) (string, error) {
+	values, ok := helm.ChartValues[chartPath]
+	if !ok {
+		return "", fmt.Errorf("no default values for chart %s", chartPath)
+	}
+	return values, nil
+}
@@ -0,0 +1,49 @@
+package exectest
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Runner is the helmexec.Runner recording the commands it executes, each as the command and its arguments joined with spaces,
+// and returning their canned outputs
+type Runner struct {
+	Calls []string
+	// Outputs are the outputs of the commands
+	Outputs map[string]string
+	// Sequences are the outputs of the successive executions of the commands, whose last one is repeated,
+	// which take precedence over Outputs
+	Sequences map[string][]string
+	// Failures are the commands failing with `exit status 1` along with their outputs
+	Failures map[string]bool
+
+	mu sync.Mutex
+}
+
+func (r *Runner) ExecuteStdIn(cmd string, args []string, env map[string]string, stdin io.Reader) ([]byte, error) {
+	return r.Execute(cmd, args, env, false)
+}
+
+func (r *Runner) Execute(cmd string, args []string, env map[string]string, enableLiveOutput bool) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	call := cmd + " " + strings.Join(args, " ")
+	r.Calls = append(r.Calls, call)
+
+	out := r.Outputs[call]
+	if seq := r.Sequences[call]; len(seq) > 0 {
+		out = seq[0]
+		if len(seq) > 1 {
+			r.Sequences[call] = seq[1:]
+		}
+	}
+
+	if r.Failures[call] {
+		return []byte(out), errors.New("exit status 1")
+	}
+
+	return []byte(out), nil
+}
@@ -0,0 +1,249 @@
+// Package expr evaluates the conditions on the values, like `.Values.region == "eu" && .Values.tier != "dev"`.
+package expr
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// EvalBool evaluates the expression against the data, whose keys are referred by the paths like `.Values.region`,
+// and returns whether the result is true.
+//
+// The expression supports the paths, the double-quoted strings, `true`, `false`, `==`, `!=`, `&&`, `||`, `!`, and the parentheses.
+// The paths to the missing keys evaluate to nil.
+// A value is true unless it's false, 0, nil, or an empty string, list, or map, like `if` of the templates.
+func EvalBool(expression string, data map[string]interface{}) (bool, error) {
+	p := &parser{s: expression, data: data}
+
+	v, err := p.parseOr()
+	if err != nil {
+		return false, err
+	}
+
+	if p.skipSpaces(); p.pos < len(p.s) {
+		return false, p.unexpected()
+	}
+
+	return truthy(v), nil
+}
+
+// parser is a recursive descent parser evaluating the expression as it parses it
+type parser struct {
+	s    string
+	pos  int
+	data map[string]interface{}
+}
+
+func (p *parser) skipSpaces() {
+	for p.pos < len(p.s) && strings.ContainsRune(" \t\r\n", rune(p.s[p.pos])) {
+		p.pos++
+	}
+}
+
+// accept consumes the operator if the expression continues with it
+func (p *parser) accept(op string) bool {
+	p.skipSpaces()
+	if strings.HasPrefix(p.s[p.pos:], op) {
+		p.pos += len(op)
+		return true
+	}
+	return false
+}
+
+func (p *parser) unexpected() error {
+	if p.pos >= len(p.s) {
+		return fmt.Errorf("unexpected end of expression at %d", p.pos)
+	}
+
+	word := p.s[p.pos:]
+	if i := strings.IndexAny(word, " \t\r\n"); i > 0 {
+		word = word[:i]
+	}
+
+	return fmt.Errorf("unexpected %q at %d", word, p.pos)
+}
+
+// parseOr parses `and ( "||" and )*`. Both sides are always evaluated, so that the errors aren't hidden by the short circuit.
+func (p *parser) parseOr() (interface{}, error) {
+	v, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.accept("||") {
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		v = truthy(v) || truthy(r)
+	}
+
+	return v, nil
+}
+
+// parseAnd parses `unary ( "&&" unary )*`
+func (p *parser) parseAnd() (interface{}, error) {
+	v, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.accept("&&") {
+		r, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		v = truthy(v) && truthy(r)
+	}
+
+	return v, nil
+}
+
+// parseUnary parses `"!" unary | comparison`
+func (p *parser) parseUnary() (interface{}, error) {
+	if p.accept("!") {
+		v, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return !truthy(v), nil
+	}
+
+	return p.parseComparison()
+}
+
+// parseComparison parses `primary ( ( "==" | "!=" ) primary )?`
+func (p *parser) parseComparison() (interface{}, error) {
+	l, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	var negate bool
+	switch {
+	case p.accept("=="):
+	case p.accept("!="):
+		negate = true
+	default:
+		return l, nil
+	}
+
+	r, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	return reflect.DeepEqual(l, r) != negate, nil
+}
+
+// parsePrimary parses `"(" or ")" | path | string | true | false`
+func (p *parser) parsePrimary() (interface{}, error) {
+	p.skipSpaces()
+	start := p.pos
+
+	switch {
+	case p.accept("("):
+		v, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, fmt.Errorf("missing ) at %d", p.pos)
+		}
+		return v, nil
+	case p.accept("."):
+		var keys []string
+		for {
+			key := p.ident()
+			if key == "" {
+				return nil, fmt.Errorf("missing key after %q at %d", p.s[start:p.pos], p.pos)
+			}
+			keys = append(keys, key)
+			if p.pos >= len(p.s) || p.s[p.pos] != '.' {
+				break
+			}
+			p.pos++
+		}
+		return lookup(p.data, keys), nil
+	case p.pos < len(p.s) && p.s[p.pos] == '"':
+		p.pos++
+		for p.pos < len(p.s) && p.s[p.pos] != '"' {
+			if p.s[p.pos] == '\\' {
+				p.pos++
+			}
+			p.pos++
+		}
+		if p.pos >= len(p.s) {
+			return nil, fmt.Errorf("unterminated string at %d", start)
+		}
+		p.pos++
+		s, err := strconv.Unquote(p.s[start:p.pos])
+		if err != nil {
+			return nil, fmt.Errorf("invalid string %s at %d: %v", p.s[start:p.pos], start, err)
+		}
+		return s, nil
+	}
+
+	switch id := p.ident(); id {
+	case "":
+		return nil, p.unexpected()
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	default:
+		return nil, fmt.Errorf("unknown identifier %q at %d. Refer to the values with the paths like .Values.%s", id, start, id)
+	}
+}
+
+// ident consumes the identifier or the key of the values at the position
+func (p *parser) ident() string {
+	start := p.pos
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		if c != '_' && c != '-' && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			break
+		}
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+// lookup returns the value at the keys of the nested maps, or nil when any of them is missing
+func lookup(v interface{}, keys []string) interface{} {
+	for _, k := range keys {
+		switch m := v.(type) {
+		case map[string]interface{}:
+			v = m[k]
+		case map[interface{}]interface{}:
+			v = m[k]
+		default:
+			return nil
+		}
+	}
+	return v
+}
+
+func truthy(v interface{}) bool {
+	if v == nil {
+		return false
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Bool:
+		return rv.Bool()
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		return rv.Len() > 0
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int() != 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return rv.Uint() != 0
+	case reflect.Float32, reflect.Float64:
+		return rv.Float() != 0
+	}
+
+	return true
+}
@@ -0,0 +1,73 @@
+package expr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvalBool(t *testing.T) {
+	data := map[string]interface{}{
+		"Values": map[string]interface{}{
+			"region":   "eu",
+			"tier":     "prod",
+			"replicas": 3,
+			"quoted":   `say "hi"`,
+			"ingress": map[string]interface{}{
+				"enabled": true,
+				"hosts":   []interface{}{},
+			},
+			"legacy": map[interface{}]interface{}{"enabled": false},
+		},
+		"Environment": map[string]interface{}{"Name": "production"},
+	}
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{expr: `.Values.region == "eu" && .Values.tier != "dev"`, want: true},
+		{expr: `.Values.region == "us" || .Values.tier == "dev"`, want: false},
+		{expr: `.Values.ingress.enabled`, want: true},
+		{expr: `!.Values.ingress.enabled`, want: false},
+		{expr: `.Values.ingress.hosts`, want: false},
+		{expr: `.Values.legacy.enabled == false`, want: true},
+		{expr: `.Values.missing.enabled`, want: false},
+		{expr: `.Values.replicas`, want: true},
+		{expr: `.Values.region != .Values.tier`, want: true},
+		{expr: `.Values.quoted == "say \"hi\""`, want: true},
+		{expr: `!(.Values.region == "eu" && .Environment.Name == "staging") && (.Values.tier == "prod" || false)`, want: true},
+	}
+
+	for _, tt := range tests {
+		got, err := EvalBool(tt.expr, data)
+		require.NoError(t, err, tt.expr)
+		require.Equal(t, tt.want, got, tt.expr)
+	}
+}
+
+func TestEvalBool_Errors(t *testing.T) {
+	data := map[string]interface{}{
+		"Values": map[string]interface{}{"region": "eu", "replicas": 3},
+	}
+
+	tests := []struct {
+		expr string
+		err  string
+	}{
+		{expr: `.Values.region == "eu`, err: "unterminated string at 18"},
+		{expr: `(.Values.region == "eu"`, err: "missing ) at 23"},
+		{expr: `.Values.region = "eu"`, err: `unexpected "=" at 15`},
+		{expr: `region == "eu"`, err: `unknown identifier "region" at 0. Refer to the values with the paths like .Values.region`},
+		{expr: `.Values.replicas > 2`, err: `unexpected ">" at 17`},
+		{expr: `.Values.region == 'eu'`, err: `unexpected "'eu'" at 18`},
+		{expr: `.Values.region ==`, err: `unexpected end of expression at 17`},
+		{expr: `.Values. == "eu"`, err: `missing key after ".Values." at 8`},
+		{expr: `.Values.region "eu"`, err: `unexpected "\"eu\"" at 15`},
+	}
+
+	for _, tt := range tests {
+		_, err := EvalBool(tt.expr, data)
+		require.EqualError(t, err, tt.err, tt.expr)
+	}
+}
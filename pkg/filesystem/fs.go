@@ -25,6 +25,8 @@ func (fs fileStat) Sys() any           { return nil }
 type FileSystem struct {
 	ReadFile          func(string) ([]byte, error)
 	ReadDir           func(string) ([]fs.DirEntry, error)
+	WriteFile         func(string, []byte, fs.FileMode) error
+	MkdirAll          func(string, fs.FileMode) error
 	DeleteFile        func(string) error
 	FileExists        func(string) (bool, error)
 	Glob              func(string) ([]string, error)
@@ -39,6 +41,8 @@ type FileSystem struct {
 func DefaultFileSystem() *FileSystem {
 	dfs := FileSystem{
 		ReadDir:    os.ReadDir,
+		WriteFile:  os.WriteFile,
+		MkdirAll:   os.MkdirAll,
 		DeleteFile: os.Remove,
 		Stat:       os.Stat,
 		Glob:       filepath.Glob,
@@ -64,6 +68,12 @@ func FromFileSystem(params FileSystem) *FileSystem {
 	if params.ReadDir != nil {
 		dfs.ReadDir = params.ReadDir
 	}
+	if params.WriteFile != nil {
+		dfs.WriteFile = params.WriteFile
+	}
+	if params.MkdirAll != nil {
+		dfs.MkdirAll = params.MkdirAll
+	}
 	if params.DeleteFile != nil {
 		dfs.DeleteFile = params.DeleteFile
 	}
@@ -1,11 +1,23 @@
 package helmexec
 
 import (
+	"context"
 	"io"
+	"time"
 )
 
 type HelmContext struct {
 	HistoryMax  int
 	WorkerIndex int
 	Writer      io.Writer
+	// Timeout kills the helm command of the release running longer than it,
+	// taking precedence over the timeouts of the command types. Zero means no timeout of the release
+	Timeout time.Duration
+	// LiveOutput overrides whether to stream the outputs of the helm command of the release, if set
+	LiveOutput *bool
+	// Env is added to the environment of the helm command of the release, like the configuration of the secrets backends
+	Env map[string]string
+	// Context kills the helm command of the release once it is done, failing the command without running it when it's done already.
+	// Nil means the command is never killed by a context
+	Context context.Context
 }
@@ -2,6 +2,7 @@ package helmexec
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"net/url"
@@ -10,6 +11,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/Masterminds/semver/v3"
 	"go.uber.org/zap"
@@ -17,9 +19,9 @@ import (
 	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/cli"
 	"helm.sh/helm/v3/pkg/plugin"
+	k8syaml "sigs.k8s.io/yaml"
 
 	"github.com/helmfile/helmfile/pkg/envvar"
-	"github.com/helmfile/helmfile/pkg/yaml"
 )
 
 type decryptedSecret struct {
@@ -37,6 +39,8 @@ type execer struct {
 	kubeContext          string
 	extra                []string
 	postRenderer         string
+	commandTimeouts      map[string]time.Duration
+	commandLiveOutputs   map[string]bool
 	decryptedSecretMutex sync.Mutex
 	decryptedSecrets     map[string]*decryptedSecret
 	writeTempFile        func([]byte) (string, error)
@@ -142,6 +146,18 @@ func (helm *execer) SetEnableLiveOutput(enableLiveOutput bool) {
 	helm.enableLiveOutput = enableLiveOutput
 }
 
+// SetCommandTimeouts sets the timeouts of the helm commands keyed by the command types like `upgrade`,
+// where the empty command type is for all the commands
+func (helm *execer) SetCommandTimeouts(timeouts map[string]time.Duration) {
+	helm.commandTimeouts = timeouts
+}
+
+// SetCommandLiveOutputs sets whether to stream the outputs of the helm commands keyed by the command types like `upgrade`,
+// which takes precedence over SetEnableLiveOutput
+func (helm *execer) SetCommandLiveOutputs(liveOutputs map[string]bool) {
+	helm.commandLiveOutputs = liveOutputs
+}
+
 func (helm *execer) SetPostRenderer(postRenderer string) {
 	helm.postRenderer = postRenderer
 }
@@ -150,7 +166,7 @@ func (helm *execer) GetPostRenderer() string {
 	return helm.postRenderer
 }
 
-func (helm *execer) AddRepo(name, repository, cafile, certfile, keyfile, username, password string, managed string, passCredentials string, skipTLSVerify string) error {
+func (helm *execer) AddRepo(ctx context.Context, name, repository, cafile, certfile, keyfile, username, password string, managed string, passCredentials string, skipTLSVerify string) error {
 	var args []string
 	var out []byte
 	var err error
@@ -190,7 +206,7 @@ func (helm *execer) AddRepo(name, repository, cafile, certfile, keyfile, usernam
 			args = append(args, "--insecure-skip-tls-verify")
 		}
 		helm.logger.Infof("Adding repo %v %v", name, repository)
-		out, err = helm.exec(args, map[string]string{}, nil)
+		out, err = helm.exec(ctx, args, map[string]string{}, nil)
 	default:
 		helm.logger.Errorf("ERROR: unknown type '%v' for repository %v", managed, name)
 		out = nil
@@ -200,14 +216,14 @@ func (helm *execer) AddRepo(name, repository, cafile, certfile, keyfile, usernam
 	return err
 }
 
-func (helm *execer) UpdateRepo() error {
+func (helm *execer) UpdateRepo(ctx context.Context) error {
 	helm.logger.Info("Updating repo")
-	out, err := helm.exec([]string{"repo", "update"}, map[string]string{}, nil)
+	out, err := helm.exec(ctx, []string{"repo", "update"}, map[string]string{}, nil)
 	helm.info(out)
 	return err
 }
 
-func (helm *execer) RegistryLogin(repository string, username string, password string) error {
+func (helm *execer) RegistryLogin(ctx context.Context, repository string, username string, password string, flags ...string) error {
 	helm.logger.Info("Logging in to registry")
 	args := []string{
 		"registry",
@@ -217,14 +233,15 @@ func (helm *execer) RegistryLogin(repository string, username string, password s
 		username,
 		"--password-stdin",
 	}
+	args = append(args, flags...)
 	buffer := bytes.Buffer{}
 	buffer.Write([]byte(fmt.Sprintf("%s\n", password)))
-	out, err := helm.execStdIn(args, map[string]string{"HELM_EXPERIMENTAL_OCI": "1"}, &buffer)
+	out, err := helm.execStdIn(ctx, args, map[string]string{"HELM_EXPERIMENTAL_OCI": "1"}, &buffer)
 	helm.info(out)
 	return err
 }
 
-func (helm *execer) BuildDeps(name, chart string, flags ...string) error {
+func (helm *execer) BuildDeps(ctx context.Context, name, chart string, flags ...string) error {
 	helm.logger.Infof("Building dependency release=%v, chart=%v", name, chart)
 	args := []string{
 		"dependency",
@@ -234,36 +251,44 @@ func (helm *execer) BuildDeps(name, chart string, flags ...string) error {
 
 	args = append(args, flags...)
 
-	out, err := helm.exec(args, map[string]string{}, nil)
+	out, err := helm.exec(ctx, args, map[string]string{}, nil)
 	helm.info(out)
 	return err
 }
 
-func (helm *execer) UpdateDeps(chart string) error {
+func (helm *execer) UpdateDeps(ctx context.Context, chart string) error {
 	helm.logger.Infof("Updating dependency %v", chart)
-	out, err := helm.exec([]string{"dependency", "update", chart}, map[string]string{}, nil)
+	out, err := helm.exec(ctx, []string{"dependency", "update", chart}, map[string]string{}, nil)
 	helm.info(out)
 	return err
 }
 
 func (helm *execer) SyncRelease(context HelmContext, name, chart string, flags ...string) error {
-	helm.logger.Infof("Upgrading release=%v, chart=%v", name, redactedURL(chart))
+	var overrideEnableLiveOutput *bool
+	if context.Writer != nil {
+		fmt.Fprintf(context.Writer, "Upgrading release=%v, chart=%v\n", name, redactedURL(chart))
+		// The output is captured to be printed along with the other outputs of the release
+		enableLiveOutput := false
+		overrideEnableLiveOutput = &enableLiveOutput
+	} else {
+		helm.logger.Infof("Upgrading release=%v, chart=%v", name, redactedURL(chart))
+	}
 	preArgs := make([]string, 0)
 	env := make(map[string]string)
 
 	flags = append(flags, "--history-max", strconv.Itoa(context.HistoryMax))
 
-	out, err := helm.exec(append(append(preArgs, "upgrade", "--install", name, chart), flags...), env, nil)
-	helm.write(nil, out)
-	return err
+	out, err := helm.execContext(context, append(append(preArgs, "upgrade", "--install", name, chart), flags...), env, overrideEnableLiveOutput)
+	helm.write(context.Writer, out)
+	return classifyWaitTimeout(err)
 }
 
 func (helm *execer) ReleaseStatus(context HelmContext, name string, flags ...string) error {
 	helm.logger.Infof("Getting status %v", name)
 	preArgs := make([]string, 0)
 	env := make(map[string]string)
-	out, err := helm.exec(append(append(preArgs, "status", name), flags...), env, nil)
-	helm.write(nil, out)
+	out, err := helm.execContext(context, append(append(preArgs, "status", name), flags...), env, nil)
+	helm.write(context.Writer, out)
 	return err
 }
 
@@ -274,7 +299,7 @@ func (helm *execer) List(context HelmContext, filter string, flags ...string) (s
 	args := []string{"list", "--filter", filter}
 
 	enableLiveOutput := false
-	out, err := helm.exec(append(append(preArgs, args...), flags...), env, &enableLiveOutput)
+	out, err := helm.execContext(context, append(append(preArgs, args...), flags...), env, &enableLiveOutput)
 	// In v2 we have been expecting `helm list FILTER` prints nothing.
 	// In v3 helm still prints the header like `NAME	NAMESPACE	REVISION	UPDATED	STATUS	CHART	APP VERSION`,
 	// which confuses helmfile's existing logic that treats any non-empty output from `helm list` is considered as the indication
@@ -323,7 +348,7 @@ func (helm *execer) DecryptSecret(context HelmContext, name string, flags ...str
 			secretArg = "decrypt"
 		}
 		enableLiveOutput := false
-		secretBytes, err := helm.exec(append(append(preArgs, "secrets", secretArg, absPath), flags...), env, &enableLiveOutput)
+		secretBytes, err := helm.execContext(context, append(append(preArgs, "secrets", secretArg, absPath), flags...), env, &enableLiveOutput)
 		if err != nil {
 			secret.err = err
 			return "", err
@@ -376,11 +401,15 @@ func (helm *execer) DecryptSecret(context HelmContext, name string, flags ...str
 	return tmpFileName, err
 }
 
-func (helm *execer) TemplateRelease(name string, chart string, flags ...string) error {
+func (helm *execer) TemplateRelease(context HelmContext, name string, chart string, flags ...string) error {
 	helm.logger.Infof("Templating release=%v, chart=%v", name, redactedURL(chart))
 	args := []string{"template", name, chart}
 
-	out, err := helm.exec(append(args, flags...), map[string]string{}, nil)
+	// The manifests are always captured, to be written to stdout for use with e.g. `helmfile template | kubectl apply -f -`,
+	// or to be post-processed before written to the writer
+	enableLiveOutput := false
+
+	out, err := helm.execContext(context, append(args, flags...), map[string]string{}, &enableLiveOutput)
 
 	var outputToFile bool
 
@@ -403,7 +432,7 @@ func (helm *execer) TemplateRelease(name string, chart string, flags ...string)
 		helm.info(out)
 	} else {
 		// Always write to stdout for use with e.g. `helmfile template | kubectl apply -f -`
-		helm.write(nil, out)
+		helm.write(context.Writer, out)
 	}
 
 	return err
@@ -423,7 +452,7 @@ func (helm *execer) DiffRelease(context HelmContext, name, chart string, suppres
 		overrideEnableLiveOutput = &enableLiveOutput
 	}
 
-	out, err := helm.exec(append(append(preArgs, "diff", "upgrade", "--allow-unreleased", name, chart), flags...), env, overrideEnableLiveOutput)
+	out, err := helm.execContext(context, append(append(preArgs, "diff", "upgrade", "--allow-unreleased", name, chart), flags...), env, overrideEnableLiveOutput)
 	// Do our best to write STDOUT only when diff existed
 	// Unfortunately, this works only when you run helmfile with `--detailed-exitcode`
 	detailedExitcodeEnabled := false
@@ -447,21 +476,21 @@ func (helm *execer) DiffRelease(context HelmContext, name, chart string, suppres
 	return err
 }
 
-func (helm *execer) Lint(name, chart string, flags ...string) error {
+func (helm *execer) Lint(ctx context.Context, name, chart string, flags ...string) error {
 	helm.logger.Infof("Linting release=%v, chart=%v", name, chart)
-	out, err := helm.exec(append([]string{"lint", chart}, flags...), map[string]string{}, nil)
+	out, err := helm.exec(ctx, append([]string{"lint", chart}, flags...), map[string]string{}, nil)
 	helm.write(nil, out)
 	return err
 }
 
-func (helm *execer) Fetch(chart string, flags ...string) error {
+func (helm *execer) Fetch(ctx context.Context, chart string, flags ...string) error {
 	helm.logger.Infof("Fetching %v", redactedURL(chart))
-	out, err := helm.exec(append([]string{"fetch", chart}, flags...), map[string]string{}, nil)
+	out, err := helm.exec(ctx, append([]string{"fetch", chart}, flags...), map[string]string{}, nil)
 	helm.info(out)
 	return err
 }
 
-func (helm *execer) ChartPull(chart string, path string, flags ...string) error {
+func (helm *execer) ChartPull(ctx context.Context, chart string, path string, flags ...string) error {
 	var helmArgs []string
 	helm.logger.Infof("Pulling %v", chart)
 	helmVersionConstraint, _ := semver.NewConstraint(">= 3.7.0")
@@ -469,16 +498,62 @@ func (helm *execer) ChartPull(chart string, path string, flags ...string) error
 		// in the 3.7.0 version, the chart pull has been replaced with helm pull
 		// https://github.com/helm/helm/releases/tag/v3.7.0
 		ociChartURL, ociChartTag := resolveOciChart(chart)
-		helmArgs = []string{"pull", ociChartURL, "--version", ociChartTag, "--destination", path, "--untar"}
+		helmArgs = []string{"pull", ociChartURL}
+		// A chart pinned by digest, like registry/helm-charts@sha256:..., is pulled without --version
+		// so that helm fetches exactly the manifest identified by the digest.
+		if !strings.Contains(ociChartURL, "@") {
+			helmArgs = append(helmArgs, "--version", ociChartTag)
+		}
+		helmArgs = append(helmArgs, "--destination", path, "--untar")
 	} else {
 		helmArgs = []string{"chart", "pull", chart}
 	}
-	out, err := helm.exec(append(helmArgs, flags...), map[string]string{"HELM_EXPERIMENTAL_OCI": "1"}, nil)
+	out, err := helm.exec(ctx, append(helmArgs, flags...), map[string]string{"HELM_EXPERIMENTAL_OCI": "1"}, nil)
 	helm.info(out)
 	return err
 }
 
-func (helm *execer) ChartExport(chart string, path string, flags ...string) error {
+// ChartDigest returns the digest of the manifest the OCI chart reference like `registry/app:1.0.0` points to,
+// which helm prints on pulling the chart
+func (helm *execer) ChartDigest(ctx context.Context, chart string, flags ...string) (string, error) {
+	helm.logger.Infof("Resolving the digest of %v", chart)
+
+	dir, err := os.MkdirTemp("", "helmfile-digest-")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	ociChartURL, ociChartTag := resolveOciChart(chart)
+	helmArgs := []string{"pull", ociChartURL}
+	if ociChartTag != "" {
+		helmArgs = append(helmArgs, "--version", ociChartTag)
+	}
+	helmArgs = append(helmArgs, "--destination", dir)
+
+	// The output is parsed, not shown
+	enableLiveOutput := false
+	out, err := helm.exec(ctx, append(helmArgs, flags...), map[string]string{"HELM_EXPERIMENTAL_OCI": "1"}, &enableLiveOutput)
+	if err != nil {
+		return "", err
+	}
+
+	return parseChartDigest(chart, out)
+}
+
+// parseChartDigest returns the digest in the output of `helm pull` like `Digest: sha256:...`
+func parseChartDigest(chart string, out []byte) (string, error) {
+	for _, line := range strings.Split(string(out), "\n") {
+		if digest, ok := strings.CutPrefix(strings.TrimSpace(line), "Digest: "); ok {
+			return digest, nil
+		}
+	}
+	return "", fmt.Errorf("no digest found in the output of pulling %s", chart)
+}
+
+func (helm *execer) ChartExport(ctx context.Context, chart string, path string, flags ...string) error {
 	helmVersionConstraint, _ := semver.NewConstraint(">= 3.7.0")
 	if helmVersionConstraint.Check(&helm.version) {
 		// in the 3.7.0 version, the chart export has been removed
@@ -488,17 +563,24 @@ func (helm *execer) ChartExport(chart string, path string, flags ...string) erro
 	var helmArgs []string
 	helm.logger.Infof("Exporting %v", chart)
 	helmArgs = []string{"chart", "export", chart, "--destination", path}
-	out, err := helm.exec(append(helmArgs, flags...), map[string]string{"HELM_EXPERIMENTAL_OCI": "1"}, nil)
+	out, err := helm.exec(ctx, append(helmArgs, flags...), map[string]string{"HELM_EXPERIMENTAL_OCI": "1"}, nil)
 	helm.info(out)
 	return err
 }
 
 func (helm *execer) DeleteRelease(context HelmContext, name string, flags ...string) error {
-	helm.logger.Infof("Deleting %v", name)
+	var overrideEnableLiveOutput *bool
+	if context.Writer != nil {
+		fmt.Fprintf(context.Writer, "Deleting %v\n", name)
+		enableLiveOutput := false
+		overrideEnableLiveOutput = &enableLiveOutput
+	} else {
+		helm.logger.Infof("Deleting %v", name)
+	}
 	preArgs := make([]string, 0)
 	env := make(map[string]string)
-	out, err := helm.exec(append(append(preArgs, "delete", name), flags...), env, nil)
-	helm.write(nil, out)
+	out, err := helm.execContext(context, append(append(preArgs, "delete", name), flags...), env, overrideEnableLiveOutput)
+	helm.write(context.Writer, out)
 	return err
 }
 
@@ -507,26 +589,50 @@ func (helm *execer) TestRelease(context HelmContext, name string, flags ...strin
 	preArgs := make([]string, 0)
 	env := make(map[string]string)
 	args := []string{"test", name}
-	out, err := helm.exec(append(append(preArgs, args...), flags...), env, nil)
-	helm.write(nil, out)
+	var overrideEnableLiveOutput *bool
+	if context.Writer != nil {
+		// The output is captured per release, so that the outputs of the releases tested concurrently don't interleave
+		enableLiveOutput := false
+		overrideEnableLiveOutput = &enableLiveOutput
+	}
+	out, err := helm.execContext(context, append(append(preArgs, args...), flags...), env, overrideEnableLiveOutput)
+	helm.write(context.Writer, out)
+	return err
+}
+
+func (helm *execer) Exec(context HelmContext, args ...string) error {
+	helm.logger.Infof("Running helm %v", strings.Join(args, " "))
+	env := make(map[string]string)
+	var overrideEnableLiveOutput *bool
+	if context.Writer != nil {
+		enableLiveOutput := false
+		overrideEnableLiveOutput = &enableLiveOutput
+	}
+	out, err := helm.execContext(context, args, env, overrideEnableLiveOutput)
+	helm.write(context.Writer, out)
 	return err
 }
 
 func (helm *execer) AddPlugin(name, path, version string) error {
 	helm.logger.Infof("Install helm plugin %v", name)
-	out, err := helm.exec([]string{"plugin", "install", path, "--version", version}, map[string]string{}, nil)
+	out, err := helm.exec(context.Background(), []string{"plugin", "install", path, "--version", version}, map[string]string{}, nil)
 	helm.info(out)
 	return err
 }
 
 func (helm *execer) UpdatePlugin(name string) error {
 	helm.logger.Infof("Update helm plugin %v", name)
-	out, err := helm.exec([]string{"plugin", "update", name}, map[string]string{}, nil)
+	out, err := helm.exec(context.Background(), []string{"plugin", "update", name}, map[string]string{}, nil)
 	helm.info(out)
 	return err
 }
 
-func (helm *execer) exec(args []string, env map[string]string, overrideEnableLiveOutput *bool) ([]byte, error) {
+func (helm *execer) exec(ctx context.Context, args []string, env map[string]string, overrideEnableLiveOutput *bool) ([]byte, error) {
+	return helm.execContext(HelmContext{Context: ctx}, args, env, overrideEnableLiveOutput)
+}
+
+// execContext runs the helm command with the timeout and the live output of the release in the context, if any
+func (helm *execer) execContext(context HelmContext, args []string, env map[string]string, overrideEnableLiveOutput *bool) ([]byte, error) {
 	cmdargs := args
 	if len(helm.extra) > 0 {
 		cmdargs = append(cmdargs, helm.extra...)
@@ -536,15 +642,39 @@ func (helm *execer) exec(args []string, env map[string]string, overrideEnableLiv
 	}
 	cmd := fmt.Sprintf("exec: %s %s", helm.helmBinary, strings.Join(cmdargs, " "))
 	helm.logger.Debug(cmd)
-	enableLiveOutput := helm.enableLiveOutput
+	enableLiveOutput := helm.liveOutput(context, args)
 	if overrideEnableLiveOutput != nil {
 		enableLiveOutput = *overrideEnableLiveOutput
 	}
+	timeout := helm.timeout(context, args)
+	if len(context.Env) > 0 {
+		merged := make(map[string]string, len(context.Env)+len(env))
+		for k, v := range context.Env {
+			merged[k] = v
+		}
+		for k, v := range env {
+			merged[k] = v
+		}
+		env = merged
+	}
+	if ctx := context.Context; ctx != nil {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("%s: %w", cmd, err)
+		}
+		if runner, ok := helm.runner.(ContextRunner); ok {
+			return runner.ExecuteContext(ctx, helm.helmBinary, cmdargs, env, enableLiveOutput, timeout)
+		}
+	}
+	if timeout > 0 {
+		if runner, ok := helm.runner.(TimeoutRunner); ok {
+			return runner.ExecuteWithTimeout(helm.helmBinary, cmdargs, env, enableLiveOutput, timeout)
+		}
+	}
 	outBytes, err := helm.runner.Execute(helm.helmBinary, cmdargs, env, enableLiveOutput)
 	return outBytes, err
 }
 
-func (helm *execer) execStdIn(args []string, env map[string]string, stdin io.Reader) ([]byte, error) {
+func (helm *execer) execStdIn(ctx context.Context, args []string, env map[string]string, stdin io.Reader) ([]byte, error) {
 	cmdargs := args
 	if len(helm.extra) > 0 {
 		cmdargs = append(cmdargs, helm.extra...)
@@ -554,6 +684,20 @@ func (helm *execer) execStdIn(args []string, env map[string]string, stdin io.Rea
 	}
 	cmd := fmt.Sprintf("exec: %s %s", helm.helmBinary, strings.Join(cmdargs, " "))
 	helm.logger.Debug(cmd)
+	timeout := helm.timeout(HelmContext{}, args)
+	if ctx != nil {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("%s: %w", cmd, err)
+		}
+		if runner, ok := helm.runner.(ContextRunner); ok {
+			return runner.ExecuteStdInContext(ctx, helm.helmBinary, cmdargs, env, stdin, timeout)
+		}
+	}
+	if timeout > 0 {
+		if runner, ok := helm.runner.(TimeoutRunner); ok {
+			return runner.ExecuteStdInWithTimeout(helm.helmBinary, cmdargs, env, stdin, timeout)
+		}
+	}
 	outBytes, err := helm.runner.ExecuteStdIn(helm.helmBinary, cmdargs, env, stdin)
 	return outBytes, err
 }
@@ -600,6 +744,11 @@ func (helm *execer) IsVersionAtLeast(versionStr string) bool {
 }
 
 func resolveOciChart(ociChart string) (ociChartURL, ociChartTag string) {
+	// A digest like registry/helm-charts@sha256:abc is part of the URL, not a tag
+	if strings.Contains(ociChart, "@") {
+		return fmt.Sprintf("oci://%s", ociChart), ""
+	}
+
 	var urlTagIndex int
 	// Get the last : index
 	// e.g.,
@@ -617,16 +766,26 @@ func resolveOciChart(ociChart string) (ociChartURL, ociChartTag string) {
 	return ociChartURL, ociChartTag
 }
 
-func (helm *execer) ShowChart(chartPath string) (chart.Metadata, error) {
-	var helmArgs = []string{"show", "chart", chartPath}
-	out, error := helm.exec(helmArgs, map[string]string{}, nil)
+func (helm *execer) ShowChart(ctx context.Context, chartPath string, flags ...string) (chart.Metadata, error) {
+	var helmArgs = append([]string{"show", "chart", chartPath}, flags...)
+	out, error := helm.exec(ctx, helmArgs, map[string]string{}, nil)
 	if error != nil {
 		return chart.Metadata{}, error
 	}
 	var metadata chart.Metadata
-	error = yaml.Unmarshal(out, &metadata)
+	// Chart.yaml is decoded by the JSON field names of the metadata, as helm does
+	error = k8syaml.Unmarshal(out, &metadata)
 	if error != nil {
 		return chart.Metadata{}, error
 	}
 	return metadata, nil
 }
+
+// ShowValues returns the default values of the chart as YAML
+func (helm *execer) ShowValues(ctx context.Context, chartPath string, flags ...string) (string, error) {
+	out, err := helm.exec(ctx, append([]string{"show", "values", chartPath}, flags...), map[string]string{}, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
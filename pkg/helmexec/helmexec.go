@@ -1,6 +1,10 @@
 package helmexec
 
-import "helm.sh/helm/v3/pkg/chart"
+import (
+	"context"
+
+	"helm.sh/helm/v3/pkg/chart"
+)
 
 // Version represents the version of helm
 type Version struct {
@@ -17,30 +21,40 @@ type Interface interface {
 	SetPostRenderer(postRenderer string)
 	GetPostRenderer() string
 
-	AddRepo(name, repository, cafile, certfile, keyfile, username, password string, managed string, passCredentials string, skipTLSVerify string) error
-	UpdateRepo() error
-	RegistryLogin(name string, username string, password string) error
-	BuildDeps(name, chart string, flags ...string) error
-	UpdateDeps(chart string) error
+	// The helm commands taking ctx are killed once it is done, and fail without being run when it's done already.
+	// The ones taking HelmContext run in its Context the same way
+	AddRepo(ctx context.Context, name, repository, cafile, certfile, keyfile, username, password string, managed string, passCredentials string, skipTLSVerify string) error
+	UpdateRepo(ctx context.Context) error
+	RegistryLogin(ctx context.Context, name string, username string, password string, flags ...string) error
+	BuildDeps(ctx context.Context, name, chart string, flags ...string) error
+	UpdateDeps(ctx context.Context, chart string) error
 	SyncRelease(context HelmContext, name, chart string, flags ...string) error
 	DiffRelease(context HelmContext, name, chart string, suppressDiff bool, flags ...string) error
-	TemplateRelease(name, chart string, flags ...string) error
-	Fetch(chart string, flags ...string) error
-	ChartPull(chart string, path string, flags ...string) error
-	ChartExport(chart string, path string, flags ...string) error
-	Lint(name, chart string, flags ...string) error
+	TemplateRelease(context HelmContext, name, chart string, flags ...string) error
+	Fetch(ctx context.Context, chart string, flags ...string) error
+	ChartPull(ctx context.Context, chart string, path string, flags ...string) error
+	ChartExport(ctx context.Context, chart string, path string, flags ...string) error
+	ChartDigest(ctx context.Context, chart string, flags ...string) (string, error)
+	Lint(ctx context.Context, name, chart string, flags ...string) error
 	ReleaseStatus(context HelmContext, name string, flags ...string) error
 	DeleteRelease(context HelmContext, name string, flags ...string) error
 	TestRelease(context HelmContext, name string, flags ...string) error
+	Exec(context HelmContext, args ...string) error
 	List(context HelmContext, filter string, flags ...string) (string, error)
 	DecryptSecret(context HelmContext, name string, flags ...string) (string, error)
 	IsHelm3() bool
 	GetVersion() Version
 	IsVersionAtLeast(versionStr string) bool
-	ShowChart(chart string) (chart.Metadata, error)
+	ShowChart(ctx context.Context, chart string, flags ...string) (chart.Metadata, error)
+	ShowValues(ctx context.Context, chart string, flags ...string) (string, error)
 }
 
 type DependencyUpdater interface {
-	UpdateDeps(chart string) error
+	UpdateDeps(ctx context.Context, chart string) error
 	IsHelm3() bool
 }
+
+// ChartDigester resolves the OCI chart references like `registry/app:1.0.0` to the digests of their manifests
+type ChartDigester interface {
+	ChartDigest(ctx context.Context, chart string, flags ...string) (string, error)
+}
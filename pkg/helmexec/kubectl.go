@@ -0,0 +1,18 @@
+package helmexec
+
+import (
+	"os"
+
+	"github.com/helmfile/helmfile/pkg/envvar"
+)
+
+// DefaultKubectlBinary is the kubectl binary run to read and inspect the cluster unless HELMFILE_KUBECTL_BINARY is set
+const DefaultKubectlBinary = "kubectl"
+
+// KubectlBinary returns the kubectl binary run to read and inspect the cluster
+func KubectlBinary() string {
+	if bin := os.Getenv(envvar.KubectlBinary); bin != "" {
+		return bin
+	}
+	return DefaultKubectlBinary
+}
@@ -0,0 +1,26 @@
+//go:build !windows
+
+package helmexec
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+func setProcessGroup(c *exec.Cmd) {
+	c.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// interruptProcess asks the command to stop with SIGTERM, which helm handles by marking the release being installed failed,
+// sending it to the whole process group led by the command when the command has its own group
+func interruptProcess(c *exec.Cmd, group bool) error {
+	if group {
+		return syscall.Kill(-c.Process.Pid, syscall.SIGTERM)
+	}
+	return c.Process.Signal(syscall.SIGTERM)
+}
+
+// killProcessGroup kills the process group led by the command, which includes its child processes
+func killProcessGroup(c *exec.Cmd) error {
+	return syscall.Kill(-c.Process.Pid, syscall.SIGKILL)
+}
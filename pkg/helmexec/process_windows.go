@@ -0,0 +1,28 @@
+//go:build windows
+
+package helmexec
+
+import (
+	"os/exec"
+	"strconv"
+)
+
+func setProcessGroup(c *exec.Cmd) {
+}
+
+// interruptProcess stops the command. Windows has no signal to ask a console process to stop, so the command is killed,
+// along with its child processes when it has its own group
+func interruptProcess(c *exec.Cmd, group bool) error {
+	if group {
+		return killProcessGroup(c)
+	}
+	return c.Process.Kill()
+}
+
+// killProcessGroup kills the process tree of the command, which includes its child processes
+func killProcessGroup(c *exec.Cmd) error {
+	if err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(c.Process.Pid)).Run(); err != nil {
+		return c.Process.Kill()
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package helmexec
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -11,6 +12,7 @@ import (
 	"path/filepath"
 	"strings"
 	"syscall"
+	"time"
 
 	"go.uber.org/zap"
 
@@ -23,37 +25,141 @@ type Runner interface {
 	ExecuteStdIn(cmd string, args []string, env map[string]string, stdin io.Reader) ([]byte, error)
 }
 
+// TimeoutRunner is implemented by the runners able to kill the commands running longer than the timeouts,
+// along with their child processes
+type TimeoutRunner interface {
+	ExecuteWithTimeout(cmd string, args []string, env map[string]string, enableLiveOutput bool, timeout time.Duration) ([]byte, error)
+	ExecuteStdInWithTimeout(cmd string, args []string, env map[string]string, stdin io.Reader, timeout time.Duration) ([]byte, error)
+}
+
+// ContextRunner is implemented by the runners able to kill the commands once the contexts are done, or on the timeouts,
+// along with their child processes
+type ContextRunner interface {
+	ExecuteContext(ctx context.Context, cmd string, args []string, env map[string]string, enableLiveOutput bool, timeout time.Duration) ([]byte, error)
+	ExecuteStdInContext(ctx context.Context, cmd string, args []string, env map[string]string, stdin io.Reader, timeout time.Duration) ([]byte, error)
+}
+
 // ShellRunner implemention for shell commands
 type ShellRunner struct {
 	Dir string
 
 	Logger *zap.SugaredLogger
+
+	// Stdout is where the live outputs of the commands are written, which defaults to os.Stdout
+	Stdout io.Writer
 }
 
 // Execute a shell command
 func (shell ShellRunner) Execute(cmd string, args []string, env map[string]string, enableLiveOutput bool) ([]byte, error) {
-	preparedCmd := exec.Command(cmd, args...)
-	preparedCmd.Dir = shell.Dir
-	preparedCmd.Env = mergeEnv(os.Environ(), env)
+	return shell.ExecuteWithTimeout(cmd, args, env, enableLiveOutput, 0)
+}
+
+// Execute a shell command
+func (shell ShellRunner) ExecuteStdIn(cmd string, args []string, env map[string]string, stdin io.Reader) ([]byte, error) {
+	return shell.ExecuteStdInWithTimeout(cmd, args, env, stdin, 0)
+}
+
+// ExecuteWithTimeout executes a shell command, killing it and its child processes when it runs longer than the timeout.
+// Zero timeout means no timeout.
+func (shell ShellRunner) ExecuteWithTimeout(cmd string, args []string, env map[string]string, enableLiveOutput bool, timeout time.Duration) ([]byte, error) {
+	return shell.ExecuteContext(context.Background(), cmd, args, env, enableLiveOutput, timeout)
+}
+
+// ExecuteStdInWithTimeout executes a shell command with the stdin, killing it and its child processes when it runs longer than the timeout.
+// Zero timeout means no timeout.
+func (shell ShellRunner) ExecuteStdInWithTimeout(cmd string, args []string, env map[string]string, stdin io.Reader, timeout time.Duration) ([]byte, error) {
+	return shell.ExecuteStdInContext(context.Background(), cmd, args, env, stdin, timeout)
+}
+
+// ExecuteContext executes a shell command, killing it and its child processes once the context is done, or when it runs longer than the timeout.
+// Zero timeout means no timeout.
+func (shell ShellRunner) ExecuteContext(ctx context.Context, cmd string, args []string, env map[string]string, enableLiveOutput bool, timeout time.Duration) ([]byte, error) {
+	preparedCmd, done := shell.command(ctx, cmd, args, env, timeout)
 
 	if !enableLiveOutput {
-		return Output(preparedCmd, &logWriterGenerator{
+		return done(Output(preparedCmd, &logWriterGenerator{
 			log: shell.Logger,
-		})
+		}))
 	} else {
-		return LiveOutput(preparedCmd, os.Stdout)
+		stdout := shell.Stdout
+		if stdout == nil {
+			stdout = os.Stdout
+		}
+		return done(LiveOutput(preparedCmd, stdout))
 	}
 }
 
-// Execute a shell command
-func (shell ShellRunner) ExecuteStdIn(cmd string, args []string, env map[string]string, stdin io.Reader) ([]byte, error) {
-	preparedCmd := exec.Command(cmd, args...)
-	preparedCmd.Dir = shell.Dir
-	preparedCmd.Env = mergeEnv(os.Environ(), env)
+// ExecuteStdInContext executes a shell command with the stdin, killing it and its child processes once the context is done,
+// or when it runs longer than the timeout. Zero timeout means no timeout.
+func (shell ShellRunner) ExecuteStdInContext(ctx context.Context, cmd string, args []string, env map[string]string, stdin io.Reader, timeout time.Duration) ([]byte, error) {
+	preparedCmd, done := shell.command(ctx, cmd, args, env, timeout)
 	preparedCmd.Stdin = stdin
-	return Output(preparedCmd, &logWriterGenerator{
+	return done(Output(preparedCmd, &logWriterGenerator{
 		log: shell.Logger,
-	})
+	}))
+}
+
+// killGracePeriod is how long a command asked to stop, once its context is done or on its timeout, is given to exit before it's killed.
+// It gives helm the time to mark the release being installed failed, rather than leaving it pending.
+var killGracePeriod = 30 * time.Second
+
+// command prepares the command, and returns it along with the function to be called with the results of the command,
+// which turns the error into TimeoutError when the command is stopped on the timeout,
+// or into the error of the context when the command is stopped as the context is done
+func (shell ShellRunner) command(parent context.Context, cmd string, args []string, env map[string]string, timeout time.Duration) (*exec.Cmd, func([]byte, error) ([]byte, error)) {
+	// The context never done, like context.Background(), doesn't need to stop the command
+	if timeout <= 0 && parent.Done() == nil {
+		preparedCmd := exec.Command(cmd, args...)
+		preparedCmd.Dir = shell.Dir
+		preparedCmd.Env = mergeEnv(envvar.Environ(), env)
+		return preparedCmd, func(out []byte, err error) ([]byte, error) {
+			return out, err
+		}
+	}
+
+	ctx, cancel := parent, context.CancelFunc(func() {})
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(parent, timeout)
+	}
+
+	// Only the command with the timeout runs in its own process group, so that its child processes like helm plugins are stopped along with it.
+	// The others stay in the group of helmfile, so that they receive the SIGINT of the terminal themselves,
+	// and the prompts of gpg, sops and the plugins can read the terminal.
+	group := timeout > 0
+
+	preparedCmd := exec.CommandContext(ctx, cmd, args...)
+	preparedCmd.Dir = shell.Dir
+	preparedCmd.Env = mergeEnv(envvar.Environ(), env)
+	if group {
+		setProcessGroup(preparedCmd)
+	}
+	// The command is asked to stop first, and killed only when it doesn't exit within the grace period
+	preparedCmd.Cancel = func() error {
+		return interruptProcess(preparedCmd, group)
+	}
+	preparedCmd.WaitDelay = killGracePeriod
+
+	return preparedCmd, func(out []byte, err error) ([]byte, error) {
+		defer cancel()
+		if err == nil {
+			return out, nil
+		}
+		if ctx.Err() != nil && group {
+			// The child processes left in the group of the command are killed, as they may outlive it
+			_ = killProcessGroup(preparedCmd)
+		}
+		if parentErr := parent.Err(); parentErr != nil {
+			return out, fmt.Errorf("command %q is stopped: %w", strings.Join(preparedCmd.Args, " "), parentErr)
+		}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return out, &TimeoutError{
+				Path:    preparedCmd.Path,
+				Args:    preparedCmd.Args,
+				Timeout: timeout,
+			}
+		}
+		return out, err
+	}
 }
 
 func Output(c *exec.Cmd, logWriterGenerators ...*logWriterGenerator) ([]byte, error) {
@@ -104,7 +210,11 @@ func Output(c *exec.Cmd, logWriterGenerators ...*logWriterGenerator) ([]byte, er
 			exitStatus := waitStatus.ExitStatus()
 			err = newExitError(c.Path, c.Args, exitStatus, ee, stderr.String(), combined.String())
 		default:
-			panic(fmt.Sprintf("unexpected error: %v", err))
+			// The context of the command with the timeout, or the one canceled by the caller, may be done before the command starts,
+			// and the command stopped may not exit within the grace period
+			if !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, context.Canceled) && !errors.Is(err, exec.ErrWaitDelay) {
+				panic(fmt.Sprintf("unexpected error: %v", err))
+			}
 		}
 	}
 
@@ -141,7 +251,11 @@ func LiveOutput(c *exec.Cmd, stdout io.Writer) ([]byte, error) {
 			exitStatus := waitStatus.ExitStatus()
 			err = newExitError(c.Path, c.Args, exitStatus, ee, "", "")
 		default:
-			panic(fmt.Sprintf("unexpected error: %v", err))
+			// The context of the command with the timeout, or the one canceled by the caller, may be done before the command starts,
+			// and the command stopped may not exit within the grace period
+			if !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, context.Canceled) && !errors.Is(err, exec.ErrWaitDelay) {
+				panic(fmt.Sprintf("unexpected error: %v", err))
+			}
 		}
 	}
 
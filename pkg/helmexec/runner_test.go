@@ -2,10 +2,17 @@ package helmexec
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestShellRunner_Execute(t *testing.T) {
@@ -86,3 +93,111 @@ Usage:  helm template [NAME] [CHART] [flags]
 		})
 	}
 }
+
+func TestShellRunner_ExecuteWithTimeout(t *testing.T) {
+	for _, enableLiveOutput := range []bool{false, true} {
+		t.Run(fmt.Sprintf("live_output_%t", enableLiveOutput), func(t *testing.T) {
+			shell := ShellRunner{
+				Logger: NewLogger(io.Discard, "debug"),
+			}
+
+			start := time.Now()
+			// The child process inheriting the stdout keeps the command waiting unless it is killed along with the shell
+			_, err := shell.ExecuteWithTimeout("sh", []string{"-c", "sleep 30 & wait"}, map[string]string{}, enableLiveOutput, 100*time.Millisecond)
+
+			var timeoutErr *TimeoutError
+			if !errors.As(err, &timeoutErr) {
+				t.Fatalf("ExecuteWithTimeout() error = %v, want TimeoutError", err)
+			}
+			if timeoutErr.Timeout != 100*time.Millisecond {
+				t.Errorf("ExecuteWithTimeout() timeout = %s, want 100ms", timeoutErr.Timeout)
+			}
+			if elapsed := time.Since(start); elapsed > 10*time.Second {
+				t.Errorf("ExecuteWithTimeout() took %s, the child process must be killed on the timeout", elapsed)
+			}
+		})
+	}
+
+	t.Run("within_timeout", func(t *testing.T) {
+		shell := ShellRunner{
+			Logger: NewLogger(io.Discard, "debug"),
+		}
+
+		got, err := shell.ExecuteWithTimeout("echo", []string{"template"}, map[string]string{}, false, time.Minute)
+		if err != nil {
+			t.Fatalf("ExecuteWithTimeout() has produced an error = %v", err)
+		}
+		if string(got) != "template\n" {
+			t.Errorf("ExecuteWithTimeout() got = %q, want %q", got, "template\n")
+		}
+	})
+}
+
+func TestShellRunner_ExecuteContext(t *testing.T) {
+	// The shell asked to stop leaves its child process inheriting the stdout, which is killed only after the grace period
+	defer func(d time.Duration) { killGracePeriod = d }(killGracePeriod)
+	killGracePeriod = 500 * time.Millisecond
+
+	for _, enableLiveOutput := range []bool{false, true} {
+		t.Run(fmt.Sprintf("live_output_%t", enableLiveOutput), func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			shell := ShellRunner{
+				Logger: NewLogger(io.Discard, "debug"),
+				Stdout: io.Discard,
+			}
+
+			time.AfterFunc(100*time.Millisecond, cancel)
+
+			start := time.Now()
+			_, err := shell.ExecuteContext(ctx, "sh", []string{"-c", "sleep 30 & wait"}, map[string]string{}, enableLiveOutput, 0)
+
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("ExecuteContext() error = %v, want context.Canceled", err)
+			}
+			if elapsed := time.Since(start); elapsed > 10*time.Second {
+				t.Errorf("ExecuteContext() took %s, the child process must be killed on the cancellation", elapsed)
+			}
+		})
+	}
+
+	t.Run("graceful_stop", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		shell := ShellRunner{
+			Logger: NewLogger(io.Discard, "debug"),
+		}
+
+		stopped := filepath.Join(t.TempDir(), "stopped")
+
+		time.AfterFunc(100*time.Millisecond, cancel)
+
+		// The command is asked to stop with SIGTERM, so that it can clean up like helm marking the release failed
+		_, err := shell.ExecuteContext(ctx, "sh", []string{"-c", fmt.Sprintf("trap 'touch %s; exit 1' TERM; sleep 30 & wait", stopped)}, map[string]string{}, false, 0)
+
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("ExecuteContext() error = %v, want context.Canceled", err)
+		}
+		if _, err := os.Stat(stopped); err != nil {
+			t.Errorf("ExecuteContext() must ask the command to stop before killing it: %v", err)
+		}
+	})
+
+	t.Run("live_output_writer", func(t *testing.T) {
+		var stdout bytes.Buffer
+
+		shell := ShellRunner{
+			Logger: NewLogger(io.Discard, "debug"),
+			Stdout: &stdout,
+		}
+
+		if _, err := shell.ExecuteContext(context.Background(), "echo", []string{"template"}, map[string]string{}, true, 0); err != nil {
+			t.Fatalf("ExecuteContext() has produced an error = %v", err)
+		}
+		if stdout.String() != "template\n" {
+			t.Errorf("ExecuteContext() wrote %q, want %q", stdout.String(), "template\n")
+		}
+	})
+}
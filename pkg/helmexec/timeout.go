@@ -0,0 +1,116 @@
+package helmexec
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	helmfileerrors "github.com/helmfile/helmfile/pkg/errors"
+)
+
+// TimeoutError is returned when the command is killed for running longer than the timeout
+type TimeoutError struct {
+	Path    string
+	Args    []string
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("command %q killed after running longer than the timeout %s: %s", e.Path, e.Timeout, strings.Join(e.Args, " "))
+}
+
+func (e *TimeoutError) Describe() helmfileerrors.Detail {
+	return helmfileerrors.Detail{Code: helmfileerrors.CodeTimeout}
+}
+
+// ParseCommandTimeout parses the timeout of the helm commands given like `10m`, or `upgrade=30m` for the command type,
+// into the command type and the timeout. The command type is empty for the timeout of all the commands.
+func ParseCommandTimeout(s string) (string, time.Duration, error) {
+	command, value, ok := strings.Cut(s, "=")
+	if !ok {
+		command, value = "", s
+	}
+
+	timeout, err := time.ParseDuration(strings.TrimSpace(value))
+	if err != nil || timeout < 0 {
+		return "", 0, fmt.Errorf("invalid command timeout %q: it must be like 10m or upgrade=30m", s)
+	}
+
+	return strings.TrimSpace(command), timeout, nil
+}
+
+// ParseCommandTimeouts parses the timeouts with ParseCommandTimeout, keyed by the command types
+func ParseCommandTimeouts(specs []string) (map[string]time.Duration, error) {
+	timeouts := map[string]time.Duration{}
+	for _, s := range specs {
+		command, timeout, err := ParseCommandTimeout(s)
+		if err != nil {
+			return nil, err
+		}
+		timeouts[command] = timeout
+	}
+	return timeouts, nil
+}
+
+// ParseCommandLiveOutput parses whether to stream the outputs of the helm commands of the type, given like `upgrade=true`
+func ParseCommandLiveOutput(s string) (string, bool, error) {
+	command, value, ok := strings.Cut(s, "=")
+	if !ok || strings.TrimSpace(command) == "" {
+		return "", false, fmt.Errorf("invalid command live output %q: it must be like upgrade=true", s)
+	}
+
+	liveOutput, err := strconv.ParseBool(strings.TrimSpace(value))
+	if err != nil {
+		return "", false, fmt.Errorf("invalid command live output %q: it must be like upgrade=true", s)
+	}
+
+	return strings.TrimSpace(command), liveOutput, nil
+}
+
+// ParseCommandLiveOutputs parses the live outputs with ParseCommandLiveOutput, keyed by the command types
+func ParseCommandLiveOutputs(specs []string) (map[string]bool, error) {
+	liveOutputs := map[string]bool{}
+	for _, s := range specs {
+		command, liveOutput, err := ParseCommandLiveOutput(s)
+		if err != nil {
+			return nil, err
+		}
+		liveOutputs[command] = liveOutput
+	}
+	return liveOutputs, nil
+}
+
+// commandType returns the type of the helm command, which is the helm subcommand like `upgrade` and `repo`
+func commandType(args []string) string {
+	for _, a := range args {
+		if !strings.HasPrefix(a, "-") {
+			return a
+		}
+	}
+	return ""
+}
+
+// timeout returns the timeout of the helm command, which is the one of the release if any,
+// or the one of the command type, or the one of all the commands
+func (helm *execer) timeout(context HelmContext, args []string) time.Duration {
+	if context.Timeout > 0 {
+		return context.Timeout
+	}
+	if timeout, ok := helm.commandTimeouts[commandType(args)]; ok {
+		return timeout
+	}
+	return helm.commandTimeouts[""]
+}
+
+// liveOutput returns whether to stream the outputs of the helm command, which is the one of the release if any,
+// or the one of the command type, or --enable-live-output
+func (helm *execer) liveOutput(context HelmContext, args []string) bool {
+	if context.LiveOutput != nil {
+		return *context.LiveOutput
+	}
+	if liveOutput, ok := helm.commandLiveOutputs[commandType(args)]; ok {
+		return liveOutput
+	}
+	return helm.enableLiveOutput
+}
@@ -0,0 +1,152 @@
+package helmexec
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+func TestParseCommandTimeout(t *testing.T) {
+	tests := []struct {
+		in      string
+		command string
+		timeout time.Duration
+		wantErr bool
+	}{
+		{in: "10m", command: "", timeout: 10 * time.Minute},
+		{in: "upgrade=30m", command: "upgrade", timeout: 30 * time.Minute},
+		{in: "repo = 1m30s", command: "repo", timeout: 90 * time.Second},
+		{in: "upgrade=30", wantErr: true},
+		{in: "-1m", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			command, timeout, err := ParseCommandTimeout(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCommandTimeout() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if command != tt.command || timeout != tt.timeout {
+				t.Errorf("ParseCommandTimeout() = (%q, %s), want (%q, %s)", command, timeout, tt.command, tt.timeout)
+			}
+		})
+	}
+}
+
+func TestParseCommandLiveOutput(t *testing.T) {
+	tests := []struct {
+		in         string
+		command    string
+		liveOutput bool
+		wantErr    bool
+	}{
+		{in: "upgrade=true", command: "upgrade", liveOutput: true},
+		{in: "diff=false", command: "diff", liveOutput: false},
+		{in: "true", wantErr: true},
+		{in: "upgrade=yes", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			command, liveOutput, err := ParseCommandLiveOutput(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCommandLiveOutput() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if command != tt.command || liveOutput != tt.liveOutput {
+				t.Errorf("ParseCommandLiveOutput() = (%q, %t), want (%q, %t)", command, liveOutput, tt.command, tt.liveOutput)
+			}
+		})
+	}
+}
+
+type timeoutRunner struct {
+	mockRunner
+
+	timeout    time.Duration
+	liveOutput bool
+}
+
+func (r *timeoutRunner) Execute(cmd string, args []string, env map[string]string, enableLiveOutput bool) ([]byte, error) {
+	r.timeout, r.liveOutput = 0, enableLiveOutput
+	return nil, nil
+}
+
+func (r *timeoutRunner) ExecuteWithTimeout(cmd string, args []string, env map[string]string, enableLiveOutput bool, timeout time.Duration) ([]byte, error) {
+	r.timeout, r.liveOutput = timeout, enableLiveOutput
+	return nil, nil
+}
+
+func (r *timeoutRunner) ExecuteStdInWithTimeout(cmd string, args []string, env map[string]string, stdin io.Reader, timeout time.Duration) ([]byte, error) {
+	r.timeout = timeout
+	return nil, nil
+}
+
+func Test_CommandTimeoutsAndLiveOutputs(t *testing.T) {
+	enabled := true
+
+	tests := []struct {
+		name       string
+		context    HelmContext
+		run        func(helm *execer, context HelmContext) error
+		timeout    time.Duration
+		liveOutput bool
+	}{
+		{
+			name: "command type",
+			run: func(helm *execer, context HelmContext) error {
+				return helm.SyncRelease(context, "foo", "bitnami/nginx")
+			},
+			timeout:    30 * time.Minute,
+			liveOutput: true,
+		},
+		{
+			name: "all commands",
+			run: func(helm *execer, context HelmContext) error {
+				return helm.ReleaseStatus(context, "foo")
+			},
+			timeout:    10 * time.Minute,
+			liveOutput: false,
+		},
+		{
+			name:    "release",
+			context: HelmContext{Timeout: time.Hour, LiveOutput: &enabled},
+			run: func(helm *execer, context HelmContext) error {
+				return helm.DeleteRelease(context, "foo")
+			},
+			timeout:    time.Hour,
+			liveOutput: true,
+		},
+		{
+			name:    "captured output",
+			context: HelmContext{LiveOutput: &enabled},
+			run: func(helm *execer, context HelmContext) error {
+				return helm.TemplateRelease(context, "foo", "bitnami/nginx")
+			},
+			timeout:    10 * time.Minute,
+			liveOutput: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &timeoutRunner{}
+			helm := &execer{
+				helmBinary: "helm",
+				logger:     NewLogger(io.Discard, "debug"),
+				runner:     runner,
+			}
+			helm.SetCommandTimeouts(map[string]time.Duration{"": 10 * time.Minute, "upgrade": 30 * time.Minute})
+			helm.SetCommandLiveOutputs(map[string]bool{"upgrade": true})
+
+			if err := tt.run(helm, tt.context); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if runner.timeout != tt.timeout {
+				t.Errorf("timeout = %s, want %s", runner.timeout, tt.timeout)
+			}
+			if runner.liveOutput != tt.liveOutput {
+				t.Errorf("live output = %t, want %t", runner.liveOutput, tt.liveOutput)
+			}
+		})
+	}
+}
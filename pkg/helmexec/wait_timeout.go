@@ -0,0 +1,51 @@
+package helmexec
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrWaitTimeout is matched by the errors of SyncRelease with errors.Is when helm gives up waiting for the resources of the release
+// on --wait or --wait-for-jobs.
+// It never matches the errors of the commands killed on their timeouts, which are TimeoutError, nor of the ones stopped as their contexts are done.
+var ErrWaitTimeout = errors.New("timed out waiting for the resources of the release")
+
+// helmWaitTimeoutErrors are the errors helm prints when --wait or --wait-for-jobs times out
+var helmWaitTimeoutErrors = []string{
+	"timed out waiting for the condition",
+	"context deadline exceeded",
+}
+
+// waitTimeoutError is the error of helm exiting on the timeout of --wait or --wait-for-jobs
+type waitTimeoutError struct {
+	err ExitError
+}
+
+func (e *waitTimeoutError) Error() string {
+	return e.err.Error()
+}
+
+func (e *waitTimeoutError) Unwrap() error {
+	return e.err
+}
+
+func (e *waitTimeoutError) Is(target error) bool {
+	return target == ErrWaitTimeout
+}
+
+// classifyWaitTimeout turns the error of helm exiting on the timeout of --wait or --wait-for-jobs into the one matching ErrWaitTimeout.
+// Only the errors of helm exiting by itself are classified, as the ones of the commands killed or stopped by helmfile aren't ExitError.
+func classifyWaitTimeout(err error) error {
+	var exitErr ExitError
+	if !errors.As(err, &exitErr) {
+		return err
+	}
+
+	for _, e := range helmWaitTimeoutErrors {
+		if strings.Contains(exitErr.Message, e) {
+			return &waitTimeoutError{err: exitErr}
+		}
+	}
+
+	return err
+}
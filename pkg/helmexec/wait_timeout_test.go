@@ -0,0 +1,38 @@
+package helmexec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestClassifyWaitTimeout(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "wait timeout", err: ExitError{Message: "Error: UPGRADE FAILED: timed out waiting for the condition", Code: 1}, want: true},
+		{name: "wait deadline", err: ExitError{Message: "Error: UPGRADE FAILED: context deadline exceeded", Code: 1}, want: true},
+		{name: "other failure", err: ExitError{Message: "Error: UPGRADE FAILED: another operation is in progress", Code: 1}},
+		{name: "command timeout", err: &TimeoutError{Path: "helm", Args: []string{"helm", "upgrade"}, Timeout: time.Minute}},
+		{name: "canceled", err: fmt.Errorf("command %q is stopped: %w", "helm upgrade", context.Canceled)},
+		{name: "deadline of the run", err: fmt.Errorf("command %q is stopped: %w", "helm upgrade", context.DeadlineExceeded)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := classifyWaitTimeout(tt.err)
+			if got := errors.Is(err, ErrWaitTimeout); got != tt.want {
+				t.Errorf("errors.Is(classifyWaitTimeout(%v), ErrWaitTimeout) = %v, want %v", tt.err, got, tt.want)
+			}
+
+			var exitErr ExitError
+			if errors.As(tt.err, &exitErr) && !errors.As(err, &exitErr) {
+				t.Errorf("the classified error must keep the exit status of helm")
+			}
+		})
+	}
+}
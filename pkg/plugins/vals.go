@@ -22,3 +22,40 @@ func ValsInstance() (*vals.Runtime, error) {
 
 	return instance, err
 }
+
+var sized = map[int]*vals.Runtime{}
+var sizedMu sync.Mutex
+
+// ValsInstanceWithCacheSize returns the vals runtime with the cache size, shared among the callers asking for the same size.
+// A non-positive size returns the default instance.
+func ValsInstanceWithCacheSize(size int) (*vals.Runtime, error) {
+	if size <= 0 || size == valsCacheSize {
+		return ValsInstance()
+	}
+
+	sizedMu.Lock()
+	defer sizedMu.Unlock()
+
+	if r, ok := sized[size]; ok {
+		return r, nil
+	}
+
+	r, err := vals.New(vals.Options{CacheSize: size})
+	if err != nil {
+		return nil, err
+	}
+	sized[size] = r
+
+	return r, nil
+}
+
+// NewValsInstance returns a new vals runtime with the cache size, not shared with the other callers,
+// so that its cache doesn't return the secrets resolved with the others' credentials.
+// A non-positive size uses the default one.
+func NewValsInstance(size int) (*vals.Runtime, error) {
+	if size <= 0 {
+		size = valsCacheSize
+	}
+
+	return vals.New(vals.Options{CacheSize: size})
+}
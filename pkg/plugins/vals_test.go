@@ -15,3 +15,25 @@ func TestValsInstance(t *testing.T) {
 		t.Error("Instances should be equal")
 	}
 }
+
+func TestValsInstanceWithCacheSize(t *testing.T) {
+	i, err := ValsInstanceWithCacheSize(16)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	i2, _ := ValsInstanceWithCacheSize(16)
+	if i != i2 {
+		t.Error("Instances of the same cache size should be equal")
+	}
+
+	d, _ := ValsInstance()
+	if i == d {
+		t.Error("Instances of different cache sizes should differ")
+	}
+
+	d2, _ := ValsInstanceWithCacheSize(0)
+	if d != d2 {
+		t.Error("Instance of the default cache size should be the default instance")
+	}
+}
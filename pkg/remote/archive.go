@@ -0,0 +1,291 @@
+package remote
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	neturl "net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// The formats of the archives extracted by helmfile
+const (
+	ArchiveFormatTarGz = "tar.gz"
+	ArchiveFormatZip   = "zip"
+)
+
+// archiveExtensions maps the extensions of the archive files to their formats
+var archiveExtensions = []struct {
+	ext, format string
+}{
+	{".tar.gz", ArchiveFormatTarGz},
+	{".tgz", ArchiveFormatTarGz},
+	{".zip", ArchiveFormatZip},
+}
+
+// FileGetter is implemented by the getters able to download a single remote file,
+// which is required to fetch the archives extracted by helmfile.
+type FileGetter interface {
+	GetFile(wd, src, dst string) error
+}
+
+// archiveFormat returns the format of the archive the http(s) source points to, or "" when it isn't an archive.
+// The format is detected from the extension of the path, or given by the `archive` query parameter.
+// `archive=false` disables the extraction.
+func archiveFormat(u *Source) (string, error) {
+	if u.Scheme != "http" && u.Scheme != "https" || u.SingleFile {
+		return "", nil
+	}
+	if u.Getter != "" && u.Getter != "http" && u.Getter != "https" {
+		return "", nil
+	}
+
+	q, err := neturl.ParseQuery(u.RawQuery)
+	if err != nil {
+		return "", err
+	}
+
+	switch f := q.Get("archive"); f {
+	case "":
+	case "false":
+		return "", nil
+	case "tgz", ArchiveFormatTarGz:
+		return ArchiveFormatTarGz, nil
+	case ArchiveFormatZip:
+		return ArchiveFormatZip, nil
+	default:
+		return "", fmt.Errorf("unsupported archive format %q: must be one of %s, %s", f, ArchiveFormatTarGz, ArchiveFormatZip)
+	}
+
+	archivePath, _ := splitSubpath(u.Dir)
+	for _, e := range archiveExtensions {
+		if strings.HasSuffix(archivePath, e.ext) {
+			return e.format, nil
+		}
+	}
+
+	return "", nil
+}
+
+// splitSubpath splits the path to the archive and the subpath within the archive selected with `//`,
+// like `/releases/download/v1.0.0/bundle.tar.gz//bundle-1.0.0`
+func splitSubpath(dir string) (string, string) {
+	if i := strings.Index(dir, "//"); i >= 0 {
+		return dir[:i], strings.Trim(dir[i+2:], "/")
+	}
+	return dir, ""
+}
+
+// fetchArchive downloads the archive of the source, verifies it against the `checksum` query parameter if any,
+// and extracts the files within the subpath of the source into dst
+func (r *Remote) fetchArchive(u *Source, format, dst string) error {
+	fg, ok := r.Getter.(FileGetter)
+	if !ok {
+		return fmt.Errorf("getter %T can't download archives", r.Getter)
+	}
+
+	q, err := neturl.ParseQuery(u.RawQuery)
+	if err != nil {
+		return err
+	}
+	checksum := q.Get("checksum")
+	q.Del("checksum")
+	// The archive is downloaded as is, and extracted by helmfile
+	q.Set("archive", "false")
+
+	archivePath, subpath := splitSubpath(u.Dir)
+
+	src := fmt.Sprintf("%s://%s%s?%s", u.Scheme, u.Host, archivePath, q.Encode())
+	if u.User != "" {
+		src = fmt.Sprintf("%s://%s@%s%s?%s", u.Scheme, u.User, u.Host, archivePath, q.Encode())
+	}
+	if u.Getter != "" {
+		src = u.Getter + "::" + src
+	}
+
+	tmpDir, err := os.MkdirTemp("", "helmfile-archive-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	file := filepath.Join(tmpDir, "archive."+format)
+
+	r.Logger.Debugf("remote> downloading archive %s to %s", src, file)
+
+	if err := fg.GetFile(r.Home, src, file); err != nil {
+		return err
+	}
+
+	if checksum != "" {
+		if err := verifyChecksum(file, checksum); err != nil {
+			return err
+		}
+	}
+
+	r.Logger.Debugf("remote> extracting %q of archive %s to %s", subpath, file, dst)
+
+	return extractArchive(file, format, subpath, dst)
+}
+
+// verifyChecksum verifies the file against the checksum like `sha256:<hex>`
+func verifyChecksum(file, checksum string) error {
+	typ, want, ok := strings.Cut(checksum, ":")
+	if !ok {
+		return fmt.Errorf("invalid checksum %q: it must be like `sha256:<hex>`", checksum)
+	}
+
+	var h hash.Hash
+	switch typ {
+	case "sha256":
+		h = sha256.New()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return fmt.Errorf("unsupported checksum type %q: must be one of sha256, sha512", typ)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+
+	if got := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(got, want) {
+		return fmt.Errorf("checksum mismatch: want %s, got %s:%s", checksum, typ, got)
+	}
+
+	return nil
+}
+
+// extractArchive extracts the directories and the regular files within the subpath of the archive into dst.
+// The other kinds of entries like symlinks are skipped.
+func extractArchive(file, format, subpath, dst string) error {
+	var (
+		extract func(func(name string, dir bool, mode os.FileMode, open func() (io.ReadCloser, error)) error) error
+		found   bool
+	)
+
+	switch format {
+	case ArchiveFormatTarGz:
+		extract = func(each func(string, bool, os.FileMode, func() (io.ReadCloser, error)) error) error {
+			f, err := os.Open(file)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			gz, err := gzip.NewReader(f)
+			if err != nil {
+				return err
+			}
+			defer gz.Close()
+
+			tr := tar.NewReader(gz)
+			for {
+				h, err := tr.Next()
+				if err == io.EOF {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+				if h.Typeflag != tar.TypeDir && h.Typeflag != tar.TypeReg {
+					continue
+				}
+				if err := each(h.Name, h.Typeflag == tar.TypeDir, h.FileInfo().Mode(), func() (io.ReadCloser, error) {
+					return io.NopCloser(tr), nil
+				}); err != nil {
+					return err
+				}
+			}
+		}
+	case ArchiveFormatZip:
+		extract = func(each func(string, bool, os.FileMode, func() (io.ReadCloser, error)) error) error {
+			zr, err := zip.OpenReader(file)
+			if err != nil {
+				return err
+			}
+			defer zr.Close()
+
+			for _, zf := range zr.File {
+				mode := zf.Mode()
+				if !mode.IsDir() && !mode.IsRegular() {
+					continue
+				}
+				if err := each(zf.Name, mode.IsDir(), mode, zf.Open); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	default:
+		return fmt.Errorf("unsupported archive format %q", format)
+	}
+
+	err := extract(func(name string, dir bool, mode os.FileMode, open func() (io.ReadCloser, error)) error {
+		clean := path.Clean(strings.TrimPrefix(name, "./"))
+		if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+			return fmt.Errorf("illegal path %q in archive", name)
+		}
+
+		rel := clean
+		if subpath != "" {
+			if clean == subpath {
+				rel = "."
+			} else if strings.HasPrefix(clean, subpath+"/") {
+				rel = strings.TrimPrefix(clean, subpath+"/")
+			} else {
+				return nil
+			}
+		}
+		found = true
+
+		target := filepath.Join(dst, filepath.FromSlash(rel))
+
+		if dir {
+			return os.MkdirAll(target, 0755)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+
+		rc, err := open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+
+		out, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode.Perm()|0600)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+
+		_, err = io.Copy(out, rc)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if subpath != "" && !found {
+		return fmt.Errorf("subpath %s not found in the archive", subpath)
+	}
+
+	return os.MkdirAll(dst, 0755)
+}
@@ -0,0 +1,196 @@
+package remote
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/filesystem"
+	"github.com/helmfile/helmfile/pkg/helmexec"
+)
+
+var archiveFiles = map[string]string{
+	"bundle-1.0.0/helmfile.yaml":        "releases: []\n",
+	"bundle-1.0.0/values/defaults.yaml": "replicas: 2\n",
+	"README.md":                         "# bundle\n",
+}
+
+func writeTarGz(t *testing.T, path string) {
+	t.Helper()
+
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for name, content := range archiveFiles {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+}
+
+func writeZip(t *testing.T, path string) {
+	t.Helper()
+
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	for name, content := range archiveFiles {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+}
+
+func sha256sum(t *testing.T, path string) string {
+	t.Helper()
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+type testFileGetter struct {
+	// files is the local files served by their sources
+	files map[string]string
+}
+
+func (g *testFileGetter) Get(wd, src, dst string) error {
+	return fmt.Errorf("unexpected directory get: %s", src)
+}
+
+func (g *testFileGetter) GetFile(wd, src, dst string) error {
+	local, ok := g.files[src]
+	if !ok {
+		return fmt.Errorf("unexpected src: %s", src)
+	}
+	content, err := os.ReadFile(local)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, content, 0644)
+}
+
+func TestRemote_Archive(t *testing.T) {
+	archives := t.TempDir()
+	tarGz := filepath.Join(archives, "bundle.tar.gz")
+	writeTarGz(t, tarGz)
+	zipFile := filepath.Join(archives, "bundle.zip")
+	writeZip(t, zipFile)
+
+	getter := &testFileGetter{
+		files: map[string]string{
+			"https://github.com/example/bundle/releases/download/v1.0.0/bundle.tar.gz?archive=false": tarGz,
+			"https://example.com/download/bundle.zip?archive=false&token=abc":                        zipFile,
+			"https://example.com/download/bundle?archive=false":                                      tarGz,
+		},
+	}
+
+	newRemote := func() *Remote {
+		return &Remote{
+			Logger: helmexec.NewLogger(io.Discard, "debug"),
+			Home:   t.TempDir(),
+			Getter: getter,
+			fs:     filesystem.DefaultFileSystem(),
+		}
+	}
+
+	testcases := []struct {
+		url     string
+		file    string
+		content string
+		err     string
+	}{
+		{
+			url:     "https://github.com/example/bundle/releases/download/v1.0.0/bundle.tar.gz//bundle-1.0.0@helmfile.yaml?checksum=sha256:" + sha256sum(t, tarGz),
+			file:    "helmfile.yaml",
+			content: "releases: []\n",
+		},
+		{
+			url:     "https://example.com/download/bundle.zip@bundle-1.0.0/values/defaults.yaml?token=abc",
+			file:    "bundle-1.0.0/values/defaults.yaml",
+			content: "replicas: 2\n",
+		},
+		{
+			url:     "https://example.com/download/bundle@README.md?archive=tgz",
+			file:    "README.md",
+			content: "# bundle\n",
+		},
+		{
+			url: "https://github.com/example/bundle/releases/download/v1.0.0/bundle.tar.gz@helmfile.yaml?checksum=sha256:0000",
+			err: "checksum mismatch: want sha256:0000, got sha256:" + sha256sum(t, tarGz),
+		},
+		{
+			url: "https://github.com/example/bundle/releases/download/v1.0.0/bundle.tar.gz//bundle-2.0.0@helmfile.yaml",
+			err: "subpath bundle-2.0.0 not found in the archive",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.url, func(t *testing.T) {
+			r := newRemote()
+
+			file, err := r.Fetch(tc.url)
+			if tc.err != "" {
+				require.EqualError(t, err, tc.err)
+				entries, _ := os.ReadDir(r.Home)
+				require.Empty(t, entries, "the cache must be removed on errors")
+				return
+			}
+			require.NoError(t, err)
+
+			require.Equal(t, tc.file, filepath.ToSlash(file[len(file)-len(tc.file):]))
+			content, err := os.ReadFile(file)
+			require.NoError(t, err)
+			require.Equal(t, tc.content, string(content))
+		})
+	}
+
+	t.Run("subpath only", func(t *testing.T) {
+		r := newRemote()
+
+		file, err := r.Fetch("https://github.com/example/bundle/releases/download/v1.0.0/bundle.tar.gz//bundle-1.0.0@helmfile.yaml")
+		require.NoError(t, err)
+
+		_, err = os.Stat(filepath.Join(filepath.Dir(file), "README.md"))
+		require.True(t, os.IsNotExist(err), "files outside the subpath must not be extracted")
+		_, err = os.Stat(filepath.Join(filepath.Dir(file), "values", "defaults.yaml"))
+		require.NoError(t, err)
+	})
+}
+
+func TestExtractArchive_IllegalPath(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "evil.zip")
+
+	f, err := os.Create(file)
+	require.NoError(t, err)
+	zw := zip.NewWriter(f)
+	w, err := zw.Create("../evil.yaml")
+	require.NoError(t, err)
+	_, err = w.Write([]byte("evil: true\n"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	require.NoError(t, f.Close())
+
+	err = extractArchive(file, ArchiveFormatZip, "", filepath.Join(dir, "out"))
+	require.EqualError(t, err, `illegal path "../evil.yaml" in archive`)
+}
@@ -0,0 +1,246 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/hashicorp/go-getter"
+)
+
+const (
+	// AzureStorageSASToken is the environment variable of the SAS token used instead of the Azure credential chain
+	AzureStorageSASToken = "AZURE_STORAGE_SAS_TOKEN"
+
+	azureStorageScope = "https://storage.azure.com/.default"
+)
+
+// AzureBlobGetter is the go-getter getter of the blobs in Azure Blob Storage,
+// like `azblob::https://<account>.blob.core.windows.net/<container>/<path>`.
+//
+// It authenticates with the SAS token in AZURE_STORAGE_SAS_TOKEN if set, or with the token of the default Azure credential chain,
+// which tries the environment variables of a service principal, the workload identity, the managed identity, and the Azure CLI in order.
+type AzureBlobGetter struct {
+	client *getter.Client
+
+	// token returns the bearer token of Azure Storage, which defaults to the one of the default Azure credential chain
+	token func(ctx context.Context) (string, error)
+
+	// httpClient sends the requests to Azure Storage, which defaults to the one of the SDK
+	httpClient *http.Client
+}
+
+func (g *AzureBlobGetter) SetClient(c *getter.Client) {
+	g.client = c
+}
+
+func (g *AzureBlobGetter) ClientMode(u *url.URL) (getter.ClientMode, error) {
+	if strings.HasSuffix(u.Path, "/") {
+		return getter.ClientModeDir, nil
+	}
+	return getter.ClientModeFile, nil
+}
+
+// Get downloads the blobs under the path of the URL into the directory dst
+func (g *AzureBlobGetter) Get(dst string, u *url.URL) error {
+	ctx := g.context()
+
+	container, prefix, err := splitAzureBlobPath(u)
+	if err != nil {
+		return err
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	p, sas, err := g.pipeline(ctx)
+	if err != nil {
+		return err
+	}
+
+	containerURL := *u
+	containerURL.Path = "/" + container
+	containerURL.RawQuery = sas
+
+	c := azblob.NewContainerURL(containerURL, p)
+
+	if err := os.RemoveAll(dst); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+
+	for marker := (azblob.Marker{}); marker.NotDone(); {
+		list, err := c.ListBlobsFlatSegment(ctx, marker, azblob.ListBlobsSegmentOptions{Prefix: prefix})
+		if err != nil {
+			return azureBlobError(&containerURL, err)
+		}
+		marker = list.NextMarker
+
+		for _, b := range list.Segment.BlobItems {
+			if strings.HasSuffix(b.Name, "/") {
+				continue
+			}
+
+			target := filepath.Join(dst, filepath.FromSlash(strings.TrimPrefix(b.Name, prefix)))
+			if !strings.HasPrefix(target, filepath.Clean(dst)+string(filepath.Separator)) {
+				return fmt.Errorf("illegal blob name %q", b.Name)
+			}
+
+			if err := downloadAzureBlob(ctx, c.NewBlobURL(b.Name), target); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// GetFile downloads the blob of the URL to the file dst
+func (g *AzureBlobGetter) GetFile(dst string, u *url.URL) error {
+	ctx := g.context()
+
+	if _, _, err := splitAzureBlobPath(u); err != nil {
+		return err
+	}
+
+	p, sas, err := g.pipeline(ctx)
+	if err != nil {
+		return err
+	}
+
+	blobURL := *u
+	blobURL.RawQuery = sas
+
+	return downloadAzureBlob(ctx, azblob.NewBlobURL(blobURL, p), dst)
+}
+
+func (g *AzureBlobGetter) context() context.Context {
+	if g.client != nil && g.client.Ctx != nil {
+		return g.client.Ctx
+	}
+	return context.Background()
+}
+
+// pipeline returns the pipeline of the requests to Azure Storage, and the SAS token to be added to the URLs if any
+func (g *AzureBlobGetter) pipeline(ctx context.Context) (pipeline.Pipeline, string, error) {
+	var opts azblob.PipelineOptions
+	if g.httpClient != nil {
+		opts.HTTPSender = azureHTTPSender(g.httpClient)
+	}
+
+	if sas := os.Getenv(AzureStorageSASToken); sas != "" {
+		q, err := url.ParseQuery(strings.TrimPrefix(sas, "?"))
+		if err != nil {
+			return nil, "", fmt.Errorf("parsing %s: %w", AzureStorageSASToken, err)
+		}
+
+		return azblob.NewPipeline(azblob.NewAnonymousCredential(), opts), q.Encode(), nil
+	}
+
+	tokenFunc := g.token
+	if tokenFunc == nil {
+		tokenFunc = defaultAzureToken
+	}
+
+	token, err := tokenFunc(ctx)
+	if err != nil {
+		return nil, "", fmt.Errorf("getting the token of Azure Storage: %w", err)
+	}
+
+	return azblob.NewPipeline(azblob.NewTokenCredential(token, nil), opts), "", nil
+}
+
+func defaultAzureToken(ctx context.Context) (string, error) {
+	cred, err := azidentity.NewDefaultAzureCredential(nil)
+	if err != nil {
+		return "", err
+	}
+
+	token, err := cred.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{azureStorageScope}})
+	if err != nil {
+		return "", err
+	}
+
+	return token.Token, nil
+}
+
+// azureHTTPSender returns the policy sending the requests of the pipeline with the HTTP client
+func azureHTTPSender(c *http.Client) pipeline.Factory {
+	return pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			resp, err := c.Do(request.WithContext(ctx))
+			if err != nil {
+				err = pipeline.NewError(err, "HTTP request failed")
+			}
+			return pipeline.NewHTTPResponse(resp), err
+		}
+	})
+}
+
+// downloadAzureBlob downloads the blob to the file dst
+func downloadAzureBlob(ctx context.Context, b azblob.BlobURL, dst string) error {
+	u := b.URL()
+
+	resp, err := b.Download(ctx, 0, azblob.CountToEnd, azblob.BlobAccessConditions{}, false, azblob.ClientProvidedKeyOptions{})
+	if err != nil {
+		return azureBlobError(&u, err)
+	}
+
+	body := resp.Body(azblob.RetryReaderOptions{MaxRetryRequests: 3})
+	defer body.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(f, body)
+	return err
+}
+
+// azureBlobError returns the error of the request to the URL, which doesn't contain the SAS token
+func azureBlobError(u *url.URL, err error) error {
+	var respErr azblob.ResponseError
+	if errors.As(err, &respErr) && respErr.Response() != nil {
+		return fmt.Errorf("requesting %s: %s", azureBlobLocation(u), respErr.Response().Status)
+	}
+
+	// The error of the HTTP client contains the URL, which may contain the SAS token
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+
+	return fmt.Errorf("requesting %s: %w", azureBlobLocation(u), err)
+}
+
+// splitAzureBlobPath returns the container and the path within the container of the URL
+func splitAzureBlobPath(u *url.URL) (string, string, error) {
+	container, path, _ := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+	if container == "" {
+		return "", "", fmt.Errorf("URL is not a valid Azure Blob Storage URL: missing container in %s", azureBlobLocation(u))
+	}
+	return container, path, nil
+}
+
+// azureBlobLocation returns the URL without the query, which may contain the SAS token
+func azureBlobLocation(u *url.URL) string {
+	return u.Scheme + "://" + u.Host + u.Path
+}
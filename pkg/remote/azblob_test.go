@@ -0,0 +1,107 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAzureBlobGetter(t *testing.T) {
+	blobs := map[string]string{
+		"helmfiles/app.yaml":               "releases: []\n",
+		"helmfiles/values/common.yaml":     "replicas: 2\n",
+		"helmfiles/values/production.yaml": "replicas: 3\n",
+	}
+	names := []string{"helmfiles/app.yaml", "helmfiles/values/", "helmfiles/values/common.yaml", "helmfiles/values/production.yaml"}
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" && r.URL.Query().Get("sig") != "secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		if r.URL.Query().Get("comp") == "list" {
+			if r.URL.Path != "/config" || r.URL.Query().Get("prefix") != "helmfiles/" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			// Two pages of the blobs
+			page, next := names[:2], "page2"
+			if r.URL.Query().Get("marker") == "page2" {
+				page, next = names[2:], ""
+			}
+			fmt.Fprint(w, `<?xml version="1.0" encoding="utf-8"?><EnumerationResults><Blobs>`)
+			for _, n := range page {
+				fmt.Fprintf(w, "<Blob><Name>%s</Name></Blob>", n)
+			}
+			fmt.Fprintf(w, "</Blobs><NextMarker>%s</NextMarker></EnumerationResults>", next)
+			return
+		}
+
+		content, ok := blobs[r.URL.Path[len("/config/"):]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, content)
+	}))
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL + "/config/helmfiles/")
+	require.NoError(t, err)
+
+	g := &AzureBlobGetter{
+		token: func(ctx context.Context) (string, error) {
+			return "token", nil
+		},
+		httpClient: srv.Client(),
+	}
+
+	dst := filepath.Join(t.TempDir(), "helmfiles")
+	require.NoError(t, g.Get(dst, u))
+
+	for name, want := range map[string]string{
+		"app.yaml":               "releases: []\n",
+		"values/common.yaml":     "replicas: 2\n",
+		"values/production.yaml": "replicas: 3\n",
+	} {
+		got, err := os.ReadFile(filepath.Join(dst, name))
+		require.NoError(t, err)
+		require.Equal(t, want, string(got))
+	}
+
+	t.Run("SAS token", func(t *testing.T) {
+		t.Setenv(AzureStorageSASToken, "?sv=2020-10-02&sig=secret")
+
+		g := &AzureBlobGetter{
+			token: func(ctx context.Context) (string, error) {
+				return "", fmt.Errorf("unexpected token request")
+			},
+			httpClient: srv.Client(),
+		}
+
+		fileURL, err := url.Parse(srv.URL + "/config/helmfiles/app.yaml")
+		require.NoError(t, err)
+
+		dst := filepath.Join(t.TempDir(), "app.yaml")
+		require.NoError(t, g.GetFile(dst, fileURL))
+
+		got, err := os.ReadFile(dst)
+		require.NoError(t, err)
+		require.Equal(t, "releases: []\n", string(got))
+
+		missingURL, err := url.Parse(srv.URL + "/config/helmfiles/missing.yaml")
+		require.NoError(t, err)
+
+		err = g.GetFile(dst, missingURL)
+		require.EqualError(t, err, "requesting "+srv.URL+"/config/helmfiles/missing.yaml: 404 Not Found")
+	})
+}
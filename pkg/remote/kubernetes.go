@@ -0,0 +1,188 @@
+package remote
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	neturl "net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/helmfile/helmfile/pkg/helmexec"
+)
+
+// KubernetesScheme is the scheme of the sources stored in Kubernetes objects, like `k8s://<namespace>/configmap/<name>`
+const KubernetesScheme = "k8s"
+
+// defaultKubernetesStateFiles are the keys of the state files read from a Kubernetes source without a key, in the order of precedence
+var defaultKubernetesStateFiles = []string{"helmfile.yaml", "helmfile.yaml.gotmpl"}
+
+// KubernetesSource is a ConfigMap, a Secret or a custom resource containing the files of the states,
+// given like `k8s://<namespace>/<kind>/<name>[/<key>][?context=<kubeContext>]`.
+// The files are the `data` of ConfigMaps and Secrets, and the `spec.data` of custom resources.
+type KubernetesSource struct {
+	Namespace string
+	// Kind is the kind of the object, like `configmap`, `secret` or `helmfilestates.example.com`, as given to kubectl
+	Kind string
+	Name string
+	// Key is the key of the file in the object. Empty means the default state file, or all the files when there is none.
+	Key string
+	// Context is the kube context to read the object from. Empty means the current context.
+	Context string
+}
+
+// kubernetesObject is the part of a Kubernetes object holding the files
+type kubernetesObject struct {
+	Data map[string]string `json:"data"`
+	Spec struct {
+		Data map[string]string `json:"data"`
+	} `json:"spec"`
+}
+
+// IsKubernetesSource returns whether the source is stored in a Kubernetes object
+func IsKubernetesSource(src string) bool {
+	return strings.HasPrefix(src, KubernetesScheme+"://")
+}
+
+// ParseKubernetesSource parses the source like `k8s://<namespace>/<kind>/<name>[/<key>][?context=<kubeContext>]`
+func ParseKubernetesSource(src string) (*KubernetesSource, error) {
+	u, err := neturl.Parse(src)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", src, err)
+	}
+
+	if u.Scheme != KubernetesScheme {
+		return nil, fmt.Errorf("invalid kubernetes source %s: it must start with %s://", src, KubernetesScheme)
+	}
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if u.Host == "" || len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("invalid kubernetes source %s: it must be like %s://<namespace>/<kind>/<name>[/<key>]", src, KubernetesScheme)
+	}
+
+	s := &KubernetesSource{
+		Namespace: u.Host,
+		Kind:      strings.ToLower(parts[0]),
+		Name:      parts[1],
+		Context:   u.Query().Get("context"),
+	}
+	if len(parts) == 3 {
+		s.Key = parts[2]
+	}
+
+	return s, nil
+}
+
+// cacheKey returns the name of the directory the files of the source are written to within the cache directory
+func (s *KubernetesSource) cacheKey() string {
+	replacer := strings.NewReplacer(":", "", "/", "_", ".", "_")
+	return replacer.Replace(strings.Join([]string{KubernetesScheme, s.Context, s.Namespace, s.Kind, s.Name}, "_"))
+}
+
+// fetchKubernetes reads the object of the source with kubectl, writes its files to the cache directory,
+// and returns the path to the file of the key, or the default state file, or else the directory of all the files.
+//
+// The object is read on every call, so that the latest state in the cluster is always used.
+func (r *Remote) fetchKubernetes(s *KubernetesSource) (string, error) {
+	args := []string{"get", s.Kind, s.Name, "--namespace", s.Namespace, "--output", "json"}
+	if s.Context != "" {
+		args = append(args, "--context", s.Context)
+	}
+
+	runner := r.Runner
+	if runner == nil {
+		runner = helmexec.ShellRunner{Logger: r.Logger}
+	}
+
+	r.Logger.Debugf("remote> reading %s %s/%s", s.Kind, s.Namespace, s.Name)
+
+	out, err := runner.Execute(helmexec.KubectlBinary(), args, map[string]string{}, false)
+	if err != nil {
+		return "", fmt.Errorf("reading %s %s/%s: %w", s.Kind, s.Namespace, s.Name, err)
+	}
+
+	files, err := kubernetesFiles(s.Kind, out)
+	if err != nil {
+		return "", fmt.Errorf("reading %s %s/%s: %w", s.Kind, s.Namespace, s.Name, err)
+	}
+
+	dir := filepath.Join(r.Home, s.cacheKey())
+
+	// The files of the keys removed from the object are removed too
+	if err := os.RemoveAll(dir); err != nil {
+		return "", err
+	}
+	// The files of Secrets must be readable only by the user
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+
+	keys := make([]string, 0, len(files))
+	for k := range files {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if k == "" || k == "." || k == ".." || strings.ContainsAny(k, `/\`) {
+			return "", fmt.Errorf("illegal key %q in %s %s/%s", k, s.Kind, s.Namespace, s.Name)
+		}
+		if err := os.WriteFile(filepath.Join(dir, k), files[k], 0600); err != nil {
+			return "", err
+		}
+	}
+
+	r.Logger.Debugf("remote> wrote %s to %s", strings.Join(keys, ", "), dir)
+
+	if s.Key != "" {
+		if _, ok := files[s.Key]; !ok {
+			return "", fmt.Errorf("key %q not found in %s %s/%s", s.Key, s.Kind, s.Namespace, s.Name)
+		}
+		return filepath.Join(dir, s.Key), nil
+	}
+
+	for _, k := range defaultKubernetesStateFiles {
+		if _, ok := files[k]; ok {
+			return filepath.Join(dir, k), nil
+		}
+	}
+
+	return dir, nil
+}
+
+// kubernetesFiles returns the files in the JSON of the object of the kind
+func kubernetesFiles(kind string, out []byte) (map[string][]byte, error) {
+	var obj kubernetesObject
+	if err := json.Unmarshal(out, &obj); err != nil {
+		return nil, fmt.Errorf("decoding the object: %w", err)
+	}
+
+	files := map[string][]byte{}
+
+	switch kind {
+	case "configmap", "configmaps", "cm":
+		for k, v := range obj.Data {
+			files[k] = []byte(v)
+		}
+	case "secret", "secrets":
+		for k, v := range obj.Data {
+			bs, err := base64.StdEncoding.DecodeString(v)
+			if err != nil {
+				return nil, fmt.Errorf("decoding key %q: %w", k, err)
+			}
+			files[k] = bs
+		}
+	default:
+		for k, v := range obj.Spec.Data {
+			files[k] = []byte(v)
+		}
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no file found: the files must be in `data` of ConfigMaps and Secrets, or `spec.data` of the other kinds")
+	}
+
+	return files, nil
+}
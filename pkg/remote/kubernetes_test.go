@@ -0,0 +1,114 @@
+package remote
+
+import (
+	"encoding/base64"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/exectest"
+	"github.com/helmfile/helmfile/pkg/helmexec"
+)
+
+func TestParseKubernetesSource(t *testing.T) {
+	s, err := ParseKubernetesSource("k8s://platform/configmap/helmfile-state")
+	require.NoError(t, err)
+	require.Equal(t, &KubernetesSource{Namespace: "platform", Kind: "configmap", Name: "helmfile-state"}, s)
+
+	s, err = ParseKubernetesSource("k8s://platform/Secret/helmfile-state/prod.yaml?context=admin@prod")
+	require.NoError(t, err)
+	require.Equal(t, &KubernetesSource{Namespace: "platform", Kind: "secret", Name: "helmfile-state", Key: "prod.yaml", Context: "admin@prod"}, s)
+
+	_, err = ParseKubernetesSource("k8s://platform/configmap")
+	require.EqualError(t, err, "invalid kubernetes source k8s://platform/configmap: it must be like k8s://<namespace>/<kind>/<name>[/<key>]")
+}
+
+func TestRemote_FetchKubernetes(t *testing.T) {
+	runner := &exectest.Runner{
+		Outputs: map[string]string{
+			"kubectl get configmap helmfile-state --namespace platform --output json": `{
+  "kind": "ConfigMap",
+  "data": {"helmfile.yaml": "releases: []\n", "values.yaml": "replicas: 2\n"}
+}`,
+			"kubectl get secret helmfile-state --namespace platform --output json --context prod": `{
+  "kind": "Secret",
+  "data": {"prod.yaml": "` + base64.StdEncoding.EncodeToString([]byte("releases: []\n")) + `"}
+}`,
+			"kubectl get helmfilestates.example.com web --namespace platform --output json": `{
+  "kind": "HelmfileState",
+  "spec": {"data": {"00-infra.yaml": "releases: []\n", "10-web.yaml": "releases: []\n"}}
+}`,
+		},
+	}
+
+	r := &Remote{
+		Logger: helmexec.NewLogger(io.Discard, "debug"),
+		Home:   t.TempDir(),
+		Runner: runner,
+	}
+
+	testcases := []struct {
+		src   string
+		files []string
+		path  string
+		err   string
+	}{
+		{
+			src:   "k8s://platform/configmap/helmfile-state",
+			files: []string{"helmfile.yaml", "values.yaml"},
+			path:  "helmfile.yaml",
+		},
+		{
+			src:   "k8s://platform/configmap/helmfile-state/values.yaml",
+			files: []string{"helmfile.yaml", "values.yaml"},
+			path:  "values.yaml",
+		},
+		{
+			src:   "k8s://platform/secret/helmfile-state/prod.yaml?context=prod",
+			files: []string{"prod.yaml"},
+			path:  "prod.yaml",
+		},
+		{
+			src:   "k8s://platform/helmfilestates.example.com/web",
+			files: []string{"00-infra.yaml", "10-web.yaml"},
+			path:  "",
+		},
+		{
+			src: "k8s://platform/configmap/helmfile-state/missing.yaml",
+			err: `key "missing.yaml" not found in configmap platform/helmfile-state`,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.src, func(t *testing.T) {
+			path, err := r.Fetch(tc.src)
+			if tc.err != "" {
+				require.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+
+			dir := path
+			if tc.path != "" {
+				require.Equal(t, tc.path, filepath.Base(path))
+				dir = filepath.Dir(path)
+			}
+
+			entries, err := os.ReadDir(dir)
+			require.NoError(t, err)
+
+			var files []string
+			for _, e := range entries {
+				files = append(files, e.Name())
+			}
+			require.Equal(t, tc.files, files)
+
+			content, err := os.ReadFile(filepath.Join(dir, tc.files[0]))
+			require.NoError(t, err)
+			require.NotEmpty(t, content)
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package remote
+
+import (
+	"fmt"
+	neturl "net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+// objectStorageSource returns the go-getter source of the directory in the object storage,
+// or "" when the source isn't any of `s3://<bucket>/<dir>`, `gs://<bucket>/<dir>` and `azblob://<account>/<container>/<dir>`.
+//
+// The objects are fetched with the credential chains of the cloud SDKs,
+// like the environment variables, the shared configuration files, and the instance metadata.
+func objectStorageSource(u *Source) (string, error) {
+	if u.Getter != "" {
+		return "", nil
+	}
+
+	q, err := neturl.ParseQuery(u.RawQuery)
+	if err != nil {
+		return "", err
+	}
+
+	// The trailing slash makes the getters fetch the objects under the directory, not the ones sharing the prefix.
+	// The single file sources point to the objects themselves instead.
+	dir := strings.Trim(u.Dir, "/")
+	if u.SingleFile {
+		dir = strings.TrimPrefix(path.Join(dir, u.File), "/")
+	} else if dir != "" {
+		dir += "/"
+	}
+
+	var src string
+
+	switch u.Scheme {
+	case "s3":
+		region := q.Get("region")
+		q.Del("region")
+		if region == "" {
+			region = os.Getenv("AWS_REGION")
+		}
+		if region == "" {
+			region = os.Getenv("AWS_DEFAULT_REGION")
+		}
+
+		// go-getter reads the region from the path-style URL, and the AWS SDK resolves the endpoint of the region
+		host := "s3.amazonaws.com"
+		if region != "" {
+			host = fmt.Sprintf("s3-%s.amazonaws.com", region)
+		}
+
+		src = fmt.Sprintf("s3::https://%s/%s/%s", host, u.Host, dir)
+	case "gs":
+		src = fmt.Sprintf("gcs::https://www.googleapis.com/storage/v1/%s/%s", u.Host, dir)
+	case "azblob":
+		container, _, _ := strings.Cut(dir, "/")
+		if container == "" {
+			return "", fmt.Errorf("invalid azblob source: it must be like `azblob://<account>/<container>/<path/to/dir>@<path/to/file>`")
+		}
+
+		src = fmt.Sprintf("azblob::https://%s.blob.core.windows.net/%s", u.Host, dir)
+	default:
+		return "", nil
+	}
+
+	if len(q) > 0 {
+		src += "?" + q.Encode()
+	}
+
+	return src, nil
+}
@@ -0,0 +1,79 @@
+package remote
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestObjectStorageSource(t *testing.T) {
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+
+	testcases := []struct {
+		src  string
+		want string
+		err  string
+	}{
+		{
+			src:  "s3://shared-config/helmfiles@app.yaml",
+			want: "s3::https://s3.amazonaws.com/shared-config/helmfiles/",
+		},
+		{
+			src:  "s3://shared-config/helmfiles/prod@app.yaml?region=eu-west-1&version=abc",
+			want: "s3::https://s3-eu-west-1.amazonaws.com/shared-config/helmfiles/prod/?version=abc",
+		},
+		{
+			src:  "gs://shared-config/helmfiles@values/common.yaml",
+			want: "gcs::https://www.googleapis.com/storage/v1/shared-config/helmfiles/",
+		},
+		{
+			src:  "azblob://myaccount/config/helmfiles@app.yaml",
+			want: "azblob::https://myaccount.blob.core.windows.net/config/helmfiles/",
+		},
+		{
+			src: "azblob://myaccount/@app.yaml",
+			err: "invalid azblob source: it must be like `azblob://<account>/<container>/<path/to/dir>@<path/to/file>`",
+		},
+		{
+			src:  "s3://shared-config/helmfiles/values.yaml?region=eu-west-1",
+			want: "s3::https://s3-eu-west-1.amazonaws.com/shared-config/helmfiles/values.yaml",
+		},
+		{
+			src:  "gs://shared-config/values.yaml",
+			want: "gcs::https://www.googleapis.com/storage/v1/shared-config/values.yaml",
+		},
+		{
+			src: "git::https://github.com/cloudposse/helmfiles.git@releases/kiam.yaml?ref=0.40.0",
+		},
+		{
+			src: "s3::https://s3.amazonaws.com/shared-config/helmfiles@app.yaml",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.src, func(t *testing.T) {
+			u, err := Parse(tc.src)
+			require.NoError(t, err)
+
+			got, err := objectStorageSource(u)
+			if tc.err != "" {
+				require.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+		})
+	}
+
+	t.Run("region from the environment", func(t *testing.T) {
+		t.Setenv("AWS_DEFAULT_REGION", "ap-northeast-1")
+
+		u, err := Parse("s3://shared-config/helmfiles@app.yaml")
+		require.NoError(t, err)
+
+		got, err := objectStorageSource(u)
+		require.NoError(t, err)
+		require.Equal(t, "s3::https://s3-ap-northeast-1.amazonaws.com/shared-config/helmfiles/", got)
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	neturl "net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -16,6 +17,7 @@ import (
 
 	"github.com/helmfile/helmfile/pkg/envvar"
 	"github.com/helmfile/helmfile/pkg/filesystem"
+	"github.com/helmfile/helmfile/pkg/helmexec"
 )
 
 var disableInsecureFeatures bool
@@ -46,6 +48,12 @@ type Remote struct {
 	// Getter is the underlying implementation of getter used for fetching remote files
 	Getter Getter
 
+	// Runner runs kubectl to read the Kubernetes sources. Defaults to helmexec.ShellRunner.
+	Runner helmexec.Runner
+
+	// Offline fails fetching the sources missing from the cache with NotCachedError, instead of downloading them
+	Offline bool
+
 	// Filesystem abstraction
 	// Inject any implementation of your choice, like an im-memory impl for testing, os.ReadFile for the real-world use.
 	fs *filesystem.FileSystem
@@ -76,8 +84,29 @@ func (e InvalidURLError) Error() string {
 	return e.err
 }
 
+// NotCachedError is returned by Fetch in the offline mode when the source isn't in the cache
+type NotCachedError struct {
+	Source string
+}
+
+func (e NotCachedError) Error() string {
+	return fmt.Sprintf("offline: %s is missing from the cache. Run helmfile without --offline once to fetch it", e.Source)
+}
+
 type Source struct {
 	Getter, Scheme, User, Host, Dir, File, RawQuery string
+	// SingleFile is true when the source is the URL of the file itself without `@`, like `https://example.com/values.yaml`,
+	// which is downloaded alone instead of the directory containing it
+	SingleFile bool
+}
+
+// singleFileSchemes are the schemes of the URLs of the single files fetched without `@`
+var singleFileSchemes = map[string]bool{
+	"http":   true,
+	"https":  true,
+	"s3":     true,
+	"gs":     true,
+	"azblob": true,
 }
 
 func IsRemote(goGetterSrc string) bool {
@@ -105,6 +134,21 @@ func Parse(goGetterSrc string) (*Source, error) {
 	}
 
 	pathComponents := strings.Split(u.Path, "@")
+	// The subdirectory of go-getter like `git::https://github.com/org/repo//charts/app?ref=v1.2.3` is accepted in place of `@`
+	if dir, subdir, ok := strings.Cut(u.Path, "//"); ok && len(pathComponents) == 1 {
+		pathComponents = []string{dir, subdir}
+	}
+	if len(pathComponents) == 1 && getter == "" && singleFileSchemes[u.Scheme] && path.Base(u.Path) != "." && !strings.HasSuffix(u.Path, "/") {
+		return &Source{
+			User:       u.User.String(),
+			Scheme:     u.Scheme,
+			Host:       u.Host,
+			Dir:        path.Dir(u.Path),
+			File:       path.Base(u.Path),
+			RawQuery:   u.RawQuery,
+			SingleFile: true,
+		}, nil
+	}
 	if len(pathComponents) != 2 {
 		return nil, fmt.Errorf("invalid src format: it must be `[<getter>::]<scheme>://<host>/<path/to/dir>@<path/to/file>?key1=val1&key2=val2: got %s", goGetterSrc)
 	}
@@ -121,12 +165,19 @@ func Parse(goGetterSrc string) (*Source, error) {
 }
 
 func (r *Remote) Fetch(goGetterSrc string, cacheDirOpt ...string) (string, error) {
+	if IsKubernetesSource(goGetterSrc) {
+		s, err := ParseKubernetesSource(goGetterSrc)
+		if err != nil {
+			return "", err
+		}
+		return r.fetchKubernetes(s)
+	}
+
 	u, err := Parse(goGetterSrc)
 	if err != nil {
 		return "", err
 	}
 
-	srcDir := fmt.Sprintf("%s://%s%s", u.Scheme, u.Host, u.Dir)
 	file := u.File
 
 	r.Logger.Debugf("remote> getter: %s", u.Getter)
@@ -144,29 +195,13 @@ func (r *Remote) Fetch(goGetterSrc string, cacheDirOpt ...string) (string, error
 		return "", fmt.Errorf("[bug] cacheDirOpt's length: want 0 or 1, got %d", len(cacheDirOpt))
 	}
 
-	query := u.RawQuery
-
-	var cacheKey string
-	replacer := strings.NewReplacer(":", "", "//", "_", "/", "_", ".", "_")
-	dirKey := replacer.Replace(srcDir)
-	if len(query) > 0 {
-		q, _ := neturl.ParseQuery(query)
-		if q.Has("sshkey") {
-			q.Set("sshkey", "redacted")
-		}
-		paramsKey := strings.ReplaceAll(q.Encode(), "&", "_")
-		cacheKey = fmt.Sprintf("%s.%s", dirKey, paramsKey)
-	} else {
-		cacheKey = dirKey
-	}
-
 	cached := false
 
 	// e.g. https_github_com_cloudposse_helmfiles_git.ref=0.xx.0
-	getterDst := filepath.Join(cacheBaseDir, cacheKey)
+	getterDst := filepath.Join(cacheBaseDir, cacheKey(u))
 
 	// e.g. os.CacheDir()/helmfile/https_github_com_cloudposse_helmfiles_git.ref=0.xx.0
-	cacheDirPath := filepath.Join(r.Home, getterDst)
+	cacheDirPath := r.cacheDirPath(u, cacheBaseDir)
 
 	r.Logger.Debugf("remote> home: %s", r.Home)
 	r.Logger.Debugf("remote> getter dest: %s", getterDst)
@@ -182,25 +217,31 @@ func (r *Remote) Fetch(goGetterSrc string, cacheDirOpt ...string) (string, error
 		}
 	}
 
-	if !cached {
-		var getterSrc string
-		if u.User != "" {
-			getterSrc = fmt.Sprintf("%s://%s@%s%s", u.Scheme, u.User, u.Host, u.Dir)
-		} else {
-			getterSrc = fmt.Sprintf("%s://%s%s", u.Scheme, u.Host, u.Dir)
-		}
-
-		if len(query) > 0 {
-			getterSrc = strings.Join([]string{getterSrc, query}, "?")
-		}
+	format, err := archiveFormat(u)
+	if err != nil {
+		return "", err
+	}
 
-		if u.Getter != "" {
-			getterSrc = u.Getter + "::" + getterSrc
-		}
+	// The checksums of the archives are verified before they are extracted, and the ones of the other sources are verified against the files
+	src, checksum, err := withoutChecksum(u)
+	if err != nil {
+		return "", err
+	}
 
-		r.Logger.Debugf("remote> downloading %s to %s", getterSrc, getterDst)
+	if !cached && r.Offline {
+		return "", NotCachedError{Source: goGetterSrc}
+	}
 
-		if err := r.Getter.Get(r.Home, getterSrc, cacheDirPath); err != nil {
+	if !cached {
+		switch {
+		case format != "":
+			err = r.fetchArchive(u, format, cacheDirPath)
+		case u.SingleFile:
+			err = r.fetchFile(src, cacheDirPath)
+		default:
+			err = r.get(src, cacheDirPath)
+		}
+		if err != nil {
 			rmerr := os.RemoveAll(cacheDirPath)
 			if rmerr != nil {
 				return "", multierr.Append(err, rmerr)
@@ -209,7 +250,179 @@ func (r *Remote) Fetch(goGetterSrc string, cacheDirOpt ...string) (string, error
 		}
 	}
 
-	return filepath.Join(cacheDirPath, file), nil
+	fetched := filepath.Join(cacheDirPath, file)
+
+	// The cached files are verified too, so that the file changed in the cache isn't used
+	if format == "" && checksum != "" {
+		if err := verifyChecksum(fetched, checksum); err != nil {
+			if rmerr := os.RemoveAll(cacheDirPath); rmerr != nil {
+				return "", multierr.Append(err, rmerr)
+			}
+			return "", fmt.Errorf("%s: %v", file, err)
+		}
+	}
+
+	return fetched, nil
+}
+
+// withoutChecksum returns the source without the `checksum` query parameter verified by helmfile, along with the checksum
+func withoutChecksum(u *Source) (*Source, string, error) {
+	q, err := neturl.ParseQuery(u.RawQuery)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if !q.Has("checksum") {
+		return u, "", nil
+	}
+
+	checksum := q.Get("checksum")
+	q.Del("checksum")
+
+	src := *u
+	src.RawQuery = q.Encode()
+
+	return &src, checksum, nil
+}
+
+// fetchFile downloads the single file of the source into dst
+func (r *Remote) fetchFile(u *Source, dst string) error {
+	fg, ok := r.Getter.(FileGetter)
+	if !ok {
+		return fmt.Errorf("getter %T can't download single files", r.Getter)
+	}
+
+	src, err := objectStorageSource(u)
+	if err != nil {
+		return err
+	}
+
+	if src == "" {
+		src = fmt.Sprintf("%s://%s%s", u.Scheme, u.Host, path.Join(u.Dir, u.File))
+		if u.User != "" {
+			src = fmt.Sprintf("%s://%s@%s%s", u.Scheme, u.User, u.Host, path.Join(u.Dir, u.File))
+		}
+		if len(u.RawQuery) > 0 {
+			src += "?" + u.RawQuery
+		}
+	}
+
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+
+	r.Logger.Debugf("remote> downloading %s to %s", src, dst)
+
+	return fg.GetFile(r.Home, src, filepath.Join(dst, u.File))
+}
+
+// Refresh fetches the remote file again like Fetch, replacing the cached directory of the source,
+// so that the latest content is used after e.g. the ref of the git repository is moved
+func (r *Remote) Refresh(goGetterSrc string, cacheDirOpt ...string) (string, error) {
+	u, err := Parse(goGetterSrc)
+	if err != nil {
+		return "", err
+	}
+
+	cacheBaseDir := ""
+	if len(cacheDirOpt) == 1 {
+		cacheBaseDir = cacheDirOpt[0]
+	} else if len(cacheDirOpt) > 0 {
+		return "", fmt.Errorf("[bug] cacheDirOpt's length: want 0 or 1, got %d", len(cacheDirOpt))
+	}
+
+	// The cached directory is kept in the offline mode, as it can't be fetched again
+	if r.Offline {
+		return r.Fetch(goGetterSrc, cacheDirOpt...)
+	}
+
+	cacheDirPath := r.cacheDirPath(u, cacheBaseDir)
+
+	r.Logger.Debugf("remote> removing the cached dir %s to fetch it again", cacheDirPath)
+
+	if err := os.RemoveAll(cacheDirPath); err != nil {
+		return "", err
+	}
+
+	return r.Fetch(goGetterSrc, cacheDirOpt...)
+}
+
+// Cached returns whether the remote source is in the cache, so that Fetch returns it without downloading it
+func (r *Remote) Cached(goGetterSrc string, cacheDirOpt ...string) (bool, error) {
+	u, err := Parse(goGetterSrc)
+	if err != nil {
+		return false, err
+	}
+
+	cacheBaseDir := ""
+	if len(cacheDirOpt) == 1 {
+		cacheBaseDir = cacheDirOpt[0]
+	} else if len(cacheDirOpt) > 0 {
+		return false, fmt.Errorf("[bug] cacheDirOpt's length: want 0 or 1, got %d", len(cacheDirOpt))
+	}
+
+	return r.fs.DirectoryExistsAt(r.cacheDirPath(u, cacheBaseDir)), nil
+}
+
+// cacheDirPath returns the directory the source is fetched into, within the cache directory of cacheBaseDir
+func (r *Remote) cacheDirPath(u *Source, cacheBaseDir string) string {
+	return filepath.Join(r.Home, cacheBaseDir, cacheKey(u))
+}
+
+// cacheKey returns the name of the directory the source is fetched into within the cache directory,
+// which is made of the directory of the source and the query, or the path to the file of the single file source
+func cacheKey(u *Source) string {
+	srcDir := fmt.Sprintf("%s://%s%s", u.Scheme, u.Host, u.Dir)
+	if u.SingleFile {
+		srcDir = fmt.Sprintf("%s://%s%s", u.Scheme, u.Host, path.Join(u.Dir, u.File))
+	}
+
+	replacer := strings.NewReplacer(":", "", "//", "_", "/", "_", ".", "_")
+	dirKey := replacer.Replace(srcDir)
+
+	if len(u.RawQuery) == 0 {
+		return dirKey
+	}
+
+	q, _ := neturl.ParseQuery(u.RawQuery)
+	if q.Has("sshkey") {
+		q.Set("sshkey", "redacted")
+	}
+	paramsKey := strings.ReplaceAll(q.Encode(), "&", "_")
+
+	return fmt.Sprintf("%s.%s", dirKey, paramsKey)
+}
+
+// get fetches the remote directory of the source into dst with the getter
+func (r *Remote) get(u *Source, dst string) error {
+	getterSrc, err := objectStorageSource(u)
+	if err != nil {
+		return err
+	}
+
+	if getterSrc != "" {
+		r.Logger.Debugf("remote> downloading %s to %s", getterSrc, dst)
+
+		return r.Getter.Get(r.Home, getterSrc, dst)
+	}
+
+	if u.User != "" {
+		getterSrc = fmt.Sprintf("%s://%s@%s%s", u.Scheme, u.User, u.Host, u.Dir)
+	} else {
+		getterSrc = fmt.Sprintf("%s://%s%s", u.Scheme, u.Host, u.Dir)
+	}
+
+	if len(u.RawQuery) > 0 {
+		getterSrc = strings.Join([]string{getterSrc, u.RawQuery}, "?")
+	}
+
+	if u.Getter != "" {
+		getterSrc = u.Getter + "::" + getterSrc
+	}
+
+	r.Logger.Debugf("remote> downloading %s to %s", getterSrc, dst)
+
+	return r.Getter.Get(r.Home, getterSrc, dst)
 }
 
 type Getter interface {
@@ -229,6 +442,7 @@ func (g *GoGetter) Get(wd, src, dst string) error {
 		Dst:     dst,
 		Pwd:     wd,
 		Mode:    getter.ClientModeDir,
+		Getters: getters(),
 		Options: []getter.ClientOption{},
 	}
 
@@ -241,6 +455,37 @@ func (g *GoGetter) Get(wd, src, dst string) error {
 	return nil
 }
 
+// GetFile downloads the single remote file to dst
+func (g *GoGetter) GetFile(wd, src, dst string) error {
+	get := &getter.Client{
+		Ctx:     context.Background(),
+		Src:     src,
+		Dst:     dst,
+		Pwd:     wd,
+		Mode:    getter.ClientModeFile,
+		Getters: getters(),
+		Options: []getter.ClientOption{},
+	}
+
+	g.Logger.Debugf("client: %+v", *get)
+
+	if err := get.Get(); err != nil {
+		return fmt.Errorf("get: %v", err)
+	}
+
+	return nil
+}
+
+// getters returns the go-getter getters used by helmfile, which are the default ones plus the one of Azure Blob Storage
+func getters() map[string]getter.Getter {
+	gs := map[string]getter.Getter{}
+	for k, g := range getter.Getters {
+		gs[k] = g
+	}
+	gs["azblob"] = &AzureBlobGetter{}
+	return gs
+}
+
 func NewRemote(logger *zap.SugaredLogger, homeDir string, fs *filesystem.FileSystem) *Remote {
 	if disableInsecureFeatures {
 		panic("Remote sources are disabled due to 'DISABLE_INSECURE_FEATURES'")
@@ -3,11 +3,14 @@ package remote
 import (
 	"fmt"
 	"io"
+	"os"
 	"path/filepath"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/require"
 
+	"github.com/helmfile/helmfile/pkg/filesystem"
 	"github.com/helmfile/helmfile/pkg/helmexec"
 	"github.com/helmfile/helmfile/pkg/testhelper"
 )
@@ -248,6 +251,32 @@ func TestParse(t *testing.T) {
 			file:   "deployments/kubernetes/chart/forecastle",
 			query:  "ref=v1.0.54",
 		},
+		{
+			input:  "git::https://github.com/org/repo//charts/app?ref=v1.2.3",
+			getter: "git",
+			scheme: "https",
+			dir:    "/org/repo",
+			file:   "charts/app",
+			query:  "ref=v1.2.3",
+		},
+		{
+			input:  "https://example.com/charts.tgz//app?archive=tgz",
+			scheme: "https",
+			dir:    "/charts.tgz",
+			file:   "app",
+			query:  "archive=tgz",
+		},
+		{
+			input:  "https://config.example.com/org/values.yaml?checksum=sha256:abc",
+			scheme: "https",
+			dir:    "/org",
+			file:   "values.yaml",
+			query:  "checksum=sha256:abc",
+		},
+		{
+			input: "https://config.example.com/org/",
+			err:   "invalid src format: it must be `[<getter>::]<scheme>://<host>/<path/to/dir>@<path/to/file>?key1=val1&key2=val2: got https://config.example.com/org/",
+		},
 	}
 
 	for i := range testcases {
@@ -297,6 +326,160 @@ func TestParse(t *testing.T) {
 	}
 }
 
+func TestRemote_Refresh(t *testing.T) {
+	home := t.TempDir()
+
+	content := "foo: bar"
+	fetches := 0
+
+	getter := &testGetter{
+		get: func(wd, src, dst string) error {
+			fetches++
+			if err := os.MkdirAll(filepath.Join(dst, "releases"), 0755); err != nil {
+				return err
+			}
+			return os.WriteFile(filepath.Join(dst, "releases", "kiam.yaml"), []byte(content), 0644)
+		},
+	}
+
+	remote := &Remote{
+		Logger: helmexec.NewLogger(io.Discard, "debug"),
+		Home:   home,
+		Getter: getter,
+		fs:     filesystem.DefaultFileSystem(),
+	}
+
+	url := "git::https://github.com/cloudposse/helmfiles.git@releases/kiam.yaml?ref=main"
+
+	file, err := remote.Fetch(url)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(home, "https_github_com_cloudposse_helmfiles_git.ref=main", "releases", "kiam.yaml"), file)
+
+	content = "foo: baz"
+
+	_, err = remote.Fetch(url)
+	require.NoError(t, err)
+	require.Equal(t, 1, fetches, "the cached file is used")
+
+	file, err = remote.Refresh(url)
+	require.NoError(t, err)
+	require.Equal(t, 2, fetches)
+
+	refreshed, err := os.ReadFile(file)
+	require.NoError(t, err)
+	require.Equal(t, "foo: baz", string(refreshed))
+}
+
+func TestRemote_Offline(t *testing.T) {
+	home := t.TempDir()
+
+	fetches := 0
+
+	getter := &testGetter{
+		get: func(wd, src, dst string) error {
+			fetches++
+			if err := os.MkdirAll(filepath.Join(dst, "releases"), 0755); err != nil {
+				return err
+			}
+			return os.WriteFile(filepath.Join(dst, "releases", "kiam.yaml"), []byte("foo: bar"), 0644)
+		},
+	}
+
+	remote := &Remote{
+		Logger:  helmexec.NewLogger(io.Discard, "debug"),
+		Home:    home,
+		Getter:  getter,
+		Offline: true,
+		fs:      filesystem.DefaultFileSystem(),
+	}
+
+	url := "git::https://github.com/cloudposse/helmfiles.git@releases/kiam.yaml?ref=main"
+
+	cached, err := remote.Cached(url)
+	require.NoError(t, err)
+	require.False(t, cached)
+
+	_, err = remote.Fetch(url)
+	require.Equal(t, NotCachedError{Source: url}, err)
+	require.Equal(t, 0, fetches)
+
+	remote.Offline = false
+	_, err = remote.Fetch(url)
+	require.NoError(t, err)
+
+	remote.Offline = true
+
+	cached, err = remote.Cached(url)
+	require.NoError(t, err)
+	require.True(t, cached)
+
+	// The cached file is kept instead of being fetched again
+	file, err := remote.Refresh(url)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(home, "https_github_com_cloudposse_helmfiles_git.ref=main", "releases", "kiam.yaml"), file)
+	require.Equal(t, 1, fetches)
+}
+
+func TestRemote_SingleFile(t *testing.T) {
+	files := t.TempDir()
+	values := filepath.Join(files, "values.yaml")
+	require.NoError(t, os.WriteFile(values, []byte("replicas: 2\n"), 0644))
+
+	getter := &testFileGetter{
+		files: map[string]string{
+			"https://config.example.com/org/values.yaml":                       values,
+			"https://config.example.com/org/values.yaml?token=abc":             values,
+			"s3::https://s3-eu-west-1.amazonaws.com/shared-config/values.yaml": values,
+		},
+	}
+
+	testcases := []struct {
+		url string
+		err string
+	}{
+		{url: "https://config.example.com/org/values.yaml"},
+		{url: "https://config.example.com/org/values.yaml?checksum=sha256:" + sha256sum(t, values) + "&token=abc"},
+		{url: "s3://shared-config/values.yaml?region=eu-west-1"},
+		{
+			url: "https://config.example.com/org/values.yaml?checksum=sha256:0000",
+			err: "values.yaml: checksum mismatch: want sha256:0000, got sha256:" + sha256sum(t, values),
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.url, func(t *testing.T) {
+			r := &Remote{
+				Logger: helmexec.NewLogger(io.Discard, "debug"),
+				Home:   t.TempDir(),
+				Getter: getter,
+				fs:     filesystem.DefaultFileSystem(),
+			}
+
+			file, err := r.Fetch(tc.url)
+			if tc.err != "" {
+				require.EqualError(t, err, tc.err)
+				entries, _ := os.ReadDir(r.Home)
+				require.Empty(t, entries, "the cache must be removed on errors")
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, "values.yaml", filepath.Base(file))
+
+			content, err := os.ReadFile(file)
+			require.NoError(t, err)
+			require.Equal(t, "replicas: 2\n", string(content))
+		})
+	}
+
+	t.Run("the files in the same directory are cached separately", func(t *testing.T) {
+		u1, err := Parse("https://config.example.com/org/values.yaml")
+		require.NoError(t, err)
+		u2, err := Parse("https://config.example.com/org/secrets.yaml")
+		require.NoError(t, err)
+		require.NotEqual(t, cacheKey(u1), cacheKey(u2))
+	})
+}
+
 type testGetter struct {
 	get func(wd, src, dst string) error
 }
@@ -0,0 +1,392 @@
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/Masterminds/semver/v3"
+
+	"github.com/helmfile/helmfile/pkg/app/version"
+	"github.com/helmfile/helmfile/pkg/helmexec"
+	"github.com/helmfile/helmfile/pkg/yaml"
+)
+
+// The policies to limit the versions the chart of a release is bumped to by `helmfile bump`
+const (
+	// BumpPolicyPatch bumps the chart to the latest version with the same major and minor versions
+	BumpPolicyPatch = "patch"
+	// BumpPolicyMinor bumps the chart to the latest version with the same major version
+	BumpPolicyMinor = "minor"
+	// BumpPolicyAny bumps the chart to the latest version
+	BumpPolicyAny = "any"
+)
+
+// VersionBump is a newer version of the chart of a release found by BumpVersions
+type VersionBump struct {
+	ID      string `json:"id"`
+	Release string `json:"release"`
+	Chart   string `json:"chart"`
+	Current string `json:"current"`
+	Latest  string `json:"latest"`
+	// File is the file the version is updated in, which is either the state file or the lock file
+	File string `json:"file"`
+	// Updated is false when the version couldn't be found in the file, like when it is rendered by a template
+	Updated bool `json:"updated"`
+
+	// namespace is the namespace of the release, to find it in the state file along with its name
+	namespace string
+}
+
+func (st *HelmState) bumpPolicy(release *ReleaseSpec) string {
+	if release.BumpPolicy != "" {
+		return release.BumpPolicy
+	}
+	if st.HelmDefaults.BumpPolicy != "" {
+		return st.HelmDefaults.BumpPolicy
+	}
+	return BumpPolicyAny
+}
+
+func validateBumpPolicy(policy string) error {
+	switch policy {
+	case BumpPolicyPatch, BumpPolicyMinor, BumpPolicyAny:
+		return nil
+	}
+	return fmt.Errorf("unknown bumpPolicy %q: must be one of %s, %s and %s", policy, BumpPolicyPatch, BumpPolicyMinor, BumpPolicyAny)
+}
+
+// BumpVersions finds the latest versions of the charts of the releases allowed by their bumpPolicy,
+// and updates the versions pinned in the state file.
+// The versions of the releases whose `version` is a constraint, like `~1.2.0`, are updated in the lock file instead,
+// to the latest ones satisfying the constraints.
+// Nothing is written when dryRun is true.
+func (st *HelmState) BumpVersions(helm helmexec.Interface, dryRun bool) ([]VersionBump, []error) {
+	repos := map[string]RepositorySpec{}
+	for _, r := range st.Repositories {
+		repos[r.Name] = r
+	}
+
+	depMan := NewChartDependencyManager(stateFileBaseName(st.FilePath), st.logger, st.LockFile)
+	if st.fs != nil && st.fs.ReadFile != nil {
+		depMan.readFile = st.fs.ReadFile
+	}
+
+	var locked *ChartLockedRequirements
+	if bs, err := depMan.readBytes(depMan.lockFileName()); err == nil {
+		locked = &ChartLockedRequirements{}
+		if err := yaml.Unmarshal(bs, locked); err != nil {
+			return nil, []error{fmt.Errorf("parsing %s: %v", depMan.lockFileName(), err)}
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, []error{err}
+	}
+
+	var (
+		bumps    []VersionBump
+		errs     []error
+		versions = map[string][]string{}
+	)
+
+	for i := range st.Releases {
+		release := &st.Releases[i]
+
+		repoAndChart, digest, err := releaseChartDigest(release)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		repoName, chart, ok := resolveRemoteChart(repoAndChart)
+		if !ok {
+			continue
+		}
+
+		repo, ok := repos[repoName]
+		// Skip the local charts like `charts/myapp`, as there's no matching `repository` in the helmfile state
+		if !ok {
+			continue
+		}
+
+		if digest != "" {
+			st.logger.Debugf("skipped bumping release %s, as its chart is pinned to the digest %s", release.Name, digest)
+			continue
+		}
+
+		policy := st.bumpPolicy(release)
+		if err := validateBumpPolicy(policy); err != nil {
+			errs = append(errs, fmt.Errorf("release %s: %v", release.Name, err))
+			continue
+		}
+
+		current, constraint := release.Version, ""
+		if _, err := semver.StrictNewVersion(current); err != nil {
+			if locked == nil {
+				st.logger.Debugf("skipped bumping release %s, as its version %q is neither pinned nor locked", release.Name, current)
+				continue
+			}
+
+			constraint = current
+			if constraint == "" {
+				constraint = "*"
+			}
+
+			current, err = lockedVersion(locked, chart, constraint)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("release %s: %v", release.Name, err))
+				continue
+			}
+			if current == "" {
+				st.logger.Debugf("skipped bumping release %s, as its chart isn't locked", release.Name)
+				continue
+			}
+		}
+
+		vs, ok := versions[repoAndChart]
+		if !ok {
+			vs, err = chartVersions(helm, repo, repoAndChart, chart)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("looking up the versions of chart %s: %v", repoAndChart, err))
+				continue
+			}
+			versions[repoAndChart] = vs
+		}
+
+		latest, err := latestAllowedVersion(current, vs, policy, constraint)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("release %s: %v", release.Name, err))
+			continue
+		}
+		if latest == "" {
+			continue
+		}
+
+		file := st.FilePath
+		if constraint != "" {
+			file = depMan.lockFileName()
+		}
+
+		bumps = append(bumps, VersionBump{
+			ID:      ReleaseToID(release),
+			Release: release.Name,
+			Chart:   repoAndChart,
+			Current: current,
+			Latest:  latest,
+			File:    file,
+
+			namespace: release.Namespace,
+		})
+	}
+
+	if len(errs) > 0 {
+		return bumps, errs
+	}
+
+	if err := st.writeVersionBumps(depMan, locked, bumps, dryRun); err != nil {
+		return bumps, []error{err}
+	}
+
+	return bumps, nil
+}
+
+// writeVersionBumps updates the versions in the state file and the lock file, marking the bumps found in them as updated
+func (st *HelmState) writeVersionBumps(depMan *chartDependencyManager, locked *ChartLockedRequirements, bumps []VersionBump, dryRun bool) error {
+	var (
+		editor       *stateFileEditor
+		stateUpdated bool
+		lockUpdated  bool
+	)
+
+	for i, b := range bumps {
+		// The lock file is updated for the pinned versions too, so that they keep matching the locked ones
+		if locked != nil {
+			_, chart, _ := resolveRemoteChart(b.Chart)
+			for j, d := range locked.ResolvedDependencies {
+				if d.ChartName == chart && d.Version == b.Current {
+					locked.ResolvedDependencies[j].Version = b.Latest
+					lockUpdated = true
+					if b.File != st.FilePath {
+						bumps[i].Updated = true
+					}
+				}
+			}
+		}
+
+		if b.File != st.FilePath {
+			continue
+		}
+
+		if editor == nil {
+			bs, err := depMan.readBytes(st.FilePath)
+			if err != nil {
+				return err
+			}
+
+			editor, err = newStateFileEditor(bs)
+			if err != nil {
+				st.logger.Warnf("unable to parse %s to update the versions: %v", st.FilePath, err)
+				editor = &stateFileEditor{content: bs}
+			}
+		}
+
+		if !editor.set(editor.release(b.Release, b.namespace), "version", b.Current, b.Latest) {
+			st.logger.Warnf("unable to find the version %s of release %s in %s. Update it to %s manually", b.Current, b.Release, st.FilePath, b.Latest)
+			continue
+		}
+
+		bumps[i].Updated = true
+		stateUpdated = true
+	}
+
+	if dryRun {
+		return nil
+	}
+
+	if stateUpdated {
+		if err := depMan.writeBytes(st.FilePath, editor.bytes()); err != nil {
+			return err
+		}
+	}
+
+	if lockUpdated {
+		locked.Version = version.Version()
+
+		bs, err := yaml.Marshal(locked)
+		if err != nil {
+			return err
+		}
+
+		if err := depMan.writeBytes(depMan.lockFileName(), bs); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// lockedVersion returns the version of the chart satisfying the constraint in the lock file, or an empty string if it isn't locked
+func lockedVersion(locked *ChartLockedRequirements, chart, constraint string) (string, error) {
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return "", err
+	}
+
+	for _, d := range locked.ResolvedDependencies {
+		if d.ChartName != chart {
+			continue
+		}
+
+		v, err := semver.NewVersion(d.Version)
+		if err != nil {
+			return "", err
+		}
+
+		if c.Check(v) {
+			if d.Digest != "" {
+				return "", nil
+			}
+			return d.Version, nil
+		}
+	}
+
+	return "", nil
+}
+
+// helmSearchResult is a chart version in the output of `helm search repo --output json`
+type helmSearchResult struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// chartVersions returns the versions of the chart available in the repository.
+// Only the latest version is available for an OCI repository, as helm can't list the tags of a registry.
+func chartVersions(helm helmexec.Interface, repo RepositorySpec, repoAndChart, chart string) ([]string, error) {
+	buf := &bytes.Buffer{}
+	context := helmexec.HelmContext{Writer: buf}
+
+	if repo.OCI {
+		if err := helm.Exec(context, "show", "chart", fmt.Sprintf("oci://%s/%s", repo.URL, chart)); err != nil {
+			return nil, err
+		}
+
+		var meta struct {
+			Version string `yaml:"version"`
+		}
+		if err := yaml.Unmarshal(buf.Bytes(), &meta); err != nil {
+			return nil, err
+		}
+
+		return []string{meta.Version}, nil
+	}
+
+	if err := helm.Exec(context, "search", "repo", "^"+regexp.QuoteMeta(repoAndChart)+"$", "--regexp", "--versions", "--devel", "--output", "json"); err != nil {
+		return nil, err
+	}
+
+	var results []helmSearchResult
+	if err := json.Unmarshal(buf.Bytes(), &results); err != nil {
+		return nil, err
+	}
+
+	var vs []string
+	for _, r := range results {
+		vs = append(vs, r.Version)
+	}
+
+	return vs, nil
+}
+
+// latestAllowedVersion returns the latest of the versions newer than the current one allowed by the policy and the constraint,
+// or an empty string if there is none.
+// Prereleases are allowed only when the current version is a prerelease.
+func latestAllowedVersion(current string, versions []string, policy, constraint string) (string, error) {
+	cur, err := semver.NewVersion(current)
+	if err != nil {
+		return "", err
+	}
+
+	var c *semver.Constraints
+	if constraint != "" {
+		c, err = semver.NewConstraint(constraint)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	var latest *semver.Version
+
+	for _, s := range versions {
+		v, err := semver.NewVersion(s)
+		if err != nil {
+			continue
+		}
+
+		if !v.GreaterThan(cur) || (v.Prerelease() != "" && cur.Prerelease() == "") {
+			continue
+		}
+
+		if policy != BumpPolicyAny && v.Major() != cur.Major() {
+			continue
+		}
+
+		if policy == BumpPolicyPatch && v.Minor() != cur.Minor() {
+			continue
+		}
+
+		if c != nil && !c.Check(v) {
+			continue
+		}
+
+		if latest == nil || v.GreaterThan(latest) {
+			latest = v
+		}
+	}
+
+	if latest == nil {
+		return "", nil
+	}
+
+	return latest.Original(), nil
+}
@@ -0,0 +1,127 @@
+package state
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/exectest"
+	"github.com/helmfile/helmfile/pkg/helmexec"
+)
+
+// searchHelm prints the canned `helm search repo` and `helm show chart` outputs of the charts
+type searchHelm struct {
+	exectest.Helm
+
+	outputs map[string]string
+}
+
+func (helm *searchHelm) Exec(context helmexec.HelmContext, args ...string) error {
+	out, ok := helm.outputs[args[2]]
+	if !ok {
+		return fmt.Errorf("unexpected args: %v", args)
+	}
+	fmt.Fprint(context.Writer, out)
+	return nil
+}
+
+func TestLatestAllowedVersion(t *testing.T) {
+	versions := []string{"1.2.3", "1.2.5", "1.3.0", "1.4.0-rc.1", "2.0.0", "0.9.0"}
+
+	tests := []struct {
+		current    string
+		policy     string
+		constraint string
+		want       string
+	}{
+		{current: "1.2.3", policy: BumpPolicyPatch, want: "1.2.5"},
+		{current: "1.2.3", policy: BumpPolicyMinor, want: "1.3.0"},
+		{current: "1.2.3", policy: BumpPolicyAny, want: "2.0.0"},
+		{current: "1.2.3", policy: BumpPolicyAny, constraint: "~1.2.0", want: "1.2.5"},
+		{current: "1.4.0-rc.0", policy: BumpPolicyMinor, want: "1.4.0-rc.1"},
+		{current: "2.0.0", policy: BumpPolicyAny, want: ""},
+	}
+
+	for _, tt := range tests {
+		got, err := latestAllowedVersion(tt.current, versions, tt.policy, tt.constraint)
+		require.NoError(t, err)
+		require.Equal(t, tt.want, got, "current=%s policy=%s constraint=%s", tt.current, tt.policy, tt.constraint)
+	}
+}
+
+func TestHelmState_BumpVersions(t *testing.T) {
+	dir := t.TempDir()
+
+	stateFile := filepath.Join(dir, "helmfile.yaml")
+	require.NoError(t, os.WriteFile(stateFile, []byte(`repositories:
+- name: stable
+  url: https://charts.example.com
+releases:
+- name: web
+  chart: stable/web
+  version: 1.2.3
+  bumpPolicy: patch
+- name: db
+  chart: stable/db
+  version: ~1.0.0
+- name: local
+  chart: ./charts/local
+`), 0644))
+
+	lockFile := filepath.Join(dir, "helmfile.lock")
+	require.NoError(t, os.WriteFile(lockFile, []byte(`version: ""
+dependencies:
+- name: db
+  repository: https://charts.example.com
+  version: 1.0.1
+digest: sha256:abc
+generated: "2023-01-01T00:00:00Z"
+`), 0644))
+
+	st := &HelmState{
+		FilePath: stateFile,
+		ReleaseSetSpec: ReleaseSetSpec{
+			LockFile:     lockFile,
+			Repositories: []RepositorySpec{{Name: "stable", URL: "https://charts.example.com"}},
+			Releases: []ReleaseSpec{
+				{Name: "web", Chart: "stable/web", Version: "1.2.3", BumpPolicy: BumpPolicyPatch},
+				{Name: "db", Chart: "stable/db", Version: "~1.0.0"},
+				{Name: "local", Chart: "./charts/local"},
+			},
+		},
+		logger: logger,
+	}
+
+	helm := &searchHelm{
+		outputs: map[string]string{
+			`^stable/web$`: `[{"name":"stable/web","version":"1.3.0"},{"name":"stable/web","version":"1.2.4"},{"name":"stable/web","version":"1.2.3"}]`,
+			`^stable/db$`:  `[{"name":"stable/db","version":"1.1.0"},{"name":"stable/db","version":"1.0.2"},{"name":"stable/db","version":"1.0.1"}]`,
+		},
+	}
+
+	bumps, errs := st.BumpVersions(helm, true)
+	require.Empty(t, errs)
+	require.Equal(t, []VersionBump{
+		{ID: "web", Release: "web", Chart: "stable/web", Current: "1.2.3", Latest: "1.2.4", File: stateFile, Updated: true},
+		{ID: "db", Release: "db", Chart: "stable/db", Current: "1.0.1", Latest: "1.0.2", File: lockFile, Updated: true},
+	}, bumps)
+
+	bs, err := os.ReadFile(stateFile)
+	require.NoError(t, err)
+	require.Contains(t, string(bs), "version: 1.2.3\n", "dry-run must not write the state file")
+
+	_, errs = st.BumpVersions(helm, false)
+	require.Empty(t, errs)
+
+	bs, err = os.ReadFile(stateFile)
+	require.NoError(t, err)
+	require.Contains(t, string(bs), "  version: 1.2.4\n  bumpPolicy: patch\n")
+	require.Contains(t, string(bs), "  version: ~1.0.0\n")
+
+	bs, err = os.ReadFile(lockFile)
+	require.NoError(t, err)
+	require.Contains(t, string(bs), "version: 1.0.2\n")
+}
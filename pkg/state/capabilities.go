@@ -0,0 +1,75 @@
+package state
+
+import (
+	"fmt"
+
+	"github.com/helmfile/helmfile/pkg/yaml"
+)
+
+// CapabilitiesSpec is the capabilities of the target clusters the releases are rendered for,
+// passed to `helm template` and `helm diff` with `--api-versions` and `--kube-version`
+type CapabilitiesSpec struct {
+	ApiVersions []string `yaml:"apiVersions,omitempty"`
+	KubeVersion string   `yaml:"kubeVersion,omitempty"`
+}
+
+// CapabilitiesFile is the content of the file referenced by `capabilitiesFile`
+type CapabilitiesFile struct {
+	CapabilitiesSpec `yaml:",inline"`
+
+	// Environments overrides the top-level capabilities when the environment is selected
+	Environments map[string]CapabilitiesSpec `yaml:"environments,omitempty"`
+}
+
+// capabilities returns the capabilities for the environment, the keys of the environment overriding the top-level ones
+func (f CapabilitiesFile) capabilities(env string) CapabilitiesSpec {
+	c := f.CapabilitiesSpec
+
+	if e, ok := f.Environments[env]; ok {
+		if len(e.ApiVersions) > 0 {
+			c.ApiVersions = e.ApiVersions
+		}
+		if e.KubeVersion != "" {
+			c.KubeVersion = e.KubeVersion
+		}
+	}
+
+	return c
+}
+
+// applyCapabilitiesFile loads the capabilities file for the environment, if any,
+// into the `apiVersions` and the `kubeVersion` of the state that aren't set in the state itself
+func (st *HelmState) applyCapabilitiesFile(env string) error {
+	if st.CapabilitiesFile == "" {
+		return nil
+	}
+
+	files, _, err := st.storage().resolveFile(nil, "capabilities", st.CapabilitiesFile)
+	if err != nil {
+		return err
+	}
+	if len(files) != 1 {
+		return fmt.Errorf("capabilities file matching %q: expected a single file, found %d", st.CapabilitiesFile, len(files))
+	}
+
+	bs, err := st.fs.ReadFile(files[0])
+	if err != nil {
+		return err
+	}
+
+	var f CapabilitiesFile
+	if err := yaml.Unmarshal(bs, &f); err != nil {
+		return fmt.Errorf("parsing capabilities file %s: %v", files[0], err)
+	}
+
+	c := f.capabilities(env)
+
+	if len(st.ApiVersions) == 0 {
+		st.ApiVersions = c.ApiVersions
+	}
+	if st.KubeVersion == "" {
+		st.KubeVersion = c.KubeVersion
+	}
+
+	return nil
+}
@@ -1,11 +1,13 @@
 package state
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/Masterminds/semver/v3"
 	goversion "github.com/hashicorp/go-version"
@@ -29,6 +31,9 @@ type unresolvedChartDependency struct {
 	Repository string `yaml:"repository"`
 	// VersionConstraint is the version constraint of the dependent chart. "*" means the latest version.
 	VersionConstraint string `yaml:"version"`
+	// Digest is the digest the OCI chart is pinned to in the helmfile spec.
+	// It isn't passed to helm, but recorded into the lock file.
+	Digest string `yaml:"-"`
 }
 
 type ResolvedChartDependency struct {
@@ -41,6 +46,8 @@ type ResolvedChartDependency struct {
 	// Version is the version number of the dependent chart.
 	// In the context of helmfile this can be omitted. When omitted, it is considered `*` which results helm/helmfile fetching the latest version.
 	Version string `yaml:"version"`
+	// Digest is the digest the OCI chart is pinned to, like `sha256:...`.
+	Digest string `yaml:"digest,omitempty"`
 }
 
 type UnresolvedDependencies struct {
@@ -56,6 +63,8 @@ type ChartLockedRequirements struct {
 	ResolvedDependencies []ResolvedChartDependency `yaml:"dependencies"`
 	Digest               string                    `yaml:"digest"`
 	Generated            string                    `yaml:"generated"`
+	// Remotes are the remote helmfiles, values files and secrets files pinned by the checksums of their contents
+	Remotes []LockedRemote `yaml:"remotes,omitempty"`
 }
 
 func (d *UnresolvedDependencies) Add(chart, url, versionConstraint string) error {
@@ -133,6 +142,16 @@ func (d *ResolvedDependencies) Get(chart, versionConstraint string) (string, err
 	return "", fmt.Errorf("no resolved dependency found for \"%s\", running \"helmfile deps\" may resolve the issue", chart)
 }
 
+// Digest returns the digest the locked chart of the version is pinned to, or an empty string if it isn't pinned.
+func (d *ResolvedDependencies) Digest(chart, version string) string {
+	for _, dep := range d.deps[chart] {
+		if dep.Version == version {
+			return dep.Digest
+		}
+	}
+	return ""
+}
+
 func (st *HelmState) mergeLockedDependencies() (*HelmState, error) {
 	filename, unresolved, err := getUnresolvedDependenciess(st)
 	if err != nil {
@@ -161,23 +180,23 @@ func resolveDependencies(st *HelmState, depMan *chartDependencyManager, unresolv
 		return st, nil
 	}
 
-	repoToURL := map[string]string{}
-
-	for _, r := range st.Repositories {
-		repoToURL[r.Name] = r.URL
-	}
+	repos := repositoriesByName(st)
 
 	updated := *st
 	for i, r := range updated.Releases {
-		repo, chart, ok := resolveRemoteChart(r.Chart)
+		repoAndChart, digest, err := releaseChartDigest(&r)
+		if err != nil {
+			return nil, err
+		}
+
+		_, chart, ok := lockableChart(repos, repoAndChart)
 		if !ok {
 			continue
 		}
 
-		_, ok = repoToURL[repo]
-		// Skip this chart from dependency management, as there's no matching `repository` in the helmfile state,
-		// which may imply that this is a local chart within a directory, like `charts/myapp`
-		if !ok {
+		// The OCI charts referenced by URL weren't locked by the older versions of Helmfile,
+		// so they are left unlocked until `helmfile deps` adds them to the lock file
+		if _, locked := resolved.deps[chart]; !locked && strings.HasPrefix(repoAndChart, "oci://") {
 			continue
 		}
 
@@ -187,18 +206,25 @@ func resolveDependencies(st *HelmState, depMan *chartDependencyManager, unresolv
 		}
 
 		updated.Releases[i].Version = ver
+
+		if digest == "" {
+			updated.Releases[i].Digest = resolved.Digest(chart, ver)
+		}
 	}
 
 	return &updated, nil
 }
 
-func (st *HelmState) updateDependenciesInTempDir(shell helmexec.DependencyUpdater, tempDir func(string, string) (string, error)) (*HelmState, error) {
+func (st *HelmState) updateDependenciesInTempDir(shell helmexec.DependencyUpdater, tempDir func(string, string) (string, error), updateLocks bool) (*HelmState, error) {
 	filename, unresolved, err := getUnresolvedDependenciess(st)
 	if err != nil {
 		return nil, err
 	}
 
 	if len(unresolved.deps) == 0 {
+		if len(st.remoteSources()) > 0 {
+			return st, st.lockRemotesOnly(filename, updateLocks)
+		}
 		st.logger.Warnf("There are no repositories defined in your helmfile.yaml.\nThis means helmfile cannot update your dependencies or create a lock file.\nSee https://github.com/roboll/helmfile/issues/878 for more information.")
 		return st, nil
 	}
@@ -211,54 +237,127 @@ func (st *HelmState) updateDependenciesInTempDir(shell helmexec.DependencyUpdate
 		_ = os.RemoveAll(d)
 	}()
 
-	return updateDependencies(st, shell, unresolved, filename, d)
+	return updateDependencies(st, shell, unresolved, filename, d, updateLocks)
 }
 
-func getUnresolvedDependenciess(st *HelmState) (string, *UnresolvedDependencies, error) {
-	repoToURL := map[string]RepositorySpec{}
+// lockRemotesOnly writes the lock file of the state with no chart to lock, pinning only the remote sources
+func (st *HelmState) lockRemotesOnly(filename string, updateLocks bool) error {
+	depMan := NewChartDependencyManager(filename, st.logger, st.LockFile)
 
-	for _, r := range st.Repositories {
-		repoToURL[r.Name] = r
+	locked, err := depMan.lockedRemotes()
+	if err != nil {
+		return err
+	}
+
+	remotes, err := st.lockRemotes(locked, updateLocks)
+	if err != nil {
+		return err
+	}
+
+	content, err := yaml.Marshal(&ChartLockedRequirements{
+		Version:              version.Version(),
+		ResolvedDependencies: []ResolvedChartDependency{},
+		Generated:            time.Now().Format(time.RFC3339Nano),
+		Remotes:              remotes,
+	})
+	if err != nil {
+		return err
 	}
 
+	return depMan.writeBytes(depMan.lockFileName(), content)
+}
+
+func getUnresolvedDependenciess(st *HelmState) (string, *UnresolvedDependencies, error) {
+	repos := repositoriesByName(st)
+
 	unresolved := &UnresolvedDependencies{deps: map[string][]unresolvedChartDependency{}}
 
 	for _, r := range st.Releases {
-		repo, chart, ok := resolveRemoteChart(r.Chart)
-		if !ok {
-			continue
+		repoAndChart, digest, err := releaseChartDigest(&r)
+		if err != nil {
+			return "", nil, err
 		}
 
-		repoSpec, ok := repoToURL[repo]
-		// Skip this chart from dependency management, as there's no matching `repository` in the helmfile state,
-		// which may imply that this is a local chart within a directory, like `charts/myapp`
+		url, chart, ok := lockableChart(repos, repoAndChart)
 		if !ok {
 			continue
 		}
 
-		url := repoSpec.URL
-
-		if repoSpec.OCI {
-			url = fmt.Sprintf("oci://%s", url)
+		dep := unresolvedChartDependency{
+			ChartName:         chart,
+			Repository:        url,
+			VersionConstraint: r.Version,
+			Digest:            digest,
 		}
 
-		if err := unresolved.Add(chart, url, r.Version); err != nil {
+		if err := unresolved.add(dep); err != nil {
 			return "", nil, err
 		}
 	}
 
-	filename := filepath.Base(st.FilePath)
+	return stateFileBaseName(st.FilePath), unresolved, nil
+}
+
+func repositoriesByName(st *HelmState) map[string]RepositorySpec {
+	repos := map[string]RepositorySpec{}
+
+	for _, r := range st.Repositories {
+		repos[r.Name] = r
+	}
+
+	return repos
+}
+
+// lockableChart returns the URL of the repository and the name of the chart managed with the lock file,
+// which is either in one of the repositories or an OCI chart referenced like `oci://registry/path/app`
+func lockableChart(repos map[string]RepositorySpec, repoAndChart string) (string, string, bool) {
+	if url, chart, ok := resolveOCIChart(repoAndChart); ok {
+		return url, chart, true
+	}
+
+	repo, chart, ok := resolveRemoteChart(repoAndChart)
+	if !ok {
+		return "", "", false
+	}
+
+	repoSpec, ok := repos[repo]
+	// Skip this chart from dependency management, as there's no matching `repository` in the helmfile state,
+	// which may imply that this is a local chart within a directory, like `charts/myapp`
+	if !ok {
+		return "", "", false
+	}
+
+	if repoSpec.OCI {
+		return fmt.Sprintf("oci://%s", repoSpec.URL), chart, true
+	}
+
+	return repoSpec.URL, chart, true
+}
+
+// stateFileBaseName returns the name of the state file without the extensions, which the lock file is named after by default
+func stateFileBaseName(path string) string {
+	filename := filepath.Base(path)
 	filename = strings.TrimSuffix(filename, ".gotmpl")
 	filename = strings.TrimSuffix(filename, ".yaml")
 	filename = strings.TrimSuffix(filename, ".yml")
 
-	return filename, unresolved, nil
+	return filename
 }
 
-func updateDependencies(st *HelmState, shell helmexec.DependencyUpdater, unresolved *UnresolvedDependencies, filename, wd string) (*HelmState, error) {
+func updateDependencies(st *HelmState, shell helmexec.DependencyUpdater, unresolved *UnresolvedDependencies, filename, wd string, updateLocks bool) (*HelmState, error) {
 	depMan := NewChartDependencyManager(filename, st.logger, st.LockFile)
+	depMan.updateLocks = updateLocks
+	depMan.lockRemotes = func(locked []LockedRemote) ([]LockedRemote, error) {
+		return st.lockRemotes(locked, updateLocks)
+	}
 
-	_, err := depMan.Update(shell, wd, unresolved)
+	if digester, ok := shell.(helmexec.ChartDigester); ok {
+		depMan.resolveDigest = func(repository, chart, version string) (string, error) {
+			return st.resolveOCIDigest(digester, fmt.Sprintf("%s/%s:%s", st.mirrorOCIURL(strings.TrimPrefix(repository, "oci://")), chart, version))
+		}
+	}
+
+	_, err := depMan.Update(st.runContext(), shell, wd, unresolved)
 	if err != nil {
 		return nil, fmt.Errorf("unable to update %d deps: %v", len(unresolved.deps), err)
 	}
@@ -275,6 +374,16 @@ type chartDependencyManager struct {
 
 	readFile  func(string) ([]byte, error)
 	writeFile func(string, []byte, os.FileMode) error
+
+	// resolveDigest returns the digest of the version of the chart in the OCI repository like `oci://registry/path`,
+	// to pin the locked OCI charts not pinned in the helmfile spec
+	resolveDigest func(repository, chart, version string) (string, error)
+
+	// updateLocks makes the digests of the OCI charts resolved again, instead of kept from the lock file for the same versions
+	updateLocks bool
+
+	// lockRemotes returns the remote sources to pin in the lock file, given the ones pinned so far
+	lockRemotes func(locked []LockedRemote) ([]LockedRemote, error)
 }
 
 func NewChartDependencyManager(name string, logger *zap.SugaredLogger, lockFilePath string) *chartDependencyManager {
@@ -295,11 +404,11 @@ func (m *chartDependencyManager) lockFileName() string {
 	}
 }
 
-func (m *chartDependencyManager) Update(shell helmexec.DependencyUpdater, wd string, unresolved *UnresolvedDependencies) (*ResolvedDependencies, error) {
-	return m.updateHelm3(shell, wd, unresolved)
+func (m *chartDependencyManager) Update(ctx context.Context, shell helmexec.DependencyUpdater, wd string, unresolved *UnresolvedDependencies) (*ResolvedDependencies, error) {
+	return m.updateHelm3(ctx, shell, wd, unresolved)
 }
 
-func (m *chartDependencyManager) updateHelm3(shell helmexec.DependencyUpdater, wd string, unresolved *UnresolvedDependencies) (*ResolvedDependencies, error) {
+func (m *chartDependencyManager) updateHelm3(ctx context.Context, shell helmexec.DependencyUpdater, wd string, unresolved *UnresolvedDependencies) (*ResolvedDependencies, error) {
 	// Generate `Chart.yaml` of the temporary local chart
 	chartMetaContent := fmt.Sprintf("name: %s\nversion: 1.0.0\napiVersion: v2\n", m.Name)
 
@@ -312,10 +421,10 @@ func (m *chartDependencyManager) updateHelm3(shell helmexec.DependencyUpdater, w
 		return nil, err
 	}
 
-	return m.doUpdate("Chart.lock", unresolved, shell, wd)
+	return m.doUpdate(ctx, "Chart.lock", unresolved, shell, wd)
 }
 
-func (m *chartDependencyManager) doUpdate(chartLockFile string, unresolved *UnresolvedDependencies, shell helmexec.DependencyUpdater, wd string) (*ResolvedDependencies, error) {
+func (m *chartDependencyManager) doUpdate(ctx context.Context, chartLockFile string, unresolved *UnresolvedDependencies, shell helmexec.DependencyUpdater, wd string) (*ResolvedDependencies, error) {
 	// Generate `requirements.lock` of the temporary local chart by coping `<basename>.lock`
 	lockFilePath := m.lockFileName()
 
@@ -331,7 +440,7 @@ func (m *chartDependencyManager) doUpdate(chartLockFile string, unresolved *Unre
 	}
 
 	// Update the lock file by running `helm dependency update`
-	if err := shell.UpdateDeps(wd); err != nil {
+	if err := shell.UpdateDeps(ctx, wd); err != nil {
 		return nil, err
 	}
 
@@ -346,10 +455,58 @@ func (m *chartDependencyManager) doUpdate(chartLockFile string, unresolved *Unre
 		return nil, err
 	}
 
+	// helm drops what only helmfile records in the lock file, which is taken over from the original lock file
+	originalLockedReqs := &ChartLockedRequirements{}
+	if originalLockFileContent != nil {
+		if err := yaml.Unmarshal(originalLockFileContent, originalLockedReqs); err != nil {
+			return nil, err
+		}
+	}
+
+	// helm doesn't know the digests the charts are pinned to, so record them from the helmfile spec,
+	// or else keep the ones locked for the same versions, or resolve the locked versions of the OCI charts to the digests they point to now
+	for i, dep := range lockedReqs.ResolvedDependencies {
+		for _, u := range unresolved.deps[dep.ChartName] {
+			if u.Digest != "" {
+				lockedReqs.ResolvedDependencies[i].Digest = u.Digest
+				break
+			}
+		}
+
+		if lockedReqs.ResolvedDependencies[i].Digest != "" || !strings.HasPrefix(dep.Repository, "oci://") {
+			continue
+		}
+
+		if !m.updateLocks {
+			for _, o := range originalLockedReqs.ResolvedDependencies {
+				if o.ChartName == dep.ChartName && o.Repository == dep.Repository && o.Version == dep.Version {
+					lockedReqs.ResolvedDependencies[i].Digest = o.Digest
+					break
+				}
+			}
+		}
+
+		if lockedReqs.ResolvedDependencies[i].Digest != "" || m.resolveDigest == nil {
+			continue
+		}
+
+		digest, err := m.resolveDigest(dep.Repository, dep.ChartName, dep.Version)
+		if err != nil {
+			return nil, fmt.Errorf("resolving the digest of %s/%s:%s: %w", dep.Repository, dep.ChartName, dep.Version, err)
+		}
+		lockedReqs.ResolvedDependencies[i].Digest = digest
+	}
+
 	sort.Slice(lockedReqs.ResolvedDependencies, func(i, j int) bool {
 		return lockedReqs.ResolvedDependencies[i].ChartName < lockedReqs.ResolvedDependencies[j].ChartName
 	})
 
+	if m.lockRemotes != nil {
+		if lockedReqs.Remotes, err = m.lockRemotes(originalLockedReqs.Remotes); err != nil {
+			return nil, err
+		}
+	}
+
 	lockedReqs.Version = version.Version()
 
 	updatedLockFileContent, err = yaml.Marshal(lockedReqs)
@@ -411,6 +568,24 @@ func (m *chartDependencyManager) Resolve(unresolved *UnresolvedDependencies) (*R
 	return resolved, true, nil
 }
 
+// lockedRemotes returns the remote sources pinned in the lock file, if any
+func (m *chartDependencyManager) lockedRemotes() ([]LockedRemote, error) {
+	content, err := m.readBytes(m.lockFileName())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	lockedReqs := &ChartLockedRequirements{}
+	if err := yaml.Unmarshal(content, lockedReqs); err != nil {
+		return nil, err
+	}
+
+	return lockedReqs.Remotes, nil
+}
+
 func (m *chartDependencyManager) readBytes(filename string) ([]byte, error) {
 	bytes, err := m.readFile(filename)
 	if err != nil {
@@ -1,9 +1,15 @@
 package state
 
 import (
+	"context"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/exectest"
+	"github.com/helmfile/helmfile/pkg/yaml"
 )
 
 func TestGetUnresolvedDependenciess(t *testing.T) {
@@ -49,6 +55,86 @@ func TestGetUnresolvedDependenciess(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "oci chart pinned by digest",
+			helmState: &HelmState{
+				FilePath: "helmfile.yaml",
+				ReleaseSetSpec: ReleaseSetSpec{
+					Releases: []ReleaseSpec{
+						{
+							Name:    "foo",
+							Chart:   "charts/abc@sha256:0123abcd",
+							Version: "0.1.0",
+						},
+					},
+					Repositories: []RepositorySpec{
+						{
+							Name: "charts",
+							URL:  "localhost:5000/aaa",
+							OCI:  true,
+						},
+					},
+				},
+			},
+			wantErr:    false,
+			expectfile: "helmfile",
+			expectDeps: &UnresolvedDependencies{
+				deps: map[string][]unresolvedChartDependency{
+					"abc": {
+						{
+							ChartName:         "abc",
+							Repository:        "oci://localhost:5000/aaa",
+							VersionConstraint: "0.1.0",
+							Digest:            "sha256:0123abcd",
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "oci chart referenced by url",
+			helmState: &HelmState{
+				FilePath: "helmfile.yaml",
+				ReleaseSetSpec: ReleaseSetSpec{
+					Releases: []ReleaseSpec{
+						{
+							Name:    "foo",
+							Chart:   "oci://localhost:5000/aaa/abc",
+							Version: "0.1.0",
+						},
+					},
+				},
+			},
+			wantErr:    false,
+			expectfile: "helmfile",
+			expectDeps: &UnresolvedDependencies{
+				deps: map[string][]unresolvedChartDependency{
+					"abc": {
+						{
+							ChartName:         "abc",
+							Repository:        "oci://localhost:5000/aaa",
+							VersionConstraint: "0.1.0",
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "conflicting digests",
+			helmState: &HelmState{
+				FilePath: "helmfile.yaml",
+				ReleaseSetSpec: ReleaseSetSpec{
+					Releases: []ReleaseSpec{
+						{
+							Name:   "foo",
+							Chart:  "charts/abc@sha256:0123abcd",
+							Digest: "sha256:4567efab",
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -56,11 +142,80 @@ func TestGetUnresolvedDependenciess(t *testing.T) {
 			f, ds, err := getUnresolvedDependenciess(tt.helmState)
 			if tt.wantErr {
 				require.Error(t, err, "getUnresolvedDependenciess() error = nil, wantErr")
-			} else {
-				require.NoErrorf(t, err, "getUnresolvedDependenciess() want no error, got %v", err)
+				return
 			}
+			require.NoErrorf(t, err, "getUnresolvedDependenciess() want no error, got %v", err)
 			require.Equalf(t, tt.expectfile, f, "getUnresolvedDependenciess() expect file %s, got %s", tt.expectfile, f)
 			require.Equalf(t, tt.expectDeps, ds, "getUnresolvedDependenciess() expect deps %v, got %v", tt.expectDeps, ds)
 		})
 	}
 }
+
+// lockingHelm writes the lock file of `helm dependency update` like helm does
+type lockingHelm struct {
+	*exectest.Helm
+	lock string
+}
+
+func (helm *lockingHelm) UpdateDeps(ctx context.Context, chart string) error {
+	return os.WriteFile(filepath.Join(chart, "Chart.lock"), []byte(helm.lock), 0644)
+}
+
+func TestUpdateDependencies_OCIDigests(t *testing.T) {
+	dir := t.TempDir()
+
+	st := &HelmState{
+		FilePath: "helmfile.yaml",
+		ReleaseSetSpec: ReleaseSetSpec{
+			LockFile: filepath.Join(dir, "helmfile.lock"),
+			Releases: []ReleaseSpec{
+				{Name: "foo", Chart: "oci://localhost:5000/aaa/abc", Version: "0.1.0"},
+				{Name: "bar", Chart: "oci://localhost:5000/aaa/def@sha256:4567efab", Version: "0.2.0"},
+				{Name: "baz", Chart: "stable/ghi", Version: "0.3.0"},
+			},
+			Repositories: []RepositorySpec{
+				{Name: "stable", URL: "https://charts.example.com"},
+			},
+		},
+		logger: logger,
+	}
+
+	helm := &lockingHelm{
+		Helm: &exectest.Helm{
+			Digests: map[string]string{"localhost:5000/aaa/abc:0.1.0": "sha256:0123abcd"},
+		},
+		lock: `dependencies:
+- name: abc
+  repository: oci://localhost:5000/aaa
+  version: 0.1.0
+- name: def
+  repository: oci://localhost:5000/aaa
+  version: 0.2.0
+- name: ghi
+  repository: https://charts.example.com
+  version: 0.3.0
+`,
+	}
+
+	_, unresolved, err := getUnresolvedDependenciess(st)
+	require.NoError(t, err)
+
+	updated, err := updateDependencies(st, helm, unresolved, "helmfile", t.TempDir(), false)
+	require.NoError(t, err)
+
+	require.Equal(t, "sha256:0123abcd", updated.Releases[0].Digest)
+	require.Equal(t, "0.1.0", updated.Releases[0].Version)
+	require.Equal(t, "", updated.Releases[1].Digest, "the digest in the chart is kept as is")
+	require.Equal(t, "", updated.Releases[2].Digest)
+
+	lock, err := os.ReadFile(st.LockFile)
+	require.NoError(t, err)
+
+	locked := &ChartLockedRequirements{}
+	require.NoError(t, yaml.Unmarshal(lock, locked))
+	require.Equal(t, []ResolvedChartDependency{
+		{ChartName: "abc", Repository: "oci://localhost:5000/aaa", Version: "0.1.0", Digest: "sha256:0123abcd"},
+		{ChartName: "def", Repository: "oci://localhost:5000/aaa", Version: "0.2.0", Digest: "sha256:4567efab"},
+		{ChartName: "ghi", Repository: "https://charts.example.com", Version: "0.3.0"},
+	}, locked.ResolvedDependencies)
+}
@@ -0,0 +1,192 @@
+package state
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+
+	"github.com/helmfile/helmfile/pkg/helmexec"
+	"github.com/helmfile/helmfile/pkg/yaml"
+)
+
+// ChartPackage is a local chart of a release packaged by PackageCharts
+type ChartPackage struct {
+	ID      string `json:"id"`
+	Release string `json:"release"`
+	// Chart is the local chart as referenced by the release
+	Chart   string `json:"chart"`
+	Version string `json:"version"`
+	// Path is the path to the chart archive
+	Path string `json:"path"`
+}
+
+// PackageChartsOpts is the options of PackageCharts
+type PackageChartsOpts struct {
+	// OutputDir is the directory the chart archives are written to
+	OutputDir string
+	// Version overrides the versions of the charts
+	Version string
+	// GitSuffix appends the short commit hash of the chart directory to the versions
+	GitSuffix bool
+}
+
+// PackageCharts packages the local charts of the releases into the output directory,
+// after rendering their templated files and injecting the chartVersion and the chartAppVersion of the releases.
+// Each chart is packaged once with the version given by packagedChartVersion.
+func (st *HelmState) PackageCharts(helm helmexec.Interface, opts PackageChartsOpts) ([]ChartPackage, []error) {
+	dest, err := filepath.Abs(opts.OutputDir)
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return nil, []error{err}
+	}
+
+	var (
+		packages []ChartPackage
+		errs     []error
+		// packaged is the archives of the charts already packaged, by their directories and versions
+		packaged = map[string]string{}
+	)
+
+	for i := range st.Releases {
+		release := &st.Releases[i]
+
+		if !st.fs.DirectoryExistsAt(normalizeChart(st.basePath, release.Chart)) {
+			continue
+		}
+
+		chartPath, err := st.renderTemplatedChart(release, release.Chart)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("release %q: %w", release.Name, err))
+			continue
+		}
+		dir := normalizeChart(st.basePath, chartPath)
+
+		name, version, err := st.packagedChartVersion(release, dir, opts.Version, opts.GitSuffix)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("release %q: %w", release.Name, err))
+			continue
+		}
+
+		key := normalizeChart(st.basePath, release.Chart) + "@" + version
+
+		archive, ok := packaged[key]
+		if !ok {
+			archive, err = st.packageChart(helm, release, dir, name, version, dest)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("release %q: packaging chart %s: %w", release.Name, release.Chart, err))
+				continue
+			}
+
+			packaged[key] = archive
+		}
+
+		packages = append(packages, ChartPackage{
+			ID:      ReleaseToID(release),
+			Release: release.Name,
+			Chart:   release.Chart,
+			Version: version,
+			Path:    archive,
+		})
+	}
+
+	return packages, errs
+}
+
+// packagedChartVersion returns the name of the chart in the directory, and the version it is packaged with,
+// which is the version given, the chartVersion of the release, the `version` of the release if it's a semantic version,
+// or the version in Chart.yaml, in this preference.
+// With gitSuffix, the short hash of the commit checked out in the directory is appended to the version, like `1.2.0-g1a2b3c4`.
+func (st *HelmState) packagedChartVersion(release *ReleaseSpec, dir, version string, gitSuffix bool) (string, string, error) {
+	bs, err := st.fs.ReadFile(filepath.Join(dir, "Chart.yaml"))
+	if err != nil {
+		return "", "", err
+	}
+
+	var chart struct {
+		Name    string `yaml:"name"`
+		Version string `yaml:"version"`
+	}
+
+	if err := yaml.Unmarshal(bs, &chart); err != nil {
+		return "", "", fmt.Errorf("parsing Chart.yaml of %s: %v", dir, err)
+	}
+
+	if version == "" {
+		version = chart.Version
+		// The chartVersion of the release is already injected into Chart.yaml
+		if _, err := semver.StrictNewVersion(release.Version); err == nil && release.ChartVersion == "" {
+			version = release.Version
+		}
+	}
+
+	if gitSuffix {
+		sha, err := st.gitShortCommit(dir)
+		if err != nil {
+			return "", "", err
+		}
+
+		sep := "-"
+		if strings.Contains(version, "-") {
+			sep = "."
+		}
+		version += sep + "g" + sha
+	}
+
+	if _, err := semver.StrictNewVersion(version); err != nil {
+		return "", "", fmt.Errorf("version %q of chart %s is not a semantic version: %v", version, chart.Name, err)
+	}
+
+	return chart.Name, version, nil
+}
+
+// gitShortCommit returns the short hash of the commit checked out in the directory
+func (st *HelmState) gitShortCommit(dir string) (string, error) {
+	runner := st.runner
+	if runner == nil {
+		runner = helmexec.ShellRunner{Logger: st.logger}
+	}
+
+	out, err := runner.Execute("git", []string{"-C", dir, "rev-parse", "--short", "HEAD"}, nil, false)
+	if err != nil {
+		return "", fmt.Errorf("getting the commit of %s: %v: %s", dir, err, out)
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
+// packageChart packages the chart named name in the directory into dest with the version, building its dependencies unless skipped,
+// and returns the path to the archive
+func (st *HelmState) packageChart(helm helmexec.Interface, release *ReleaseSpec, dir, name, version, dest string) (string, error) {
+	args := []string{"package", dir, "--version", version, "--destination", dest}
+
+	skipDeps := (release.SkipDeps != nil && *release.SkipDeps) || (release.SkipDeps == nil && st.HelmDefaults.SkipDeps)
+	if !skipDeps {
+		var err error
+		skipDeps, err = st.skipsDependencyBuild(release, dir)
+		if err != nil {
+			return "", err
+		}
+	}
+	if !skipDeps {
+		args = append(args, "--dependency-update")
+	}
+
+	buf := &bytes.Buffer{}
+	if err := helm.Exec(helmexec.HelmContext{Writer: buf}, args...); err != nil {
+		return "", err
+	}
+
+	archive := filepath.Join(dest, name+"-"+version+".tgz")
+	if _, err := os.Stat(archive); err != nil {
+		return "", fmt.Errorf("unable to find the chart packaged into %s: %s", archive, buf.String())
+	}
+
+	return archive, nil
+}
@@ -0,0 +1,195 @@
+package state
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	yamlv3 "gopkg.in/yaml.v3"
+
+	"github.com/helmfile/helmfile/pkg/helmexec"
+)
+
+// ChartPush is a local chart of a release packaged and pushed to an OCI registry by PushCharts
+type ChartPush struct {
+	ID      string `json:"id"`
+	Release string `json:"release"`
+	// Chart is the local chart as referenced by the release
+	Chart   string `json:"chart"`
+	Version string `json:"version"`
+	// Ref is the pushed chart, like `oci://registry.example.com/charts/app`
+	Ref string `json:"ref"`
+	// Rewritten is true when the release in the state file is rewritten to the pushed chart
+	Rewritten bool `json:"rewritten"`
+
+	// namespace is the namespace of the release, to find it in the state file along with its name
+	namespace string
+}
+
+// PushChartsOpts is the options of PushCharts
+type PushChartsOpts struct {
+	// Registry is the OCI registry the charts are pushed to, like `oci://registry.example.com/charts`
+	Registry string
+	// Version overrides the versions of the charts
+	Version string
+	// GitSuffix appends the short commit hash of the chart directory to the versions
+	GitSuffix bool
+	// Rewrite rewrites the releases in the state file to install the pushed charts
+	Rewrite bool
+	// DryRun only prints the charts to be pushed
+	DryRun bool
+}
+
+// PushCharts packages the local charts of the releases, and pushes them to the OCI registry.
+// Each chart is pushed once with the version given by packagedChartVersion.
+// With `opts.Rewrite`, the releases in the state file are rewritten to the pushed charts and versions.
+func (st *HelmState) PushCharts(helm helmexec.Interface, opts PushChartsOpts) ([]ChartPush, []error) {
+	registry := strings.TrimSuffix(opts.Registry, "/")
+	if !strings.HasPrefix(registry, "oci://") {
+		return nil, []error{fmt.Errorf("registry %q must start with oci://", opts.Registry)}
+	}
+
+	dest, err := st.MkdirTemp("helmfile-push-")
+	if err != nil {
+		return nil, []error{err}
+	}
+	defer os.RemoveAll(dest)
+
+	var (
+		pushes []ChartPush
+		errs   []error
+		// pushed is the refs of the charts already pushed, by their directories and versions
+		pushed = map[string]string{}
+	)
+
+	for i := range st.Releases {
+		release := &st.Releases[i]
+
+		if !st.fs.DirectoryExistsAt(normalizeChart(st.basePath, release.Chart)) {
+			continue
+		}
+
+		chartPath, err := st.renderTemplatedChart(release, release.Chart)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("release %q: %w", release.Name, err))
+			continue
+		}
+		dir := normalizeChart(st.basePath, chartPath)
+
+		name, version, err := st.packagedChartVersion(release, dir, opts.Version, opts.GitSuffix)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("release %q: %w", release.Name, err))
+			continue
+		}
+
+		key := normalizeChart(st.basePath, release.Chart) + "@" + version
+
+		ref, ok := pushed[key]
+		if !ok {
+			ref = registry + "/" + name
+
+			if !opts.DryRun {
+				if err := st.pushChart(helm, release, dir, name, version, registry, dest); err != nil {
+					errs = append(errs, fmt.Errorf("release %q: pushing chart %s: %w", release.Name, release.Chart, err))
+					continue
+				}
+			}
+
+			pushed[key] = ref
+		}
+
+		pushes = append(pushes, ChartPush{
+			ID:      ReleaseToID(release),
+			Release: release.Name,
+			Chart:   release.Chart,
+			Version: version,
+			Ref:     ref,
+
+			namespace: release.Namespace,
+		})
+	}
+
+	if len(errs) > 0 {
+		return pushes, errs
+	}
+
+	if opts.Rewrite {
+		if err := st.rewritePushedCharts(pushes, opts.DryRun); err != nil {
+			return pushes, []error{err}
+		}
+	}
+
+	return pushes, nil
+}
+
+// pushChart packages the chart in the directory into dest with the version, and pushes it to the registry
+func (st *HelmState) pushChart(helm helmexec.Interface, release *ReleaseSpec, dir, name, version, registry, dest string) error {
+	archive, err := st.packageChart(helm, release, dir, name, version, dest)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(archive)
+
+	pushArgs := []string{"push", archive, registry}
+	if release.PlainHTTP != nil && *release.PlainHTTP {
+		pushArgs = append(pushArgs, "--plain-http")
+	}
+	if release.InsecureSkipTLSVerify != nil && *release.InsecureSkipTLSVerify {
+		pushArgs = append(pushArgs, "--insecure-skip-tls-verify")
+	}
+
+	return helm.Exec(helmexec.HelmContext{}, pushArgs...)
+}
+
+// rewritePushedCharts rewrites the charts and the versions of the releases in the state file to the pushed ones,
+// marking the pushes found in the file as rewritten
+func (st *HelmState) rewritePushedCharts(pushes []ChartPush, dryRun bool) error {
+	content, err := st.fs.ReadFile(st.FilePath)
+	if err != nil {
+		return err
+	}
+
+	editor, err := newStateFileEditor(content)
+	if err != nil {
+		st.logger.Warnf("unable to parse %s to rewrite the charts: %v", st.FilePath, err)
+		editor = &stateFileEditor{content: content}
+	}
+
+	var updated bool
+
+	for i, p := range pushes {
+		if !rewriteReleaseChart(editor, editor.release(p.Release, p.namespace), p.Chart, p.Ref, p.Version) {
+			st.logger.Warnf("unable to find the chart %s of release %s in %s. Update it to %s version %s manually", p.Chart, p.Release, st.FilePath, p.Ref, p.Version)
+			continue
+		}
+
+		pushes[i].Rewritten = true
+		updated = true
+	}
+
+	if dryRun || !updated {
+		return nil
+	}
+
+	return os.WriteFile(st.FilePath, editor.bytes(), 0644)
+}
+
+// rewriteReleaseChart replaces the chart `from` of the release with `to`, and sets its version, adding it when missing.
+// It returns false and leaves the release as is when the chart isn't `from`, or either of them can't be edited, like when it is a template.
+func rewriteReleaseChart(e *stateFileEditor, release *yamlv3.Node, from, to, version string) bool {
+	edits := len(e.edits)
+
+	if e.set(release, "chart", from, to) {
+		if _, v := yamlMappingEntry(release, "version"); v != nil {
+			if e.set(release, "version", v.Value, version) {
+				return true
+			}
+		} else if e.insertAfter(release, "chart", "version", version) {
+			return true
+		}
+	}
+
+	e.edits = e.edits[:edits]
+
+	return false
+}
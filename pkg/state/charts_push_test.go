@@ -0,0 +1,100 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/exectest"
+	"github.com/helmfile/helmfile/pkg/filesystem"
+)
+
+func TestRewriteReleaseChart(t *testing.T) {
+	content := `releases:
+- name: web
+  chart: ./charts/web # the frontend
+  values:
+  - version: 1
+- chart: "./charts/api"
+  name: api
+  version: 0.1.0
+- name: api
+  namespace: staging
+  chart: ./charts/api
+- name: db
+  chart: bitnami/postgresql
+`
+
+	e, err := newStateFileEditor([]byte(content))
+	require.NoError(t, err)
+
+	require.True(t, rewriteReleaseChart(e, e.release("web", ""), "./charts/web", "oci://registry.example.com/charts/web", "1.2.0"))
+	require.True(t, rewriteReleaseChart(e, e.release("api", "staging"), "./charts/api", "oci://registry.example.com/charts/api", "0.2.0-g1a2b3c4"))
+	require.False(t, rewriteReleaseChart(e, e.release("db", ""), "./charts/db", "oci://registry.example.com/charts/db", "1.0.0"))
+
+	require.Equal(t, `releases:
+- name: web
+  chart: oci://registry.example.com/charts/web # the frontend
+  version: 1.2.0
+  values:
+  - version: 1
+- chart: "./charts/api"
+  name: api
+  version: 0.1.0
+- name: api
+  namespace: staging
+  chart: oci://registry.example.com/charts/api
+  version: 0.2.0-g1a2b3c4
+- name: db
+  chart: bitnami/postgresql
+`, string(e.bytes()))
+}
+
+func TestHelmState_PushCharts(t *testing.T) {
+	basePath := t.TempDir()
+
+	for name, version := range map[string]string{"web": "1.0.0", "api": "0.1.0"} {
+		dir := filepath.Join(basePath, "charts", name)
+		require.NoError(t, os.MkdirAll(dir, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "Chart.yaml"), []byte("apiVersion: v2\nname: "+name+"\nversion: "+version+"\n"), 0644))
+	}
+
+	r := &exectest.Runner{
+		Outputs: map[string]string{
+			"git -C " + filepath.Join(basePath, "charts/web") + " rev-parse --short HEAD": "1a2b3c4\n",
+			"git -C " + filepath.Join(basePath, "charts/api") + " rev-parse --short HEAD": "5d6e7f8\n",
+		},
+	}
+
+	st := &HelmState{
+		basePath: basePath,
+		fs:       filesystem.DefaultFileSystem(),
+		logger:   logger,
+		runner:   r,
+		ReleaseSetSpec: ReleaseSetSpec{
+			Releases: []ReleaseSpec{
+				{Name: "web", Namespace: "web", Chart: "./charts/web"},
+				{Name: "web-canary", Namespace: "web", Chart: "./charts/web"},
+				{Name: "api", Chart: "./charts/api", Version: "0.2.0"},
+				{Name: "db", Chart: "bitnami/postgresql"},
+			},
+		},
+	}
+
+	helm := &exectest.Helm{}
+
+	pushes, errs := st.PushCharts(helm, PushChartsOpts{Registry: "oci://registry.example.com/charts/", GitSuffix: true, DryRun: true})
+	require.Empty(t, errs)
+
+	require.Equal(t, []ChartPush{
+		{ID: "web/web", Release: "web", Chart: "./charts/web", Version: "1.0.0-g1a2b3c4", Ref: "oci://registry.example.com/charts/web", namespace: "web"},
+		{ID: "web/web-canary", Release: "web-canary", Chart: "./charts/web", Version: "1.0.0-g1a2b3c4", Ref: "oci://registry.example.com/charts/web", namespace: "web"},
+		{ID: "api", Release: "api", Chart: "./charts/api", Version: "0.2.0-g5d6e7f8", Ref: "oci://registry.example.com/charts/api"},
+	}, pushes)
+	require.Empty(t, helm.Execs)
+
+	_, errs = st.PushCharts(helm, PushChartsOpts{Registry: "registry.example.com/charts"})
+	require.EqualError(t, errs[0], `registry "registry.example.com/charts" must start with oci://`)
+}
@@ -0,0 +1,33 @@
+package state
+
+import (
+	"fmt"
+	"time"
+)
+
+// commandTimeout returns the timeout of the helm commands of the release, which is the commandTimeout of the release or helmDefaults,
+// or zero when neither is set
+func (st *HelmState) commandTimeout(release *ReleaseSpec) (time.Duration, error) {
+	s := release.CommandTimeout
+	if s == "" {
+		s = st.HelmDefaults.CommandTimeout
+	}
+	if s == "" {
+		return 0, nil
+	}
+
+	timeout, err := time.ParseDuration(s)
+	if err != nil || timeout < 0 {
+		return 0, fmt.Errorf("invalid commandTimeout %q of release %s: it must be a duration like 10m", s, release.Name)
+	}
+
+	return timeout, nil
+}
+
+// liveOutput returns whether to stream the outputs of the helm commands of the release, or nil when it isn't set for the release
+func (st *HelmState) liveOutput(release *ReleaseSpec) *bool {
+	if release.LiveOutput != nil {
+		return release.LiveOutput
+	}
+	return st.HelmDefaults.LiveOutput
+}
@@ -0,0 +1,128 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/helmfile/helmfile/pkg/envvar"
+	"github.com/helmfile/helmfile/pkg/helmexec"
+)
+
+// DefaultCosignBinary is the cosign binary used to verify chart signatures unless HELMFILE_COSIGN_BINARY is set
+const DefaultCosignBinary = "cosign"
+
+// CosignSpec is the policy to verify the keyless cosign signature of OCI charts,
+// that is the signature made with a short-lived certificate issued by Fulcio and recorded in the Rekor transparency log.
+// A chart is pulled only when it is signed by the identity issued by the issuer.
+type CosignSpec struct {
+	// Identity is the identity expected in the signing certificate, like the email or the workflow URL
+	Identity string `yaml:"identity,omitempty"`
+	// IdentityRegexp is the regular expression matching the identity, used instead of Identity
+	IdentityRegexp string `yaml:"identityRegexp,omitempty"`
+	// Issuer is the OIDC issuer expected in the signing certificate, like `https://token.actions.githubusercontent.com`
+	Issuer string `yaml:"issuer,omitempty"`
+	// IssuerRegexp is the regular expression matching the OIDC issuer, used instead of Issuer
+	IssuerRegexp string `yaml:"issuerRegexp,omitempty"`
+	// RekorURL is the URL of the Rekor transparency log. Defaults to the public instance.
+	RekorURL string `yaml:"rekorURL,omitempty"`
+}
+
+func (c CosignSpec) validate() error {
+	if c.Identity == "" && c.IdentityRegexp == "" {
+		return errors.New("either identity or identityRegexp is required for keyless verification")
+	}
+	if c.Issuer == "" && c.IssuerRegexp == "" {
+		return errors.New("either issuer or issuerRegexp is required for keyless verification")
+	}
+	return nil
+}
+
+func (c CosignSpec) verifyArgs(ref string, reg *RegistrySpec) []string {
+	args := []string{"verify"}
+	if c.Identity != "" {
+		args = append(args, "--certificate-identity", c.Identity)
+	}
+	if c.IdentityRegexp != "" {
+		args = append(args, "--certificate-identity-regexp", c.IdentityRegexp)
+	}
+	if c.Issuer != "" {
+		args = append(args, "--certificate-oidc-issuer", c.Issuer)
+	}
+	if c.IssuerRegexp != "" {
+		args = append(args, "--certificate-oidc-issuer-regexp", c.IssuerRegexp)
+	}
+	if c.RekorURL != "" {
+		args = append(args, "--rekor-url", c.RekorURL)
+	}
+	if reg != nil {
+		if reg.InsecureSkipTLSVerify {
+			args = append(args, "--allow-insecure-registry")
+		}
+		if reg.PlainHTTP {
+			args = append(args, "--allow-http-registry")
+		}
+	}
+	return append(args, ref)
+}
+
+// getCosignPolicy returns the policy the OCI chart reference of the release is verified against, or nil if it isn't verified.
+// The reference is the one before the mirrors are applied, as the chart pulled through any mirror must satisfy the policy of its origin.
+// The policy of the release takes precedence over the one of the registry.
+func (st *HelmState) getCosignPolicy(release *ReleaseSpec, ref string) *CosignSpec {
+	if release.Cosign != nil {
+		return release.Cosign
+	}
+	if reg := st.getRegistry(ref); reg != nil {
+		return reg.Cosign
+	}
+	return nil
+}
+
+// pinOCIRef returns the OCI chart reference pinned by the digest of the manifest it points to, like `registry/app@sha256:...`,
+// or the reference itself when it's already pinned
+func pinOCIRef(ctx context.Context, helm helmexec.ChartDigester, ref string, flags []string) (string, error) {
+	if _, digest := splitChartDigest(ref); digest != "" {
+		return ref, nil
+	}
+
+	digest, err := helm.ChartDigest(ctx, ref, flags...)
+	if err != nil {
+		return "", err
+	}
+
+	repo := ref
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		repo = ref[:i]
+	}
+
+	return repo + "@" + digest, nil
+}
+
+// verifyOCIChart verifies the signature of the OCI chart reference like `registry/app:1.0.0` with cosign,
+// so that an unsigned or mis-signed chart is never installed.
+func (st *HelmState) verifyOCIChart(policy *CosignSpec, ref string) error {
+	if err := policy.validate(); err != nil {
+		return fmt.Errorf("invalid cosign policy for %s: %v", ref, err)
+	}
+
+	bin := os.Getenv(envvar.CosignBinary)
+	if bin == "" {
+		bin = DefaultCosignBinary
+	}
+
+	runner := st.runner
+	if runner == nil {
+		runner = helmexec.ShellRunner{Logger: st.logger}
+	}
+
+	st.logger.Infof("Verifying the signature of %s", ref)
+
+	if out, err := runner.Execute(bin, policy.verifyArgs(ref, st.getRegistry(ref)), map[string]string{}, false); err != nil {
+		return fmt.Errorf("verifying the signature of %s: %v: %s", ref, err, out)
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package state
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/envvar"
+	"github.com/helmfile/helmfile/pkg/exectest"
+)
+
+func TestCosignSpec_verifyArgs(t *testing.T) {
+	c := CosignSpec{
+		IdentityRegexp: "^https://github.com/myorg/",
+		Issuer:         "https://token.actions.githubusercontent.com",
+		RekorURL:       "https://rekor.example.com",
+	}
+
+	require.Equal(t, []string{
+		"verify",
+		"--certificate-identity-regexp", "^https://github.com/myorg/",
+		"--certificate-oidc-issuer", "https://token.actions.githubusercontent.com",
+		"--rekor-url", "https://rekor.example.com",
+		"--allow-http-registry",
+		"registry/app:1.0.0",
+	}, c.verifyArgs("registry/app:1.0.0", &RegistrySpec{PlainHTTP: true}))
+}
+
+func TestCosignSpec_validate(t *testing.T) {
+	require.EqualError(t, CosignSpec{Issuer: "https://issuer"}.validate(), "either identity or identityRegexp is required for keyless verification")
+	require.EqualError(t, CosignSpec{Identity: "me@example.com"}.validate(), "either issuer or issuerRegexp is required for keyless verification")
+	require.NoError(t, CosignSpec{Identity: "me@example.com", IssuerRegexp: ".*"}.validate())
+}
+
+func TestGetOCIChart_Cosign(t *testing.T) {
+	registryPolicy := &CosignSpec{Identity: "ci@example.com", Issuer: "https://issuer"}
+	releasePolicy := &CosignSpec{Identity: "release@example.com", Issuer: "https://issuer"}
+
+	newState := func(r *exectest.Runner) *HelmState {
+		return &HelmState{
+			ReleaseSetSpec: ReleaseSetSpec{
+				Registries: []RegistrySpec{
+					{
+						Host:   "registry.example.com",
+						Cosign: registryPolicy,
+					},
+				},
+			},
+			logger: logger,
+			runner: r,
+		}
+	}
+
+	t.Run("policy of the release takes precedence", func(t *testing.T) {
+		st := newState(nil)
+
+		require.Equal(t, registryPolicy, st.getCosignPolicy(&ReleaseSpec{}, "registry.example.com/app:1.0.0"))
+		require.Equal(t, releasePolicy, st.getCosignPolicy(&ReleaseSpec{Cosign: releasePolicy}, "registry.example.com/app:1.0.0"))
+		require.Nil(t, st.getCosignPolicy(&ReleaseSpec{}, "other.example.com/app:1.0.0"))
+	})
+
+	t.Run("unsigned chart is never pulled", func(t *testing.T) {
+		t.Setenv(envvar.CosignBinary, "")
+
+		verify := "cosign verify --certificate-identity ci@example.com --certificate-oidc-issuer https://issuer registry.example.com/app@sha256:0123"
+		r := &exectest.Runner{
+			Outputs:  map[string]string{verify: "no matching signatures"},
+			Failures: map[string]bool{verify: true},
+		}
+		st := newState(r)
+
+		release := &ReleaseSpec{
+			Name:    "app",
+			Chart:   "oci://registry.example.com/app",
+			Version: "1.0.0",
+		}
+
+		helm := &exectest.Helm{Digests: map[string]string{"registry.example.com/app:1.0.0": "sha256:0123"}}
+
+		_, err := st.getOCIChart(release, t.TempDir(), "", helm)
+		require.EqualError(t, err, "verifying the signature of registry.example.com/app@sha256:0123: exit status 1: no matching signatures")
+		require.Equal(t, []string{verify}, r.Calls)
+	})
+
+	t.Run("chart pulled through the mirror is verified against the policy of the origin", func(t *testing.T) {
+		t.Setenv(envvar.CosignBinary, "")
+
+		r := &exectest.Runner{}
+		st := newState(r)
+		st.Registries[0].Mirrors = []string{"mirror.example.com"}
+
+		release := &ReleaseSpec{
+			Name:    "app",
+			Chart:   "oci://registry.example.com/app",
+			Version: "1.0.0",
+		}
+
+		helm := &pullingHelm{Helm: &exectest.Helm{Digests: map[string]string{"mirror.example.com/app:1.0.0": "sha256:0123"}}}
+
+		_, err := st.getOCIChart(release, t.TempDir(), "", helm)
+		require.NoError(t, err)
+		require.Equal(t, []string{
+			"cosign verify --certificate-identity ci@example.com --certificate-oidc-issuer https://issuer mirror.example.com/app@sha256:0123",
+		}, r.Calls)
+		// The chart verified is pulled by the digest, not by the tag
+		require.Equal(t, []string{"mirror.example.com/app@sha256:0123"}, helm.pulled)
+	})
+}
+
+func TestPinOCIRef(t *testing.T) {
+	helm := &exectest.Helm{Digests: map[string]string{"registry:443/app:1.0.0": "sha256:0123"}}
+
+	ref, err := pinOCIRef(context.Background(), helm, "registry:443/app:1.0.0", nil)
+	require.NoError(t, err)
+	require.Equal(t, "registry:443/app@sha256:0123", ref)
+
+	ref, err = pinOCIRef(context.Background(), helm, "registry:443/app@sha256:4567", nil)
+	require.NoError(t, err)
+	require.Equal(t, "registry:443/app@sha256:4567", ref)
+}
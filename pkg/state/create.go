@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/helmfile/vals"
 	"github.com/imdario/mergo"
@@ -14,6 +15,7 @@ import (
 	"github.com/helmfile/helmfile/pkg/helmexec"
 	"github.com/helmfile/helmfile/pkg/maputil"
 	"github.com/helmfile/helmfile/pkg/remote"
+	"github.com/helmfile/helmfile/pkg/tmpl"
 	"github.com/helmfile/helmfile/pkg/yaml"
 )
 
@@ -58,6 +60,14 @@ type StateCreator struct {
 	remote *remote.Remote
 
 	lockFile string
+
+	// Namespace and KubeContext are the ones specified on the command line, if any.
+	// They are exposed as `.Namespace` and `.KubeContext` to the environment values templates.
+	Namespace   string
+	KubeContext string
+
+	// ClusterLookups is whether the templates of the states are allowed to query the cluster with `kubectlGet`
+	ClusterLookups tmpl.ClusterLookups
 }
 
 func NewCreator(logger *zap.SugaredLogger, fs *filesystem.FileSystem, valsRuntime vals.Evaluator, getHelm func(*HelmState) helmexec.Interface, overrideHelmBinary string, remote *remote.Remote, enableLiveOutput bool, lockFile string) *StateCreator {
@@ -102,6 +112,11 @@ func (c *StateCreator) Parse(content []byte, baseDir, file string) (*HelmState,
 		if err == io.EOF {
 			break
 		} else if err != nil {
+			if c.Strict {
+				if unknown := unknownFieldsOfDocument(content, i); len(unknown) > 0 {
+					err = fmt.Errorf("unknown fields %s: %w", strings.Join(unknown, ", "), err)
+				}
+			}
 			return nil, &StateLoadError{fmt.Sprintf("failed to read %s: reading document at index %d", file, i), err}
 		}
 
@@ -133,6 +148,7 @@ func (c *StateCreator) Parse(content []byte, baseDir, file string) (*HelmState,
 
 	state.logger = c.logger
 	state.valsRuntime = c.valsRuntime
+	state.ClusterLookups = c.ClusterLookups
 
 	return &state, nil
 }
@@ -146,7 +162,7 @@ func (c *StateCreator) LoadEnvValues(target *HelmState, env string, ctxEnv *envi
 		return nil, &StateLoadError{fmt.Sprintf("failed to read %s", state.FilePath), err}
 	}
 
-	newDefaults, err := state.loadValuesEntries(nil, state.DefaultValues, c.remote, ctxEnv, env)
+	newDefaults, err := c.loadValuesEntries(&state, nil, state.DefaultValues, ctxEnv, env)
 	if err != nil {
 		return nil, err
 	}
@@ -157,6 +173,21 @@ func (c *StateCreator) LoadEnvValues(target *HelmState, env string, ctxEnv *envi
 
 	state.Env = *e
 
+	if err := state.applyEnvironmentHelmDefaults(env); err != nil {
+		return nil, &StateLoadError{fmt.Sprintf("failed to read %s", state.FilePath), err}
+	}
+
+	if err := state.applyCapabilitiesFile(env); err != nil {
+		return nil, &StateLoadError{fmt.Sprintf("failed to read %s", state.FilePath), err}
+	}
+
+	// The preliminary load of the first pass isn't strict, as the values may be incomplete until the second pass
+	if c.Strict {
+		if err := state.validateEnvironmentValues(env); err != nil {
+			return nil, &StateLoadError{fmt.Sprintf("failed to read %s", state.FilePath), err}
+		}
+	}
+
 	return &state, nil
 }
 
@@ -179,6 +210,10 @@ func (c *StateCreator) ParseAndLoad(content []byte, baseDir, file string, envNam
 		}
 	}
 
+	if err := state.applySecretsBackends(); err != nil {
+		return nil, err
+	}
+
 	state, err = c.LoadEnvValues(state, envName, envValues, evaluateBases)
 	if err != nil {
 		return nil, err
@@ -221,7 +256,7 @@ func (c *StateCreator) loadEnvValues(st *HelmState, name string, failOnMissingEn
 	envSpec, ok := st.Environments[name]
 	if ok {
 		var err error
-		envVals, err = st.loadValuesEntries(envSpec.MissingFileHandler, envSpec.Values, c.remote, ctxEnv, name)
+		envVals, err = c.loadValuesEntries(st, envSpec.MissingFileHandler, envSpec.Values, ctxEnv, name)
 		if err != nil {
 			return nil, err
 		}
@@ -239,9 +274,22 @@ func (c *StateCreator) loadEnvValues(st *HelmState, name string, failOnMissingEn
 
 				envSecretFiles = append(envSecretFiles, resolved...)
 			}
-			if err = c.scatterGatherEnvSecretFiles(st, envSecretFiles, envVals); err != nil {
+
+			secretVals := envVals
+			if envSpec.ValuesOverrideSecrets {
+				secretVals = map[string]interface{}{}
+			}
+
+			if err = c.scatterGatherEnvSecretFiles(st, envSecretFiles, secretVals); err != nil {
 				return nil, err
 			}
+
+			if envSpec.ValuesOverrideSecrets {
+				if err := mergo.Merge(&secretVals, envVals, mergo.WithOverride, mergo.WithOverwriteWithEmptyValue); err != nil {
+					return nil, fmt.Errorf("error while merging environment values over secrets for \"%s\": %v", name, err)
+				}
+				envVals = secretVals
+			}
 		}
 	} else if ctxEnv == nil && name != DefaultEnv && failOnMissingEnv {
 		return nil, &UndefinedEnvError{msg: fmt.Sprintf("environment \"%s\" is not defined", name)}
@@ -299,6 +347,16 @@ func (c *StateCreator) scatterGatherEnvSecretFiles(st *HelmState, envSecretFiles
 					urlOrPath = localPath
 				}
 
+				// The files encrypted by sops are decrypted in-process, without the helm-secrets plugin
+				if isSOPSFile(secret.path) {
+					vals, err := st.sopsDecrypter().Decrypt(urlOrPath)
+					if err != nil {
+						err = fmt.Errorf("failed to decrypt environment secrets file \"%s\": %v", secret.path, err)
+					}
+					results <- secretResult{secret.id, vals, err, secret.path}
+					continue
+				}
+
 				release := &ReleaseSpec{}
 				flags := st.appendConnectionFlags([]string{}, release)
 				decFile, err := helm.DecryptSecret(st.createHelmContext(release, 0), urlOrPath, flags...)
@@ -365,11 +423,28 @@ func (c *StateCreator) scatterGatherEnvSecretFiles(st *HelmState, envSecretFiles
 	return nil
 }
 
-func (st *HelmState) loadValuesEntries(missingFileHandler *string, entries []interface{}, remote *remote.Remote, ctxEnv *environment.Environment, envName string) (map[string]interface{}, error) {
+func (c *StateCreator) loadValuesEntries(st *HelmState, missingFileHandler *string, entries []interface{}, ctxEnv *environment.Environment, envName string) (map[string]interface{}, error) {
 	var envVals map[string]interface{}
 
 	valuesEntries := append([]interface{}{}, entries...)
-	ld := NewEnvironmentValuesLoader(st.storage(), st.fs, st.logger, remote)
+	ld := NewEnvironmentValuesLoader(st.storage(), st.fs, st.logger, c.remote)
+	ld.sops = st.sopsDecrypter()
+	if st.secretsBackendsEnv() != nil {
+		ld.secrets = st.valsRuntime
+	}
+	ld.Namespace = c.Namespace
+	if ld.Namespace == "" {
+		ld.Namespace = st.OverrideNamespace
+	}
+	ld.KubeContext = c.KubeContext
+	if ld.KubeContext == "" {
+		ld.KubeContext = st.OverrideKubeContext
+	}
+	if ld.KubeContext == "" {
+		ld.KubeContext = st.HelmDefaults.KubeContext
+	}
+	ld.HelmBinary = st.DefaultHelmBinary
+	ld.ClusterLookups = st.ClusterLookups
 	var err error
 	envVals, err = ld.LoadEnvironmentValues(missingFileHandler, valuesEntries, ctxEnv, envName)
 	if err != nil {
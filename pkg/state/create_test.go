@@ -9,7 +9,9 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/helmfile/helmfile/pkg/environment"
+	"github.com/helmfile/helmfile/pkg/exectest"
 	"github.com/helmfile/helmfile/pkg/filesystem"
+	"github.com/helmfile/helmfile/pkg/helmexec"
 	"github.com/helmfile/helmfile/pkg/remote"
 	"github.com/helmfile/helmfile/pkg/testhelper"
 )
@@ -266,6 +268,214 @@ overrideNamespace: myns
 	}
 }
 
+func TestReadFromYaml_EnvironmentHelmDefaults(t *testing.T) {
+	yamlFile := "example/path/to/yaml/file"
+	yamlContent := []byte(`environments:
+  default:
+  production:
+    helmDefaults:
+      wait: true
+      atomic: true
+      timeout: 900
+  dev:
+    helmDefaults:
+      verify: false
+
+helmDefaults:
+  verify: true
+  timeout: 300
+  kubeContext: main
+
+releases:
+- name: myrelease
+  chart: mychart
+`)
+
+	prod, err := createFromYaml(yamlContent, yamlFile, "production", logger)
+	require.NoError(t, err)
+	require.Equal(t, HelmSpec{KubeContext: "main", Verify: true, Wait: true, Atomic: true, Timeout: 900}, prod.HelmDefaults)
+
+	dev, err := createFromYaml(yamlContent, yamlFile, "dev", logger)
+	require.NoError(t, err)
+	require.Equal(t, HelmSpec{KubeContext: "main", Timeout: 300}, dev.HelmDefaults)
+
+	def, err := createFromYaml(yamlContent, yamlFile, DefaultEnv, logger)
+	require.NoError(t, err)
+	require.Equal(t, HelmSpec{KubeContext: "main", Verify: true, Timeout: 300}, def.HelmDefaults)
+
+	_, err = createFromYaml([]byte(`environments:
+  production:
+    helmDefaults:
+      wiat: true
+`), yamlFile, "production", logger)
+	require.ErrorContains(t, err, `invalid helmDefaults in environment "production"`)
+}
+
+func TestReadFromYaml_CapabilitiesFile(t *testing.T) {
+	testEnv := stateTestEnv{
+		Files: map[string]string{
+			"/example/path/to/helmfile.yaml": `environments:
+  default:
+  production:
+capabilitiesFile: capabilities.yaml
+releases:
+- name: myrelease
+  chart: mychart
+`,
+			"/example/path/to/pinned.yaml": `environments:
+  production:
+capabilitiesFile: capabilities.yaml
+kubeVersion: v1.25.0
+releases:
+- name: myrelease
+  chart: mychart
+`,
+			"/example/path/to/capabilities.yaml": `apiVersions:
+- monitoring.coreos.com/v1
+kubeVersion: v1.27.3
+environments:
+  production:
+    kubeVersion: v1.26.5
+`,
+		},
+	}
+
+	def := testEnv.MustLoadState(t, "/example/path/to/helmfile.yaml", DefaultEnv)
+	require.Equal(t, []string{"monitoring.coreos.com/v1"}, def.ApiVersions)
+	require.Equal(t, "v1.27.3", def.KubeVersion)
+
+	prod := testEnv.MustLoadState(t, "/example/path/to/helmfile.yaml", "production")
+	require.Equal(t, []string{"monitoring.coreos.com/v1"}, prod.ApiVersions)
+	require.Equal(t, "v1.26.5", prod.KubeVersion)
+
+	pinned := testEnv.MustLoadState(t, "/example/path/to/pinned.yaml", "production")
+	require.Equal(t, "v1.25.0", pinned.KubeVersion)
+}
+
+func TestReadFromYaml_EnvironmentValuesSchema(t *testing.T) {
+	files := map[string]string{
+		"/example/path/to/helmfile.yaml": `environments:
+  default:
+    values:
+    - domain: example.com
+      replicas: 2
+    valuesSchema: schema.json
+  production:
+    values:
+    - domian: example.com
+      replicas: "2"
+    valuesSchema: schema.json
+releases:
+- name: myrelease
+  chart: mychart
+`,
+		"/example/path/to/schema.json": `{
+  "type": "object",
+  "required": ["domain"],
+  "properties": {
+    "domain": {"type": "string"},
+    "replicas": {"type": "integer"}
+  }
+}`,
+	}
+
+	def := stateTestEnv{Files: files}.MustLoadState(t, "/example/path/to/helmfile.yaml", DefaultEnv)
+	require.Equal(t, "example.com", def.Env.Values["domain"])
+
+	testFs := testhelper.NewTestFs(files)
+	r := remote.NewRemote(logger, "/", testFs.ToFileSystem())
+	_, err := NewCreator(logger, testFs.ToFileSystem(), nil, nil, "", r, false, "").
+		ParseAndLoad([]byte(files["/example/path/to/helmfile.yaml"]), "/example/path/to", "/example/path/to/helmfile.yaml", "production", true, nil)
+	require.ErrorContains(t, err, `values of environment "production" don't match the schema schema.json`)
+	require.ErrorContains(t, err, "domain is required")
+	require.ErrorContains(t, err, "replicas: Invalid type. Expected: integer, given: string")
+}
+
+// decryptingHelm decrypts the secrets files by returning the paths to their decrypted contents, suffixed with `.dec`
+type decryptingHelm struct {
+	exectest.Helm
+}
+
+func (h *decryptingHelm) DecryptSecret(context helmexec.HelmContext, name string, flags ...string) (string, error) {
+	return name + ".dec", nil
+}
+
+func TestReadFromYaml_EnvironmentSecrets(t *testing.T) {
+	files := map[string]string{
+		"/example/path/to/helmfile.yaml": `environments:
+  default:
+    values:
+    - values.yaml
+    secrets:
+    - secrets.yaml
+  production:
+    values:
+    - values.yaml
+    secrets:
+    - secrets.yaml
+    valuesOverrideSecrets: true
+`,
+		"/example/path/to/values.yaml":      "db:\n  host: db.local\n  password: dummy\n",
+		"/example/path/to/secrets.yaml":     "ENC[...]",
+		"/example/path/to/secrets.yaml.dec": "db:\n  password: secret\n  user: admin\n",
+	}
+
+	load := func(env string) map[string]interface{} {
+		t.Helper()
+
+		testFs := testhelper.NewTestFs(files)
+		testFs.Cwd = "/example/path/to"
+		r := remote.NewRemote(logger, testFs.Cwd, testFs.ToFileSystem())
+		helm := &decryptingHelm{}
+
+		st, err := NewCreator(logger, testFs.ToFileSystem(), nil, func(*HelmState) helmexec.Interface { return helm }, "", r, false, "").
+			ParseAndLoad([]byte(files["/example/path/to/helmfile.yaml"]), "/example/path/to", "/example/path/to/helmfile.yaml", env, true, nil)
+		require.NoError(t, err)
+
+		return st.Env.Values
+	}
+
+	require.Equal(t, map[string]interface{}{
+		"db": map[string]interface{}{"host": "db.local", "password": "secret", "user": "admin"},
+	}, load(DefaultEnv))
+
+	require.Equal(t, map[string]interface{}{
+		"db": map[string]interface{}{"host": "db.local", "password": "dummy", "user": "admin"},
+	}, load("production"))
+}
+
+func TestScatterGatherEnvSecretFiles_SOPS(t *testing.T) {
+	files := map[string]string{
+		"/example/path/to/secrets.yaml":      "ENC[...]",
+		"/example/path/to/secrets.yaml.dec":  "db:\n  password: secret\n  user: admin\n",
+		"/example/path/to/secrets.sops.yaml": "ENC[...]",
+	}
+
+	testFs := testhelper.NewTestFs(files)
+	testFs.Cwd = "/example/path/to"
+	r := remote.NewRemote(logger, testFs.Cwd, testFs.ToFileSystem())
+
+	st := &HelmState{
+		basePath: "/example/path/to",
+		logger:   logger,
+		fs:       testFs.ToFileSystem(),
+		secretsDecrypters: map[string]SecretsDecrypter{
+			SecretsBackendSOPS: &fakeDecrypter{
+				files:   true,
+				secrets: map[string]map[string]interface{}{"secrets.sops.yaml": {"db": map[string]interface{}{"user": "sops"}}},
+			},
+		},
+	}
+
+	c := NewCreator(logger, testFs.ToFileSystem(), nil, func(*HelmState) helmexec.Interface { return &decryptingHelm{} }, "", r, false, "")
+
+	vals := map[string]interface{}{}
+	require.NoError(t, c.scatterGatherEnvSecretFiles(st, []string{"/example/path/to/secrets.yaml", "/example/path/to/secrets.sops.yaml"}, vals))
+	require.Equal(t, map[string]interface{}{
+		"db": map[string]interface{}{"password": "secret", "user": "sops"},
+	}, vals)
+}
+
 func TestReadFromYaml_StrictUnmarshalling(t *testing.T) {
 	yamlFile := "example/path/to/yaml/file"
 	yamlContent := []byte(`releases:
@@ -279,6 +489,27 @@ func TestReadFromYaml_StrictUnmarshalling(t *testing.T) {
 	}
 }
 
+func TestReadFromYaml_UnknownFields(t *testing.T) {
+	yamlFile := "example/path/to/yaml/file"
+	yamlContent := []byte(`helmDefaults:
+  tiemout: 300
+releases:
+- name: myrelease
+  chart: mychart
+- name: yourrelease
+  chart: yourchart
+  kubeContxt: prod
+  needs:
+  - myrelease
+  inherit:
+  - template: default
+helmfiles:
+- path: sub.yaml
+`)
+	_, err := createFromYaml(yamlContent, yamlFile, DefaultEnv, logger)
+	require.ErrorContains(t, err, "unknown fields helmDefaults.tiemout, releases[1].kubeContxt: ")
+}
+
 // TODO: Remove this function once Helmfile v0.x
 func TestReadFromYaml_DeprecatedReleaseReferences(t *testing.T) {
 	yamlFile := "example/path/to/yaml/file"
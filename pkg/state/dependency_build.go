@@ -0,0 +1,95 @@
+package state
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/helmfile/helmfile/pkg/yaml"
+)
+
+// The strategies of running `helm dependency build` on the local charts
+const (
+	// DependencyUpdateStrategyAlways builds the dependencies of the chart every time, which is the default
+	DependencyUpdateStrategyAlways = "always"
+	// DependencyUpdateStrategyIfMissing builds the dependencies of the chart only when any of them is missing in the charts/ directory,
+	// so that the charts with the vendored dependencies skip it
+	DependencyUpdateStrategyIfMissing = "if-missing"
+	// DependencyUpdateStrategyNever never builds the dependencies of the chart, like skipDeps
+	DependencyUpdateStrategyNever = "never"
+)
+
+func (st *HelmState) dependencyUpdateStrategy(release *ReleaseSpec) string {
+	if release.DependencyUpdateStrategy != "" {
+		return release.DependencyUpdateStrategy
+	}
+	return st.HelmDefaults.DependencyUpdateStrategy
+}
+
+// skipsDependencyBuild returns true if the dependencyUpdateStrategy of the release skips building the dependencies of the local chart
+func (st *HelmState) skipsDependencyBuild(release *ReleaseSpec, chartPath string) (bool, error) {
+	switch strategy := st.dependencyUpdateStrategy(release); strategy {
+	case "", DependencyUpdateStrategyAlways:
+		return false, nil
+	case DependencyUpdateStrategyNever:
+		return true, nil
+	case DependencyUpdateStrategyIfMissing:
+		missing, err := st.missingChartDependencies(chartPath)
+		if err != nil {
+			return false, err
+		}
+		if len(missing) > 0 {
+			st.logger.Debugf("building the dependencies of chart %s of release %s, as %v are missing in the charts/ directory", chartPath, release.Name, missing)
+			return false, nil
+		}
+		return true, nil
+	default:
+		return false, fmt.Errorf("invalid dependencyUpdateStrategy %q: must be one of %s, %s, %s", strategy, DependencyUpdateStrategyAlways, DependencyUpdateStrategyIfMissing, DependencyUpdateStrategyNever)
+	}
+}
+
+// missingChartDependencies returns the names of the dependencies declared in Chart.yaml or requirements.yaml of the local chart,
+// that have neither a directory nor an archive in the charts/ directory
+func (st *HelmState) missingChartDependencies(chartPath string) ([]string, error) {
+	var missing []string
+
+	for _, f := range []string{"Chart.yaml", "requirements.yaml"} {
+		path := filepath.Join(chartPath, f)
+		if !st.fs.FileExistsAt(path) {
+			continue
+		}
+
+		bs, err := st.fs.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		var chart struct {
+			Dependencies []struct {
+				Name string `yaml:"name"`
+			} `yaml:"dependencies"`
+		}
+
+		if err := yaml.Unmarshal(bs, &chart); err != nil {
+			return nil, fmt.Errorf("parsing %s: %v", path, err)
+		}
+
+		for _, d := range chart.Dependencies {
+			dir := filepath.Join(chartPath, "charts", d.Name)
+			if st.fs.DirectoryExistsAt(dir) {
+				continue
+			}
+
+			archives, err := st.fs.Glob(dir + "-*.tgz")
+			if err != nil {
+				return nil, err
+			}
+			if len(archives) > 0 {
+				continue
+			}
+
+			missing = append(missing, d.Name)
+		}
+	}
+
+	return missing, nil
+}
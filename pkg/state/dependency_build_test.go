@@ -0,0 +1,66 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/filesystem"
+)
+
+func TestHelmState_skipsDependencyBuild(t *testing.T) {
+	basePath := t.TempDir()
+
+	writeFile := func(path, content string) {
+		t.Helper()
+		path = filepath.Join(basePath, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+
+	chartYaml := "apiVersion: v2\nname: app\nversion: 0.1.0\ndependencies:\n- name: redis\n  version: 17.0.0\n  repository: https://charts.bitnami.com/bitnami\n- name: common\n  version: 0.1.0\n  repository: file://../common\n"
+
+	writeFile("vendored/Chart.yaml", chartYaml)
+	writeFile("vendored/charts/redis-17.0.0.tgz", "")
+	writeFile("vendored/charts/common/Chart.yaml", "apiVersion: v2\nname: common\nversion: 0.1.0\n")
+	writeFile("partial/Chart.yaml", chartYaml)
+	writeFile("partial/charts/redis-17.0.0.tgz", "")
+
+	st := &HelmState{
+		basePath: basePath,
+		fs:       filesystem.DefaultFileSystem(),
+		logger:   logger,
+		ReleaseSetSpec: ReleaseSetSpec{
+			HelmDefaults: HelmSpec{DependencyUpdateStrategy: DependencyUpdateStrategyIfMissing},
+		},
+	}
+
+	tests := []struct {
+		strategy string
+		chart    string
+		skips    bool
+		err      string
+	}{
+		{strategy: "", chart: "vendored", skips: true},
+		{strategy: "", chart: "partial", skips: false},
+		{strategy: DependencyUpdateStrategyAlways, chart: "vendored", skips: false},
+		{strategy: DependencyUpdateStrategyNever, chart: "partial", skips: true},
+		{strategy: "sometimes", chart: "partial", err: `invalid dependencyUpdateStrategy "sometimes": must be one of always, if-missing, never`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.strategy+"/"+tt.chart, func(t *testing.T) {
+			release := &ReleaseSpec{Name: "app", DependencyUpdateStrategy: tt.strategy}
+
+			skips, err := st.skipsDependencyBuild(release, filepath.Join(basePath, tt.chart))
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.skips, skips)
+		})
+	}
+}
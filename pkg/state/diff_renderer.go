@@ -0,0 +1,390 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// The renderers of the helm-diff outputs
+const (
+	// DiffRendererDefault prints the helm-diff outputs as they are
+	DiffRendererDefault = "default"
+	// DiffRendererRich highlights the changed words of the changed lines, and collapses the unchanged lines of the resources
+	DiffRendererRich = "rich"
+	// DiffRendererJSON prints the result of the diff of each release as a JSON object per line, for CI to parse
+	DiffRendererJSON = "json"
+)
+
+// defaultRichDiffContext is the number of the unchanged lines shown around the changes by the rich renderer, when --context isn't given
+const defaultRichDiffContext = 3
+
+// maxWordDiffTokens limits the size of the word diff of a pair of lines, above which the lines are shown without the highlights
+const maxWordDiffTokens = 250000
+
+const (
+	ansiReset     = "\x1b[0m"
+	ansiRed       = "\x1b[31m"
+	ansiGreen     = "\x1b[32m"
+	ansiYellow    = "\x1b[33m"
+	ansiDim       = "\x1b[2m"
+	ansiReverse   = "\x1b[7m"
+	ansiNoReverse = "\x1b[27m"
+)
+
+// ValidateDiffRenderer returns an error if the renderer is unknown, or can't render the output format of helm-diff
+func ValidateDiffRenderer(renderer, output string) error {
+	switch renderer {
+	case "", DiffRendererDefault:
+		return nil
+	case DiffRendererRich, DiffRendererJSON:
+		if output != "" && output != "diff" {
+			return fmt.Errorf("diff renderer %q supports only the diff output format, not %q", renderer, output)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown diff renderer %q: must be one of %s, %s, %s", renderer, DiffRendererDefault, DiffRendererRich, DiffRendererJSON)
+	}
+}
+
+// DiffResult is the result of the diff of a release, printed by DiffRendererJSON
+type DiffResult struct {
+	// Release is the ID of the release, like `default/web`
+	Release string `json:"release"`
+	// Changed is true when the release has any changes, including the releases upgraded without the diff by skipDiffOnInstall
+	Changed bool `json:"changed"`
+	// Added and Removed are the numbers of the added and the removed lines of all the resources
+	Added   int `json:"added"`
+	Removed int `json:"removed"`
+	// Resources are the changed resources in the order of the diff
+	Resources []DiffResourceResult `json:"resources"`
+	// Diff is the uncolored output of helm-diff
+	Diff string `json:"diff"`
+}
+
+// DiffResourceResult is a resource changed in the diff of a release
+type DiffResourceResult struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Kind      string `json:"kind"`
+	// Group is the API group of the kind as printed by helm-diff, like `apps`, or the version for the core group, like `v1`
+	Group string `json:"group"`
+	// Change is one of `changed`, `added` and `removed`
+	Change  string `json:"change"`
+	Added   int    `json:"added"`
+	Removed int    `json:"removed"`
+}
+
+// parseDiffResult parses the uncolored output of helm-diff into the result of the diff of the release
+func parseDiffResult(id string, out []byte, changed bool) DiffResult {
+	result := DiffResult{
+		Release:   id,
+		Resources: []DiffResourceResult{},
+		Diff:      string(out),
+	}
+
+	var cur *DiffResourceResult
+
+	for _, l := range strings.Split(string(out), "\n") {
+		plain := ansiEscapeRegexp.ReplaceAllString(l, "")
+
+		if m := diffHeaderRegexp.FindStringSubmatch(plain); m != nil {
+			result.Resources = append(result.Resources, DiffResourceResult{
+				Namespace: m[1],
+				Name:      m[2],
+				Kind:      m[3],
+				Group:     m[4],
+				Change:    strings.TrimPrefix(m[5], "been "),
+			})
+			cur = &result.Resources[len(result.Resources)-1]
+			continue
+		}
+
+		if cur == nil {
+			// The lines before the first header, like `Comparing release=...`
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(plain, "+ "), plain == "+":
+			cur.Added++
+			result.Added++
+		case strings.HasPrefix(plain, "- "), plain == "-":
+			cur.Removed++
+			result.Removed++
+		}
+	}
+
+	result.Changed = changed || len(result.Resources) > 0
+
+	return result
+}
+
+// renderJSONDiff renders the result of the diff of the release as a JSON object on a line
+func renderJSONDiff(id string, out []byte, changed bool) ([]byte, error) {
+	bs, err := json.Marshal(parseDiffResult(id, out, changed))
+	if err != nil {
+		return nil, fmt.Errorf("rendering the diff of release %s as json: %w", id, err)
+	}
+
+	return append(bs, '\n'), nil
+}
+
+type diffLine struct {
+	// op is '+' for an added line, '-' for a removed line, and ' ' for an unchanged line
+	op   byte
+	text string
+}
+
+// renderRichDiff renders the output of helm-diff, which must be uncolored, with the changed words of the changed lines highlighted,
+// and the unchanged lines of each changed resource further than `context` lines away from the changes collapsed.
+// Without colors, the removed and the added words are marked like `[-old-]` and `{+new+}`.
+func renderRichDiff(out []byte, context int, colored bool) []byte {
+	if context <= 0 {
+		context = defaultRichDiffContext
+	}
+
+	var (
+		sb      strings.Builder
+		block   []diffLine
+		inBlock bool
+	)
+
+	flush := func() {
+		if inBlock {
+			writeRichDiffBlock(&sb, block, context, colored)
+		}
+		block = nil
+	}
+
+	text := strings.TrimSuffix(string(out), "\n")
+	if text == "" {
+		return out
+	}
+
+	for _, l := range strings.Split(text, "\n") {
+		plain := ansiEscapeRegexp.ReplaceAllString(l, "")
+
+		if diffHeaderRegexp.MatchString(plain) {
+			flush()
+			inBlock = true
+			if colored {
+				sb.WriteString(ansiYellow + plain + ansiReset + "\n")
+			} else {
+				sb.WriteString(plain + "\n")
+			}
+			continue
+		}
+
+		if !inBlock {
+			// The lines before the first header, like `Comparing release=...`
+			sb.WriteString(l + "\n")
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(plain, "+ "), plain == "+":
+			block = append(block, diffLine{op: '+', text: strings.TrimPrefix(plain[1:], " ")})
+		case strings.HasPrefix(plain, "- "), plain == "-":
+			block = append(block, diffLine{op: '-', text: strings.TrimPrefix(plain[1:], " ")})
+		default:
+			block = append(block, diffLine{op: ' ', text: strings.TrimPrefix(plain, "  ")})
+		}
+	}
+	flush()
+
+	return []byte(sb.String())
+}
+
+// writeRichDiffBlock writes the lines of the diff of a resource
+func writeRichDiffBlock(sb *strings.Builder, lines []diffLine, context int, colored bool) {
+	// shown is true for the lines within `context` lines of a change
+	shown := make([]bool, len(lines))
+	for i, l := range lines {
+		if l.op == ' ' {
+			continue
+		}
+		for j := i - context; j <= i+context; j++ {
+			if j >= 0 && j < len(lines) {
+				shown[j] = true
+			}
+		}
+	}
+
+	for i := 0; i < len(lines); {
+		l := lines[i]
+
+		if !shown[i] {
+			j := i
+			for j < len(lines) && !shown[j] {
+				j++
+			}
+			writeCollapsedLines(sb, j-i, colored)
+			i = j
+			continue
+		}
+
+		if l.op == ' ' {
+			if l.text != "" {
+				sb.WriteString("  " + l.text)
+			}
+			sb.WriteString("\n")
+			i++
+			continue
+		}
+
+		// A run of removed lines followed by a run of added lines, whose lines are paired in order to highlight the changed words
+		var removed, added []string
+		j := i
+		for j < len(lines) && lines[j].op == '-' {
+			removed = append(removed, lines[j].text)
+			j++
+		}
+		for j < len(lines) && lines[j].op == '+' {
+			added = append(added, lines[j].text)
+			j++
+		}
+
+		removedTexts := make([]string, len(removed))
+		addedTexts := make([]string, len(added))
+		for k := range removed {
+			removedTexts[k] = highlightLine(removed[k], nil, '-', colored)
+		}
+		for k := range added {
+			addedTexts[k] = highlightLine(added[k], nil, '+', colored)
+		}
+		for k := 0; k < len(removed) && k < len(added); k++ {
+			oldChanged, newChanged, ok := wordDiff(removed[k], added[k])
+			if !ok {
+				continue
+			}
+			removedTexts[k] = highlightLine(removed[k], oldChanged, '-', colored)
+			addedTexts[k] = highlightLine(added[k], newChanged, '+', colored)
+		}
+
+		for _, t := range removedTexts {
+			sb.WriteString(t + "\n")
+		}
+		for _, t := range addedTexts {
+			sb.WriteString(t + "\n")
+		}
+
+		i = j
+	}
+}
+
+func writeCollapsedLines(sb *strings.Builder, n int, colored bool) {
+	unit := "lines"
+	if n == 1 {
+		unit = "line"
+	}
+	msg := fmt.Sprintf("  ... %d unchanged %s", n, unit)
+	if colored {
+		msg = ansiDim + msg + ansiReset
+	}
+	sb.WriteString(msg + "\n")
+}
+
+var wordTokenRegexp = regexp.MustCompile(`\w+|\s+|[^\w\s]`)
+
+// wordDiff returns the tokens of the old and the new lines, each marked whether it's changed or not.
+// It returns false when the lines are too long to be compared word by word.
+func wordDiff(oldLine, newLine string) ([]token, []token, bool) {
+	a := wordTokenRegexp.FindAllString(oldLine, -1)
+	b := wordTokenRegexp.FindAllString(newLine, -1)
+
+	if len(a)*len(b) > maxWordDiffTokens {
+		return nil, nil, false
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var oldTokens, newTokens []token
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			oldTokens = append(oldTokens, token{text: a[i]})
+			newTokens = append(newTokens, token{text: b[j]})
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]):
+			newTokens = append(newTokens, token{text: b[j], changed: true})
+			j++
+		default:
+			oldTokens = append(oldTokens, token{text: a[i], changed: true})
+			i++
+		}
+	}
+
+	return oldTokens, newTokens, true
+}
+
+type token struct {
+	text    string
+	changed bool
+}
+
+// highlightLine renders the removed or the added line, with the changed tokens highlighted.
+// The whole line is highlighted as is when tokens is nil.
+func highlightLine(text string, tokens []token, op byte, colored bool) string {
+	var sb strings.Builder
+
+	color, openMark, closeMark := ansiGreen, "{+", "+}"
+	if op == '-' {
+		color, openMark, closeMark = ansiRed, "[-", "-]"
+	}
+
+	if colored {
+		sb.WriteString(color)
+	}
+	sb.WriteByte(op)
+	sb.WriteByte(' ')
+
+	if tokens == nil {
+		sb.WriteString(text)
+	}
+
+	for k, t := range tokens {
+		prevChanged := k > 0 && tokens[k-1].changed
+		nextChanged := k < len(tokens)-1 && tokens[k+1].changed
+
+		if t.changed && !prevChanged {
+			if colored {
+				sb.WriteString(ansiReverse)
+			} else {
+				sb.WriteString(openMark)
+			}
+		}
+		sb.WriteString(t.text)
+		if t.changed && !nextChanged {
+			if colored {
+				sb.WriteString(ansiNoReverse)
+			} else {
+				sb.WriteString(closeMark)
+			}
+		}
+	}
+
+	if colored {
+		sb.WriteString(ansiReset)
+	}
+
+	return sb.String()
+}
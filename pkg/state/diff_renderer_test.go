@@ -0,0 +1,107 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderRichDiff(t *testing.T) {
+	out := `Comparing release=web, chart=charts/web
+default, web, Deployment (apps) has changed:
+  # Source: web/templates/deployment.yaml
+  apiVersion: apps/v1
+  kind: Deployment
+  metadata:
+    name: web
+  spec:
+-   replicas: 1
++   replicas: 3
+    template:
+      spec:
+        containers:
+        - name: web
+-         image: "nginx:1.23.1"
++         image: "nginx:1.25.3"
+          ports:
+          - containerPort: 80
++         - containerPort: 443
+          resources: {}
+
+default, web, Service (v1) has been added:
++ apiVersion: v1
++ kind: Service
+`
+
+	require.Equal(t, `Comparing release=web, chart=charts/web
+default, web, Deployment (apps) has changed:
+  ... 3 unchanged lines
+  metadata:
+    name: web
+  spec:
+-   replicas: [-1-]
++   replicas: {+3+}
+    template:
+      spec:
+        containers:
+        - name: web
+-         image: "nginx:1.[-23-].[-1-]"
++         image: "nginx:1.{+25+}.{+3+}"
+          ports:
+          - containerPort: 80
++         - containerPort: 443
+          resources: {}
+
+default, web, Service (v1) has been added:
++ apiVersion: v1
++ kind: Service
+`, string(renderRichDiff([]byte(out), 0, false)))
+}
+
+func TestRenderRichDiff_Colored(t *testing.T) {
+	out := `default, web, ConfigMap (v1) has changed:
+-   level: info
++   level: debug
+`
+
+	require.Equal(t, "\x1b[33mdefault, web, ConfigMap (v1) has changed:\x1b[0m\n"+
+		"\x1b[31m-   level: \x1b[7minfo\x1b[27m\x1b[0m\n"+
+		"\x1b[32m+   level: \x1b[7mdebug\x1b[27m\x1b[0m\n", string(renderRichDiff([]byte(out), 0, true)))
+
+	require.EqualError(t, ValidateDiffRenderer(DiffRendererRich, "json"), `diff renderer "rich" supports only the diff output format, not "json"`)
+	require.EqualError(t, ValidateDiffRenderer("fancy", ""), `unknown diff renderer "fancy": must be one of default, rich, json`)
+}
+
+func TestParseDiffResult(t *testing.T) {
+	out := `Comparing release=web, chart=charts/web
+default, web, Deployment (apps) has changed:
+  spec:
+-   replicas: 1
++   replicas: 3
+    template:
++     foo: bar
+
+default, web, Service (v1) has been added:
++ apiVersion: v1
++ kind: Service
+`
+
+	require.Equal(t, DiffResult{
+		Release: "default/web",
+		Changed: true,
+		Added:   4,
+		Removed: 1,
+		Resources: []DiffResourceResult{
+			{Namespace: "default", Name: "web", Kind: "Deployment", Group: "apps", Change: "changed", Added: 2, Removed: 1},
+			{Namespace: "default", Name: "web", Kind: "Service", Group: "v1", Change: "added", Added: 2},
+		},
+		Diff: out,
+	}, parseDiffResult("default/web", []byte(out), false))
+
+	require.Equal(t, DiffResult{
+		Release:   "default/db",
+		Resources: []DiffResourceResult{},
+	}, parseDiffResult("default/db", nil, false))
+
+	require.True(t, parseDiffResult("default/db", nil, true).Changed, "the releases upgraded without the diff must be changed")
+}
@@ -1,10 +1,31 @@
 package state
 
+import (
+	"fmt"
+
+	"helm.sh/helm/v3/pkg/chartutil"
+
+	"github.com/helmfile/helmfile/pkg/yaml"
+)
+
 type EnvironmentSpec struct {
 	Values      []interface{} `yaml:"values,omitempty"`
 	Secrets     []string      `yaml:"secrets,omitempty"`
 	KubeContext string        `yaml:"kubeContext,omitempty"`
 
+	// ValuesOverrideSecrets makes the keys of `values` take precedence over the same keys of the decrypted `secrets`,
+	// rather than being overridden by them, which is the default.
+	ValuesOverrideSecrets bool `yaml:"valuesOverrideSecrets,omitempty"`
+
+	// HelmDefaults overrides the keys of the top-level `helmDefaults` when the environment is selected,
+	// like `wait: true` and a longer `timeout` for production.
+	// The keys not specified here are left as they are in the top-level `helmDefaults`.
+	HelmDefaults map[string]interface{} `yaml:"helmDefaults,omitempty"`
+
+	// ValuesSchema is the JSON schema file the merged values of the environment are validated against on loading the state,
+	// so that the typos and the missing keys in the values fail early, before any release is rendered
+	ValuesSchema string `yaml:"valuesSchema,omitempty"`
+
 	// MissingFileHandler instructs helmfile to fail when unable to find a environment values file listed
 	// under `environments.NAME.values`.
 	//
@@ -16,3 +37,71 @@ type EnvironmentSpec struct {
 	// MissingFileHandlerConfig is composed of various settings for the MissingFileHandler
 	MissingFileHandlerConfig MissingFileHandlerConfig `yaml:"missingFileHandlerConfig,omitempty"`
 }
+
+// applyEnvironmentHelmDefaults overrides the helmDefaults of the state with the ones of the environment, if any
+func (st *HelmState) applyEnvironmentHelmDefaults(name string) error {
+	envSpec, ok := st.Environments[name]
+	if !ok || len(envSpec.HelmDefaults) == 0 {
+		return nil
+	}
+
+	bs, err := yaml.Marshal(st.HelmDefaults)
+	if err != nil {
+		return err
+	}
+
+	defaults := map[string]interface{}{}
+	if err := yaml.Unmarshal(bs, &defaults); err != nil {
+		return err
+	}
+
+	for k, v := range envSpec.HelmDefaults {
+		defaults[k] = v
+	}
+
+	bs, err = yaml.Marshal(defaults)
+	if err != nil {
+		return err
+	}
+
+	var merged HelmSpec
+	if err := yaml.NewDecoder(bs, true)(&merged); err != nil {
+		return fmt.Errorf("invalid helmDefaults in environment %q: %v", name, err)
+	}
+
+	st.HelmDefaults = merged
+
+	return nil
+}
+
+// validateEnvironmentValues validates the merged values of the environment against the `valuesSchema` of the environment, if any
+func (st *HelmState) validateEnvironmentValues(name string) error {
+	envSpec, ok := st.Environments[name]
+	if !ok || envSpec.ValuesSchema == "" {
+		return nil
+	}
+
+	files, _, err := st.storage().resolveFile(nil, "environment values schema", envSpec.ValuesSchema)
+	if err != nil {
+		return err
+	}
+	if len(files) != 1 {
+		return fmt.Errorf("values schema matching %q of environment %q: expected a single file, found %d", envSpec.ValuesSchema, name, len(files))
+	}
+
+	schema, err := st.fs.ReadFile(files[0])
+	if err != nil {
+		return err
+	}
+
+	vals, err := st.Env.GetMergedValues()
+	if err != nil {
+		return err
+	}
+
+	if err := chartutil.ValidateAgainstSingleSchema(vals, schema); err != nil {
+		return fmt.Errorf("values of environment %q don't match the schema %s:\n%v", name, envSpec.ValuesSchema, err)
+	}
+
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"path/filepath"
 
+	"github.com/helmfile/vals"
 	"github.com/imdario/mergo"
 	"go.uber.org/zap"
 
@@ -23,6 +24,20 @@ type EnvironmentValuesLoader struct {
 	logger *zap.SugaredLogger
 
 	remote *remote.Remote
+
+	// Namespace is accessible as `.Namespace` from the environment values templates
+	Namespace string
+	// KubeContext is accessible as `.KubeContext` from the environment values templates
+	KubeContext string
+	// HelmBinary is the helm binary run by `renderChart` and `chartValues` in the environment values templates
+	HelmBinary string
+	// ClusterLookups is whether the environment values templates are allowed to query the cluster with `kubectlGet`
+	ClusterLookups tmpl.ClusterLookups
+
+	// sops decrypts the values files ending in `.sops.yaml`, which are not rendered as templates
+	sops SecretsDecrypter
+	// secrets resolves the secret references in the environment values templates, instead of the shared vals runtime, if set
+	secrets vals.Evaluator
 }
 
 func NewEnvironmentValuesLoader(storage *Storage, fs *filesystem.FileSystem, logger *zap.SugaredLogger, remote *remote.Remote) *EnvironmentValuesLoader {
@@ -31,6 +46,7 @@ func NewEnvironmentValuesLoader(storage *Storage, fs *filesystem.FileSystem, log
 		fs:      fs,
 		logger:  logger,
 		remote:  remote,
+		sops:    sopsDecrypter{},
 	}
 }
 
@@ -57,6 +73,16 @@ func (ld *EnvironmentValuesLoader) LoadEnvironmentValues(missingFileHandler *str
 			}
 
 			for _, f := range files {
+				if isSOPSFile(f) {
+					m, err := ld.sops.Decrypt(f)
+					if err != nil {
+						return nil, fmt.Errorf("failed to decrypt environment values file \"%s\": %v", f, err)
+					}
+					maps = append(maps, m)
+					ld.logger.Debugf("envvals_loader: decrypted %s", strOrMap)
+					continue
+				}
+
 				var env environment.Environment
 				if ctxEnv == nil {
 					env = *environment.New(envName)
@@ -64,8 +90,15 @@ func (ld *EnvironmentValuesLoader) LoadEnvironmentValues(missingFileHandler *str
 					env = *ctxEnv
 				}
 
-				tmplData := NewEnvironmentTemplateData(env, "", map[string]interface{}{})
+				tmplData := NewEnvironmentTemplateData(env, ld.Namespace, map[string]interface{}{})
+				tmplData.KubeContext = ld.KubeContext
+				tmplData.Files = tmpl.NewFiles(ld.fs, ld.storage.basePath)
 				r := tmpl.NewFileRenderer(ld.fs, filepath.Dir(f), tmplData)
+				r.Context.SetHelmBinary(ld.HelmBinary)
+				r.Context.SetClusterLookups(ld.ClusterLookups)
+				if ld.secrets != nil {
+					r.Context.SetSecretsEvaluator(ld.secrets)
+				}
 				bytes, err := r.RenderToBytes(f)
 				if err != nil {
 					return nil, fmt.Errorf("failed to load environment values file \"%s\": %v", f, err)
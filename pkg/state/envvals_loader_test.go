@@ -2,9 +2,12 @@ package state
 
 import (
 	"io"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/require"
 
 	"github.com/helmfile/helmfile/pkg/environment"
 	ffs "github.com/helmfile/helmfile/pkg/filesystem"
@@ -43,6 +46,27 @@ func TestEnvValsLoad_SingleValuesFile(t *testing.T) {
 	}
 }
 
+func TestEnvValsLoad_TemplateContext(t *testing.T) {
+	l := newLoader()
+	l.Namespace = "myns"
+	l.KubeContext = "mycontext"
+
+	actual, err := l.LoadEnvironmentValues(nil, []interface{}{"testdata/values.7.yaml.gotmpl"}, nil, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"namespace":   "myns",
+		"kubeContext": "mycontext",
+		"releaseName": "",
+	}
+
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Errorf(diff)
+	}
+}
+
 func TestEnvValsLoad_EnvironmentNameFile(t *testing.T) {
 	l := newLoader()
 
@@ -187,3 +211,23 @@ func TestEnvValsLoad_OverwriteEmptyValue_Issue1168(t *testing.T) {
 		t.Errorf(diff)
 	}
 }
+
+func TestEnvValsLoad_SOPSFile(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "values.sops.yaml"), []byte("password: ENC[...]\n"), 0644))
+
+	l := newLoader()
+	l.sops = &fakeDecrypter{
+		files:   true,
+		secrets: map[string]map[string]interface{}{"values.sops.yaml": {"password": "secret"}},
+	}
+
+	actual, err := l.LoadEnvironmentValues(nil, []interface{}{"testdata/values.5.yaml", filepath.Join(dir, "values.sops.yaml")}, nil, "")
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"affinity": map[string]interface{}{}, "password": "secret"}, actual)
+
+	l.sops = &fakeDecrypter{files: true}
+
+	_, err = l.LoadEnvironmentValues(nil, []interface{}{filepath.Join(dir, "values.sops.yaml")}, nil, "")
+	require.ErrorContains(t, err, "failed to decrypt environment values file")
+}
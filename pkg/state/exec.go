@@ -0,0 +1,110 @@
+package state
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/helmfile/helmfile/pkg/helmexec"
+)
+
+// ExecReleases runs the helm command given as args, like `get manifest`, for each release,
+// with the namespace and the kube context of the release filled in.
+// Any arg can be a go template referring to the release as `.Release`, like `{{ .Release.Name }}`.
+// The release name is appended to the args unless any of them is a template.
+// The outputs are written to w in the order of the releases, so that the outputs of the releases run concurrently don't interleave.
+func (st *HelmState) ExecReleases(helm helmexec.Interface, args []string, workerLimit int, w io.Writer) []error {
+	var (
+		mu      sync.Mutex
+		outputs = map[string][]byte{}
+	)
+
+	errs := st.scatterGatherReleases(helm, workerLimit, func(release ReleaseSpec, workerIndex int) error {
+		if !release.Desired() {
+			return nil
+		}
+
+		st.ApplyOverrides(&release)
+
+		cmdArgs, err := renderExecArgs(&release, args)
+		if err != nil {
+			return err
+		}
+
+		if release.Namespace != "" {
+			cmdArgs = append(cmdArgs, "--namespace", release.Namespace)
+		}
+		cmdArgs = st.appendConnectionFlags(cmdArgs, &release)
+
+		buf := &bytes.Buffer{}
+		context := st.createHelmContext(&release, workerIndex)
+		context.Writer = buf
+
+		err = helm.Exec(context, cmdArgs...)
+
+		mu.Lock()
+		outputs[ReleaseToID(&release)] = buf.Bytes()
+		mu.Unlock()
+
+		if err != nil {
+			return fmt.Errorf("running helm %s on release %s: %w", strings.Join(args, " "), release.Name, err)
+		}
+
+		return nil
+	})
+
+	for _, r := range st.Releases {
+		release := r
+		st.ApplyOverrides(&release)
+
+		if out, ok := outputs[ReleaseToID(&release)]; ok {
+			_, _ = w.Write(out)
+		}
+	}
+
+	return errs
+}
+
+// renderExecArgs renders the args given to ExecReleases for the release
+func renderExecArgs(release *ReleaseSpec, args []string) ([]string, error) {
+	var (
+		rendered   []string
+		isTemplate bool
+	)
+
+	data := struct {
+		Release ReleaseSpec
+	}{
+		Release: *release,
+	}
+
+	for _, a := range args {
+		if !strings.Contains(a, "{{") {
+			rendered = append(rendered, a)
+			continue
+		}
+
+		isTemplate = true
+
+		t, err := template.New("exec-arg").Option("missingkey=error").Parse(a)
+		if err != nil {
+			return nil, fmt.Errorf("parsing arg %q: %w", a, err)
+		}
+
+		buf := &bytes.Buffer{}
+		if err := t.Execute(buf, data); err != nil {
+			return nil, fmt.Errorf("rendering arg %q for release %s: %w", a, release.Name, err)
+		}
+
+		rendered = append(rendered, buf.String())
+	}
+
+	if !isTemplate {
+		rendered = append(rendered, release.Name)
+	}
+
+	return rendered, nil
+}
@@ -0,0 +1,52 @@
+package state
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/exectest"
+)
+
+func TestHelmState_ExecReleases(t *testing.T) {
+	st := &HelmState{
+		ReleaseSetSpec: ReleaseSetSpec{
+			HelmDefaults: HelmSpec{KubeContext: "prod"},
+			Releases: []ReleaseSpec{
+				{Name: "db", Namespace: "data"},
+				{Name: "web"},
+			},
+		},
+		logger: logger,
+	}
+
+	t.Run("release name appended", func(t *testing.T) {
+		helm := &exectest.Helm{}
+		buf := &bytes.Buffer{}
+
+		errs := st.ExecReleases(helm, []string{"get", "manifest"}, 1, buf)
+		require.Empty(t, errs)
+		require.Equal(t, [][]string{
+			{"get", "manifest", "db", "--namespace", "data", "--kube-context", "prod"},
+			{"get", "manifest", "web", "--kube-context", "prod"},
+		}, helm.Execs)
+		require.Equal(t, "get manifest db --namespace data --kube-context prod\nget manifest web --kube-context prod\n", buf.String())
+	})
+
+	t.Run("templated args", func(t *testing.T) {
+		helm := &exectest.Helm{}
+
+		errs := st.ExecReleases(helm, []string{"rollback", "{{ .Release.Name }}", "0"}, 1, &bytes.Buffer{})
+		require.Empty(t, errs)
+		require.Equal(t, [][]string{
+			{"rollback", "db", "0", "--namespace", "data", "--kube-context", "prod"},
+			{"rollback", "web", "0", "--kube-context", "prod"},
+		}, helm.Execs)
+	})
+
+	t.Run("invalid template", func(t *testing.T) {
+		errs := st.ExecReleases(&exectest.Helm{}, []string{"get", "{{ .Release.Nam }}"}, 1, &bytes.Buffer{})
+		require.Len(t, errs, 2)
+	})
+}
@@ -0,0 +1,94 @@
+package state
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// needsHelmfilePrefix marks a `needs` entry that refers to a release of another helmfile by its name or path,
+// written as a map like `{helmfile: platform, release: kube-system/ingress-nginx}`
+const needsHelmfilePrefix = "helmfile:"
+
+// externalNeedSeparator separates the helmfile and the release of a `needs` entry referring to a release of another helmfile,
+// like the go-getter subdirectories
+const externalNeedSeparator = "//"
+
+// ExternalNeed is a `needs` entry referring to a release of another helmfile processed in the same run,
+// like `../platform/helmfile.yaml//kube-system/ingress-nginx`.
+// The releases of the other helmfile are processed before the ones needing them, instead of being part of the DAG of the state.
+type ExternalNeed struct {
+	// Helmfile is either the path to the other helmfile relative to the helmfile of the release needing it,
+	// or the name of the other helmfile given in the `helmfiles` of its parent
+	Helmfile string
+	// Release is the name of the release, optionally prefixed with the namespace and the kubecontext
+	Release string
+}
+
+// ParseExternalNeed returns the external need of the `needs` entry, and false if the entry refers to a release of the same helmfile.
+// The helmfile of the string form must be a path to a YAML or a gotmpl file, so that it isn't taken for a kubecontext.
+func ParseExternalNeed(n string) (ExternalNeed, bool) {
+	if strings.HasPrefix(n, needsHelmfilePrefix) {
+		helmfile, release, ok := strings.Cut(strings.TrimPrefix(n, needsHelmfilePrefix), externalNeedSeparator)
+		if !ok || helmfile == "" || release == "" {
+			return ExternalNeed{}, false
+		}
+		return ExternalNeed{Helmfile: helmfile, Release: release}, true
+	}
+
+	helmfile, release, ok := strings.Cut(n, externalNeedSeparator)
+	if !ok || release == "" || !isStateFilePath(helmfile) {
+		return ExternalNeed{}, false
+	}
+
+	return ExternalNeed{Helmfile: helmfile, Release: release}, true
+}
+
+func isStateFilePath(p string) bool {
+	switch filepath.Ext(p) {
+	case ".yaml", ".yml", ".gotmpl":
+		return true
+	}
+	return false
+}
+
+// IsName returns true when the helmfile is referred by the name given in the `helmfiles` of its parent rather than by the path
+func (e ExternalNeed) IsName() bool {
+	return !strings.ContainsAny(e.Helmfile, `/\`) && !isStateFilePath(e.Helmfile)
+}
+
+func (e ExternalNeed) String() string {
+	if isStateFilePath(e.Helmfile) {
+		return e.Helmfile + externalNeedSeparator + e.Release
+	}
+	return needsHelmfilePrefix + e.Helmfile + externalNeedSeparator + e.Release
+}
+
+// Matches returns true when the release is the one referred, where the namespace and the kubecontext match any when omitted
+func (e ExternalNeed) Matches(r *ReleaseSpec) bool {
+	components := strings.Split(e.Release, "/")
+
+	if components[len(components)-1] != r.Name {
+		return false
+	}
+
+	if len(components) > 1 && components[len(components)-2] != r.Namespace {
+		return false
+	}
+
+	if len(components) > 2 && strings.Join(components[:len(components)-2], "/") != r.KubeContext {
+		return false
+	}
+
+	return true
+}
+
+// ExternalNeeds returns the needs of the release referring to the releases of other helmfiles
+func (r ReleaseSpec) ExternalNeeds() []ExternalNeed {
+	var needs []ExternalNeed
+	for _, n := range r.Needs {
+		if e, ok := ParseExternalNeed(n); ok {
+			needs = append(needs, e)
+		}
+	}
+	return needs
+}
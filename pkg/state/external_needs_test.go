@@ -0,0 +1,68 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseExternalNeed(t *testing.T) {
+	tests := []struct {
+		need string
+		want ExternalNeed
+		ok   bool
+	}{
+		{need: "ingress-nginx"},
+		{need: "kube-system/ingress-nginx"},
+		{need: "prod/kube-system/ingress-nginx"},
+		{need: "selector:tier=infra"},
+		{need: "../platform/helmfile.yaml"},
+		{need: "helmfile:platform"},
+		{need: "helmfile:platform//"},
+		{
+			need: "../platform/helmfile.yaml//kube-system/ingress-nginx",
+			want: ExternalNeed{Helmfile: "../platform/helmfile.yaml", Release: "kube-system/ingress-nginx"},
+			ok:   true,
+		},
+		{
+			need: "platform.yaml.gotmpl//ingress-nginx",
+			want: ExternalNeed{Helmfile: "platform.yaml.gotmpl", Release: "ingress-nginx"},
+			ok:   true,
+		},
+		{
+			need: "helmfile:platform//kube-system/ingress-nginx",
+			want: ExternalNeed{Helmfile: "platform", Release: "kube-system/ingress-nginx"},
+			ok:   true,
+		},
+		{
+			need: "helmfile:../platform//ingress-nginx",
+			want: ExternalNeed{Helmfile: "../platform", Release: "ingress-nginx"},
+			ok:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		got, ok := ParseExternalNeed(tt.need)
+		require.Equal(t, tt.ok, ok, tt.need)
+		require.Equal(t, tt.want, got, tt.need)
+
+		if ok {
+			require.Equal(t, tt.need, got.String(), tt.need)
+		}
+	}
+
+	require.True(t, ExternalNeed{Helmfile: "platform"}.IsName())
+	require.False(t, ExternalNeed{Helmfile: "../platform"}.IsName())
+	require.False(t, ExternalNeed{Helmfile: "platform.yaml"}.IsName())
+}
+
+func TestExternalNeed_Matches(t *testing.T) {
+	r := &ReleaseSpec{Name: "ingress-nginx", Namespace: "kube-system", KubeContext: "prod"}
+
+	require.True(t, ExternalNeed{Release: "ingress-nginx"}.Matches(r))
+	require.True(t, ExternalNeed{Release: "kube-system/ingress-nginx"}.Matches(r))
+	require.True(t, ExternalNeed{Release: "prod/kube-system/ingress-nginx"}.Matches(r))
+	require.False(t, ExternalNeed{Release: "default/ingress-nginx"}.Matches(r))
+	require.False(t, ExternalNeed{Release: "dev/kube-system/ingress-nginx"}.Matches(r))
+	require.False(t, ExternalNeed{Release: "cert-manager"}.Matches(r))
+}
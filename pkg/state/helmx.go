@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/variantdev/chartify"
 
@@ -45,6 +46,11 @@ type Chartify struct {
 }
 
 func (st *HelmState) downloadChartWithGoGetter(r *ReleaseSpec) (string, error) {
+	return st.goGetterChart(r.Chart, r.Directory, goGetterChartCacheDir(r), r.ForceGoGetter)
+}
+
+// goGetterChartCacheDir returns the directory the remote chart of the release is fetched into within the cache directory
+func goGetterChartCacheDir(r *ReleaseSpec) string {
 	var pathElems []string
 
 	if r.Namespace != "" {
@@ -57,9 +63,7 @@ func (st *HelmState) downloadChartWithGoGetter(r *ReleaseSpec) (string, error) {
 
 	pathElems = append(pathElems, r.Name)
 
-	cacheDir := filepath.Join(pathElems...)
-
-	return st.goGetterChart(r.Chart, r.Directory, cacheDir, r.ForceGoGetter)
+	return filepath.Join(pathElems...)
 }
 
 func (st *HelmState) goGetterChart(chart, dir, cacheDir string, force bool) (string, error) {
@@ -67,13 +71,18 @@ func (st *HelmState) goGetterChart(chart, dir, cacheDir string, force bool) (str
 		chart = dir
 	}
 
+	// An OCI chart, which may be pinned by digest like `oci://registry/app@sha256:...`, is pulled by helm rather than go-getter
+	if strings.HasPrefix(chart, "oci://") && !force {
+		return chart, nil
+	}
+
 	_, err := remote.Parse(chart)
 	if err != nil {
 		if force {
 			return "", fmt.Errorf("Parsing url from dir failed due to error %q.\nContinuing the process assuming this is a regular Helm chart or a local dir.", err.Error())
 		}
 	} else {
-		r := remote.NewRemote(st.logger, "", st.fs)
+		r := st.newRemote()
 
 		fetchedDir, err := r.Fetch(chart, cacheDir)
 		if err != nil {
@@ -181,6 +190,18 @@ func (st *HelmState) PrepareChartify(helm helmexec.Interface, release *ReleaseSp
 		shouldRun = true
 	}
 
+	if release.Kustomize != nil {
+		generatedFiles, err := st.generateKustomizeTransformers(release)
+		filesNeedCleaning = append(filesNeedCleaning, generatedFiles...)
+		if err != nil {
+			return nil, clean, err
+		}
+
+		c.Opts.Transformers = append(c.Opts.Transformers, generatedFiles...)
+
+		shouldRun = true
+	}
+
 	if release.ForceNamespace != "" {
 		c.Opts.OverrideNamespace = release.ForceNamespace
 
@@ -0,0 +1,117 @@
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/helmfile/helmfile/pkg/helmexec"
+)
+
+// ReleaseRevision is a revision in the history of a release
+type ReleaseRevision struct {
+	// ID is the ID of the release, like `default/web` or `prod/default/web`
+	ID          string    `json:"id"`
+	Release     string    `json:"release"`
+	Namespace   string    `json:"namespace,omitempty"`
+	KubeContext string    `json:"kubeContext,omitempty"`
+	Revision    int       `json:"revision"`
+	Updated     time.Time `json:"updated"`
+	Status      string    `json:"status"`
+	Chart       string    `json:"chart"`
+	AppVersion  string    `json:"appVersion"`
+	Description string    `json:"description"`
+}
+
+// helmRevision is a revision in the output of `helm history --output json`
+type helmRevision struct {
+	Revision    int       `json:"revision"`
+	Updated     time.Time `json:"updated"`
+	Status      string    `json:"status"`
+	Chart       string    `json:"chart"`
+	AppVersion  string    `json:"app_version"`
+	Description string    `json:"description"`
+}
+
+// ReleaseHistories returns the revisions of the releases, up to max revisions per release when max is greater than 0.
+// The releases not installed yet have no revisions.
+// The revisions are sorted by the release ID, and then by the revision.
+func (st *HelmState) ReleaseHistories(helm helmexec.Interface, max int, workerLimit int) ([]ReleaseRevision, []error) {
+	var (
+		mu        sync.Mutex
+		revisions []ReleaseRevision
+	)
+
+	errs := st.scatterGatherReleases(helm, workerLimit, func(release ReleaseSpec, workerIndex int) error {
+		if !release.Desired() {
+			return nil
+		}
+
+		st.ApplyOverrides(&release)
+
+		args := []string{"history", release.Name, "--output", "json"}
+		if max > 0 {
+			args = append(args, "--max", strconv.Itoa(max))
+		}
+		if release.Namespace != "" {
+			args = append(args, "--namespace", release.Namespace)
+		}
+		args = st.appendConnectionFlags(args, &release)
+
+		buf := &bytes.Buffer{}
+		context := st.createHelmContext(&release, workerIndex)
+		context.Writer = buf
+
+		if err := helm.Exec(context, args...); err != nil {
+			if strings.Contains(err.Error(), "release: not found") {
+				return nil
+			}
+			return fmt.Errorf("getting history of release %s: %w", release.Name, err)
+		}
+
+		var hrs []helmRevision
+		if err := json.Unmarshal(buf.Bytes(), &hrs); err != nil {
+			return fmt.Errorf("parsing history of release %s: %v", release.Name, err)
+		}
+
+		id := ReleaseToID(&release)
+		kubeContext := release.KubeContext
+		if kubeContext == "" {
+			kubeContext = st.HelmDefaults.KubeContext
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		for _, r := range hrs {
+			revisions = append(revisions, ReleaseRevision{
+				ID:          id,
+				Release:     release.Name,
+				Namespace:   release.Namespace,
+				KubeContext: kubeContext,
+				Revision:    r.Revision,
+				Updated:     r.Updated,
+				Status:      r.Status,
+				Chart:       r.Chart,
+				AppVersion:  r.AppVersion,
+				Description: r.Description,
+			})
+		}
+
+		return nil
+	})
+
+	sort.SliceStable(revisions, func(i, j int) bool {
+		if revisions[i].ID != revisions[j].ID {
+			return revisions[i].ID < revisions[j].ID
+		}
+		return revisions[i].Revision < revisions[j].Revision
+	})
+
+	return revisions, errs
+}
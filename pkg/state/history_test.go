@@ -0,0 +1,65 @@
+package state
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/exectest"
+	"github.com/helmfile/helmfile/pkg/helmexec"
+)
+
+// historyHelm prints the canned `helm history` outputs of the releases
+type historyHelm struct {
+	exectest.Helm
+
+	histories map[string]string
+}
+
+func (helm *historyHelm) Exec(context helmexec.HelmContext, args ...string) error {
+	out, ok := helm.histories[args[1]]
+	if !ok {
+		return fmt.Errorf("Error: release: not found")
+	}
+	fmt.Fprint(context.Writer, out)
+	return nil
+}
+
+func TestHelmState_ReleaseHistories(t *testing.T) {
+	st := &HelmState{
+		ReleaseSetSpec: ReleaseSetSpec{
+			HelmDefaults: HelmSpec{KubeContext: "prod"},
+			Releases: []ReleaseSpec{
+				{Name: "web", Namespace: "default"},
+				{Name: "db", Namespace: "data"},
+				{Name: "new", Namespace: "default"},
+			},
+		},
+		logger: logger,
+	}
+
+	helm := &historyHelm{
+		histories: map[string]string{
+			"web": `[{"revision":1,"updated":"2023-01-01T00:00:00Z","status":"superseded","chart":"web-1.0.0","app_version":"1.0.0","description":"Install complete"},` +
+				`{"revision":2,"updated":"2023-01-02T00:00:00Z","status":"deployed","chart":"web-1.1.0","app_version":"1.1.0","description":"Upgrade complete"}]`,
+			"db": `[{"revision":3,"updated":"2023-01-03T00:00:00Z","status":"failed","chart":"db-2.0.0","app_version":"2.0.0","description":"Upgrade failed"}]`,
+		},
+	}
+
+	revisions, errs := st.ReleaseHistories(helm, 0, 1)
+	require.Empty(t, errs)
+
+	var got []string
+	for _, r := range revisions {
+		got = append(got, fmt.Sprintf("%s %d %s %s %s %s", r.ID, r.Revision, r.Updated.Format(time.RFC3339), r.Status, r.Chart, r.KubeContext))
+	}
+
+	require.Equal(t, strings.Join([]string{
+		"data/db 3 2023-01-03T00:00:00Z failed db-2.0.0 prod",
+		"default/web 1 2023-01-01T00:00:00Z superseded web-1.0.0 prod",
+		"default/web 2 2023-01-02T00:00:00Z deployed web-1.1.0 prod",
+	}, "\n"), strings.Join(got, "\n"))
+}
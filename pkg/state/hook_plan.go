@@ -0,0 +1,110 @@
+package state
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/helmfile/helmfile/pkg/event"
+)
+
+// HookPlan collects the hooks evaluated without being executed on the dry-runs,
+// so that their side effects can be reviewed before running them
+type HookPlan struct {
+	mu    sync.Mutex
+	hooks []PlannedHook
+}
+
+// PlannedHook is a hook command a helmfile run would execute
+type PlannedHook struct {
+	event.PlannedHook
+
+	// StateFile is the path to the state file declaring the hook
+	StateFile string `json:"stateFile"`
+	// Release is the ID of the release the hook is declared in, or empty for the hooks of the state
+	Release string `json:"release,omitempty"`
+}
+
+// recorder returns the function recording the hooks of the state file and the release into the plan
+func (p *HookPlan) recorder(stateFile, release string) func(event.PlannedHook) {
+	return func(h event.PlannedHook) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		p.hooks = append(p.hooks, PlannedHook{PlannedHook: h, StateFile: stateFile, Release: release})
+	}
+}
+
+// Hooks returns the hooks recorded into the plan, in the order they were triggered
+func (p *HookPlan) Hooks() []PlannedHook {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]PlannedHook(nil), p.hooks...)
+}
+
+// Write writes the table of the hooks recorded into the plan, or nothing when no hook would run
+func (p *HookPlan) Write(out io.Writer) error {
+	hooks := p.Hooks()
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	w := new(tabwriter.Writer)
+	w.Init(out, 0, 1, 3, ' ', 0)
+
+	fmt.Fprintln(w, "HOOKS THAT WOULD RUN:")
+	fmt.Fprintln(w, "STATE\tRELEASE\tEVENT\tHOOK\tCOMMAND\tDIR")
+
+	for _, h := range hooks {
+		release := h.Release
+		if release == "" {
+			release = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", h.StateFile, release, h.Event, h.Name, commandLine(h.Command, h.Args), h.Dir)
+	}
+
+	return w.Flush()
+}
+
+// commandLine returns the command and the args quoted where needed, like they would be typed in a shell
+func commandLine(command string, args []string) string {
+	words := make([]string, 0, len(args)+1)
+	for _, w := range append([]string{command}, args...) {
+		if w == "" || strings.ContainsAny(w, " \t\n\"'\\$`") {
+			w = fmt.Sprintf("%q", w)
+		}
+		words = append(words, w)
+	}
+	return strings.Join(words, " ")
+}
+
+// PlanSyncHooks records the hooks `helmfile apply` runs on deleting and upgrading the releases into the hook plan,
+// in the order they would be triggered, without executing them
+func (st *HelmState) PlanSyncHooks(toDelete, toUpdate []ReleaseSpec) error {
+	if st.HookPlan == nil {
+		return fmt.Errorf("bug: no hook plan to record the hooks of %s into", st.FilePath)
+	}
+
+	for i := range toDelete {
+		release := &toDelete[i]
+		for _, evt := range []string{"presync", "preuninstall", "postuninstall", "postsync", "cleanup"} {
+			if _, err := st.triggerReleaseEvent(evt, nil, release, "sync"); err != nil {
+				return err
+			}
+		}
+	}
+
+	for i := range toUpdate {
+		release := &toUpdate[i]
+		for _, evt := range []string{"presync", "postsync", "cleanup"} {
+			if _, err := st.triggerReleaseEvent(evt, nil, release, "sync"); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
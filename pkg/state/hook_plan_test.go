@@ -0,0 +1,42 @@
+package state
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/event"
+)
+
+func TestHookPlan_Write(t *testing.T) {
+	p := &HookPlan{}
+
+	var buf bytes.Buffer
+	require.NoError(t, p.Write(&buf))
+	require.Empty(t, buf.String(), "nothing must be written when no hook would run")
+
+	p.recorder("helmfile.yaml", "")(event.PlannedHook{
+		Event:   "prepare",
+		Command: "./scripts/fetch-secrets.sh",
+		Args:    []string{"staging"},
+		Dir:     ".",
+	})
+	p.recorder("helmfile.yaml", "prod/web/frontend")(event.PlannedHook{
+		Event:   "postsync",
+		Name:    "smoke",
+		Command: "sh",
+		Args:    []string{"-c", "curl -f $URL", ""},
+		Dir:     ".",
+	})
+
+	require.Len(t, p.Hooks(), 2)
+	require.Equal(t, "prod/web/frontend", p.Hooks()[1].Release)
+
+	require.NoError(t, p.Write(&buf))
+	require.Equal(t, `HOOKS THAT WOULD RUN:
+STATE           RELEASE             EVENT      HOOK    COMMAND                              DIR
+helmfile.yaml   -                   prepare            ./scripts/fetch-secrets.sh staging   .
+helmfile.yaml   prod/web/frontend   postsync   smoke   sh -c "curl -f $URL" ""              .
+`, buf.String())
+}
@@ -0,0 +1,392 @@
+package state
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	k8syaml "sigs.k8s.io/yaml"
+
+	"github.com/helmfile/helmfile/pkg/maputil"
+)
+
+// IgnoreDiffSpec is a field of the release's resources that is generated by the chart, like an auto-generated password,
+// a self-signed certificate or the caBundle of a webhook, whose changes are ignored by diff.
+type IgnoreDiffSpec struct {
+	// Kind is the kind of the resources, like `Secret`
+	Kind string `yaml:"kind,omitempty"`
+	// Name is the name of the resource. All the resources of the kind match when omitted
+	Name string `yaml:"name,omitempty"`
+	// Field is the path to the field like `data.postgres-password` or `webhooks[0].clientConfig.caBundle`.
+	// A dot in a key must be escaped, like `data.tls\.crt`.
+	Field string `yaml:"field,omitempty"`
+	// PreserveValue is the path to the chart value, like `auth.postgresPassword`, set to the current value of the field
+	// on diff and upgrade, so that the chart doesn't generate another one.
+	PreserveValue string `yaml:"preserveValue,omitempty"`
+}
+
+func (r IgnoreDiffSpec) validate() error {
+	if r.Kind == "" || r.Field == "" {
+		return errors.New("kind and field are required")
+	}
+
+	if r.PreserveValue != "" && r.Name == "" {
+		return errors.New("name is required to preserve the value")
+	}
+
+	return nil
+}
+
+func (r IgnoreDiffSpec) matches(kind, name string) bool {
+	return r.Kind == kind && (r.Name == "" || r.Name == name)
+}
+
+// diffHeaderRegexp matches the line helm-diff prints before the diff of each resource,
+// like `default, postgresql, Secret (v1) has changed:`
+var diffHeaderRegexp = regexp.MustCompile(`^([^,]*), ([^,]+), (\S+) \(([^)]*)\) has (changed|been added|been removed):\s*$`)
+
+var ansiEscapeRegexp = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+type diffBlock struct {
+	lines []string
+	kind  string
+	name  string
+	// changed is true for a block of a changed resource, which is the only type of block the rules apply to
+	changed bool
+}
+
+// filterIgnoredDiffs removes the diffs of the resources whose changes are all in the ignored fields from the output of helm-diff.
+// The old and the new manifests of each changed resource are reconstructed from its diff and compared without the ignored fields,
+// so that the fields are matched by their full paths. The resources with any other change are kept with their whole diffs,
+// and so are the ones whose diffs can't be parsed, like the ones cut by --context.
+// It returns the filtered output and whether any change is left.
+func filterIgnoredDiffs(out []byte, rules []IgnoreDiffSpec) ([]byte, bool) {
+	var (
+		blocks []*diffBlock
+		cur    = &diffBlock{}
+	)
+
+	blocks = append(blocks, cur)
+
+	for _, l := range strings.SplitAfter(string(out), "\n") {
+		if m := diffHeaderRegexp.FindStringSubmatch(strings.TrimRight(ansiEscapeRegexp.ReplaceAllString(l, ""), "\r\n")); m != nil {
+			cur = &diffBlock{kind: m[3], name: m[2], changed: m[5] == "changed"}
+			blocks = append(blocks, cur)
+		}
+		cur.lines = append(cur.lines, l)
+	}
+
+	var (
+		sb      strings.Builder
+		changed bool
+	)
+
+	for i, b := range blocks {
+		// The lines before the first header, like `Comparing release=...`
+		if i == 0 {
+			sb.WriteString(strings.Join(b.lines, ""))
+			continue
+		}
+
+		if b.changed && b.onlyIgnoredChanges(rules) {
+			continue
+		}
+
+		changed = true
+		sb.WriteString(strings.Join(b.lines, ""))
+	}
+
+	return []byte(sb.String()), changed
+}
+
+// onlyIgnoredChanges returns true if the old and the new manifests of the block are equal without the fields ignored by the rules
+func (b *diffBlock) onlyIgnoredChanges(rules []IgnoreDiffSpec) bool {
+	var fields [][]string
+	for _, r := range rules {
+		if r.matches(b.kind, b.name) {
+			fields = append(fields, maputil.ParseKey(r.Field))
+		}
+	}
+
+	if len(fields) == 0 {
+		return false
+	}
+
+	var oldManifest, newManifest strings.Builder
+
+	// Skip the header
+	for _, l := range b.lines[1:] {
+		l = strings.TrimRight(ansiEscapeRegexp.ReplaceAllString(l, ""), "\r\n")
+		if l == "" {
+			continue
+		}
+
+		if len(l) < 2 {
+			return false
+		}
+
+		switch prefix, content := l[:2], l[2:]; prefix {
+		case "  ":
+			oldManifest.WriteString(content + "\n")
+			newManifest.WriteString(content + "\n")
+		case "- ":
+			oldManifest.WriteString(content + "\n")
+		case "+ ":
+			newManifest.WriteString(content + "\n")
+		default:
+			return false
+		}
+	}
+
+	var oldDoc, newDoc interface{}
+	if err := k8syaml.Unmarshal([]byte(oldManifest.String()), &oldDoc); err != nil {
+		return false
+	}
+	if err := k8syaml.Unmarshal([]byte(newManifest.String()), &newDoc); err != nil {
+		return false
+	}
+
+	for _, f := range fields {
+		removeField(oldDoc, f)
+		removeField(newDoc, f)
+	}
+
+	return reflect.DeepEqual(oldDoc, newDoc)
+}
+
+// fieldSegmentRegexp matches a segment of the path to a field, like `webhooks[0]` or `data`
+var fieldSegmentRegexp = regexp.MustCompile(`^([^\[]*)((?:\[\d+\])*)$`)
+
+var fieldIndexRegexp = regexp.MustCompile(`\[(\d+)\]`)
+
+// removeField removes the field at the path from the parsed manifest, doing nothing when it's missing
+func removeField(doc interface{}, path []string) {
+	if len(path) == 0 {
+		return
+	}
+
+	m := fieldSegmentRegexp.FindStringSubmatch(path[0])
+	if m == nil {
+		return
+	}
+
+	key := m[1]
+	var indices []int
+	for _, i := range fieldIndexRegexp.FindAllStringSubmatch(m[2], -1) {
+		n, err := strconv.Atoi(i[1])
+		if err != nil {
+			return
+		}
+		indices = append(indices, n)
+	}
+
+	last := len(path) == 1
+
+	mm, ok := doc.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	if len(indices) == 0 {
+		if last {
+			delete(mm, key)
+			return
+		}
+		removeField(mm[key], path[1:])
+		return
+	}
+
+	v := mm[key]
+	for j, n := range indices {
+		arr, ok := v.([]interface{})
+		if !ok || n >= len(arr) {
+			return
+		}
+		if last && j == len(indices)-1 {
+			arr[n] = nil
+			return
+		}
+		v = arr[n]
+	}
+
+	removeField(v, path[1:])
+}
+
+// ignoreDiffRules returns the rules of the release, failing when any of them is invalid
+func ignoreDiffRules(release *ReleaseSpec) ([]IgnoreDiffSpec, error) {
+	for _, r := range release.IgnoreDiffs {
+		if err := r.validate(); err != nil {
+			return nil, fmt.Errorf("invalid ignoreDiffs of release %s: %v", release.Name, err)
+		}
+	}
+
+	return release.IgnoreDiffs, nil
+}
+
+// preservedValuesFlags returns the flags to set the chart values of the ignored fields to their current values in the cluster.
+// The fields of the resources that don't exist yet, like on the first install, are left to the chart,
+// and the values set by valuesFlags, which are the values files and the set values of the release, are left to the user.
+// The values are passed with `--set-string` rather than a values file, so that the decoded Secret data is never written to disk.
+func (st *HelmState) preservedValuesFlags(release *ReleaseSpec, valuesFlags []string) ([]string, error) {
+	rules, err := ignoreDiffRules(release)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		flags    []string
+		userKeys map[string]bool
+	)
+
+	for _, r := range rules {
+		if r.PreserveValue == "" {
+			continue
+		}
+
+		if userKeys == nil {
+			userKeys, err = st.userValueKeys(valuesFlags)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		key := maputil.ParseKey(r.PreserveValue)
+		if userKeys[strings.Join(key, "\x00")] {
+			st.logger.Debugf("release %s: not preserving %s, which is set by the values of the release", release.Name, r.PreserveValue)
+			continue
+		}
+
+		v, found, err := st.currentFieldValue(release, r)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			continue
+		}
+
+		escapedKey := make([]string, len(key))
+		for i, k := range key {
+			escapedKey[i] = strings.ReplaceAll(k, ".", `\.`)
+		}
+
+		flags = append(flags, "--set-string", fmt.Sprintf("%s=%s", escape(strings.Join(escapedKey, ".")), escapeSetValue(v)))
+	}
+
+	return flags, nil
+}
+
+// escapeSetValue escapes the value of `--set-string`, including the backslashes, which helm reads as the escape character
+func escapeSetValue(v string) string {
+	return escape(strings.ReplaceAll(v, `\`, `\\`))
+}
+
+// userValueKeys returns the paths of all the values set by the values files and the set values in the flags,
+// each joined with NUL, so that the values set by the user aren't overridden
+func (st *HelmState) userValueKeys(flags []string) (map[string]bool, error) {
+	keys := map[string]bool{}
+
+	var collect func(prefix []string, v interface{})
+	collect = func(prefix []string, v interface{}) {
+		keys[strings.Join(prefix, "\x00")] = true
+		if m, ok := v.(map[string]interface{}); ok {
+			for k, child := range m {
+				collect(append(append([]string{}, prefix...), k), child)
+			}
+		}
+	}
+
+	for i := 0; i < len(flags)-1; i++ {
+		switch flags[i] {
+		case "--values":
+			bs, err := st.fs.ReadFile(flags[i+1])
+			if err != nil {
+				return nil, err
+			}
+			var m map[string]interface{}
+			if err := k8syaml.Unmarshal(bs, &m); err != nil {
+				return nil, fmt.Errorf("parsing %s: %v", flags[i+1], err)
+			}
+			for k, v := range m {
+				collect([]string{k}, v)
+			}
+		case "--set", "--set-string", "--set-file":
+			for _, kv := range splitSetFlag(flags[i+1]) {
+				k, _, _ := strings.Cut(kv, "=")
+				collect(maputil.ParseKey(k), nil)
+			}
+		}
+	}
+
+	return keys, nil
+}
+
+// splitSetFlag splits the value of `--set` into the `key=value` pairs at the unescaped commas
+func splitSetFlag(s string) []string {
+	var (
+		pairs   []string
+		cur     strings.Builder
+		escaped bool
+		depth   int
+	)
+
+	for _, r := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '{':
+			depth++
+		case r == '}':
+			depth--
+		case r == ',' && depth == 0:
+			pairs = append(pairs, cur.String())
+			cur.Reset()
+			continue
+		}
+		cur.WriteRune(r)
+	}
+
+	return append(pairs, cur.String())
+}
+
+// currentFieldValue returns the value of the field of the resource in the cluster, decoding the data of Secrets
+func (st *HelmState) currentFieldValue(release *ReleaseSpec, r IgnoreDiffSpec) (string, bool, error) {
+	args := []string{"get", r.Kind, r.Name, "--ignore-not-found", "-o", "jsonpath={." + r.Field + "}"}
+
+	if release.Namespace != "" {
+		args = append(args, "--namespace", release.Namespace)
+	}
+
+	kubeContext := release.KubeContext
+	if kubeContext == "" {
+		kubeContext = st.HelmDefaults.KubeContext
+	}
+	if kubeContext != "" {
+		args = append(args, "--context", kubeContext)
+	}
+
+	out, err := st.execKubectl(args)
+	if err != nil {
+		return "", false, fmt.Errorf("looking up %s of %s/%s: %v: %s", r.Field, r.Kind, r.Name, err, strings.TrimSpace(string(out)))
+	}
+
+	v := strings.TrimSpace(string(out))
+	if v == "" {
+		return "", false, nil
+	}
+
+	if r.Kind == "Secret" && strings.HasPrefix(r.Field, "data.") {
+		decoded, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return "", false, fmt.Errorf("decoding %s of %s/%s: %v", r.Field, r.Kind, r.Name, err)
+		}
+		v = string(decoded)
+	}
+
+	return v, true, nil
+}
@@ -0,0 +1,171 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/envvar"
+	"github.com/helmfile/helmfile/pkg/exectest"
+	"github.com/helmfile/helmfile/pkg/filesystem"
+)
+
+func TestFilterIgnoredDiffs(t *testing.T) {
+	rules := []IgnoreDiffSpec{
+		{Kind: "Secret", Name: "postgresql", Field: "data.postgres-password"},
+		{Kind: "MutatingWebhookConfiguration", Field: "webhooks[0].clientConfig.caBundle"},
+	}
+
+	secret := `default, postgresql, Secret (v1) has changed:
+  # Source: postgresql/templates/secrets.yaml
+  apiVersion: v1
+  kind: Secret
+  data:
+-   postgres-password: 'REDACTED # (10 bytes)'
++   postgres-password: 'REDACTED # (10 bytes)'
+  type: Opaque
+`
+
+	webhook := `, cert-manager-webhook, MutatingWebhookConfiguration (admissionregistration.k8s.io) has changed:
+  webhooks:
+    - clientConfig:
+-       caBundle: LS0tLS1CRUdJTi...
++       caBundle: LS0tLS1CRUdJTk...
+`
+
+	deployment := `default, postgresql, StatefulSet (apps) has changed:
+  spec:
+-   replicas: 1
++   replicas: 2
+`
+
+	added := `default, postgresql-metrics, Service (v1) has been added:
++ apiVersion: v1
++ kind: Service
+`
+
+	t.Run("only ignored changes", func(t *testing.T) {
+		out, changed := filterIgnoredDiffs([]byte("Comparing release=postgresql, chart=bitnami/postgresql\n"+secret+webhook), rules)
+		require.False(t, changed)
+		require.Equal(t, "Comparing release=postgresql, chart=bitnami/postgresql\n", string(out))
+	})
+
+	t.Run("other changes", func(t *testing.T) {
+		out, changed := filterIgnoredDiffs([]byte(secret+deployment+added), rules)
+		require.True(t, changed)
+		require.Equal(t, deployment+added, string(out))
+	})
+
+	t.Run("other secret", func(t *testing.T) {
+		in := `default, other, Secret (v1) has changed:
+  data:
+-   postgres-password: 'REDACTED # (10 bytes)'
++   postgres-password: 'REDACTED # (10 bytes)'
+`
+		out, changed := filterIgnoredDiffs([]byte(in), rules)
+		require.True(t, changed)
+		require.Equal(t, in, string(out))
+	})
+}
+
+func TestFilterIgnoredDiffs_Paths(t *testing.T) {
+	rules := []IgnoreDiffSpec{
+		{Kind: "Secret", Name: "tls", Field: `data.ca\.crt`},
+	}
+
+	t.Run("block scalar", func(t *testing.T) {
+		in := `default, tls, Secret (v1) has changed:
+  data:
+-   ca.crt: |
+-     AAAA
+-     BBBB
++   ca.crt: |
++     CCCC
++     DDDD
+  type: kubernetes.io/tls
+`
+		out, changed := filterIgnoredDiffs([]byte(in), rules)
+		require.False(t, changed)
+		require.Empty(t, string(out))
+	})
+
+	t.Run("same key at another path", func(t *testing.T) {
+		in := `default, tls, Secret (v1) has changed:
+  metadata:
+    annotations:
+-     ca.crt: old
++     ca.crt: new
+  data:
+    ca.crt: AAAA
+`
+		out, changed := filterIgnoredDiffs([]byte(in), rules)
+		require.True(t, changed)
+		require.Equal(t, in, string(out))
+	})
+
+	t.Run("unparsable", func(t *testing.T) {
+		in := `default, tls, Secret (v1) has changed:
+...
+-   ca.crt: AAAA
++   ca.crt: BBBB
+`
+		out, changed := filterIgnoredDiffs([]byte(in), rules)
+		require.True(t, changed)
+		require.Equal(t, in, string(out))
+	})
+}
+
+func TestIgnoreDiffSpec_validate(t *testing.T) {
+	require.NoError(t, IgnoreDiffSpec{Kind: "Secret", Field: "data.password"}.validate())
+	require.EqualError(t, IgnoreDiffSpec{Kind: "Secret"}.validate(), "kind and field are required")
+	require.EqualError(t, IgnoreDiffSpec{Kind: "Secret", Field: "data.password", PreserveValue: "auth.password"}.validate(), "name is required to preserve the value")
+}
+
+func TestPreservedValuesFlags(t *testing.T) {
+	t.Setenv(envvar.KubectlBinary, "")
+
+	r := &exectest.Runner{
+		Outputs: map[string]string{
+			`kubectl get Secret postgresql --ignore-not-found -o jsonpath={.data.postgres-password} --namespace db --context prod`: "c2VjcmV0",
+		},
+	}
+
+	st := &HelmState{
+		ReleaseSetSpec: ReleaseSetSpec{
+			HelmDefaults: HelmSpec{KubeContext: "prod"},
+		},
+		fs:     filesystem.DefaultFileSystem(),
+		logger: logger,
+		runner: r,
+	}
+
+	release := &ReleaseSpec{
+		Name:      "postgresql",
+		Namespace: "db",
+		IgnoreDiffs: []IgnoreDiffSpec{
+			{Kind: "Secret", Name: "postgresql", Field: "data.postgres-password", PreserveValue: "auth.postgresPassword"},
+			{Kind: "Secret", Name: "postgresql-replication", Field: "data.replication-password", PreserveValue: "auth.replicationPassword"},
+		},
+	}
+
+	flags, err := st.preservedValuesFlags(release, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"--set-string", "auth.postgresPassword=secret"}, flags)
+
+	valuesFile := filepath.Join(t.TempDir(), "values.yaml")
+	require.NoError(t, os.WriteFile(valuesFile, []byte("auth:\n  postgresPassword: mine\n"), 0600))
+
+	flags, err = st.preservedValuesFlags(release, []string{"--namespace", "db", "--values", valuesFile})
+	require.NoError(t, err)
+	require.Empty(t, flags, "the value set by the user must not be overridden")
+
+	flags, err = st.preservedValuesFlags(release, []string{"--set", `auth.postgresPassword=mine\,too,image.tag=1`})
+	require.NoError(t, err)
+	require.Empty(t, flags, "the value set by the user must not be overridden")
+}
+
+func TestEscapeSetValue(t *testing.T) {
+	require.Equal(t, `a\,b\{c\}\\d`, escapeSetValue(`a,b{c}\d`))
+}
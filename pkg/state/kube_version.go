@@ -0,0 +1,229 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/Masterminds/semver/v3"
+	"helm.sh/helm/v3/pkg/chart"
+	k8syaml "sigs.k8s.io/yaml"
+
+	"github.com/helmfile/helmfile/pkg/helmexec"
+)
+
+// The modes of checking the kubeVersion constraints of the charts against the Kubernetes versions they are deployed to
+const (
+	KubeVersionCheckOff  = "off"
+	KubeVersionCheckWarn = "warn"
+	KubeVersionCheckFail = "fail"
+)
+
+// KubeVersionChecks is the modes of checking the kubeVersion constraints of the charts
+var KubeVersionChecks = []string{KubeVersionCheckOff, KubeVersionCheckWarn, KubeVersionCheckFail}
+
+// ValidateKubeVersionCheck returns an error if the mode isn't one of KubeVersionChecks
+func ValidateKubeVersionCheck(mode string) error {
+	for _, m := range KubeVersionChecks {
+		if mode == m {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown kube version check %q: must be one of %s", mode, strings.Join(KubeVersionChecks, ", "))
+}
+
+// KubeVersionIncompatibility is a release whose chart doesn't support the Kubernetes version it is deployed to
+type KubeVersionIncompatibility struct {
+	Release string
+	Chart   string
+	// Constraint is the kubeVersion in Chart.yaml
+	Constraint  string
+	KubeVersion string
+}
+
+func (i KubeVersionIncompatibility) String() string {
+	return fmt.Sprintf("release %s: chart %s requires kubeVersion %q, which the target Kubernetes version %s doesn't satisfy", i.Release, i.Chart, i.Constraint, i.KubeVersion)
+}
+
+// KubeVersionChecker remembers the releases checked, the kubeVersion constraints of the charts and the versions of the clusters,
+// so that each release is checked once, and each chart and cluster is looked up once, in a run across the helmfiles
+type KubeVersionChecker struct {
+	mu sync.Mutex
+
+	checked         map[string]bool
+	constraints     map[string]string
+	clusterVersions map[string]string
+}
+
+// NewKubeVersionChecker returns a KubeVersionChecker that has checked nothing yet
+func NewKubeVersionChecker() *KubeVersionChecker {
+	return &KubeVersionChecker{
+		checked:         map[string]bool{},
+		constraints:     map[string]string{},
+		clusterVersions: map[string]string{},
+	}
+}
+
+// CheckKubeVersions returns the releases whose charts declare the kubeVersion constraints that the Kubernetes versions they are deployed to don't satisfy,
+// the same way as helm checks them on installing the charts.
+// The Kubernetes version is the kubeVersion of the release or the helmfile if set, or else the version of the cluster.
+// The releases whose charts or Kubernetes versions can't be looked up are skipped, as helm will check them anyway,
+// and so are the releases already checked by the checker.
+func (st *HelmState) CheckKubeVersions(helm helmexec.Interface, releases []ReleaseSpec, checker *KubeVersionChecker) []KubeVersionIncompatibility {
+	var incompatibilities []KubeVersionIncompatibility
+
+	checker.mu.Lock()
+	defer checker.mu.Unlock()
+
+	constraints := checker.constraints
+	clusterVersions := checker.clusterVersions
+
+	for i := range releases {
+		r := &releases[i]
+
+		if !r.Desired() {
+			continue
+		}
+
+		id := ReleaseToID(r)
+		if checker.checked[id] {
+			continue
+		}
+		checker.checked[id] = true
+
+		chartName := r.ChartPathOrName()
+
+		key := chartName + "@" + r.Version
+		constraint, ok := constraints[key]
+		if !ok {
+			metadata, err := st.chartMetadata(helm, r)
+			if err != nil {
+				st.logger.Debugf("skipped checking the kubeVersion of chart %s of release %s: %v", chartName, r.Name, err)
+			}
+			constraint = metadata.KubeVersion
+			constraints[key] = constraint
+		}
+
+		if constraint == "" {
+			continue
+		}
+
+		kubeVersion := r.KubeVersion
+		if kubeVersion == "" {
+			kubeVersion = st.KubeVersion
+		}
+
+		if kubeVersion == "" {
+			kubeContext := r.KubeContext
+			if kubeContext == "" {
+				kubeContext = st.HelmDefaults.KubeContext
+			}
+
+			v, ok := clusterVersions[kubeContext]
+			if !ok {
+				var err error
+				v, err = st.clusterKubeVersion(kubeContext)
+				if err != nil {
+					st.logger.Warnf("unable to get the Kubernetes version of kube context %q to check the kubeVersion of the charts: %v", kubeContext, err)
+				}
+				clusterVersions[kubeContext] = v
+			}
+
+			kubeVersion = v
+		}
+
+		if kubeVersion == "" {
+			continue
+		}
+
+		compatible, err := isCompatibleKubeVersion(constraint, kubeVersion)
+		if err != nil {
+			st.logger.Debugf("skipped checking the kubeVersion of chart %s of release %s: %v", chartName, r.Name, err)
+			continue
+		}
+
+		if !compatible {
+			incompatibilities = append(incompatibilities, KubeVersionIncompatibility{
+				Release:     id,
+				Chart:       chartName,
+				Constraint:  constraint,
+				KubeVersion: kubeVersion,
+			})
+		}
+	}
+
+	return incompatibilities
+}
+
+// chartMetadata returns the metadata of the chart of the release, read from Chart.yaml of the local chart,
+// or by `helm show chart` for the remote one
+func (st *HelmState) chartMetadata(helm helmexec.Interface, r *ReleaseSpec) (chart.Metadata, error) {
+	var metadata chart.Metadata
+
+	chartName := r.ChartPathOrName()
+
+	if dir := normalizeChart(st.basePath, chartName); st.fs.DirectoryExistsAt(dir) {
+		bs, err := st.fs.ReadFile(filepath.Join(dir, "Chart.yaml"))
+		if err != nil {
+			return metadata, err
+		}
+		if err := k8syaml.Unmarshal(bs, &metadata); err != nil {
+			return metadata, err
+		}
+		return metadata, nil
+	}
+
+	flags := st.chartVersionFlags(r)
+
+	// The OCI charts are shown with the TLS settings of their registries, as they are pulled,
+	// while the charts in the repositories use the settings the repositories were added with
+	if ref, ok := strings.CutPrefix(chartName, "oci://"); ok {
+		if reg := st.getRegistry(ref); reg != nil {
+			flags = append(flags, r.overrideOCITransportFlags(reg.pullFlags())...)
+		}
+	}
+
+	return helm.ShowChart(st.runContext(), chartName, flags...)
+}
+
+// clusterKubeVersion returns the version of the Kubernetes cluster of the kube context
+func (st *HelmState) clusterKubeVersion(kubeContext string) (string, error) {
+	args := []string{"version", "-o", "json"}
+	if kubeContext != "" {
+		args = append(args, "--context", kubeContext)
+	}
+
+	out, err := st.execKubectl(args)
+	if err != nil {
+		return "", fmt.Errorf("%v: %s", err, lastLine(out, err))
+	}
+
+	var v struct {
+		ServerVersion struct {
+			GitVersion string `json:"gitVersion"`
+		} `json:"serverVersion"`
+	}
+
+	if err := json.Unmarshal(out, &v); err != nil {
+		return "", fmt.Errorf("parsing the output of kubectl version: %v", err)
+	}
+
+	return v.ServerVersion.GitVersion, nil
+}
+
+// isCompatibleKubeVersion returns true if the Kubernetes version satisfies the constraint, like helm's chartutil.IsCompatibleRange
+func isCompatibleKubeVersion(constraint, kubeVersion string) (bool, error) {
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return false, err
+	}
+
+	v, err := semver.NewVersion(kubeVersion)
+	if err != nil {
+		return false, err
+	}
+
+	return c.Check(v), nil
+}
@@ -0,0 +1,75 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/envvar"
+	"github.com/helmfile/helmfile/pkg/exectest"
+	"github.com/helmfile/helmfile/pkg/filesystem"
+)
+
+func TestHelmState_CheckKubeVersions(t *testing.T) {
+	t.Setenv(envvar.KubectlBinary, "")
+
+	basePath := t.TempDir()
+
+	writeChart := func(name, kubeVersion string) {
+		t.Helper()
+		dir := filepath.Join(basePath, "charts", name)
+		require.NoError(t, os.MkdirAll(dir, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "Chart.yaml"), []byte("apiVersion: v2\nname: "+name+"\nversion: 0.1.0\nkubeVersion: \""+kubeVersion+"\"\n"), 0644))
+	}
+
+	writeChart("legacy", "<1.22.0-0")
+	writeChart("modern", ">=1.25.0-0")
+	writeChart("any", "")
+
+	r := &exectest.Runner{
+		Outputs: map[string]string{
+			"kubectl version -o json --context prod": `{"serverVersion":{"gitVersion":"v1.27.3-eks-a5565ad"}}`,
+		},
+	}
+
+	uninstalled := false
+
+	st := &HelmState{
+		basePath: basePath,
+		fs:       filesystem.DefaultFileSystem(),
+		ReleaseSetSpec: ReleaseSetSpec{
+			HelmDefaults: HelmSpec{KubeContext: "prod"},
+		},
+		logger: logger,
+		runner: r,
+	}
+
+	releases := []ReleaseSpec{
+		{Name: "ingress", Namespace: "kube-system", Chart: "./charts/legacy"},
+		{Name: "web", Chart: "./charts/modern"},
+		{Name: "api", Chart: "./charts/any"},
+		{Name: "old", Chart: "./charts/legacy", Installed: &uninstalled},
+		{Name: "pinned", Chart: "./charts/modern", KubeVersion: "1.24.0"},
+		{Name: "remote", Chart: "stable/unknown"},
+	}
+
+	checker := NewKubeVersionChecker()
+
+	incompatibilities := st.CheckKubeVersions(&exectest.Helm{}, releases, checker)
+
+	require.Equal(t, []KubeVersionIncompatibility{
+		{Release: "kube-system/ingress", Chart: "./charts/legacy", Constraint: "<1.22.0-0", KubeVersion: "v1.27.3-eks-a5565ad"},
+		{Release: "pinned", Chart: "./charts/modern", Constraint: ">=1.25.0-0", KubeVersion: "1.24.0"},
+	}, incompatibilities)
+
+	require.Empty(t, st.CheckKubeVersions(&exectest.Helm{}, releases, checker), "the releases should be checked once in a run")
+
+	require.Equal(t, []string{"kubectl version -o json --context prod"}, r.Calls)
+}
+
+func TestValidateKubeVersionCheck(t *testing.T) {
+	require.NoError(t, ValidateKubeVersionCheck(KubeVersionCheckFail))
+	require.EqualError(t, ValidateKubeVersionCheck("error"), `unknown kube version check "error": must be one of off, warn, fail`)
+}
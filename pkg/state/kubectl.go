@@ -0,0 +1,15 @@
+package state
+
+import (
+	"github.com/helmfile/helmfile/pkg/helmexec"
+)
+
+// execKubectl runs kubectl with the args and returns its combined output
+func (st *HelmState) execKubectl(args []string) ([]byte, error) {
+	runner := st.runner
+	if runner == nil {
+		runner = helmexec.ShellRunner{Logger: st.logger}
+	}
+
+	return runner.Execute(helmexec.KubectlBinary(), args, map[string]string{}, false)
+}
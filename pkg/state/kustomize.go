@@ -0,0 +1,197 @@
+package state
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/variantdev/chartify"
+
+	"github.com/helmfile/helmfile/pkg/envvar"
+	"github.com/helmfile/helmfile/pkg/yaml"
+)
+
+// DefaultKustomizeBinary is the kustomize binary chartify runs to patch the charts unless HELMFILE_KUSTOMIZE_BINARY is set
+const DefaultKustomizeBinary = "kustomize"
+
+// KustomizeSpec is the kustomization applied to the manifests rendered by helm for the release,
+// which helmfile turns into the kustomize transformers chartify applies to the chart
+type KustomizeSpec struct {
+	// Patches are the patches of the resources, each being a strategic merge patch or a JSON 6902 patch
+	Patches []KustomizePatch `yaml:"patches,omitempty"`
+	// PatchesStrategicMerge are the strategic merge patches, each being the resource to patch with the fields to change as a YAML hash
+	PatchesStrategicMerge []map[string]interface{} `yaml:"patchesStrategicMerge,omitempty"`
+	// Images overrides the names, the tags, and the digests of the images of the resources
+	Images []KustomizeImage `yaml:"images,omitempty"`
+}
+
+// KustomizePatch is a patch of the resources matching the target, given inline or as a file relative to the helmfile
+type KustomizePatch struct {
+	// Patch is the strategic merge patch or the JSON 6902 patch, either as a string or as YAML
+	Patch interface{} `yaml:"patch,omitempty"`
+	// Path is the file containing the patch, which is exclusive with Patch
+	Path string `yaml:"path,omitempty"`
+	// Target selects the resources to patch, which is required for the JSON 6902 patches
+	Target *KustomizePatchTarget `yaml:"target,omitempty"`
+}
+
+// KustomizePatchTarget selects the resources to patch, like the target of the patches of kustomize
+type KustomizePatchTarget struct {
+	Group              string `yaml:"group,omitempty"`
+	Version            string `yaml:"version,omitempty"`
+	Kind               string `yaml:"kind,omitempty"`
+	Name               string `yaml:"name,omitempty"`
+	Namespace          string `yaml:"namespace,omitempty"`
+	LabelSelector      string `yaml:"labelSelector,omitempty"`
+	AnnotationSelector string `yaml:"annotationSelector,omitempty"`
+}
+
+// KustomizeImage overrides the image of the name in the resources, like the images of kustomize
+type KustomizeImage struct {
+	Name    string `yaml:"name"`
+	NewName string `yaml:"newName,omitempty"`
+	NewTag  string `yaml:"newTag,omitempty"`
+	Digest  string `yaml:"digest,omitempty"`
+}
+
+// kustomizeTransformer is a builtin transformer of kustomize, generated for the kustomize block of a release
+type kustomizeTransformer struct {
+	APIVersion string                `yaml:"apiVersion"`
+	Kind       string                `yaml:"kind"`
+	Metadata   map[string]string     `yaml:"metadata"`
+	Patch      string                `yaml:"patch,omitempty"`
+	Target     *KustomizePatchTarget `yaml:"target,omitempty"`
+	ImageTag   *KustomizeImage       `yaml:"imageTag,omitempty"`
+}
+
+// kustomizeTransformers returns the PatchTransformers and the ImageTagTransformers of the kustomize block of the release,
+// with the patches in the files inlined, as kustomize refuses the files outside of the directory of the kustomization
+func (st *HelmState) kustomizeTransformers(release *ReleaseSpec) ([]kustomizeTransformer, error) {
+	var transformers []kustomizeTransformer
+
+	patchTransformer := func(patch string, target *KustomizePatchTarget) kustomizeTransformer {
+		return kustomizeTransformer{
+			APIVersion: "builtin",
+			Kind:       "PatchTransformer",
+			Metadata:   map[string]string{"name": fmt.Sprintf("%s-patch-%d", release.Name, len(transformers))},
+			Patch:      patch,
+			Target:     target,
+		}
+	}
+
+	for i, p := range release.Kustomize.Patches {
+		var patch string
+
+		switch {
+		case p.Path != "" && p.Patch != nil:
+			return nil, fmt.Errorf("kustomize.patches[%d]: path and patch cannot be used together", i)
+		case p.Path != "":
+			bs, err := st.fs.ReadFile(st.storage().normalizePath(p.Path))
+			if err != nil {
+				return nil, fmt.Errorf("kustomize.patches[%d]: reading %s: %v", i, p.Path, err)
+			}
+			patch = string(bs)
+		case p.Patch != nil:
+			s, err := kustomizePatchString(p.Patch)
+			if err != nil {
+				return nil, fmt.Errorf("kustomize.patches[%d]: %v", i, err)
+			}
+			patch = s
+		default:
+			return nil, fmt.Errorf("kustomize.patches[%d]: either path or patch is required", i)
+		}
+
+		transformers = append(transformers, patchTransformer(patch, p.Target))
+	}
+
+	for i, p := range release.Kustomize.PatchesStrategicMerge {
+		s, err := kustomizePatchString(p)
+		if err != nil {
+			return nil, fmt.Errorf("kustomize.patchesStrategicMerge[%d]: %v", i, err)
+		}
+
+		transformers = append(transformers, patchTransformer(s, nil))
+	}
+
+	for i := range release.Kustomize.Images {
+		img := release.Kustomize.Images[i]
+		if img.Name == "" {
+			return nil, fmt.Errorf("kustomize.images[%d]: name is required", i)
+		}
+
+		transformers = append(transformers, kustomizeTransformer{
+			APIVersion: "builtin",
+			Kind:       "ImageTagTransformer",
+			Metadata:   map[string]string{"name": fmt.Sprintf("%s-image-%d", release.Name, i)},
+			ImageTag:   &img,
+		})
+	}
+
+	return transformers, nil
+}
+
+func kustomizePatchString(patch interface{}) (string, error) {
+	if s, ok := patch.(string); ok {
+		return s, nil
+	}
+
+	bs, err := yaml.Marshal(patch)
+	if err != nil {
+		return "", err
+	}
+
+	return string(bs), nil
+}
+
+// generateKustomizeTransformers writes the transformers of the kustomize block of the release to a new directory in the temporary files directory,
+// and returns the files, which chartify applies to the manifests rendered by helm along with the transformers of the release
+func (st *HelmState) generateKustomizeTransformers(release *ReleaseSpec) ([]string, error) {
+	transformers, err := st.kustomizeTransformers(release)
+	if err != nil {
+		return nil, fmt.Errorf("release %q: %v", release.Name, err)
+	}
+
+	if len(transformers) == 0 {
+		return nil, nil
+	}
+
+	dir, err := st.MkdirTemp(fmt.Sprintf("%s-kustomize", release.Name))
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+
+	for i, t := range transformers {
+		bs, err := yaml.Marshal(t)
+		if err != nil {
+			return files, err
+		}
+
+		file := filepath.Join(dir, fmt.Sprintf("transformer.%d.yaml", i))
+		if err := os.WriteFile(file, bs, 0600); err != nil {
+			return files, err
+		}
+		files = append(files, file)
+	}
+
+	st.logger.Debugf("generated the kustomize transformers %v for the kustomize block of release %q", files, release.Name)
+
+	return files, nil
+}
+
+// kustomizeBinary returns the kustomize binary chartify runs
+func kustomizeBinary() string {
+	if bin := os.Getenv(envvar.KustomizeBinary); bin != "" {
+		return bin
+	}
+	return DefaultKustomizeBinary
+}
+
+// withKustomizeBinary makes chartify run the kustomize binary, which chartify has no option for
+func withKustomizeBinary(bin string) chartify.Option {
+	return func(r *chartify.Runner) error {
+		r.KustomizeBinary = bin
+		return nil
+	}
+}
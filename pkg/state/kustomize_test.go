@@ -0,0 +1,98 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/envvar"
+	"github.com/helmfile/helmfile/pkg/filesystem"
+	"github.com/helmfile/helmfile/pkg/yaml"
+)
+
+func TestHelmState_KustomizeTransformers(t *testing.T) {
+	basePath := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(basePath, "replicas.yaml"), []byte("- op: replace\n  path: /spec/replicas\n  value: 3\n"), 0644))
+
+	newState := func(release ReleaseSpec) *HelmState {
+		return &HelmState{
+			basePath: basePath,
+			fs:       filesystem.DefaultFileSystem(),
+			logger:   logger,
+			WorkDir:  t.TempDir(),
+			ReleaseSetSpec: ReleaseSetSpec{
+				Releases: []ReleaseSpec{release},
+			},
+		}
+	}
+
+	t.Run("generates the transformers of the kustomization", func(t *testing.T) {
+		st := newState(ReleaseSpec{
+			Name:  "web",
+			Chart: "charts/web",
+			Kustomize: &KustomizeSpec{
+				Patches: []KustomizePatch{
+					{Path: "replicas.yaml", Target: &KustomizePatchTarget{Kind: "Deployment", Name: "web"}},
+				},
+				PatchesStrategicMerge: []map[string]interface{}{
+					{"apiVersion": "v1", "kind": "Service", "metadata": map[string]interface{}{"name": "web", "annotations": map[string]interface{}{"team": "web"}}},
+				},
+				Images: []KustomizeImage{{Name: "nginx", NewTag: "1.25"}},
+			},
+		})
+
+		files, err := st.generateKustomizeTransformers(&st.Releases[0])
+		require.NoError(t, err)
+		require.Len(t, files, 3)
+
+		transformers := make([]kustomizeTransformer, len(files))
+		for i, f := range files {
+			bs, err := os.ReadFile(f)
+			require.NoError(t, err)
+			require.NoError(t, yaml.Unmarshal(bs, &transformers[i]))
+		}
+
+		require.Equal(t, kustomizeTransformer{
+			APIVersion: "builtin",
+			Kind:       "PatchTransformer",
+			Metadata:   map[string]string{"name": "web-patch-0"},
+			Patch:      "- op: replace\n  path: /spec/replicas\n  value: 3\n",
+			Target:     &KustomizePatchTarget{Kind: "Deployment", Name: "web"},
+		}, transformers[0])
+		require.Equal(t, "PatchTransformer", transformers[1].Kind)
+		require.True(t, strings.Contains(transformers[1].Patch, "kind: Service"), transformers[1].Patch)
+		require.Nil(t, transformers[1].Target)
+		require.Equal(t, kustomizeTransformer{
+			APIVersion: "builtin",
+			Kind:       "ImageTagTransformer",
+			Metadata:   map[string]string{"name": "web-image-0"},
+			ImageTag:   &KustomizeImage{Name: "nginx", NewTag: "1.25"},
+		}, transformers[2])
+
+		st.removeFiles(files)
+		_, err = os.Stat(filepath.Dir(files[0]))
+		require.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("fails with a patch without path nor patch", func(t *testing.T) {
+		st := newState(ReleaseSpec{
+			Name:      "web",
+			Chart:     "charts/web",
+			Kustomize: &KustomizeSpec{Patches: []KustomizePatch{{Target: &KustomizePatchTarget{Kind: "Deployment"}}}},
+		})
+
+		_, err := st.generateKustomizeTransformers(&st.Releases[0])
+		require.EqualError(t, err, `release "web": kustomize.patches[0]: either path or patch is required`)
+	})
+}
+
+func TestKustomizeBinary(t *testing.T) {
+	t.Setenv(envvar.KustomizeBinary, "")
+	require.Equal(t, DefaultKustomizeBinary, kustomizeBinary())
+
+	t.Setenv(envvar.KustomizeBinary, "/usr/local/bin/kustomize")
+	require.Equal(t, "/usr/local/bin/kustomize", kustomizeBinary())
+}
@@ -0,0 +1,366 @@
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	k8syaml "sigs.k8s.io/yaml"
+
+	"github.com/helmfile/helmfile/pkg/yaml"
+)
+
+// ManifestTransformer transforms the manifests of a release rendered by `helmfile template` in-process,
+// before they are written to the output directory or stdout.
+// The transformations of a transformer are applied in the order of JSONPatch, Namespace, Labels and Annotations.
+type ManifestTransformer struct {
+	// Target selects the resources to transform, which defaults to all the resources of the release
+	Target *ManifestTransformerTarget `yaml:"target,omitempty"`
+	// JSONPatch is the JSON 6902 patch applied to the resources
+	JSONPatch []JSONPatchOperation `yaml:"jsonPatch,omitempty"`
+	// Namespace rewrites the namespace of the namespaced resources, along with the namespaces of the service accounts
+	// in the subjects of the role bindings that are the namespace of the release
+	Namespace string `yaml:"namespace,omitempty"`
+	// Labels are added to the labels of the resources, overriding the ones of the same keys
+	Labels map[string]string `yaml:"labels,omitempty"`
+	// Annotations are added to the annotations of the resources, overriding the ones of the same keys
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// ManifestTransformerTarget selects the resources whose fields equal the non-empty ones
+type ManifestTransformerTarget struct {
+	APIVersion string `yaml:"apiVersion,omitempty"`
+	Kind       string `yaml:"kind,omitempty"`
+	Name       string `yaml:"name,omitempty"`
+	Namespace  string `yaml:"namespace,omitempty"`
+}
+
+// JSONPatchOperation is an operation of a JSON 6902 patch
+type JSONPatchOperation struct {
+	Op    string      `yaml:"op"`
+	Path  string      `yaml:"path"`
+	From  string      `yaml:"from,omitempty"`
+	Value interface{} `yaml:"value,omitempty"`
+}
+
+// clusterScopedKinds are the kinds of the built-in cluster-scoped resources, whose namespaces aren't rewritten
+var clusterScopedKinds = map[string]bool{
+	"APIService":                     true,
+	"CSIDriver":                      true,
+	"CSINode":                        true,
+	"CertificateSigningRequest":      true,
+	"ClusterRole":                    true,
+	"ClusterRoleBinding":             true,
+	"CustomResourceDefinition":       true,
+	"FlowSchema":                     true,
+	"IngressClass":                   true,
+	"MutatingWebhookConfiguration":   true,
+	"Namespace":                      true,
+	"Node":                           true,
+	"PersistentVolume":               true,
+	"PriorityClass":                  true,
+	"PriorityLevelConfiguration":     true,
+	"RuntimeClass":                   true,
+	"StorageClass":                   true,
+	"ValidatingWebhookConfiguration": true,
+	"VolumeAttachment":               true,
+}
+
+// manifestTransformers returns the transformers of the release, which follow the ones of helmDefaults
+func (st *HelmState) manifestTransformers(release *ReleaseSpec) []ManifestTransformer {
+	return append(append([]ManifestTransformer{}, st.HelmDefaults.ManifestTransformers...), release.ManifestTransformers...)
+}
+
+// transformManifests applies the transformers of the release to each of the manifests rendered by helm,
+// keeping the comments like `# Source:` preceding them
+func (st *HelmState) transformManifests(release *ReleaseSpec, out []byte) ([]byte, error) {
+	transformers := st.manifestTransformers(release)
+	if len(transformers) == 0 {
+		return out, nil
+	}
+
+	buf := &bytes.Buffer{}
+
+	for i, doc := range splitManifests(out) {
+		header, body := splitManifestHeader(doc)
+
+		transformed, err := transformManifest(release, body, transformers, len(st.HelmDefaults.ManifestTransformers))
+		if err != nil {
+			return nil, fmt.Errorf("transforming the manifest %d of release %q: %w", i, release.Name, err)
+		}
+
+		buf.WriteString("---\n")
+		buf.WriteString(header)
+		buf.WriteString(transformed)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// transformManifest applies the transformers, the first numDefaults of which are the ones of helmDefaults, to the manifest,
+// returning it as it is when it is empty
+func transformManifest(release *ReleaseSpec, manifest string, transformers []ManifestTransformer, numDefaults int) (string, error) {
+	js, err := k8syaml.YAMLToJSON([]byte(manifest))
+	if err != nil {
+		return "", err
+	}
+
+	var obj map[string]interface{}
+	if err := json.Unmarshal(js, &obj); err != nil || obj == nil {
+		return manifest, nil
+	}
+
+	for i, t := range transformers {
+		if !t.matches(obj) {
+			continue
+		}
+
+		if obj, err = t.apply(release, obj); err != nil {
+			if i < numDefaults {
+				return "", fmt.Errorf("helmDefaults.manifestTransformers[%d]: %w", i, err)
+			}
+			return "", fmt.Errorf("manifestTransformers[%d]: %w", i-numDefaults, err)
+		}
+	}
+
+	js, err = json.Marshal(obj)
+	if err != nil {
+		return "", err
+	}
+
+	bs, err := k8syaml.JSONToYAML(js)
+	if err != nil {
+		return "", err
+	}
+
+	return string(bs), nil
+}
+
+func (t ManifestTransformer) matches(obj map[string]interface{}) bool {
+	if t.Target == nil {
+		return true
+	}
+
+	metadata, _ := obj["metadata"].(map[string]interface{})
+
+	for _, f := range []struct{ want, got interface{} }{
+		{t.Target.APIVersion, obj["apiVersion"]},
+		{t.Target.Kind, obj["kind"]},
+		{t.Target.Name, metadata["name"]},
+		{t.Target.Namespace, metadata["namespace"]},
+	} {
+		if f.want != "" && f.want != f.got {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (t ManifestTransformer) apply(release *ReleaseSpec, obj map[string]interface{}) (map[string]interface{}, error) {
+	if len(t.JSONPatch) > 0 {
+		patched, err := applyJSONPatch(obj, t.JSONPatch)
+		if err != nil {
+			return nil, err
+		}
+		obj = patched
+	}
+
+	kind, _ := obj["kind"].(string)
+
+	if t.Namespace != "" && !clusterScopedKinds[kind] {
+		manifestMetadata(obj)["namespace"] = t.Namespace
+	}
+
+	if t.Namespace != "" && (kind == "RoleBinding" || kind == "ClusterRoleBinding") {
+		subjects, _ := obj["subjects"].([]interface{})
+		for _, s := range subjects {
+			subject, ok := s.(map[string]interface{})
+			if ok && subject["kind"] == "ServiceAccount" && subject["namespace"] == release.Namespace {
+				subject["namespace"] = t.Namespace
+			}
+		}
+	}
+
+	for field, values := range map[string]map[string]string{"labels": t.Labels, "annotations": t.Annotations} {
+		if len(values) == 0 {
+			continue
+		}
+
+		metadata := manifestMetadata(obj)
+
+		m, _ := metadata[field].(map[string]interface{})
+		if m == nil {
+			m = map[string]interface{}{}
+			metadata[field] = m
+		}
+
+		for k, v := range values {
+			m[k] = v
+		}
+	}
+
+	return obj, nil
+}
+
+// manifestMetadata returns the metadata of the resource, adding it when missing
+func manifestMetadata(obj map[string]interface{}) map[string]interface{} {
+	metadata, _ := obj["metadata"].(map[string]interface{})
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+		obj["metadata"] = metadata
+	}
+	return metadata
+}
+
+func applyJSONPatch(obj map[string]interface{}, ops []JSONPatchOperation) (map[string]interface{}, error) {
+	// The values of the operations are marshaled via YAML, as they may contain the maps with the keys of interface{} decoded from the state file
+	bs, err := yaml.Marshal(ops)
+	if err != nil {
+		return nil, err
+	}
+
+	opsJSON, err := k8syaml.YAMLToJSON(bs)
+	if err != nil {
+		return nil, err
+	}
+
+	patch, err := jsonpatch.DecodePatch(opsJSON)
+	if err != nil {
+		return nil, fmt.Errorf("decoding jsonPatch: %w", err)
+	}
+
+	doc, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err = patch.Apply(doc)
+	if err != nil {
+		return nil, fmt.Errorf("applying jsonPatch: %w", err)
+	}
+
+	var patched map[string]interface{}
+	if err := json.Unmarshal(doc, &patched); err != nil {
+		return nil, err
+	}
+
+	return patched, nil
+}
+
+// splitManifests splits the output of `helm template` into the manifests, skipping the empty ones
+func splitManifests(out []byte) []string {
+	var (
+		docs []string
+		sb   strings.Builder
+	)
+
+	flush := func() {
+		if strings.TrimSpace(sb.String()) != "" {
+			docs = append(docs, sb.String())
+		}
+		sb.Reset()
+	}
+
+	for _, l := range strings.SplitAfter(string(out), "\n") {
+		if strings.TrimRight(l, " \r\n") == "---" {
+			flush()
+			continue
+		}
+		sb.WriteString(l)
+	}
+
+	flush()
+
+	return docs
+}
+
+// splitManifestHeader splits the comment lines preceding the manifest, like `# Source: chart/templates/deployment.yaml`, from the manifest
+func splitManifestHeader(doc string) (string, string) {
+	lines := strings.SplitAfter(doc, "\n")
+
+	var i int
+	for ; i < len(lines); i++ {
+		l := strings.TrimSpace(lines[i])
+		if l != "" && !strings.HasPrefix(l, "#") {
+			break
+		}
+	}
+
+	return strings.Join(lines[:i], ""), strings.Join(lines[i:], "")
+}
+
+// manifestSource returns the template of the manifest in its `# Source:` comment added by helm, if any
+func manifestSource(doc string) string {
+	header, _ := splitManifestHeader(doc)
+
+	for _, l := range strings.Split(header, "\n") {
+		if s, ok := strings.CutPrefix(strings.TrimSpace(l), "# Source:"); ok {
+			return strings.TrimSpace(s)
+		}
+	}
+
+	return ""
+}
+
+// manifestSourcePath returns the file in dir the manifests of the template are written to,
+// refusing the templates outside of dir, like `../../.bashrc` in the `# Source:` comment of a malicious chart
+func manifestSourcePath(dir, source string) (string, error) {
+	rel := filepath.Clean(filepath.FromSlash(source))
+	if !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("writing the manifests of %q: the template is outside of %s", source, dir)
+	}
+
+	return filepath.Join(dir, rel), nil
+}
+
+// writeManifestsToDir writes the manifests to the files named after their templates in dir, like `helm template --output-dir`,
+// concatenating the ones of the same template into the same file
+func (st *HelmState) writeManifestsToDir(dir string, out []byte) error {
+	written := map[string]bool{}
+
+	for _, doc := range splitManifests(out) {
+		source := manifestSource(doc)
+		if source == "" {
+			continue
+		}
+
+		p, err := manifestSourcePath(dir, source)
+		if err != nil {
+			return err
+		}
+
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			return err
+		}
+
+		flag := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+		if written[p] {
+			flag = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+		}
+
+		f, err := os.OpenFile(p, flag, 0644)
+		if err != nil {
+			return err
+		}
+
+		_, err = f.WriteString("---\n" + doc)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+
+		if !written[p] {
+			st.logger.Debugf("wrote %s", p)
+		}
+		written[p] = true
+	}
+
+	return nil
+}
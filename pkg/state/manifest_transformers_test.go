@@ -0,0 +1,179 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/exectest"
+	"github.com/helmfile/helmfile/pkg/filesystem"
+)
+
+const transformerTestManifests = `---
+# Source: web/templates/serviceaccount.yaml
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: web
+  namespace: default
+---
+# Source: web/templates/clusterrolebinding.yaml
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: web
+subjects:
+- kind: ServiceAccount
+  name: web
+  namespace: default
+---
+# Source: web/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: default
+spec:
+  replicas: 1
+`
+
+func TestHelmState_transformManifests(t *testing.T) {
+	st := &HelmState{
+		ReleaseSetSpec: ReleaseSetSpec{
+			HelmDefaults: HelmSpec{
+				ManifestTransformers: []ManifestTransformer{
+					{Labels: map[string]string{"team": "web"}},
+				},
+			},
+		},
+	}
+
+	release := &ReleaseSpec{
+		Name:      "web",
+		Namespace: "default",
+		ManifestTransformers: []ManifestTransformer{
+			{Namespace: "web"},
+			{
+				Target:      &ManifestTransformerTarget{Kind: "Deployment"},
+				JSONPatch:   []JSONPatchOperation{{Op: "replace", Path: "/spec/replicas", Value: 3}},
+				Annotations: map[string]string{"owner": "platform"},
+			},
+		},
+	}
+
+	out, err := st.transformManifests(release, []byte(transformerTestManifests))
+	require.NoError(t, err)
+	require.Equal(t, `---
+# Source: web/templates/serviceaccount.yaml
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  labels:
+    team: web
+  name: web
+  namespace: web
+---
+# Source: web/templates/clusterrolebinding.yaml
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  labels:
+    team: web
+  name: web
+subjects:
+- kind: ServiceAccount
+  name: web
+  namespace: web
+---
+# Source: web/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  annotations:
+    owner: platform
+  labels:
+    team: web
+  name: web
+  namespace: web
+spec:
+  replicas: 3
+`, string(out))
+
+	t.Run("fails with an invalid patch", func(t *testing.T) {
+		release := &ReleaseSpec{
+			Name: "web",
+			ManifestTransformers: []ManifestTransformer{
+				{JSONPatch: []JSONPatchOperation{{Op: "remove", Path: "/spec/missing"}}},
+			},
+		}
+
+		_, err := st.transformManifests(release, []byte(transformerTestManifests))
+		require.ErrorContains(t, err, `transforming the manifest 0 of release "web": manifestTransformers[0]: applying jsonPatch`)
+	})
+}
+
+func TestHelmState_templateRelease_ManifestTransformers(t *testing.T) {
+	dir := t.TempDir()
+
+	st := &HelmState{
+		fs:     filesystem.DefaultFileSystem(),
+		logger: logger,
+	}
+
+	helm := &manifestHelm{
+		Helm:     exectest.Helm{Version: semver.MustParse("3.10.0")},
+		manifest: transformerTestManifests + "---\n# Source: web/templates/deployment.yaml\napiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: worker\n",
+	}
+
+	release := &ReleaseSpec{
+		Name:  "web",
+		Chart: "charts/web",
+		ManifestTransformers: []ManifestTransformer{
+			{Target: &ManifestTransformerTarget{Kind: "Deployment", Name: "worker"}, Labels: map[string]string{"role": "worker"}},
+		},
+	}
+
+	require.NoError(t, st.templateRelease(helm, release, nil, nil, &TemplateOpts{}, dir))
+
+	bs, err := os.ReadFile(filepath.Join(dir, "web", "templates", "deployment.yaml"))
+	require.NoError(t, err)
+	require.Equal(t, `---
+# Source: web/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: default
+spec:
+  replicas: 1
+---
+# Source: web/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  labels:
+    role: worker
+  name: worker
+`, string(bs))
+
+	require.FileExists(t, filepath.Join(dir, "web", "templates", "serviceaccount.yaml"))
+	require.FileExists(t, filepath.Join(dir, "web", "templates", "clusterrolebinding.yaml"))
+}
+
+func TestManifestSourcePath(t *testing.T) {
+	dir := filepath.Join("out", "web")
+
+	for _, source := range []string{"web/templates/deployment.yaml", "web/templates/../templates/service.yaml"} {
+		p, err := manifestSourcePath(dir, source)
+		require.NoError(t, err, source)
+		require.Equal(t, filepath.Join(dir, filepath.Clean(filepath.FromSlash(source))), p)
+	}
+
+	for _, source := range []string{"../../.bashrc", "web/../../secret.yaml", "/etc/passwd", ".."} {
+		_, err := manifestSourcePath(dir, source)
+		require.ErrorContains(t, err, "the template is outside of "+dir, source)
+	}
+}
@@ -0,0 +1,162 @@
+package state
+
+import (
+	"fmt"
+	"strings"
+)
+
+// needsSelectorPrefix marks a `needs` entry that refers to the releases matching a label selector
+// instead of a single release.
+const needsSelectorPrefix = "selector:"
+
+// Needs is the list of releases a release depends on.
+// Each entry is either the name of a release, optionally prefixed with the kubecontext and namespace,
+// a map like `{selector: tier=infra}` that refers to every release matching the label selector,
+// or a release of another helmfile like `../platform/helmfile.yaml//kube-system/ingress-nginx`
+// or `{helmfile: platform, release: kube-system/ingress-nginx}`. See ExternalNeed.
+type Needs []string
+
+type needsMap struct {
+	Selector string `yaml:"selector"`
+	Helmfile string `yaml:"helmfile"`
+	Release  string `yaml:"release"`
+}
+
+func (n *Needs) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var entries []needsEntry
+	if err := unmarshal(&entries); err != nil {
+		return err
+	}
+
+	needs := make(Needs, 0, len(entries))
+	for _, e := range entries {
+		needs = append(needs, string(e))
+	}
+
+	*n = needs
+
+	return nil
+}
+
+func (n Needs) hasSelector() bool {
+	for _, e := range n {
+		if strings.HasPrefix(e, needsSelectorPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+type needsEntry string
+
+func (e *needsEntry) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var id string
+	if err := unmarshal(&id); err == nil {
+		*e = needsEntry(id)
+		return nil
+	}
+
+	var m needsMap
+	if err := unmarshal(&m); err != nil {
+		return fmt.Errorf("needs entry must be either a release name or a map like `selector: key=value`: %v", err)
+	}
+
+	if m.Helmfile != "" || m.Release != "" {
+		if m.Helmfile == "" || m.Release == "" {
+			return fmt.Errorf("needs entry of another helmfile must have both `helmfile` and `release`")
+		}
+		*e = needsEntry(ExternalNeed{Helmfile: m.Helmfile, Release: m.Release}.String())
+		return nil
+	}
+
+	if m.Selector == "" {
+		return fmt.Errorf("needs entry must have a non-empty selector")
+	}
+
+	*e = needsEntry(needsSelectorPrefix + m.Selector)
+
+	return nil
+}
+
+// releaseLabelsForNeeds returns the labels a `needs` selector is matched against,
+// including the implicit `name`, `namespace` and `chart` labels also available to `--selector`.
+func releaseLabelsForNeeds(r ReleaseSpec) map[string]string {
+	labels := map[string]string{}
+	for k, v := range r.Labels {
+		labels[k] = v
+	}
+	labels["name"] = r.Name
+	labels["namespace"] = r.Namespace
+	chartSplit := strings.Split(r.Chart, "/")
+	labels["chart"] = chartSplit[len(chartSplit)-1]
+	return labels
+}
+
+// neededReleaseID returns the `needs` entry referring to target from the release needing it.
+// The kubecontext and namespace are omitted when they're the same as the needing release's,
+// so that they keep following it when --kube-context or --namespace overrides them.
+func neededReleaseID(needing, target *ReleaseSpec) string {
+	if target.KubeContext != needing.KubeContext {
+		return target.KubeContext + "/" + target.Namespace + "/" + target.Name
+	}
+
+	if target.Namespace != needing.Namespace {
+		return target.Namespace + "/" + target.Name
+	}
+
+	return target.Name
+}
+
+// expandNeedsSelectors replaces each `needs` selector with the releases matching it,
+// so that the DAG is built only from concrete dependencies.
+// A release never needs itself even if it matches its own selector.
+func expandNeedsSelectors(releases []ReleaseSpec) error {
+	for i := range releases {
+		r := &releases[i]
+
+		if !r.Needs.hasSelector() {
+			continue
+		}
+
+		var expanded Needs
+
+		seen := map[string]struct{}{}
+		add := func(id string) {
+			if _, ok := seen[id]; !ok {
+				seen[id] = struct{}{}
+				expanded = append(expanded, id)
+			}
+		}
+
+		for _, n := range r.Needs {
+			if !strings.HasPrefix(n, needsSelectorPrefix) {
+				add(n)
+				continue
+			}
+
+			selector := strings.TrimPrefix(n, needsSelectorPrefix)
+
+			filter, err := ParseLabels(selector)
+			if err != nil {
+				return fmt.Errorf("invalid needs selector %q in release %q: %v", selector, r.Name, err)
+			}
+
+			for j := range releases {
+				if i == j {
+					continue
+				}
+
+				target := releases[j]
+				target.Labels = releaseLabelsForNeeds(target)
+
+				if filter.Match(target) {
+					add(neededReleaseID(r, &releases[j]))
+				}
+			}
+		}
+
+		r.Needs = expanded
+	}
+
+	return nil
+}
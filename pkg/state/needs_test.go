@@ -0,0 +1,94 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/yaml"
+)
+
+func TestNeeds_UnmarshalYAML(t *testing.T) {
+	var r ReleaseSpec
+	require.NoError(t, yaml.NewDecoder([]byte(`
+name: app
+chart: charts/app
+needs:
+- infra/cert-manager
+- selector: tier=infra
+`), true)(&r))
+
+	require.Equal(t, Needs{"infra/cert-manager", "selector:tier=infra"}, r.Needs)
+
+	require.Error(t, yaml.NewDecoder([]byte(`
+needs:
+- selector: ""
+`), true)(&r))
+
+	require.NoError(t, yaml.NewDecoder([]byte(`
+name: app
+chart: charts/app
+needs:
+- ../platform/helmfile.yaml//kube-system/ingress-nginx
+- helmfile: platform
+  release: kube-system/ingress-nginx
+- helmfile: ../platform/helmfile.yaml
+  release: cert-manager
+`), true)(&r))
+
+	require.Equal(t, Needs{
+		"../platform/helmfile.yaml//kube-system/ingress-nginx",
+		"helmfile:platform//kube-system/ingress-nginx",
+		"../platform/helmfile.yaml//cert-manager",
+	}, r.Needs)
+
+	require.EqualError(t, yaml.NewDecoder([]byte(`
+needs:
+- helmfile: platform
+`), true)(&r), "needs entry of another helmfile must have both `helmfile` and `release`")
+}
+
+func TestExpandNeedsSelectors(t *testing.T) {
+	releases := []ReleaseSpec{
+		{
+			Name:      "cert-manager",
+			Namespace: "infra",
+			Chart:     "jetstack/cert-manager",
+			Labels:    map[string]string{"tier": "infra"},
+		},
+		{
+			Name:      "ingress",
+			Namespace: "app",
+			Chart:     "charts/ingress-nginx",
+			Labels:    map[string]string{"tier": "infra"},
+		},
+		{
+			Name:        "monitoring",
+			Namespace:   "infra",
+			KubeContext: "ops",
+			Chart:       "charts/prometheus",
+			Labels:      map[string]string{"tier": "infra"},
+			Needs:       Needs{"selector:tier=infra"},
+		},
+		{
+			Name:      "app",
+			Namespace: "app",
+			Chart:     "charts/app",
+			Needs:     Needs{"ingress", "selector:tier=infra", "selector:chart=prometheus"},
+		},
+		{
+			Name:      "worker",
+			Namespace: "app",
+			Chart:     "charts/app",
+			Needs:     Needs{"app"},
+		},
+	}
+
+	require.NoError(t, expandNeedsSelectors(releases))
+
+	require.Equal(t, Needs{"/infra/cert-manager", "/app/ingress"}, releases[2].Needs)
+	require.Equal(t, Needs{"ingress", "infra/cert-manager", "ops/infra/monitoring"}, releases[3].Needs)
+	require.Equal(t, Needs{"app"}, releases[4].Needs)
+
+	require.Error(t, expandNeedsSelectors([]ReleaseSpec{{Name: "app", Needs: Needs{"selector:tier"}}}))
+}
@@ -0,0 +1,90 @@
+package state
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/helmfile/helmfile/pkg/remote"
+)
+
+// newRemote returns the remote fetching the remote charts and values files of the state into the cache directory,
+// which fails on the sources missing from the cache in the offline mode
+func (st *HelmState) newRemote() *remote.Remote {
+	r := remote.NewRemote(st.logger, "", st.fs)
+	r.Offline = st.Offline
+	return r
+}
+
+// checkOfflineCharts fails fast in the offline mode, listing the charts of the releases that are neither local, vendored, nor cached,
+// so that they are all reported at once instead of failing on the first download
+func (st *HelmState) checkOfflineCharts(releases []ReleaseSpec, vendor *VendorManifest, ociCacheDir string) error {
+	var missing []string
+
+	for i := range releases {
+		release := releases[i]
+		if st.OverrideChart != "" {
+			release.Chart = st.OverrideChart
+		}
+
+		if reason := st.offlineChartMissing(&release, vendor, ociCacheDir); reason != "" {
+			missing = append(missing, fmt.Sprintf("release %q: %s", release.Name, reason))
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("offline: %d chart(s) of %s are missing from the cache and the vendor directory:\n  %s\nRun `helmfile fetch --vendor` without --offline to vendor them",
+		len(missing), st.FilePath, strings.Join(missing, "\n  "))
+}
+
+// offlineChartMissing returns why the chart of the release can't be used in the offline mode, or an empty string when it can
+func (st *HelmState) offlineChartMissing(release *ReleaseSpec, vendor *VendorManifest, ociCacheDir string) string {
+	if st.vendoredChart(vendor, release) != nil {
+		return ""
+	}
+
+	chart := release.Chart
+	if release.Directory != "" && chart == "" {
+		chart = release.Directory
+	}
+
+	if st.fs.DirectoryExistsAt(normalizeChart(st.basePath, chart)) {
+		return ""
+	}
+
+	if !strings.HasPrefix(chart, "oci://") || release.ForceGoGetter {
+		if _, err := remote.Parse(chart); err == nil {
+			cached, err := st.newRemote().Cached(chart, goGetterChartCacheDir(release))
+			if err != nil {
+				return err.Error()
+			}
+			if !cached {
+				return fmt.Sprintf("the chart %s is not cached", chart)
+			}
+			return ""
+		}
+	}
+
+	if qualifiedChartName, _, _ := st.getOCIQualifiedChartName(release); qualifiedChartName != "" {
+		_, digest, err := releaseChartDigest(release)
+		if err != nil {
+			return err.Error()
+		}
+		// Only the OCI charts pinned by digest are cached, as the tags may be moved
+		if digest == "" || ociCacheDir == "" {
+			return fmt.Sprintf("the OCI chart %s is not vendored, and only the ones pinned by digest are cached", qualifiedChartName)
+		}
+		if _, err := findChartDirectory(ociCachePath(ociCacheDir, qualifiedChartName, digest)); err != nil {
+			return fmt.Sprintf("the OCI chart %s is not cached", qualifiedChartName)
+		}
+		return ""
+	}
+
+	if release.Version != "" {
+		return fmt.Sprintf("the chart %s@%s is not vendored", chart, release.Version)
+	}
+
+	return fmt.Sprintf("the chart %s is not vendored", chart)
+}
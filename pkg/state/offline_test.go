@@ -0,0 +1,77 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/envvar"
+	"github.com/helmfile/helmfile/pkg/exectest"
+	"github.com/helmfile/helmfile/pkg/filesystem"
+)
+
+func TestHelmState_CheckOfflineCharts(t *testing.T) {
+	t.Setenv(envvar.CacheHome, t.TempDir())
+
+	dir := t.TempDir()
+	ociCacheDir := t.TempDir()
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "charts", "web"), 0755))
+
+	cached := ociCachePath(ociCacheDir, "registry.example.com/charts/cached@sha256:abc", "sha256:abc")
+	require.NoError(t, os.MkdirAll(filepath.Join(cached, "cached"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(cached, "cached", "Chart.yaml"), []byte("name: cached\n"), 0644))
+
+	st := &HelmState{
+		basePath: dir,
+		FilePath: "helmfile.yaml",
+		ReleaseSetSpec: ReleaseSetSpec{
+			Releases: []ReleaseSpec{
+				{Name: "local", Namespace: "app", Chart: "./charts/web"},
+				{Name: "vendored", Namespace: "app", Chart: "bitnami/nginx", Version: "15.0.0"},
+				{Name: "repo", Namespace: "app", Chart: "bitnami/redis", Version: "18.0.0"},
+				{Name: "cached", Namespace: "app", Chart: "oci://registry.example.com/charts/cached", Digest: "sha256:abc"},
+				{Name: "uncached", Namespace: "app", Chart: "oci://registry.example.com/charts/uncached", Digest: "sha256:def"},
+				{Name: "tagged", Namespace: "app", Chart: "oci://registry.example.com/charts/tagged", Version: "1.0.0"},
+				{Name: "git", Namespace: "app", Chart: "git::https://github.com/org/repo.git@charts/app?ref=v1.0.0"},
+			},
+		},
+		logger: logger,
+		fs:     filesystem.DefaultFileSystem(),
+	}
+
+	vendor := &VendorManifest{
+		Charts: []VendoredChart{
+			{Helmfile: "helmfile.yaml", Release: "app/vendored", Chart: "bitnami/nginx", Version: "15.0.0", Path: "bitnami/nginx/15.0.0"},
+		},
+	}
+
+	err := st.checkOfflineCharts(st.Releases, vendor, ociCacheDir)
+	require.EqualError(t, err, `offline: 4 chart(s) of helmfile.yaml are missing from the cache and the vendor directory:
+  release "repo": the chart bitnami/redis@18.0.0 is not vendored
+  release "uncached": the OCI chart registry.example.com/charts/uncached@sha256:def is not cached
+  release "tagged": the OCI chart registry.example.com/charts/tagged:1.0.0 is not vendored, and only the ones pinned by digest are cached
+  release "git": the chart git::https://github.com/org/repo.git@charts/app?ref=v1.0.0 is not cached
+Run `+"`helmfile fetch --vendor`"+` without --offline to vendor them`)
+
+	require.NoError(t, st.checkOfflineCharts(st.Releases[:2], vendor, ociCacheDir))
+}
+
+func TestHelmState_SyncRepos_Offline(t *testing.T) {
+	st := &HelmState{
+		ReleaseSetSpec: ReleaseSetSpec{
+			Repositories: []RepositorySpec{{Name: "bitnami", URL: "https://charts.bitnami.com/bitnami"}},
+		},
+		logger:  logger,
+		Offline: true,
+	}
+
+	helm := &exectest.Helm{}
+
+	updated, err := st.SyncRepos(helm, map[string]bool{})
+	require.NoError(t, err)
+	require.Empty(t, updated)
+	require.Empty(t, helm.Repo)
+}
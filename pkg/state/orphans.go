@@ -0,0 +1,333 @@
+package state
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/helmfile/helmfile/pkg/helmexec"
+)
+
+// The types of resources `helm uninstall` leaves behind
+const (
+	OrphanTypePVC       = "pvc"
+	OrphanTypeSecret    = "secret"
+	OrphanTypeNamespace = "namespace"
+)
+
+// OrphanTypes is the types of orphaned resources that can be reported and deleted
+var OrphanTypes = []string{OrphanTypePVC, OrphanTypeSecret, OrphanTypeNamespace}
+
+// releaseInstanceLabel is the label charts following the Helm conventions put on every resource of a release
+const releaseInstanceLabel = "app.kubernetes.io/instance"
+
+// createdNamespaceLabel is the label Helmfile puts on the namespaces created by `--create-namespace`
+// when helmDefaults.labelCreatedNamespaces is enabled. Only the namespaces with it are reported and deleted as orphaned.
+const createdNamespaceLabel = "helmfile.readthedocs.io/created-namespace"
+
+// systemNamespaces are never reported as orphaned even if they were created by `--create-namespace`
+var systemNamespaces = map[string]bool{
+	"default":         true,
+	"kube-system":     true,
+	"kube-public":     true,
+	"kube-node-lease": true,
+}
+
+// transientResourceTypes are the namespaced types of the resources that outlive what they are about for a while,
+// which don't make the namespace non-empty
+var transientResourceTypes = map[string]bool{
+	"events":               true,
+	"events.events.k8s.io": true,
+	"pods.metrics.k8s.io":  true,
+}
+
+// defaultNamespaceResources are the patterns of the resources Kubernetes creates in every namespace,
+// which don't make the namespace non-empty
+var defaultNamespaceResources = []string{
+	"configmap/kube-root-ca.crt",
+	"serviceaccount/default",
+	"secret/default-token-*",
+}
+
+// OrphanedResource is a resource of a deleted release that is left in the cluster
+type OrphanedResource struct {
+	// Type is one of OrphanTypes
+	Type        string
+	Namespace   string
+	Name        string
+	KubeContext string
+	// Release is the name of the deleted release
+	Release string
+}
+
+func (o OrphanedResource) kind() string {
+	switch o.Type {
+	case OrphanTypePVC:
+		return "persistentvolumeclaim"
+	default:
+		return o.Type
+	}
+}
+
+func (o OrphanedResource) deleteArgs() []string {
+	args := []string{"delete", o.kind(), o.Name}
+
+	if o.Type != OrphanTypeNamespace && o.Namespace != "" {
+		args = append(args, "--namespace", o.Namespace)
+	}
+
+	if o.KubeContext != "" {
+		args = append(args, "--context", o.KubeContext)
+	}
+
+	return args
+}
+
+// ValidateOrphanTypes returns an error if any of the types isn't one of OrphanTypes
+func ValidateOrphanTypes(types []string) error {
+	for _, t := range types {
+		var valid bool
+		for _, v := range OrphanTypes {
+			if t == v {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("unknown orphaned resource type %q: must be one of %s", t, strings.Join(OrphanTypes, ", "))
+		}
+	}
+
+	return nil
+}
+
+// NamespaceNotEmptyError is returned when deleting the orphaned namespace with the resources left in it
+type NamespaceNotEmptyError struct {
+	Namespace string
+	Resources []string
+}
+
+func (e *NamespaceNotEmptyError) Error() string {
+	return fmt.Sprintf("namespace %s is not empty: %s", e.Namespace, strings.Join(e.Resources, ", "))
+}
+
+// FindOrphanedResources looks up the resources intentionally left behind by `helm uninstall` for the deleted releases,
+// that are the PVCs and the Secrets labeled with the release name, like the ones created from StatefulSet volume claim templates
+// or by hooks, and the namespaces labeled as created by Helmfile with no release left in them.
+func (st *HelmState) FindOrphanedResources(helm helmexec.Interface, releases []*ReleaseSpec) ([]OrphanedResource, error) {
+	var orphans []OrphanedResource
+
+	namespaces := map[string]OrphanedResource{}
+
+	for _, r := range releases {
+		kubeContext := r.KubeContext
+		if kubeContext == "" {
+			kubeContext = st.HelmDefaults.KubeContext
+		}
+
+		for _, t := range []string{OrphanTypePVC, OrphanTypeSecret} {
+			args := []string{
+				"get", OrphanedResource{Type: t}.kind(),
+				"--selector", releaseInstanceLabel + "=" + r.Name,
+				"-o", `jsonpath={range .items[*]}{.metadata.name}{"\n"}{end}`,
+			}
+			if t == OrphanTypeSecret {
+				// Helm's own release records are removed by `helm uninstall` unless --keep-history is given
+				args = append(args, "--field-selector", "type!=helm.sh/release.v1")
+			}
+			if r.Namespace != "" {
+				args = append(args, "--namespace", r.Namespace)
+			}
+			if kubeContext != "" {
+				args = append(args, "--context", kubeContext)
+			}
+
+			out, err := st.execKubectl(args)
+			if err != nil {
+				return nil, fmt.Errorf("looking up orphaned %ss of release %s: %v: %s", t, r.Name, err, strings.TrimSpace(string(out)))
+			}
+
+			for _, name := range strings.Fields(string(out)) {
+				orphans = append(orphans, OrphanedResource{
+					Type:        t,
+					Namespace:   r.Namespace,
+					Name:        name,
+					KubeContext: kubeContext,
+					Release:     r.Name,
+				})
+			}
+		}
+
+		if r.Namespace != "" && st.CreatesNamespace(r) && !systemNamespaces[r.Namespace] {
+			namespaces[kubeContext+"/"+r.Namespace] = OrphanedResource{
+				Type:        OrphanTypeNamespace,
+				Name:        r.Namespace,
+				KubeContext: kubeContext,
+				Release:     r.Name,
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(namespaces))
+	for k := range namespaces {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		ns := namespaces[k]
+
+		flags := []string{"--namespace", ns.Name}
+		if ns.KubeContext != "" {
+			flags = append(flags, "--kube-context", ns.KubeContext)
+		}
+
+		out, err := helm.List(helmexec.HelmContext{}, ".", flags...)
+		if err != nil {
+			return nil, fmt.Errorf("listing releases in namespace %s: %v", ns.Name, err)
+		}
+		if strings.TrimSpace(out) != "" {
+			continue
+		}
+
+		// The namespaces existing before the releases, or created by anything else, are never orphaned,
+		// even if the releases set createNamespace
+		args := []string{"get", "namespace", ns.Name, "--ignore-not-found", "-o", "jsonpath={.metadata.labels." + strings.ReplaceAll(createdNamespaceLabel, ".", `\.`) + "}"}
+		if ns.KubeContext != "" {
+			args = append(args, "--context", ns.KubeContext)
+		}
+
+		nsOut, err := st.execKubectl(args)
+		if err != nil {
+			return nil, fmt.Errorf("looking up namespace %s: %v: %s", ns.Name, err, strings.TrimSpace(string(nsOut)))
+		}
+		if strings.TrimSpace(string(nsOut)) == "true" {
+			orphans = append(orphans, ns)
+		}
+	}
+
+	return orphans, nil
+}
+
+// DeleteOrphanedResource deletes the orphaned resource.
+// The namespaces are deleted only when they are empty, returning NamespaceNotEmptyError otherwise.
+func (st *HelmState) DeleteOrphanedResource(o OrphanedResource) error {
+	if o.Type == OrphanTypeNamespace {
+		remaining, err := st.RemainingNamespaceResources(o)
+		if err != nil {
+			return err
+		}
+		if len(remaining) > 0 {
+			return &NamespaceNotEmptyError{Namespace: o.Name, Resources: remaining}
+		}
+	}
+
+	if out, err := st.execKubectl(o.deleteArgs()); err != nil {
+		return fmt.Errorf("deleting %s %s: %v: %s", o.kind(), o.Name, err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
+
+// RemainingNamespaceResources returns the resources left in the namespace of the orphaned namespace,
+// except the ones Kubernetes creates in every namespace, so that namespaces still in use aren't deleted
+func (st *HelmState) RemainingNamespaceResources(o OrphanedResource) ([]string, error) {
+	types, err := st.namespacedResourceTypes(o.KubeContext)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{"get", strings.Join(types, ","), "--namespace", o.Name, "--ignore-not-found", "-o", "name"}
+	if o.KubeContext != "" {
+		args = append(args, "--context", o.KubeContext)
+	}
+
+	out, err := st.execKubectl(args)
+	if err != nil {
+		return nil, fmt.Errorf("looking up resources in namespace %s: %v: %s", o.Name, err, strings.TrimSpace(string(out)))
+	}
+
+	var remaining []string
+
+	for _, r := range strings.Fields(string(out)) {
+		var isDefault bool
+		for _, p := range defaultNamespaceResources {
+			if ok, _ := path.Match(p, r); ok {
+				isDefault = true
+				break
+			}
+		}
+		if !isDefault {
+			remaining = append(remaining, r)
+		}
+	}
+
+	return remaining, nil
+}
+
+// namespacedResourceTypes returns the namespaced types of the resources the cluster serves, including the custom resources,
+// that can be listed to tell if a namespace is empty
+func (st *HelmState) namespacedResourceTypes(kubeContext string) ([]string, error) {
+	args := []string{"api-resources", "--verbs=list", "--namespaced", "-o", "name"}
+	if kubeContext != "" {
+		args = append(args, "--context", kubeContext)
+	}
+
+	out, err := st.execKubectl(args)
+	if err != nil {
+		return nil, fmt.Errorf("looking up the namespaced resource types: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+
+	var types []string
+
+	for _, t := range strings.Fields(string(out)) {
+		if !transientResourceTypes[t] {
+			types = append(types, t)
+		}
+	}
+
+	if len(types) == 0 {
+		return nil, fmt.Errorf("looking up the namespaced resource types: no type is served")
+	}
+
+	return types, nil
+}
+
+// namespaceLabeler returns the func labeling the namespace of the release as created by Helmfile, to be called once the release is installed.
+// It does nothing unless helmDefaults.labelCreatedNamespaces is enabled and the namespace doesn't exist yet,
+// so that only the namespaces `--create-namespace` creates are labeled.
+// Failing to look up or label the namespace is only warned, as it only keeps the namespace from being deleted as orphaned.
+func (st *HelmState) namespaceLabeler(r *ReleaseSpec) func() {
+	noop := func() {}
+
+	if !st.HelmDefaults.LabelCreatedNamespaces || r.Namespace == "" || !st.CreatesNamespace(r) || systemNamespaces[r.Namespace] {
+		return noop
+	}
+
+	kubeContext := r.KubeContext
+	if kubeContext == "" {
+		kubeContext = st.HelmDefaults.KubeContext
+	}
+
+	var contextFlags []string
+	if kubeContext != "" {
+		contextFlags = []string{"--context", kubeContext}
+	}
+
+	out, err := st.execKubectl(append([]string{"get", "namespace", r.Namespace, "--ignore-not-found", "-o", "name"}, contextFlags...))
+	if err != nil {
+		st.logger.Warnf("warn: unable to look up namespace %s, leaving it unlabeled: %v: %s", r.Namespace, err, strings.TrimSpace(string(out)))
+		return noop
+	}
+	if strings.TrimSpace(string(out)) != "" {
+		return noop
+	}
+
+	return func() {
+		args := append([]string{"label", "namespace", r.Namespace, createdNamespaceLabel + "=true", "--overwrite"}, contextFlags...)
+		if out, err := st.execKubectl(args); err != nil {
+			st.logger.Warnf("warn: unable to label namespace %s as created by helmfile: %v: %s", r.Namespace, err, strings.TrimSpace(string(out)))
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/envvar"
+	"github.com/helmfile/helmfile/pkg/exectest"
+)
+
+func TestFindOrphanedResources(t *testing.T) {
+	t.Setenv(envvar.KubectlBinary, "")
+
+	r := &exectest.Runner{
+		Outputs: map[string]string{
+			`kubectl get persistentvolumeclaim --selector app.kubernetes.io/instance=db -o jsonpath={range .items[*]}{.metadata.name}{"\n"}{end} --namespace data --context prod`:                            "data-db-0\ndata-db-1\n",
+			`kubectl get secret --selector app.kubernetes.io/instance=db -o jsonpath={range .items[*]}{.metadata.name}{"\n"}{end} --field-selector type!=helm.sh/release.v1 --namespace data --context prod`: "db-root-password\n",
+			`kubectl get namespace data --ignore-not-found -o jsonpath={.metadata.labels.helmfile\.readthedocs\.io/created-namespace} --context prod`:                                                        "true",
+			"kubectl api-resources --verbs=list --namespaced -o name --context prod":                                                                                                                         "configmaps\nevents\npods\nsecrets\nserviceaccounts\nevents.events.k8s.io\nwidgets.example.com\n",
+			`kubectl get namespace logs --ignore-not-found -o jsonpath={.metadata.labels.helmfile\.readthedocs\.io/created-namespace} --context prod`:                                                        "",
+		},
+	}
+
+	st := &HelmState{
+		ReleaseSetSpec: ReleaseSetSpec{
+			HelmDefaults: HelmSpec{KubeContext: "prod"},
+		},
+		logger: logger,
+		runner: r,
+	}
+
+	helm := &exectest.Helm{
+		Lists: map[exectest.ListKey]string{},
+	}
+
+	createNamespace := false
+
+	orphans, err := st.FindOrphanedResources(helm, []*ReleaseSpec{
+		{Name: "db", Namespace: "data"},
+		{Name: "web", Namespace: "default"},
+		{Name: "cache", Namespace: "data", CreateNamespace: &createNamespace},
+		{Name: "fluentd", Namespace: "logs"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []OrphanedResource{
+		{Type: OrphanTypePVC, Namespace: "data", Name: "data-db-0", KubeContext: "prod", Release: "db"},
+		{Type: OrphanTypePVC, Namespace: "data", Name: "data-db-1", KubeContext: "prod", Release: "db"},
+		{Type: OrphanTypeSecret, Namespace: "data", Name: "db-root-password", KubeContext: "prod", Release: "db"},
+		{Type: OrphanTypeNamespace, Name: "data", KubeContext: "prod", Release: "db"},
+	}, orphans)
+
+	require.NoError(t, st.DeleteOrphanedResource(orphans[0]))
+	require.NoError(t, st.DeleteOrphanedResource(orphans[3]))
+	require.Equal(t, []string{
+		"kubectl delete persistentvolumeclaim data-db-0 --namespace data --context prod",
+		"kubectl api-resources --verbs=list --namespaced -o name --context prod",
+		"kubectl get configmaps,pods,secrets,serviceaccounts,widgets.example.com --namespace data --ignore-not-found -o name --context prod",
+		"kubectl delete namespace data --context prod",
+	}, r.Calls[len(r.Calls)-4:])
+
+	r.Outputs["kubectl get configmaps,pods,secrets,serviceaccounts,widgets.example.com --namespace data --ignore-not-found -o name --context prod"] = "widget.example.com/legacy\n"
+
+	err = st.DeleteOrphanedResource(orphans[3])
+	require.Equal(t, &NamespaceNotEmptyError{Namespace: "data", Resources: []string{"widget.example.com/legacy"}}, err)
+	require.NotContains(t, r.Calls[len(r.Calls)-1], "delete")
+}
+
+func TestNamespaceLabeler(t *testing.T) {
+	t.Setenv(envvar.KubectlBinary, "")
+
+	r := &exectest.Runner{
+		Outputs: map[string]string{
+			"kubectl get namespace existing --ignore-not-found -o name": "namespace/existing\n",
+		},
+	}
+
+	st := &HelmState{
+		ReleaseSetSpec: ReleaseSetSpec{
+			HelmDefaults: HelmSpec{LabelCreatedNamespaces: true},
+		},
+		logger: logger,
+		runner: r,
+	}
+
+	st.namespaceLabeler(&ReleaseSpec{Name: "db", Namespace: "data"})()
+	st.namespaceLabeler(&ReleaseSpec{Name: "web", Namespace: "existing"})()
+	st.namespaceLabeler(&ReleaseSpec{Name: "app", Namespace: "default"})()
+
+	require.Equal(t, []string{
+		"kubectl get namespace data --ignore-not-found -o name",
+		"kubectl label namespace data helmfile.readthedocs.io/created-namespace=true --overwrite",
+		"kubectl get namespace existing --ignore-not-found -o name",
+	}, r.Calls)
+
+	r.Calls = nil
+	st.HelmDefaults.LabelCreatedNamespaces = false
+	st.namespaceLabeler(&ReleaseSpec{Name: "db", Namespace: "data"})()
+	require.Empty(t, r.Calls)
+}
+
+func TestValidateOrphanTypes(t *testing.T) {
+	require.NoError(t, ValidateOrphanTypes([]string{"pvc", "namespace"}))
+	require.EqualError(t, ValidateOrphanTypes([]string{"pvcs"}), `unknown orphaned resource type "pvcs": must be one of pvc, secret, namespace`)
+}
+
+func TestRemainingNamespaceResources(t *testing.T) {
+	t.Setenv(envvar.KubectlBinary, "")
+
+	r := &exectest.Runner{
+		Outputs: map[string]string{
+			"kubectl api-resources --verbs=list --namespaced -o name --context prod":                                                             "configmaps\nevents\npods\nsecrets\nserviceaccounts\nevents.events.k8s.io\nwidgets.example.com\n",
+			`kubectl get configmaps,pods,secrets,serviceaccounts,widgets.example.com --namespace data --ignore-not-found -o name --context prod`: "configmap/kube-root-ca.crt\nserviceaccount/default\nsecret/default-token-x7k2p\n",
+			`kubectl get configmaps,pods,secrets,serviceaccounts,widgets.example.com --namespace web --ignore-not-found -o name --context prod`:  "configmap/kube-root-ca.crt\nwidget.example.com/legacy\n",
+		},
+	}
+
+	st := &HelmState{
+		logger: logger,
+		runner: r,
+	}
+
+	remaining, err := st.RemainingNamespaceResources(OrphanedResource{Type: OrphanTypeNamespace, Name: "data", KubeContext: "prod"})
+	require.NoError(t, err)
+	require.Empty(t, remaining)
+
+	remaining, err = st.RemainingNamespaceResources(OrphanedResource{Type: OrphanTypeNamespace, Name: "web", KubeContext: "prod"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"widget.example.com/legacy"}, remaining)
+}
@@ -0,0 +1,104 @@
+package state
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/helmfile/helmfile/pkg/helmexec"
+)
+
+// The policies to recover the releases stuck in a pending status, like the ones left by a crashed helm process,
+// which otherwise fail to upgrade with "another operation (install/upgrade/rollback) is in progress"
+const (
+	// PendingRecoveryFail fails before upgrading the pending release, with its status
+	PendingRecoveryFail = "fail"
+	// PendingRecoveryRollback rolls back the pending release to the previous revision before upgrading it
+	PendingRecoveryRollback = "rollback"
+	// PendingRecoveryUninstallIfNeverDeployed rolls back the pending release like PendingRecoveryRollback,
+	// but uninstalls the one stuck in pending-install, that has no revision to roll back to
+	PendingRecoveryUninstallIfNeverDeployed = "uninstall-if-never-deployed"
+)
+
+const (
+	releaseStatusPendingInstall  = "pending-install"
+	releaseStatusPendingUpgrade  = "pending-upgrade"
+	releaseStatusPendingRollback = "pending-rollback"
+)
+
+func (st *HelmState) pendingRecovery(release *ReleaseSpec) string {
+	if release.PendingRecovery != "" {
+		return release.PendingRecovery
+	}
+	return st.HelmDefaults.PendingRecovery
+}
+
+// pendingStatus returns the status of the release if it is pending, or an empty string
+func (st *HelmState) pendingStatus(context helmexec.HelmContext, helm helmexec.Interface, release *ReleaseSpec) (string, error) {
+	flags := st.connectionFlags(release)
+	if release.Namespace != "" {
+		flags = append(flags, "--namespace", release.Namespace)
+	}
+	flags = append(flags, "--pending")
+
+	out, err := helm.List(context, "^"+release.Name+"$", flags...)
+	if err != nil {
+		return "", err
+	}
+
+	for _, s := range []string{releaseStatusPendingInstall, releaseStatusPendingUpgrade, releaseStatusPendingRollback} {
+		if strings.Contains(out, s) {
+			return s, nil
+		}
+	}
+
+	return "", nil
+}
+
+// recoverPendingRelease applies the pendingRecovery policy of the release to it when it is stuck in a pending status,
+// so that it can be upgraded
+func (st *HelmState) recoverPendingRelease(context helmexec.HelmContext, helm helmexec.Interface, release *ReleaseSpec) error {
+	policy := st.pendingRecovery(release)
+
+	switch policy {
+	case "":
+		return nil
+	case PendingRecoveryFail, PendingRecoveryRollback, PendingRecoveryUninstallIfNeverDeployed:
+	default:
+		return fmt.Errorf("invalid pendingRecovery %q: must be one of %s, %s, %s", policy, PendingRecoveryFail, PendingRecoveryRollback, PendingRecoveryUninstallIfNeverDeployed)
+	}
+
+	status, err := st.pendingStatus(context, helm, release)
+	if err != nil {
+		return fmt.Errorf("checking if release %s is pending: %v", release.Name, err)
+	}
+	if status == "" {
+		return nil
+	}
+
+	if policy == PendingRecoveryFail || policy == PendingRecoveryRollback && status == releaseStatusPendingInstall {
+		return fmt.Errorf("release %s is in %s status, which needs to be recovered manually", release.Name, status)
+	}
+
+	flags := st.connectionFlags(release)
+	if release.Namespace != "" {
+		flags = append(flags, "--namespace", release.Namespace)
+	}
+
+	if status == releaseStatusPendingInstall {
+		st.logger.Infof("Uninstalling release %s in %s status, which has never been deployed", release.Name, status)
+
+		if err := helm.DeleteRelease(context, release.Name, flags...); err != nil {
+			return fmt.Errorf("uninstalling release %s in %s status: %v", release.Name, status, err)
+		}
+
+		return nil
+	}
+
+	st.logger.Infof("Rolling back release %s in %s status", release.Name, status)
+
+	if err := helm.Exec(context, append([]string{"rollback", release.Name}, flags...)...); err != nil {
+		return fmt.Errorf("rolling back release %s in %s status: %v", release.Name, status, err)
+	}
+
+	return nil
+}
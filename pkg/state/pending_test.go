@@ -0,0 +1,95 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/exectest"
+	"github.com/helmfile/helmfile/pkg/helmexec"
+)
+
+func TestHelmState_recoverPendingRelease(t *testing.T) {
+	const (
+		pendingUpgrade = "web	default	2	2023-01-01 00:00:00.000000000 +0000 UTC	pending-upgrade	web-1.0.0	1.0.0\n"
+		pendingInstall = "web	default	1	2023-01-01 00:00:00.000000000 +0000 UTC	pending-install	web-1.0.0	1.0.0\n"
+	)
+
+	listKey := exectest.ListKey{Filter: "^web$", Flags: "--kube-contextprod--namespacedefault--pending"}
+
+	tests := []struct {
+		name          string
+		defaultPolicy string
+		releasePolicy string
+		list          string
+		wantErr       string
+		wantExecs     [][]string
+		wantDeleted   []exectest.Release
+	}{
+		{
+			name: "no policy",
+			list: pendingUpgrade,
+		},
+		{
+			name:          "not pending",
+			defaultPolicy: PendingRecoveryRollback,
+		},
+		{
+			name:          "fail",
+			defaultPolicy: PendingRecoveryFail,
+			list:          pendingUpgrade,
+			wantErr:       "release web is in pending-upgrade status, which needs to be recovered manually",
+		},
+		{
+			name:          "rollback",
+			defaultPolicy: PendingRecoveryFail,
+			releasePolicy: PendingRecoveryRollback,
+			list:          pendingUpgrade,
+			wantExecs:     [][]string{{"rollback", "web", "--kube-context", "prod", "--namespace", "default"}},
+		},
+		{
+			name:          "rollback never deployed",
+			defaultPolicy: PendingRecoveryRollback,
+			list:          pendingInstall,
+			wantErr:       "release web is in pending-install status, which needs to be recovered manually",
+		},
+		{
+			name:          "uninstall never deployed",
+			defaultPolicy: PendingRecoveryUninstallIfNeverDeployed,
+			list:          pendingInstall,
+			wantDeleted:   []exectest.Release{{Name: "web", Flags: []string{"--kube-context", "prod", "--namespace", "default"}}},
+		},
+		{
+			name:          "invalid",
+			defaultPolicy: "retry",
+			wantErr:       `invalid pendingRecovery "retry": must be one of fail, rollback, uninstall-if-never-deployed`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := &HelmState{
+				ReleaseSetSpec: ReleaseSetSpec{
+					HelmDefaults: HelmSpec{KubeContext: "prod", PendingRecovery: tt.defaultPolicy},
+				},
+				logger: logger,
+			}
+
+			helm := &exectest.Helm{
+				Lists:                map[exectest.ListKey]string{listKey: tt.list},
+				FailOnUnexpectedList: true,
+			}
+
+			release := &ReleaseSpec{Name: "web", Namespace: "default", PendingRecovery: tt.releasePolicy}
+
+			err := st.recoverPendingRelease(helmexec.HelmContext{}, helm, release)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.wantExecs, helm.Execs)
+			require.Equal(t, tt.wantDeleted, helm.Deleted)
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package state
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The operations to check the permissions for
+const (
+	PreflightOperationDiff    = "diff"
+	PreflightOperationSync    = "sync"
+	PreflightOperationDestroy = "destroy"
+)
+
+// PreflightOperations is the operations that can be checked before running them
+var PreflightOperations = []string{PreflightOperationDiff, PreflightOperationSync, PreflightOperationDestroy}
+
+// ValidatePreflightOperation returns an error if the operation isn't one of PreflightOperations
+func ValidatePreflightOperation(operation string) error {
+	for _, o := range PreflightOperations {
+		if operation == o {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown operation %q: must be one of %s", operation, strings.Join(PreflightOperations, ", "))
+}
+
+// PreflightPermission is a verb needed on a resource in a namespace, or in the whole cluster when the namespace is empty
+type PreflightPermission struct {
+	KubeContext string
+	Namespace   string
+	Verb        string
+	// Resource is the resource, followed by the API group like `deployments.apps` unless it's in the core group
+	Resource string
+}
+
+// PreflightCheck is the result of a check run before the operation
+type PreflightCheck struct {
+	KubeContext string `json:"kubeContext"`
+	// Namespace is empty for the checks of the whole cluster
+	Namespace string `json:"namespace"`
+	// Check is either "reachable", or "can-i VERB RESOURCE" for the permission checks
+	Check   string `json:"check"`
+	OK      bool   `json:"ok"`
+	Message string `json:"message,omitempty"`
+}
+
+// PreflightReport is the results of the checks for all the kube contexts and the namespaces of the releases,
+// each check run once even if it's needed by the releases of multiple helmfiles
+type PreflightReport struct {
+	Checks []PreflightCheck
+
+	checked map[string]bool
+	// unusable is the kube contexts that failed to be connected or authenticated, whose remaining checks are skipped
+	unusable map[string]bool
+}
+
+// NewPreflightReport returns an empty PreflightReport
+func NewPreflightReport() *PreflightReport {
+	return &PreflightReport{
+		checked:  map[string]bool{},
+		unusable: map[string]bool{},
+	}
+}
+
+// Failed returns the checks that failed
+func (r *PreflightReport) Failed() []PreflightCheck {
+	var failed []PreflightCheck
+	for _, c := range r.Checks {
+		if !c.OK {
+			failed = append(failed, c)
+		}
+	}
+	return failed
+}
+
+// Preflight checks, for each kube context of the permissions, that the cluster is reachable with the credentials,
+// and that the current identity is allowed the permissions, adding the results to the report.
+// It never changes anything in the clusters.
+func (st *HelmState) Preflight(permissions []PreflightPermission, report *PreflightReport) {
+	for _, p := range permissions {
+		st.preflightReachable(p.KubeContext, report)
+		st.preflightCanI(p.KubeContext, p.Namespace, p.Verb, p.Resource, report)
+	}
+}
+
+// CreatesNamespace returns true if the namespace of the release is created by `--create-namespace`
+func (st *HelmState) CreatesNamespace(r *ReleaseSpec) bool {
+	return r.CreateNamespace != nil && *r.CreateNamespace ||
+		r.CreateNamespace == nil && (st.HelmDefaults.CreateNamespace == nil || *st.HelmDefaults.CreateNamespace)
+}
+
+// Waits returns true if Helm waits for the resources of the release with `--wait`
+func (st *HelmState) Waits(r *ReleaseSpec) bool {
+	return r.Wait != nil && *r.Wait || r.Wait == nil && st.HelmDefaults.Wait
+}
+
+// Forces returns true if Helm replaces the resources of the release with `--force`
+func (st *HelmState) Forces(r *ReleaseSpec) bool {
+	return r.Force != nil && *r.Force || r.Force == nil && st.HelmDefaults.Force
+}
+
+func (st *HelmState) preflightReachable(kubeContext string, report *PreflightReport) {
+	key := kubeContext + "/reachable"
+	if report.checked[key] {
+		return
+	}
+	report.checked[key] = true
+
+	args := []string{"version", "-o", "json"}
+	if kubeContext != "" {
+		args = append(args, "--context", kubeContext)
+	}
+
+	c := PreflightCheck{KubeContext: kubeContext, Check: "reachable", OK: true}
+
+	out, err := st.execKubectl(args)
+	if err != nil {
+		c.OK = false
+		c.Message = lastLine(out, err)
+		report.unusable[kubeContext] = true
+	}
+
+	report.Checks = append(report.Checks, c)
+}
+
+func (st *HelmState) preflightCanI(kubeContext, namespace, verb, resource string, report *PreflightReport) {
+	check := fmt.Sprintf("can-i %s %s", verb, resource)
+
+	key := strings.Join([]string{kubeContext, namespace, check}, "/")
+	if report.checked[key] || report.unusable[kubeContext] {
+		return
+	}
+	report.checked[key] = true
+
+	args := []string{"auth", "can-i", verb, resource}
+	if namespace != "" {
+		args = append(args, "--namespace", namespace)
+	}
+	if kubeContext != "" {
+		args = append(args, "--context", kubeContext)
+	}
+
+	c := PreflightCheck{KubeContext: kubeContext, Namespace: namespace, Check: check}
+
+	// `kubectl auth can-i` answers "no" with the exit code 1, while it fails with a message for e.g. invalid credentials
+	out, err := st.execKubectl(args)
+	switch answer := strings.TrimSpace(string(out)); {
+	case err == nil && strings.HasPrefix(answer, "yes"):
+		c.OK = true
+	case strings.HasPrefix(answer, "no"):
+		c.Message = "forbidden"
+	default:
+		// The credentials are likely to be invalid, which would fail all the other checks of the kube context as well
+		c.Message = lastLine(out, err)
+		report.unusable[kubeContext] = true
+	}
+
+	report.Checks = append(report.Checks, c)
+}
+
+// lastLine returns the last line of the output of the failed command, which is usually the error message, or the error itself
+func lastLine(out []byte, err error) string {
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if l := strings.TrimSpace(lines[len(lines)-1]); l != "" {
+		return l
+	}
+	if err != nil {
+		return err.Error()
+	}
+	return ""
+}
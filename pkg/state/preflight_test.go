@@ -0,0 +1,76 @@
+package state
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/envvar"
+	"github.com/helmfile/helmfile/pkg/exectest"
+)
+
+func TestHelmState_Preflight(t *testing.T) {
+	t.Setenv(envvar.KubectlBinary, "")
+
+	r := &exectest.Runner{
+		Outputs: map[string]string{
+			"kubectl auth can-i get secrets --namespace web --context prod":            "yes\n",
+			"kubectl auth can-i list secrets --namespace web --context prod":           "yes\n",
+			"kubectl auth can-i create secrets --namespace web --context prod":         "yes\n",
+			"kubectl auth can-i update secrets --namespace web --context prod":         "yes\n",
+			"kubectl auth can-i delete secrets --namespace web --context prod":         "no\n",
+			"kubectl auth can-i create namespaces --context prod":                      "yes\n",
+			"kubectl auth can-i patch deployments.apps --namespace web --context prod": "yes\n",
+			"kubectl auth can-i get secrets --namespace db --context prod":             "error: You must be logged in to the server (Unauthorized)\n",
+			"kubectl version -o json --context staging":                                "{}\nUnable to connect to the server: dial tcp 10.0.0.1:443: i/o timeout\n",
+		},
+		Failures: map[string]bool{
+			"kubectl auth can-i delete secrets --namespace web --context prod": true,
+			"kubectl auth can-i get secrets --namespace db --context prod":     true,
+			"kubectl version -o json --context staging":                        true,
+		},
+	}
+
+	st := &HelmState{
+		logger: logger,
+		runner: r,
+	}
+
+	report := NewPreflightReport()
+
+	st.Preflight([]PreflightPermission{
+		{KubeContext: "prod", Namespace: "web", Verb: "get", Resource: "secrets"},
+		{KubeContext: "prod", Namespace: "web", Verb: "list", Resource: "secrets"},
+		{KubeContext: "prod", Namespace: "web", Verb: "create", Resource: "secrets"},
+		{KubeContext: "prod", Namespace: "web", Verb: "update", Resource: "secrets"},
+		{KubeContext: "prod", Namespace: "web", Verb: "delete", Resource: "secrets"},
+		{KubeContext: "prod", Verb: "create", Resource: "namespaces"},
+		{KubeContext: "prod", Namespace: "web", Verb: "patch", Resource: "deployments.apps"},
+		{KubeContext: "staging", Namespace: "monitoring", Verb: "get", Resource: "secrets"},
+		{KubeContext: "prod", Namespace: "db", Verb: "get", Resource: "secrets"},
+		{KubeContext: "prod", Namespace: "db", Verb: "list", Resource: "secrets"},
+	}, report)
+
+	require.Equal(t, []PreflightCheck{
+		{KubeContext: "prod", Check: "reachable", OK: true},
+		{KubeContext: "prod", Namespace: "web", Check: "can-i get secrets", OK: true},
+		{KubeContext: "prod", Namespace: "web", Check: "can-i list secrets", OK: true},
+		{KubeContext: "prod", Namespace: "web", Check: "can-i create secrets", OK: true},
+		{KubeContext: "prod", Namespace: "web", Check: "can-i update secrets", OK: true},
+		{KubeContext: "prod", Namespace: "web", Check: "can-i delete secrets", Message: "forbidden"},
+		{KubeContext: "prod", Check: "can-i create namespaces", OK: true},
+		{KubeContext: "prod", Namespace: "web", Check: "can-i patch deployments.apps", OK: true},
+		{KubeContext: "staging", Check: "reachable", Message: "Unable to connect to the server: dial tcp 10.0.0.1:443: i/o timeout"},
+		{KubeContext: "prod", Namespace: "db", Check: "can-i get secrets", Message: "error: You must be logged in to the server (Unauthorized)"},
+	}, report.Checks)
+
+	require.Len(t, report.Failed(), 3)
+
+	require.Equal(t, 1, strings.Count(strings.Join(r.Calls, "\n"), "kubectl version -o json --context prod"), "each check should be run once")
+}
+
+func TestValidatePreflightOperation(t *testing.T) {
+	require.NoError(t, ValidatePreflightOperation("diff"))
+	require.EqualError(t, ValidatePreflightOperation("apply"), `unknown operation "apply": must be one of diff, sync, destroy`)
+}
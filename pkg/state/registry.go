@@ -0,0 +1,201 @@
+package state
+
+import (
+	"strings"
+
+	"github.com/helmfile/helmfile/pkg/helmexec"
+)
+
+// RegistrySpec defines an OCI registry that Helmfile logs in to and pulls charts from
+type RegistrySpec struct {
+	// Host is the registry host, optionally followed by a path prefix, like `myregistry.azurecr.io` or `ghcr.io/myorg`.
+	// It applies to every OCI chart reference starting with it.
+	Host string `yaml:"host,omitempty"`
+	// Name is used to read the credentials from the `<NAME>_USERNAME` and `<NAME>_PASSWORD` environment variables,
+	// when Username and Password are omitted.
+	Name     string `yaml:"name,omitempty"`
+	Username string `yaml:"username,omitempty"`
+	Password string `yaml:"password,omitempty"`
+	// CaFile is the path to the CA bundle used to verify the registry's certificate
+	CaFile   string `yaml:"caFile,omitempty"`
+	CertFile string `yaml:"certFile,omitempty"`
+	KeyFile  string `yaml:"keyFile,omitempty"`
+	// InsecureSkipTLSVerify disables the verification of the registry's certificate
+	InsecureSkipTLSVerify bool `yaml:"insecureSkipTLSVerify,omitempty"`
+	// PlainHTTP makes helm access the registry over HTTP instead of HTTPS
+	PlainHTTP bool `yaml:"plainHttp,omitempty"`
+	// Mirrors are the hosts, optionally followed by path prefixes, tried in order before Host when pulling charts.
+	// Declare a mirror as another registry to give it its own credentials and TLS settings.
+	Mirrors []string `yaml:"mirrors,omitempty"`
+	// Cosign is the policy to verify the keyless cosign signature of every OCI chart pulled from the registry
+	Cosign *CosignSpec `yaml:"cosign,omitempty"`
+}
+
+func (r RegistrySpec) credentials() (string, string) {
+	if r.Name == "" {
+		return r.Username, r.Password
+	}
+	return gatherUsernamePassword(r.Name, r.Username, r.Password)
+}
+
+func (r RegistrySpec) tlsFlags() []string {
+	var flags []string
+	if r.CaFile != "" {
+		flags = append(flags, "--ca-file", r.CaFile)
+	}
+	if r.CertFile != "" {
+		flags = append(flags, "--cert-file", r.CertFile)
+	}
+	if r.KeyFile != "" {
+		flags = append(flags, "--key-file", r.KeyFile)
+	}
+	return flags
+}
+
+// loginFlags returns the flags for `helm registry login`
+func (r RegistrySpec) loginFlags() []string {
+	flags := r.tlsFlags()
+	if r.InsecureSkipTLSVerify || r.PlainHTTP {
+		flags = append(flags, "--insecure")
+	}
+	return flags
+}
+
+// pullFlags returns the flags for `helm pull`
+func (r RegistrySpec) pullFlags() []string {
+	flags := r.tlsFlags()
+	if r.InsecureSkipTLSVerify {
+		flags = append(flags, "--insecure-skip-tls-verify")
+	}
+	if r.PlainHTTP {
+		flags = append(flags, "--plain-http")
+	}
+	return flags
+}
+
+// loginHost returns the host part of Host, as `helm registry login` doesn't accept path prefixes
+func (r RegistrySpec) loginHost() string {
+	return strings.SplitN(r.Host, "/", 2)[0]
+}
+
+// getRegistry returns the registry with the longest Host matching the OCI chart reference like `registry/path/chart:tag`,
+// or nil if there's none.
+func (st *HelmState) getRegistry(ref string) *RegistrySpec {
+	var found *RegistrySpec
+	for i := range st.Registries {
+		r := &st.Registries[i]
+		if r.Host == "" || !strings.HasPrefix(ref, strings.TrimSuffix(r.Host, "/")+"/") {
+			continue
+		}
+		if found == nil || len(r.Host) > len(found.Host) {
+			found = r
+		}
+	}
+	return found
+}
+
+type ociPullCandidate struct {
+	ref   string
+	flags []string
+}
+
+// ociPullCandidates returns the chart references to try pulling in order, each with the helm flags for its registry.
+// The references on the mirrors of the registry come first, followed by the original reference.
+func (st *HelmState) ociPullCandidates(ref string) []ociPullCandidate {
+	reg := st.getRegistry(ref)
+	if reg == nil {
+		return []ociPullCandidate{{ref: ref}}
+	}
+
+	var candidates []ociPullCandidate
+
+	rest := strings.TrimPrefix(ref, strings.TrimSuffix(reg.Host, "/"))
+	for _, m := range reg.Mirrors {
+		mirrorRef := strings.TrimSuffix(m, "/") + rest
+		flags := reg.pullFlags()
+		if mirrorReg := st.getRegistry(mirrorRef); mirrorReg != nil {
+			flags = mirrorReg.pullFlags()
+		}
+		candidates = append(candidates, ociPullCandidate{ref: mirrorRef, flags: flags})
+	}
+
+	return append(candidates, ociPullCandidate{ref: ref, flags: reg.pullFlags()})
+}
+
+// resolveOCIDigest returns the digest of the manifest the OCI chart reference like `registry/app:1.0.0` points to,
+// trying the mirrors of the registry first like pulling the chart
+func (st *HelmState) resolveOCIDigest(helm helmexec.ChartDigester, ref string) (string, error) {
+	var err error
+	for _, c := range st.ociPullCandidates(ref) {
+		var digest string
+		digest, err = helm.ChartDigest(st.runContext(), c.ref, c.flags...)
+		if err == nil {
+			return digest, nil
+		}
+		st.logger.Warnf("failed resolving the digest of %s: %v", c.ref, err)
+	}
+	return "", err
+}
+
+// overrideOCITransportFlags replaces the --plain-http and --insecure-skip-tls-verify flags of the registry
+// for pulling the chart with the release's plainHttp and insecureSkipTLSVerify, if any.
+func (r *ReleaseSpec) overrideOCITransportFlags(flags []string) []string {
+	if r.PlainHTTP == nil && r.InsecureSkipTLSVerify == nil {
+		return flags
+	}
+
+	var (
+		overridden    []string
+		plainHTTP     bool
+		skipTLSVerify bool
+	)
+
+	for _, f := range flags {
+		switch f {
+		case "--plain-http":
+			plainHTTP = true
+		case "--insecure-skip-tls-verify":
+			skipTLSVerify = true
+		default:
+			overridden = append(overridden, f)
+		}
+	}
+
+	if r.PlainHTTP != nil {
+		plainHTTP = *r.PlainHTTP
+	}
+	if r.InsecureSkipTLSVerify != nil {
+		skipTLSVerify = *r.InsecureSkipTLSVerify
+	}
+
+	if skipTLSVerify {
+		overridden = append(overridden, "--insecure-skip-tls-verify")
+	}
+	if plainHTTP {
+		overridden = append(overridden, "--plain-http")
+	}
+
+	return overridden
+}
+
+// releasesRequireInsecureOCI returns true when any release with an OCI chart under the prefix like `registry/path`
+// enables plainHttp or insecureSkipTLSVerify, so that helmfile logs in to the registry with `--insecure`.
+func (st *HelmState) releasesRequireInsecureOCI(prefix string) bool {
+	prefix = strings.TrimSuffix(strings.TrimPrefix(prefix, "oci://"), "/") + "/"
+
+	for i := range st.Releases {
+		r := &st.Releases[i]
+
+		plainHTTP := r.PlainHTTP != nil && *r.PlainHTTP
+		skipTLSVerify := r.InsecureSkipTLSVerify != nil && *r.InsecureSkipTLSVerify
+		if !plainHTTP && !skipTLSVerify {
+			continue
+		}
+
+		if ref, _, _ := st.getOCIQualifiedChartName(r); ref != "" && strings.HasPrefix(ref, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,161 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/exectest"
+)
+
+func TestOCIPullCandidates(t *testing.T) {
+	st := &HelmState{
+		ReleaseSetSpec: ReleaseSetSpec{
+			Registries: []RegistrySpec{
+				{
+					Host:    "registry.example.com",
+					CaFile:  "ca.crt",
+					Mirrors: []string{"mirror.example.com/proxy", "plain.example.com"},
+				},
+				{
+					Host:                  "registry.example.com/insecure",
+					InsecureSkipTLSVerify: true,
+				},
+				{
+					Host:      "plain.example.com",
+					PlainHTTP: true,
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name string
+		ref  string
+		want []ociPullCandidate
+	}{
+		{
+			name: "no registry",
+			ref:  "other.example.com/charts/app:1.0.0",
+			want: []ociPullCandidate{{ref: "other.example.com/charts/app:1.0.0"}},
+		},
+		{
+			name: "mirrors come first",
+			ref:  "registry.example.com/charts/app:1.0.0",
+			want: []ociPullCandidate{
+				{ref: "mirror.example.com/proxy/charts/app:1.0.0", flags: []string{"--ca-file", "ca.crt"}},
+				{ref: "plain.example.com/charts/app:1.0.0", flags: []string{"--plain-http"}},
+				{ref: "registry.example.com/charts/app:1.0.0", flags: []string{"--ca-file", "ca.crt"}},
+			},
+		},
+		{
+			name: "longest host wins",
+			ref:  "registry.example.com/insecure/app@sha256:0123abcd",
+			want: []ociPullCandidate{
+				{ref: "registry.example.com/insecure/app@sha256:0123abcd", flags: []string{"--insecure-skip-tls-verify"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, st.ociPullCandidates(tt.ref))
+		})
+	}
+}
+
+func TestHelmState_SyncRepos_Registries(t *testing.T) {
+	t.Setenv("MY_REGISTRY_USERNAME", "user")
+	t.Setenv("MY_REGISTRY_PASSWORD", "pass")
+
+	st := &HelmState{
+		ReleaseSetSpec: ReleaseSetSpec{
+			Registries: []RegistrySpec{
+				{
+					Host: "anonymous.example.com",
+				},
+				{
+					Host:      "registry.example.com/charts",
+					Name:      "my-registry",
+					CaFile:    "ca.crt",
+					PlainHTTP: true,
+				},
+			},
+		},
+	}
+
+	helm := &exectest.Helm{}
+
+	updated, err := st.SyncRepos(helm, map[string]bool{})
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"registry.example.com"}, updated)
+	require.Equal(t, []string{"registry.example.com", "user", "pass", "--ca-file", "ca.crt", "--insecure"}, helm.Registry)
+}
+
+func TestReleaseSpec_overrideOCITransportFlags(t *testing.T) {
+	enable := true
+	disable := false
+
+	tests := []struct {
+		name    string
+		release ReleaseSpec
+		flags   []string
+		want    []string
+	}{
+		{
+			name:  "registry settings",
+			flags: []string{"--ca-file", "ca.crt", "--plain-http"},
+			want:  []string{"--ca-file", "ca.crt", "--plain-http"},
+		},
+		{
+			name:    "release enables",
+			release: ReleaseSpec{PlainHTTP: &enable, InsecureSkipTLSVerify: &enable},
+			flags:   []string{"--ca-file", "ca.crt"},
+			want:    []string{"--ca-file", "ca.crt", "--insecure-skip-tls-verify", "--plain-http"},
+		},
+		{
+			name:    "release disables",
+			release: ReleaseSpec{PlainHTTP: &disable},
+			flags:   []string{"--insecure-skip-tls-verify", "--plain-http"},
+			want:    []string{"--insecure-skip-tls-verify"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, tt.release.overrideOCITransportFlags(tt.flags))
+		})
+	}
+}
+
+func TestHelmState_SyncRepos_InsecureRelease(t *testing.T) {
+	enable := true
+
+	st := &HelmState{
+		ReleaseSetSpec: ReleaseSetSpec{
+			Registries: []RegistrySpec{
+				{
+					Host:     "lab.example.com",
+					Username: "user",
+					Password: "pass",
+				},
+			},
+			Releases: []ReleaseSpec{
+				{
+					Name:      "app",
+					Chart:     "oci://lab.example.com/charts/app",
+					Version:   "1.0.0",
+					PlainHTTP: &enable,
+				},
+			},
+		},
+	}
+
+	helm := &exectest.Helm{}
+
+	_, err := st.SyncRepos(helm, map[string]bool{})
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"lab.example.com", "user", "pass", "--insecure"}, helm.Registry)
+}
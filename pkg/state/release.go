@@ -49,6 +49,22 @@ func (r ReleaseSpec) ExecuteTemplateExpressions(renderer *tmpl.FileRenderer) (*R
 		}
 	}
 
+	{
+		ts := result.ChartVersion
+		result.ChartVersion, err = renderer.RenderTemplateContentToString([]byte(ts))
+		if err != nil {
+			return nil, fmt.Errorf("failed executing template expressions in release \"%s\".chartVersion = \"%s\": %v", r.Name, ts, err)
+		}
+	}
+
+	{
+		ts := result.ChartAppVersion
+		result.ChartAppVersion, err = renderer.RenderTemplateContentToString([]byte(ts))
+		if err != nil {
+			return nil, fmt.Errorf("failed executing template expressions in release \"%s\".chartAppVersion = \"%s\": %v", r.Name, ts, err)
+		}
+	}
+
 	if result.WaitTemplate != nil {
 		ts := *result.WaitTemplate
 		resultTmpl, err := renderer.RenderTemplateContentToString([]byte(ts))
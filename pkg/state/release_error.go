@@ -1,7 +1,11 @@
 package state
 
 import (
+	"errors"
 	"fmt"
+
+	helmfileerrors "github.com/helmfile/helmfile/pkg/errors"
+	"github.com/helmfile/helmfile/pkg/helmexec"
 )
 
 const ReleaseErrorCodeFailure = 1
@@ -16,6 +20,17 @@ func (e *ReleaseError) Error() string {
 	return e.err.Error()
 }
 
+func (e *ReleaseError) Describe() helmfileerrors.Detail {
+	code := helmfileerrors.CodeReleaseFailed
+	var timeoutErr *helmexec.TimeoutError
+	if e.Code == 2 {
+		code = helmfileerrors.CodeDiffDetected
+	} else if errors.As(e.err, &timeoutErr) {
+		code = helmfileerrors.CodeTimeout
+	}
+	return helmfileerrors.Detail{Code: code, Release: ReleaseToID(e.ReleaseSpec)}
+}
+
 func NewReleaseError(release *ReleaseSpec, err error, code int) *ReleaseError {
 	return &ReleaseError{
 		ReleaseSpec: release,
@@ -25,7 +40,7 @@ func NewReleaseError(release *ReleaseSpec, err error, code int) *ReleaseError {
 }
 
 func newReleaseFailedError(release *ReleaseSpec, err error) *ReleaseError {
-	wrappedErr := fmt.Errorf("failed processing release %s: %v", release.Name, err.Error())
+	wrappedErr := fmt.Errorf("failed processing release %s: %w", release.Name, err)
 
 	return NewReleaseError(release, wrappedErr, ReleaseErrorCodeFailure)
 }
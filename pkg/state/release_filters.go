@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+
+	"github.com/helmfile/helmfile/pkg/yaml"
 )
 
 // ReleaseFilter is used to determine if a given release should be used during helmfile execution
@@ -21,11 +23,16 @@ type LabelFilter struct {
 
 // Match will match a release that has the same labels as the filter
 func (l LabelFilter) Match(r ReleaseSpec) bool {
+	return l.MatchLabels(r.Labels)
+}
+
+// MatchLabels will match the labels, like the ones of a release or a sub-helmfile, that are the same as the filter
+func (l LabelFilter) MatchLabels(labels map[string]string) bool {
 	if len(l.positiveLabels) > 0 {
 		for _, element := range l.positiveLabels {
 			k := element[0]
 			v := element[1]
-			if rVal, ok := r.Labels[k]; !ok {
+			if rVal, ok := labels[k]; !ok {
 				return false
 			} else if rVal != v {
 				return false
@@ -37,7 +44,7 @@ func (l LabelFilter) Match(r ReleaseSpec) bool {
 		for _, element := range l.negativeLabels {
 			k := element[0]
 			v := element[1]
-			if rVal, ok := r.Labels[k]; !ok {
+			if rVal, ok := labels[k]; !ok {
 
 			} else if rVal == v {
 				return false
@@ -69,3 +76,29 @@ func ParseLabels(l string) (LabelFilter, error) {
 	}
 	return lf, err
 }
+
+// ParseSelectors parses the content of a selector file, that is either a YAML list of selectors
+// or one selector per line. Blank lines and comments starting with `#` are ignored.
+func ParseSelectors(content []byte) ([]string, error) {
+	var selectors []string
+	if err := yaml.Unmarshal(content, &selectors); err != nil {
+		selectors = nil
+		for _, line := range strings.Split(string(content), "\n") {
+			if i := strings.Index(line, "#"); i >= 0 {
+				line = line[:i]
+			}
+			line = strings.TrimSpace(line)
+			if line != "" {
+				selectors = append(selectors, line)
+			}
+		}
+	}
+
+	for _, s := range selectors {
+		if _, err := ParseLabels(s); err != nil {
+			return nil, err
+		}
+	}
+
+	return selectors, nil
+}
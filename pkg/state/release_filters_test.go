@@ -0,0 +1,55 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSelectors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "one per line",
+			content: `# frontend releases except the proxy
+tier=frontend,tier!=proxy
+
+tier=backend # and all the backends
+`,
+			want: []string{"tier=frontend,tier!=proxy", "tier=backend"},
+		},
+		{
+			name: "yaml list",
+			content: `# releases deployed by the nightly job
+- tier=frontend,tier!=proxy
+- name=myrelease
+`,
+			want: []string{"tier=frontend,tier!=proxy", "name=myrelease"},
+		},
+		{
+			name:    "comments only",
+			content: "# nothing\n",
+		},
+		{
+			name:    "malformed",
+			content: "tier\n",
+			wantErr: "malformed label: tier. Expected label in form k=v or k!=v",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSelectors([]byte(tt.content))
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
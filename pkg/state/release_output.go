@@ -0,0 +1,98 @@
+package state
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/helmfile/helmfile/pkg/helmexec"
+)
+
+// The log outputs of the releases processed concurrently
+const (
+	// LogOutputInterleaved prints the logs and the outputs of the releases as they come, interleaving the ones of the releases processed concurrently
+	LogOutputInterleaved = "interleaved"
+	// LogOutputGrouped buffers the logs and the outputs of each release, and prints them as a contiguous block on completion
+	LogOutputGrouped = "grouped"
+)
+
+// releaseOutput is the buffered logs and outputs of a release in the grouped log output
+type releaseOutput struct {
+	id     string
+	start  time.Time
+	logger *zap.SugaredLogger
+
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (o *releaseOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.buf.Write(p)
+}
+
+func (o *releaseOutput) Sync() error {
+	return nil
+}
+
+// withReleaseOutput returns the copy of the state whose logs are buffered into the output of the release in the grouped log output,
+// with the output set as the writer of the helm context.
+// It returns the state itself and a nil output otherwise.
+func (st *HelmState) withReleaseOutput(release *ReleaseSpec, context *helmexec.HelmContext, logOutput string) (*HelmState, *releaseOutput) {
+	if logOutput != LogOutputGrouped {
+		return st, nil
+	}
+
+	o := &releaseOutput{
+		id:     ReleaseToID(release),
+		start:  time.Now(),
+		logger: st.logger,
+	}
+
+	var cfg zapcore.EncoderConfig
+	cfg.MessageKey = "message"
+
+	core := zapcore.NewCore(zapcore.NewConsoleEncoder(cfg), o, zap.LevelEnablerFunc(st.logger.Desugar().Core().Enabled))
+
+	rst := *st
+	rst.logger = zap.New(core).Sugar()
+
+	context.Writer = o
+
+	return &rst, o
+}
+
+// flush prints the buffered logs and outputs of the release as a block, each line prefixed by the release ID,
+// followed by the result and the time it took
+func (o *releaseOutput) flush(failed bool) {
+	if o == nil {
+		return
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var sb strings.Builder
+
+	for _, l := range strings.Split(strings.TrimRight(o.buf.String(), "\n"), "\n") {
+		if l == "" {
+			continue
+		}
+		fmt.Fprintf(&sb, "%s | %s\n", o.id, l)
+	}
+
+	result := "completed"
+	if failed {
+		result = "failed"
+	}
+
+	fmt.Fprintf(&sb, "%s | %s in %s", o.id, result, time.Since(o.start).Round(time.Millisecond))
+
+	o.logger.Info(sb.String())
+}
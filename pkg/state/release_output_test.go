@@ -0,0 +1,47 @@
+package state
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/helmexec"
+)
+
+func TestHelmState_withReleaseOutput(t *testing.T) {
+	var logs bytes.Buffer
+
+	st := &HelmState{
+		logger: helmexec.NewLogger(&logs, "info"),
+	}
+
+	release := &ReleaseSpec{Name: "web", Namespace: "default"}
+
+	context := helmexec.HelmContext{}
+	rst, output := st.withReleaseOutput(release, &context, LogOutputInterleaved)
+	require.Same(t, st, rst)
+	require.Nil(t, output)
+	require.Nil(t, context.Writer)
+	output.flush(false)
+
+	rst, output = st.withReleaseOutput(release, &context, LogOutputGrouped)
+	require.NotSame(t, st, rst)
+
+	rst.logger.Info("hook: presync")
+	rst.logger.Debug("not logged at the info level")
+	fmt.Fprintln(context.Writer, "Release \"web\" has been upgraded.")
+	st.logger.Info("another release")
+
+	require.Equal(t, "another release\n", logs.String(), "the logs of the release should be buffered until flushed")
+
+	output.flush(true)
+
+	require.Regexp(t, regexp.MustCompile(`^another release
+default/web \| hook: presync
+default/web \| Release "web" has been upgraded.
+default/web \| failed in \S+
+$`), logs.String())
+}
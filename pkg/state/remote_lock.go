@@ -0,0 +1,204 @@
+package state
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/helmfile/helmfile/pkg/remote"
+)
+
+// LockedRemote is a remote helmfile, values file or secrets file pinned in the lock file by the checksum of its content
+type LockedRemote struct {
+	// URL is the go-getter URL of the file, like `git::https://github.com/org/repo.git@values.yaml?ref=main`
+	URL string `yaml:"url"`
+	// Checksum is the sha256 checksum of the file like `sha256:...`,
+	// or of the paths and the contents of the files in it when it's a directory
+	Checksum string `yaml:"checksum"`
+}
+
+// remoteSources returns the go-getter URLs of the remote sub-helmfiles, values files and secrets files of the state, sorted
+func (st *HelmState) remoteSources() []string {
+	seen := map[string]bool{}
+
+	var srcs []string
+
+	add := func(s string) {
+		if seen[s] || !remote.IsRemote(s) {
+			return
+		}
+		seen[s] = true
+		srcs = append(srcs, s)
+	}
+
+	addEntries := func(entries []interface{}) {
+		for _, e := range entries {
+			if s, ok := e.(string); ok {
+				add(s)
+			}
+		}
+	}
+
+	for _, h := range st.Helmfiles {
+		add(h.Path)
+	}
+
+	addEntries(st.DefaultValues)
+
+	for _, env := range st.Environments {
+		addEntries(env.Values)
+		for _, s := range env.Secrets {
+			add(s)
+		}
+	}
+
+	for _, r := range st.Releases {
+		addEntries(r.Values)
+		addEntries(r.Secrets)
+	}
+
+	sort.Strings(srcs)
+
+	return srcs
+}
+
+// fetchRemoteSource returns the path to the fetched remote file.
+// With refresh, the file is fetched again into the caches of both the helmfiles and the values files,
+// so that the later runs read the content locked now.
+func (st *HelmState) fetchRemoteSource(src string, refresh bool) (string, error) {
+	r := st.newRemote()
+
+	if !refresh {
+		return r.Fetch(src)
+	}
+
+	if _, err := r.Refresh(src, "values"); err != nil {
+		return "", err
+	}
+
+	return r.Refresh(src)
+}
+
+// remoteChecksum returns the checksum of the fetched file, or of the paths and the contents of the files in the fetched directory
+func remoteChecksum(path string) (string, error) {
+	h := sha256.New()
+
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			// The metadata of the git repositories differs among the clones of the same commit
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(path, p)
+		if err != nil {
+			return err
+		}
+
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(h, "%s\n%d\n", filepath.ToSlash(rel), len(content))
+		h.Write(content)
+
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// lockRemotes returns the remote sources of the state pinned by the checksums of their contents.
+// The sources already locked keep their checksums, unless updateLocks is true, in which case all the sources are fetched again.
+func (st *HelmState) lockRemotes(locked []LockedRemote, updateLocks bool) ([]LockedRemote, error) {
+	checksums := map[string]string{}
+	for _, l := range locked {
+		checksums[l.URL] = l.Checksum
+	}
+
+	remotes := []LockedRemote{}
+
+	for _, src := range st.remoteSources() {
+		if checksum, ok := checksums[src]; ok && !updateLocks {
+			remotes = append(remotes, LockedRemote{URL: src, Checksum: checksum})
+			continue
+		}
+
+		path, err := st.fetchRemoteSource(src, updateLocks)
+		if err != nil {
+			return nil, fmt.Errorf("locking %s: %w", src, err)
+		}
+
+		checksum, err := remoteChecksum(path)
+		if err != nil {
+			return nil, fmt.Errorf("locking %s: %w", src, err)
+		}
+
+		st.logger.Debugf("locked %s to %s", src, checksum)
+
+		remotes = append(remotes, LockedRemote{URL: src, Checksum: checksum})
+	}
+
+	return remotes, nil
+}
+
+// verifyLockedRemotes fails when the content of any remote source of the state differs from the one locked in the lock file,
+// like when the ref of the git repository is moved to another commit.
+// The sources not locked yet are used as they are.
+func (st *HelmState) verifyLockedRemotes() error {
+	srcs := map[string]bool{}
+	for _, src := range st.remoteSources() {
+		srcs[src] = true
+	}
+
+	if len(srcs) == 0 {
+		return nil
+	}
+
+	depMan := NewChartDependencyManager(stateFileBaseName(st.FilePath), st.logger, st.LockFile)
+
+	if st.fs.ReadFile != nil {
+		depMan.readFile = st.fs.ReadFile
+	}
+
+	locked, err := depMan.lockedRemotes()
+	if err != nil {
+		return err
+	}
+
+	for _, l := range locked {
+		if !srcs[l.URL] {
+			continue
+		}
+
+		path, err := st.fetchRemoteSource(l.URL, false)
+		if err != nil {
+			return err
+		}
+
+		checksum, err := remoteChecksum(path)
+		if err != nil {
+			return err
+		}
+
+		if checksum != l.Checksum {
+			return fmt.Errorf("the content of %s doesn't match the checksum %s in the lock file %s: got %s. Run `helmfile deps --update-locks` to lock the new content", l.URL, l.Checksum, depMan.lockFileName(), checksum)
+		}
+	}
+
+	return nil
+}
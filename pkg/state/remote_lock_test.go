@@ -0,0 +1,87 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/envvar"
+	"github.com/helmfile/helmfile/pkg/filesystem"
+	"github.com/helmfile/helmfile/pkg/yaml"
+)
+
+func TestRemoteSources(t *testing.T) {
+	st := &HelmState{
+		ReleaseSetSpec: ReleaseSetSpec{
+			Helmfiles: []SubHelmfileSpec{
+				{Path: "git::https://github.com/org/repo.git@helmfile.yaml?ref=v1"},
+				{Path: "apps/helmfile.yaml"},
+			},
+			Environments: map[string]EnvironmentSpec{
+				"prod": {
+					Values:  []interface{}{"git::https://github.com/org/repo.git@values.yaml?ref=v1", map[string]interface{}{"a": 1}},
+					Secrets: []string{"s3::https://s3.amazonaws.com/bucket/dir@secrets.yaml"},
+				},
+			},
+			Releases: []ReleaseSpec{
+				{Name: "a", Values: []interface{}{"git::https://github.com/org/repo.git@values.yaml?ref=v1", "values.yaml"}},
+			},
+		},
+	}
+
+	require.Equal(t, []string{
+		"git::https://github.com/org/repo.git@helmfile.yaml?ref=v1",
+		"git::https://github.com/org/repo.git@values.yaml?ref=v1",
+		"s3::https://s3.amazonaws.com/bucket/dir@secrets.yaml",
+	}, st.remoteSources())
+}
+
+func TestLockRemotes(t *testing.T) {
+	cacheHome := t.TempDir()
+	t.Setenv(envvar.CacheHome, cacheHome)
+
+	// The remote file is already in the cache, so that it isn't fetched
+	cached := filepath.Join(cacheHome, "https_github_com_org_repo_git.ref=main", "values.yaml")
+	require.NoError(t, os.MkdirAll(filepath.Dir(cached), 0755))
+	require.NoError(t, os.WriteFile(cached, []byte("replicas: 1\n"), 0644))
+
+	src := "git::https://github.com/org/repo.git@values.yaml?ref=main"
+
+	st := &HelmState{
+		FilePath: "helmfile.yaml",
+		ReleaseSetSpec: ReleaseSetSpec{
+			LockFile: filepath.Join(t.TempDir(), "helmfile.lock"),
+			Releases: []ReleaseSpec{
+				{Name: "a", Values: []interface{}{src}},
+			},
+		},
+		logger: logger,
+		fs:     filesystem.DefaultFileSystem(),
+	}
+
+	remotes, err := st.lockRemotes(nil, false)
+	require.NoError(t, err)
+	require.Len(t, remotes, 1)
+	require.Equal(t, src, remotes[0].URL)
+	require.Regexp(t, "^sha256:[0-9a-f]{64}$", remotes[0].Checksum)
+
+	kept, err := st.lockRemotes([]LockedRemote{{URL: src, Checksum: "sha256:old"}}, false)
+	require.NoError(t, err)
+	require.Equal(t, []LockedRemote{{URL: src, Checksum: "sha256:old"}}, kept, "the checksums locked are kept without --update-locks")
+
+	require.NoError(t, st.verifyLockedRemotes(), "nothing is verified without the lock file")
+
+	lock, err := yaml.Marshal(&ChartLockedRequirements{Remotes: remotes})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(st.LockFile, lock, 0644))
+
+	require.NoError(t, st.verifyLockedRemotes())
+
+	require.NoError(t, os.WriteFile(cached, []byte("replicas: 2\n"), 0644))
+
+	err = st.verifyLockedRemotes()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "the content of "+src+" doesn't match the checksum "+remotes[0].Checksum)
+}
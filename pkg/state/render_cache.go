@@ -0,0 +1,305 @@
+package state
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+
+	"github.com/helmfile/helmfile/pkg/helmexec"
+)
+
+// templateRelease runs `helm template` on the release and writes the manifests to opts.Stdout.
+// When opts.RenderCacheDir is set, the manifests are cached in it,
+// and reused as long as the chart, the values and the flags of the release stay the same, unless opts.RefreshRenderCache is set.
+func (st *HelmState) templateRelease(helm helmexec.Interface, release *ReleaseSpec, args, flags []string, opts *TemplateOpts, outputDir string) error {
+	var cacheFile string
+
+	if opts.RenderCacheDir != "" {
+		key, err := st.renderCacheKey(helm, release, args, flags)
+		if err != nil {
+			return err
+		}
+
+		if key != "" {
+			cacheFile = filepath.Join(opts.RenderCacheDir, key+".yaml")
+		} else {
+			st.logger.Debugf("skipped caching the manifests of release %s, which depend on anything other than its chart, values and flags", release.Name)
+		}
+	}
+
+	if cacheFile != "" && !opts.RefreshRenderCache {
+		if out, err := os.ReadFile(cacheFile); err == nil {
+			st.logger.Infof("Using the cached manifests of release=%v, chart=%v", release.Name, release.Chart)
+			touchCacheEntry(cacheFile)
+			return st.writeManifests(release, out, opts, outputDir)
+		}
+	}
+
+	if cacheFile == "" && !opts.AnnotateSource && opts.OnManifests == nil && len(st.manifestTransformers(release)) == 0 {
+		return helm.TemplateRelease(st.createHelmContextWithWriter(release, opts.Stdout), release.Name, release.ChartPathOrName(), flags...)
+	}
+
+	buf := &bytes.Buffer{}
+	err := helm.TemplateRelease(st.createHelmContextWithWriter(release, buf), release.Name, release.ChartPathOrName(), flags...)
+	if err != nil {
+		// The manifests rendered before the failure are still shown, to help debugging it
+		if outputDir == "" {
+			if writeErr := st.writeManifests(release, buf.Bytes(), opts, ""); writeErr != nil {
+				st.logger.Warnf("unable to write the manifests of release %s: %v", release.Name, writeErr)
+			}
+		}
+		return err
+	}
+
+	if err := st.writeManifests(release, buf.Bytes(), opts, outputDir); err != nil {
+		return err
+	}
+
+	if cacheFile != "" && secretManifestRegexp.Match(buf.Bytes()) {
+		st.logger.Debugf("skipped caching the manifests of release %s, which contain Secrets", release.Name)
+	} else if cacheFile != "" {
+		if err := writeCacheEntry(opts.RenderCacheDir, cacheFile, buf.Bytes()); err != nil {
+			st.logger.Warnf("unable to cache the manifests of release %s: %v", release.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// writeManifests transforms the manifests of the release with its transformers, if any, and writes them to outputDir if it isn't empty,
+// or else passes them to OnManifests if set, or else prints them to opts.Stdout
+func (st *HelmState) writeManifests(release *ReleaseSpec, out []byte, opts *TemplateOpts, outputDir string) error {
+	out, err := st.transformManifests(release, out)
+	if err != nil {
+		return err
+	}
+	if outputDir != "" {
+		return st.writeManifestsToDir(outputDir, out)
+	}
+	if opts.AnnotateSource {
+		out = annotateManifests(out, st.sourceAnnotations(release))
+	}
+	if opts.OnManifests != nil {
+		opts.OnManifests(release, out)
+		return nil
+	}
+	w := opts.Stdout
+	if w == nil {
+		w = os.Stdout
+	}
+	fmt.Fprint(w, string(out))
+	return nil
+}
+
+// renderCacheKey returns the key the manifests of the release are cached with,
+// which is the hash of the helm version, the release name, the chart, the contents of the values files and the rest of the args and flags.
+// It returns an empty key when the manifests may change without any of them changing,
+// like when the chart is a remote one whose version isn't pinned, they are post-rendered, or they are validated against the cluster.
+func (st *HelmState) renderCacheKey(helm helmexec.Interface, release *ReleaseSpec, args, flags []string) (string, error) {
+	// The decrypted secrets are never cached, nor are the manifests rendered from them
+	if len(release.Secrets) > 0 {
+		return "", nil
+	}
+
+	h := sha256.New()
+
+	v := helm.GetVersion()
+	fmt.Fprintf(h, "helm=%d.%d.%d\nrelease=%s\n", v.Major, v.Minor, v.Patch, release.Name)
+
+	chart := release.ChartPathOrName()
+	if st.fs.DirectoryExistsAt(chart) {
+		if err := hashDir(h, chart); err != nil {
+			return "", fmt.Errorf("hashing chart %s: %v", chart, err)
+		}
+	} else {
+		_, digest, err := releaseChartDigest(release)
+		if err != nil {
+			return "", err
+		}
+		if _, err := semver.StrictNewVersion(release.Version); err != nil && digest == "" {
+			return "", nil
+		}
+		fmt.Fprintf(h, "chart=%s\nversion=%s\ndigest=%s\n", chart, release.Version, digest)
+	}
+
+	flags = append(append([]string{}, args...), flags...)
+
+	for i := 0; i < len(flags); i++ {
+		f := flags[i]
+
+		switch f {
+		case "--validate", "--output-dir", "--post-renderer":
+			return "", nil
+		case "--values", "-f", "--set-file":
+			if i+1 >= len(flags) {
+				break
+			}
+			i++
+
+			file := flags[i]
+			if f == "--set-file" {
+				k, v, _ := strings.Cut(file, "=")
+				fmt.Fprintf(h, "%s=%s=", f, k)
+				file = v
+			} else {
+				fmt.Fprintf(h, "%s=", f)
+			}
+
+			bs, err := os.ReadFile(file)
+			if err != nil {
+				// The values that can't be read here, like remote ones, may change any time
+				return "", nil
+			}
+			fmt.Fprintf(h, "%x\n", sha256.Sum256(bs))
+
+			continue
+		}
+
+		fmt.Fprintf(h, "%s\n", f)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashDir writes the paths and the contents of all the files in the directory to the hash, in the lexical order of the paths
+func hashDir(h hash.Hash, dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = f.Close()
+		}()
+
+		fmt.Fprintf(h, "file=%s\n", filepath.ToSlash(rel))
+		_, err = io.Copy(h, f)
+		return err
+	})
+}
+
+// diffCacheFile returns the file recording that the release had no changes with the diff flags,
+// named after the render cache key of the flags along with the release deployed in the cluster as listed by helm,
+// so that any upgrade, rollback or uninstall of the release invalidates it.
+// It returns an empty path when the result of the diff can't be cached, like when the release isn't installed yet.
+func (st *HelmState) diffCacheFile(helm helmexec.Interface, release *ReleaseSpec, flags []string, cacheDir string) string {
+	key, err := st.renderCacheKey(helm, release, nil, flags)
+	if err != nil || key == "" {
+		return ""
+	}
+
+	deployed, err := st.listReleases(st.createHelmContext(release, 0), helm, release)
+	if err != nil {
+		st.logger.Debugf("skipped caching the diff of release %s: %v", release.Name, err)
+		return ""
+	}
+	if strings.TrimSpace(deployed) == "" {
+		return ""
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s", key, deployed)
+
+	return filepath.Join(cacheDir, hex.EncodeToString(h.Sum(nil)))
+}
+
+// cacheDiff records that the release had no changes
+func (st *HelmState) cacheDiff(release *ReleaseSpec, cacheFile string) {
+	if err := writeCacheEntry(filepath.Dir(cacheFile), cacheFile, nil); err != nil {
+		st.logger.Warnf("unable to cache the diff of release %s: %v", release.Name, err)
+	}
+}
+
+// maxCacheEntries is the number of the entries kept in each of the render and the diff caches.
+// The least recently used ones are evicted beyond it.
+const maxCacheEntries = 256
+
+// secretManifestRegexp matches the manifests of Secrets, which are never cached
+var secretManifestRegexp = regexp.MustCompile(`(?m)^kind:\s*["']?Secret["']?\s*$`)
+
+// writeCacheEntry writes the entry to the cache directory, readable only by the user,
+// and evicts the least recently used entries beyond maxCacheEntries
+func writeCacheEntry(dir, file string, data []byte) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(file, data, 0600); err != nil {
+		return err
+	}
+
+	return evictCacheEntries(dir, maxCacheEntries)
+}
+
+// touchCacheEntry marks the entry as used, so that it's evicted after the entries used less recently
+func touchCacheEntry(file string) {
+	now := time.Now()
+	_ = os.Chtimes(file, now, now)
+}
+
+// evictCacheEntries removes the files in the directory used least recently, as told by their modification times,
+// until max files are left
+func evictCacheEntries(dir string, max int) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	type entry struct {
+		path    string
+		modTime time.Time
+	}
+
+	var files []entry
+
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, entry{filepath.Join(dir, e.Name()), info.ModTime()})
+	}
+
+	if len(files) <= max {
+		return nil
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+
+	for _, f := range files[:len(files)-max] {
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,185 @@
+package state
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/exectest"
+	"github.com/helmfile/helmfile/pkg/filesystem"
+	"github.com/helmfile/helmfile/pkg/helmexec"
+)
+
+// manifestHelm writes the manifest on `helm template`
+type manifestHelm struct {
+	exectest.Helm
+
+	manifest string
+}
+
+func (helm *manifestHelm) TemplateRelease(context helmexec.HelmContext, name, chart string, flags ...string) error {
+	helm.Templated = append(helm.Templated, exectest.Release{Name: name, Flags: flags})
+	if context.Writer != nil {
+		fmt.Fprint(context.Writer, helm.manifest)
+	}
+	return nil
+}
+
+func TestHelmState_templateRelease_RenderCache(t *testing.T) {
+	dir := t.TempDir()
+
+	chart := filepath.Join(dir, "charts", "web")
+	require.NoError(t, os.MkdirAll(chart, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(chart, "Chart.yaml"), []byte("name: web\nversion: 1.0.0\n"), 0644))
+
+	values := filepath.Join(dir, "values.yaml")
+	require.NoError(t, os.WriteFile(values, []byte("replicas: 1\n"), 0644))
+
+	st := &HelmState{
+		fs:     filesystem.DefaultFileSystem(),
+		logger: logger,
+	}
+
+	helm := &manifestHelm{
+		Helm:     exectest.Helm{Version: semver.MustParse("3.10.0")},
+		manifest: "kind: ConfigMap\n",
+	}
+
+	opts := &TemplateOpts{RenderCacheDir: filepath.Join(dir, "cache")}
+
+	local := &ReleaseSpec{Name: "web", Chart: chart}
+	flags := []string{"--values", values}
+
+	require.NoError(t, st.templateRelease(helm, local, nil, flags, opts, ""))
+	require.NoError(t, st.templateRelease(helm, local, nil, flags, opts, ""))
+	require.Len(t, helm.Templated, 1, "the cached manifests should be reused")
+
+	require.NoError(t, os.WriteFile(values, []byte("replicas: 2\n"), 0644))
+	require.NoError(t, st.templateRelease(helm, local, nil, flags, opts, ""))
+	require.Len(t, helm.Templated, 2, "the manifests should be rendered again on the change of the values")
+
+	require.NoError(t, st.templateRelease(helm, local, []string{"--set", "replicas=3"}, flags, opts, ""))
+	require.Len(t, helm.Templated, 3, "the manifests should be rendered again on the change of the args")
+
+	unpinned := &ReleaseSpec{Name: "db", Chart: "stable/db"}
+	require.NoError(t, st.templateRelease(helm, unpinned, nil, nil, opts, ""))
+	require.NoError(t, st.templateRelease(helm, unpinned, nil, nil, opts, ""))
+	require.Len(t, helm.Templated, 5, "the manifests of the remote chart of no pinned version should not be cached")
+
+	pinned := &ReleaseSpec{Name: "db", Chart: "stable/db", Version: "1.2.3"}
+	require.NoError(t, st.templateRelease(helm, pinned, nil, nil, opts, ""))
+	require.NoError(t, st.templateRelease(helm, pinned, nil, nil, opts, ""))
+	require.Len(t, helm.Templated, 6)
+
+	refresh := &TemplateOpts{RenderCacheDir: opts.RenderCacheDir, RefreshRenderCache: true}
+	require.NoError(t, st.templateRelease(helm, pinned, nil, nil, refresh, ""))
+	require.Len(t, helm.Templated, 7, "the manifests should be rendered again when the cache is refreshed")
+	require.NoError(t, st.templateRelease(helm, pinned, nil, nil, opts, ""))
+	require.Len(t, helm.Templated, 7, "the refreshed manifests should be cached")
+
+	withSecrets := &ReleaseSpec{Name: "api", Chart: chart, Secrets: []interface{}{"secrets.yaml"}}
+	require.NoError(t, st.templateRelease(helm, withSecrets, nil, nil, opts, ""))
+	require.NoError(t, st.templateRelease(helm, withSecrets, nil, nil, opts, ""))
+	require.Len(t, helm.Templated, 9, "the manifests of the release with secrets should not be cached")
+
+	helm.manifest = "apiVersion: v1\nkind: Secret\ndata:\n  password: c2VjcmV0\n"
+	secret := &ReleaseSpec{Name: "secret", Chart: chart}
+	require.NoError(t, st.templateRelease(helm, secret, nil, nil, opts, ""))
+	require.NoError(t, st.templateRelease(helm, secret, nil, nil, opts, ""))
+	require.Len(t, helm.Templated, 11, "the manifests containing Secrets should not be cached")
+
+	info, err := os.Stat(opts.RenderCacheDir)
+	require.NoError(t, err)
+	if runtime.GOOS != "windows" {
+		require.Equal(t, os.FileMode(0700), info.Mode().Perm())
+	}
+}
+
+func TestEvictCacheEntries(t *testing.T) {
+	dir := t.TempDir()
+
+	now := time.Now()
+	for i, name := range []string{"oldest", "old", "new"} {
+		f := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(f, nil, 0600))
+		mtime := now.Add(time.Duration(i-3) * time.Hour)
+		require.NoError(t, os.Chtimes(f, mtime, mtime))
+	}
+
+	require.NoError(t, evictCacheEntries(dir, 2))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	require.Equal(t, []string{"new", "old"}, names)
+}
+
+// changingHelm finds the changes in the releases in changed on `helm diff`
+type changingHelm struct {
+	exectest.Helm
+
+	changed map[string]bool
+}
+
+func (helm *changingHelm) DiffRelease(context helmexec.HelmContext, name, chart string, suppressDiff bool, flags ...string) error {
+	_ = helm.Helm.DiffRelease(context, name, chart, suppressDiff, flags...)
+	if helm.changed[name] {
+		return helmexec.ExitError{Code: 2}
+	}
+	return nil
+}
+
+func TestHelmState_DiffReleases_Cache(t *testing.T) {
+	dir := t.TempDir()
+
+	st := &HelmState{
+		ReleaseSetSpec: ReleaseSetSpec{
+			Releases: []ReleaseSpec{
+				{Name: "db", Chart: "stable/db", Version: "1.2.3"},
+				{Name: "web", Chart: "stable/web"},
+			},
+		},
+		fs:             filesystem.DefaultFileSystem(),
+		logger:         logger,
+		valsRuntime:    valsRuntime,
+		RenderedValues: map[string]interface{}{},
+	}
+
+	helm := &changingHelm{Helm: exectest.Helm{Version: semver.MustParse("3.10.0")}}
+
+	opts := &DiffOpts{Stdout: io.Discard, CacheDir: filepath.Join(dir, "diffs")}
+
+	diffed := func() []string {
+		_, _ = st.DiffReleases(helm, []string{}, 1, true, false, []string{}, false, false, false, false, false, opts)
+
+		var names []string
+		for _, r := range helm.Diffed {
+			names = append(names, r.Name)
+		}
+		helm.Diffed = nil
+		return names
+	}
+
+	require.Equal(t, []string{"db", "web"}, diffed())
+	require.Equal(t, []string{"web"}, diffed(), "the release of the pinned chart found with no changes should be skipped")
+
+	helm.Lists = map[exectest.ListKey]string{
+		{Filter: "^db$", Flags: "--uninstalling--deployed--failed--pending"}: "db\tdefault\t2\t2024-01-01 00:00:00\tdeployed\tdb-1.2.3\t1.0.0",
+	}
+	require.Equal(t, []string{"db", "web"}, diffed(), "the release should be diffed again once another revision is deployed")
+
+	st.Releases[0].Version = "1.2.4"
+	helm.changed = map[string]bool{"db": true}
+	require.Equal(t, []string{"db", "web"}, diffed())
+	require.Equal(t, []string{"db", "web"}, diffed(), "the release with changes should be diffed again")
+}
@@ -0,0 +1,86 @@
+package state
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ParseRepositoryMirror parses the repository mirror given like `https://charts.bitnami.com/*=https://nexus.internal/bitnami`
+// into the pattern of the repository URLs and the URL of the mirror
+func ParseRepositoryMirror(s string) (string, string, error) {
+	from, to, ok := strings.Cut(s, "=")
+	if !ok || strings.TrimSpace(from) == "" || strings.TrimSpace(to) == "" {
+		return "", "", fmt.Errorf("invalid repository mirror %q: it must be like FROM=TO", s)
+	}
+	return strings.TrimSpace(from), strings.TrimSpace(to), nil
+}
+
+// mirrorURL returns the URL of the repository or the chart rewritten by the `repositoryMirrors`, or the URL as is when no mirror matches.
+//
+// A pattern without `*` matches the URL as is, ignoring the trailing slashes.
+// `*` in a pattern matches any string, and `*` in the mirror is replaced with the string matched by the first `*` of the pattern.
+// The longer patterns take precedence when multiple patterns match.
+func (st *HelmState) mirrorURL(u string) string {
+	if len(st.RepositoryMirrors) == 0 || u == "" {
+		return u
+	}
+
+	patterns := make([]string, 0, len(st.RepositoryMirrors))
+	for p := range st.RepositoryMirrors {
+		patterns = append(patterns, p)
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		if len(patterns[i]) != len(patterns[j]) {
+			return len(patterns[i]) > len(patterns[j])
+		}
+		return patterns[i] < patterns[j]
+	})
+
+	for _, p := range patterns {
+		if mirrored, ok := rewriteMirrorURL(p, st.RepositoryMirrors[p], u); ok {
+			if st.logger != nil {
+				st.logger.Debugf("using the mirror %s of %s", mirrored, u)
+			}
+			return mirrored
+		}
+	}
+
+	return u
+}
+
+// mirrorOCIURL is mirrorURL for the OCI repositories and charts, which are referenced with or without `oci://`.
+// The patterns and the mirrors of the OCI registries can be given either way too.
+func (st *HelmState) mirrorOCIURL(u string) string {
+	if strings.HasPrefix(u, "oci://") {
+		return st.mirrorURL(u)
+	}
+
+	if mirrored := st.mirrorURL("oci://" + u); mirrored != "oci://"+u {
+		return strings.TrimPrefix(mirrored, "oci://")
+	}
+
+	return st.mirrorURL(u)
+}
+
+func rewriteMirrorURL(pattern, mirror, u string) (string, bool) {
+	if !strings.Contains(pattern, "*") {
+		if strings.TrimSuffix(pattern, "/") != strings.TrimSuffix(u, "/") {
+			return "", false
+		}
+		return mirror, true
+	}
+
+	parts := strings.Split(pattern, "*")
+	for i := range parts {
+		parts[i] = regexp.QuoteMeta(parts[i])
+	}
+
+	m := regexp.MustCompile("^" + strings.Join(parts, "(.*)") + "$").FindStringSubmatch(u)
+	if m == nil {
+		return "", false
+	}
+
+	return strings.Replace(mirror, "*", m[1], 1), true
+}
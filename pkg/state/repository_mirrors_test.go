@@ -0,0 +1,75 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/exectest"
+)
+
+func TestHelmState_mirrorURL(t *testing.T) {
+	st := &HelmState{
+		logger: logger,
+		ReleaseSetSpec: ReleaseSetSpec{
+			RepositoryMirrors: map[string]string{
+				"https://charts.bitnami.com/*":                        "https://nexus.internal/bitnami",
+				"https://charts.example.com/*":                        "https://nexus.internal/example/*",
+				"https://charts.example.com/stable/*":                 "https://nexus.internal/stable",
+				"https://prometheus-community.github.io/helm-charts/": "https://nexus.internal/prometheus",
+				"oci://registry-1.docker.io/bitnamicharts":            "oci://registry.internal/bitnamicharts",
+			},
+		},
+	}
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{url: "https://charts.bitnami.com/bitnami", want: "https://nexus.internal/bitnami"},
+		{url: "https://charts.example.com/incubator/", want: "https://nexus.internal/example/incubator/"},
+		{url: "https://charts.example.com/stable/", want: "https://nexus.internal/stable"},
+		{url: "https://prometheus-community.github.io/helm-charts", want: "https://nexus.internal/prometheus"},
+		{url: "https://charts.jetstack.io", want: "https://charts.jetstack.io"},
+		{url: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.want, st.mirrorURL(tt.url), tt.url)
+	}
+
+	require.Equal(t, "registry.internal/bitnamicharts", st.mirrorOCIURL("registry-1.docker.io/bitnamicharts"))
+	require.Equal(t, "oci://registry.internal/bitnamicharts", st.mirrorOCIURL("oci://registry-1.docker.io/bitnamicharts"))
+}
+
+func TestHelmState_RepositoryMirrors(t *testing.T) {
+	st := &HelmState{
+		logger: logger,
+		ReleaseSetSpec: ReleaseSetSpec{
+			RepositoryMirrors: map[string]string{
+				"https://charts.bitnami.com/*": "https://nexus.internal/bitnami",
+				"oci://registry-1.docker.io/*": "oci://registry.internal/*",
+			},
+			Repositories: []RepositorySpec{
+				{Name: "bitnami", URL: "https://charts.bitnami.com/bitnami"},
+			},
+		},
+	}
+
+	helm := &exectest.Helm{}
+	_, err := st.SyncRepos(helm, map[string]bool{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"bitnami", "https://nexus.internal/bitnami", "", "", "", "", "", "", "", ""}, helm.Repo)
+
+	qualified, name, version := st.getOCIQualifiedChartName(&ReleaseSpec{Chart: "oci://registry-1.docker.io/bitnamicharts/nginx", Version: "15.0.0"})
+	require.Equal(t, "registry.internal/bitnamicharts/nginx:15.0.0", qualified)
+	require.Equal(t, "nginx", name)
+	require.Equal(t, "15.0.0", version)
+
+	st.Repositories = append(st.Repositories, RepositorySpec{Name: "dockerhub", URL: "registry-1.docker.io/bitnamicharts", OCI: true})
+	qualified, _, _ = st.getOCIQualifiedChartName(&ReleaseSpec{Chart: "dockerhub/redis", Version: "17.0.0"})
+	require.Equal(t, "registry.internal/bitnamicharts/redis:17.0.0", qualified)
+
+	_, _, err = ParseRepositoryMirror("https://charts.bitnami.com/*")
+	require.EqualError(t, err, `invalid repository mirror "https://charts.bitnami.com/*": it must be like FROM=TO`)
+}
@@ -0,0 +1,173 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/helmfile/helmfile/pkg/helmexec"
+)
+
+// defaultRequirementTimeout is the number of seconds to wait for a requirement unless its timeout is set
+const defaultRequirementTimeout = 300
+
+// requirementPollInterval is the interval between the checks of an unmet requirement
+var requirementPollInterval = 5 * time.Second
+
+// RequirementSpec is a prerequisite of a release that isn't managed by this helmfile,
+// like a CRD, a Deployment or a release owned by another team.
+// Helmfile waits for it before installing or upgrading the release.
+type RequirementSpec struct {
+	// Kind and Name are the Kubernetes resource that must exist, like `kind: CustomResourceDefinition`
+	Kind string `yaml:"kind,omitempty"`
+	Name string `yaml:"name,omitempty"`
+	// Condition is the status condition the resource must have, like `Available` or `Established`
+	Condition string `yaml:"condition,omitempty"`
+	// Release is the name of the Helm release that must be deployed, used instead of Kind and Name
+	Release string `yaml:"release,omitempty"`
+	// Namespace and KubeContext default to the ones of the release
+	Namespace   string `yaml:"namespace,omitempty"`
+	KubeContext string `yaml:"kubeContext,omitempty"`
+	// Timeout is the number of seconds to wait for the requirement (default 300)
+	Timeout int `yaml:"timeout,omitempty"`
+}
+
+func (r RequirementSpec) validate() error {
+	if r.Release != "" {
+		if r.Kind != "" || r.Name != "" || r.Condition != "" {
+			return errors.New("release can't be used with kind, name or condition")
+		}
+		return nil
+	}
+
+	if r.Kind == "" || r.Name == "" {
+		return errors.New("either release or both kind and name are required")
+	}
+
+	return nil
+}
+
+func (r RequirementSpec) String() string {
+	var s string
+	if r.Release != "" {
+		s = "release " + r.Release
+	} else {
+		s = r.Kind + "/" + r.Name
+		if r.Condition != "" {
+			s += " to be " + r.Condition
+		}
+	}
+
+	if r.Namespace != "" {
+		s += " in namespace " + r.Namespace
+	}
+
+	return s
+}
+
+// kubectlArgs returns the arguments of `kubectl get` that prints `True` only when the resource meets the requirement
+func (r RequirementSpec) kubectlArgs() []string {
+	args := []string{"get", r.Kind, r.Name}
+
+	if r.Condition != "" {
+		args = append(args, "-o", fmt.Sprintf(`jsonpath={.status.conditions[?(@.type=="%s")].status}`, r.Condition))
+	} else {
+		args = append(args, "-o", "jsonpath=True")
+	}
+
+	if r.Namespace != "" {
+		args = append(args, "--namespace", r.Namespace)
+	}
+
+	if r.KubeContext != "" {
+		args = append(args, "--context", r.KubeContext)
+	}
+
+	return args
+}
+
+// requirementsOf returns the requirements of the release with the namespace and kubecontext defaulted to the release's
+func (st *HelmState) requirementsOf(release *ReleaseSpec) []RequirementSpec {
+	var reqs []RequirementSpec
+
+	for _, r := range release.Requires {
+		if r.Namespace == "" {
+			r.Namespace = release.Namespace
+		}
+		if r.KubeContext == "" {
+			r.KubeContext = release.KubeContext
+		}
+		if r.KubeContext == "" {
+			r.KubeContext = st.HelmDefaults.KubeContext
+		}
+		reqs = append(reqs, r)
+	}
+
+	return reqs
+}
+
+// waitForRequirements blocks until all the requirements of the release are met,
+// failing when any of them isn't met within its timeout.
+func (st *HelmState) waitForRequirements(context helmexec.HelmContext, helm helmexec.Interface, release *ReleaseSpec) error {
+	for _, req := range st.requirementsOf(release) {
+		if err := req.validate(); err != nil {
+			return fmt.Errorf("invalid requirement %s: %v", req, err)
+		}
+
+		timeout := req.Timeout
+		if timeout <= 0 {
+			timeout = defaultRequirementTimeout
+		}
+
+		deadline := time.Now().Add(time.Duration(timeout) * time.Second)
+
+		for i := 0; ; i++ {
+			met, err := st.requirementMet(context, helm, req)
+			if met {
+				break
+			}
+
+			if time.Now().After(deadline) {
+				if err != nil {
+					return fmt.Errorf("timed out after %ds waiting for %s: %v", timeout, req, err)
+				}
+				return fmt.Errorf("timed out after %ds waiting for %s", timeout, req)
+			}
+
+			if i == 0 {
+				st.logger.Infof("Waiting for %s required by release %s", req, release.Name)
+			}
+
+			time.Sleep(requirementPollInterval)
+		}
+	}
+
+	return nil
+}
+
+func (st *HelmState) requirementMet(context helmexec.HelmContext, helm helmexec.Interface, req RequirementSpec) (bool, error) {
+	if req.Release != "" {
+		flags := []string{"--deployed"}
+		if req.Namespace != "" {
+			flags = append(flags, "--namespace", req.Namespace)
+		}
+		if req.KubeContext != "" {
+			flags = append(flags, "--kube-context", req.KubeContext)
+		}
+
+		out, err := helm.List(context, "^"+req.Release+"$", flags...)
+		if err != nil {
+			return false, err
+		}
+
+		return strings.TrimSpace(out) != "", nil
+	}
+
+	out, err := st.execKubectl(req.kubectlArgs())
+	if err != nil {
+		return false, fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+
+	return strings.TrimSpace(string(out)) == "True", nil
+}
@@ -0,0 +1,86 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/envvar"
+	"github.com/helmfile/helmfile/pkg/exectest"
+	"github.com/helmfile/helmfile/pkg/helmexec"
+)
+
+func TestRequirementSpec_validate(t *testing.T) {
+	require.NoError(t, RequirementSpec{Release: "cert-manager"}.validate())
+	require.NoError(t, RequirementSpec{Kind: "Deployment", Name: "cert-manager", Condition: "Available"}.validate())
+	require.EqualError(t, RequirementSpec{Kind: "Deployment"}.validate(), "either release or both kind and name are required")
+	require.EqualError(t, RequirementSpec{Release: "cert-manager", Kind: "Deployment"}.validate(), "release can't be used with kind, name or condition")
+}
+
+func TestWaitForRequirements(t *testing.T) {
+	t.Setenv(envvar.KubectlBinary, "")
+
+	interval := requirementPollInterval
+	requirementPollInterval = time.Millisecond
+	t.Cleanup(func() { requirementPollInterval = interval })
+
+	release := &ReleaseSpec{
+		Name:        "app",
+		Namespace:   "app",
+		KubeContext: "prod",
+		Requires: []RequirementSpec{
+			{Kind: "CustomResourceDefinition", Name: "certificates.cert-manager.io", Condition: "Established"},
+			{Kind: "Deployment", Name: "cert-manager", Namespace: "cert-manager"},
+		},
+	}
+
+	t.Run("waits until met", func(t *testing.T) {
+		r := &exectest.Runner{
+			Sequences: map[string][]string{
+				`kubectl get CustomResourceDefinition certificates.cert-manager.io -o jsonpath={.status.conditions[?(@.type=="Established")].status} --namespace app --context prod`: {"", "False", "True"},
+			},
+			Outputs: map[string]string{
+				`kubectl get Deployment cert-manager -o jsonpath=True --namespace cert-manager --context prod`: "True",
+			},
+		}
+		st := &HelmState{logger: logger, runner: r}
+
+		require.NoError(t, st.waitForRequirements(helmexec.HelmContext{}, &exectest.Helm{}, release))
+		require.Equal(t, []string{
+			`kubectl get CustomResourceDefinition certificates.cert-manager.io -o jsonpath={.status.conditions[?(@.type=="Established")].status} --namespace app --context prod`,
+			`kubectl get CustomResourceDefinition certificates.cert-manager.io -o jsonpath={.status.conditions[?(@.type=="Established")].status} --namespace app --context prod`,
+			`kubectl get CustomResourceDefinition certificates.cert-manager.io -o jsonpath={.status.conditions[?(@.type=="Established")].status} --namespace app --context prod`,
+			`kubectl get Deployment cert-manager -o jsonpath=True --namespace cert-manager --context prod`,
+		}, r.Calls)
+	})
+
+	t.Run("times out", func(t *testing.T) {
+		notFound := "kubectl get Deployment cert-manager -o jsonpath=True"
+		st := &HelmState{logger: logger, runner: &exectest.Runner{
+			Outputs:  map[string]string{notFound: "Error from server (NotFound)"},
+			Failures: map[string]bool{notFound: true},
+		}}
+
+		err := st.waitForRequirements(helmexec.HelmContext{}, &exectest.Helm{}, &ReleaseSpec{
+			Name:     "app",
+			Requires: []RequirementSpec{{Kind: "Deployment", Name: "cert-manager", Timeout: 1}},
+		})
+		require.EqualError(t, err, "timed out after 1s waiting for Deployment/cert-manager: exit status 1: Error from server (NotFound)")
+	})
+
+	t.Run("release", func(t *testing.T) {
+		st := &HelmState{logger: logger}
+		helm := &exectest.Helm{
+			Lists: map[exectest.ListKey]string{
+				{Filter: "^cert-manager$", Flags: "--deployed--namespacecert-manager--kube-contextprod"}: "cert-manager\tcert-manager\t1",
+			},
+		}
+
+		require.NoError(t, st.waitForRequirements(helmexec.HelmContext{}, helm, &ReleaseSpec{
+			Name:        "app",
+			KubeContext: "prod",
+			Requires:    []RequirementSpec{{Release: "cert-manager", Namespace: "cert-manager"}},
+		}))
+	})
+}
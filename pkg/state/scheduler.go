@@ -0,0 +1,215 @@
+package state
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The schedulings of the releases on sync and apply
+const (
+	// SchedulingGroups installs the groups of the releases in the order of `needs` one after another,
+	// starting a group once all the releases of the previous group are done
+	SchedulingGroups = "groups"
+	// SchedulingReleases starts each release as soon as all the releases it needs are done
+	SchedulingReleases = "releases"
+)
+
+func validateScheduling(scheduling string) error {
+	switch scheduling {
+	case "", SchedulingGroups, SchedulingReleases:
+		return nil
+	}
+	return fmt.Errorf("unknown scheduling %q: must be one of %s and %s", scheduling, SchedulingGroups, SchedulingReleases)
+}
+
+// SchedulesReleasesByNeeds returns whether sync and apply start each release as soon as all the releases it needs are done,
+// in which case all the releases are given to SyncReleases at once instead of group by group
+func (st *HelmState) SchedulesReleasesByNeeds() bool {
+	return st.HelmDefaults.Scheduling == SchedulingReleases
+}
+
+// releaseTiers returns the tier of each release, which is 0 for the releases needing none of the releases
+// and one more than the highest tier of the releases needed otherwise.
+// The releases in a cycle of needs are put in the tier of the release the cycle is entered from.
+func releaseTiers(needs [][]int) []int {
+	tiers := make([]int, len(needs))
+	visited := make([]bool, len(needs))
+
+	var tier func(i int) int
+	tier = func(i int) int {
+		if visited[i] {
+			return tiers[i]
+		}
+		visited[i] = true
+		for _, j := range needs[i] {
+			if t := tier(j) + 1; t > tiers[i] {
+				tiers[i] = t
+			}
+		}
+		return tiers[i]
+	}
+
+	for i := range needs {
+		tier(i)
+	}
+
+	return tiers
+}
+
+// scheduleReleases runs do for the releases concurrently, up to the concurrency and the limits of their concurrency groups,
+// starting them in the given order.
+// With byNeeds, each release is started once all the releases it needs among the releases succeeded,
+// skip is called instead of do for the releases that need a failed or skipped release,
+// and the number of the releases of each tier of needs running at once is limited by helmDefaults.tierConcurrency.
+// The needs on the releases not given are considered satisfied.
+// do is given the slot it runs in, from 1 to the concurrency, like the worker index of scatterGather.
+// An error is returned when the releases left can't be started as their needs make a cycle.
+func (st *HelmState) scheduleReleases(releases []*ReleaseSpec, concurrency int, byNeeds bool, do func(*ReleaseSpec, int) error, skip func(release *ReleaseSpec, need string)) error {
+	if len(releases) == 0 {
+		return nil
+	}
+
+	if concurrency < 1 || concurrency > len(releases) {
+		concurrency = len(releases)
+	}
+
+	const (
+		pending = iota
+		running
+		succeeded
+		failed
+	)
+
+	indices := map[string][]int{}
+	for i, r := range releases {
+		id := ReleaseToID(r)
+		indices[id] = append(indices[id], i)
+	}
+
+	// needs are the indices of the releases each release needs
+	needs := make([][]int, len(releases))
+	if byNeeds {
+		for i, r := range releases {
+			for _, n := range r.Needs {
+				needs[i] = append(needs[i], indices[n]...)
+			}
+		}
+	}
+
+	statuses := make([]int, len(releases))
+	groups := map[string]int{}
+	limits := st.HelmDefaults.ConcurrencyGroups
+
+	var tiers []int
+	tierRunning := map[int]int{}
+	tierLimit := 0
+	if byNeeds {
+		tiers = releaseTiers(needs)
+		tierLimit = st.HelmDefaults.TierConcurrency
+	}
+
+	slots := make([]int, 0, concurrency)
+	for s := concurrency; s >= 1; s-- {
+		slots = append(slots, s)
+	}
+
+	type done struct {
+		index int
+		slot  int
+		err   error
+	}
+
+	results := make(chan done, len(releases))
+	finished := 0
+
+	// dispatch starts the releases ready to run in order, and skips the ones that need a failed release
+	dispatch := func() {
+		for i, r := range releases {
+			if statuses[i] != pending {
+				continue
+			}
+
+			ready, failedNeed := true, -1
+			for _, j := range needs[i] {
+				if statuses[j] == failed {
+					failedNeed = j
+					break
+				}
+				if statuses[j] != succeeded {
+					ready = false
+				}
+			}
+
+			if failedNeed >= 0 {
+				statuses[i] = failed
+				finished++
+				skip(r, ReleaseToID(releases[failedNeed]))
+				continue
+			}
+
+			if !ready || len(slots) == 0 {
+				continue
+			}
+
+			if limit := limits[r.ConcurrencyGroup]; r.ConcurrencyGroup != "" && limit > 0 && groups[r.ConcurrencyGroup] >= limit {
+				continue
+			}
+
+			if tierLimit > 0 && tierRunning[tiers[i]] >= tierLimit {
+				continue
+			}
+
+			slot := slots[len(slots)-1]
+			slots = slots[:len(slots)-1]
+			groups[r.ConcurrencyGroup]++
+			if tierLimit > 0 {
+				tierRunning[tiers[i]]++
+			}
+			statuses[i] = running
+
+			go func(i, slot int, r *ReleaseSpec) {
+				results <- done{index: i, slot: slot, err: do(r, slot)}
+			}(i, slot, r)
+		}
+	}
+
+	dispatch()
+
+	for finished < len(releases) {
+		if len(slots) == concurrency {
+			// Nothing is running, which happens when the skipped releases are needed by the releases earlier in the order
+			before := finished
+			dispatch()
+			if len(slots) == concurrency && finished == before {
+				var left []string
+				for i, r := range releases {
+					if statuses[i] == pending {
+						left = append(left, ReleaseToID(r))
+					}
+				}
+				return fmt.Errorf("no release can be started among the %d releases left, whose needs make a cycle: %s", len(left), strings.Join(left, ", "))
+			}
+			continue
+		}
+
+		res := <-results
+		finished++
+		slots = append(slots, res.slot)
+		groups[releases[res.index].ConcurrencyGroup]--
+		if tierLimit > 0 {
+			tierRunning[tiers[res.index]]--
+		}
+
+		if res.err != nil {
+			statuses[res.index] = failed
+		} else {
+			statuses[res.index] = succeeded
+		}
+
+		st.logger.Debugf("release %q processed", releases[res.index].Name)
+
+		dispatch()
+	}
+
+	return nil
+}
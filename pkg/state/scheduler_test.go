@@ -0,0 +1,183 @@
+package state
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduleReleases_ByNeeds(t *testing.T) {
+	st := &HelmState{logger: logger}
+
+	releases := []*ReleaseSpec{
+		{Name: "slow", Namespace: "default"},
+		{Name: "db", Namespace: "default"},
+		{Name: "app", Namespace: "default", Needs: []string{"default/db"}},
+		{Name: "broken", Namespace: "default"},
+		{Name: "frontend", Namespace: "default", Needs: []string{"default/broken"}},
+		{Name: "cdn", Namespace: "default", Needs: []string{"default/frontend"}},
+	}
+
+	appStarted := make(chan struct{})
+
+	var (
+		mu      sync.Mutex
+		done    []string
+		skipped []string
+	)
+
+	err := st.scheduleReleases(releases, 0, true, func(r *ReleaseSpec, slot int) error {
+		switch r.Name {
+		case "slow":
+			// The release is started without waiting for the slow release in the same group of the plan
+			select {
+			case <-appStarted:
+			case <-time.After(10 * time.Second):
+				return errors.New("timed out waiting for app")
+			}
+		case "app":
+			close(appStarted)
+		case "broken":
+			return errors.New("failed")
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		done = append(done, r.Name)
+		return nil
+	}, func(r *ReleaseSpec, need string) {
+		mu.Lock()
+		defer mu.Unlock()
+		skipped = append(skipped, r.Name+" needs "+need)
+	})
+
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"slow", "db", "app"}, done)
+	require.Equal(t, []string{"frontend needs default/broken", "cdn needs default/frontend"}, skipped)
+}
+
+func TestScheduleReleases_ConcurrencyGroups(t *testing.T) {
+	st := &HelmState{
+		ReleaseSetSpec: ReleaseSetSpec{
+			HelmDefaults: HelmSpec{ConcurrencyGroups: map[string]int{"db": 1}},
+		},
+		logger: logger,
+	}
+
+	var releases []*ReleaseSpec
+	for _, name := range []string{"db1", "db2", "db3"} {
+		releases = append(releases, &ReleaseSpec{Name: name, ConcurrencyGroup: "db"})
+	}
+	releases = append(releases, &ReleaseSpec{Name: "app"})
+
+	var (
+		mu      sync.Mutex
+		running int
+		max     int
+		slots   = map[int]bool{}
+	)
+
+	err := st.scheduleReleases(releases, 2, false, func(r *ReleaseSpec, slot int) error {
+		mu.Lock()
+		if r.ConcurrencyGroup == "db" {
+			running++
+			if running > max {
+				max = running
+			}
+		}
+		slots[slot] = true
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		if r.ConcurrencyGroup == "db" {
+			running--
+		}
+		mu.Unlock()
+		return nil
+	}, func(r *ReleaseSpec, need string) {
+		t.Errorf("unexpected skip of %s", r.Name)
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, 1, max, "the releases of the concurrency group must be installed one at a time")
+	require.Equal(t, map[int]bool{1: true, 2: true}, slots)
+}
+
+func TestScheduleReleases_TierConcurrency(t *testing.T) {
+	st := &HelmState{
+		ReleaseSetSpec: ReleaseSetSpec{
+			HelmDefaults: HelmSpec{TierConcurrency: 1},
+		},
+		logger: logger,
+	}
+
+	releases := []*ReleaseSpec{
+		{Name: "leaf1", Namespace: "default"},
+		{Name: "leaf2", Namespace: "default"},
+		{Name: "app", Namespace: "default", Needs: []string{"default/leaf1"}},
+		{Name: "frontend", Namespace: "default", Needs: []string{"default/leaf2"}},
+	}
+
+	var (
+		mu      sync.Mutex
+		running = map[string]int{}
+		max     = map[string]int{}
+	)
+
+	tierOf := func(r *ReleaseSpec) string {
+		if len(r.Needs) == 0 {
+			return "first"
+		}
+		return "second"
+	}
+
+	err := st.scheduleReleases(releases, 0, true, func(r *ReleaseSpec, slot int) error {
+		tier := tierOf(r)
+
+		mu.Lock()
+		running[tier]++
+		if running[tier] > max[tier] {
+			max[tier] = running[tier]
+		}
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		running[tier]--
+		mu.Unlock()
+		return nil
+	}, func(r *ReleaseSpec, need string) {
+		t.Errorf("unexpected skip of %s", r.Name)
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"first": 1, "second": 1}, max, "the releases of each tier must be installed one at a time")
+}
+
+func TestScheduleReleases_Cycle(t *testing.T) {
+	st := &HelmState{logger: logger}
+
+	releases := []*ReleaseSpec{
+		{Name: "a", Namespace: "default", Needs: []string{"default/b"}},
+		{Name: "b", Namespace: "default", Needs: []string{"default/a"}},
+		{Name: "c", Namespace: "default"},
+	}
+
+	var done []string
+
+	err := st.scheduleReleases(releases, 0, true, func(r *ReleaseSpec, slot int) error {
+		done = append(done, r.Name)
+		return nil
+	}, func(r *ReleaseSpec, need string) {
+		t.Errorf("unexpected skip of %s", r.Name)
+	})
+
+	require.EqualError(t, err, "no release can be started among the 2 releases left, whose needs make a cycle: default/a, default/b")
+	require.Equal(t, []string{"c"}, done)
+}
@@ -0,0 +1,187 @@
+package state
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/helmfile/vals"
+
+	"github.com/helmfile/helmfile/pkg/envvar"
+	"github.com/helmfile/helmfile/pkg/plugins"
+)
+
+// SecretsBackendsSpec configures the backends used to resolve `ref+` secret references,
+// so that runs don't depend on the ambient environment variables of each machine.
+// Each setting is passed as the environment variable the backend reads, overriding the ambient one while the secrets of the state are resolved.
+type SecretsBackendsSpec struct {
+	Vault *VaultBackendSpec `yaml:"vault,omitempty"`
+	AWS   *AWSBackendSpec   `yaml:"aws,omitempty"`
+	SOPS  *SOPSBackendSpec  `yaml:"sops,omitempty"`
+	// CacheSize is the number of resolved secret references cached during the run (default 512)
+	CacheSize int `yaml:"cacheSize,omitempty"`
+}
+
+// VaultBackendSpec configures `ref+vault://` references
+type VaultBackendSpec struct {
+	// Address is the address of the Vault server, like `https://vault.example.com:8200`
+	Address string `yaml:"address,omitempty"`
+	// Namespace is the Vault Enterprise namespace
+	Namespace string `yaml:"namespace,omitempty"`
+	// AuthMethod is either `token` (default), `approle` or `kubernetes`
+	AuthMethod string `yaml:"authMethod,omitempty"`
+	// RoleID is the role for the `approle` and `kubernetes` auth methods
+	RoleID string `yaml:"roleID,omitempty"`
+	// TokenFile is the file to read the token from for the `token` auth method
+	TokenFile string `yaml:"tokenFile,omitempty"`
+	// LoginMountPoint is the mount point of the `approle` auth method
+	LoginMountPoint string `yaml:"loginMountPoint,omitempty"`
+	// KubernetesMountPoint is the mount point of the `kubernetes` auth method
+	KubernetesMountPoint string `yaml:"kubernetesMountPoint,omitempty"`
+}
+
+// AWSBackendSpec configures `ref+awssecrets://`, `ref+awskms://`, `ref+s3://` and `ref+ssm://` references
+type AWSBackendSpec struct {
+	Region string `yaml:"region,omitempty"`
+	// Profile is the profile in the AWS shared config, which may assume a role
+	Profile string `yaml:"profile,omitempty"`
+	// RoleARN is the role assumed with the web identity token read from WebIdentityTokenFile
+	RoleARN              string `yaml:"roleARN,omitempty"`
+	WebIdentityTokenFile string `yaml:"webIdentityTokenFile,omitempty"`
+}
+
+// SOPSBackendSpec configures the key sources used to decrypt `ref+sops://` references and secrets files
+type SOPSBackendSpec struct {
+	// AgeKeyFile is the file containing the age identities
+	AgeKeyFile string `yaml:"ageKeyFile,omitempty"`
+	// GnuPGHome is the GnuPG home directory containing the PGP keys
+	GnuPGHome string `yaml:"gnupgHome,omitempty"`
+}
+
+// envVars returns the environment variables read by the backends
+func (s SecretsBackendsSpec) envVars() map[string]string {
+	vars := map[string]string{}
+
+	set := func(name, value string) {
+		if value != "" {
+			vars[name] = value
+		}
+	}
+
+	if v := s.Vault; v != nil {
+		set("VAULT_ADDR", v.Address)
+		set("VAULT_NAMESPACE", v.Namespace)
+		set("VAULT_AUTH_METHOD", v.AuthMethod)
+		set("VAULT_ROLE_ID", v.RoleID)
+		set("VAULT_TOKEN_FILE", v.TokenFile)
+		set("VAULT_LOGIN_MOUNT_POINT", v.LoginMountPoint)
+		set("VAULT_KUBERNETES_MOUNT_POINT", v.KubernetesMountPoint)
+	}
+
+	if a := s.AWS; a != nil {
+		set("AWS_REGION", a.Region)
+		set("AWS_DEFAULT_REGION", a.Region)
+		if a.Profile != "" {
+			set("AWS_PROFILE", a.Profile)
+			set("AWS_SDK_LOAD_CONFIG", "true")
+			set("FORCE_AWS_PROFILE", "true")
+		}
+		set("AWS_ROLE_ARN", a.RoleARN)
+		set("AWS_WEB_IDENTITY_TOKEN_FILE", a.WebIdentityTokenFile)
+	}
+
+	if o := s.SOPS; o != nil {
+		set("SOPS_AGE_KEY_FILE", o.AgeKeyFile)
+		set("GNUPGHOME", o.GnuPGHome)
+	}
+
+	return vars
+}
+
+// secretsBackendsEnv returns the environment variables configuring the secrets backends of the state, or nil if none is configured
+func (st *HelmState) secretsBackendsEnv() map[string]string {
+	vars := st.SecretsBackends.envVars()
+	if len(vars) == 0 {
+		return nil
+	}
+	return vars
+}
+
+// applySecretsBackends switches the vals runtime of the state to the one configured by the secrets backends.
+// The configuration is never exported to the process environment for good. It's set only while the secrets of the state are resolved,
+// and passed to the helm commands of the releases, so that it doesn't leak to the other helmfiles, including the sub-helmfiles,
+// even when they are processed concurrently.
+// It must be called before any secret reference in the state is resolved.
+func (st *HelmState) applySecretsBackends() error {
+	vars := st.secretsBackendsEnv()
+
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if cur, ok := os.LookupEnv(name); ok && cur != vars[name] {
+			st.logger.Debugf("secretsBackends: overriding %s", name)
+		}
+	}
+
+	switch {
+	case vars != nil:
+		// The runtime isn't shared, not to return the secrets cached with the credentials of the other helmfiles
+		r, err := plugins.NewValsInstance(st.SecretsBackends.CacheSize)
+		if err != nil {
+			return fmt.Errorf("secretsBackends: %v", err)
+		}
+		st.valsRuntime = &secretsBackendsEvaluator{runtime: r, env: vars}
+	case st.SecretsBackends.CacheSize > 0:
+		r, err := plugins.ValsInstanceWithCacheSize(st.SecretsBackends.CacheSize)
+		if err != nil {
+			return fmt.Errorf("secretsBackends: %v", err)
+		}
+		st.valsRuntime = r
+	}
+
+	return nil
+}
+
+// secretsBackendsEvaluator resolves the secret references with the environment variables of the secrets backends set
+type secretsBackendsEvaluator struct {
+	runtime vals.Evaluator
+	env     map[string]string
+}
+
+func (e *secretsBackendsEvaluator) Eval(template map[string]interface{}) (map[string]interface{}, error) {
+	var result map[string]interface{}
+	err := envvar.With(e.env, func() error {
+		var err error
+		result, err = e.runtime.Eval(template)
+		return err
+	})
+	return result, err
+}
+
+// secretsBackendsDecrypter decrypts the secrets with the environment variables of the secrets backends set
+type secretsBackendsDecrypter struct {
+	SecretsDecrypter
+	env map[string]string
+}
+
+func (d secretsBackendsDecrypter) Decrypt(secret string) (map[string]interface{}, error) {
+	var result map[string]interface{}
+	err := envvar.With(d.env, func() error {
+		var err error
+		result, err = d.SecretsDecrypter.Decrypt(secret)
+		return err
+	})
+	return result, err
+}
+
+// withSecretsBackends returns the decrypter using the secrets backends of the state, if configured
+func (st *HelmState) withSecretsBackends(d SecretsDecrypter) SecretsDecrypter {
+	if vars := st.secretsBackendsEnv(); vars != nil {
+		return secretsBackendsDecrypter{SecretsDecrypter: d, env: vars}
+	}
+	return d
+}
@@ -0,0 +1,118 @@
+package state
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecretsBackendsSpec_envVars(t *testing.T) {
+	s := SecretsBackendsSpec{
+		Vault: &VaultBackendSpec{
+			Address:    "https://vault.example.com:8200",
+			AuthMethod: "approle",
+			RoleID:     "helmfile",
+		},
+		AWS: &AWSBackendSpec{
+			Region:  "ap-northeast-1",
+			Profile: "deploy",
+		},
+		SOPS: &SOPSBackendSpec{
+			AgeKeyFile: "keys.txt",
+		},
+	}
+
+	require.Equal(t, map[string]string{
+		"VAULT_ADDR":          "https://vault.example.com:8200",
+		"VAULT_AUTH_METHOD":   "approle",
+		"VAULT_ROLE_ID":       "helmfile",
+		"AWS_REGION":          "ap-northeast-1",
+		"AWS_DEFAULT_REGION":  "ap-northeast-1",
+		"AWS_PROFILE":         "deploy",
+		"AWS_SDK_LOAD_CONFIG": "true",
+		"FORCE_AWS_PROFILE":   "true",
+		"SOPS_AGE_KEY_FILE":   "keys.txt",
+	}, s.envVars())
+
+	require.Empty(t, SecretsBackendsSpec{}.envVars())
+}
+
+func TestHelmState_applySecretsBackends(t *testing.T) {
+	t.Setenv("VAULT_ADDR", "https://ambient.example.com")
+
+	st := &HelmState{
+		ReleaseSetSpec: ReleaseSetSpec{
+			SecretsBackends: SecretsBackendsSpec{
+				Vault: &VaultBackendSpec{Address: "https://vault.example.com"},
+			},
+		},
+		logger: logger,
+	}
+
+	require.NoError(t, st.applySecretsBackends())
+	require.Equal(t, "https://ambient.example.com", os.Getenv("VAULT_ADDR"), "the process environment must be left untouched")
+	require.IsType(t, &secretsBackendsEvaluator{}, st.valsRuntime)
+
+	ctx := st.createHelmContext(&ReleaseSpec{Name: "foo"}, 0)
+	require.Equal(t, map[string]string{"VAULT_ADDR": "https://vault.example.com"}, ctx.Env)
+}
+
+type envEvaluator struct {
+	name string
+}
+
+func (e envEvaluator) Eval(map[string]interface{}) (map[string]interface{}, error) {
+	return map[string]interface{}{"value": os.Getenv(e.name)}, nil
+}
+
+func TestSecretsBackendsEvaluator(t *testing.T) {
+	t.Setenv("VAULT_ADDR", "https://ambient.example.com")
+
+	e := &secretsBackendsEvaluator{
+		runtime: envEvaluator{name: "VAULT_ADDR"},
+		env:     map[string]string{"VAULT_ADDR": "https://vault.example.com"},
+	}
+
+	m, err := e.Eval(map[string]interface{}{})
+	require.NoError(t, err)
+	require.Equal(t, "https://vault.example.com", m["value"])
+	require.Equal(t, "https://ambient.example.com", os.Getenv("VAULT_ADDR"))
+}
+
+func TestSecretsBackendsEvaluator_Concurrent(t *testing.T) {
+	t.Setenv("VAULT_ADDR", "https://ambient.example.com")
+
+	addrs := []string{"https://a.example.com", "https://b.example.com", "https://c.example.com"}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(addrs)*10)
+	for _, addr := range addrs {
+		e := &secretsBackendsEvaluator{
+			runtime: envEvaluator{name: "VAULT_ADDR"},
+			env:     map[string]string{"VAULT_ADDR": addr},
+		}
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(addr string) {
+				defer wg.Done()
+				m, err := e.Eval(map[string]interface{}{})
+				if err == nil && m["value"] != addr {
+					err = fmt.Errorf("want %s, got %v", addr, m["value"])
+				}
+				if err != nil {
+					errs <- err
+				}
+			}(addr)
+		}
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+	require.Equal(t, "https://ambient.example.com", os.Getenv("VAULT_ADDR"))
+}
@@ -0,0 +1,125 @@
+package state
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/helmfile/vals/pkg/config"
+	"github.com/helmfile/vals/pkg/providers/awssecrets"
+	"github.com/helmfile/vals/pkg/providers/sops"
+	"github.com/helmfile/vals/pkg/providers/vault"
+)
+
+const (
+	// SecretsBackendHelmSecrets decrypts the secrets files with the helm-secrets plugin, which is the default
+	SecretsBackendHelmSecrets = "helm-secrets"
+	// SecretsBackendSOPS decrypts the secrets files with sops in-process
+	SecretsBackendSOPS = "sops"
+	// SecretsBackendVault reads the secrets from the paths in Vault
+	SecretsBackendVault = "vault"
+	// SecretsBackendAWSSecrets reads the secrets from AWS Secrets Manager
+	SecretsBackendAWSSecrets = "awssecrets"
+)
+
+// SecretsDecrypter decrypts the `secrets` entries of the releases in-process, instead of the helm-secrets plugin.
+// The backends are configured by the environment variables of `secretsBackends`, like the `ref+` secret references.
+type SecretsDecrypter interface {
+	// Decrypt returns the values decrypted from the entry,
+	// which is the path to the encrypted file when ReadsFiles is true, or the name of the secret in the backend otherwise
+	Decrypt(secret string) (map[string]interface{}, error)
+	// ReadsFiles is true when the entries are the encrypted files, resolved like the values files
+	ReadsFiles() bool
+}
+
+// secretsDecrypters are the in-process backends selectable per release with `secretsBackend`
+var secretsDecrypters = map[string]func() SecretsDecrypter{
+	SecretsBackendSOPS:       func() SecretsDecrypter { return sopsDecrypter{} },
+	SecretsBackendVault:      func() SecretsDecrypter { return vaultDecrypter{} },
+	SecretsBackendAWSSecrets: func() SecretsDecrypter { return awsSecretsDecrypter{} },
+}
+
+// secretsDecrypter returns the in-process decrypter of the backend, or nil for the helm-secrets plugin
+func (st *HelmState) secretsDecrypter(release *ReleaseSpec) (SecretsDecrypter, error) {
+	backend := release.SecretsBackend
+
+	if backend == "" || backend == SecretsBackendHelmSecrets {
+		return nil, nil
+	}
+
+	if d, ok := st.secretsDecrypters[backend]; ok {
+		return st.withSecretsBackends(d), nil
+	}
+
+	if newDecrypter, ok := secretsDecrypters[backend]; ok {
+		return st.withSecretsBackends(newDecrypter()), nil
+	}
+
+	backends := []string{SecretsBackendHelmSecrets}
+	for b := range secretsDecrypters {
+		backends = append(backends, b)
+	}
+	sort.Strings(backends[1:])
+
+	return nil, fmt.Errorf("release %q: unknown secretsBackend %q: it must be one of %s", release.Name, backend, strings.Join(backends, ", "))
+}
+
+// sopsFileSuffixes are the suffixes of the environment values and secrets files decrypted by sops in-process, instead of the helm-secrets plugin
+var sopsFileSuffixes = []string{".sops.yaml", ".sops.yml", ".sops.json"}
+
+// isSOPSFile returns true if the file is decrypted by sops in-process while loading the environment values
+func isSOPSFile(path string) bool {
+	for _, s := range sopsFileSuffixes {
+		if strings.HasSuffix(path, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// sopsDecrypter returns the decrypter of the files encrypted by sops
+func (st *HelmState) sopsDecrypter() SecretsDecrypter {
+	if d, ok := st.secretsDecrypters[SecretsBackendSOPS]; ok {
+		return st.withSecretsBackends(d)
+	}
+	return st.withSecretsBackends(sopsDecrypter{})
+}
+
+// sopsDecrypter decrypts the files encrypted by sops, with the keys of the environment like SOPS_AGE_KEY_FILE
+type sopsDecrypter struct{}
+
+func (sopsDecrypter) Decrypt(path string) (map[string]interface{}, error) {
+	format := "yaml"
+	if filepath.Ext(path) == ".json" {
+		format = "json"
+	}
+
+	return sops.New(config.MapConfig{M: map[string]interface{}{"format": format}}).GetStringMap(path)
+}
+
+func (sopsDecrypter) ReadsFiles() bool {
+	return true
+}
+
+// vaultDecrypter reads the key-value pairs of the path in Vault, like `secret/data/app`
+type vaultDecrypter struct{}
+
+func (vaultDecrypter) Decrypt(path string) (map[string]interface{}, error) {
+	return vault.New(config.MapConfig{M: map[string]interface{}{}}).GetStringMap(path)
+}
+
+func (vaultDecrypter) ReadsFiles() bool {
+	return false
+}
+
+// awsSecretsDecrypter reads the YAML or JSON value of the secret in AWS Secrets Manager, named by its name or ARN
+type awsSecretsDecrypter struct{}
+
+func (awsSecretsDecrypter) Decrypt(id string) (map[string]interface{}, error) {
+	return awssecrets.New(config.MapConfig{M: map[string]interface{}{}}).GetStringMap(id)
+}
+
+func (awsSecretsDecrypter) ReadsFiles() bool {
+	return false
+}
@@ -0,0 +1,106 @@
+package state
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/exectest"
+	"github.com/helmfile/helmfile/pkg/filesystem"
+)
+
+type fakeDecrypter struct {
+	files   bool
+	secrets map[string]map[string]interface{}
+}
+
+func (d *fakeDecrypter) Decrypt(secret string) (map[string]interface{}, error) {
+	if d.files {
+		secret = filepath.Base(secret)
+	}
+	values, ok := d.secrets[secret]
+	if !ok {
+		return nil, fmt.Errorf("secret %s not found", secret)
+	}
+	return values, nil
+}
+
+func (d *fakeDecrypter) ReadsFiles() bool {
+	return d.files
+}
+
+func TestGenerateSecretValuesFiles_SecretsBackend(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "secrets.yaml"), []byte("password: ENC[...]\n"), 0644))
+
+	st := &HelmState{
+		basePath: dir,
+		FilePath: filepath.Join(dir, "helmfile.yaml"),
+		WorkDir:  t.TempDir(),
+		logger:   logger,
+		fs:       filesystem.DefaultFileSystem(),
+		secretsDecrypters: map[string]SecretsDecrypter{
+			SecretsBackendSOPS: &fakeDecrypter{
+				files:   true,
+				secrets: map[string]map[string]interface{}{"secrets.yaml": {"password": "sops"}},
+			},
+			SecretsBackendVault: &fakeDecrypter{
+				secrets: map[string]map[string]interface{}{"secret/data/app": {"password": "vault"}},
+			},
+		},
+	}
+
+	tests := []struct {
+		backend string
+		secrets []interface{}
+		want    string
+		wantErr string
+	}{
+		{
+			backend: SecretsBackendSOPS,
+			secrets: []interface{}{"secrets.yaml"},
+			want:    "password: sops\n",
+		},
+		{
+			backend: SecretsBackendVault,
+			secrets: []interface{}{"secret/data/app"},
+			want:    "password: vault\n",
+		},
+		{
+			backend: SecretsBackendVault,
+			secrets: []interface{}{"secret/data/missing"},
+			wantErr: `failed to read secret "secret/data/missing" with the vault backend: secret secret/data/missing not found`,
+		},
+		{
+			backend: SecretsBackendVault,
+			secrets: []interface{}{map[string]interface{}{"password": "plain"}},
+			wantErr: `release "app": the secrets of the vault backend must be the names of the secrets, but got map[password:plain]`,
+		},
+		{
+			backend: "gpg",
+			secrets: []interface{}{"secrets.yaml"},
+			wantErr: `release "app": unknown secretsBackend "gpg": it must be one of helm-secrets, awssecrets, sops, vault`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.backend, func(t *testing.T) {
+			release := &ReleaseSpec{Name: "app", SecretsBackend: tt.backend, Secrets: tt.secrets}
+
+			files, err := st.generateSecretValuesFiles(&exectest.Helm{}, release, 0)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, files, 1)
+
+			content, err := os.ReadFile(files[0])
+			require.NoError(t, err)
+			require.Equal(t, tt.want, string(content))
+		})
+	}
+}
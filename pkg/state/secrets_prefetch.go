@@ -0,0 +1,138 @@
+package state
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/helmfile/vals/pkg/expansion"
+
+	"github.com/helmfile/helmfile/pkg/yaml"
+)
+
+// defaultSecretsPrefetchConcurrency bounds the number of secret references resolved in parallel when --concurrency is unlimited
+const defaultSecretsPrefetchConcurrency = 16
+
+// secretsPrefetchCommands are the commands rendering the values of the releases, which are the only ones the secrets are prefetched for.
+// The other ones, like deps and fetch, don't read the values, so prefetching the secrets would only add round-trips to the backends.
+var secretsPrefetchCommands = map[string]bool{
+	"apply":        true,
+	"charts":       true,
+	"diff":         true,
+	"lint":         true,
+	"sync":         true,
+	"template":     true,
+	"write-values": true,
+}
+
+// PrefetchSecrets resolves the `ref+` secret references found in the values and set values of the releases
+// with a bounded worker pool, so that rendering the releases later hits the cache of the vals runtime
+// instead of looking up the backends one by one.
+//
+// Each distinct reference is resolved once. Values files are scanned only when they are plain YAML,
+// as templated ones can't be read before rendering, and their references are resolved on demand as before.
+// Failures are only logged, because a reference may be unused after rendering and will fail again later if it is used.
+func (st *HelmState) PrefetchSecrets(releases []ReleaseSpec, concurrency int) {
+	if st.valsRuntime == nil {
+		return
+	}
+
+	refs := st.collectSecretRefs(releases)
+	if len(refs) == 0 {
+		return
+	}
+
+	if concurrency < 1 {
+		concurrency = defaultSecretsPrefetchConcurrency
+	}
+
+	st.logger.Debugf("prefetching %d secret references with concurrency %d", len(refs), concurrency)
+
+	jobs := make(chan string)
+
+	st.scatterGather(
+		concurrency,
+		len(refs),
+		func() {
+			for _, ref := range refs {
+				jobs <- ref
+			}
+			close(jobs)
+		},
+		func(_ int) {
+			for ref := range jobs {
+				if _, err := st.valsRuntime.Eval(map[string]interface{}{"ref": ref}); err != nil {
+					st.logger.Debugf("prefetching secret reference failed: %v", err)
+				}
+			}
+		},
+		func() {},
+	)
+}
+
+// collectSecretRefs returns the distinct strings containing `ref+` secret references in the releases, sorted.
+func (st *HelmState) collectSecretRefs(releases []ReleaseSpec) []string {
+	found := map[string]struct{}{}
+
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch typed := v.(type) {
+		case string:
+			if expansion.DefaultRefRegexp.MatchString(typed) && !strings.Contains(typed, "{{") {
+				found[typed] = struct{}{}
+			}
+		case map[string]interface{}:
+			for _, e := range typed {
+				walk(e)
+			}
+		case map[interface{}]interface{}:
+			for _, e := range typed {
+				walk(e)
+			}
+		case []interface{}:
+			for _, e := range typed {
+				walk(e)
+			}
+		}
+	}
+
+	for _, r := range releases {
+		for _, v := range r.Values {
+			path, isPath := v.(string)
+			if !isPath {
+				walk(v)
+				continue
+			}
+
+			if strings.HasSuffix(path, ".gotmpl") {
+				continue
+			}
+
+			bytes, err := st.fs.ReadFile(st.storage().normalizePath(r.ValuesPathPrefix + path))
+			if err != nil {
+				continue
+			}
+
+			var values map[string]interface{}
+			if err := yaml.Unmarshal(bytes, &values); err != nil {
+				continue
+			}
+
+			walk(values)
+		}
+
+		for _, set := range r.SetValues {
+			walk(set.Value)
+			for _, v := range set.Values {
+				walk(v)
+			}
+		}
+	}
+
+	refs := make([]string, 0, len(found))
+	for ref := range found {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+
+	return refs
+}
@@ -0,0 +1,82 @@
+package state
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/testhelper"
+)
+
+type countingEvaluator struct {
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func (e *countingEvaluator) Eval(m map[string]interface{}) (map[string]interface{}, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, v := range m {
+		e.calls[v.(string)]++
+	}
+	return m, nil
+}
+
+func TestPrefetchSecrets(t *testing.T) {
+	testFs := testhelper.NewTestFs(map[string]string{
+		"/path/to/values.yaml": `
+db:
+  password: ref+vault://secret/db#/password
+  user: admin
+`,
+		"/path/to/values.yaml.gotmpl": `
+token: ref+vault://secret/{{ .Environment.Name }}#/token
+`,
+	})
+
+	evaluator := &countingEvaluator{calls: map[string]int{}}
+
+	st := &HelmState{
+		basePath:    "/path/to",
+		fs:          testFs.ToFileSystem(),
+		logger:      logger,
+		valsRuntime: evaluator,
+	}
+
+	releases := []ReleaseSpec{
+		{
+			Name:   "foo",
+			Values: []interface{}{"values.yaml", "values.yaml.gotmpl", "missing.yaml"},
+			SetValues: []SetValue{
+				{Name: "apiKey", Value: "ref+awssecrets://api#/key"},
+				{Name: "plain", Value: "plain"},
+			},
+		},
+		{
+			Name: "bar",
+			Values: []interface{}{
+				map[string]interface{}{
+					"db": map[string]interface{}{
+						"password": "ref+vault://secret/db#/password",
+					},
+					"hosts": []interface{}{"ref+ssm://hosts/a", "b"},
+				},
+			},
+		},
+	}
+
+	require.Equal(t, []string{
+		"ref+awssecrets://api#/key",
+		"ref+ssm://hosts/a",
+		"ref+vault://secret/db#/password",
+	}, st.collectSecretRefs(releases))
+
+	st.PrefetchSecrets(releases, 2)
+
+	require.Equal(t, map[string]int{
+		"ref+awssecrets://api#/key":       1,
+		"ref+ssm://hosts/a":               1,
+		"ref+vault://secret/db#/password": 1,
+	}, evaluator.calls)
+}
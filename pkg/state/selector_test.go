@@ -198,3 +198,44 @@ func TestSelectReleasesWithOverridesWithIncludedTransitives(t *testing.T) {
 		}
 	}
 }
+
+func TestSelectReleasesWithEnabledIf(t *testing.T) {
+	st := &HelmState{
+		ReleaseSetSpec: ReleaseSetSpec{
+			Releases: []ReleaseSpec{
+				{Name: "always"},
+				{Name: "eu-prod", EnabledIf: `.Values.region == "eu" && .Values.tier != "dev"`},
+				{Name: "us", EnabledIf: `.Values.region == "us"`},
+				{Name: "production", EnabledIf: `.Environment.Name == "production"`},
+				{Name: "both", Condition: "monitoring.enabled", EnabledIf: `.Values.tier == "prod"`},
+			},
+		},
+		RenderedValues: map[string]interface{}{
+			"region":     "eu",
+			"tier":       "prod",
+			"monitoring": map[string]interface{}{"enabled": false},
+		},
+	}
+	st.Env.Name = "production"
+
+	releases, err := st.GetSelectedReleases(false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var names []string
+	for _, r := range releases {
+		names = append(names, r.Name)
+	}
+
+	if d := cmp.Diff([]string{"always", "eu-prod", "production"}, names); d != "" {
+		t.Errorf("unexpected releases: want (-), got (+):\n%s", d)
+	}
+
+	st.Releases = []ReleaseSpec{{Name: "broken", EnabledIf: `.Values.region = "eu"`}}
+
+	_, err = st.GetSelectedReleases(false)
+	if err == nil || err.Error() != `failed to parse condition in release broken: enabledIf ".Values.region = \"eu\"": unexpected "=" at 15` {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package state
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha1"
 	"encoding/hex"
 	"errors"
@@ -16,6 +17,7 @@ import (
 	"strings"
 	"sync"
 	"text/template"
+	"time"
 
 	"github.com/helmfile/vals"
 	"github.com/imdario/mergo"
@@ -25,6 +27,7 @@ import (
 
 	"github.com/helmfile/helmfile/pkg/environment"
 	"github.com/helmfile/helmfile/pkg/event"
+	"github.com/helmfile/helmfile/pkg/expr"
 	"github.com/helmfile/helmfile/pkg/filesystem"
 	"github.com/helmfile/helmfile/pkg/helmexec"
 	"github.com/helmfile/helmfile/pkg/policy"
@@ -57,13 +60,19 @@ type ReleaseSetSpec struct {
 	DeprecatedContext  string        `yaml:"context,omitempty"`
 	DeprecatedReleases []ReleaseSpec `yaml:"charts,omitempty"`
 
-	OverrideKubeContext string            `yaml:"kubeContext,omitempty"`
-	OverrideNamespace   string            `yaml:"namespace,omitempty"`
-	OverrideChart       string            `yaml:"chart,omitempty"`
-	Repositories        []RepositorySpec  `yaml:"repositories,omitempty"`
-	CommonLabels        map[string]string `yaml:"commonLabels,omitempty"`
-	Releases            []ReleaseSpec     `yaml:"releases,omitempty"`
-	Selectors           []string          `yaml:"-"`
+	OverrideKubeContext string              `yaml:"kubeContext,omitempty"`
+	OverrideNamespace   string              `yaml:"namespace,omitempty"`
+	OverrideChart       string              `yaml:"chart,omitempty"`
+	Repositories        []RepositorySpec    `yaml:"repositories,omitempty"`
+	Registries          []RegistrySpec      `yaml:"registries,omitempty"`
+	SecretsBackends     SecretsBackendsSpec `yaml:"secretsBackends,omitempty"`
+	CommonLabels        map[string]string   `yaml:"commonLabels,omitempty"`
+	Releases            []ReleaseSpec       `yaml:"releases,omitempty"`
+	Selectors           []string            `yaml:"-"`
+
+	// RepositoryMirrors maps the patterns of the URLs of the repositories and the OCI charts to the URLs of their mirrors,
+	// like `https://charts.bitnami.com/*: https://nexus.internal/bitnami`
+	RepositoryMirrors map[string]string `yaml:"repositoryMirrors,omitempty"`
 
 	// Capabilities.APIVersions
 	ApiVersions []string `yaml:"apiVersions,omitempty"`
@@ -71,11 +80,19 @@ type ReleaseSetSpec struct {
 	// Capabilities.KubeVersion
 	KubeVersion string `yaml:"kubeVersion,omitempty"`
 
+	// CapabilitiesFile is the file of the `apiVersions` and the `kubeVersion` of the target clusters, per environment,
+	// used for the ones not set in the state
+	CapabilitiesFile string `yaml:"capabilitiesFile,omitempty"`
+
 	// Hooks is a list of extension points paired with operations, that are executed in specific points of the lifecycle of releases defined in helmfile
 	Hooks []event.Hook `yaml:"hooks,omitempty"`
 
 	Templates map[string]TemplateSpec `yaml:"templates"`
 
+	// TemplateLibraries is the local or remote directories of the *.tpl files defining the named templates,
+	// that are available to the following parts of the state file
+	TemplateLibraries []string `yaml:"templateLibraries,omitempty"`
+
 	Env environment.Environment `yaml:"-"`
 
 	// If set to "Error", return an error when a subhelmfile points to a
@@ -128,20 +145,53 @@ type HelmState struct {
 
 	valsRuntime vals.Evaluator
 
+	// secretsDecrypters overrides the in-process backends of `secretsBackend` by their names
+	secretsDecrypters map[string]SecretsDecrypter
+
+	// runner runs external commands other than helm, like cosign. Defaults to helmexec.ShellRunner.
+	runner helmexec.Runner
+
 	// RenderedValues is the helmfile-wide values that is `.Values`
 	// which is accessible from within the whole helmfile go template.
 	// Note that this is usually computed by DesiredStateLoader from ReleaseSetSpec.Env
 	RenderedValues map[string]interface{}
+
+	// HookPlan, if set, records the hooks evaluated without being executed, instead of executing them
+	HookPlan *HookPlan `yaml:"-"`
+
+	// WorkDir, if set, is the working directory of the run the temporary files of the state are created in
+	WorkDir string `yaml:"-"`
+
+	// Offline skips the repository updates, and uses only the vendored charts and the ones in the cache instead of downloading them
+	Offline bool `yaml:"-"`
+
+	// Context, if set, is the context of the run the state is loaded for, which kills the helm commands of the state once it is done
+	Context context.Context `yaml:"-"`
+
+	// ClusterLookups is whether the templates of the state are allowed to query the cluster with kubectlGet
+	ClusterLookups tmpl.ClusterLookups `yaml:"-"`
+}
+
+// runContext returns the context the helm commands of the state run in
+func (st *HelmState) runContext() context.Context {
+	if st.Context == nil {
+		return context.Background()
+	}
+	return st.Context
 }
 
 // SubHelmfileSpec defines the subhelmfile path and options
 type SubHelmfileSpec struct {
+	//stable name of the sub helmfiles, used in logs and errors, and as the `name` label for --helmfile-selector
+	Name string `yaml:"name,omitempty"`
 	//path or glob pattern for the sub helmfiles
 	Path string `yaml:"path,omitempty"`
 	//chosen selectors for the sub helmfiles
 	Selectors []string `yaml:"selectors,omitempty"`
 	//do the sub helmfiles inherits from parent selectors
 	SelectorsInherited bool `yaml:"selectorsInherited,omitempty"`
+	//labels of the sub helmfiles to include or exclude them, along with their own sub helmfiles, with --helmfile-selector
+	Labels map[string]string `yaml:"labels,omitempty"`
 
 	Environment SubhelmfileEnvironmentSpec
 }
@@ -174,16 +224,44 @@ type HelmSpec struct {
 	CleanupOnFail bool `yaml:"cleanupOnFail,omitempty"`
 	// HistoryMax, limit the maximum number of revisions saved per release. Use 0 for no limit (default 10)
 	HistoryMax *int `yaml:"historyMax,omitempty"`
+	// PendingRecovery is the policy to recover the releases stuck in a pending status before upgrading them,
+	// which is one of `fail`, `rollback` and `uninstall-if-never-deployed`. The pending releases are left as they are by default
+	PendingRecovery string `yaml:"pendingRecovery,omitempty"`
+	// BumpPolicy limits the versions `helmfile bump` updates the charts to, which is one of `patch`, `minor` and `any` (default)
+	BumpPolicy string `yaml:"bumpPolicy,omitempty"`
+	// CommandTimeout kills the helm commands of the releases running longer than it, like `10m`,
+	// taking precedence over --command-timeout
+	CommandTimeout string `yaml:"commandTimeout,omitempty"`
+	// LiveOutput streams the outputs of the helm commands of the releases as they come, taking precedence over --command-live-output
+	LiveOutput *bool `yaml:"liveOutput,omitempty"`
+	// Scheduling is how sync and apply start the releases, which is one of `groups` (default) to install the groups of the releases
+	// in the order of `needs` one after another, and `releases` to start each release as soon as all the releases it needs are done
+	Scheduling string `yaml:"scheduling,omitempty"`
+	// ConcurrencyGroups limits the number of the releases of each concurrencyGroup installed at once by sync and apply,
+	// in addition to --concurrency
+	ConcurrencyGroups map[string]int `yaml:"concurrencyGroups,omitempty"`
+	// TierConcurrency limits the number of the releases of each tier of needs installed at once by sync and apply
+	// with `scheduling: releases`, in addition to --concurrency.
+	// The releases needing none of the others are in the first tier, and the others are in the tier next to the releases they need
+	TierConcurrency int `yaml:"tierConcurrency,omitempty"`
 	// CreateNamespace, when set to true (default), --create-namespace is passed to helm3 on install/upgrade (ignored for helm2)
 	CreateNamespace *bool `yaml:"createNamespace,omitempty"`
+	// LabelCreatedNamespaces labels the namespaces created by --create-namespace on sync and apply as created by Helmfile,
+	// which is required for destroy and delete to delete them with `--delete-orphans namespace`
+	LabelCreatedNamespaces bool `yaml:"labelCreatedNamespaces,omitempty"`
 	// SkipDeps disables running `helm dependency up` and `helm dependency build` on this release's chart.
 	// This is relevant only when your release uses a local chart or a directory containing K8s manifests or a Kustomization
 	// as a Helm chart.
 	SkipDeps bool `yaml:"skipDeps"`
+	// DependencyUpdateStrategy is when to run `helm dependency build` on the local charts,
+	// which is one of `always` (default), `if-missing` and `never`
+	DependencyUpdateStrategy string `yaml:"dependencyUpdateStrategy,omitempty"`
 	// on helm upgrade/diff, reuse values currently set in the release and merge them with the ones defined within helmfile
 	ReuseValues bool `yaml:"reuseValues"`
 	// Propagate '--post-renderer' to helmv3 template and helm install
 	PostRenderer *string `yaml:"postRenderer,omitempty"`
+	// ManifestTransformers transforms the manifests of all the releases rendered by `helmfile template`, before the ones of each release
+	ManifestTransformers []ManifestTransformer `yaml:"manifestTransformers,omitempty"`
 
 	TLS                      bool   `yaml:"tls"`
 	TLSCACert                string `yaml:"tlsCACert,omitempty"`
@@ -229,6 +307,18 @@ type ReleaseSpec struct {
 	Directory string `yaml:"directory,omitempty"`
 	// Version is the semver version or version constraint for the chart
 	Version string `yaml:"version,omitempty"`
+	// Digest pins the OCI chart to the manifest digest like `sha256:...`, so that a mutable tag can't change what gets deployed.
+	// It can also be specified as a part of the chart, like `chart: oci://registry/app@sha256:...`.
+	Digest string `yaml:"digest,omitempty"`
+	// Cosign is the policy to verify the keyless cosign signature of the OCI chart, which overrides the one of the registry
+	Cosign *CosignSpec `yaml:"cosign,omitempty"`
+	// PlainHTTP makes helm access the OCI registry of the chart over HTTP instead of HTTPS, which overrides plainHttp of the registry
+	PlainHTTP *bool `yaml:"plainHttp,omitempty"`
+	// InsecureSkipTLSVerify disables the verification of the certificate of the OCI registry of the chart,
+	// which overrides insecureSkipTLSVerify of the registry
+	InsecureSkipTLSVerify *bool `yaml:"insecureSkipTLSVerify,omitempty"`
+	// IgnoreDiffs are the fields generated by the chart whose changes are ignored by diff
+	IgnoreDiffs []IgnoreDiffSpec `yaml:"ignoreDiffs,omitempty"`
 	// Verify enables signature verification on fetched chart.
 	// Beware some (or many?) chart repositories and charts don't seem to support it.
 	Verify *bool `yaml:"verify,omitempty"`
@@ -252,8 +342,22 @@ type ReleaseSpec struct {
 	CleanupOnFail *bool `yaml:"cleanupOnFail,omitempty"`
 	// HistoryMax, limit the maximum number of revisions saved per release. Use 0 for no limit (default 10)
 	HistoryMax *int `yaml:"historyMax,omitempty"`
+	// PendingRecovery overrides the pendingRecovery of helmDefaults for the release
+	PendingRecovery string `yaml:"pendingRecovery,omitempty"`
+	// BumpPolicy overrides the bumpPolicy of helmDefaults for the release
+	BumpPolicy string `yaml:"bumpPolicy,omitempty"`
+	// CommandTimeout overrides the commandTimeout of helmDefaults for the release
+	CommandTimeout string `yaml:"commandTimeout,omitempty"`
+	// LiveOutput overrides the liveOutput of helmDefaults for the release
+	LiveOutput *bool `yaml:"liveOutput,omitempty"`
+	// OnWaitTimeout is the policy applied when waiting for the resources of the release times out on upgrade,
+	// which is one of `keep` (default), `rollback`, `retry` and `retryN` like `retry3`
+	OnWaitTimeout string `yaml:"onWaitTimeout,omitempty"`
 	// Condition, when set, evaluate the mapping specified in this string to a boolean which decides whether or not to process the release
 	Condition string `yaml:"condition,omitempty"`
+	// EnabledIf, when set, is the expression like `.Values.region == "eu" && .Values.tier != "dev"` evaluated against
+	// `.Values` and `.Environment.Name`, which decides whether or not to process the release along with Condition
+	EnabledIf string `yaml:"enabledIf,omitempty"`
 	// CreateNamespace, when set to true (default), --create-namespace is passed to helm3 on install (ignored for helm2)
 	CreateNamespace *bool `yaml:"createNamespace,omitempty"`
 
@@ -277,7 +381,14 @@ type ReleaseSpec struct {
 	// The default value for MissingFileHandler is "Error".
 	MissingFileHandler *string `yaml:"missingFileHandler,omitempty"`
 	// Needs is the [TILLER_NS/][NS/]NAME representations of releases that this release depends on.
-	Needs []string `yaml:"needs,omitempty"`
+	Needs Needs `yaml:"needs,omitempty"`
+	// Priority orders the release among the releases that don't need each other, the higher first.
+	// It's 0 by default, and the releases of the same priority are ordered as defined.
+	Priority int `yaml:"priority,omitempty"`
+	// ConcurrencyGroup is the group of the releases whose number installed at once is limited by helmDefaults.concurrencyGroups
+	ConcurrencyGroup string `yaml:"concurrencyGroup,omitempty"`
+	// Requires is the prerequisites not managed by this helmfile, that are waited for before installing or upgrading this release
+	Requires []RequirementSpec `yaml:"requires,omitempty"`
 
 	// Hooks is a list of extension points paired with operations, that are executed in specific points of the lifecycle of releases defined in helmfile
 	Hooks []event.Hook `yaml:"hooks,omitempty"`
@@ -290,6 +401,10 @@ type ReleaseSpec struct {
 	Secrets   []interface{}     `yaml:"secrets,omitempty"`
 	SetValues []SetValue        `yaml:"set,omitempty"`
 
+	// SecretsBackend decrypts the secrets of this release, either `helm-secrets` (default), `sops`, `vault` or `awssecrets`.
+	// The backends other than `helm-secrets` decrypt them in-process, without the helm-secrets plugin.
+	SecretsBackend string `yaml:"secretsBackend,omitempty"`
+
 	ValuesTemplate    []interface{} `yaml:"valuesTemplate,omitempty"`
 	SetValuesTemplate []SetValue    `yaml:"setTemplate,omitempty"`
 
@@ -351,9 +466,25 @@ type ReleaseSpec struct {
 	// as a Helm chart.
 	SkipDeps *bool `yaml:"skipDeps,omitempty"`
 
+	// DependencyUpdateStrategy overrides the dependencyUpdateStrategy of helmDefaults for the release
+	DependencyUpdateStrategy string `yaml:"dependencyUpdateStrategy,omitempty"`
+
+	// ChartVersion overrides the version in Chart.yaml of the local chart, like the one computed from the git tag by a template
+	ChartVersion string `yaml:"chartVersion,omitempty"`
+	// ChartAppVersion overrides the appVersion in Chart.yaml of the local chart
+	ChartAppVersion string `yaml:"chartAppVersion,omitempty"`
+
 	// Propagate '--post-renderer' to helmv3 template and helm install
 	PostRenderer *string `yaml:"postRenderer,omitempty"`
 
+	// Kustomize is the kustomization applied to the manifests of the release,
+	// which helmfile turns into the kustomize transformers run by chartify
+	Kustomize *KustomizeSpec `yaml:"kustomize,omitempty"`
+
+	// ManifestTransformers transforms the manifests of the release rendered by `helmfile template` in-process,
+	// after the ones of helmDefaults
+	ManifestTransformers []ManifestTransformer `yaml:"manifestTransformers,omitempty"`
+
 	// Inherit is used to inherit a release template from a release or another release template
 	Inherit Inherits `yaml:"inherit,omitempty"`
 }
@@ -402,6 +533,10 @@ type AffectedReleases struct {
 	Upgraded []*ReleaseSpec
 	Deleted  []*ReleaseSpec
 	Failed   []*ReleaseSpec
+	// Retried is the releases upgraded again after waiting for them timed out, by `onWaitTimeout: retry`
+	Retried []*ReleaseSpec
+	// RolledBack is the releases rolled back after waiting for them timed out, by `onWaitTimeout: rollback`
+	RolledBack []*ReleaseSpec
 }
 
 // DefaultEnv is the default environment to use for helm commands
@@ -419,6 +554,9 @@ const MissingFileHandlerWarn = "Warn"
 // MissingFileHandlerDebug is the debug returned when a file is missing
 const MissingFileHandlerDebug = "Debug"
 
+// DefaultDiffOutputFileTemplate is the default template for the path of the file each release's diff is written to
+const DefaultDiffOutputFileTemplate = "{{ .Release.Name }}.diff"
+
 var DefaultFetchOutputDirTemplate = path.Join(
 	"{{ .OutputDir }}{{ if .Release.Namespace }}",
 	"{{ .Release.Namespace }}{{ end }}{{ if .Release.KubeContext }}",
@@ -443,6 +581,12 @@ func (st *HelmState) ApplyOverrides(spec *ReleaseSpec) {
 	for i := 0; i < len(spec.Needs); i++ {
 		n := spec.Needs[i]
 
+		// The releases of the other helmfiles are matched by their own IDs
+		if _, ok := ParseExternalNeed(n); ok {
+			needs = append(needs, n)
+			continue
+		}
+
 		var kubecontext, ns, name string
 
 		components := strings.Split(n, "/")
@@ -488,12 +632,17 @@ func (st *HelmState) ApplyOverrides(spec *ReleaseSpec) {
 
 type RepoUpdater interface {
 	IsHelm3() bool
-	AddRepo(name, repository, cafile, certfile, keyfile, username, password string, managed string, passCredentials string, skipTLSVerify string) error
-	UpdateRepo() error
-	RegistryLogin(name string, username string, password string) error
+	AddRepo(ctx context.Context, name, repository, cafile, certfile, keyfile, username, password string, managed string, passCredentials string, skipTLSVerify string) error
+	UpdateRepo(ctx context.Context) error
+	RegistryLogin(ctx context.Context, name string, username string, password string, flags ...string) error
 }
 
 func (st *HelmState) SyncRepos(helm RepoUpdater, shouldSkip map[string]bool) ([]string, error) {
+	if st.Offline {
+		st.logger.Debugf("skipping the repository updates in the offline mode")
+		return nil, nil
+	}
+
 	var updated []string
 
 	for _, repo := range st.Repositories {
@@ -504,10 +653,14 @@ func (st *HelmState) SyncRepos(helm RepoUpdater, shouldSkip map[string]bool) ([]
 		var err error
 		if repo.OCI {
 			if username != "" && password != "" {
-				err = helm.RegistryLogin(repo.URL, username, password)
+				var flags []string
+				if st.releasesRequireInsecureOCI(repo.URL) {
+					flags = append(flags, "--insecure")
+				}
+				err = helm.RegistryLogin(st.runContext(), st.mirrorOCIURL(repo.URL), username, password, flags...)
 			}
 		} else {
-			err = helm.AddRepo(repo.Name, repo.URL, repo.CaFile, repo.CertFile, repo.KeyFile, username, password, repo.Managed, repo.PassCredentials, repo.SkipTLSVerify)
+			err = helm.AddRepo(st.runContext(), repo.Name, st.mirrorURL(repo.URL), repo.CaFile, repo.CertFile, repo.KeyFile, username, password, repo.Managed, repo.PassCredentials, repo.SkipTLSVerify)
 		}
 
 		if err != nil {
@@ -517,6 +670,28 @@ func (st *HelmState) SyncRepos(helm RepoUpdater, shouldSkip map[string]bool) ([]
 		updated = append(updated, repo.Name)
 	}
 
+	for _, reg := range st.Registries {
+		host := reg.loginHost()
+		if shouldSkip[host] {
+			continue
+		}
+		username, password := reg.credentials()
+		if username == "" || password == "" {
+			continue
+		}
+
+		flags := reg.loginFlags()
+		if !reg.InsecureSkipTLSVerify && !reg.PlainHTTP && st.releasesRequireInsecureOCI(reg.Host) {
+			flags = append(flags, "--insecure")
+		}
+
+		if err := helm.RegistryLogin(st.runContext(), host, username, password, flags...); err != nil {
+			return nil, err
+		}
+
+		updated = append(updated, host)
+	}
+
 	return updated, nil
 }
 
@@ -583,6 +758,7 @@ func (st *HelmState) prepareSyncReleases(helm helmexec.Interface, additionalValu
 		func(workerIndex int) {
 			for release := range jobs {
 				st.ApplyOverrides(release)
+				opts.overrideRelease(release)
 
 				// If `installed: false`, the only potential operation on this release would be uninstalling.
 				// We skip generating values files in that case, because for an uninstall with `helm delete`, we don't need to those.
@@ -631,14 +807,6 @@ func (st *HelmState) prepareSyncReleases(helm helmexec.Interface, additionalValu
 					flags = append(flags, "--skip-crds")
 				}
 
-				if opts.Wait {
-					flags = append(flags, "--wait")
-				}
-
-				if opts.WaitForJobs {
-					flags = append(flags, "--wait-for-jobs")
-				}
-
 				flags = st.appendValuesControlModeFlag(flags, opts.ReuseValues, opts.ResetValues)
 
 				if len(errs) > 0 {
@@ -695,6 +863,11 @@ func (st *HelmState) DetectReleasesToBeDeletedForSync(helm helmexec.Interface, r
 }
 
 func (st *HelmState) DetectReleasesToBeDeleted(helm helmexec.Interface, releases []ReleaseSpec) ([]ReleaseSpec, error) {
+	return st.DetectInstalledReleases(helm, releases)
+}
+
+// DetectInstalledReleases returns the releases installed in the clusters out of the releases
+func (st *HelmState) DetectInstalledReleases(helm helmexec.Interface, releases []ReleaseSpec) ([]ReleaseSpec, error) {
 	detected := []ReleaseSpec{}
 	for i := range releases {
 		release := releases[i]
@@ -715,14 +888,45 @@ type SyncOpts struct {
 	Set         []string
 	SkipCleanup bool
 	SkipCRDs    bool
-	Wait        bool
-	WaitForJobs bool
 	ReuseValues bool
 	ResetValues bool
+	// LogOutput is either LogOutputInterleaved (default) or LogOutputGrouped
+	LogOutput string
+	// Atomic, CleanupOnFail, Wait, WaitForJobs, and Timeout are given by the flags of the command when non-nil,
+	// overriding the ones of the releases, which override the ones of helmDefaults
+	Atomic        *bool
+	CleanupOnFail *bool
+	Timeout       *int
+	Wait          *bool
+	WaitForJobs   *bool
 }
 
 type SyncOpt interface{ Apply(*SyncOpts) }
 
+// overrideRelease overrides the settings of the release with the ones given by the flags of the command
+func (o *SyncOpts) overrideRelease(release *ReleaseSpec) {
+	if o.Atomic != nil {
+		v := *o.Atomic
+		release.Atomic = &v
+	}
+	if o.CleanupOnFail != nil {
+		v := *o.CleanupOnFail
+		release.CleanupOnFail = &v
+	}
+	if o.Wait != nil {
+		v := *o.Wait
+		release.Wait = &v
+	}
+	if o.WaitForJobs != nil {
+		v := *o.WaitForJobs
+		release.WaitForJobs = &v
+	}
+	if o.Timeout != nil {
+		v := *o.Timeout
+		release.Timeout = &v
+	}
+}
+
 func (o *SyncOpts) Apply(opts *SyncOpts) {
 	*opts = *o
 }
@@ -815,6 +1019,12 @@ func (st *HelmState) DeleteReleasesForSync(affectedReleases *AffectedReleases, h
 					st.logger.Warnf("warn: %v\n", err)
 				}
 
+				if relErr != nil {
+					if _, err := st.triggerOnFailureEvent(release, relErr, "sync"); err != nil {
+						st.logger.Warnf("warn: %v\n", err)
+					}
+				}
+
 				if _, err := st.TriggerCleanupEvent(release, "sync"); err != nil {
 					st.logger.Warnf("warn: %v\n", err)
 				}
@@ -851,6 +1061,10 @@ func (st *HelmState) SyncReleases(affectedReleases *AffectedReleases, helm helme
 		o.Apply(opts)
 	}
 
+	if err := validateScheduling(st.HelmDefaults.Scheduling); err != nil {
+		return []error{err}
+	}
+
 	preps, prepErrs := st.prepareSyncReleases(helm, additionalValues, workerLimit, opts)
 
 	if !opts.SkipCleanup {
@@ -865,15 +1079,149 @@ func (st *HelmState) SyncReleases(affectedReleases *AffectedReleases, helm helme
 		return prepErrs
 	}
 
+	// Start the releases in the order of the plan, as the preparations finish in any order
+	order := st.releaseOrder()
+	sort.SliceStable(preps, func(i, j int) bool {
+		return order(preps[i].release) < order(preps[j].release)
+	})
+
 	errs := []error{}
-	jobQueue := make(chan *syncPrepareResult, len(preps))
-	results := make(chan syncResult, len(preps))
 	if workerLimit == 0 {
 		workerLimit = len(preps)
 	}
 
 	m := new(sync.Mutex)
 
+	syncRelease := func(prep *syncPrepareResult, workerIndex int) *ReleaseError {
+		release := prep.release
+		flags := prep.flags
+		chart := normalizeChart(st.basePath, release.ChartPathOrName())
+		var relErr *ReleaseError
+		context := st.createHelmContext(release, workerIndex)
+
+		// st is shadowed by the copy logging to the output of the release in the grouped log output
+		st, output := st.withReleaseOutput(release, &context, opts.LogOutput)
+
+		labelNamespace := func() {}
+		if release.Desired() {
+			labelNamespace = st.namespaceLabeler(release)
+		}
+
+		if _, err := st.triggerPresyncEvent(release, "sync"); err != nil {
+			relErr = newReleaseFailedError(release, err)
+		} else if !release.Desired() {
+			installed, err := st.isReleaseInstalled(context, helm, *release)
+			if err != nil {
+				relErr = newReleaseFailedError(release, err)
+			} else if installed {
+				var args []string
+				deletionFlags := st.appendConnectionFlags(args, release)
+				m.Lock()
+				if _, err := st.triggerReleaseEvent("preuninstall", nil, release, "sync"); err != nil {
+					affectedReleases.Failed = append(affectedReleases.Failed, release)
+					relErr = newReleaseFailedError(release, err)
+				} else if err := helm.DeleteRelease(context, release.Name, deletionFlags...); err != nil {
+					affectedReleases.Failed = append(affectedReleases.Failed, release)
+					relErr = newReleaseFailedError(release, err)
+				} else if _, err := st.triggerReleaseEvent("postuninstall", nil, release, "sync"); err != nil {
+					affectedReleases.Failed = append(affectedReleases.Failed, release)
+					relErr = newReleaseFailedError(release, err)
+				} else {
+					affectedReleases.Deleted = append(affectedReleases.Deleted, release)
+				}
+				m.Unlock()
+			}
+		} else if err := st.waitForRequirements(context, helm, release); err != nil {
+			m.Lock()
+			affectedReleases.Failed = append(affectedReleases.Failed, release)
+			m.Unlock()
+			relErr = newReleaseFailedError(release, err)
+		} else if err := st.recoverPendingRelease(context, helm, release); err != nil {
+			m.Lock()
+			affectedReleases.Failed = append(affectedReleases.Failed, release)
+			m.Unlock()
+			relErr = newReleaseFailedError(release, err)
+		} else if err := st.syncRelease(context, helm, release, chart, flags, affectedReleases, m); err != nil {
+			m.Lock()
+			affectedReleases.Failed = append(affectedReleases.Failed, release)
+			m.Unlock()
+			relErr = newReleaseFailedError(release, err)
+		} else {
+			m.Lock()
+			affectedReleases.Upgraded = append(affectedReleases.Upgraded, release)
+			m.Unlock()
+			labelNamespace()
+			installedVersion, err := st.getDeployedVersion(context, helm, release)
+			if err != nil { // err is not really impacting so just log it
+				st.logger.Debugf("getting deployed release version failed: %v", err)
+			} else {
+				release.installedVersion = installedVersion
+			}
+		}
+
+		if _, err := st.triggerPostsyncEvent(release, relErr, "sync"); err != nil {
+			if relErr == nil {
+				relErr = newReleaseFailedError(release, err)
+			} else {
+				st.logger.Warnf("warn: %v\n", err)
+			}
+		}
+
+		if relErr != nil {
+			if _, err := st.triggerOnFailureEvent(release, relErr, "sync"); err != nil {
+				st.logger.Warnf("warn: %v\n", err)
+			}
+		}
+
+		if _, err := st.TriggerCleanupEvent(release, "sync"); err != nil {
+			if relErr == nil {
+				relErr = newReleaseFailedError(release, err)
+			} else {
+				st.logger.Warnf("warn: %v\n", err)
+			}
+		}
+
+		output.flush(relErr != nil)
+
+		return relErr
+	}
+
+	if st.SchedulesReleasesByNeeds() || len(st.HelmDefaults.ConcurrencyGroups) > 0 {
+		releases := make([]*ReleaseSpec, len(preps))
+		releasePreps := make(map[*ReleaseSpec]*syncPrepareResult, len(preps))
+		for i := range preps {
+			releases[i] = preps[i].release
+			releasePreps[preps[i].release] = &preps[i]
+		}
+
+		var mu sync.Mutex
+
+		schedErr := st.scheduleReleases(releases, workerLimit, st.SchedulesReleasesByNeeds(), func(release *ReleaseSpec, slot int) error {
+			if relErr := syncRelease(releasePreps[release], slot); relErr != nil {
+				mu.Lock()
+				errs = append(errs, relErr)
+				mu.Unlock()
+				return relErr
+			}
+			return nil
+		}, func(release *ReleaseSpec, need string) {
+			mu.Lock()
+			errs = append(errs, newReleaseFailedError(release, fmt.Errorf("skipped as it needs %q, which failed", need)))
+			mu.Unlock()
+		})
+		if schedErr != nil {
+			errs = append(errs, schedErr)
+		}
+
+		if len(errs) > 0 {
+			return errs
+		}
+		return nil
+	}
+
+	jobQueue := make(chan *syncPrepareResult, len(preps))
+	results := make(chan syncResult, len(preps))
+
 	st.scatterGather(
 		workerLimit,
 		len(preps),
@@ -885,73 +1233,10 @@ func (st *HelmState) SyncReleases(affectedReleases *AffectedReleases, helm helme
 		},
 		func(workerIndex int) {
 			for prep := range jobQueue {
-				release := prep.release
-				flags := prep.flags
-				chart := normalizeChart(st.basePath, release.ChartPathOrName())
-				var relErr *ReleaseError
-				context := st.createHelmContext(release, workerIndex)
-
-				if _, err := st.triggerPresyncEvent(release, "sync"); err != nil {
-					relErr = newReleaseFailedError(release, err)
-				} else if !release.Desired() {
-					installed, err := st.isReleaseInstalled(context, helm, *release)
-					if err != nil {
-						relErr = newReleaseFailedError(release, err)
-					} else if installed {
-						var args []string
-						deletionFlags := st.appendConnectionFlags(args, release)
-						m.Lock()
-						if _, err := st.triggerReleaseEvent("preuninstall", nil, release, "sync"); err != nil {
-							affectedReleases.Failed = append(affectedReleases.Failed, release)
-							relErr = newReleaseFailedError(release, err)
-						} else if err := helm.DeleteRelease(context, release.Name, deletionFlags...); err != nil {
-							affectedReleases.Failed = append(affectedReleases.Failed, release)
-							relErr = newReleaseFailedError(release, err)
-						} else if _, err := st.triggerReleaseEvent("postuninstall", nil, release, "sync"); err != nil {
-							affectedReleases.Failed = append(affectedReleases.Failed, release)
-							relErr = newReleaseFailedError(release, err)
-						} else {
-							affectedReleases.Deleted = append(affectedReleases.Deleted, release)
-						}
-						m.Unlock()
-					}
-				} else if err := helm.SyncRelease(context, release.Name, chart, flags...); err != nil {
-					m.Lock()
-					affectedReleases.Failed = append(affectedReleases.Failed, release)
-					m.Unlock()
-					relErr = newReleaseFailedError(release, err)
+				if relErr := syncRelease(prep, workerIndex); relErr != nil {
+					results <- syncResult{errors: []*ReleaseError{relErr}}
 				} else {
-					m.Lock()
-					affectedReleases.Upgraded = append(affectedReleases.Upgraded, release)
-					m.Unlock()
-					installedVersion, err := st.getDeployedVersion(context, helm, release)
-					if err != nil { // err is not really impacting so just log it
-						st.logger.Debugf("getting deployed release version failed: %v", err)
-					} else {
-						release.installedVersion = installedVersion
-					}
-				}
-
-				if _, err := st.triggerPostsyncEvent(release, relErr, "sync"); err != nil {
-					if relErr == nil {
-						relErr = newReleaseFailedError(release, err)
-					} else {
-						st.logger.Warnf("warn: %v\n", err)
-					}
-				}
-
-				if _, err := st.TriggerCleanupEvent(release, "sync"); err != nil {
-					if relErr == nil {
-						relErr = newReleaseFailedError(release, err)
-					} else {
-						st.logger.Warnf("warn: %v\n", err)
-					}
-				}
-
-				if relErr == nil {
 					results <- syncResult{}
-				} else {
-					results <- syncResult{errors: []*ReleaseError{relErr}}
 				}
 			}
 		},
@@ -993,7 +1278,7 @@ func (st *HelmState) getDeployedVersion(context helmexec.HelmContext, helm helme
 		if len(versions) > 0 {
 			return versions[1], nil
 		} else {
-			chartMetadata, err := helm.ShowChart(release.Chart)
+			chartMetadata, err := helm.ShowChart(st.runContext(), release.Chart)
 			if err != nil {
 				return "failed to get version", errors.New("Failed to get the version for: " + chartName)
 			}
@@ -1033,6 +1318,10 @@ type ChartPrepareOptions struct {
 	OutputDirTemplate      string
 	IncludeTransitiveNeeds bool
 	Concurrency            int
+	// OCICacheDir is the directory the OCI charts pinned by digest are cached in, to be reused instead of pulled on every run
+	OCICacheDir string
+	// SkipVendor downloads the charts even when they are vendored, to vendor them again
+	SkipVendor bool
 }
 
 type chartPrepareResult struct {
@@ -1045,6 +1334,8 @@ type chartPrepareResult struct {
 	buildDeps              bool
 	skipRefresh            bool
 	chartFetchedByGoGetter bool
+	plainHTTP              bool
+	insecureSkipTLSVerify  bool
 }
 
 func (st *HelmState) GetRepositoryAndNameFromChartName(chartName string) (*RepositorySpec, string) {
@@ -1097,6 +1388,11 @@ func (st *HelmState) PrepareCharts(helm helmexec.Interface, dir string, concurre
 
 	releases := releasesNeedCharts(selected)
 
+	if secretsPrefetchCommands[helmfileCommand] {
+		// Resolve the secret references of all the releases in parallel ahead of rendering their values one by one
+		st.PrefetchSecrets(releases, concurrency)
+	}
+
 	temp := make(map[PrepareChartKey]string, len(releases))
 
 	errs := []error{}
@@ -1112,6 +1408,20 @@ func (st *HelmState) PrepareCharts(helm helmexec.Interface, dir string, concurre
 		*st = *updated
 	}
 
+	var vendor *VendorManifest
+	if !opts.SkipVendor {
+		var err error
+		if vendor, err = st.readVendorManifest(); err != nil {
+			return nil, []error{err}
+		}
+	}
+
+	if st.Offline {
+		if err := st.checkOfflineCharts(releases, vendor, opts.OCICacheDir); err != nil {
+			return nil, []error{err}
+		}
+	}
+
 	var builds []*chartPrepareResult
 
 	st.scatterGather(
@@ -1140,15 +1450,25 @@ func (st *HelmState) PrepareCharts(helm helmexec.Interface, dir string, concurre
 
 				chartName := release.Chart
 
-				chartPath, err := st.downloadChartWithGoGetter(release)
+				vendoredPath, err := st.vendoredChartPath(vendor, release)
 				if err != nil {
 					results <- &chartPrepareResult{err: fmt.Errorf("release %q: %w", release.Name, err)}
 					return
 				}
-				chartFetchedByGoGetter := chartPath != chartName
+				vendored := vendoredPath != ""
+
+				chartPath := vendoredPath
+				if !vendored {
+					chartPath, err = st.downloadChartWithGoGetter(release)
+					if err != nil {
+						results <- &chartPrepareResult{err: fmt.Errorf("release %q: %w", release.Name, err)}
+						return
+					}
+				}
+				chartFetchedByGoGetter := !vendored && chartPath != chartName
 
-				if !chartFetchedByGoGetter {
-					ociChartPath, err := st.getOCIChart(release, dir, helm)
+				if !chartFetchedByGoGetter && !vendored {
+					ociChartPath, err := st.getOCIChart(release, dir, opts.OCICacheDir, helm)
 					if err != nil {
 						results <- &chartPrepareResult{err: fmt.Errorf("release %q: %w", release.Name, err)}
 
@@ -1160,6 +1480,14 @@ func (st *HelmState) PrepareCharts(helm helmexec.Interface, dir string, concurre
 					}
 				}
 
+				// Render the templated files of the local chart, like Chart.yaml.gotmpl, into a temporary copy of the chart,
+				// before anything else inspects the chart, as the chart lacks Chart.yaml until then.
+				chartPath, err = st.renderTemplatedChart(release, chartPath)
+				if err != nil {
+					results <- &chartPrepareResult{err: fmt.Errorf("release %q: %w", release.Name, err)}
+					return
+				}
+
 				isLocal := st.fs.DirectoryExistsAt(normalizeChart(st.basePath, chartName))
 
 				chartification, clean, err := st.PrepareChartify(helm, release, chartPath, workerIndex)
@@ -1179,13 +1507,23 @@ func (st *HelmState) PrepareCharts(helm helmexec.Interface, dir string, concurre
 				skipDepsGlobal := opts.SkipDeps
 				skipDepsRelease := release.SkipDeps != nil && *release.SkipDeps
 				skipDepsDefault := release.SkipDeps == nil && st.HelmDefaults.SkipDeps
-				skipDeps := (!isLocal && !chartFetchedByGoGetter) || skipDepsGlobal || skipDepsRelease || skipDepsDefault
+				// The vendored charts contain their dependencies, and the dependencies can't be downloaded in the offline mode
+				skipDeps := (!isLocal && !chartFetchedByGoGetter) || skipDepsGlobal || skipDepsRelease || skipDepsDefault || vendored || st.Offline
+
+				if !skipDeps {
+					skipDeps, err = st.skipsDependencyBuild(release, normalizeChart(st.basePath, chartPath))
+					if err != nil {
+						results <- &chartPrepareResult{err: fmt.Errorf("release %q: %w", release.Name, err)}
+						return
+					}
+				}
 
 				if chartification != nil && helmfileCommand != "pull" {
 					c := chartify.New(
 						chartify.HelmBin(st.DefaultHelmBinary),
 						chartify.UseHelm3(true),
 						chartify.WithLogf(st.logger.Debugf),
+						withKustomizeBinary(kustomizeBinary()),
 					)
 
 					chartifyOpts := chartification.Opts
@@ -1266,7 +1604,7 @@ func (st *HelmState) PrepareCharts(helm helmexec.Interface, dir string, concurre
 					if _, err := os.Stat(chartPath); os.IsNotExist(err) {
 						fetchFlags := st.chartVersionFlags(release)
 						fetchFlags = append(fetchFlags, "--untar", "--untardir", chartPath)
-						if err := helm.Fetch(chartName, fetchFlags...); err != nil {
+						if err := helm.Fetch(st.runContext(), chartName, fetchFlags...); err != nil {
 							results <- &chartPrepareResult{err: err}
 							return
 						}
@@ -1289,6 +1627,8 @@ func (st *HelmState) PrepareCharts(helm helmexec.Interface, dir string, concurre
 					buildDeps:              buildDeps,
 					skipRefresh:            !isLocal,
 					chartFetchedByGoGetter: chartFetchedByGoGetter,
+					plainHTTP:              release.PlainHTTP != nil && *release.PlainHTTP,
+					insecureSkipTLSVerify:  release.InsecureSkipTLSVerify != nil && *release.InsecureSkipTLSVerify,
 				}
 			}
 		},
@@ -1340,7 +1680,7 @@ func (st *HelmState) runHelmDepBuilds(helm helmexec.Interface, concurrency int,
 	//    See https://github.com/roboll/helmfile/issues/1521
 	for _, r := range builds {
 		buildDepsFlags := getBuildDepsFlags(r)
-		if err := helm.BuildDeps(r.releaseName, r.chartPath, buildDepsFlags...); err != nil {
+		if err := helm.BuildDeps(st.runContext(), r.releaseName, r.chartPath, buildDepsFlags...); err != nil {
 			if r.chartFetchedByGoGetter {
 				diagnostic := fmt.Sprintf(
 					"WARN: `helm dep build` failed. While processing release %q, Helmfile observed that remote chart %q fetched by go-getter is seemingly broken. "+
@@ -1370,6 +1710,21 @@ type TemplateOpts struct {
 	OutputDirTemplate string
 	IncludeCRDs       bool
 	SkipTests         bool
+	// ShowOnly is the templates to render, either `path` for all the releases or `release:path` for the release.
+	// Releases with no template to show are skipped when this isn't empty.
+	ShowOnly []string
+	// AnnotateSource prefixes each rendered manifest with comments identifying the state file, the release and the chart it came from.
+	// This has no effect when the manifests are written to the output directory.
+	AnnotateSource bool
+	// RenderCacheDir is the directory the rendered manifests of the releases are cached in, to be reused while their inputs stay the same.
+	// Nothing is cached when this is empty.
+	RenderCacheDir string
+	// RefreshRenderCache renders the manifests of all the releases instead of reusing the cached ones, while still caching them
+	RefreshRenderCache bool
+	// OnManifests receives the rendered manifests of each release in place of stdout, to inspect them instead of printing them.
+	OnManifests func(release *ReleaseSpec, manifests []byte)
+	// Stdout is where the rendered manifests are written. Defaults to os.Stdout.
+	Stdout io.Writer
 }
 
 type TemplateOpt interface{ Apply(*TemplateOpts) }
@@ -1378,6 +1733,59 @@ func (o *TemplateOpts) Apply(opts *TemplateOpts) {
 	*opts = *o
 }
 
+// showOnlyFor returns the templates to show for the release out of the `--show-only` values,
+// that are either `path` that applies to all the releases or `release:path` that applies to the release named `release`.
+func showOnlyFor(release *ReleaseSpec, showOnly []string) []string {
+	var templates []string
+
+	for _, s := range showOnly {
+		name, path, scoped := strings.Cut(s, ":")
+		if !scoped {
+			templates = append(templates, s)
+		} else if name == release.Name {
+			templates = append(templates, path)
+		}
+	}
+
+	return templates
+}
+
+// sourceAnnotations returns the comments identifying where the manifests of the release came from.
+// The chart template path is already in the `# Source:` comment helm adds to each manifest.
+func (st *HelmState) sourceAnnotations(release *ReleaseSpec) []string {
+	return []string{
+		"# Helmfile-State: " + st.FilePath,
+		"# Helmfile-Release: " + ReleaseToID(release),
+		"# Helmfile-Chart: " + release.Chart,
+	}
+}
+
+// annotateManifests inserts the annotations at the top of each YAML document in the output of helm-template
+func annotateManifests(out []byte, annotations []string) []byte {
+	if len(bytes.TrimSpace(out)) == 0 {
+		return out
+	}
+
+	header := strings.Join(annotations, "\n") + "\n"
+
+	var sb strings.Builder
+
+	lines := strings.SplitAfter(string(out), "\n")
+
+	if strings.TrimSpace(lines[0]) != "---" {
+		sb.WriteString(header)
+	}
+
+	for _, l := range lines {
+		sb.WriteString(l)
+		if strings.TrimRight(l, " \r\n") == "---" {
+			sb.WriteString(header)
+		}
+	}
+
+	return []byte(sb.String())
+}
+
 // TemplateReleases wrapper for executing helm template on the releases
 func (st *HelmState) TemplateReleases(helm helmexec.Interface, outputDir string, additionalValues []string, args []string, workerLimit int,
 	validate bool, opt ...TemplateOpt) []error {
@@ -1395,6 +1803,12 @@ func (st *HelmState) TemplateReleases(helm helmexec.Interface, outputDir string,
 			continue
 		}
 
+		showOnly := showOnlyFor(release, opts.ShowOnly)
+		if len(opts.ShowOnly) > 0 && len(showOnly) == 0 {
+			st.logger.Debugf("Skipping release %s as it has no template to show", release.Name)
+			continue
+		}
+
 		st.ApplyOverrides(release)
 
 		flags, files, err := st.flagsForTemplate(helm, release, 0)
@@ -1425,13 +1839,21 @@ func (st *HelmState) TemplateReleases(helm helmexec.Interface, outputDir string,
 			}
 		}
 
+		// The manifests of the releases with the transformers are written to the output directory by helmfile,
+		// after they are transformed, instead of helm
+		var transformedOutputDir string
+
 		if len(outputDir) > 0 || len(opts.OutputDirTemplate) > 0 {
 			releaseOutputDir, err := st.GenerateOutputDir(outputDir, release, opts.OutputDirTemplate)
 			if err != nil {
 				errs = append(errs, err)
 			}
 
-			flags = append(flags, "--output-dir", releaseOutputDir)
+			if len(st.manifestTransformers(release)) > 0 {
+				transformedOutputDir = releaseOutputDir
+			} else {
+				flags = append(flags, "--output-dir", releaseOutputDir)
+			}
 			st.logger.Debugf("Generating templates to : %s\n", releaseOutputDir)
 			err = os.MkdirAll(releaseOutputDir, 0755)
 			if err != nil {
@@ -1451,8 +1873,12 @@ func (st *HelmState) TemplateReleases(helm helmexec.Interface, outputDir string,
 			flags = append(flags, "--skip-tests")
 		}
 
+		for _, s := range showOnly {
+			flags = append(flags, "--show-only", s)
+		}
+
 		if len(errs) == 0 {
-			if err := helm.TemplateRelease(release.Name, release.ChartPathOrName(), flags...); err != nil {
+			if err := st.templateRelease(helm, release, args, flags, opts, transformedOutputDir); err != nil {
 				errs = append(errs, err)
 			}
 		}
@@ -1473,6 +1899,8 @@ type WriteValuesOpts struct {
 	Set                []string
 	OutputFileTemplate string
 	SkipCleanup        bool
+	// IncludeChartValues merges the values into the default values of the charts, writing all the values the releases are rendered with
+	IncludeChartValues bool
 }
 
 type WriteValuesOpt interface{ Apply(*WriteValuesOpts) }
@@ -1530,6 +1958,13 @@ func (st *HelmState) WriteReleasesValues(helm helmexec.Interface, additionalValu
 
 		merged := map[string]interface{}{}
 
+		if opts.IncludeChartValues {
+			merged, err = st.chartDefaultValues(helm, release)
+			if err != nil {
+				return []error{fmt.Errorf("release %q: %w", release.Name, err)}
+			}
+		}
+
 		for _, f := range append(generatedFiles, additionalValues...) {
 			src := map[string]interface{}{}
 
@@ -1547,6 +1982,21 @@ func (st *HelmState) WriteReleasesValues(helm helmexec.Interface, additionalValu
 			}
 		}
 
+		// The values set by `set` of the release and --set take precedence over the values files, as they do on helm
+		setFlags, err := st.setFlags(release.SetValues)
+		if err != nil {
+			return []error{fmt.Errorf("Failed to render set value entry in %s for release %s: %v", st.FilePath, release.Name, err)}
+		}
+
+		for _, s := range opts.Set {
+			setFlags = append(setFlags, "--set", s)
+		}
+
+		merged, err = mergeSetValues(merged, setFlags)
+		if err != nil {
+			return []error{fmt.Errorf("release %q: %w", release.Name, err)}
+		}
+
 		var buf bytes.Buffer
 
 		encoder := yaml.NewEncoder(&buf)
@@ -1628,7 +2078,7 @@ func (st *HelmState) LintReleases(helm helmexec.Interface, additionalValues []st
 		}
 
 		if len(errs) == 0 {
-			if err := helm.Lint(release.Name, release.ChartPathOrName(), flags...); err != nil {
+			if err := helm.Lint(st.runContext(), release.Name, release.ChartPathOrName(), flags...); err != nil {
 				errs = append(errs, err)
 			}
 		}
@@ -1686,13 +2136,16 @@ func (st *HelmState) commonDiffFlags(detailedExitCode bool, includeTests bool, s
 		flags = append(flags, "--no-hooks")
 	}
 
-	if opt.NoColor {
+	// The rich renderer colors and collapses the uncolored full diffs by itself, and the json renderer parses the uncolored diffs
+	rich := opt.Renderer == DiffRendererRich
+
+	if opt.NoColor || rich || opt.Renderer == DiffRendererJSON {
 		flags = append(flags, "--no-color")
 	} else if opt.Color {
 		flags = append(flags, "--color")
 	}
 
-	if opt.Context > 0 {
+	if opt.Context > 0 && !rich {
 		flags = append(flags, "--context", fmt.Sprintf("%d", opt.Context))
 	}
 
@@ -1850,9 +2303,16 @@ func (st *HelmState) createHelmContext(spec *ReleaseSpec, workerIndex int) helme
 		historyMax = *spec.HistoryMax
 	}
 
+	// The invalid commandTimeout fails on building the flags of the release
+	timeout, _ := st.commandTimeout(spec)
+
 	return helmexec.HelmContext{
+		Context:     st.runContext(),
 		WorkerIndex: workerIndex,
 		HistoryMax:  historyMax,
+		Timeout:     timeout,
+		LiveOutput:  st.liveOutput(spec),
+		Env:         st.secretsBackendsEnv(),
 	}
 }
 
@@ -1878,6 +2338,25 @@ type DiffOpts struct {
 	SkipDiffOnInstall bool
 	ReuseValues       bool
 	ResetValues       bool
+	// Stdout is where the helm-diff outputs are written. Defaults to os.Stdout.
+	Stdout io.Writer
+	// OutputDir is the directory the helm-diff output of each release is additionally written to.
+	// Nothing is written when this is empty.
+	OutputDir string
+	// OutputFileTemplate is the go text template for the path of each release's diff file relative to OutputDir.
+	// Defaults to DefaultDiffOutputFileTemplate.
+	OutputFileTemplate string
+	// Renderer is how the helm-diff outputs are rendered, one of DiffRendererDefault, DiffRendererRich and DiffRendererJSON
+	Renderer string
+	// CacheDir is the directory the releases found with no changes are recorded in, not to be diffed again
+	// while their inputs and the deployed releases stay the same. Nothing is cached when this is empty.
+	CacheDir string
+	// RefreshCache diffs all the releases instead of skipping the ones recorded in CacheDir, while still recording them
+	RefreshCache bool
+	// OnDiffResult is called with the result of the diff of each release in the order of the plan, after the diffs are written
+	OnDiffResult func(release *ReleaseSpec, result DiffResult)
+	// HelmfileCommand is the command the releases are diffed for, exposed to the postdiff and onFailure hooks, which defaults to `diff`
+	HelmfileCommand string
 }
 
 func (o *DiffOpts) Apply(opts *DiffOpts) {
@@ -1900,6 +2379,10 @@ func (st *HelmState) DiffReleases(helm helmexec.Interface, additionalValues []st
 		o.Apply(opts)
 	}
 
+	if err := ValidateDiffRenderer(opts.Renderer, opts.Output); err != nil {
+		return nil, []error{err}
+	}
+
 	preps, prepErrs := st.prepareDiffReleases(helm, additionalValues, workerLimit, detailedExitCode, includeTests, suppress, suppressSecrets, showSecrets, noHooks, opts)
 
 	if !opts.SkipCleanup {
@@ -1914,11 +2397,19 @@ func (st *HelmState) DiffReleases(helm helmexec.Interface, additionalValues []st
 		return []ReleaseSpec{}, prepErrs
 	}
 
+	// Start the releases in the order of the plan, as the preparations finish in any order
+	order := st.releaseOrder()
+	sort.SliceStable(preps, func(i, j int) bool {
+		return order(preps[i].release) < order(preps[j].release)
+	})
+
 	jobQueue := make(chan *diffPrepareResult, len(preps))
 	results := make(chan diffResult, len(preps))
 
 	rs := []ReleaseSpec{}
 	outputs := map[string]*bytes.Buffer{}
+	changed := map[string]bool{}
+	failed := map[string]error{}
 	errs := []error{}
 
 	// The exit code returned by helm-diff when it detected any changes
@@ -1938,11 +2429,31 @@ func (st *HelmState) DiffReleases(helm helmexec.Interface, additionalValues []st
 				flags := prep.flags
 				release := prep.release
 				buf := &bytes.Buffer{}
+				// The rules can only be applied to the default output format of helm-diff
+				ignoreDiffs := len(release.IgnoreDiffs) > 0 && (opts.Output == "" || opts.Output == "diff")
+				var cacheFile string
+				if opts.CacheDir != "" && !prep.upgradeDueToSkippedDiff {
+					cacheFile = st.diffCacheFile(helm, release, flags, opts.CacheDir)
+				}
 				if prep.upgradeDueToSkippedDiff {
 					results <- diffResult{release, &ReleaseError{ReleaseSpec: release, err: nil, Code: HelmDiffExitCodeChanged}, buf}
+				} else if cacheFile != "" && !opts.RefreshCache && st.fs.FileExistsAt(cacheFile) {
+					touchCacheEntry(cacheFile)
+					st.logger.Infof("Skipped diffing release=%v, as the cached result of the previous run found no changes with the same inputs and the same deployed release. "+
+						"Run without --render-cache to detect the changes made outside of Helm", release.Name)
+					results <- diffResult{release, nil, buf}
 				} else if err := helm.DiffRelease(st.createHelmContextWithWriter(release, buf), release.Name, normalizeChart(st.basePath, release.ChartPathOrName()), suppressDiff, flags...); err != nil {
 					switch e := err.(type) {
 					case helmexec.ExitError:
+						if e.ExitStatus() == HelmDiffExitCodeChanged && ignoreDiffs {
+							filtered, changed := filterIgnoredDiffs(buf.Bytes(), release.IgnoreDiffs)
+							buf = bytes.NewBuffer(filtered)
+							if !changed {
+								// all the changes are of the ignored fields
+								results <- diffResult{release, nil, buf}
+								break
+							}
+						}
 						// Propagate any non-zero exit status from the external command like `helm` that is failed under the hood
 						results <- diffResult{release, &ReleaseError{release, err, e.ExitStatus()}, buf}
 					default:
@@ -1950,6 +2461,15 @@ func (st *HelmState) DiffReleases(helm helmexec.Interface, additionalValues []st
 					}
 				} else {
 					// diff succeeded, found no changes
+					if ignoreDiffs {
+						// Without --detailed-exitcode, helm-diff succeeds even when there are changes
+						filtered, _ := filterIgnoredDiffs(buf.Bytes(), release.IgnoreDiffs)
+						buf = bytes.NewBuffer(filtered)
+					}
+					// Without --detailed-exitcode, helm-diff succeeds with the changes in its output
+					if cacheFile != "" && (detailedExitCode || len(bytes.TrimSpace(buf.Bytes())) == 0) {
+						st.cacheDiff(release, cacheFile)
+					}
 					results <- diffResult{release, nil, buf}
 				}
 
@@ -1967,6 +2487,9 @@ func (st *HelmState) DiffReleases(helm helmexec.Interface, additionalValues []st
 					errs = append(errs, res.err)
 					if res.err.Code == HelmDiffExitCodeChanged {
 						rs = append(rs, *res.err.ReleaseSpec)
+						changed[ReleaseToID(res.release)] = true
+					} else {
+						failed[ReleaseToID(res.release)] = res.err
 					}
 				}
 
@@ -1975,38 +2498,155 @@ func (st *HelmState) DiffReleases(helm helmexec.Interface, additionalValues []st
 		},
 	)
 
+	w := opts.Stdout
+	if w == nil {
+		w = os.Stdout
+	}
+
 	for _, p := range preps {
 		id := ReleaseToID(p.release)
 		if stdout, ok := outputs[id]; ok {
-			fmt.Print(stdout.String())
+			switch opts.Renderer {
+			case DiffRendererRich:
+				w.Write(renderRichDiff(stdout.Bytes(), opts.Context, opts.Color && !opts.NoColor))
+			case DiffRendererJSON:
+				out, err := renderJSONDiff(id, stdout.Bytes(), changed[id])
+				if err != nil {
+					errs = append(errs, err)
+					continue
+				}
+				w.Write(out)
+			default:
+				fmt.Fprint(w, stdout.String())
+			}
 		} else {
 			panic(fmt.Sprintf("missing output for release %s", id))
 		}
 	}
 
-	return rs, errs
-}
-
-func (st *HelmState) ReleaseStatuses(helm helmexec.Interface, workerLimit int) []error {
-	return st.scatterGatherReleases(helm, workerLimit, func(release ReleaseSpec, workerIndex int) error {
-		if !release.Desired() {
-			return nil
+	if opts.OnDiffResult != nil {
+		for _, p := range preps {
+			id := ReleaseToID(p.release)
+			opts.OnDiffResult(p.release, parseDiffResult(id, outputs[id].Bytes(), changed[id]))
 		}
+	}
 
-		st.ApplyOverrides(&release)
+	helmfileCommand := opts.HelmfileCommand
+	if helmfileCommand == "" {
+		helmfileCommand = "diff"
+	}
 
-		flags := []string{}
-		if release.Namespace != "" {
-			flags = append(flags, "--namespace", release.Namespace)
+	for _, p := range preps {
+		id := ReleaseToID(p.release)
+		if err, ok := failed[id]; ok {
+			if _, hookErr := st.triggerOnFailureEvent(p.release, err, helmfileCommand); hookErr != nil {
+				st.logger.Warnf("warn: %v\n", hookErr)
+			}
+			continue
 		}
-		flags = st.appendConnectionFlags(flags, &release)
 
-		return helm.ReleaseStatus(st.createHelmContext(&release, workerIndex), release.Name, flags...)
+		result := parseDiffResult(id, outputs[id].Bytes(), changed[id])
+		if _, err := st.triggerPostdiffEvent(p.release, &result, helmfileCommand); err != nil {
+			errs = append(errs, newReleaseFailedError(p.release, err))
+		}
+	}
+
+	if opts.OutputDir != "" {
+		for _, p := range preps {
+			id := ReleaseToID(p.release)
+			out := outputs[id].Bytes()
+			switch opts.Renderer {
+			case DiffRendererRich:
+				out = renderRichDiff(out, opts.Context, false)
+			case DiffRendererJSON:
+				var err error
+				if out, err = renderJSONDiff(id, out, changed[id]); err != nil {
+					errs = append(errs, err)
+					continue
+				}
+			}
+			if err := writeDiffOutput(opts.OutputDir, p.release, opts.OutputFileTemplate, out); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return rs, errs
+}
+
+// writeDiffOutput writes the helm-diff output of the release to the path generated from the template under the output directory.
+// The file is written even when the diff is empty so that it records that the release had no changes.
+func writeDiffOutput(outputDir string, release *ReleaseSpec, outputFileTemplate string, out []byte) error {
+	if outputFileTemplate == "" {
+		outputFileTemplate = DefaultDiffOutputFileTemplate
+	}
+
+	t, err := template.New("diff-output-file-template").Parse(outputFileTemplate)
+	if err != nil {
+		return fmt.Errorf("parsing diff-output-file-template template %q: %w", outputFileTemplate, err)
+	}
+
+	buf := &bytes.Buffer{}
+	data := struct {
+		Release ReleaseSpec
+	}{
+		Release: *release,
+	}
+	if err := t.Execute(buf, data); err != nil {
+		return fmt.Errorf("executing diff-output-file-template template: %w", err)
+	}
+
+	path := filepath.Join(outputDir, buf.String())
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("creating directory for diff output of release %s: %w", release.Name, err)
+	}
+
+	if err := os.WriteFile(path, out, 0644); err != nil {
+		return fmt.Errorf("writing diff output of release %s to %s: %w", release.Name, path, err)
+	}
+
+	return nil
+}
+
+func (st *HelmState) ReleaseStatuses(helm helmexec.Interface, workerLimit int) []error {
+	return st.scatterGatherReleases(helm, workerLimit, func(release ReleaseSpec, workerIndex int) error {
+		if !release.Desired() {
+			return nil
+		}
+
+		st.ApplyOverrides(&release)
+
+		flags := []string{}
+		if release.Namespace != "" {
+			flags = append(flags, "--namespace", release.Namespace)
+		}
+		flags = st.appendConnectionFlags(flags, &release)
+
+		return helm.ReleaseStatus(st.createHelmContext(&release, workerIndex), release.Name, flags...)
 	})
 }
 
+type DeleteOpts struct {
+	// Wait makes helm wait for the resources of each release to be deleted before returning
+	Wait bool
+	// Timeout is the seconds helm waits for the resources to be deleted, which defaults to the one of helm when 0
+	Timeout int
+}
+
+type DeleteOpt interface{ Apply(*DeleteOpts) }
+
+func (o *DeleteOpts) Apply(opts *DeleteOpts) {
+	*opts = *o
+}
+
 // DeleteReleases wrapper for executing helm delete on the releases
-func (st *HelmState) DeleteReleases(affectedReleases *AffectedReleases, helm helmexec.Interface, concurrency int, purge bool) []error {
+func (st *HelmState) DeleteReleases(affectedReleases *AffectedReleases, helm helmexec.Interface, concurrency int, purge bool, opt ...DeleteOpt) []error {
+	opts := &DeleteOpts{}
+	for _, o := range opt {
+		o.Apply(opts)
+	}
+
 	return st.scatterGatherReleases(helm, concurrency, func(release ReleaseSpec, workerIndex int) error {
 		st.ApplyOverrides(&release)
 
@@ -2015,22 +2655,34 @@ func (st *HelmState) DeleteReleases(affectedReleases *AffectedReleases, helm hel
 		if release.Namespace != "" {
 			flags = append(flags, "--namespace", release.Namespace)
 		}
+		if opts.Wait {
+			flags = append(flags, "--wait")
+			if opts.Timeout > 0 {
+				flags = append(flags, "--timeout", fmt.Sprintf("%ds", opts.Timeout))
+			}
+		}
 		context := st.createHelmContext(&release, workerIndex)
 
-		if _, err := st.triggerReleaseEvent("preuninstall", nil, &release, "delete"); err != nil {
+		fail := func(err error) error {
 			affectedReleases.Failed = append(affectedReleases.Failed, &release)
 
+			if _, hookErr := st.triggerOnFailureEvent(&release, err, "delete"); hookErr != nil {
+				st.logger.Warnf("warn: %v\n", hookErr)
+			}
+
 			return err
 		}
 
+		if _, err := st.triggerReleaseEvent("preuninstall", nil, &release, "delete"); err != nil {
+			return fail(err)
+		}
+
 		if err := helm.DeleteRelease(context, release.Name, flags...); err != nil {
-			affectedReleases.Failed = append(affectedReleases.Failed, &release)
-			return err
+			return fail(err)
 		}
 
 		if _, err := st.triggerReleaseEvent("postuninstall", nil, &release, "delete"); err != nil {
-			affectedReleases.Failed = append(affectedReleases.Failed, &release)
-			return err
+			return fail(err)
 		}
 
 		affectedReleases.Deleted = append(affectedReleases.Deleted, &release)
@@ -2040,6 +2692,12 @@ func (st *HelmState) DeleteReleases(affectedReleases *AffectedReleases, helm hel
 
 type TestOpts struct {
 	Logs bool
+	// LogsDir is the directory to write the output of each failed release test to, including the logs of the test pods
+	LogsDir string
+	// Results collects the result of each release test
+	Results *TestResults
+	// Output is where the outputs of the release tests are printed, which defaults to os.Stdout
+	Output io.Writer
 }
 
 type TestOption func(*TestOpts)
@@ -2050,6 +2708,24 @@ func Logs(v bool) func(*TestOpts) {
 	}
 }
 
+func LogsDir(dir string) func(*TestOpts) {
+	return func(o *TestOpts) {
+		o.LogsDir = dir
+	}
+}
+
+func CollectTestResults(r *TestResults) func(*TestOpts) {
+	return func(o *TestOpts) {
+		o.Results = r
+	}
+}
+
+func TestOutput(w io.Writer) func(*TestOpts) {
+	return func(o *TestOpts) {
+		o.Output = w
+	}
+}
+
 // TestReleases wrapper for executing helm test on the releases
 func (st *HelmState) TestReleases(helm helmexec.Interface, cleanup bool, timeout int, concurrency int, options ...TestOption) []error {
 	var opts TestOpts
@@ -2058,8 +2734,17 @@ func (st *HelmState) TestReleases(helm helmexec.Interface, cleanup bool, timeout
 		o(&opts)
 	}
 
+	var w io.Writer = os.Stdout
+	if opts.Output != nil {
+		w = opts.Output
+	}
+
+	// outputMu prevents the outputs of the release tests run concurrently from interleaving
+	var outputMu sync.Mutex
+
 	return st.scatterGatherReleases(helm, concurrency, func(release ReleaseSpec, workerIndex int) error {
 		if !release.Desired() {
+			opts.Results.add(TestResult{ID: ReleaseToID(&release), Release: release.Name, Result: TestResultSkipped})
 			return nil
 		}
 
@@ -2067,7 +2752,7 @@ func (st *HelmState) TestReleases(helm helmexec.Interface, cleanup bool, timeout
 		if release.Namespace != "" {
 			flags = append(flags, "--namespace", release.Namespace)
 		}
-		if opts.Logs {
+		if opts.Logs || opts.LogsDir != "" {
 			flags = append(flags, "--logs")
 		}
 
@@ -2081,7 +2766,22 @@ func (st *HelmState) TestReleases(helm helmexec.Interface, cleanup bool, timeout
 
 		flags = st.appendConnectionFlags(flags, &release)
 
-		return helm.TestRelease(st.createHelmContext(&release, workerIndex), release.Name, flags...)
+		buf := &bytes.Buffer{}
+		context := st.createHelmContext(&release, workerIndex)
+		context.Writer = buf
+
+		started := time.Now()
+
+		err := helm.TestRelease(context, release.Name, flags...)
+		duration := time.Since(started)
+
+		if buf.Len() > 0 {
+			outputMu.Lock()
+			_, _ = w.Write(buf.Bytes())
+			outputMu.Unlock()
+		}
+
+		return st.reportTestResult(&release, buf.Bytes(), err, duration, opts)
 	})
 }
 
@@ -2102,14 +2802,14 @@ func (st *HelmState) GetReleasesWithOverrides() []ReleaseSpec {
 
 func (st *HelmState) SelectReleases(includeTransitiveNeeds bool) ([]Release, error) {
 	values := st.Values()
-	rs, err := markExcludedReleases(st.Releases, st.Selectors, st.CommonLabels, values, includeTransitiveNeeds)
+	rs, err := markExcludedReleases(st.Releases, st.Selectors, st.CommonLabels, st.Env.Name, values, includeTransitiveNeeds)
 	if err != nil {
 		return nil, err
 	}
 	return rs, nil
 }
 
-func markExcludedReleases(releases []ReleaseSpec, selectors []string, commonLabels map[string]string, values map[string]interface{}, includeTransitiveNeeds bool) ([]Release, error) {
+func markExcludedReleases(releases []ReleaseSpec, selectors []string, commonLabels map[string]string, env string, values map[string]interface{}, includeTransitiveNeeds bool) ([]Release, error) {
 	var filteredReleases []Release
 	filters := []ReleaseFilter{}
 	for _, label := range selectors {
@@ -2144,7 +2844,7 @@ func markExcludedReleases(releases []ReleaseSpec, selectors []string, commonLabe
 			}
 		}
 		var conditionMatch bool
-		conditionMatch, err := ConditionEnabled(r, values)
+		conditionMatch, err := ReleaseEnabled(r, env, values)
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse condition in release %s: %w", r.Name, err)
 		}
@@ -2183,6 +2883,34 @@ func ConditionEnabled(r ReleaseSpec, values map[string]interface{}) (bool, error
 	return conditionMatch, nil
 }
 
+// ReleaseEnabled returns whether both the condition and the enabledIf expression of the release, if any, are true
+// for the environment and the values
+func ReleaseEnabled(r ReleaseSpec, env string, values map[string]interface{}) (bool, error) {
+	enabled, err := ConditionEnabled(r, values)
+	if err != nil || !enabled {
+		return enabled, err
+	}
+
+	if r.EnabledIf == "" {
+		return true, nil
+	}
+
+	data := map[string]interface{}{
+		"Values": values,
+		"Environment": map[string]interface{}{
+			"Name":   env,
+			"Values": values,
+		},
+	}
+
+	enabled, err = expr.EvalBool(r.EnabledIf, data)
+	if err != nil {
+		return false, fmt.Errorf("enabledIf %q: %v", r.EnabledIf, err)
+	}
+
+	return enabled, nil
+}
+
 func unmarkNeedsAndTransitives(filteredReleases []Release, allReleases []ReleaseSpec) {
 	needsWithTranstives := collectAllNeedsWithTransitives(filteredReleases, allReleases)
 	unmarkReleases(needsWithTranstives, filteredReleases)
@@ -2254,20 +2982,87 @@ func (st *HelmState) TriggerGlobalCleanupEvent(helmfileCommand string) (bool, er
 	return st.triggerGlobalReleaseEvent("cleanup", nil, helmfileCommand)
 }
 
-func (st *HelmState) triggerGlobalReleaseEvent(evt string, evtErr error, helmfileCmd string) (bool, error) {
-	bus := &event.Bus{
-		Hooks:         st.Hooks,
-		StateFilePath: st.FilePath,
-		BasePath:      st.basePath,
-		Namespace:     st.OverrideNamespace,
-		Chart:         st.OverrideChart,
-		Env:           st.Env,
-		Logger:        st.logger,
-		Fs:            st.fs,
+// runCommands are the helmfile commands triggering the `pre<command>` and `post<command>` hooks of the root helmfiles,
+// like `preapply` and `postapply`, once per invocation along with the `prerun` and `postrun` hooks
+var runCommands = []string{"apply", "sync", "diff", "delete", "destroy"}
+
+func isRunCommand(helmfileCommand string) bool {
+	for _, c := range runCommands {
+		if c == helmfileCommand {
+			return true
+		}
 	}
-	data := map[string]interface{}{
-		"HelmfileCommand": helmfileCmd,
+	return false
+}
+
+// TriggerPrerunEvent triggers the `prerun` hooks, followed by the `pre<command>` hooks of the helmfile command,
+// which are run once per invocation before processing any release
+func (st *HelmState) TriggerPrerunEvent(helmfileCommand string) (bool, error) {
+	executed, err := st.triggerGlobalReleaseEvent("prerun", nil, helmfileCommand)
+	if err != nil || !isRunCommand(helmfileCommand) {
+		return executed, err
+	}
+
+	ok, err := st.triggerGlobalReleaseEvent("pre"+helmfileCommand, nil, helmfileCommand)
+
+	return executed || ok, err
+}
+
+// TriggerPostrunEvent triggers the `post<command>` hooks of the helmfile command, followed by the `postrun` hooks,
+// which are run once per invocation after processing all the releases, with the summary of the run available as `.Run`.
+// The `postrun` hooks are triggered even when the `post<command>` hooks fail, and the first error is returned.
+func (st *HelmState) TriggerPostrunEvent(helmfileCommand string, evtErr error, summary interface{}) (bool, error) {
+	data := func() map[string]interface{} {
+		return map[string]interface{}{
+			"HelmfileCommand": helmfileCommand,
+			"Run":             summary,
+		}
 	}
+
+	var (
+		executed bool
+		cmdErr   error
+	)
+
+	if isRunCommand(helmfileCommand) {
+		executed, cmdErr = st.triggerGlobalEvent("post"+helmfileCommand, evtErr, data())
+	}
+
+	ok, err := st.triggerGlobalEvent("postrun", evtErr, data())
+	if cmdErr != nil {
+		err = cmdErr
+	}
+
+	return executed || ok, err
+}
+
+func (st *HelmState) triggerGlobalReleaseEvent(evt string, evtErr error, helmfileCmd string) (bool, error) {
+	return st.triggerGlobalEvent(evt, evtErr, map[string]interface{}{
+		"HelmfileCommand": helmfileCmd,
+	})
+}
+
+func (st *HelmState) triggerGlobalEvent(evt string, evtErr error, data map[string]interface{}) (bool, error) {
+	bus := &event.Bus{
+		Hooks:          st.Hooks,
+		StateFilePath:  st.FilePath,
+		BasePath:       st.basePath,
+		Namespace:      st.OverrideNamespace,
+		Chart:          st.OverrideChart,
+		Env:            st.Env,
+		Logger:         st.logger,
+		Fs:             st.fs,
+		HelmBinary:     st.DefaultHelmBinary,
+		ClusterLookups: st.ClusterLookups,
+	}
+	if st.HookPlan != nil {
+		bus.Planned = st.HookPlan.recorder(st.FilePath, "")
+	}
+	kubeContext := st.OverrideKubeContext
+	if kubeContext == "" {
+		kubeContext = st.HelmDefaults.KubeContext
+	}
+	data["KubeContext"] = kubeContext
 	return bus.Trigger(evt, evtErr, data)
 }
 
@@ -2287,38 +3082,85 @@ func (st *HelmState) triggerPostsyncEvent(r *ReleaseSpec, evtErr error, helmfile
 	return st.triggerReleaseEvent("postsync", evtErr, r, helmfileCommand)
 }
 
-func (st *HelmState) TriggerPreapplyEvent(r *ReleaseSpec, helmfileCommand string) (bool, error) {
-	return st.triggerReleaseEvent("preapply", nil, r, helmfileCommand)
+// TriggerPreapplyEvent triggers the `preapply` hooks of the release, with the result of the diff of the release available as `.Diff` if any
+func (st *HelmState) TriggerPreapplyEvent(r *ReleaseSpec, diff *DiffResult, helmfileCommand string) (bool, error) {
+	return st.triggerReleaseEventWithData("preapply", nil, r, helmfileCommand, map[string]interface{}{"Diff": diff})
+}
+
+// triggerPostdiffEvent triggers the `postdiff` hooks of the release after it is diffed, with the result of the diff available as `.Diff`
+func (st *HelmState) triggerPostdiffEvent(r *ReleaseSpec, diff *DiffResult, helmfileCommand string) (bool, error) {
+	return st.triggerReleaseEventWithData("postdiff", nil, r, helmfileCommand, map[string]interface{}{"Diff": diff})
+}
+
+// triggerOnFailureEvent triggers the `onFailure` hooks of the release when helm fails on it, with the error available as `.Event.Error`
+func (st *HelmState) triggerOnFailureEvent(r *ReleaseSpec, evtErr error, helmfileCommand string) (bool, error) {
+	return st.triggerReleaseEvent("onFailure", evtErr, r, helmfileCommand)
 }
 
 func (st *HelmState) triggerReleaseEvent(evt string, evtErr error, r *ReleaseSpec, helmfileCmd string) (bool, error) {
+	return st.triggerReleaseEventWithData(evt, evtErr, r, helmfileCmd, nil)
+}
+
+// triggerReleaseEventWithData triggers the hooks of the release for the event, with the data added to the template data of the hooks
+func (st *HelmState) triggerReleaseEventWithData(evt string, evtErr error, r *ReleaseSpec, helmfileCmd string, extra map[string]interface{}) (bool, error) {
 	bus := &event.Bus{
-		Hooks:         r.Hooks,
-		StateFilePath: st.FilePath,
-		BasePath:      st.basePath,
-		Namespace:     st.OverrideNamespace,
-		Chart:         st.OverrideChart,
-		Env:           st.Env,
-		Logger:        st.logger,
-		Fs:            st.fs,
+		Hooks:          r.Hooks,
+		StateFilePath:  st.FilePath,
+		BasePath:       st.basePath,
+		Namespace:      st.releaseTemplateNamespace(r),
+		Chart:          st.OverrideChart,
+		Env:            st.Env,
+		Logger:         st.logger,
+		Fs:             st.fs,
+		HelmBinary:     st.DefaultHelmBinary,
+		ClusterLookups: st.ClusterLookups,
+	}
+	if st.HookPlan != nil {
+		bus.Planned = st.HookPlan.recorder(st.FilePath, ReleaseToID(r))
 	}
 	vals := st.Values()
 	data := map[string]interface{}{
 		"Values":          vals,
 		"Release":         r,
+		"KubeContext":     st.releaseTemplateKubeContext(r),
 		"HelmfileCommand": helmfileCmd,
 	}
+	for k, v := range extra {
+		data[k] = v
+	}
 
 	return bus.Trigger(evt, evtErr, data)
 }
 
-// ResolveDeps returns a copy of this helmfile state with the concrete chart version numbers filled in for remote chart dependencies
+// ResolveDeps returns a copy of this helmfile state with the concrete chart version numbers filled in for remote chart dependencies.
+// It fails when any remote source pinned in the lock file has changed.
 func (st *HelmState) ResolveDeps() (*HelmState, error) {
+	if err := st.verifyLockedRemotes(); err != nil {
+		return nil, err
+	}
+
 	return st.mergeLockedDependencies()
 }
 
+type DepsOpts struct {
+	// UpdateLocks makes the remote sources fetched again and the OCI charts resolved to their digests again,
+	// instead of keeping the checksums and the digests already locked
+	UpdateLocks bool
+}
+
+type DepsOpt interface{ Apply(*DepsOpts) }
+
+func (o *DepsOpts) Apply(opts *DepsOpts) {
+	*opts = *o
+}
+
 // UpdateDeps wrapper for updating dependencies on the releases
-func (st *HelmState) UpdateDeps(helm helmexec.Interface, includeTransitiveNeeds bool) []error {
+func (st *HelmState) UpdateDeps(helm helmexec.Interface, includeTransitiveNeeds bool, opt ...DepsOpt) []error {
+	opts := &DepsOpts{}
+	for _, o := range opt {
+		o.Apply(opts)
+	}
+
 	var selected []ReleaseSpec
 
 	if len(st.Selectors) > 0 {
@@ -2340,7 +3182,7 @@ func (st *HelmState) UpdateDeps(helm helmexec.Interface, includeTransitiveNeeds
 
 	for _, release := range releases {
 		if st.fs.DirectoryExistsAt(release.ChartPathOrName()) {
-			if err := helm.UpdateDeps(release.ChartPathOrName()); err != nil {
+			if err := helm.UpdateDeps(st.runContext(), release.ChartPathOrName()); err != nil {
 				errs = append(errs, err)
 			}
 		} else {
@@ -2353,7 +3195,7 @@ func (st *HelmState) UpdateDeps(helm helmexec.Interface, includeTransitiveNeeds
 		if tempDir == nil {
 			tempDir = os.MkdirTemp
 		}
-		_, err := st.updateDependenciesInTempDir(helm, tempDir)
+		_, err := st.updateDependenciesInTempDir(helm, tempDir, opts.UpdateLocks)
 		if err != nil {
 			errs = append(errs, fmt.Errorf("unable to update deps: %v", err))
 		}
@@ -2458,7 +3300,7 @@ func (st *HelmState) flagsForUpgrade(helm helmexec.Interface, release *ReleaseSp
 		flags = append(flags, "--verify")
 	}
 
-	if release.Wait != nil && *release.Wait || release.Wait == nil && st.HelmDefaults.Wait {
+	if st.Waits(release) {
 		flags = append(flags, "--wait")
 	}
 
@@ -2468,7 +3310,7 @@ func (st *HelmState) flagsForUpgrade(helm helmexec.Interface, release *ReleaseSp
 
 	flags = append(flags, st.timeoutFlags(release)...)
 
-	if release.Force != nil && *release.Force || release.Force == nil && st.HelmDefaults.Force {
+	if st.Forces(release) {
 		flags = append(flags, "--force")
 	}
 
@@ -2509,7 +3351,13 @@ func (st *HelmState) flagsForUpgrade(helm helmexec.Interface, release *ReleaseSp
 	if err != nil {
 		return nil, clean, err
 	}
-	return append(flags, common...), clean, nil
+
+	preserved, err := st.preservedValuesFlags(release, common)
+	if err != nil {
+		return nil, clean, err
+	}
+
+	return append(append(flags, preserved...), common...), clean, nil
 }
 
 func (st *HelmState) flagsForTemplate(helm helmexec.Interface, release *ReleaseSpec, workerIndex int) ([]string, []string, error) {
@@ -2566,7 +3414,13 @@ func (st *HelmState) flagsForDiff(helm helmexec.Interface, release *ReleaseSpec,
 	if err != nil {
 		return nil, files, err
 	}
-	return append(flags, common...), files, nil
+
+	preserved, err := st.preservedValuesFlags(release, common)
+	if err != nil {
+		return nil, files, err
+	}
+
+	return append(append(flags, preserved...), common...), files, nil
 }
 
 func (st *HelmState) chartVersionFlags(release *ReleaseSpec) []string {
@@ -2638,8 +3492,20 @@ func (st *HelmState) newReleaseTemplateData(release *ReleaseSpec) releaseTemplat
 	return templateData
 }
 
+// newFileRenderer returns the renderer of the templates of the state,
+// resolving the secret references with the secrets backends of the state
+func (st *HelmState) newFileRenderer(dir string, data interface{}) *tmpl.FileRenderer {
+	r := tmpl.NewFileRenderer(st.fs, dir, data)
+	r.Context.SetHelmBinary(st.DefaultHelmBinary)
+	r.Context.SetClusterLookups(st.ClusterLookups)
+	if st.secretsBackendsEnv() != nil && st.valsRuntime != nil {
+		r.Context.SetSecretsEvaluator(st.valsRuntime)
+	}
+	return r
+}
+
 func (st *HelmState) newReleaseTemplateFuncMap(dir string) template.FuncMap {
-	r := tmpl.NewFileRenderer(st.fs, dir, nil)
+	r := st.newFileRenderer(dir, nil)
 
 	return r.Context.CreateFuncMap()
 }
@@ -2647,7 +3513,7 @@ func (st *HelmState) newReleaseTemplateFuncMap(dir string) template.FuncMap {
 func (st *HelmState) RenderReleaseValuesFileToBytes(release *ReleaseSpec, path string) ([]byte, error) {
 	templateData := st.newReleaseTemplateData(release)
 
-	r := tmpl.NewFileRenderer(st.fs, filepath.Dir(path), templateData)
+	r := st.newFileRenderer(filepath.Dir(path), templateData)
 	rawBytes, err := r.RenderToBytes(path)
 	if err != nil {
 		return nil, err
@@ -2683,10 +3549,31 @@ func (st *HelmState) storage() *Storage {
 		basePath: st.basePath,
 		logger:   st.logger,
 		fs:       st.fs,
+		offline:  st.Offline,
 	}
 }
 
+// Ref returns the reference to the sub-helmfile at the index of `helmfiles:` in logs and errors,
+// which is its name if any
+func (hf SubHelmfileSpec) Ref(index int) string {
+	if hf.Name != "" {
+		return fmt.Sprintf("helmfile %q", hf.Name)
+	}
+	return fmt.Sprintf(".helmfiles[%d]", index)
+}
+
 func (st *HelmState) ExpandedHelmfiles() ([]SubHelmfileSpec, error) {
+	names := map[string]int{}
+	for i, hf := range st.Helmfiles {
+		if hf.Name == "" {
+			continue
+		}
+		if j, ok := names[hf.Name]; ok {
+			return nil, fmt.Errorf("duplicate name %q found in .helmfiles[%d] and .helmfiles[%d]", hf.Name, j, i)
+		}
+		names[hf.Name] = i
+	}
+
 	helmfiles := []SubHelmfileSpec{}
 	for _, hf := range st.Helmfiles {
 		if remote.IsRemote(hf.Path) {
@@ -2777,7 +3664,7 @@ func (st *HelmState) generateTemporaryReleaseValuesFiles(release *ReleaseSpec, v
 				return generatedFiles, fmt.Errorf("failed to render values files \"%s\": %v", typedValue, err)
 			}
 
-			valfile, err := createTempValuesFile(release, yamlBytes)
+			valfile, err := st.createTempValuesFile(release, yamlBytes)
 			if err != nil {
 				return generatedFiles, err
 			}
@@ -2793,7 +3680,7 @@ func (st *HelmState) generateTemporaryReleaseValuesFiles(release *ReleaseSpec, v
 
 			generatedFiles = append(generatedFiles, valfile.Name())
 		case map[interface{}]interface{}, map[string]interface{}:
-			valfile, err := createTempValuesFile(release, typedValue)
+			valfile, err := st.createTempValuesFile(release, typedValue)
 			if err != nil {
 				return generatedFiles, err
 			}
@@ -2849,9 +3736,29 @@ func (st *HelmState) generateVanillaValuesFiles(release *ReleaseSpec) ([]string,
 }
 
 func (st *HelmState) generateSecretValuesFiles(helm helmexec.Interface, release *ReleaseSpec, workerIndex int) ([]string, error) {
+	decrypter, err := st.secretsDecrypter(release)
+	if err != nil {
+		return nil, err
+	}
+
 	var generatedDecryptedFiles []interface{}
 
 	for _, v := range release.Secrets {
+		if decrypter != nil && !decrypter.ReadsFiles() {
+			name, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("release %q: the secrets of the %s backend must be the names of the secrets, but got %v", release.Name, release.SecretsBackend, v)
+			}
+
+			values, err := decrypter.Decrypt(name)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read secret %q with the %s backend: %v", name, release.SecretsBackend, err)
+			}
+
+			generatedDecryptedFiles = append(generatedDecryptedFiles, values)
+			continue
+		}
+
 		var (
 			paths []string
 			skip  bool
@@ -2870,7 +3777,12 @@ func (st *HelmState) generateSecretValuesFiles(helm helmexec.Interface, release
 				return nil, err
 			}
 
-			path, err := os.CreateTemp(os.TempDir(), "helmfile-embdedded-secrets-*.yaml.enc")
+			dir, err := st.tempFilesDir()
+			if err != nil {
+				return nil, err
+			}
+
+			path, err := os.CreateTemp(dir, "helmfile-embdedded-secrets-*.yaml.enc")
 			if err != nil {
 				return nil, err
 			}
@@ -2895,6 +3807,16 @@ func (st *HelmState) generateSecretValuesFiles(helm helmexec.Interface, release
 		}
 		path := paths[0]
 
+		if decrypter != nil {
+			values, err := decrypter.Decrypt(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decrypt secrets file \"%s\" with the %s backend: %v", path, release.SecretsBackend, err)
+			}
+
+			generatedDecryptedFiles = append(generatedDecryptedFiles, values)
+			continue
+		}
+
 		decryptFlags := st.appendConnectionFlags([]string{}, release)
 		valfile, err := helm.DecryptSecret(st.createHelmContext(release, workerIndex), path, decryptFlags...)
 		if err != nil {
@@ -2932,6 +3854,10 @@ func (st *HelmState) generateValuesFiles(helm helmexec.Interface, release *Relea
 }
 
 func (st *HelmState) namespaceAndValuesFlags(helm helmexec.Interface, release *ReleaseSpec, workerIndex int) ([]string, []string, error) {
+	if _, err := st.commandTimeout(release); err != nil {
+		return nil, nil, err
+	}
+
 	flags := []string{}
 	if release.Namespace != "" {
 		flags = append(flags, "--namespace", release.Namespace)
@@ -3070,6 +3996,20 @@ func (ar *AffectedReleases) DisplayAffectedReleases(logger *zap.SugaredLogger) {
 			logger.Info(release.Name)
 		}
 	}
+	if len(ar.Retried) > 0 {
+		logger.Info("\nRETRIED RELEASES AFTER WAIT TIMEOUT:")
+		logger.Info("NAME")
+		for _, release := range ar.Retried {
+			logger.Info(release.Name)
+		}
+	}
+	if len(ar.RolledBack) > 0 {
+		logger.Info("\nROLLED BACK RELEASES AFTER WAIT TIMEOUT:")
+		logger.Info("NAME")
+		for _, release := range ar.RolledBack {
+			logger.Info(release.Name)
+		}
+	}
 }
 
 func escape(value string) string {
@@ -3082,15 +4022,19 @@ func escape(value string) string {
 // future time
 func (p SubHelmfileSpec) MarshalYAML() (interface{}, error) {
 	type SubHelmfileSpecTmp struct {
-		Path               string        `yaml:"path,omitempty"`
-		Selectors          []string      `yaml:"selectors,omitempty"`
-		SelectorsInherited bool          `yaml:"selectorsInherited,omitempty"`
-		OverrideValues     []interface{} `yaml:"values,omitempty"`
+		Name               string            `yaml:"name,omitempty"`
+		Path               string            `yaml:"path,omitempty"`
+		Selectors          []string          `yaml:"selectors,omitempty"`
+		SelectorsInherited bool              `yaml:"selectorsInherited,omitempty"`
+		Labels             map[string]string `yaml:"labels,omitempty"`
+		OverrideValues     []interface{}     `yaml:"values,omitempty"`
 	}
 	return &SubHelmfileSpecTmp{
+		Name:               p.Name,
 		Path:               p.Path,
 		Selectors:          p.Selectors,
 		SelectorsInherited: p.SelectorsInherited,
+		Labels:             p.Labels,
 		OverrideValues:     p.Environment.OverrideValues,
 	}, nil
 }
@@ -3108,18 +4052,22 @@ func (hf *SubHelmfileSpec) UnmarshalYAML(unmarshal func(interface{}) error) erro
 		hf.Path = i
 	case map[interface{}]interface{}, map[string]interface{}: // helmfile path with sub section
 		var subHelmfileSpecTmp struct {
-			Path               string   `yaml:"path"`
-			Selectors          []string `yaml:"selectors"`
-			SelectorsInherited bool     `yaml:"selectorsInherited"`
+			Name               string            `yaml:"name"`
+			Path               string            `yaml:"path"`
+			Selectors          []string          `yaml:"selectors"`
+			SelectorsInherited bool              `yaml:"selectorsInherited"`
+			Labels             map[string]string `yaml:"labels"`
 
 			Environment SubhelmfileEnvironmentSpec `yaml:",inline"`
 		}
 		if err := unmarshal(&subHelmfileSpecTmp); err != nil {
 			return err
 		}
+		hf.Name = subHelmfileSpecTmp.Name
 		hf.Path = subHelmfileSpecTmp.Path
 		hf.Selectors = subHelmfileSpecTmp.Selectors
 		hf.SelectorsInherited = subHelmfileSpecTmp.SelectorsInherited
+		hf.Labels = subHelmfileSpecTmp.Labels
 		hf.Environment = subHelmfileSpecTmp.Environment
 	}
 	// since we cannot make sur the "console" string can be red after the "path" we must check we don't have
@@ -3355,13 +4303,33 @@ func (st *HelmState) Reverse() {
 	}
 }
 
-func (st *HelmState) getOCIChart(release *ReleaseSpec, tempDir string, helm helmexec.Interface) (*string, error) {
+// getOCIChart pulls the OCI chart of the release into the temporary directory, and returns the path to it,
+// or nil if the chart isn't an OCI chart.
+// When cacheDir is given, the charts pinned by digest are pulled into it instead, and reused on the later runs,
+// as the digest always identifies the same chart unlike the tags.
+func (st *HelmState) getOCIChart(release *ReleaseSpec, tempDir, cacheDir string, helm helmexec.Interface) (*string, error) {
+	_, digest, err := releaseChartDigest(release)
+	if err != nil {
+		return nil, err
+	}
+
 	qualifiedChartName, chartName, chartVersion := st.getOCIQualifiedChartName(release)
 
 	if qualifiedChartName == "" {
+		if digest != "" {
+			return nil, fmt.Errorf("digest %q is specified for the non-OCI chart %q: pinning by digest is supported only for OCI charts", digest, release.Chart)
+		}
 		return nil, nil
 	}
 
+	// The policy is resolved once from the chart of the release before the mirrors are applied,
+	// so that the chart pulled through a mirror is verified against the policy of its origin registry
+	policy := st.getCosignPolicy(release, st.ociOriginRef(release))
+
+	if cacheDir != "" && digest != "" {
+		return st.getCachedOCIChart(release, qualifiedChartName, digest, cacheDir, policy, helm)
+	}
+
 	pathElems := []string{
 		tempDir,
 	}
@@ -3374,54 +4342,200 @@ func (st *HelmState) getOCIChart(release *ReleaseSpec, tempDir string, helm helm
 		pathElems = append(pathElems, release.KubeContext)
 	}
 
+	if digest != "" {
+		chartVersion = digestPathElem(digest)
+	}
+
 	pathElems = append(pathElems, release.Name, chartName, chartVersion)
 
 	chartPath := path.Join(pathElems...)
 
-	err := helm.ChartPull(qualifiedChartName, chartPath)
+	if err := st.pullOCIChart(release, qualifiedChartName, chartPath, policy, helm); err != nil {
+		return nil, err
+	}
+
+	fullChartPath, err := findChartDirectory(chartPath)
 	if err != nil {
 		return nil, err
 	}
-	err = helm.ChartExport(qualifiedChartName, chartPath)
+
+	chartPath = filepath.Dir(fullChartPath)
+
+	return &chartPath, nil
+}
+
+// pullOCIChart pulls the OCI chart reference like `registry/app:1.0.0` into the chart path, trying the mirrors of the registry first.
+// When the policy is given, the chart from each one is resolved to its digest, verified by the digest, and pulled by the same digest,
+// so that the chart pulled is the one verified even when the tag is moved in between.
+func (st *HelmState) pullOCIChart(release *ReleaseSpec, qualifiedChartName, chartPath string, policy *CosignSpec, helm helmexec.Interface) error {
+	var err error
+	for _, c := range st.ociPullCandidates(qualifiedChartName) {
+		ref := c.ref
+		flags := release.overrideOCITransportFlags(c.flags)
+		if policy != nil {
+			if ref, err = pinOCIRef(st.runContext(), helm, c.ref, flags); err != nil {
+				st.logger.Warnf("failed resolving the digest of %s: %v", c.ref, err)
+				continue
+			}
+			if err = st.verifyOCIChart(policy, ref); err != nil {
+				st.logger.Warnf("%v", err)
+				continue
+			}
+		}
+		err = helm.ChartPull(st.runContext(), ref, chartPath, flags...)
+		if err == nil {
+			qualifiedChartName = ref
+			break
+		}
+		st.logger.Warnf("failed pulling %s: %v", ref, err)
+	}
 	if err != nil {
+		return err
+	}
+	return helm.ChartExport(st.runContext(), qualifiedChartName, chartPath)
+}
+
+// getCachedOCIChart returns the path to the OCI chart pinned by the digest in the cache directory,
+// pulling it into the cache first unless it's already there.
+// The signature of the cached chart is still verified if required, as the policy may have changed since it was cached.
+func (st *HelmState) getCachedOCIChart(release *ReleaseSpec, qualifiedChartName, digest, cacheDir string, policy *CosignSpec, helm helmexec.Interface) (*string, error) {
+	cachePath := ociCachePath(cacheDir, qualifiedChartName, digest)
+
+	if fullChartPath, err := findChartDirectory(cachePath); err == nil {
+		if policy != nil {
+			if err := st.verifyOCIChart(policy, qualifiedChartName); err != nil {
+				return nil, err
+			}
+		}
+
+		st.logger.Debugf("using the cached chart %s in %s", qualifiedChartName, cachePath)
+
+		chartPath := filepath.Dir(fullChartPath)
+		return &chartPath, nil
+	}
+
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
 		return nil, err
 	}
 
-	fullChartPath, err := findChartDirectory(chartPath)
+	// The chart is pulled into a temporary directory first, and then moved into the cache at once,
+	// so that the releases and the runs pulling the same chart concurrently never see a partially pulled chart
+	pullDir, err := os.MkdirTemp(cacheDir, ".pull-")
 	if err != nil {
 		return nil, err
 	}
+	defer func() {
+		_ = os.RemoveAll(pullDir)
+	}()
 
-	chartPath = filepath.Dir(fullChartPath)
+	if err := st.pullOCIChart(release, qualifiedChartName, pullDir, policy, helm); err != nil {
+		return nil, err
+	}
+
+	if _, err := findChartDirectory(pullDir); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err != nil {
+		return nil, err
+	}
+
+	// Another release or run may have cached the same chart meanwhile, which is used instead
+	if err := os.Rename(pullDir, cachePath); err != nil {
+		if _, statErr := os.Stat(cachePath); statErr != nil {
+			return nil, err
+		}
+	}
+
+	fullChartPath, err := findChartDirectory(cachePath)
+	if err != nil {
+		return nil, err
+	}
+
+	chartPath := filepath.Dir(fullChartPath)
 
 	return &chartPath, nil
 }
 
+// ociCachePath returns the directory the OCI chart pinned by the digest is cached in within the cache directory
+func ociCachePath(cacheDir, qualifiedChartName, digest string) string {
+	repo := strings.TrimSuffix(qualifiedChartName, "@"+digest)
+	return filepath.Join(cacheDir, strings.NewReplacer(":", "_", "/", "_").Replace(repo), digestPathElem(digest))
+}
+
+// digestPathElem returns the digest like `sha256:...` as the path element like `sha256-...`, as `:` isn't allowed in the paths on Windows
+func digestPathElem(digest string) string {
+	return strings.Replace(digest, ":", "-", 1)
+}
+
 func (st *HelmState) getOCIQualifiedChartName(release *ReleaseSpec) (qualifiedChartName, chartName, chartVersion string) {
+	return st.ociQualifiedChartName(release, st.mirrorOCIURL)
+}
+
+// ociOriginRef returns the OCI chart reference of the release without the repository mirrors applied, or an empty one for the other charts
+func (st *HelmState) ociOriginRef(release *ReleaseSpec) string {
+	ref, _, _ := st.ociQualifiedChartName(release, func(u string) string { return u })
+	return ref
+}
+
+// ociQualifiedChartName returns the OCI chart reference of the release, with the URL of the chart or the repository rewritten by mirror
+func (st *HelmState) ociQualifiedChartName(release *ReleaseSpec, mirror func(string) string) (qualifiedChartName, chartName, chartVersion string) {
+	chart, digest := splitChartDigest(release.Chart)
+	if digest == "" {
+		digest = release.Digest
+	}
+
 	chartVersion = "latest"
 	if release.Version != "" {
 		chartVersion = release.Version
 	}
 
-	if strings.HasPrefix(release.Chart, "oci://") {
-		split := strings.Split(release.Chart, "/")
+	// A chart pinned by digest is referenced as `registry/app@sha256:...` instead of `registry/app:tag`
+	ref := ":" + chartVersion
+	if digest != "" {
+		chartVersion = digest
+		ref = "@" + digest
+	}
+
+	if strings.HasPrefix(chart, "oci://") {
+		chart = mirror(chart)
+		split := strings.Split(chart, "/")
 		chartName = split[len(split)-1]
-		qualifiedChartName = strings.Replace(fmt.Sprintf("%s:%s", release.Chart, chartVersion), "oci://", "", 1)
+		qualifiedChartName = strings.Replace(chart+ref, "oci://", "", 1)
 	} else {
 		var repo *RepositorySpec
-		repo, chartName = st.GetRepositoryAndNameFromChartName(release.Chart)
+		repo, chartName = st.GetRepositoryAndNameFromChartName(chart)
 		if repo == nil {
 			return
 		}
 		if !repo.OCI {
 			return
 		}
-		qualifiedChartName = fmt.Sprintf("%s/%s:%s", repo.URL, chartName, chartVersion)
+		qualifiedChartName = fmt.Sprintf("%s/%s%s", mirror(repo.URL), chartName, ref)
 	}
 	return
 }
 
+// releaseChartDigest returns the chart without the digest and the digest the release is pinned to, if any.
+// It fails when the digest in the chart and the `digest` field disagree.
+func releaseChartDigest(release *ReleaseSpec) (string, string, error) {
+	chart, digest := splitChartDigest(release.Chart)
+
+	if release.Digest != "" {
+		if digest != "" && digest != release.Digest {
+			return "", "", fmt.Errorf("the digest %q in the chart %q conflicts with the digest %q", digest, release.Chart, release.Digest)
+		}
+		digest = release.Digest
+	}
+
+	return chart, digest, nil
+}
+
 func (st *HelmState) FullFilePath() (string, error) {
+	if filepath.IsAbs(st.basePath) {
+		return filepath.Join(st.basePath, st.FilePath), nil
+	}
+
 	var wd string
 	var err error
 	if st.fs != nil {
@@ -3429,3 +4543,11 @@ func (st *HelmState) FullFilePath() (string, error) {
 	}
 	return filepath.Join(wd, st.basePath, st.FilePath), err
 }
+
+// Rebase makes the directory the relative paths in the state are resolved against absolute by joining it to the directory,
+// which is the working directory the state is loaded in, so that the state can be processed in other working directories
+func (st *HelmState) Rebase(dir string) {
+	if !filepath.IsAbs(st.basePath) {
+		st.basePath = filepath.Join(dir, st.basePath)
+	}
+}
@@ -20,13 +20,30 @@ func (st *HelmState) Values() map[string]interface{} {
 	return st.RenderedValues
 }
 
+// releaseTemplateKubeContext returns the kube context exposed as `.KubeContext` to the templates of the release
+func (st *HelmState) releaseTemplateKubeContext(release *ReleaseSpec) string {
+	if st.OverrideKubeContext != "" {
+		return st.OverrideKubeContext
+	}
+	return release.KubeContext
+}
+
+// releaseTemplateNamespace returns the namespace exposed as `.Namespace` to the templates of the release
+func (st *HelmState) releaseTemplateNamespace(release *ReleaseSpec) string {
+	if st.OverrideNamespace != "" {
+		return st.OverrideNamespace
+	}
+	return release.Namespace
+}
+
 func (st *HelmState) createReleaseTemplateData(release *ReleaseSpec, vals map[string]interface{}) releaseTemplateData {
 	tmplData := releaseTemplateData{
 		Environment: st.Env,
-		KubeContext: st.OverrideKubeContext,
-		Namespace:   st.OverrideNamespace,
+		KubeContext: st.releaseTemplateKubeContext(release),
+		Namespace:   st.releaseTemplateNamespace(release),
 		Chart:       st.OverrideChart,
 		Values:      vals,
+		Files:       tmpl.NewFiles(st.fs, st.basePath),
 		Release: releaseTemplateDataRelease{
 			Name:        release.Name,
 			Chart:       release.Chart,
@@ -112,7 +129,7 @@ func (st *HelmState) ExecuteTemplates() (*HelmState, error) {
 		successFlag := false
 		for it, prev := 0, release; it < 6; it++ {
 			tmplData := st.createReleaseTemplateData(prev, vals)
-			renderer := tmpl.NewFileRenderer(st.fs, st.basePath, tmplData)
+			renderer := st.newFileRenderer(st.basePath, tmplData)
 			r, err := release.ExecuteTemplateExpressions(renderer)
 			if err != nil {
 				return nil, fmt.Errorf("failed executing templates in release \"%s\".\"%s\": %v", st.FilePath, release.Name, err)
@@ -137,6 +154,10 @@ func (st *HelmState) ExecuteTemplates() (*HelmState, error) {
 		}
 	}
 
+	if err := expandNeedsSelectors(st.Releases); err != nil {
+		return nil, fmt.Errorf("failed expanding needs in %q: %v", st.FilePath, err)
+	}
+
 	return &r, nil
 }
 
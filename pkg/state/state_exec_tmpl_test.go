@@ -105,6 +105,31 @@ func TestHelmState_executeTemplates(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "Has release context in set-values",
+			input: ReleaseSpec{
+				Chart:       "test-charts/chart",
+				Name:        "test-app",
+				Namespace:   "dev",
+				KubeContext: "dev-cluster",
+				SetValuesTemplate: []SetValue{
+					{Name: "namespace", Value: "{{ .Namespace }}"},
+					{Name: "kubeContext", Value: "{{ .KubeContext }}"},
+					{Name: "release", Value: "{{ .Release.Namespace }}/{{ .Release.Name }}@{{ .Release.KubeContext }}"},
+				},
+			},
+			want: ReleaseSpec{
+				Chart:       "test-charts/chart",
+				Name:        "test-app",
+				Namespace:   "dev",
+				KubeContext: "dev-cluster",
+				SetValues: []SetValue{
+					{Name: "namespace", Value: "dev"},
+					{Name: "kubeContext", Value: "dev-cluster"},
+					{Name: "release", Value: "dev/test-app@dev-cluster"},
+				},
+			},
+		},
 		{
 			name: "Has template in values (map)",
 			input: ReleaseSpec{
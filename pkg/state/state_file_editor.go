@@ -0,0 +1,207 @@
+package state
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// stateFileEditor edits the values of the releases in a state file in place.
+// The values are located with the YAML nodes of the file, but replaced in the original content,
+// so that the rest of the file is kept as is, including the quotes, the comments and the indentation.
+type stateFileEditor struct {
+	content []byte
+	// lines is the offsets of the lines in the content
+	lines []int
+	// releases is the releases in all the documents of the file
+	releases []*yamlv3.Node
+	edits    []stateFileEdit
+}
+
+// stateFileEdit replaces content[start:end] with text
+type stateFileEdit struct {
+	start, end int
+	text       string
+}
+
+// newStateFileEditor parses the state file content.
+// It fails when the content isn't YAML, like when it is a template.
+func newStateFileEditor(content []byte) (*stateFileEditor, error) {
+	e := &stateFileEditor{content: content, lines: []int{0}}
+
+	for i, b := range content {
+		if b == '\n' {
+			e.lines = append(e.lines, i+1)
+		}
+	}
+
+	dec := yamlv3.NewDecoder(bytes.NewReader(content))
+	for {
+		var doc yamlv3.Node
+		if err := dec.Decode(&doc); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		if len(doc.Content) == 0 {
+			continue
+		}
+
+		_, releases := yamlMappingEntry(doc.Content[0], "releases")
+		if releases == nil || releases.Kind != yamlv3.SequenceNode {
+			continue
+		}
+
+		for _, r := range releases.Content {
+			if r.Kind == yamlv3.MappingNode {
+				e.releases = append(e.releases, r)
+			}
+		}
+	}
+
+	return e, nil
+}
+
+// release returns the release of the name in the namespace.
+// A release without `namespace` in the file matches any namespace, as its namespace can be given by `--namespace`,
+// but the one with the namespace takes precedence.
+// It returns nil when no release or more than one release matches.
+func (e *stateFileEditor) release(name, namespace string) *yamlv3.Node {
+	var named, unnamespaced []*yamlv3.Node
+
+	for _, r := range e.releases {
+		if _, n := yamlMappingEntry(r, "name"); n == nil || n.Kind != yamlv3.ScalarNode || n.Value != name {
+			continue
+		}
+
+		named = append(named, r)
+
+		_, ns := yamlMappingEntry(r, "namespace")
+		if ns == nil {
+			unnamespaced = append(unnamespaced, r)
+		} else if ns.Kind == yamlv3.ScalarNode && ns.Value == namespace {
+			return r
+		}
+	}
+
+	if len(unnamespaced) == 1 {
+		return unnamespaced[0]
+	}
+	if len(named) == 1 {
+		return named[0]
+	}
+
+	return nil
+}
+
+// set replaces the value `from` of the key of the release with `to`.
+// It returns false when the value isn't `from`, or when it isn't a single-line scalar, like a template.
+func (e *stateFileEditor) set(release *yamlv3.Node, key, from, to string) bool {
+	_, v := yamlMappingEntry(release, key)
+	if v == nil || v.Kind != yamlv3.ScalarNode || v.Value != from {
+		return false
+	}
+
+	start, ok := e.offset(v.Line, v.Column)
+	if !ok {
+		return false
+	}
+	if v.Style&(yamlv3.DoubleQuotedStyle|yamlv3.SingleQuotedStyle) != 0 {
+		start++
+	}
+
+	end := start + len(from)
+	if end > len(e.content) || string(e.content[start:end]) != from {
+		return false
+	}
+
+	e.edits = append(e.edits, stateFileEdit{start: start, end: end, text: to})
+
+	return true
+}
+
+// insertAfter adds the key with the value to the release, on the line next to the key `after`.
+// It returns false when the release is in the flow style, or the value of `after` spans lines.
+func (e *stateFileEditor) insertAfter(release *yamlv3.Node, after, key, value string) bool {
+	k, v := yamlMappingEntry(release, after)
+	if k == nil || release.Style&yamlv3.FlowStyle != 0 || v.Line != k.Line {
+		return false
+	}
+
+	if _, ok := e.offset(k.Line, k.Column); !ok {
+		return false
+	}
+
+	line := strings.Repeat(" ", k.Column-1) + key + ": " + value
+
+	var pos int
+	if k.Line < len(e.lines) {
+		pos = e.lines[k.Line]
+		nl := "\n"
+		if pos >= 2 && e.content[pos-2] == '\r' {
+			nl = "\r\n"
+		}
+		line += nl
+	} else {
+		pos = len(e.content)
+		line = "\n" + line
+	}
+
+	e.edits = append(e.edits, stateFileEdit{start: pos, end: pos, text: line})
+
+	return true
+}
+
+// offset returns the offset in the content of the 1-based line and column of a node
+func (e *stateFileEditor) offset(line, column int) (int, bool) {
+	if line < 1 || line > len(e.lines) {
+		return 0, false
+	}
+
+	pos := e.lines[line-1]
+	for c := 1; c < column; c++ {
+		if pos >= len(e.content) || e.content[pos] == '\n' {
+			return 0, false
+		}
+		_, size := utf8.DecodeRune(e.content[pos:])
+		pos += size
+	}
+
+	return pos, true
+}
+
+// bytes returns the content with the edits applied
+func (e *stateFileEditor) bytes() []byte {
+	edits := append([]stateFileEdit{}, e.edits...)
+	sort.SliceStable(edits, func(i, j int) bool {
+		return edits[i].start > edits[j].start
+	})
+
+	out := append([]byte{}, e.content...)
+	for _, ed := range edits {
+		out = append(out[:ed.start], append([]byte(ed.text), out[ed.end:]...)...)
+	}
+
+	return out
+}
+
+// yamlMappingEntry returns the key and the value nodes of the key in the mapping node, or nils if it isn't found
+func yamlMappingEntry(m *yamlv3.Node, key string) (*yamlv3.Node, *yamlv3.Node) {
+	if m == nil || m.Kind != yamlv3.MappingNode {
+		return nil, nil
+	}
+
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i], m.Content[i+1]
+		}
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,96 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStateFileEditor_Set(t *testing.T) {
+	in := `releases:
+- name: web
+  chart: stable/web
+  version: "1.2.3" # pinned
+  values:
+  - version: 1.2.3
+- chart: stable/db
+  version: 1.2.3
+  name: db
+- name: other
+  version: 1.2.3
+`
+
+	e, err := newStateFileEditor([]byte(in))
+	require.NoError(t, err)
+
+	require.True(t, e.set(e.release("web", ""), "version", "1.2.3", "1.2.5"))
+	require.True(t, e.set(e.release("db", ""), "version", "1.2.3", "1.3.0"))
+	require.False(t, e.set(e.release("web", ""), "version", "1.0.0", "1.2.5"))
+	require.False(t, e.set(e.release("missing", ""), "version", "1.2.3", "1.2.5"))
+
+	require.Equal(t, `releases:
+- name: web
+  chart: stable/web
+  version: "1.2.5" # pinned
+  values:
+  - version: 1.2.3
+- chart: stable/db
+  version: 1.3.0
+  name: db
+- name: other
+  version: 1.2.3
+`, string(e.bytes()))
+}
+
+func TestStateFileEditor_Release(t *testing.T) {
+	in := `releases:
+- name: web
+  namespace: staging
+  version: 1.2.3
+- name: web
+  namespace: production
+  version: 1.2.3
+---
+releases:
+- name: api
+  version: 0.1.0
+- name: api
+  namespace: production
+  version: 0.1.0
+- name: db
+  namespace: production
+  version: 1.0.0
+`
+
+	e, err := newStateFileEditor([]byte(in))
+	require.NoError(t, err)
+
+	require.True(t, e.set(e.release("web", "production"), "version", "1.2.3", "1.3.0"))
+	require.True(t, e.set(e.release("api", "staging"), "version", "0.1.0", "0.2.0"))
+	require.True(t, e.set(e.release("db", "override"), "version", "1.0.0", "1.1.0"))
+	require.Nil(t, e.release("web", "override"), "the release is ambiguous")
+
+	require.Equal(t, `releases:
+- name: web
+  namespace: staging
+  version: 1.2.3
+- name: web
+  namespace: production
+  version: 1.3.0
+---
+releases:
+- name: api
+  version: 0.2.0
+- name: api
+  namespace: production
+  version: 0.1.0
+- name: db
+  namespace: production
+  version: 1.1.0
+`, string(e.bytes()))
+}
+
+func TestNewStateFileEditor_Template(t *testing.T) {
+	_, err := newStateFileEditor([]byte("releases:\n{{ range .Values.apps }}\n- name: {{ . }}\n{{ end }}\n"))
+	require.Error(t, err)
+}
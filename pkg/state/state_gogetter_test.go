@@ -4,10 +4,13 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/require"
 
+	"github.com/helmfile/helmfile/pkg/envvar"
 	"github.com/helmfile/helmfile/pkg/filesystem"
 	"github.com/helmfile/helmfile/pkg/helmexec"
 )
@@ -63,3 +66,27 @@ func TestGoGetter(t *testing.T) {
 		})
 	}
 }
+
+func TestDownloadChartWithGoGetter_Subdirectory(t *testing.T) {
+	cacheHome := t.TempDir()
+	t.Setenv(envvar.CacheHome, cacheHome)
+
+	// The chart in the subdirectory of the git repository fetched by a previous run
+	chartDir := filepath.Join(cacheHome, "app", "web", "https_github_com_org_repo.ref=v1.2.3", "charts", "app")
+	require.NoError(t, os.MkdirAll(chartDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "Chart.yaml"), []byte("name: app\nversion: 1.2.3\n"), 0644))
+
+	st := &HelmState{
+		logger:   helmexec.NewLogger(io.Discard, "warn"),
+		fs:       filesystem.DefaultFileSystem(),
+		basePath: t.TempDir(),
+	}
+
+	out, err := st.downloadChartWithGoGetter(&ReleaseSpec{
+		Name:      "web",
+		Namespace: "app",
+		Chart:     "git::https://github.com/org/repo//charts/app?ref=v1.2.3",
+	})
+	require.NoError(t, err)
+	require.Equal(t, chartDir, out)
+}
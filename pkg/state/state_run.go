@@ -112,6 +112,51 @@ func (st *HelmState) PlanReleases(opts PlanOptions) ([][]Release, error) {
 	return groups, nil
 }
 
+// ReleasesNeeding returns the releases of the state that need any of the releases directly or transitively,
+// other than the releases themselves, in the order they are defined.
+// Deleting them first keeps any release from losing the ones it needs while it is still installed.
+func (st *HelmState) ReleasesNeeding(releases []ReleaseSpec) []ReleaseSpec {
+	needed := map[string]bool{}
+	for _, r := range releases {
+		release := r
+		needed[ReleaseToID(&release)] = true
+	}
+
+	included := map[string]bool{}
+
+	var dependents []ReleaseSpec
+
+	for found := true; found; {
+		found = false
+
+		for _, r := range st.Releases {
+			release := r
+			id := ReleaseToID(&release)
+			if needed[id] {
+				continue
+			}
+
+			for _, n := range release.Needs {
+				if needed[n] {
+					needed[id] = true
+					included[id] = true
+					found = true
+					break
+				}
+			}
+		}
+	}
+
+	for _, r := range st.Releases {
+		release := r
+		if included[ReleaseToID(&release)] {
+			dependents = append(dependents, release)
+		}
+	}
+
+	return dependents
+}
+
 func SortedReleaseGroups(releases []Release, opts PlanOptions) ([][]Release, error) {
 	reverse := opts.Reverse
 
@@ -132,6 +177,7 @@ func SortedReleaseGroups(releases []Release, opts PlanOptions) ([][]Release, err
 func GroupReleasesByDependency(releases []Release, opts PlanOptions) ([][]Release, error) {
 	idToReleases := map[string][]Release{}
 	idToIndex := map[string]int{}
+	idToPriority := map[string]int{}
 
 	d := dag.New()
 	for i, r := range releases {
@@ -139,10 +185,15 @@ func GroupReleasesByDependency(releases []Release, opts PlanOptions) ([][]Releas
 
 		idToReleases[id] = append(idToReleases[id], r)
 		idToIndex[id] = i
+		idToPriority[id] = r.Priority
 
 		var needs []string
 		for i := 0; i < len(r.Needs); i++ {
 			n := r.Needs[i]
+			// The releases of the other helmfiles are processed before the state, rather than being part of its DAG
+			if _, ok := ParseExternalNeed(n); ok {
+				continue
+			}
 			needs = append(needs, n)
 		}
 		d.Add(id, dag.Dependencies(needs))
@@ -241,7 +292,13 @@ func GroupReleasesByDependency(releases []Release, opts PlanOptions) ([][]Releas
 		// Make the helmfile behavior deterministic for reproducibility and ease of testing
 		// We try to keep the order of definitions to keep backward-compatibility
 		// See https://github.com/roboll/helmfile/issues/988
+		// The releases of higher priorities come first, or last when reversed, like when deleting them.
 		sort.Slice(idsInGroup, func(i, j int) bool {
+			pi := idToPriority[idsInGroup[i]]
+			pj := idToPriority[idsInGroup[j]]
+			if pi != pj {
+				return (pi > pj) != opts.Reverse
+			}
 			ii := idToIndex[idsInGroup[i]]
 			ij := idToIndex[idsInGroup[j]]
 			return ii < ij
@@ -260,3 +317,19 @@ func GroupReleasesByDependency(releases []Release, opts PlanOptions) ([][]Releas
 
 	return result, nil
 }
+
+// releaseOrder returns the function returning the position of the release in the releases of the state,
+// or the number of the releases if it isn't one of them
+func (st *HelmState) releaseOrder() func(*ReleaseSpec) int {
+	positions := make(map[*ReleaseSpec]int, len(st.Releases))
+	for i := range st.Releases {
+		positions[&st.Releases[i]] = i
+	}
+
+	return func(r *ReleaseSpec) int {
+		if i, ok := positions[r]; ok {
+			return i
+		}
+		return len(st.Releases)
+	}
+}
@@ -0,0 +1,44 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupReleasesByDependency_Priority(t *testing.T) {
+	releases := []Release{
+		{ReleaseSpec: ReleaseSpec{Name: "logging"}},
+		{ReleaseSpec: ReleaseSpec{Name: "ingress", Priority: 10}},
+		{ReleaseSpec: ReleaseSpec{Name: "app", Needs: []string{"db"}, Priority: 100}},
+		{ReleaseSpec: ReleaseSpec{Name: "db"}},
+		{ReleaseSpec: ReleaseSpec{Name: "monitoring", Priority: -1}},
+		{ReleaseSpec: ReleaseSpec{Name: "dns", Priority: 10}},
+	}
+
+	names := func(groups [][]Release) [][]string {
+		var names [][]string
+		for _, g := range groups {
+			var ns []string
+			for _, r := range g {
+				ns = append(ns, r.Name)
+			}
+			names = append(names, ns)
+		}
+		return names
+	}
+
+	groups, err := SortedReleaseGroups(releases, PlanOptions{})
+	require.NoError(t, err)
+	require.Equal(t, [][]string{
+		{"ingress", "dns", "logging", "db", "monitoring"},
+		{"app"},
+	}, names(groups))
+
+	groups, err = SortedReleaseGroups(releases, PlanOptions{Reverse: true})
+	require.NoError(t, err)
+	require.Equal(t, [][]string{
+		{"app"},
+		{"monitoring", "logging", "db", "ingress", "dns"},
+	}, names(groups))
+}
@@ -1,6 +1,8 @@
 package state
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -13,6 +15,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/helmfile/helmfile/pkg/environment"
+	"github.com/helmfile/helmfile/pkg/event"
 	"github.com/helmfile/helmfile/pkg/exectest"
 	"github.com/helmfile/helmfile/pkg/filesystem"
 	"github.com/helmfile/helmfile/pkg/helmexec"
@@ -729,6 +732,39 @@ func TestHelmState_flagsForUpgrade(t *testing.T) {
 	}
 }
 
+func TestHelmState_createHelmContext(t *testing.T) {
+	zero := 0
+	five := 5
+	twenty := 20
+
+	tests := []struct {
+		name     string
+		defaults *int
+		release  *int
+		want     int
+	}{
+		{name: "default", want: 10},
+		{name: "from-default", defaults: &twenty, want: 20},
+		{name: "release", release: &five, want: 5},
+		{name: "release-overrides-default", defaults: &twenty, release: &five, want: 5},
+		{name: "release-unlimited", defaults: &twenty, release: &zero, want: 0},
+	}
+
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			st := &HelmState{
+				ReleaseSetSpec: ReleaseSetSpec{
+					HelmDefaults: HelmSpec{HistoryMax: tt.defaults},
+				},
+			}
+
+			ctx := st.createHelmContext(&ReleaseSpec{Name: "foo", HistoryMax: tt.release}, 0)
+			require.Equal(t, tt.want, ctx.HistoryMax)
+		})
+	}
+}
+
 func TestHelmState_flagsForTemplate(t *testing.T) {
 	enable := true
 	disable := false
@@ -1779,6 +1815,113 @@ func TestHelmState_DiffReleases(t *testing.T) {
 	}
 }
 
+func TestHelmState_DiffReleasesOutputDir(t *testing.T) {
+	dir := t.TempDir()
+
+	state := &HelmState{
+		ReleaseSetSpec: ReleaseSetSpec{
+			Releases: []ReleaseSpec{
+				{Name: "foo", Namespace: "default", Chart: "foo"},
+				{Name: "bar", Namespace: "monitoring", Chart: "bar"},
+			},
+		},
+		logger:         logger,
+		valsRuntime:    valsRuntime,
+		RenderedValues: map[string]interface{}{},
+	}
+
+	_, errs := state.DiffReleases(&exectest.Helm{}, []string{}, 1, false, false, []string{}, false, false, false, false, false, &DiffOpts{
+		Stdout:             io.Discard,
+		OutputDir:          dir,
+		OutputFileTemplate: "{{ .Release.Namespace }}/{{ .Release.Name }}.diff",
+	})
+	require.Empty(t, errs)
+
+	for _, f := range []string{"default/foo.diff", "monitoring/bar.diff"} {
+		_, err := os.Stat(filepath.Join(dir, f))
+		require.NoError(t, err)
+	}
+}
+
+func TestWriteDiffOutput(t *testing.T) {
+	dir := t.TempDir()
+
+	require.NoError(t, writeDiffOutput(dir, &ReleaseSpec{Name: "foo"}, "", []byte("default, foo, Deployment (apps) has changed:\n")))
+
+	bs, err := os.ReadFile(filepath.Join(dir, "foo.diff"))
+	require.NoError(t, err)
+	require.Equal(t, "default, foo, Deployment (apps) has changed:\n", string(bs))
+
+	require.ErrorContains(t, writeDiffOutput(dir, &ReleaseSpec{Name: "foo"}, "{{ .Release.Missing }}", nil), "can't evaluate field Missing")
+}
+
+func TestHelmState_TemplateReleasesShowOnly(t *testing.T) {
+	state := &HelmState{
+		ReleaseSetSpec: ReleaseSetSpec{
+			Releases: []ReleaseSpec{
+				{Name: "frontend", Chart: "foo"},
+				{Name: "backend", Chart: "bar"},
+			},
+		},
+		logger:         logger,
+		valsRuntime:    valsRuntime,
+		RenderedValues: map[string]interface{}{},
+	}
+
+	helm := &exectest.Helm{}
+
+	errs := state.TemplateReleases(helm, "", []string{}, []string{}, 1, false, &TemplateOpts{
+		ShowOnly: []string{"backend:templates/deployment.yaml", "backend:templates/service.yaml"},
+	})
+	require.Empty(t, errs)
+	require.Equal(t, []exectest.Release{
+		{Name: "backend", Flags: []string{"--show-only", "templates/deployment.yaml", "--show-only", "templates/service.yaml"}},
+	}, helm.Templated)
+}
+
+func TestShowOnlyFor(t *testing.T) {
+	release := &ReleaseSpec{Name: "frontend"}
+
+	require.Nil(t, showOnlyFor(release, nil))
+	require.Equal(t, []string{"templates/configmap.yaml", "templates/deployment.yaml"},
+		showOnlyFor(release, []string{"templates/configmap.yaml", "frontend:templates/deployment.yaml", "backend:templates/service.yaml"}))
+	require.Nil(t, showOnlyFor(release, []string{"backend:templates/service.yaml"}))
+}
+
+func TestAnnotateManifests(t *testing.T) {
+	st := &HelmState{FilePath: "helmfile.yaml"}
+	release := &ReleaseSpec{Name: "myapp", Namespace: "default", Chart: "stable/myapp"}
+
+	out := `---
+# Source: myapp/templates/service.yaml
+apiVersion: v1
+kind: Service
+---
+# Source: myapp/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+`
+
+	want := `---
+# Helmfile-State: helmfile.yaml
+# Helmfile-Release: default/myapp
+# Helmfile-Chart: stable/myapp
+# Source: myapp/templates/service.yaml
+apiVersion: v1
+kind: Service
+---
+# Helmfile-State: helmfile.yaml
+# Helmfile-Release: default/myapp
+# Helmfile-Chart: stable/myapp
+# Source: myapp/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+`
+
+	require.Equal(t, want, string(annotateManifests([]byte(out), st.sourceAnnotations(release))))
+	require.Empty(t, annotateManifests(nil, st.sourceAnnotations(release)))
+}
+
 func TestHelmState_DiffFlags(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -2351,6 +2494,56 @@ func TestHelmState_TestReleasesNoCleanUp(t *testing.T) {
 	}
 }
 
+func TestHelmState_TriggerRunEvents(t *testing.T) {
+	var buf bytes.Buffer
+
+	st := &HelmState{
+		ReleaseSetSpec: ReleaseSetSpec{
+			Hooks: []event.Hook{
+				{
+					Name:     "report",
+					Events:   []string{"postrun"},
+					Command:  "echo",
+					Args:     []string{"releases={{ len .Run.Releases }}"},
+					ShowLogs: true,
+				},
+				{
+					Name:     "preflight",
+					Events:   []string{"preapply", "postapply"},
+					Command:  "echo",
+					Args:     []string{"{{ .Event.Name }}", "{{ .HelmfileCommand }}"},
+					ShowLogs: true,
+				},
+			},
+		},
+		logger: helmexec.NewLogger(&buf, "info"),
+		fs:     filesystem.DefaultFileSystem(),
+	}
+
+	summary := struct{ Releases []string }{Releases: []string{"default/foo", "default/bar"}}
+
+	executed, err := st.TriggerPrerunEvent("sync")
+	require.NoError(t, err)
+	require.False(t, executed)
+
+	executed, err = st.TriggerPostrunEvent("sync", nil, summary)
+	require.NoError(t, err)
+	require.True(t, executed)
+	require.Contains(t, buf.String(), "hook[postrun] logs | releases=2")
+	require.NotContains(t, buf.String(), "hook[preapply]")
+	require.NotContains(t, buf.String(), "hook[postapply]")
+
+	executed, err = st.TriggerPrerunEvent("apply")
+	require.NoError(t, err)
+	require.True(t, executed)
+	require.Contains(t, buf.String(), "hook[preapply] logs | preapply apply")
+
+	executed, err = st.TriggerPostrunEvent("apply", nil, summary)
+	require.NoError(t, err)
+	require.True(t, executed)
+	require.Contains(t, buf.String(), "hook[postapply] logs | postapply apply")
+}
+
 func TestHelmState_NoReleaseMatched(t *testing.T) {
 	releases := []ReleaseSpec{
 		{
@@ -2757,6 +2950,71 @@ func TestPrepareSyncReleases(t *testing.T) {
 	}
 }
 
+func TestPrepareSyncReleases_Overrides(t *testing.T) {
+	enabled, disabled, timeout := true, false, 600
+
+	tests := []struct {
+		name        string
+		release     ReleaseSpec
+		syncOptions *SyncOpts
+		flags       []string
+	}{
+		{
+			name:        "helmDefaults",
+			release:     ReleaseSpec{Name: "foo"},
+			syncOptions: &SyncOpts{},
+			flags:       []string{"--timeout", "300s", "--atomic", "--reset-values"},
+		},
+		{
+			name:        "release overriding helmDefaults",
+			release:     ReleaseSpec{Name: "foo", Atomic: &disabled, CleanupOnFail: &enabled, Timeout: &timeout},
+			syncOptions: &SyncOpts{},
+			flags:       []string{"--timeout", "600s", "--cleanup-on-fail", "--reset-values"},
+		},
+		{
+			name:        "flags overriding release",
+			release:     ReleaseSpec{Name: "foo", Atomic: &disabled, CleanupOnFail: &enabled, Timeout: &timeout},
+			syncOptions: &SyncOpts{Atomic: &enabled, CleanupOnFail: &disabled, Timeout: func() *int { v := 60; return &v }()},
+			flags:       []string{"--timeout", "60s", "--atomic", "--reset-values"},
+		},
+		{
+			name:        "flags turning waiting on",
+			release:     ReleaseSpec{Name: "foo", Wait: &disabled},
+			syncOptions: &SyncOpts{Wait: &enabled, WaitForJobs: &enabled},
+			flags:       []string{"--wait", "--wait-for-jobs", "--timeout", "300s", "--atomic", "--reset-values"},
+		},
+		{
+			name:        "flags turning waiting off",
+			release:     ReleaseSpec{Name: "foo", Wait: &enabled, WaitForJobs: &enabled},
+			syncOptions: &SyncOpts{Wait: &disabled, WaitForJobs: &disabled},
+			flags:       []string{"--timeout", "300s", "--atomic", "--reset-values"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := &HelmState{
+				ReleaseSetSpec: ReleaseSetSpec{
+					Releases:     []ReleaseSpec{tt.release},
+					HelmDefaults: HelmSpec{Atomic: true, Timeout: 300},
+				},
+				logger:      logger,
+				valsRuntime: valsRuntime,
+			}
+			helm := &exectest.Helm{
+				Lists: map[exectest.ListKey]string{},
+				Helm3: true,
+			}
+
+			results, es := state.prepareSyncReleases(helm, []string{}, 1, tt.syncOptions)
+
+			require.Len(t, es, 0)
+			require.Len(t, results, 1)
+			require.Equal(t, tt.flags, results[0].flags)
+		})
+	}
+}
+
 func TestReverse(t *testing.T) {
 	num := 8
 	st := &HelmState{}
@@ -3013,6 +3271,38 @@ func TestGetOCIQualifiedChartName(t *testing.T) {
 				{"registry/chart-path/chart-name:0.1.2", "chart-name", "0.1.2"},
 			},
 		},
+		{
+			state: HelmState{
+				ReleaseSetSpec: ReleaseSetSpec{
+					Repositories: []RepositorySpec{
+						{
+							Name: "oci-repo",
+							URL:  "registry/chart-path",
+							OCI:  true,
+						},
+					},
+					Releases: []ReleaseSpec{
+						{
+							Chart:   "oci://registry/chart-path/chart-name@sha256:0123abcd",
+							Version: "0.1.2",
+						},
+						{
+							Chart:   "oci-repo/chart-name",
+							Version: "0.1.2",
+							Digest:  "sha256:0123abcd",
+						},
+					},
+				},
+			},
+			expected: []struct {
+				qualifiedChartName string
+				chartName          string
+				chartVersion       string
+			}{
+				{"registry/chart-path/chart-name@sha256:0123abcd", "chart-name", "sha256:0123abcd"},
+				{"registry/chart-path/chart-name@sha256:0123abcd", "chart-name", "sha256:0123abcd"},
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -3027,6 +3317,53 @@ func TestGetOCIQualifiedChartName(t *testing.T) {
 	}
 }
 
+// pullingHelm untars a chart on pulling it like helm does
+type pullingHelm struct {
+	*exectest.Helm
+	pulled []string
+}
+
+func (helm *pullingHelm) ChartPull(ctx context.Context, chart string, path string, flags ...string) error {
+	helm.pulled = append(helm.pulled, chart)
+	if err := os.MkdirAll(filepath.Join(path, "app"), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(path, "app", "Chart.yaml"), []byte("name: app\n"), 0644)
+}
+
+func TestGetOCIChart_Cache(t *testing.T) {
+	st := &HelmState{logger: logger}
+	helm := &pullingHelm{Helm: &exectest.Helm{}}
+	cacheDir := t.TempDir()
+
+	pinned := &ReleaseSpec{Name: "app", Chart: "oci://registry.example.com/app", Version: "1.0.0", Digest: "sha256:0123abcd"}
+
+	for i := 0; i < 2; i++ {
+		chartPath, err := st.getOCIChart(pinned, t.TempDir(), cacheDir, helm)
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(cacheDir, "registry.example.com_app", "sha256-0123abcd", "app"), *chartPath)
+	}
+	require.Equal(t, []string{"registry.example.com/app@sha256:0123abcd"}, helm.pulled, "the pinned chart is pulled only once")
+
+	// The charts not pinned by digest are pulled every time, as their tags may point to another chart later
+	unpinned := &ReleaseSpec{Name: "app", Chart: "oci://registry.example.com/app", Version: "1.0.0"}
+	for i := 0; i < 2; i++ {
+		_, err := st.getOCIChart(unpinned, t.TempDir(), cacheDir, helm)
+		require.NoError(t, err)
+	}
+	require.Len(t, helm.pulled, 3)
+
+	entries, err := os.ReadDir(cacheDir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "no temporary directory is left in the cache")
+
+	// Without the cache, the pinned chart is pulled into the temporary directory named after the digest
+	tempDir := t.TempDir()
+	chartPath, err := st.getOCIChart(pinned, tempDir, "", helm)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(tempDir, "app", "app", "sha256-0123abcd", "app"), *chartPath)
+}
+
 func TestGenerateChartPath(t *testing.T) {
 	tests := []struct {
 		testName          string
@@ -0,0 +1,160 @@
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/helmfile/helmfile/pkg/helmexec"
+)
+
+// The health of the releases, summarized from their statuses
+const (
+	// ReleaseHealthHealthy is the health of the releases deployed successfully
+	ReleaseHealthHealthy = "healthy"
+	// ReleaseHealthUnhealthy is the health of the releases whose last deployment failed
+	ReleaseHealthUnhealthy = "unhealthy"
+	// ReleaseHealthPending is the health of the releases being installed, upgraded, rolled back or uninstalled
+	ReleaseHealthPending = "pending"
+	// ReleaseHealthMissing is the health of the releases not installed, or uninstalled
+	ReleaseHealthMissing = "missing"
+	// ReleaseHealthUnknown is the health of the releases in the other statuses
+	ReleaseHealthUnknown = "unknown"
+)
+
+// releaseStatusNotInstalled is the status of the releases not found in the cluster
+const releaseStatusNotInstalled = "not-installed"
+
+// ReleaseStatusSummary is the status of the deployed revision of a release
+type ReleaseStatusSummary struct {
+	// ID is the ID of the release, like `default/web` or `prod/default/web`
+	ID          string `json:"id"`
+	Release     string `json:"release"`
+	Namespace   string `json:"namespace,omitempty"`
+	KubeContext string `json:"kubeContext,omitempty"`
+	// Revision is the deployed revision, which is 0 for the releases not installed
+	Revision     int        `json:"revision"`
+	Status       string     `json:"status"`
+	Health       string     `json:"health"`
+	Chart        string     `json:"chart,omitempty"`
+	AppVersion   string     `json:"appVersion,omitempty"`
+	LastDeployed *time.Time `json:"lastDeployed,omitempty"`
+	Description  string     `json:"description,omitempty"`
+}
+
+// helmStatus is the part of the output of `helm status --output json` summarized
+type helmStatus struct {
+	Version int `json:"version"`
+	Info    struct {
+		Status       string    `json:"status"`
+		LastDeployed time.Time `json:"last_deployed"`
+		Description  string    `json:"description"`
+	} `json:"info"`
+	Chart struct {
+		Metadata struct {
+			Name       string `json:"name"`
+			Version    string `json:"version"`
+			AppVersion string `json:"appVersion"`
+		} `json:"metadata"`
+	} `json:"chart"`
+}
+
+// releaseHealth returns the health of the release in the status
+func releaseHealth(status string) string {
+	switch status {
+	case "deployed":
+		return ReleaseHealthHealthy
+	case "failed":
+		return ReleaseHealthUnhealthy
+	case "pending-install", "pending-upgrade", "pending-rollback", "uninstalling":
+		return ReleaseHealthPending
+	case "uninstalled", releaseStatusNotInstalled:
+		return ReleaseHealthMissing
+	default:
+		return ReleaseHealthUnknown
+	}
+}
+
+// ReleaseStatusSummaries returns the statuses of the releases, read concurrently with `helm status`.
+// The releases not installed yet are summarized as missing, as they are expected to be installed.
+// The statuses are sorted by the release ID.
+func (st *HelmState) ReleaseStatusSummaries(helm helmexec.Interface, workerLimit int) ([]ReleaseStatusSummary, []error) {
+	var (
+		mu        sync.Mutex
+		summaries []ReleaseStatusSummary
+	)
+
+	errs := st.scatterGatherReleases(helm, workerLimit, func(release ReleaseSpec, workerIndex int) error {
+		if !release.Desired() {
+			return nil
+		}
+
+		st.ApplyOverrides(&release)
+
+		flags := []string{"--output", "json"}
+		if release.Namespace != "" {
+			flags = append(flags, "--namespace", release.Namespace)
+		}
+		flags = st.appendConnectionFlags(flags, &release)
+
+		kubeContext := release.KubeContext
+		if kubeContext == "" {
+			kubeContext = st.HelmDefaults.KubeContext
+		}
+
+		summary := ReleaseStatusSummary{
+			ID:          ReleaseToID(&release),
+			Release:     release.Name,
+			Namespace:   release.Namespace,
+			KubeContext: kubeContext,
+		}
+
+		buf := &bytes.Buffer{}
+		context := st.createHelmContext(&release, workerIndex)
+		context.Writer = buf
+		// The output is captured to be parsed, not streamed
+		liveOutput := false
+		context.LiveOutput = &liveOutput
+
+		if err := helm.ReleaseStatus(context, release.Name, flags...); err != nil {
+			if !strings.Contains(err.Error(), "release: not found") {
+				return fmt.Errorf("getting status of release %s: %w", release.Name, err)
+			}
+			summary.Status = releaseStatusNotInstalled
+		} else {
+			var hs helmStatus
+			if err := json.Unmarshal(buf.Bytes(), &hs); err != nil {
+				return fmt.Errorf("parsing status of release %s: %v", release.Name, err)
+			}
+
+			summary.Revision = hs.Version
+			summary.Status = hs.Info.Status
+			summary.Chart = fmt.Sprintf("%s-%s", hs.Chart.Metadata.Name, hs.Chart.Metadata.Version)
+			summary.AppVersion = hs.Chart.Metadata.AppVersion
+			summary.Description = hs.Info.Description
+			if !hs.Info.LastDeployed.IsZero() {
+				lastDeployed := hs.Info.LastDeployed
+				summary.LastDeployed = &lastDeployed
+			}
+		}
+
+		summary.Health = releaseHealth(summary.Status)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		summaries = append(summaries, summary)
+
+		return nil
+	})
+
+	sort.SliceStable(summaries, func(i, j int) bool {
+		return summaries[i].ID < summaries[j].ID
+	})
+
+	return summaries, errs
+}
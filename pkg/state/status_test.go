@@ -0,0 +1,63 @@
+package state
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/exectest"
+	"github.com/helmfile/helmfile/pkg/helmexec"
+)
+
+// statusHelm prints the canned `helm status` outputs of the releases
+type statusHelm struct {
+	exectest.Helm
+
+	statuses map[string]string
+}
+
+func (helm *statusHelm) ReleaseStatus(context helmexec.HelmContext, name string, flags ...string) error {
+	out, ok := helm.statuses[name]
+	if !ok {
+		return fmt.Errorf("Error: release: not found")
+	}
+	fmt.Fprint(context.Writer, out)
+	return nil
+}
+
+func TestHelmState_ReleaseStatusSummaries(t *testing.T) {
+	st := &HelmState{
+		ReleaseSetSpec: ReleaseSetSpec{
+			HelmDefaults: HelmSpec{KubeContext: "prod"},
+			Releases: []ReleaseSpec{
+				{Name: "web", Namespace: "default"},
+				{Name: "db", Namespace: "data"},
+				{Name: "new", Namespace: "default"},
+			},
+		},
+		logger: logger,
+	}
+
+	helm := &statusHelm{
+		statuses: map[string]string{
+			"web": `{"name":"web","version":2,"namespace":"default","info":{"status":"deployed","last_deployed":"2023-01-02T00:00:00Z","description":"Upgrade complete"},` +
+				`"chart":{"metadata":{"name":"web","version":"1.1.0","appVersion":"1.1.0"}}}`,
+			"db": `{"name":"db","version":3,"namespace":"data","info":{"status":"failed","last_deployed":"2023-01-03T00:00:00Z","description":"Upgrade failed"},` +
+				`"chart":{"metadata":{"name":"db","version":"2.0.0","appVersion":"2.0.0"}}}`,
+		},
+	}
+
+	summaries, errs := st.ReleaseStatusSummaries(helm, 1)
+	require.Empty(t, errs)
+
+	webDeployed := time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)
+	dbDeployed := time.Date(2023, 1, 3, 0, 0, 0, 0, time.UTC)
+
+	require.Equal(t, []ReleaseStatusSummary{
+		{ID: "data/db", Release: "db", Namespace: "data", KubeContext: "prod", Revision: 3, Status: "failed", Health: ReleaseHealthUnhealthy, Chart: "db-2.0.0", AppVersion: "2.0.0", LastDeployed: &dbDeployed, Description: "Upgrade failed"},
+		{ID: "default/new", Release: "new", Namespace: "default", KubeContext: "prod", Status: "not-installed", Health: ReleaseHealthMissing},
+		{ID: "default/web", Release: "web", Namespace: "default", KubeContext: "prod", Revision: 2, Status: "deployed", Health: ReleaseHealthHealthy, Chart: "web-1.1.0", AppVersion: "1.1.0", LastDeployed: &webDeployed, Description: "Upgrade complete"},
+	}, summaries)
+}
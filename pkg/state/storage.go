@@ -20,6 +20,9 @@ type Storage struct {
 
 	basePath string
 	fs       *filesystem.FileSystem
+
+	// offline fails fetching the remote values files missing from the cache
+	offline bool
 }
 
 func NewStorage(forFile string, logger *zap.SugaredLogger, fs *filesystem.FileSystem) *Storage {
@@ -58,6 +61,7 @@ func (st *Storage) resolveFile(missingFileHandler *string, tpe, path string, opt
 
 	if remote.IsRemote(path) {
 		r := remote.NewRemote(st.logger, "", st.fs)
+		r.Offline = st.offline
 
 		fetchedFilePath, err := r.Fetch(path, "values")
 		if err != nil {
@@ -14,8 +14,36 @@ import (
 	"github.com/helmfile/helmfile/pkg/envvar"
 )
 
-func createTempValuesFile(release *ReleaseSpec, data interface{}) (*os.File, error) {
-	p, err := tempValuesFilePath(release, data)
+// tempFilesDir returns the directory the temporary files of the state are created in,
+// which is the working directory of the run the state is loaded for, or else HELMFILE_TEMPDIR, or else the system's temporary directory
+func (st *HelmState) tempFilesDir() (string, error) {
+	dir := st.WorkDir
+	if dir == "" {
+		dir = os.Getenv(envvar.TempDir)
+	}
+	if dir == "" {
+		return os.TempDir(), nil
+	}
+
+	if err := os.MkdirAll(dir, os.FileMode(0700)); err != nil {
+		return "", err
+	}
+
+	return dir, nil
+}
+
+// MkdirTemp creates a new directory in the directory of the temporary files of the state, like os.MkdirTemp
+func (st *HelmState) MkdirTemp(pattern string) (string, error) {
+	dir, err := st.tempFilesDir()
+	if err != nil {
+		return "", err
+	}
+
+	return os.MkdirTemp(dir, pattern)
+}
+
+func (st *HelmState) createTempValuesFile(release *ReleaseSpec, data interface{}) (*os.File, error) {
+	p, err := st.tempValuesFilePath(release, data)
 	if err != nil {
 		return nil, err
 	}
@@ -28,19 +56,22 @@ func createTempValuesFile(release *ReleaseSpec, data interface{}) (*os.File, err
 	return f, nil
 }
 
-func tempValuesFilePath(release *ReleaseSpec, data interface{}) (*string, error) {
+// tempValuesFilePath returns the path to the values file of the release, named after the release and the hash of the values,
+// so that the same values are written to the same file within a run
+func (st *HelmState) tempValuesFilePath(release *ReleaseSpec, data interface{}) (*string, error) {
 	id, err := generateValuesID(release, data)
 	if err != nil {
 		return nil, err
 	}
 
-	workDir := os.Getenv(envvar.TempDir)
+	workDir := st.WorkDir
 	if workDir == "" {
-		workDir, err = os.MkdirTemp(os.TempDir(), "helmfile")
-	} else {
-		err = os.MkdirAll(workDir, os.FileMode(0700))
-	}
-	if err != nil {
+		// Without the working directory of a run, each file gets its own directory not to collide with the ones of the other processes
+		workDir, err = st.MkdirTemp("helmfile")
+		if err != nil {
+			return nil, err
+		}
+	} else if err := os.MkdirAll(workDir, os.FileMode(0700)); err != nil {
 		return nil, err
 	}
 
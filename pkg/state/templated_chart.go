@@ -0,0 +1,249 @@
+package state
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"helm.sh/helm/v3/pkg/chart"
+	k8syaml "sigs.k8s.io/yaml"
+)
+
+// templatedChartFiles are the files of a local chart that can be templated by adding the `.gotmpl` extension,
+// like `Chart.yaml.gotmpl` to inject the chart version from the state values.
+var templatedChartFiles = []string{"Chart.yaml", "values.yaml"}
+
+const templatedChartFileExt = ".gotmpl"
+
+// renderTemplatedChart returns the path to the temporary copy of the local chart whose templated files are rendered
+// with the release template data, and whose version and appVersion are overridden by the chartVersion and the chartAppVersion of the release,
+// or the chart as is if it isn't a local chart having any templated file nor the release overrides the versions.
+func (st *HelmState) renderTemplatedChart(release *ReleaseSpec, chart string) (string, error) {
+	dir := normalizeChart(st.basePath, chart)
+	if !st.fs.DirectoryExistsAt(dir) {
+		return chart, nil
+	}
+
+	var templated []string
+	for _, f := range templatedChartFiles {
+		if !st.fs.FileExistsAt(filepath.Join(dir, f+templatedChartFileExt)) {
+			continue
+		}
+		if st.fs.FileExistsAt(filepath.Join(dir, f)) {
+			return "", fmt.Errorf("chart %q has both %s and %s%s: remove either of them", chart, f, f, templatedChartFileExt)
+		}
+		templated = append(templated, f)
+	}
+
+	injectsVersion := release.ChartVersion != "" || release.ChartAppVersion != ""
+
+	if len(templated) == 0 && !injectsVersion {
+		return chart, nil
+	}
+
+	tempDir, err := st.MkdirTemp("helmfile-chart-")
+	if err != nil {
+		return "", err
+	}
+
+	// Keep the directory name, as helm expects it to be the chart name in some cases, like `helm package`
+	out := filepath.Join(tempDir, filepath.Base(dir))
+
+	if err := st.copyDir(dir, out); err != nil {
+		return "", fmt.Errorf("copying chart %q: %w", chart, err)
+	}
+
+	r := st.newFileRenderer(dir, st.newReleaseTemplateData(release))
+
+	files := map[string][]byte{}
+
+	for _, f := range templated {
+		bs, err := r.RenderToBytes(filepath.Join(dir, f+templatedChartFileExt))
+		if err != nil {
+			return "", err
+		}
+
+		if err := os.Remove(filepath.Join(out, f+templatedChartFileExt)); err != nil {
+			return "", err
+		}
+
+		files[f] = bs
+	}
+
+	metadata, ok := files["Chart.yaml"]
+	if !ok {
+		if metadata, err = st.fs.ReadFile(filepath.Join(dir, "Chart.yaml")); err != nil {
+			return "", fmt.Errorf("chart %q: %w", chart, err)
+		}
+	}
+
+	absDir, err := st.fs.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	rewritten, err := rewriteChartMetadata(metadata, absDir, release.ChartVersion, release.ChartAppVersion)
+	if err != nil {
+		return "", fmt.Errorf("chart %q: %w", chart, err)
+	}
+
+	if rewritten != nil {
+		files["Chart.yaml"] = rewritten
+
+		lockPath := filepath.Join(dir, "Chart.lock")
+		if st.fs.FileExistsAt(lockPath) {
+			lock, err := st.fs.ReadFile(lockPath)
+			if err != nil {
+				return "", err
+			}
+
+			if files["Chart.lock"], err = rewriteChartLock(lock, rewritten, absDir); err != nil {
+				return "", fmt.Errorf("chart %q: %w", chart, err)
+			}
+		}
+	}
+
+	for f, bs := range files {
+		if err := os.WriteFile(filepath.Join(out, f), bs, 0644); err != nil {
+			return "", err
+		}
+	}
+
+	st.logger.Debugf("rendered templated chart %q to %s", chart, out)
+
+	return out, nil
+}
+
+// rewriteChartMetadata overrides the version and the appVersion in the Chart.yaml, unless they are empty,
+// and makes the relative `file://` repositories of the dependencies absolute against the original chart directory,
+// so that the copy of the chart still finds them.
+// It returns nil when nothing is changed, to keep the Chart.yaml as is.
+func rewriteChartMetadata(bs []byte, dir, version, appVersion string) ([]byte, error) {
+	var metadata map[string]interface{}
+	if err := k8syaml.Unmarshal(bs, &metadata); err != nil {
+		return nil, fmt.Errorf("parsing Chart.yaml: %v", err)
+	}
+
+	changed := false
+
+	if version != "" {
+		if _, err := semver.StrictNewVersion(version); err != nil {
+			return nil, fmt.Errorf("chartVersion %q is not a semantic version: %v", version, err)
+		}
+		metadata["version"] = version
+		changed = true
+	}
+
+	if appVersion != "" {
+		metadata["appVersion"] = appVersion
+		changed = true
+	}
+
+	deps, _ := metadata["dependencies"].([]interface{})
+	for _, d := range deps {
+		dep, ok := d.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		repo, _ := dep["repository"].(string)
+		if abs, ok := absoluteFileRepository(repo, dir); ok {
+			dep["repository"] = abs
+			changed = true
+		}
+	}
+
+	if !changed {
+		return nil, nil
+	}
+
+	return k8syaml.Marshal(metadata)
+}
+
+// rewriteChartLock makes the relative `file://` repositories in the Chart.lock absolute like rewriteChartMetadata,
+// and updates the digest of it to the one of the rewritten dependencies, for `helm dependency build` not to find it out of sync
+func rewriteChartLock(bs, metadata []byte, dir string) ([]byte, error) {
+	var lock chart.Lock
+	if err := k8syaml.Unmarshal(bs, &lock); err != nil {
+		return nil, fmt.Errorf("parsing Chart.lock: %v", err)
+	}
+
+	for _, d := range lock.Dependencies {
+		if abs, ok := absoluteFileRepository(d.Repository, dir); ok {
+			d.Repository = abs
+		}
+	}
+
+	var m chart.Metadata
+	if err := k8syaml.Unmarshal(metadata, &m); err != nil {
+		return nil, fmt.Errorf("parsing Chart.yaml: %v", err)
+	}
+
+	// The digest is computed the same way as helm does
+	data, err := json.Marshal([2][]*chart.Dependency{m.Dependencies, lock.Dependencies})
+	if err != nil {
+		return nil, err
+	}
+	lock.Digest = fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+
+	return k8syaml.Marshal(lock)
+}
+
+// absoluteFileRepository returns the `file://` repository relative to the chart directory made absolute
+func absoluteFileRepository(repo, dir string) (string, bool) {
+	path, ok := strings.CutPrefix(repo, "file://")
+	if !ok || filepath.IsAbs(path) {
+		return "", false
+	}
+
+	return "file://" + filepath.Join(dir, path), true
+}
+
+// copyDir copies the files in the src directory recursively into the dst directory, keeping their modes
+func (st *HelmState) copyDir(src, dst string) error {
+	info, err := st.fs.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dst, info.Mode().Perm()); err != nil {
+		return err
+	}
+
+	entries, err := st.fs.ReadDir(src)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		path := filepath.Join(src, e.Name())
+		target := filepath.Join(dst, e.Name())
+
+		info, err := st.fs.Stat(path)
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if err := st.copyDir(path, target); err != nil {
+				return err
+			}
+			continue
+		}
+
+		bs, err := st.fs.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		if err := os.WriteFile(target, bs, info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,166 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/envvar"
+	"github.com/helmfile/helmfile/pkg/filesystem"
+)
+
+func TestHelmState_renderTemplatedChart(t *testing.T) {
+	basePath := t.TempDir()
+	t.Setenv(envvar.TempDir, t.TempDir())
+
+	writeFile := func(path, content string) {
+		t.Helper()
+		path = filepath.Join(basePath, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+
+	writeFile("charts/app/Chart.yaml.gotmpl", "apiVersion: v2\nname: app\nversion: {{ .Values.version }}\n")
+	writeFile("charts/app/values.yaml.gotmpl", "release: {{ .Release.Name }}\n")
+	writeFile("charts/app/templates/configmap.yaml", "{{ .Values.release }}\n")
+	writeFile("charts/plain/Chart.yaml", "apiVersion: v2\nname: plain\nversion: 0.1.0\n")
+	writeFile("charts/both/Chart.yaml", "apiVersion: v2\nname: both\nversion: 0.1.0\n")
+	writeFile("charts/both/Chart.yaml.gotmpl", "apiVersion: v2\nname: both\nversion: 0.1.0\n")
+
+	st := &HelmState{
+		basePath:       basePath,
+		fs:             filesystem.DefaultFileSystem(),
+		logger:         logger,
+		RenderedValues: map[string]interface{}{"version": "1.2.3"},
+	}
+	release := &ReleaseSpec{Name: "web", Chart: "./charts/app"}
+
+	out, err := st.renderTemplatedChart(release, "./charts/app")
+	require.NoError(t, err)
+	require.Equal(t, "app", filepath.Base(out))
+
+	chart, err := os.ReadFile(filepath.Join(out, "Chart.yaml"))
+	require.NoError(t, err)
+	require.Equal(t, "apiVersion: v2\nname: app\nversion: 1.2.3\n", string(chart))
+
+	values, err := os.ReadFile(filepath.Join(out, "values.yaml"))
+	require.NoError(t, err)
+	require.Equal(t, "release: web\n", string(values))
+
+	tmpl, err := os.ReadFile(filepath.Join(out, "templates", "configmap.yaml"))
+	require.NoError(t, err)
+	require.Equal(t, "{{ .Values.release }}\n", string(tmpl), "the chart templates should be copied as is")
+
+	require.NoFileExists(t, filepath.Join(out, "Chart.yaml.gotmpl"))
+	require.FileExists(t, filepath.Join(basePath, "charts", "app", "Chart.yaml.gotmpl"), "the original chart should be left untouched")
+
+	out, err = st.renderTemplatedChart(release, "./charts/plain")
+	require.NoError(t, err)
+	require.Equal(t, "./charts/plain", out)
+
+	out, err = st.renderTemplatedChart(release, "stable/nginx")
+	require.NoError(t, err)
+	require.Equal(t, "stable/nginx", out)
+
+	_, err = st.renderTemplatedChart(release, "./charts/both")
+	require.EqualError(t, err, `chart "./charts/both" has both Chart.yaml and Chart.yaml.gotmpl: remove either of them`)
+}
+
+func TestHelmState_renderTemplatedChart_injectsVersion(t *testing.T) {
+	basePath := t.TempDir()
+	t.Setenv(envvar.TempDir, t.TempDir())
+
+	dir := filepath.Join(basePath, "charts", "app")
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Chart.yaml"), []byte("apiVersion: v2\nname: app\nversion: 0.1.0\nappVersion: \"1.0\"\n"), 0644))
+
+	st := &HelmState{
+		basePath: basePath,
+		fs:       filesystem.DefaultFileSystem(),
+		logger:   logger,
+	}
+
+	release := &ReleaseSpec{Name: "web", Chart: "./charts/app", ChartVersion: "1.4.0-rc.1", ChartAppVersion: "2.0"}
+
+	out, err := st.renderTemplatedChart(release, "./charts/app")
+	require.NoError(t, err)
+	require.NotEqual(t, "./charts/app", out)
+
+	name, version, err := st.packagedChartVersion(release, out, "", false)
+	require.NoError(t, err)
+	require.Equal(t, "app", name)
+	require.Equal(t, "1.4.0-rc.1", version)
+
+	chart, err := os.ReadFile(filepath.Join(out, "Chart.yaml"))
+	require.NoError(t, err)
+	require.Equal(t, "apiVersion: v2\nappVersion: \"2.0\"\nname: app\nversion: 1.4.0-rc.1\n", string(chart))
+
+	_, err = st.renderTemplatedChart(&ReleaseSpec{Name: "web", Chart: "./charts/app", ChartVersion: "latest"}, "./charts/app")
+	require.ErrorContains(t, err, `chart "./charts/app": chartVersion "latest" is not a semantic version`)
+}
+
+func TestHelmState_renderTemplatedChart_fileDependencies(t *testing.T) {
+	basePath := t.TempDir()
+	t.Setenv(envvar.TempDir, t.TempDir())
+
+	dir := filepath.Join(basePath, "charts", "app")
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Chart.yaml.gotmpl"), []byte(`apiVersion: v2
+name: app
+version: {{ .Values.version }}
+dependencies:
+- name: common
+  version: 0.1.0
+  repository: file://../common
+- name: redis
+  version: 17.0.0
+  repository: https://charts.bitnami.com/bitnami
+`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Chart.lock"), []byte(`dependencies:
+- name: common
+  repository: file://../common
+  version: 0.1.0
+- name: redis
+  repository: https://charts.bitnami.com/bitnami
+  version: 17.0.0
+digest: sha256:0000000000000000000000000000000000000000000000000000000000000000
+generated: "2023-01-01T00:00:00Z"
+`), 0644))
+
+	st := &HelmState{
+		basePath:       basePath,
+		fs:             filesystem.DefaultFileSystem(),
+		logger:         logger,
+		RenderedValues: map[string]interface{}{"version": "1.2.3"},
+	}
+
+	out, err := st.renderTemplatedChart(&ReleaseSpec{Name: "web", Chart: "./charts/app"}, "./charts/app")
+	require.NoError(t, err)
+
+	common := "file://" + filepath.Join(basePath, "charts", "common")
+
+	metadata, err := os.ReadFile(filepath.Join(out, "Chart.yaml"))
+	require.NoError(t, err)
+	require.Equal(t, `apiVersion: v2
+dependencies:
+- name: common
+  repository: `+common+`
+  version: 0.1.0
+- name: redis
+  repository: https://charts.bitnami.com/bitnami
+  version: 17.0.0
+name: app
+version: 1.2.3
+`, string(metadata))
+
+	lock, err := os.ReadFile(filepath.Join(out, "Chart.lock"))
+	require.NoError(t, err)
+	require.Contains(t, string(lock), "repository: "+common+"\n")
+	require.NotContains(t, string(lock), "sha256:0000000000000000000000000000000000000000000000000000000000000000", "the digest should be updated")
+
+	original, err := os.ReadFile(filepath.Join(dir, "Chart.lock"))
+	require.NoError(t, err)
+	require.Contains(t, string(original), "repository: file://../common\n", "the original chart should be left untouched")
+}
@@ -0,0 +1,113 @@
+package state
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The results of release tests
+const (
+	TestResultPassed  = "passed"
+	TestResultFailed  = "failed"
+	TestResultSkipped = "skipped"
+)
+
+// TestResult is the result of `helm test` on a release
+type TestResult struct {
+	// ID is the ID of the release, like `kubecontext/namespace/name`
+	ID      string `json:"id"`
+	Release string `json:"release"`
+	// Result is one of TestResultPassed, TestResultFailed and TestResultSkipped
+	Result string `json:"result"`
+	// LogFile is the file the output of the failed test is written to
+	LogFile string `json:"logFile,omitempty"`
+	// Duration is the time `helm test` took, which is zero for the skipped releases
+	Duration time.Duration `json:"-"`
+}
+
+// TestResults collects the results of release tests run concurrently
+type TestResults struct {
+	mu      sync.Mutex
+	results []TestResult
+}
+
+func (r *TestResults) add(result TestResult) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.results = append(r.results, result)
+}
+
+// Results returns the collected results sorted by the release ID
+func (r *TestResults) Results() []TestResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	results := append([]TestResult{}, r.results...)
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].ID < results[j].ID
+	})
+
+	return results
+}
+
+// Failed returns the number of the failed release tests
+func (r *TestResults) Failed() int {
+	var n int
+	for _, res := range r.Results() {
+		if res.Result == TestResultFailed {
+			n++
+		}
+	}
+	return n
+}
+
+// reportTestResult writes the captured output of the release test to the logs directory when the test failed, and records the result.
+func (st *HelmState) reportTestResult(release *ReleaseSpec, out []byte, testErr error, duration time.Duration, opts TestOpts) error {
+	id := ReleaseToID(release)
+
+	result := TestResult{ID: id, Release: release.Name, Result: TestResultPassed, Duration: duration}
+
+	if testErr != nil {
+		result.Result = TestResultFailed
+
+		if opts.LogsDir != "" {
+			logFile, err := writeTestLog(opts.LogsDir, id, out, testErr)
+			if err != nil {
+				st.logger.Warnf("warn: unable to write the test output of release %s: %v", release.Name, err)
+			} else {
+				result.LogFile = logFile
+			}
+		}
+	}
+
+	opts.Results.add(result)
+
+	return testErr
+}
+
+// writeTestLog writes the output of the failed release test to a file named after the release ID in dir
+func writeTestLog(dir, id string, out []byte, testErr error) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, strings.ReplaceAll(id, "/", "_")+".log")
+
+	content := append(append([]byte{}, out...), []byte(fmt.Sprintf("\n%v\n", testErr))...)
+
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
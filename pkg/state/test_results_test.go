@@ -0,0 +1,55 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/exectest"
+)
+
+func TestHelmState_TestReleasesResults(t *testing.T) {
+	installed := false
+
+	st := &HelmState{
+		ReleaseSetSpec: ReleaseSetSpec{
+			Releases: []ReleaseSpec{
+				{Name: "api", Namespace: "app"},
+				{Name: "error-prone", Namespace: "app"},
+				{Name: "legacy", Namespace: "app", Installed: &installed},
+			},
+		},
+		logger: logger,
+	}
+
+	dir := filepath.Join(t.TempDir(), "logs")
+	results := &TestResults{}
+	helm := &exectest.Helm{}
+
+	errs := st.TestReleases(helm, false, 1, 1, LogsDir(dir), CollectTestResults(results))
+	require.Len(t, errs, 1)
+
+	logFile := filepath.Join(dir, "app_error-prone.log")
+
+	got := results.Results()
+	for i := range got {
+		got[i].Duration = 0
+	}
+
+	require.Equal(t, []TestResult{
+		{ID: "app/api", Release: "api", Result: TestResultPassed},
+		{ID: "app/error-prone", Release: "error-prone", Result: TestResultFailed, LogFile: logFile},
+		{ID: "app/legacy", Release: "legacy", Result: TestResultSkipped},
+	}, got)
+	require.Equal(t, 1, results.Failed())
+
+	bs, err := os.ReadFile(logFile)
+	require.NoError(t, err)
+	require.Equal(t, "\nerror\n", string(bs))
+
+	require.Equal(t, []exectest.Release{
+		{Name: "api", Flags: []string{"--namespace", "app", "--logs", "--timeout", "1s"}},
+	}, helm.Releases)
+}
@@ -2,6 +2,7 @@ package state
 
 import (
 	"github.com/helmfile/helmfile/pkg/environment"
+	"github.com/helmfile/helmfile/pkg/tmpl"
 )
 
 // TemplateSpec defines the structure of a reusable and composable template for helm releases.
@@ -13,15 +14,22 @@ type TemplateSpec struct {
 type EnvironmentTemplateData struct {
 	// Environment is accessible as `.Environment` from any template executed by the renderer
 	Environment environment.Environment
-	// Namespace is accessible as `.Namespace` from any non-values template executed by the renderer
+	// Namespace is accessible as `.Namespace` from any template executed by the renderer
 	Namespace string
+	// KubeContext is accessible as `.KubeContext` from any template executed by the renderer
+	KubeContext string
+	// Release is accessible as `.Release`. It is always empty as no release is being rendered,
+	// but allows a template shared with releases to refer to `.Release` without failing.
+	Release releaseTemplateDataRelease
 	// Values is accessible as `.Values` and it contains default state values overrode by environment values and override values.
 	Values      map[string]interface{}
 	StateValues *map[string]interface{}
+	// Files is accessible as `.Files` and gives access to the files in the directory of the state file
+	Files *tmpl.Files
 }
 
 func NewEnvironmentTemplateData(environment environment.Environment, namespace string, values map[string]interface{}) *EnvironmentTemplateData {
-	d := EnvironmentTemplateData{environment, namespace, values, nil}
+	d := EnvironmentTemplateData{Environment: environment, Namespace: namespace, Values: values}
 	d.StateValues = &d.Values
 	return &d
 }
@@ -37,18 +45,16 @@ type releaseTemplateData struct {
 	// Values is accessible as `.Values` and it contains default state values overrode by environment values and override values.
 	Values      map[string]interface{}
 	StateValues *map[string]interface{}
-	// KubeContext is HelmState.OverrideKubeContext.
-	// You should better use Release.KubeContext as it might work as you'd expect even if HelmState.OverrideKubeContext is not set.
-	// See releaseTemplateDataRelease.KubeContext for more information.
+	// KubeContext is HelmState.OverrideKubeContext, or if it's empty, ReleaseSpec.KubeContext.
 	KubeContext string
-	// Namespace is HelmState.OverrideNamespace.
-	// You should better use Release.Namespace as it might work as you'd expect even if OverrideNamespace is not set.
-	// See releaseTemplateDataRelease.Namespace for more information.
+	// Namespace is HelmState.OverrideNamespace, or if it's empty, ReleaseSpec.Namespace.
 	Namespace string
 	// Chart is HelmState.OverrideChart.
 	// You should better use Release.Chart as it might work as you'd expect even if OverrideChart is not set.
 	// See releaseTemplateDataRelease.Chart for more information.
 	Chart string
+	// Files is accessible as `.Files` and gives access to the files in the directory of the state file
+	Files *tmpl.Files
 }
 
 type releaseTemplateDataRelease struct {
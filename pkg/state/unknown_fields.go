@@ -0,0 +1,135 @@
+package state
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/helmfile/helmfile/pkg/yaml"
+)
+
+// yamlUnmarshalerType is the type of the fields decoding themselves, which accept the shapes other than their Go types
+var yamlUnmarshalerType = reflect.TypeOf((*interface {
+	UnmarshalYAML(func(interface{}) error) error
+})(nil)).Elem()
+
+// unknownFieldsOfDocument returns the paths of the keys of the document at the 1-based index of the YAML stream
+// that have no corresponding fields in HelmState, like `releases[0].kubeContxt`, to locate the typos the strict decoding rejects
+func unknownFieldsOfDocument(content []byte, index int) []string {
+	decode := yaml.NewDecoder(content, false)
+
+	var doc interface{}
+	for i := 0; i < index; i++ {
+		doc = nil
+		if err := decode(&doc); err != nil {
+			return nil
+		}
+	}
+
+	return unknownFields(doc, reflect.TypeOf(helmStateAlias{}), "")
+}
+
+// unknownFields returns the paths of the keys of the decoded YAML value that have no corresponding fields in the type, sorted
+func unknownFields(v interface{}, t reflect.Type, path string) []string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if v == nil || reflect.PointerTo(t).Implements(yamlUnmarshalerType) {
+		return nil
+	}
+
+	var unknown []string
+
+	switch t.Kind() {
+	case reflect.Struct:
+		fields := map[string]reflect.Type{}
+		collectYAMLFields(t, fields)
+
+		for _, k := range sortedKeys(v) {
+			child := joinFieldPath(path, k.name)
+			ft, ok := fields[k.name]
+			if !ok {
+				unknown = append(unknown, child)
+				continue
+			}
+			unknown = append(unknown, unknownFields(k.value, ft, child)...)
+		}
+	case reflect.Map:
+		for _, k := range sortedKeys(v) {
+			unknown = append(unknown, unknownFields(k.value, t.Elem(), joinFieldPath(path, k.name))...)
+		}
+	case reflect.Slice, reflect.Array:
+		items, _ := v.([]interface{})
+		for i, item := range items {
+			unknown = append(unknown, unknownFields(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	}
+
+	return unknown
+}
+
+// collectYAMLFields adds the types of the fields of the struct to the map by their YAML keys, including the ones of the inlined structs
+func collectYAMLFields(t reflect.Type, fields map[string]reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		tag := f.Tag.Get("yaml")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		if strings.Contains(opts, "inline") {
+			ft := f.Type
+			for ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				collectYAMLFields(ft, fields)
+			}
+			continue
+		}
+
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f.Type
+	}
+}
+
+type keyedValue struct {
+	name  string
+	value interface{}
+}
+
+// sortedKeys returns the entries of the decoded YAML map sorted by the keys, or nil when the value isn't a map
+func sortedKeys(v interface{}) []keyedValue {
+	var kvs []keyedValue
+
+	switch m := v.(type) {
+	case map[string]interface{}:
+		for k, v := range m {
+			kvs = append(kvs, keyedValue{k, v})
+		}
+	case map[interface{}]interface{}:
+		for k, v := range m {
+			kvs = append(kvs, keyedValue{fmt.Sprintf("%v", k), v})
+		}
+	}
+
+	sort.Slice(kvs, func(i, j int) bool { return kvs[i].name < kvs[j].name })
+
+	return kvs
+}
+
+func joinFieldPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -31,6 +31,18 @@ func isLocalChart(chart string) bool {
 			len(strings.Split(chart, "/")) != 3)
 }
 
+// splitChartDigest splits a chart reference pinned by digest like `oci://registry/app@sha256:abc`
+// into the chart `oci://registry/app` and the digest `sha256:abc`.
+// The digest is empty when the chart isn't pinned.
+func splitChartDigest(chart string) (string, string) {
+	i := strings.LastIndex(chart, "@")
+	if i < 0 || !strings.Contains(chart[i+1:], ":") || strings.Contains(chart[i+1:], "/") {
+		return chart, ""
+	}
+
+	return chart[:i], chart[i+1:]
+}
+
 func resolveRemoteChart(repoAndChart string) (string, string, bool) {
 	if isLocalChart(repoAndChart) {
 		return "", "", false
@@ -52,6 +64,20 @@ func resolveRemoteChart(repoAndChart string) (string, string, bool) {
 	return repo, chart, true
 }
 
+// resolveOCIChart returns the repository and the name of the OCI chart referenced like `oci://registry/path/app`
+func resolveOCIChart(chart string) (string, string, bool) {
+	if !strings.HasPrefix(chart, "oci://") {
+		return "", "", false
+	}
+
+	i := strings.LastIndex(chart, "/")
+	if i < len("oci://") || i == len(chart)-1 {
+		return "", "", false
+	}
+
+	return chart[:i], chart[i+1:], true
+}
+
 // normalizeChart allows for the distinction between a file path reference and repository references.
 // - Any single (or double character) followed by a `/` will be considered a local file reference and
 // be constructed relative to the `base path`.
@@ -68,6 +94,12 @@ func getBuildDepsFlags(cpr *chartPrepareResult) []string {
 	if cpr.skipRefresh {
 		flags = append(flags, "--skip-refresh")
 	}
+	if cpr.plainHTTP {
+		flags = append(flags, "--plain-http")
+	}
+	if cpr.insecureSkipTLSVerify {
+		flags = append(flags, "--insecure-skip-tls-verify")
+	}
 
 	return flags
 }
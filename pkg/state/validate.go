@@ -0,0 +1,196 @@
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/helmfile/helmfile/pkg/envvar"
+	"github.com/helmfile/helmfile/pkg/helmexec"
+)
+
+const (
+	// DefaultKubeconformBinary is the kubeconform binary used to validate the manifests against the schemas unless HELMFILE_KUBECONFORM_BINARY is set
+	DefaultKubeconformBinary = "kubeconform"
+	// DefaultConftestBinary is the conftest binary used to check the manifests against the rego policies unless HELMFILE_CONFTEST_BINARY is set
+	DefaultConftestBinary = "conftest"
+)
+
+const (
+	ValidationCheckSchema = "schema"
+	ValidationCheckPolicy = "policy"
+)
+
+// ValidateOpts is the options to validate the rendered manifests of the releases
+type ValidateOpts struct {
+	// SchemaLocations are the locations of the JSON schemas passed to kubeconform, which defaults to its own when empty
+	SchemaLocations []string
+	// Strict disallows the fields not in the schemas
+	Strict bool
+	// IgnoreMissingSchemas skips the resources without the schemas, like the custom resources, instead of failing
+	IgnoreMissingSchemas bool
+	// Policies are the files or the directories of the rego policies checked with conftest. No policy is checked when empty.
+	Policies []string
+}
+
+// Violation is a problem found in the rendered manifests of a release
+type Violation struct {
+	Release     string `json:"release"`
+	Namespace   string `json:"namespace"`
+	KubeContext string `json:"kubeContext"`
+	// Check is either "schema" or "policy"
+	Check string `json:"check"`
+	// Kind and Name are the resource violating the schema, which are unknown for the policies
+	Kind string `json:"kind,omitempty"`
+	Name string `json:"name,omitempty"`
+	// Warning is true for the warnings of the policies, which don't fail the validation
+	Warning bool   `json:"warning"`
+	Message string `json:"message"`
+}
+
+// kubeconformResult is the output of `kubeconform -output json`, which lists the resources other than the valid ones
+type kubeconformResult struct {
+	Resources []struct {
+		Kind   string `json:"kind"`
+		Name   string `json:"name"`
+		Status string `json:"status"`
+		Msg    string `json:"msg"`
+	} `json:"resources"`
+}
+
+// conftestResult is an element of the output of `conftest test --output json`
+type conftestResult struct {
+	Failures []struct {
+		Msg string `json:"msg"`
+	} `json:"failures"`
+	Warnings []struct {
+		Msg string `json:"msg"`
+	} `json:"warnings"`
+}
+
+// ValidateManifests validates the rendered manifests of the release against the schemas with kubeconform,
+// and checks them against the rego policies with conftest when any policy is given, returning the violations found.
+// The manifests are validated against the schemas of `kubeVersion` of the release or the helmfile if any.
+func (st *HelmState) ValidateManifests(release *ReleaseSpec, manifests []byte, opts ValidateOpts) ([]Violation, error) {
+	if len(bytes.TrimSpace(manifests)) == 0 {
+		return nil, nil
+	}
+
+	kubeContext := release.KubeContext
+	if kubeContext == "" {
+		kubeContext = st.HelmDefaults.KubeContext
+	}
+
+	violation := func(check string) Violation {
+		return Violation{
+			Release:     release.Name,
+			Namespace:   release.Namespace,
+			KubeContext: kubeContext,
+			Check:       check,
+		}
+	}
+
+	var violations []Violation
+
+	out, err := st.execValidator(envvar.KubeconformBinary, DefaultKubeconformBinary, st.kubeconformArgs(release, opts), manifests)
+
+	var schemaResult kubeconformResult
+	if jsonErr := json.Unmarshal(out, &schemaResult); jsonErr != nil {
+		if err != nil {
+			return nil, fmt.Errorf("validating the manifests of release %q against the schemas: %w", release.Name, err)
+		}
+		return nil, fmt.Errorf("validating the manifests of release %q against the schemas: parsing the output of kubeconform: %v", release.Name, jsonErr)
+	}
+
+	for _, r := range schemaResult.Resources {
+		if r.Status != "statusInvalid" && r.Status != "statusError" {
+			continue
+		}
+
+		v := violation(ValidationCheckSchema)
+		v.Kind = r.Kind
+		v.Name = r.Name
+		v.Message = r.Msg
+		violations = append(violations, v)
+	}
+
+	if len(opts.Policies) == 0 {
+		return violations, nil
+	}
+
+	args := []string{"test", "--output", "json", "--parser", "yaml"}
+	for _, p := range opts.Policies {
+		args = append(args, "--policy", p)
+	}
+	args = append(args, "-")
+
+	out, err = st.execValidator(envvar.ConftestBinary, DefaultConftestBinary, args, manifests)
+
+	var policyResults []conftestResult
+	if jsonErr := json.Unmarshal(out, &policyResults); jsonErr != nil {
+		if err != nil {
+			return nil, fmt.Errorf("checking the manifests of release %q against the policies: %w", release.Name, err)
+		}
+		return nil, fmt.Errorf("checking the manifests of release %q against the policies: parsing the output of conftest: %v", release.Name, jsonErr)
+	}
+
+	for _, r := range policyResults {
+		for _, f := range r.Failures {
+			v := violation(ValidationCheckPolicy)
+			v.Message = f.Msg
+			violations = append(violations, v)
+		}
+		for _, w := range r.Warnings {
+			v := violation(ValidationCheckPolicy)
+			v.Warning = true
+			v.Message = w.Msg
+			violations = append(violations, v)
+		}
+	}
+
+	return violations, nil
+}
+
+func (st *HelmState) kubeconformArgs(release *ReleaseSpec, opts ValidateOpts) []string {
+	args := []string{"-output", "json"}
+
+	kubeVersion := release.KubeVersion
+	if kubeVersion == "" {
+		kubeVersion = st.KubeVersion
+	}
+	if kubeVersion != "" {
+		args = append(args, "-kubernetes-version", strings.TrimPrefix(kubeVersion, "v"))
+	}
+
+	for _, l := range opts.SchemaLocations {
+		args = append(args, "-schema-location", l)
+	}
+
+	if opts.Strict {
+		args = append(args, "-strict")
+	}
+
+	if opts.IgnoreMissingSchemas {
+		args = append(args, "-ignore-missing-schemas")
+	}
+
+	return append(args, "-")
+}
+
+// execValidator runs the binary in the environment variable, or the default one, with the manifests in the stdin, and returns its stdout.
+// The output is returned along with the error, as the validators exit with non-zero codes when they find any violation.
+func (st *HelmState) execValidator(binaryEnv, defaultBinary string, args []string, manifests []byte) ([]byte, error) {
+	bin := os.Getenv(binaryEnv)
+	if bin == "" {
+		bin = defaultBinary
+	}
+
+	runner := st.runner
+	if runner == nil {
+		runner = helmexec.ShellRunner{Logger: st.logger}
+	}
+
+	return runner.ExecuteStdIn(bin, args, map[string]string{}, bytes.NewReader(manifests))
+}
@@ -0,0 +1,69 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/envvar"
+	"github.com/helmfile/helmfile/pkg/exectest"
+)
+
+func TestHelmState_ValidateManifests(t *testing.T) {
+	t.Setenv(envvar.KubeconformBinary, "")
+	t.Setenv(envvar.ConftestBinary, "")
+
+	kubeconform := "kubeconform -output json -kubernetes-version 1.27.0 -schema-location default -strict -"
+	conftest := "conftest test --output json --parser yaml --policy policies -"
+
+	r := &exectest.Runner{
+		Outputs: map[string]string{
+			kubeconform: `{
+  "resources": [
+    {"filename": "stdin", "kind": "Deployment", "name": "web", "version": "apps/v1", "status": "statusInvalid", "msg": "For field spec.replicas: Invalid type. Expected: [integer,null], given: string"},
+    {"filename": "stdin", "kind": "Widget", "name": "w", "version": "example.com/v1", "status": "statusSkipped", "msg": ""}
+  ],
+  "summary": {"valid": 1, "invalid": 1, "errors": 0, "skipped": 1}
+}`,
+			conftest: `[{"filename": "", "namespace": "main", "successes": 1, "failures": [{"msg": "containers must not run as root"}], "warnings": [{"msg": "image tag should be pinned"}]}]`,
+		},
+		Failures: map[string]bool{
+			kubeconform: true,
+			conftest:    true,
+		},
+	}
+
+	st := &HelmState{
+		ReleaseSetSpec: ReleaseSetSpec{
+			HelmDefaults: HelmSpec{KubeContext: "prod"},
+			KubeVersion:  "v1.27.0",
+		},
+		logger: logger,
+		runner: r,
+	}
+
+	violations, err := st.ValidateManifests(&ReleaseSpec{Name: "web", Namespace: "apps"}, []byte("kind: Deployment\n"), ValidateOpts{
+		SchemaLocations: []string{"default"},
+		Strict:          true,
+		Policies:        []string{"policies"},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, []Violation{
+		{Release: "web", Namespace: "apps", KubeContext: "prod", Check: "schema", Kind: "Deployment", Name: "web", Message: "For field spec.replicas: Invalid type. Expected: [integer,null], given: string"},
+		{Release: "web", Namespace: "apps", KubeContext: "prod", Check: "policy", Message: "containers must not run as root"},
+		{Release: "web", Namespace: "apps", KubeContext: "prod", Check: "policy", Warning: true, Message: "image tag should be pinned"},
+	}, violations)
+	require.Equal(t, []string{kubeconform, conftest}, r.Calls)
+
+	violations, err = st.ValidateManifests(&ReleaseSpec{Name: "empty"}, []byte("\n"), ValidateOpts{})
+	require.NoError(t, err)
+	require.Empty(t, violations)
+	require.Len(t, r.Calls, 2, "empty manifests aren't validated")
+
+	r.Outputs = map[string]string{}
+	r.Failures = map[string]bool{"kubeconform -output json -kubernetes-version 1.27.0 -": true}
+
+	_, err = st.ValidateManifests(&ReleaseSpec{Name: "web"}, []byte("kind: Deployment\n"), ValidateOpts{})
+	require.EqualError(t, err, `validating the manifests of release "web" against the schemas: exit status 1`)
+}
@@ -0,0 +1,310 @@
+package state
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/helmfile/helmfile/pkg/yaml"
+)
+
+// DefaultVendorDir is the directory the charts are vendored into by `helmfile fetch --vendor`, relative to the directory of the helmfile
+const DefaultVendorDir = "vendor/charts"
+
+// VendorManifestFile is the manifest of the charts vendored in the vendor directory
+const VendorManifestFile = "vendor.yaml"
+
+// VendorManifest records the charts vendored for the releases of the helmfiles in the same directory
+type VendorManifest struct {
+	Charts []VendoredChart `yaml:"charts"`
+}
+
+// VendoredChart is the chart vendored for a release, which is used instead of downloading the chart
+// as long as the chart and the version of the release are unchanged
+type VendoredChart struct {
+	// Helmfile is the name of the helmfile containing the release
+	Helmfile string `yaml:"helmfile"`
+	// Release is the ID of the release, like `kubecontext/namespace/name`
+	Release string `yaml:"release"`
+	Chart   string `yaml:"chart"`
+	Version string `yaml:"version,omitempty"`
+	// Path is the directory of the vendored chart, relative to the vendor directory
+	Path string `yaml:"path"`
+	// Digest is the sha256 digest of the files of the vendored chart, which is verified before using it
+	Digest string `yaml:"digest"`
+}
+
+var vendorPathSchemeRegexp = regexp.MustCompile(`^([a-zA-Z0-9+.-]+::)?([a-zA-Z0-9+.-]+://)?`)
+
+var vendorPathInvalidCharsRegexp = regexp.MustCompile(`[^a-zA-Z0-9._/-]`)
+
+// vendorDir returns the vendor directory of the state
+func (st *HelmState) vendorDir() string {
+	return filepath.Join(st.basePath, filepath.FromSlash(DefaultVendorDir))
+}
+
+// readVendorManifest reads the manifest in the vendor directory, returning nil without any error when it doesn't exist
+func (st *HelmState) readVendorManifest() (*VendorManifest, error) {
+	path := filepath.Join(st.vendorDir(), VendorManifestFile)
+
+	bs, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var m VendorManifest
+	if err := yaml.Unmarshal(bs, &m); err != nil {
+		return nil, fmt.Errorf("reading %s: %v", path, err)
+	}
+
+	return &m, nil
+}
+
+// vendoredChart returns the chart vendored for the release, or nil when the release has no vendored chart
+// or the chart or the version of the release changed since it was vendored
+func (st *HelmState) vendoredChart(m *VendorManifest, release *ReleaseSpec) *VendoredChart {
+	if m == nil {
+		return nil
+	}
+
+	helmfile, id := filepath.Base(st.FilePath), ReleaseToID(release)
+
+	for i, c := range m.Charts {
+		if c.Helmfile != helmfile || c.Release != id {
+			continue
+		}
+
+		if c.Chart != release.Chart || c.Version != release.Version {
+			st.logger.Warnf("warn: ignoring the chart vendored for release %q, as the chart changed from %s@%s to %s@%s. Run `helmfile fetch --vendor` to vendor it again",
+				release.Name, c.Chart, c.Version, release.Chart, release.Version)
+			return nil
+		}
+
+		return &m.Charts[i]
+	}
+
+	return nil
+}
+
+// vendoredChartPath returns the absolute path to the chart vendored for the release after verifying its digest,
+// or an empty string when the release has no vendored chart
+func (st *HelmState) vendoredChartPath(m *VendorManifest, release *ReleaseSpec) (string, error) {
+	c := st.vendoredChart(m, release)
+	if c == nil {
+		return "", nil
+	}
+
+	path, err := filepath.Abs(filepath.Join(st.vendorDir(), filepath.FromSlash(c.Path)))
+	if err != nil {
+		return "", err
+	}
+
+	digest, err := dirDigest(path)
+	if err != nil {
+		return "", fmt.Errorf("reading the vendored chart %s: %v", c.Path, err)
+	}
+
+	if digest != c.Digest {
+		return "", fmt.Errorf("the vendored chart %s has been modified: expected digest %s, got %s", c.Path, c.Digest, digest)
+	}
+
+	st.logger.Debugf("using the vendored chart %s for release %q", c.Path, release.Name)
+
+	return path, nil
+}
+
+// ChartsVendored returns true when the charts of all the releases to be installed are vendored,
+// so that the chart repositories don't need to be updated
+func (st *HelmState) ChartsVendored() bool {
+	m, err := st.readVendorManifest()
+	if err != nil || m == nil {
+		return false
+	}
+
+	releases := releasesNeedCharts(st.Releases)
+	for i := range releases {
+		if st.vendoredChart(m, &releases[i]) == nil {
+			return false
+		}
+	}
+
+	if len(releases) == 0 {
+		return false
+	}
+
+	st.logger.Debugf("all the charts of %s are vendored", st.FilePath)
+
+	return true
+}
+
+// VendorCharts copies the charts prepared for the releases into the vendor directory,
+// at the paths named after the charts and their versions, and records them in the manifest of the vendor directory.
+// The records of the other helmfiles in the same directory are kept.
+func (st *HelmState) VendorCharts(releaseToChart map[PrepareChartKey]string) error {
+	dir := st.vendorDir()
+
+	m, err := st.readVendorManifest()
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		m = &VendorManifest{}
+	}
+
+	helmfile := filepath.Base(st.FilePath)
+
+	charts := []VendoredChart{}
+	for _, c := range m.Charts {
+		if c.Helmfile != helmfile {
+			charts = append(charts, c)
+		}
+	}
+
+	// vendored is the digests of the charts vendored in this run by their paths, to share the same chart among the releases
+	vendored := map[string]string{}
+
+	releases := releasesNeedCharts(st.Releases)
+
+	for i := range releases {
+		release := &releases[i]
+
+		src, ok := releaseToChart[PrepareChartKey{Name: release.Name, Namespace: release.Namespace, KubeContext: release.KubeContext}]
+		if !ok {
+			continue
+		}
+		src = normalizeChart(st.basePath, src)
+
+		if !st.fs.DirectoryExistsAt(src) {
+			return fmt.Errorf("release %q: the chart %s has not been downloaded", release.Name, release.Chart)
+		}
+
+		digest, err := dirDigest(src)
+		if err != nil {
+			return fmt.Errorf("release %q: %v", release.Name, err)
+		}
+
+		path := vendorChartPath(release.Chart, release.Version, st.fs.DirectoryExistsAt(normalizeChart(st.basePath, release.Chart)))
+		if d, ok := vendored[path]; ok && d != digest {
+			// The releases sharing the chart may have different charts, like the local charts templated with the release
+			path = fmt.Sprintf("%s-%s", path, strings.TrimPrefix(digest, "sha256:")[:8])
+		}
+
+		if _, ok := vendored[path]; !ok {
+			dst := filepath.Join(dir, filepath.FromSlash(path))
+
+			if err := os.RemoveAll(dst); err != nil {
+				return err
+			}
+			if err := st.copyDir(src, dst); err != nil {
+				return fmt.Errorf("release %q: vendoring the chart %s: %v", release.Name, release.Chart, err)
+			}
+
+			vendored[path] = digest
+
+			st.logger.Infof("vendored the chart %s into %s", release.Chart, dst)
+		}
+
+		charts = append(charts, VendoredChart{
+			Helmfile: helmfile,
+			Release:  ReleaseToID(release),
+			Chart:    release.Chart,
+			Version:  release.Version,
+			Path:     path,
+			Digest:   digest,
+		})
+	}
+
+	sort.SliceStable(charts, func(i, j int) bool {
+		if charts[i].Helmfile != charts[j].Helmfile {
+			return charts[i].Helmfile < charts[j].Helmfile
+		}
+		return charts[i].Release < charts[j].Release
+	})
+
+	m.Charts = charts
+
+	bs, err := yaml.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(dir, VendorManifestFile), bs, 0644)
+}
+
+// vendorChartPath returns the deterministic path of the vendored chart relative to the vendor directory,
+// like `bitnami/nginx/15.0.0` for the remote chart and `local/charts/web/latest` for the local chart without the version
+func vendorChartPath(chart, version string, local bool) string {
+	name := vendorPathSchemeRegexp.ReplaceAllString(chart, "")
+	name = vendorPathInvalidCharsRegexp.ReplaceAllString(name, "_")
+
+	var elems []string
+	if local {
+		elems = append(elems, "local")
+	}
+
+	for _, e := range strings.Split(name, "/") {
+		switch e {
+		case "", ".":
+			continue
+		case "..":
+			e = "_"
+		}
+		elems = append(elems, e)
+	}
+
+	if version == "" {
+		version = "latest"
+	}
+
+	return strings.Join(append(elems, vendorPathInvalidCharsRegexp.ReplaceAllString(version, "_")), "/")
+}
+
+// dirDigest returns the sha256 digest of the paths and the contents of the files in the directory
+func dirDigest(dir string) (string, error) {
+	h := sha256.New()
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = f.Close()
+		}()
+
+		fmt.Fprintf(h, "%s\x00", filepath.ToSlash(rel))
+		if _, err := io.Copy(h, f); err != nil {
+			return err
+		}
+		h.Write([]byte{0})
+
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,94 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/filesystem"
+)
+
+func TestVendorChartPath(t *testing.T) {
+	tests := []struct {
+		chart   string
+		version string
+		local   bool
+		want    string
+	}{
+		{chart: "bitnami/nginx", version: "15.0.0", want: "bitnami/nginx/15.0.0"},
+		{chart: "oci://registry.example.com/charts/app", version: "1.2.3", want: "registry.example.com/charts/app/1.2.3"},
+		{chart: "git::https://github.com/org/repo.git@charts/app?ref=v1.0.0", want: "github.com/org/repo.git_charts/app_ref_v1.0.0/latest"},
+		{chart: "./charts/web", local: true, want: "local/charts/web/latest"},
+		{chart: "../shared/web", version: "0.1.0", local: true, want: "local/_/shared/web/0.1.0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.chart, func(t *testing.T) {
+			require.Equal(t, tt.want, vendorChartPath(tt.chart, tt.version, tt.local))
+		})
+	}
+}
+
+func TestHelmState_VendorCharts(t *testing.T) {
+	dir := t.TempDir()
+
+	downloaded := filepath.Join(t.TempDir(), "nginx")
+	require.NoError(t, os.MkdirAll(filepath.Join(downloaded, "templates"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(downloaded, "Chart.yaml"), []byte("name: nginx\nversion: 15.0.0\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(downloaded, "templates", "deployment.yaml"), []byte("kind: Deployment\n"), 0644))
+
+	newState := func(version string) *HelmState {
+		return &HelmState{
+			basePath: dir,
+			FilePath: filepath.Join(dir, "helmfile.yaml"),
+			ReleaseSetSpec: ReleaseSetSpec{
+				Releases: []ReleaseSpec{
+					{Name: "web", Namespace: "app", Chart: "bitnami/nginx", Version: version},
+					{Name: "admin", Namespace: "app", Chart: "bitnami/nginx", Version: version},
+				},
+			},
+			logger: logger,
+			fs:     filesystem.DefaultFileSystem(),
+		}
+	}
+
+	st := newState("15.0.0")
+
+	require.NoError(t, st.VendorCharts(map[PrepareChartKey]string{
+		{Name: "web", Namespace: "app"}:   downloaded,
+		{Name: "admin", Namespace: "app"}: downloaded,
+	}))
+
+	vendored := filepath.Join(dir, "vendor", "charts", "bitnami", "nginx", "15.0.0")
+
+	bs, err := os.ReadFile(filepath.Join(vendored, "templates", "deployment.yaml"))
+	require.NoError(t, err)
+	require.Equal(t, "kind: Deployment\n", string(bs))
+
+	m, err := st.readVendorManifest()
+	require.NoError(t, err)
+	require.Len(t, m.Charts, 2)
+	require.Equal(t, "app/admin", m.Charts[0].Release)
+	require.Equal(t, "app/web", m.Charts[1].Release)
+	require.Equal(t, "bitnami/nginx/15.0.0", m.Charts[1].Path)
+	require.Equal(t, "helmfile.yaml", m.Charts[1].Helmfile)
+	require.True(t, st.ChartsVendored())
+
+	path, err := st.vendoredChartPath(m, &st.Releases[0])
+	require.NoError(t, err)
+	require.Equal(t, vendored, path)
+
+	// The vendored chart is ignored once the version of the release changes
+	upgraded := newState("16.0.0")
+	path, err = upgraded.vendoredChartPath(m, &upgraded.Releases[0])
+	require.NoError(t, err)
+	require.Empty(t, path)
+	require.False(t, upgraded.ChartsVendored())
+
+	require.NoError(t, os.WriteFile(filepath.Join(vendored, "templates", "deployment.yaml"), []byte("kind: StatefulSet\n"), 0644))
+
+	_, err = st.vendoredChartPath(m, &st.Releases[0])
+	require.ErrorContains(t, err, "the vendored chart bitnami/nginx/15.0.0 has been modified")
+}
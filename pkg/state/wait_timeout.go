@@ -0,0 +1,94 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/helmfile/helmfile/pkg/helmexec"
+)
+
+// The policies for `onWaitTimeout` of the release, applied when `--wait` or `--wait-for-jobs` times out on the upgrade of the release
+const (
+	// OnWaitTimeoutKeep leaves the timed out release as it is, which is the default
+	OnWaitTimeoutKeep = "keep"
+	// OnWaitTimeoutRollback rolls back the timed out release to the previous revision, like `--atomic`
+	OnWaitTimeoutRollback = "rollback"
+	// OnWaitTimeoutRetry upgrades the timed out release again, as many times as the number following it, like `retry3`, or once
+	OnWaitTimeoutRetry = "retry"
+)
+
+type waitTimeoutPolicy struct {
+	rollback bool
+	retries  int
+}
+
+func parseOnWaitTimeout(s string) (waitTimeoutPolicy, error) {
+	switch {
+	case s == "" || s == OnWaitTimeoutKeep:
+		return waitTimeoutPolicy{}, nil
+	case s == OnWaitTimeoutRollback:
+		return waitTimeoutPolicy{rollback: true}, nil
+	case s == OnWaitTimeoutRetry:
+		return waitTimeoutPolicy{retries: 1}, nil
+	case strings.HasPrefix(s, OnWaitTimeoutRetry):
+		n, err := strconv.Atoi(strings.TrimPrefix(s, OnWaitTimeoutRetry))
+		if err == nil && n > 0 {
+			return waitTimeoutPolicy{retries: n}, nil
+		}
+	}
+
+	return waitTimeoutPolicy{}, fmt.Errorf("invalid onWaitTimeout %q: must be one of %s, %s, %s and %sN like %s3", s, OnWaitTimeoutKeep, OnWaitTimeoutRollback, OnWaitTimeoutRetry, OnWaitTimeoutRetry, OnWaitTimeoutRetry)
+}
+
+// isWaitTimeout returns whether helm timed out waiting for the resources of the release,
+// which excludes the helm commands killed on --command-timeout and the ones stopped on SIGINT, SIGTERM, or --run-timeout
+func isWaitTimeout(err error) bool {
+	return errors.Is(err, helmexec.ErrWaitTimeout)
+}
+
+// syncRelease upgrades the release, applying the onWaitTimeout policy of the release when waiting for its resources times out.
+// The retried and rolled back releases are recorded to affectedReleases.
+func (st *HelmState) syncRelease(context helmexec.HelmContext, helm helmexec.Interface, release *ReleaseSpec, chart string, flags []string, affectedReleases *AffectedReleases, m *sync.Mutex) error {
+	policy, err := parseOnWaitTimeout(release.OnWaitTimeout)
+	if err != nil {
+		return err
+	}
+
+	err = helm.SyncRelease(context, release.Name, chart, flags...)
+
+	for i := 1; i <= policy.retries && err != nil && isWaitTimeout(err); i++ {
+		st.logger.Warnf("Waiting for release %s timed out. Retrying (%d/%d)", release.Name, i, policy.retries)
+
+		if i == 1 {
+			m.Lock()
+			affectedReleases.Retried = append(affectedReleases.Retried, release)
+			m.Unlock()
+		}
+
+		err = helm.SyncRelease(context, release.Name, chart, flags...)
+	}
+
+	if err == nil || !policy.rollback || !isWaitTimeout(err) {
+		return err
+	}
+
+	st.logger.Warnf("Waiting for release %s timed out. Rolling back", release.Name)
+
+	rollbackFlags := st.connectionFlags(release)
+	if release.Namespace != "" {
+		rollbackFlags = append(rollbackFlags, "--namespace", release.Namespace)
+	}
+
+	if rbErr := helm.Exec(context, append([]string{"rollback", release.Name}, rollbackFlags...)...); rbErr != nil {
+		return fmt.Errorf("%w\n\nrolling back release %s after the wait timeout: %v", err, release.Name, rbErr)
+	}
+
+	m.Lock()
+	affectedReleases.RolledBack = append(affectedReleases.RolledBack, release)
+	m.Unlock()
+
+	return err
+}
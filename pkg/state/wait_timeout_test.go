@@ -0,0 +1,99 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/exectest"
+	"github.com/helmfile/helmfile/pkg/helmexec"
+)
+
+// timingOutHelm fails to upgrade the releases with the wait timeout, or with err if set, for the given number of times
+type timingOutHelm struct {
+	exectest.Helm
+
+	timeouts int
+	err      error
+	syncs    int
+}
+
+func (helm *timingOutHelm) SyncRelease(context helmexec.HelmContext, name, chart string, flags ...string) error {
+	helm.syncs++
+	if helm.syncs <= helm.timeouts {
+		if helm.err != nil {
+			return helm.err
+		}
+		return fmt.Errorf("Error: UPGRADE FAILED: timed out waiting for the condition: %w", helmexec.ErrWaitTimeout)
+	}
+	return nil
+}
+
+func TestParseOnWaitTimeout(t *testing.T) {
+	for s, want := range map[string]waitTimeoutPolicy{
+		"":         {},
+		"keep":     {},
+		"rollback": {rollback: true},
+		"retry":    {retries: 1},
+		"retry3":   {retries: 3},
+	} {
+		got, err := parseOnWaitTimeout(s)
+		require.NoError(t, err)
+		require.Equal(t, want, got, s)
+	}
+
+	for _, s := range []string{"retry0", "retryx", "atomic"} {
+		_, err := parseOnWaitTimeout(s)
+		require.Error(t, err, s)
+	}
+}
+
+func TestHelmState_syncReleaseOnWaitTimeout(t *testing.T) {
+	tests := []struct {
+		name           string
+		policy         string
+		timeouts       int
+		err            error
+		wantErr        bool
+		wantSyncs      int
+		wantRetried    bool
+		wantRolledBack bool
+	}{
+		{name: "keep", policy: "keep", timeouts: 1, wantErr: true, wantSyncs: 1},
+		{name: "retry succeeds", policy: "retry2", timeouts: 2, wantSyncs: 3, wantRetried: true},
+		{name: "retry fails", policy: "retry", timeouts: 2, wantErr: true, wantSyncs: 2, wantRetried: true},
+		{name: "rollback", policy: "rollback", timeouts: 1, wantErr: true, wantSyncs: 1, wantRolledBack: true},
+		{name: "no timeout", policy: "rollback", wantSyncs: 1},
+		{name: "command timeout", policy: "retry", timeouts: 1, err: &helmexec.TimeoutError{Path: "helm", Timeout: time.Minute}, wantErr: true, wantSyncs: 1},
+		{name: "canceled", policy: "rollback", timeouts: 1, err: fmt.Errorf("command %q is stopped: %w", "helm upgrade", context.Canceled), wantErr: true, wantSyncs: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := &HelmState{logger: logger}
+			helm := &timingOutHelm{timeouts: tt.timeouts, err: tt.err}
+			release := &ReleaseSpec{Name: "web", Namespace: "default", OnWaitTimeout: tt.policy}
+			affected := &AffectedReleases{}
+
+			err := st.syncRelease(helmexec.HelmContext{}, helm, release, "charts/web", nil, affected, &sync.Mutex{})
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.wantSyncs, helm.syncs)
+			require.Equal(t, tt.wantRetried, len(affected.Retried) == 1)
+			require.Equal(t, tt.wantRolledBack, len(affected.RolledBack) == 1)
+
+			if tt.wantRolledBack {
+				require.Equal(t, [][]string{{"rollback", "web", "--namespace", "default"}}, helm.Execs)
+			} else {
+				require.Empty(t, helm.Execs)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package state
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/strvals"
+
+	"github.com/helmfile/helmfile/pkg/helmexec"
+	"github.com/helmfile/helmfile/pkg/maputil"
+	"github.com/helmfile/helmfile/pkg/yaml"
+)
+
+// chartDefaultValues returns the default values of the chart of the release, which are the values.yaml of a local chart,
+// or the ones shown by `helm show values` for a remote chart
+func (st *HelmState) chartDefaultValues(helm helmexec.Interface, release *ReleaseSpec) (map[string]interface{}, error) {
+	chartName := release.ChartPathOrName()
+
+	var out []byte
+
+	if dir := normalizeChart(st.basePath, chartName); st.fs.DirectoryExistsAt(dir) {
+		valuesFile := filepath.Join(dir, "values.yaml")
+		if !st.fs.FileExistsAt(valuesFile) {
+			return map[string]interface{}{}, nil
+		}
+
+		bs, err := st.fs.ReadFile(valuesFile)
+		if err != nil {
+			return nil, err
+		}
+		out = bs
+	} else {
+		flags := st.chartVersionFlags(release)
+
+		values, err := helm.ShowValues(st.runContext(), chartName, flags...)
+		if err != nil {
+			return nil, fmt.Errorf("showing the default values of chart %s: %w", chartName, err)
+		}
+		out = []byte(values)
+	}
+
+	values := map[string]interface{}{}
+	if err := yaml.Unmarshal(out, &values); err != nil {
+		return nil, fmt.Errorf("unmarshalling the default values of chart %s: %w", chartName, err)
+	}
+
+	return maputil.CastKeysToStrings(values)
+}
+
+// mergeSetValues merges the `--set` and `--set-file` flags into the values, like helm does
+func mergeSetValues(values map[string]interface{}, flags []string) (map[string]interface{}, error) {
+	values, err := maputil.CastKeysToStrings(values)
+	if err != nil {
+		return nil, err
+	}
+
+	readFile := func(rs []rune) (interface{}, error) {
+		bs, err := os.ReadFile(string(rs))
+		if err != nil {
+			return nil, err
+		}
+		return string(bs), nil
+	}
+
+	for i := 0; i+1 < len(flags); i += 2 {
+		switch flags[i] {
+		case "--set":
+			err = strvals.ParseInto(flags[i+1], values)
+		case "--set-file":
+			err = strvals.ParseIntoFile(flags[i+1], values, readFile)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s %s: %w", flags[i], strings.SplitN(flags[i+1], "=", 2)[0], err)
+		}
+	}
+
+	return values, nil
+}
@@ -0,0 +1,79 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/exectest"
+	"github.com/helmfile/helmfile/pkg/filesystem"
+)
+
+func TestHelmState_chartDefaultValues(t *testing.T) {
+	basePath := t.TempDir()
+
+	require.NoError(t, os.MkdirAll(filepath.Join(basePath, "charts", "web"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(basePath, "charts", "web", "values.yaml"), []byte("replicas: 1\nimage:\n  tag: latest\n"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(basePath, "charts", "novalues"), 0755))
+
+	st := &HelmState{
+		basePath: basePath,
+		fs:       filesystem.DefaultFileSystem(),
+		logger:   logger,
+	}
+
+	helm := &exectest.Helm{
+		ChartValues: map[string]string{
+			"bitnami/redis": "architecture: replication\n",
+		},
+	}
+
+	tests := []struct {
+		chart string
+		want  map[string]interface{}
+		err   string
+	}{
+		{chart: "./charts/web", want: map[string]interface{}{"replicas": 1, "image": map[string]interface{}{"tag": "latest"}}},
+		{chart: "./charts/novalues", want: map[string]interface{}{}},
+		{chart: "bitnami/redis", want: map[string]interface{}{"architecture": "replication"}},
+		{chart: "bitnami/missing", err: "showing the default values of chart bitnami/missing: no default values for chart bitnami/missing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.chart, func(t *testing.T) {
+			values, err := st.chartDefaultValues(helm, &ReleaseSpec{Name: "foo", Chart: tt.chart})
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, values)
+		})
+	}
+}
+
+func TestMergeSetValues(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "cert.pem")
+	require.NoError(t, os.WriteFile(file, []byte("CERT"), 0644))
+
+	values := map[string]interface{}{
+		"image": map[interface{}]interface{}{"tag": "1.0", "pullPolicy": "Always"},
+	}
+
+	merged, err := mergeSetValues(values, []string{
+		"--set", "image.tag=2.0,replicas=3",
+		"--set-file", "tls.cert=" + file,
+		"--set", "replicas=5",
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"image":    map[string]interface{}{"tag": "2.0", "pullPolicy": "Always"},
+		"replicas": int64(5),
+		"tls":      map[string]interface{}{"cert": "CERT"},
+	}, merged)
+
+	_, err = mergeSetValues(map[string]interface{}{}, []string{"--set", "a.b[=1"})
+	require.ErrorContains(t, err, "parsing --set a.b[")
+}
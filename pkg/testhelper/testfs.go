@@ -46,6 +46,8 @@ func (f *TestFs) ToFileSystem() *ffs.FileSystem {
 		FileExists:        f.FileExists,
 		DirectoryExistsAt: f.DirectoryExistsAt,
 		ReadFile:          f.ReadFile,
+		WriteFile:         f.WriteFile,
+		MkdirAll:          f.MkdirAll,
 		Glob:              f.Glob,
 		Getwd:             f.Getwd,
 		Chdir:             f.Chdir,
@@ -99,6 +101,26 @@ func (f *TestFs) ReadFile(filename string) ([]byte, error) {
 	return []byte(str), nil
 }
 
+func (f *TestFs) WriteFile(filename string, data []byte, _ os.FileMode) error {
+	abs, _ := f.Abs(filename)
+	if !f.dirs[filepath.ToSlash(filepath.Dir(abs))] {
+		return &os.PathError{Op: "open", Path: filename, Err: os.ErrNotExist}
+	}
+
+	f.files[abs] = string(data)
+
+	return nil
+}
+
+func (f *TestFs) MkdirAll(dir string, _ os.FileMode) error {
+	abs, _ := f.Abs(dir)
+	for d := abs; !f.dirs[d]; d = filepath.ToSlash(filepath.Dir(d)) {
+		f.dirs[d] = true
+	}
+
+	return nil
+}
+
 func (f *TestFs) SuccessfulReads() []string {
 	return f.successfulReads
 }
@@ -1,6 +1,8 @@
 package tmpl
 
 import (
+	"github.com/helmfile/vals"
+
 	"github.com/helmfile/helmfile/pkg/filesystem"
 )
 
@@ -8,6 +10,14 @@ type Context struct {
 	preRender bool
 	basePath  string
 	fs        *filesystem.FileSystem
+	// templateLibraries is the directories of the *.tpl files defining the named templates available to the templates
+	templateLibraries []string
+	// secrets resolves the secret references of `fetchSecretValue` and `expandSecretRefs`, instead of the shared vals runtime
+	secrets vals.Evaluator
+	// helmBinary is the helm binary of the state run by `renderChart` and `chartValues`
+	helmBinary string
+	// clusterLookups is whether `kubectlGet` is allowed to query the cluster, and the kube context it queries
+	clusterLookups ClusterLookups
 }
 
 // SetBasePath sets the base path for the template
@@ -18,3 +28,25 @@ func (c *Context) SetBasePath(path string) {
 func (c *Context) SetFileSystem(fs *filesystem.FileSystem) {
 	c.fs = fs
 }
+
+// SetTemplateLibraries sets the directories of the *.tpl files defining the named templates
+// that can be used by the template with `{{ template "name" . }}` and `{{ include "name" . }}`
+func (c *Context) SetTemplateLibraries(dirs []string) {
+	c.templateLibraries = dirs
+}
+
+// SetHelmBinary sets the helm binary run by the template, like the one of `--helm-binary` or `helmBinary` of the state
+func (c *Context) SetHelmBinary(bin string) {
+	c.helmBinary = bin
+}
+
+// SetClusterLookups allows or disallows the template to query the cluster of the kube context with `kubectlGet`
+func (c *Context) SetClusterLookups(l ClusterLookups) {
+	c.clusterLookups = l
+}
+
+// SetSecretsEvaluator sets the evaluator resolving the secret references of the template,
+// like the one configured by the secrets backends of the state
+func (c *Context) SetSecretsEvaluator(e vals.Evaluator) {
+	c.secrets = e
+}
@@ -1,6 +1,8 @@
 package tmpl
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -13,6 +15,8 @@ import (
 	"strings"
 	"text/template"
 
+	"github.com/BurntSushi/toml"
+	"github.com/imdario/mergo"
 	"golang.org/x/sync/errgroup"
 
 	"github.com/helmfile/helmfile/pkg/envvar"
@@ -53,14 +57,24 @@ func (c *Context) createFuncMap() template.FuncMap {
 		"readDirEntries":   c.ReadDirEntries,
 		"toYaml":           ToYaml,
 		"fromYaml":         FromYaml,
+		"toJson":           ToJson,
+		"toPrettyJson":     ToPrettyJson,
+		"fromJson":         FromJson,
+		"toToml":           ToToml,
+		"fromToml":         FromToml,
+		"deepMerge":        DeepMerge,
 		"setValueAtPath":   SetValueAtPath,
 		"requiredEnv":      RequiredEnv,
 		"get":              get,
 		"getOrNil":         getOrNil,
 		"tpl":              c.Tpl,
 		"required":         Required,
-		"fetchSecretValue": fetchSecretValue,
-		"expandSecretRefs": fetchSecretValues,
+		"fail":             Fail,
+		"fetchSecretValue": c.FetchSecretValue,
+		"expandSecretRefs": c.FetchSecretValues,
+		"renderChart":      c.RenderChart,
+		"chartValues":      c.ChartValues,
+		"kubectlGet":       c.KubectlGet,
 	}
 	if c.preRender || skipInsecureTemplateFunctions {
 		// disable potential side-effect template calls
@@ -79,6 +93,15 @@ func (c *Context) createFuncMap() template.FuncMap {
 		funcMap["readDirEntries"] = func(string) ([]fs.DirEntry, error) {
 			return []fs.DirEntry{}, nil
 		}
+		funcMap["renderChart"] = func(string, Values, ...string) ([]interface{}, error) {
+			return []interface{}{}, nil
+		}
+		funcMap["chartValues"] = func(string, ...string) (Values, error) {
+			return Values{}, nil
+		}
+		funcMap["kubectlGet"] = func(string, string, ...string) (Values, error) {
+			return Values{}, nil
+		}
 	}
 	if disableInsecureFeatures {
 		// disable insecure functions
@@ -94,6 +117,15 @@ func (c *Context) createFuncMap() template.FuncMap {
 		funcMap["readDirEntries"] = func(string) ([]string, error) {
 			return nil, DisableInsecureFeaturesErr
 		}
+		funcMap["renderChart"] = func(string, Values, ...string) ([]interface{}, error) {
+			return nil, DisableInsecureFeaturesErr
+		}
+		funcMap["chartValues"] = func(string, ...string) (Values, error) {
+			return nil, DisableInsecureFeaturesErr
+		}
+		funcMap["kubectlGet"] = func(string, string, ...string) (Values, error) {
+			return nil, DisableInsecureFeaturesErr
+		}
 	}
 
 	return funcMap
@@ -130,8 +162,8 @@ func (c *Context) EnvExec(envs map[string]interface{}, command string, args []in
 
 	cmd := exec.Command(command, strArgs...)
 	cmd.Dir = c.basePath
+	cmd.Env = envvar.Environ()
 	if envs != nil {
-		cmd.Env = os.Environ()
 		for k, v := range envs {
 			cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
 		}
@@ -301,6 +333,103 @@ func FromYaml(str string) (Values, error) {
 	return m, nil
 }
 
+// stringifyKeys returns the copy of the map whose keys are all strings, as the maps decoded from YAML may have interface{} keys,
+// or the value as is if it isn't a map
+func stringifyKeys(v interface{}) (interface{}, error) {
+	switch v.(type) {
+	case map[interface{}]interface{}, map[string]interface{}:
+		return maputil.CastKeysToStrings(v)
+	}
+	return v, nil
+}
+
+func ToJson(v interface{}) (string, error) {
+	v, err := stringifyKeys(v)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func ToPrettyJson(v interface{}) (string, error) {
+	v, err := stringifyKeys(v)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func FromJson(str string) (Values, error) {
+	m := map[string]interface{}{}
+
+	if err := json.Unmarshal([]byte(str), &m); err != nil {
+		return nil, fmt.Errorf("%s, offending json: %s", err, str)
+	}
+
+	return m, nil
+}
+
+func ToToml(v interface{}) (string, error) {
+	v, err := stringifyKeys(v)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(v); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func FromToml(str string) (Values, error) {
+	m := map[string]interface{}{}
+
+	if err := toml.Unmarshal([]byte(str), &m); err != nil {
+		return nil, fmt.Errorf("%s, offending toml: %s", err, str)
+	}
+
+	return m, nil
+}
+
+// DeepMerge merges the maps recursively into a new map, the latter ones taking precedence over the former ones,
+// the same way as environment values are merged.
+// The maps given are left untouched.
+func DeepMerge(values ...interface{}) (Values, error) {
+	merged := Values{}
+
+	for i, v := range values {
+		if v == nil {
+			continue
+		}
+
+		switch v.(type) {
+		case map[interface{}]interface{}, map[string]interface{}:
+		default:
+			return nil, fmt.Errorf("deepMerge: argument %d must be a map, but got %T", i, v)
+		}
+
+		// CastKeysToStrings copies the nested maps, so that merging doesn't modify the given ones
+		m, err := maputil.CastKeysToStrings(v)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := mergo.Merge(&merged, m, mergo.WithOverride, mergo.WithOverwriteWithEmptyValue); err != nil {
+			return nil, err
+		}
+	}
+
+	return merged, nil
+}
+
 func SetValueAtPath(path string, value interface{}, values Values) (Values, error) {
 	var current interface{}
 	current = values
@@ -356,10 +485,10 @@ func RequiredEnv(name string) (string, error) {
 
 func Required(warn string, val interface{}) (interface{}, error) {
 	if val == nil {
-		return nil, fmt.Errorf(warn)
+		return nil, &FailError{Message: warn}
 	} else if _, ok := val.(string); ok {
 		if val == "" {
-			return nil, fmt.Errorf(warn)
+			return nil, &FailError{Message: warn}
 		}
 	}
 
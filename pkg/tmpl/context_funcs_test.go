@@ -278,6 +278,97 @@ func TestFromYamlToJson(t *testing.T) {
 	require.Equal(t, string(got), want)
 }
 
+func TestToJson(t *testing.T) {
+	// nolint: unconvert
+	vals := Values(map[string]interface{}{
+		"foo": map[interface{}]interface{}{
+			"bar": "BAR",
+		},
+	})
+
+	actual, err := ToJson(vals)
+	require.NoError(t, err)
+	require.Equal(t, `{"foo":{"bar":"BAR"}}`, actual)
+
+	actual, err = ToPrettyJson(vals)
+	require.NoError(t, err)
+	require.Equal(t, "{\n  \"foo\": {\n    \"bar\": \"BAR\"\n  }\n}", actual)
+
+	actual, err = ToJson([]interface{}{"a", 1})
+	require.NoError(t, err)
+	require.Equal(t, `["a",1]`, actual)
+}
+
+func TestFromJson(t *testing.T) {
+	actual, err := FromJson(`{"foo":{"bar":"BAR"}}`)
+	require.NoError(t, err)
+	require.Equal(t, Values{"foo": map[string]interface{}{"bar": "BAR"}}, actual)
+
+	_, err = FromJson(`{"foo":`)
+	require.EqualError(t, err, "unexpected end of JSON input, offending json: {\"foo\":")
+}
+
+func TestToTomlFromToml(t *testing.T) {
+	// nolint: unconvert
+	vals := Values(map[string]interface{}{
+		"title": "app",
+		"server": map[interface{}]interface{}{
+			"port":  8080,
+			"hosts": []interface{}{"a", "b"},
+		},
+	})
+
+	actual, err := ToToml(vals)
+	require.NoError(t, err)
+	require.Equal(t, "title = \"app\"\n\n[server]\n  hosts = [\"a\", \"b\"]\n  port = 8080\n", actual)
+
+	m, err := FromToml(actual)
+	require.NoError(t, err)
+	require.Equal(t, Values{
+		"title": "app",
+		"server": map[string]interface{}{
+			"port":  int64(8080),
+			"hosts": []interface{}{"a", "b"},
+		},
+	}, m)
+
+	_, err = FromToml("title = ")
+	require.Error(t, err)
+}
+
+func TestDeepMerge(t *testing.T) {
+	base := map[string]interface{}{
+		"image": map[string]interface{}{
+			"repository": "nginx",
+			"tag":        "1.0",
+		},
+		"replicas": 1,
+		"ports":    []interface{}{80, 443},
+	}
+	overrides := map[interface{}]interface{}{
+		"image": map[interface{}]interface{}{
+			"tag": "2.0",
+		},
+		"ports": []interface{}{8080},
+	}
+
+	merged, err := DeepMerge(base, nil, overrides)
+	require.NoError(t, err)
+	require.Equal(t, Values{
+		"image": map[string]interface{}{
+			"repository": "nginx",
+			"tag":        "2.0",
+		},
+		"replicas": 1,
+		"ports":    []interface{}{8080},
+	}, merged)
+
+	require.Equal(t, "1.0", base["image"].(map[string]interface{})["tag"], "deepMerge should not modify the given maps")
+
+	_, err = DeepMerge(base, "foo")
+	require.EqualError(t, err, "deepMerge: argument 1 must be a map, but got string")
+}
+
 func TestSetValueAtPath_OneComponent(t *testing.T) {
 	input := map[string]interface{}{
 		"foo": "",
@@ -2,6 +2,10 @@ package tmpl
 
 import (
 	"bytes"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
 	"text/template"
 
 	"github.com/Masterminds/sprig/v3"
@@ -31,10 +35,23 @@ func (c *Context) CreateFuncMap() template.FuncMap {
 	return funcMap
 }
 
+// TemplateName is the name of the template the content is rendered as, which the errors of rendering it are located with
+const TemplateName = "stringTemplate"
+
 func (c *Context) newTemplate() *template.Template {
 	funcMap := c.CreateFuncMap()
 
-	tmpl := template.New("stringTemplate").Funcs(funcMap)
+	tmpl := template.New(TemplateName).Funcs(funcMap)
+	tmpl = tmpl.Funcs(template.FuncMap{
+		// include renders the named template like the template action, but returns the result to be piped to other functions like nindent
+		"include": func(name string, data interface{}) (string, error) {
+			var buf strings.Builder
+			if err := tmpl.ExecuteTemplate(&buf, name, data); err != nil {
+				return "", err
+			}
+			return buf.String(), nil
+		},
+	})
 	if c.preRender {
 		tmpl = tmpl.Option("missingkey=zero")
 	} else {
@@ -43,8 +60,39 @@ func (c *Context) newTemplate() *template.Template {
 	return tmpl
 }
 
+// parseTemplateLibraries parses the *.tpl files in the template libraries into the template, in the order of the libraries and the file names
+func (c *Context) parseTemplateLibraries(t *template.Template) error {
+	for _, dir := range c.templateLibraries {
+		files, err := c.fs.Glob(filepath.Join(dir, "*.tpl"))
+		if err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			return fmt.Errorf("template library %s: no *.tpl files found", dir)
+		}
+		sort.Strings(files)
+
+		for _, f := range files {
+			content, err := c.fs.ReadFile(f)
+			if err != nil {
+				return fmt.Errorf("template library %s: %v", dir, err)
+			}
+			if _, err := t.New(f).Parse(string(content)); err != nil {
+				return fmt.Errorf("template library %s: %v", dir, err)
+			}
+		}
+	}
+
+	return nil
+}
+
 func (c *Context) RenderTemplateToBuffer(s string, data ...interface{}) (*bytes.Buffer, error) {
-	var t, parseErr = c.newTemplate().Parse(s)
+	t := c.newTemplate()
+	if err := c.parseTemplateLibraries(t); err != nil {
+		return nil, err
+	}
+
+	t, parseErr := t.Parse(s)
 	if parseErr != nil {
 		return nil, parseErr
 	}
@@ -326,3 +326,52 @@ func TestRenderTemplate_Required(t *testing.T) {
 		}
 	}
 }
+
+func TestRenderTemplate_TemplateLibraries(t *testing.T) {
+	files := map[string]string{
+		"lib/_release.tpl": `{{ define "release.defaults" }}namespace: {{ .namespace }}
+chart: {{ .chart }}{{ end }}`,
+		"lib/_labels.tpl": `{{ define "labels" }}team: {{ . }}{{ end }}`,
+	}
+	ctx := &Context{fs: &ffs.FileSystem{
+		ReadFile: func(filename string) ([]byte, error) {
+			content, ok := files[filename]
+			if !ok {
+				return nil, fmt.Errorf("unexpected filename: %s", filename)
+			}
+			return []byte(content), nil
+		},
+		Glob: func(pattern string) ([]string, error) {
+			if pattern == "empty/*.tpl" {
+				return nil, nil
+			}
+			return []string{"lib/_release.tpl", "lib/_labels.tpl"}, nil
+		},
+	}}
+	ctx.SetTemplateLibraries([]string{"lib"})
+
+	buf, err := ctx.RenderTemplateToBuffer(`- name: web
+  {{- include "release.defaults" (dict "namespace" "web" "chart" "./charts/web") | nindent 2 }}
+  labels:
+    {{ template "labels" "frontend" }}
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := `- name: web
+  namespace: web
+  chart: ./charts/web
+  labels:
+    team: frontend
+`
+	if actual := buf.String(); actual != expected {
+		t.Errorf("unexpected result: expected=%v, actual=%v", expected, actual)
+	}
+
+	ctx.SetTemplateLibraries([]string{"empty"})
+
+	_, err = ctx.RenderTemplateToBuffer(`foo`)
+	if err == nil || err.Error() != "template library empty: no *.tpl files found" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -17,10 +17,30 @@ type valClient interface {
 var once sync.Once
 var secretsClient valClient
 
+// FetchSecretValue resolves the secret reference with the evaluator of the context, or the shared vals runtime
+func (c *Context) FetchSecretValue(path string) (string, error) {
+	if c.secrets == nil {
+		return fetchSecretValue(path)
+	}
+	return fetchSecretValueWith(c.secrets.Eval, path)
+}
+
+// FetchSecretValues resolves the secret references in the values with the evaluator of the context, or the shared vals runtime
+func (c *Context) FetchSecretValues(values map[string]interface{}) (map[string]interface{}, error) {
+	if c.secrets == nil {
+		return fetchSecretValues(values)
+	}
+	return c.secrets.Eval(values)
+}
+
 func fetchSecretValue(path string) (string, error) {
+	return fetchSecretValueWith(fetchSecretValues, path)
+}
+
+func fetchSecretValueWith(eval func(map[string]interface{}) (map[string]interface{}, error), path string) (string, error) {
 	tmpMap := make(map[string]interface{})
 	tmpMap["key"] = path
-	resultMap, err := fetchSecretValues(tmpMap)
+	resultMap, err := eval(tmpMap)
 	if err != nil {
 		return "", err
 	}
@@ -77,3 +77,25 @@ func Test_fetchSecretValue_invalid_type(t *testing.T) {
 	assert.Error(t, err, "expected 10 to be string")
 	assert.Equal(t, result, "")
 }
+
+func Test_Context_FetchSecretValue(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+	shared := NewMockvalClient(controller)
+	secretsClient = shared
+
+	c := NewMockvalClient(controller)
+	ctx := &Context{}
+	ctx.SetSecretsEvaluator(c)
+
+	secretPath := "ref+vault://key/#path"
+	expectArg := make(map[string]interface{})
+	expectArg["key"] = secretPath
+
+	valsResult := make(map[string]interface{})
+	valsResult["key"] = "key_value"
+	c.EXPECT().Eval(expectArg).Return(valsResult, nil)
+	result, err := ctx.FetchSecretValue(secretPath)
+	assert.Nil(t, err)
+	assert.Equal(t, "key_value", result)
+}
@@ -0,0 +1,40 @@
+package tmpl
+
+import (
+	"regexp"
+	"strconv"
+)
+
+// FailError is the error raised on purpose by the template with `required` or `fail`,
+// whose message is written by the template author for the users of the template
+type FailError struct {
+	Message string
+}
+
+func (e *FailError) Error() string {
+	return e.Message
+}
+
+// Fail fails rendering the template with the message
+func Fail(msg string) (string, error) {
+	return "", &FailError{Message: msg}
+}
+
+// errorLocationRegexp matches the location text/template prefixes the parse and execution errors with
+var errorLocationRegexp = regexp.MustCompile(`^template: (.+?):(\d+)(?::\d+)?: `)
+
+// ErrorLocation returns the name of the template and the line in it the error of parsing or executing the template occurred at,
+// or an empty name and 0 when the error isn't located
+func ErrorLocation(err error) (string, int) {
+	m := errorLocationRegexp.FindStringSubmatch(err.Error())
+	if m == nil {
+		return "", 0
+	}
+
+	line, err := strconv.Atoi(m[2])
+	if err != nil {
+		return "", 0
+	}
+
+	return m[1], line
+}
@@ -0,0 +1,42 @@
+package tmpl
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderTemplate_Fail(t *testing.T) {
+	ctx := &Context{basePath: "."}
+
+	_, err := ctx.RenderTemplateToBuffer("name: web\n{{ if not .domain }}{{ fail \"the domain is required\" }}{{ end }}", map[string]interface{}{"domain": ""})
+	require.Error(t, err)
+
+	var failErr *FailError
+	require.True(t, errors.As(err, &failErr))
+	require.Equal(t, "the domain is required", failErr.Message)
+
+	name, line := ErrorLocation(err)
+	require.Equal(t, TemplateName, name)
+	require.Equal(t, 2, line)
+}
+
+func TestErrorLocation(t *testing.T) {
+	tests := []struct {
+		err  string
+		name string
+		line int
+	}{
+		{err: `template: stringTemplate:3:14: executing "stringTemplate" at <required "x" .foo>: error calling required: x`, name: "stringTemplate", line: 3},
+		{err: `template: stringTemplate:6: unclosed left paren`, name: "stringTemplate", line: 6},
+		{err: `template: lib/_release.tpl:2:3: executing "release" at <.chart>: map has no entry for key "chart"`, name: "lib/_release.tpl", line: 2},
+		{err: `failed to read values.yaml`, name: "", line: 0},
+	}
+
+	for _, tt := range tests {
+		name, line := ErrorLocation(errors.New(tt.err))
+		require.Equal(t, tt.name, name, tt.err)
+		require.Equal(t, tt.line, line, tt.err)
+	}
+}
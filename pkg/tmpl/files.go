@@ -0,0 +1,126 @@
+package tmpl
+
+import (
+	"encoding/base64"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/helmfile/helmfile/pkg/filesystem"
+	"github.com/helmfile/helmfile/pkg/yaml"
+)
+
+// Files gives the templates access to the files in a directory, like `.Files` of Helm charts.
+// It is accessible as `.Files` from the state templates and the values templates, scoped to the directory of the state file.
+type Files struct {
+	fs       *filesystem.FileSystem
+	basePath string
+
+	// names are the paths to the files selected by Glob, relative to basePath
+	names []string
+}
+
+// NewFiles returns the Files scoped to the directory
+func NewFiles(fs *filesystem.FileSystem, basePath string) *Files {
+	return &Files{fs: fs, basePath: basePath}
+}
+
+// path returns the path to the file, which must be relative to and within the directory
+func (f *Files) path(name string) (string, error) {
+	clean := filepath.Clean(name)
+	if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%q is not within the directory of the state file", name)
+	}
+	return filepath.Join(f.basePath, clean), nil
+}
+
+// Get returns the content of the file
+func (f *Files) Get(name string) (string, error) {
+	path, err := f.path(name)
+	if err != nil {
+		return "", err
+	}
+
+	bs, err := f.fs.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return string(bs), nil
+}
+
+// Glob returns the files matching the pattern, excluding the directories
+func (f *Files) Glob(pattern string) (*Files, error) {
+	path, err := f.path(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	matches, err := f.fs.Glob(path)
+	if err != nil {
+		return nil, fmt.Errorf("Glob %q: %w", pattern, err)
+	}
+
+	names := []string{}
+	for _, m := range matches {
+		if f.fs.DirectoryExistsAt(m) {
+			continue
+		}
+		name, err := filepath.Rel(f.basePath, m)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return &Files{fs: f.fs, basePath: f.basePath, names: names}, nil
+}
+
+// Names returns the paths to the files selected by Glob
+func (f *Files) Names() []string {
+	return f.names
+}
+
+// AsConfig returns the files selected by Glob as a YAML mapping of their base names to their contents,
+// to be embedded as the data of a ConfigMap
+func (f *Files) AsConfig() (string, error) {
+	return f.asMap(func(bs []byte) string {
+		return string(bs)
+	})
+}
+
+// AsSecrets returns the files selected by Glob as a YAML mapping of their base names to their base64-encoded contents,
+// to be embedded as the data of a Secret
+func (f *Files) AsSecrets() (string, error) {
+	return f.asMap(func(bs []byte) string {
+		return base64.StdEncoding.EncodeToString(bs)
+	})
+}
+
+func (f *Files) asMap(encode func([]byte) string) (string, error) {
+	if f.names == nil {
+		return "", fmt.Errorf("no files selected: select the files with Glob first, like `(.Files.Glob \"config/*\").AsConfig`")
+	}
+
+	m := map[string]string{}
+	for _, name := range f.names {
+		bs, err := f.fs.ReadFile(filepath.Join(f.basePath, name))
+		if err != nil {
+			return "", err
+		}
+		m[filepath.Base(name)] = encode(bs)
+	}
+
+	if len(m) == 0 {
+		return "", nil
+	}
+
+	bs, err := yaml.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(string(bs), "\n"), nil
+}
@@ -0,0 +1,51 @@
+package tmpl
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/filesystem"
+)
+
+func TestFiles(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "config", "nested"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config", "app.conf"), []byte("key: value\nother: 1\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config", "motd"), []byte("hello"), 0644))
+
+	files := NewFiles(filesystem.DefaultFileSystem(), dir)
+
+	content, err := files.Get("config/motd")
+	require.NoError(t, err)
+	require.Equal(t, "hello", content)
+
+	_, err = files.Get("../outside")
+	require.EqualError(t, err, `"../outside" is not within the directory of the state file`)
+
+	_, err = files.Get("/etc/passwd")
+	require.Error(t, err)
+
+	_, err = files.AsConfig()
+	require.Error(t, err, "AsConfig without Glob should fail")
+
+	selected, err := files.Glob("config/*")
+	require.NoError(t, err)
+	require.Equal(t, []string{filepath.Join("config", "app.conf"), filepath.Join("config", "motd")}, selected.Names())
+
+	config, err := selected.AsConfig()
+	require.NoError(t, err)
+	require.Equal(t, "app.conf: |\n  key: value\n  other: 1\nmotd: hello", config)
+
+	secrets, err := selected.AsSecrets()
+	require.NoError(t, err)
+	require.Equal(t, "app.conf: a2V5OiB2YWx1ZQpvdGhlcjogMQo=\nmotd: aGVsbG8=", secrets)
+
+	none, err := files.Glob("nothing/*")
+	require.NoError(t, err)
+	config, err = none.AsConfig()
+	require.NoError(t, err)
+	require.Equal(t, "", config)
+}
@@ -0,0 +1,58 @@
+package tmpl
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/helmfile/helmfile/pkg/helmexec"
+)
+
+// ClusterLookups is whether kubectlGet is allowed to query the cluster, and the kube context it queries.
+// The lookups are disallowed by default, as the templates querying the cluster render differently depending on the live cluster.
+type ClusterLookups struct {
+	Allowed bool
+	// KubeContext is the kube context queried, or the current context when it's empty
+	KubeContext string
+}
+
+// KubectlGet returns the object of the kind and the name in the namespace read from the cluster by `kubectl get`,
+// or the list of all the objects of the kind when the name is empty.
+// The namespace defaults to the one of the kube context.
+// It returns an empty map when the object doesn't exist, so that the template can fall back to the defaults, like `lookup` of helm.
+func (c *Context) KubectlGet(kind, name string, namespace ...string) (Values, error) {
+	if !c.clusterLookups.Allowed {
+		return nil, fmt.Errorf("kubectlGet %s %s: querying the cluster is disallowed. Run helmfile with --allow-cluster-lookups to allow it", kind, name)
+	}
+
+	if len(namespace) > 1 {
+		return nil, fmt.Errorf("kubectlGet %s %s: want at most one namespace, got %d", kind, name, len(namespace))
+	}
+
+	args := []interface{}{"get", kind}
+	if name != "" {
+		args = append(args, name)
+	}
+	if len(namespace) == 1 && namespace[0] != "" {
+		args = append(args, "--namespace", namespace[0])
+	}
+	if c.clusterLookups.KubeContext != "" {
+		args = append(args, "--context", c.clusterLookups.KubeContext)
+	}
+	args = append(args, "--ignore-not-found", "--output", "json")
+
+	out, err := c.Exec(helmexec.KubectlBinary(), args)
+	if err != nil {
+		return nil, fmt.Errorf("kubectlGet %s %s: %w", kind, name, err)
+	}
+
+	if strings.TrimSpace(out) == "" {
+		return Values{}, nil
+	}
+
+	obj, err := FromJson(out)
+	if err != nil {
+		return nil, fmt.Errorf("kubectlGet %s %s: %w", kind, name, err)
+	}
+
+	return obj, nil
+}
@@ -0,0 +1,89 @@
+package tmpl
+
+import (
+	"os"
+	"path/filepath"
+	goruntime "runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/helmfile/helmfile/pkg/envvar"
+)
+
+// fakeKubectl creates a script printing the given output and the args to the file, to be run as kubectl
+func fakeKubectl(t *testing.T, output string) string {
+	t.Helper()
+
+	if goruntime.GOOS == "windows" {
+		t.Skip("the fake kubectl is a shell script")
+	}
+
+	dir := t.TempDir()
+	outFile := filepath.Join(dir, "output.json")
+	calls := filepath.Join(dir, "calls")
+	script := filepath.Join(dir, "kubectl")
+
+	require.NoError(t, os.WriteFile(outFile, []byte(output), 0644))
+	require.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\necho \"$@\" >> "+calls+"\ncat "+outFile+"\n"), 0755))
+
+	t.Setenv(envvar.KubectlBinary, script)
+
+	return calls
+}
+
+func TestKubectlGet(t *testing.T) {
+	calls := fakeKubectl(t, `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"settings","namespace":"app"},"data":{"replicas":"3"}}`)
+
+	ctx := &Context{basePath: "."}
+
+	_, err := ctx.KubectlGet("configmap", "settings", "app")
+	require.EqualError(t, err, "kubectlGet configmap settings: querying the cluster is disallowed. Run helmfile with --allow-cluster-lookups to allow it")
+
+	ctx.SetClusterLookups(ClusterLookups{Allowed: true, KubeContext: "prod"})
+
+	obj, err := ctx.KubectlGet("configmap", "settings", "app")
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"replicas": "3"}, obj["data"])
+
+	bs, err := os.ReadFile(calls)
+	require.NoError(t, err)
+	require.Equal(t, "get configmap settings --namespace app --context prod --ignore-not-found --output json\n", string(bs))
+}
+
+func TestKubectlGet_NotFound(t *testing.T) {
+	fakeKubectl(t, "")
+
+	ctx := &Context{basePath: "."}
+	ctx.SetClusterLookups(ClusterLookups{Allowed: true})
+
+	obj, err := ctx.KubectlGet("secret", "missing")
+	require.NoError(t, err)
+	require.Equal(t, Values{}, obj)
+}
+
+func TestKubectlGet_Template(t *testing.T) {
+	fakeKubectl(t, `{"data":{"replicas":"3"}}`)
+
+	ctx := &Context{basePath: "."}
+	ctx.SetClusterLookups(ClusterLookups{Allowed: true})
+
+	out, err := ctx.RenderTemplateToBuffer(`replicas: {{ (kubectlGet "configmap" "settings" "app").data.replicas | default "1" }}`)
+	require.NoError(t, err)
+	require.Equal(t, "replicas: 3", out.String())
+}
+
+func TestKubectlGet_OwnClusterLookups(t *testing.T) {
+	fakeKubectl(t, `{"data":{"replicas":"3"}}`)
+
+	allowed := &Context{basePath: "."}
+	allowed.SetClusterLookups(ClusterLookups{Allowed: true})
+
+	disallowed := &Context{basePath: "."}
+
+	_, err := allowed.KubectlGet("configmap", "settings")
+	require.NoError(t, err)
+
+	_, err = disallowed.KubectlGet("configmap", "settings")
+	require.ErrorContains(t, err, "querying the cluster is disallowed")
+}
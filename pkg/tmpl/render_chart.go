@@ -0,0 +1,97 @@
+package tmpl
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/helmfile/helmfile/pkg/maputil"
+	"github.com/helmfile/helmfile/pkg/yaml"
+)
+
+// renderChartReleaseName is the release name the charts are rendered with by renderChart, which is the same as `helm template` defaults to
+const renderChartReleaseName = "release-name"
+
+// defaultHelmBinary is the helm binary renderChart and chartValues run unless the helm binary of the state is set to the context
+const defaultHelmBinary = "helm"
+
+func (c *Context) getHelmBinary() string {
+	if c.helmBinary != "" {
+		return c.helmBinary
+	}
+	return defaultHelmBinary
+}
+
+// RenderChart renders the chart, either local or remote, with the values by `helm template`,
+// and returns the rendered manifests, so that the state can be composed of them, like the list of the CRDs of the chart.
+// The flags are passed to `helm template` as they are, like `--version 1.2.3` and `--include-crds`.
+func (c *Context) RenderChart(chart string, values Values, flags ...string) ([]interface{}, error) {
+	vals, err := ToYaml(values)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []interface{}{"template", renderChartReleaseName, chart, "--values", "-"}
+	for _, f := range flags {
+		args = append(args, f)
+	}
+
+	out, err := c.Exec(c.getHelmBinary(), args, vals)
+	if err != nil {
+		return nil, fmt.Errorf("renderChart %s: %w", chart, err)
+	}
+
+	manifests, err := decodeManifests(out)
+	if err != nil {
+		return nil, fmt.Errorf("renderChart %s: %w", chart, err)
+	}
+
+	return manifests, nil
+}
+
+// ChartValues returns the default values of the chart, either local or remote, by `helm show values`.
+// The flags are passed to `helm show values` as they are, like `--version 1.2.3`.
+func (c *Context) ChartValues(chart string, flags ...string) (Values, error) {
+	args := []interface{}{"show", "values", chart}
+	for _, f := range flags {
+		args = append(args, f)
+	}
+
+	out, err := c.Exec(c.getHelmBinary(), args)
+	if err != nil {
+		return nil, fmt.Errorf("chartValues %s: %w", chart, err)
+	}
+
+	return FromYaml(out)
+}
+
+// decodeManifests decodes the multi-document YAML into the list of the manifests, skipping the empty documents
+func decodeManifests(out string) ([]interface{}, error) {
+	decode := yaml.NewDecoder([]byte(out), false)
+
+	manifests := []interface{}{}
+
+	for {
+		m := map[string]interface{}{}
+
+		err := decode(&m)
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("decoding manifest: %v", err)
+		}
+
+		if len(m) == 0 {
+			continue
+		}
+
+		m, err = maputil.CastKeysToStrings(m)
+		if err != nil {
+			return nil, err
+		}
+
+		manifests = append(manifests, m)
+	}
+
+	return manifests, nil
+}
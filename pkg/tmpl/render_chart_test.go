@@ -0,0 +1,83 @@
+package tmpl
+
+import (
+	"os"
+	"path/filepath"
+	goruntime "runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeHelm creates a script printing the given output and the args along with the stdin to the file, to be run as helm
+func fakeHelm(t *testing.T, output string) (string, string) {
+	t.Helper()
+
+	if goruntime.GOOS == "windows" {
+		t.Skip("the fake helm is a shell script")
+	}
+
+	dir := t.TempDir()
+	outFile := filepath.Join(dir, "output.yaml")
+	calls := filepath.Join(dir, "calls")
+	script := filepath.Join(dir, "helm")
+
+	require.NoError(t, os.WriteFile(outFile, []byte(output), 0644))
+	require.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\necho \"$@\" >> "+calls+"\ncat >> "+calls+"\ncat "+outFile+"\n"), 0755))
+
+	return script, calls
+}
+
+func TestRenderChart(t *testing.T) {
+	helm, calls := fakeHelm(t, `---
+# Source: cert-manager/templates/crds.yaml
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: certificates.cert-manager.io
+---
+# Source: cert-manager/templates/empty.yaml
+---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: cert-manager
+`)
+
+	ctx := &Context{basePath: "."}
+	ctx.SetHelmBinary(helm)
+
+	manifests, err := ctx.RenderChart("jetstack/cert-manager", Values{"installCRDs": true}, "--version", "v1.11.0")
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{
+		map[string]interface{}{
+			"apiVersion": "apiextensions.k8s.io/v1",
+			"kind":       "CustomResourceDefinition",
+			"metadata":   map[string]interface{}{"name": "certificates.cert-manager.io"},
+		},
+		map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ServiceAccount",
+			"metadata":   map[string]interface{}{"name": "cert-manager"},
+		},
+	}, manifests)
+
+	bs, err := os.ReadFile(calls)
+	require.NoError(t, err)
+	require.Equal(t, "template release-name jetstack/cert-manager --values - --version v1.11.0\ninstallCRDs: true\n", string(bs))
+}
+
+func TestChartValues(t *testing.T) {
+	helm, calls := fakeHelm(t, "fullnameOverride: web\nimage:\n  tag: 1.0.0\n")
+
+	ctx := &Context{basePath: "."}
+	ctx.SetHelmBinary(helm)
+
+	values, err := ctx.ChartValues("charts/web")
+	require.NoError(t, err)
+	require.Equal(t, Values{"fullnameOverride": "web", "image": map[string]interface{}{"tag": "1.0.0"}}, values)
+
+	bs, err := os.ReadFile(calls)
+	require.NoError(t, err)
+	require.Equal(t, "show values charts/web\n", string(bs))
+}